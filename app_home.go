@@ -0,0 +1,117 @@
+package clio
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/boss-net/fangs"
+)
+
+// AppHomeEnvVar returns the environment variable relocating all the directories of the application (e.g.
+// "APP_APP_HOME", see SetupConfig.WithAppHome), which is inherited by child processes.
+func AppHomeEnvVar(appName string) string {
+	return envVarName(appName, []string{"app", "home"})
+}
+
+// appHome returns the directory all the directories of the application are relocated to, or empty when not
+// overridden.
+func appHome(appName string) string {
+	return os.Getenv(AppHomeEnvVar(appName))
+}
+
+// userConfigDir returns the directory for user config (within which the application has its own directory), which is
+// within the app home when overridden.
+func userConfigDir(appName string) (string, error) {
+	if home := appHome(appName); home != "" {
+		return filepath.Join(home, "config"), nil
+	}
+	return os.UserConfigDir()
+}
+
+// userCacheDir returns the directory for cached data (within which the application has its own directory), which is
+// within the app home when overridden.
+func userCacheDir(appName string) (string, error) {
+	if home := appHome(appName); home != "" {
+		return filepath.Join(home, "cache"), nil
+	}
+	return os.UserCacheDir()
+}
+
+// WithAppHome adds an --app-home flag to all commands, which relocates the config, cache, state, and log directories of
+// the application to the given directory (as does the environment variable named by AppHomeEnvVar, even without the
+// flag). This isolates concurrent users or test harnesses sharing a machine: the config file is only found within
+// the app home (or given with --config), and the workspace and system-wide config files are still layered as usual.
+func (c *SetupConfig) WithAppHome() *SetupConfig {
+	return c.withPostConstructs(func(a *application) {
+		a.root.PersistentFlags().Var(&appHomeFlag{appName: a.setupConfig.ID.Name}, "app-home",
+			"relocate the config, cache, state, and log directories to the given directory (e.g. to isolate users on shared machines)")
+	})
+}
+
+// appHomeFlag is the --app-home flag, which sets the environment variable so that all directories (including those of
+// child processes) are relocated from the time the flag is parsed.
+type appHomeFlag struct {
+	appName string
+}
+
+func (f *appHomeFlag) String() string {
+	return appHome(f.appName)
+}
+
+func (f *appHomeFlag) Set(value string) error {
+	dir, err := filepath.Abs(value)
+	if err != nil {
+		return err
+	}
+	return os.Setenv(AppHomeEnvVar(f.appName), dir)
+}
+
+func (f *appHomeFlag) Type() string {
+	return "dir"
+}
+
+// configFinders returns how the config file is found: only within the app home when overridden (or as given with
+// --config), and otherwise as configured.
+func (a *application) configFinders() []fangs.Finder {
+	home := appHome(a.setupConfig.ID.Name)
+	if home == "" {
+		return a.setupConfig.FangsConfig.Finders
+	}
+	dir := filepath.Join(home, "config", a.setupConfig.ID.Name)
+	return []fangs.Finder{
+		fangs.FindDirect,
+		func(fangs.Config) []string {
+			var files []string
+			for _, name := range []string{"config.yaml", "config.yml", "config.json", "config.toml"} {
+				files = append(files, filepath.Join(dir, name))
+			}
+			return files
+		},
+	}
+}
+
+// appHomeConfig shows the app home in the config summary when overridden.
+type appHomeConfig struct {
+	AppHome string `yaml:"app-home" json:"app-home" mapstructure:"app-home"`
+}
+
+// withAppHome returns the configs to summarize, along with the app home when overridden.
+func (a *application) withAppHome(cfgs []any) []any {
+	home := appHome(a.setupConfig.ID.Name)
+	if home == "" {
+		return cfgs
+	}
+	return append([]any{&appHomeConfig{AppHome: home}}, cfgs...)
+}
+
+// logConfigInAppHome returns the logging config with a relative log file relocated to the log directory within the
+// app home, when overridden.
+func logConfigInAppHome(appName string, cfg *LoggingConfig) *LoggingConfig {
+	home := appHome(appName)
+	if cfg == nil || home == "" || cfg.FileLocation == "" || filepath.IsAbs(cfg.FileLocation) {
+		return cfg
+	}
+	c := *cfg
+	c.FileLocation = filepath.Join(home, "log", cfg.FileLocation)
+	return &c
+}
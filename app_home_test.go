@@ -0,0 +1,70 @@
+package clio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AppHomeEnvVar(t *testing.T) {
+	assert.Equal(t, "MY_APP_APP_HOME", AppHomeEnvVar("my-app"))
+}
+
+func Test_Application_appHome(t *testing.T) {
+	// the flag sets the environment variable, which is restored after the test
+	t.Setenv(AppHomeEnvVar("app"), "")
+
+	home := t.TempDir()
+	configDir := filepath.Join(home, "config", "app")
+	require.NoError(t, os.MkdirAll(configDir, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "config.yaml"), []byte("name: isolated\n"), 0o600))
+
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithAppHome())
+	got := &systemTestConfig{}
+	var dirs []string
+	root := app.SetupRootCommand(&cobra.Command{
+		RunE: func(*cobra.Command, []string) error {
+			state, err := stateDir("app")
+			require.NoError(t, err)
+			cache, err := app.(*application).State().cacheDir()
+			require.NoError(t, err)
+			dirs = append(dirs, state, cache)
+			return nil
+		},
+	}, got)
+
+	root.SetArgs([]string{"--app-home", home})
+	require.NoError(t, root.Execute())
+
+	assert.Equal(t, "isolated", got.Name)
+	assert.Equal(t, []string{filepath.Join(home, "state", "app"), filepath.Join(home, "cache", "app")}, dirs)
+	assert.Equal(t, home, os.Getenv(AppHomeEnvVar("app")))
+
+	a := app.(*application)
+	assert.Contains(t, formatConfiguration(a.withAppHome(a.loadedConfigs)...), "app-home: "+home)
+}
+
+func Test_Application_appHome_unset(t *testing.T) {
+	t.Setenv(AppHomeEnvVar("app"), "")
+
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus()).(*application)
+	cfgs := []any{&systemTestConfig{}}
+	assert.Equal(t, cfgs, app.withAppHome(cfgs))
+	assert.Len(t, app.configFinders(), len(app.setupConfig.FangsConfig.Finders))
+}
+
+func Test_logConfigInAppHome(t *testing.T) {
+	t.Setenv(AppHomeEnvVar("app"), "/home")
+
+	cfg := &LoggingConfig{FileLocation: "app.log"}
+	assert.Equal(t, filepath.Join("/home", "log", "app.log"), logConfigInAppHome("app", cfg).FileLocation)
+	assert.Equal(t, "app.log", cfg.FileLocation)
+
+	abs := &LoggingConfig{FileLocation: "/var/log/app.log"}
+	assert.Same(t, abs, logConfigInAppHome("app", abs))
+	assert.Nil(t, logConfigInAppHome("app", nil))
+}
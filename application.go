@@ -2,7 +2,9 @@ package clio
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/gookit/color"
@@ -19,18 +21,90 @@ import (
 
 type Initializer func(*State) error
 
+// Finalizer runs once a command has completed (successfully or not) with the summary of the run (see
+// SetupConfig.WithFinalizers).
+type Finalizer func(*State, RunSummary) error
+
 type postConstruct func(*application)
 
 type Application interface {
 	AddFlags(flags *pflag.FlagSet, cfgs ...any)
+	AddPersistentFlags(cmd *cobra.Command, cfgs ...any)
 	SetupCommand(cmd *cobra.Command, cfgs ...any) *cobra.Command
 	SetupRootCommand(cmd *cobra.Command, cfgs ...any) *cobra.Command
+	RegisterFlagCompletion(cmd *cobra.Command, flag string, fn CompletionFunc)
+	RegisterArgsCompletion(cmd *cobra.Command, fn CompletionFunc)
+	AddPrerequisites(cmd *cobra.Command, prerequisites ...Requirement)
+	RequiresLock(cmd *cobra.Command, names ...string)
+	RequiresConfirmation(cmd *cobra.Command, estimate Estimator)
+	AddExamples(cmd *cobra.Command, examples ...Example)
+	RunWithState(fn RunFunc) func(cmd *cobra.Command, args []string) error
+	Execute(ctx context.Context) int
 }
 
+// RunFunc is a command body receiving the command context (which is canceled on interrupt) and the application
+// State directly, so that it can be tested without a cobra command or application.
+type RunFunc func(ctx context.Context, state *State, args []string) error
+
 type application struct {
-	root        *cobra.Command
-	setupConfig SetupConfig `yaml:"-" mapstructure:"-"`
-	state       State       `yaml:"-" mapstructure:"-"`
+	root          *cobra.Command
+	setupConfig   SetupConfig   `yaml:"-" mapstructure:"-"`
+	state         State         `yaml:"-" mapstructure:"-"`
+	cobraMessages cobraMessages `yaml:"-" mapstructure:"-"`
+
+	// configs bound to the persistent flags of a command, which are loaded for the command and all children
+	persistentConfigs map[*cobra.Command][]any
+
+	// relationships between flags, by the flag set they were added to: the rules of the local flags of a command are
+	// enforced for the command, and the rules of its persistent flags for the command and all children
+	flagRules map[*pflag.FlagSet][]flagRule
+
+	// conditions which must be met to run a command and all children (see AddPrerequisites)
+	prerequisites map[*cobra.Command][]Requirement
+
+	// named locks held while running a command and all children (see RequiresLock)
+	locks map[*cobra.Command][]string
+
+	// the impact of running a command and all children, which the user confirms (see RequiresConfirmation)
+	confirmations map[*cobra.Command][]Estimator
+
+	// the subcommand run when the root command is invoked without a subcommand
+	defaultCommand *defaultCommand
+
+	// the root command only shows help when run (see setupUnknownCommands)
+	rootShowsHelp bool
+
+	// client/daemon execution (see SetupConfig.WithDaemon)
+	daemon *daemon
+
+	// all configs loaded for the command being run (the control API shows copies of them, see State.setLoadedConfigs)
+	loadedConfigs []any
+
+	// reject unknown keys in the config file (see SetupConfig.WithStrictConfigFlag)
+	strictConfig bool
+
+	// the config files read (and migrated) while loading the configuration, keyed by path (see readConfigFile)
+	configLayers map[string]*configLayer
+
+	// the configs given when setting up each command (used to load configuration for shell completion)
+	commandConfigs map[*cobra.Command][]any
+
+	// shell completion for flags (see RegisterFlagCompletion)
+	flagCompletions map[*pflag.Flag]cobraCompletionFunc
+	completion      completionState
+
+	// the bug report command (see SetupConfig.WithBugReportCommand), and the stats of the command being run
+	bugReportCmd *cobra.Command
+	runStats     *runStats
+
+	// the invocation of the command being run, recorded in the run history (see SetupConfig.WithHistory)
+	historyEntry *historyEntry
+
+	// the "config" command grouping the built-in config commands (e.g. see SetupConfig.WithConfigWizardCommand)
+	configCmd *cobra.Command
+
+	// the config holding the config sections of the UIs (see ConfigurableUI)
+	uiSections any
 }
 
 var _ interface {
@@ -61,29 +135,101 @@ func (a *application) Setup(cfgs ...any) func(cmd *cobra.Command, args []string)
 		// as early as possible before the final configuration is logged. This allows for a couple things:
 		// 1. user initializers to account for taking action before logging the final configuration (such as log redactions).
 		// 2. other user-facing PostLoad() functions to be able to use the logger, bus, etc. as early as possible. (though it's up to the caller on how these objects are made accessible)
-		allConfigs, err := a.loadConfigs(cmd, true, cfgs...)
+		a.state.enterPhase(cmd.Context(), PhaseSetup, a.setupConfig.PhaseTimeouts[PhaseSetup])
+
+		// the configuration is found as if started in the directory given with --cwd
+		leaveConfigDir, err := a.enterConfigDir(cmd)
+		if err != nil {
+			return err
+		}
+		defer leaveConfigDir()
+
+		if err := a.loadDotEnv(); err != nil {
+			return err
+		}
+
+		// commands run by the daemon share the resources it has set up (see runDaemonRequest)
+		withResources := !a.daemon.isServing()
+		allConfigs, err := a.loadConfigs(cmd, withResources, append(a.inheritedConfigs(cmd), cfgs...)...)
 		if err != nil {
 			return err
 		}
+		if !withResources {
+			if err := a.state.setupForwarded(a.setupConfig); err != nil {
+				return err
+			}
+		}
+
+		a.loadedConfigs = allConfigs
+		// for the control API, which leaves out the application itself (it only sets up the State, see PostLoad)
+		a.state.setLoadedConfigs(withoutConfig(allConfigs, a))
+
+		if err := a.checkUnknownConfigKeys(allConfigs...); err != nil {
+			return err
+		}
+
+		if err := a.checkRequiredFields(cmd, allConfigs...); err != nil {
+			return err
+		}
+
+		if err := a.validateFlagRules(cmd, allConfigs...); err != nil {
+			return err
+		}
+
+		if err := a.checkEnumFields(cmd, allConfigs...); err != nil {
+			return err
+		}
+
+		if err := a.checkPrivileges(); err != nil {
+			return err
+		}
 
 		// show the app version and configuration...
 		logVersion(a.setupConfig, a.state.Logger)
 
-		logConfiguration(a.state.Logger, allConfigs...)
+		if a.state.Config.Log.showsDebug() && a.state.Config.Log.format() == LogFormatGitHubActions {
+			// the configuration can be long, so collapse it in the workflow log
+			defer githubActionsGroup(os.Stderr, "configuration")()
+		}
+
+		logConfiguration(a.state.Logger, a.encodeConfigs(a.withAppHome(allConfigs))...)
 
 		return nil
 	}
 }
 
 func (a *application) loadConfigs(cmd *cobra.Command, withResources bool, cfgs ...any) ([]any, error) {
-	allConfigs := []any{&a.state.Config} // 1. process the core application configurations first (logging and development)
+	uiSections, err := a.uiSectionsConfig()
+	if err != nil {
+		return nil, err
+	}
+	allConfigs := []any{&a.state.Config, uiSections} // 1. process the core application configurations first (logging, development, and the UI sections)
 	if withResources {
 		allConfigs = append(allConfigs, a) // 2. enables application.PostLoad() to be called, initializing all state (bus, logger, ui, etc.)
 	}
 	allConfigs = append(allConfigs, cfgs...) // 3. allow for all other configs to be loaded + call PostLoad()
 	allConfigs = nonNil(allConfigs...)
 
-	if err := fangs.Load(a.setupConfig.FangsConfig, cmd, allConfigs...); err != nil {
+	// config files are read again for each load, since they may have changed
+	a.configLayers = nil
+	fangsCfg, cleanup, err := a.layeredConfig(cmd, allConfigs...)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if err := fangs.Load(fangsCfg, cmd, allConfigs...); err != nil {
+		if fileErr := a.configDecodeErrors(err); fileErr != nil {
+			return nil, fileErr
+		}
+		var reqErr *RequirementsError
+		if errors.As(err, &reqErr) {
+			// the configuration is fine, the environment is not
+			return nil, reqErr
+		}
+		return nil, fmt.Errorf("invalid application config: %v", err)
+	}
+	if err := validateConfigValues(a.configTagName(), allConfigs...); err != nil {
 		return nil, fmt.Errorf("invalid application config: %v", err)
 	}
 	return allConfigs, nil
@@ -93,6 +239,9 @@ func (a *application) PostLoad() error {
 	if err := a.state.setup(a.setupConfig); err != nil {
 		return err
 	}
+	if err := a.setupJobQueue(); err != nil {
+		return err
+	}
 	return a.runInitializers()
 }
 
@@ -102,12 +251,69 @@ func (a *application) runInitializers() error {
 			return err
 		}
 	}
+	a.state.health.setInitialized()
 	return nil
 }
 
 func (a *application) Run(fn func(cmd *cobra.Command, args []string) error) func(cmd *cobra.Command, args []string) error {
 	return func(cmd *cobra.Command, args []string) error {
-		return a.run(cmd.Context(), async(cmd, args, fn))
+		if err := a.dropConfiguredPrivileges(); err != nil {
+			return err
+		}
+
+		restore, err := a.state.enterWorkingDir()
+		if err != nil {
+			return err
+		}
+		defer restore()
+
+		// the teardown phase is not cancelled along with the command
+		parent := cmd.Context()
+		defer a.state.exitPhase()
+
+		ctx, stopControl, err := a.withControl(cmd)
+		if err != nil {
+			return err
+		}
+		ctx = a.state.enterPhase(ctx, PhaseRun, 0)
+		cmd.SetContext(ctx)
+
+		releaseLocks, err := a.acquireLocks(ctx, cmd)
+		if err != nil {
+			return stopControl(err)
+		}
+		defer releaseLocks()
+
+		if err := a.confirmCommand(ctx, cmd, args); err != nil {
+			return stopControl(err)
+		}
+
+		stopInstance, err := a.startInstance(ctx)
+		if err != nil {
+			return stopControl(err)
+		}
+		defer stopInstance()
+
+		if err := a.startCheckpoints(cmd, args); err != nil {
+			return stopControl(err)
+		}
+
+		started := now()
+		a.startRunStats(cmd)
+		a.startHistory(cmd, args)
+		a.startStageTimings()
+		err = a.run(ctx, async(cmd, args, fn))
+		if err == nil {
+			err = a.strictModeError()
+		}
+		a.state.enterPhase(parent, PhaseTeardown, a.setupConfig.PhaseTimeouts[PhaseTeardown])
+		a.finishRunStats(err)
+		a.finishHistory(ctx, err)
+		a.finishStageTimings(ctx, cmd, started, err)
+		a.finishCheckpoints(err)
+		a.runFinalizers(ctx, cmd, args, started, err)
+		a.showWarnings(cmd.ErrOrStderr())
+		return stopControl(err)
 	}
 }
 
@@ -121,13 +327,36 @@ func (a *application) run(ctx context.Context, errs <-chan error) error {
 		}
 	}
 
-	return eventloop(
+	// temp dirs are removed once the eventloop exits, which includes interrupts
+	defer a.state.removeTempDirs()
+
+	uis, stopMetrics := a.startMetrics(ctx)
+
+	uis, stopBridge, err := a.startBusBridge(uis)
+	if err != nil {
+		stopMetrics(err)
+		return err
+	}
+	defer stopBridge()
+
+	// the trace context is removed from events before they are given to the UIs (including the bus bridge)
+	uis = withTracing(ctx, a.state.propagator, uis)
+
+	uis = withPanicIsolation(a.state.Logger, a.setupConfig.DisableUIOnPanic, a.runStats, uis)
+
+	uis = withQuit(&a.state, uis)
+
+	err = eventloop(
 		ctx,
 		a.state.Logger.Nested("component", "eventloop"),
 		a.state.Subscription,
 		errs,
-		a.state.UIs...,
+		uis...,
 	)
+	err = a.state.cancelled(ctx, err)
+
+	stopMetrics(err)
+	return err
 }
 
 func logVersion(cfg SetupConfig, log logger.Logger) {
@@ -143,19 +372,71 @@ func logVersion(cfg SetupConfig, log logger.Logger) {
 }
 
 func logConfiguration(log logger.Logger, cfgs ...any) {
+	content := formatConfiguration(cfgs...)
+
+	if content != "" {
+		formatted := color.Magenta.Sprint(indent.String("  ", strings.TrimSpace(content)))
+		log.Debugf("config:\n%+v", formatted)
+	} else {
+		log.Debug("config: (none)")
+	}
+}
+
+func formatConfiguration(cfgs ...any) string {
 	var sb strings.Builder
 
+	// configs sharing a top-level section (e.g. the core UI config and the UI config sections) are shown together,
+	// under the section of the first config
+	sections := map[string]*yaml.Node{}
+	var docs []*yaml.Node
+	var strs []string
+
 	for _, cfg := range cfgs {
 		if cfg == nil {
 			continue
 		}
 
-		var str string
+		if encoder, ok := cfg.(ConfigEncoder); ok {
+			encoded, err := encoder.EncodeConfig()
+			if err != nil {
+				docs = append(docs, nil)
+				strs = append(strs, fmt.Sprintf("%+v", err))
+				continue
+			}
+			if encoded == nil {
+				continue
+			}
+			if str, ok := encoded.(string); ok {
+				docs = append(docs, nil)
+				strs = append(strs, str)
+				continue
+			}
+			cfg = encoded
+		}
+
 		if stringer, ok := cfg.(fmt.Stringer); ok {
-			str = stringer.String()
-		} else {
-			// yaml is pretty human friendly (at least when compared to json)
-			cfgBytes, err := yaml.Marshal(cfg)
+			docs = append(docs, nil)
+			strs = append(strs, stringer.String())
+			continue
+		}
+
+		// yaml is pretty human friendly (at least when compared to json)
+		var doc yaml.Node
+		if err := doc.Encode(cfg); err != nil {
+			docs = append(docs, nil)
+			strs = append(strs, fmt.Sprintf("%+v", err))
+			continue
+		}
+		if mergeConfigSections(sections, &doc) {
+			continue
+		}
+		docs = append(docs, &doc)
+		strs = append(strs, "")
+	}
+
+	for i, str := range strs {
+		if doc := docs[i]; doc != nil {
+			cfgBytes, err := yaml.Marshal(doc)
 			if err != nil {
 				str = fmt.Sprintf("%+v", err)
 			} else {
@@ -170,21 +451,62 @@ func logConfiguration(log logger.Logger, cfgs ...any) {
 		}
 	}
 
-	content := sb.String()
+	return sb.String()
+}
 
-	if content != "" {
-		formatted := color.Magenta.Sprint(indent.String("  ", strings.TrimSpace(content)))
-		log.Debugf("config:\n%+v", formatted)
-	} else {
-		log.Debug("config: (none)")
+// mergeConfigSections merges the top-level sections of the config (a yaml mapping) into the same sections of configs
+// seen before, recording the sections which are new. This indicates whether all sections were merged (so the config
+// does not need to be shown on its own).
+func mergeConfigSections(sections map[string]*yaml.Node, doc *yaml.Node) bool {
+	if doc.Kind != yaml.MappingNode {
+		return false
+	}
+	merged := false
+	var remaining []*yaml.Node
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i], doc.Content[i+1]
+		if existing, ok := sections[key.Value]; ok && value.Kind == yaml.MappingNode {
+			existing.Content = append(existing.Content, value.Content...)
+			merged = true
+			continue
+		}
+		if value.Kind == yaml.MappingNode {
+			sections[key.Value] = value
+		}
+		remaining = append(remaining, key, value)
 	}
+	doc.Content = remaining
+	return merged && len(remaining) == 0
 }
 
 func (a *application) AddFlags(flags *pflag.FlagSet, cfgs ...any) {
 	fangs.AddFlags(a.setupConfig.FangsConfig.Logger, flags, cfgs...)
+	a.describeFlagEnv(flags, cfgs...)
+	a.addFlagRules(flags, cfgs...)
 	a.state.Config.FromCommands = append(a.state.Config.FromCommands, cfgs...)
 }
 
+// AddPersistentFlags adds flags for the given configs to the persistent flags of the command. The flags are inherited
+// by all child commands, where the configs are also loaded (from flags, env, and config files) during setup.
+func (a *application) AddPersistentFlags(cmd *cobra.Command, cfgs ...any) {
+	if a.persistentConfigs == nil {
+		a.persistentConfigs = make(map[*cobra.Command][]any)
+	}
+	a.persistentConfigs[cmd] = append(a.persistentConfigs[cmd], cfgs...)
+	a.AddFlags(cmd.PersistentFlags(), cfgs...)
+	a.describeEnumFlags(cmd.PersistentFlags(), cfgs...)
+	a.addFlagCompletions(cmd, cmd.PersistentFlags(), cfgs...)
+}
+
+// inheritedConfigs returns all configs bound to persistent flags of the command and its parents (root-most first).
+func (a *application) inheritedConfigs(cmd *cobra.Command) []any {
+	var cfgs []any
+	for c := cmd; c != nil; c = c.Parent() {
+		cfgs = append(append([]any{}, a.persistentConfigs[c]...), cfgs...)
+	}
+	return cfgs
+}
+
 func (a *application) SetupCommand(cmd *cobra.Command, cfgs ...any) *cobra.Command {
 	return a.setupCommand(cmd, cmd.Flags(), &cmd.PreRunE, cfgs...)
 }
@@ -203,15 +525,35 @@ func (a *application) setupRootCommand(cmd *cobra.Command, cfgs ...any) *cobra.C
 
 	cmd.SetVersionTemplate(fmt.Sprintf("%s {{.Version}}\n", a.setupConfig.ID.Name))
 
+	// route messages that cobra would otherwise print raw (e.g. deprecation notices) through the logger
+	a.cobraMessages.wrap(cmd)
+	cmd.SetFlagErrorFunc(flagError)
+
 	// make a copy of the default configs
 	a.state.Config.Log = cp(a.setupConfig.DefaultLoggingConfig)
 	a.state.Config.Dev = cp(a.setupConfig.DefaultDevelopmentConfig)
+	a.state.Config.Temp = cp(a.setupConfig.DefaultTempConfig)
+	a.state.Config.Permissions = cp(a.setupConfig.DefaultPermissions)
+	a.state.Config.Telemetry = cp(a.setupConfig.DefaultTelemetryConfig)
+	a.state.Config.UI = cp(a.setupConfig.DefaultUIConfig)
+	a.state.Config.Network = cp(a.setupConfig.DefaultNetworkConfig)
+	a.state.Config.HTTP = cp(a.setupConfig.DefaultHTTPConfig)
+	a.state.Config.Notifications = cp(a.setupConfig.DefaultNotificationsConfig)
+	a.state.Config.Hooks = cp(a.setupConfig.DefaultHooksConfig)
+	a.state.Config.Policy = cp(a.setupConfig.DefaultPolicyConfig)
+	a.state.Config.Storage = cp(a.setupConfig.DefaultStorageConfig)
+	a.state.Config.Locks = cp(a.setupConfig.DefaultLocksConfig)
+	a.state.Config.Strict = cp(a.setupConfig.DefaultStrictModeConfig)
+	a.state.Config.Priority = cp(a.setupConfig.DefaultPriorityConfig)
 
 	for _, pc := range a.setupConfig.postConstructs {
 		pc(a)
 	}
 
-	return a.setupCommand(cmd, cmd.Flags(), &cmd.PreRunE, cfgs...)
+	a.setupUnknownCommands(cmd)
+	cmd = a.setupCommand(cmd, cmd.Flags(), &cmd.PreRunE, cfgs...)
+	a.linkDefaultCommand()
+	return cmd
 }
 
 func cp[T any](value *T) *T {
@@ -226,7 +568,21 @@ func cp[T any](value *T) *T {
 func (a *application) setupCommand(cmd *cobra.Command, flags *pflag.FlagSet, fn *func(cmd *cobra.Command, args []string) error, cfgs ...any) *cobra.Command {
 	original := *fn
 	*fn = func(cmd *cobra.Command, args []string) error {
-		err := a.Setup(cfgs...)(cmd, args)
+		if a.dispatchToDaemon(cmd) || a.forwardToInstance(cmd) {
+			return nil
+		}
+
+		setupCfgs := cfgs
+		if d := a.defaultCommandFor(cmd); d != nil {
+			// the root command will run the default command, so the default command configs must be loaded too
+			setupCfgs = append(append([]any{}, cfgs...), d.cfgs...)
+		}
+
+		err := a.Setup(setupCfgs...)(cmd, args)
+		if err == nil {
+			err = a.checkPrerequisites(cmd)
+		}
+		a.reportCobraMessages()
 		if err != nil {
 			return err
 		}
@@ -244,17 +600,31 @@ func (a *application) setupCommand(cmd *cobra.Command, flags *pflag.FlagSet, fn
 	cmd.SilenceErrors = true
 
 	a.state.Config.FromCommands = append(a.state.Config.FromCommands, cfgs...)
+	if a.commandConfigs == nil {
+		a.commandConfigs = make(map[*cobra.Command][]any)
+	}
+	a.commandConfigs[cmd] = append(a.commandConfigs[cmd], cfgs...)
 
 	fangs.AddFlags(a.setupConfig.FangsConfig.Logger, flags, cfgs...)
+	a.describeFlagEnv(flags, cfgs...)
+	a.addFlagRules(flags, cfgs...)
+	a.describeEnumFlags(flags, cfgs...)
+	a.addFlagCompletions(cmd, flags, cfgs...)
+
+	if a.isDefaultCommand(cmd) {
+		a.defaultCommand = &defaultCommand{cmd: cmd, cfgs: cfgs, preRunE: original}
+		a.linkDefaultCommand()
+	}
 
 	return cmd
 }
 
 func (a *application) summarizeConfig(cmd *cobra.Command) string {
 	cfg := a.setupConfig.FangsConfig
+	uiSections, _ := a.uiSectionsConfig()
 
 	summary := "Application Configuration:\n\n"
-	summary += indent.String("  ", strings.TrimSuffix(fangs.SummarizeCommand(cfg, cmd, a.state.Config.FromCommands...), "\n"))
+	summary += indent.String("  ", strings.TrimSuffix(fangs.SummarizeCommand(cfg, cmd, nonNil(append([]any{uiSections}, a.state.Config.FromCommands...)...)...), "\n"))
 	summary += "\n"
 	summary += "Config Search Locations:\n"
 	for _, f := range fangs.SummarizeLocations(cfg) {
@@ -266,6 +636,22 @@ func (a *application) summarizeConfig(cmd *cobra.Command) string {
 	return strings.TrimSpace(summary)
 }
 
+// RunWithState adapts the function to a cobra RunE function, for use with SetupCommand:
+//
+//	app.SetupCommand(&cobra.Command{
+//		Use:  "scan",
+//		RunE: app.RunWithState(func(ctx context.Context, state *clio.State, args []string) error { ... }),
+//	}, cfg)
+func (a *application) RunWithState(fn RunFunc) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		return fn(ctx, &a.state, args)
+	}
+}
+
 func async(cmd *cobra.Command, args []string, f func(cmd *cobra.Command, args []string) error) <-chan error {
 	errs := make(chan error)
 	go func() {
@@ -277,6 +663,17 @@ func async(cmd *cobra.Command, args []string, f func(cmd *cobra.Command, args []
 	return errs
 }
 
+// withoutConfig returns the configs other than the given config.
+func withoutConfig(cfgs []any, cfg any) []any {
+	var ret []any
+	for _, c := range cfgs {
+		if c != cfg {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
 func nonNil(a ...any) []any {
 	var ret []any
 	for _, v := range a {
@@ -2,6 +2,7 @@ package clio
 
 import (
 	"bytes"
+	"context"
 	"os"
 	"regexp"
 	"testing"
@@ -308,3 +309,86 @@ func (t *f1) AddFlags(flags fangs.FlagSet) {
 	flags.BoolVarP(&t.Extras, "extras", "", "the flag extras")
 	flags.BoolPtrVarP(&t.Online, "online", "", "the flag online")
 }
+
+type platformConfig struct {
+	Platform string `mapstructure:"platform"`
+	loaded   bool
+}
+
+func (p *platformConfig) AddFlags(flags fangs.FlagSet) {
+	flags.StringVarP(&p.Platform, "platform", "", "the platform to use")
+}
+
+func (p *platformConfig) PostLoad() error {
+	p.loaded = true
+	return nil
+}
+
+func Test_Application_AddPersistentFlags(t *testing.T) {
+	cfg := NewSetupConfig(Identification{Name: "myApp", Version: "v2.4.11"})
+	app := New(*cfg)
+
+	root := app.SetupRootCommand(&cobra.Command{})
+
+	platform := &platformConfig{}
+	group := &cobra.Command{Use: "group"}
+	app.AddPersistentFlags(group, platform)
+	root.AddCommand(group)
+
+	f := &f1{}
+	var ran bool
+	child := app.SetupCommand(&cobra.Command{
+		Use: "child",
+		Run: func(cmd *cobra.Command, args []string) {
+			ran = true
+		},
+	}, f)
+	group.AddCommand(child)
+
+	// the flag is inherited by the child command...
+	assert.Contains(t, child.UsageString(), "--platform")
+
+	// ...and the config is loaded for the child command (from env, which is only possible via fangs)
+	t.Setenv("MYAPP_PLATFORM", "linux/arm64")
+	root.SetArgs([]string{"group", "child", "--output", "json"})
+	require.NoError(t, root.Execute())
+
+	assert.True(t, ran)
+	assert.True(t, platform.loaded)
+	assert.Equal(t, "linux/arm64", platform.Platform)
+	assert.Equal(t, "json", f.Output)
+
+	// the flag takes precedence over env
+	root.SetArgs([]string{"group", "child", "--platform", "linux/amd64"})
+	require.NoError(t, root.Execute())
+	assert.Equal(t, "linux/amd64", platform.Platform)
+}
+
+func Test_Application_RunWithState(t *testing.T) {
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus())
+
+	root := app.SetupRootCommand(&cobra.Command{})
+	cfg := &systemTestConfig{}
+
+	var gotArgs []string
+	var gotState *State
+	var gotCtx context.Context
+	root.AddCommand(app.SetupCommand(&cobra.Command{
+		Use: "run",
+		RunE: app.RunWithState(func(ctx context.Context, state *State, args []string) error {
+			gotCtx, gotState, gotArgs = ctx, state, args
+			return nil
+		}),
+	}, cfg))
+
+	t.Setenv("APP_NAME", "from-env")
+	root.SetArgs([]string{"run", "a", "b"})
+	require.NoError(t, root.Execute())
+
+	assert.Equal(t, []string{"a", "b"}, gotArgs)
+	assert.NotNil(t, gotCtx)
+	require.NotNil(t, gotState)
+	assert.Same(t, &app.(*application).state, gotState)
+	assert.NotNil(t, gotState.Logger)
+	assert.Equal(t, "from-env", cfg.Name)
+}
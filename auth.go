@@ -0,0 +1,375 @@
+package clio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// credentialsKey is the key of the login credentials within the CredentialStore.
+const credentialsKey = "credentials"
+
+// expiryMargin is how long before they expire that credentials are considered expired, so that they do not expire
+// while a request is being made.
+const expiryMargin = 30 * time.Second
+
+// ErrNotAuthenticated is matched (see errors.Is) by the error returned when the user is not logged in, or the login
+// has expired (see State.Credentials).
+var ErrNotAuthenticated = errors.New("not logged in")
+
+// Credentials are the result of logging in to the service of the application (see SetupConfig.WithAuth).
+type Credentials struct {
+	Token        string    `json:"token"`
+	TokenType    string    `json:"token_type,omitempty"`    // e.g. "Bearer"
+	RefreshToken string    `json:"refresh_token,omitempty"` // used to renew the token once it expires (see CredentialsRefresher)
+	Identity     string    `json:"identity,omitempty"`      // who is logged in (e.g. a username or email), shown by whoami
+	Expiry       time.Time `json:"expiry"`                  // when the token expires, or zero if it does not
+}
+
+// Expired indicates the token has expired, or is about to.
+func (c *Credentials) Expired() bool {
+	return !c.Expiry.IsZero() && !now().Add(expiryMargin).Before(c.Expiry)
+}
+
+// Authenticator performs the authentication flow with the service of the application (e.g. a device code, browser,
+// or API key flow) for the login command (see SetupConfig.WithAuth). Authenticators may also implement
+// CredentialsRefresher, CredentialsRevoker, and TokenAuthenticator.
+type Authenticator interface {
+	Login(ctx context.Context, state *State, prompt AuthPrompt) (*Credentials, error)
+}
+
+// CredentialsRefresher renews credentials once they expire, using the refresh token, so the user does not need to
+// log in again.
+type CredentialsRefresher interface {
+	Refresh(ctx context.Context, state *State, credentials *Credentials) (*Credentials, error)
+}
+
+// CredentialsRevoker invalidates credentials with the service when the user logs out.
+type CredentialsRevoker interface {
+	Revoke(ctx context.Context, state *State, credentials *Credentials) error
+}
+
+// TokenAuthenticator logs in with a token given non-interactively (with "login --with-token", e.g. in CI), returning
+// the credentials for the token after checking it with the service. Without this, the token is kept as-is.
+type TokenAuthenticator interface {
+	LoginWithToken(ctx context.Context, state *State, token string) (*Credentials, error)
+}
+
+// AuthPrompt is the input and output of the login command, for an Authenticator to give instructions to the user
+// (e.g. the code to enter for a device code flow) and read their answers.
+type AuthPrompt struct {
+	In  io.Reader
+	Out io.Writer
+}
+
+// Printf writes instructions to the user.
+func (p AuthPrompt) Printf(format string, args ...any) {
+	_, _ = fmt.Fprintf(p.Out, format, args...)
+}
+
+// ReadLine asks the user for a value.
+func (p AuthPrompt) ReadLine(label string) (string, error) {
+	p.Printf("%s: ", label)
+	line, err := readLine(p.In)
+	if err != nil {
+		return "", err
+	}
+	if line == "" {
+		return "", fmt.Errorf("no %s given", strings.ToLower(label))
+	}
+	return line, nil
+}
+
+// readLine reads a line a byte at a time, so that nothing after the line is consumed from the input.
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSpace(string(line)), nil
+}
+
+// ReadSecret asks the user for a secret value (e.g. an API key), which is not shown as it is typed.
+func (p AuthPrompt) ReadSecret(label string) (string, error) {
+	f, ok := p.In.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return p.ReadLine(label)
+	}
+	p.Printf("%s: ", label)
+	b, err := term.ReadPassword(int(f.Fd()))
+	p.Printf("\n")
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimSpace(string(b))
+	if secret == "" {
+		return "", fmt.Errorf("no %s given", strings.ToLower(label))
+	}
+	return secret, nil
+}
+
+// APIKeyAuth is an Authenticator which asks for an API key (or personal access token), optionally checking it with
+// the service (e.g. to find who it belongs to).
+type APIKeyAuth struct {
+	// Prompt is shown when asking for the key (default: "API key").
+	Prompt string
+	// Validate checks the key, returning the credentials for it (or nil to keep the key as-is).
+	Validate func(ctx context.Context, state *State, key string) (*Credentials, error)
+}
+
+var _ interface {
+	Authenticator
+	TokenAuthenticator
+} = (*APIKeyAuth)(nil)
+
+func (a *APIKeyAuth) Login(ctx context.Context, state *State, prompt AuthPrompt) (*Credentials, error) {
+	label := a.Prompt
+	if label == "" {
+		label = "API key"
+	}
+	key, err := prompt.ReadSecret(label)
+	if err != nil {
+		return nil, err
+	}
+	return a.LoginWithToken(ctx, state, key)
+}
+
+func (a *APIKeyAuth) LoginWithToken(ctx context.Context, state *State, token string) (*Credentials, error) {
+	if a.Validate != nil {
+		credentials, err := a.Validate(ctx, state, token)
+		if err != nil || credentials != nil {
+			return credentials, err
+		}
+	}
+	return &Credentials{Token: token}, nil
+}
+
+// CredentialStore returns the store for secrets of the application (see SetupConfig.WithCredentialStore), which keeps
+// the credentials from logging in.
+func (s *State) CredentialStore() CredentialStore {
+	return s.credentials
+}
+
+// Credentials returns the credentials of the logged in user (see SetupConfig.WithAuth), renewing them first when
+// they have expired (see CredentialsRefresher). The error matches ErrNotAuthenticated when the user is not logged in,
+// or the login has expired.
+func (s *State) Credentials(ctx context.Context) (*Credentials, error) {
+	s.authLock.Lock()
+	defer s.authLock.Unlock()
+
+	credentials, err := s.storedCredentials()
+	if err != nil {
+		return nil, err
+	}
+	if !credentials.Expired() {
+		return credentials, nil
+	}
+
+	refresher, ok := s.auth.(CredentialsRefresher)
+	if !ok || credentials.RefreshToken == "" {
+		return nil, s.loginError(fmt.Errorf("%w: the login has expired", ErrNotAuthenticated))
+	}
+	refreshed, err := refresher.Refresh(ctx, s, credentials)
+	if err != nil {
+		return nil, s.loginError(fmt.Errorf("%w: the login expired and could not be renewed: %v", ErrNotAuthenticated, err))
+	}
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = credentials.RefreshToken
+	}
+	if refreshed.Identity == "" {
+		refreshed.Identity = credentials.Identity
+	}
+	if err := s.saveCredentials(refreshed); err != nil {
+		return nil, err
+	}
+	return refreshed, nil
+}
+
+// storedCredentials returns the credentials as kept in the credential store (which may have expired).
+func (s *State) storedCredentials() (*Credentials, error) {
+	if s.credentials == nil {
+		return nil, s.loginError(ErrNotAuthenticated)
+	}
+	value, err := s.credentials.Get(credentialsKey)
+	if errors.Is(err, ErrCredentialNotFound) {
+		return nil, s.loginError(ErrNotAuthenticated)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read credentials: %w", err)
+	}
+	var credentials Credentials
+	if err := json.Unmarshal([]byte(value), &credentials); err != nil || credentials.Token == "" {
+		return nil, s.loginError(fmt.Errorf("%w: the stored credentials are invalid", ErrNotAuthenticated))
+	}
+	s.redactCredentials(&credentials)
+	return &credentials, nil
+}
+
+func (s *State) saveCredentials(credentials *Credentials) error {
+	if s.credentials == nil {
+		return errors.New("unable to save credentials: there is no credential store")
+	}
+	value, err := json.Marshal(credentials)
+	if err != nil {
+		return err
+	}
+	if err := s.credentials.Set(credentialsKey, string(value)); err != nil {
+		return fmt.Errorf("unable to save credentials: %w", err)
+	}
+	s.redactCredentials(credentials)
+	return nil
+}
+
+// redactCredentials keeps the tokens out of logs and other output.
+func (s *State) redactCredentials(credentials *Credentials) {
+	if s.RedactStore == nil {
+		return
+	}
+	for _, token := range []string{credentials.Token, credentials.RefreshToken} {
+		if token != "" {
+			s.RedactStore.Add(token)
+		}
+	}
+}
+
+// loginError adds a hint for how to log in to the error.
+func (s *State) loginError(err error) error {
+	if s.auth == nil {
+		return err
+	}
+	return fmt.Errorf("%w (run %q)", err, s.id.Name+" login")
+}
+
+// RequireLogin requires the user to be logged in (see SetupConfig.WithAuth), e.g. as a prerequisite of commands which
+// call the service of the application (see Application.AddPrerequisites).
+func RequireLogin() Requirement {
+	return Requirement{
+		Name: "authentication",
+		Check: func(s *State) error {
+			_, err := s.Credentials(context.Background())
+			return err
+		},
+	}
+}
+
+// setupAuthCommands adds the "login", "logout", and "whoami" commands.
+func (a *application) setupAuthCommands() {
+	var withToken bool
+	loginCmd := &cobra.Command{
+		Use:   "login",
+		Short: "log in to " + a.setupConfig.ID.Name,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			credentials, err := a.login(cmd, withToken)
+			if err != nil {
+				return fmt.Errorf("unable to log in: %w", err)
+			}
+			if err := a.state.saveCredentials(credentials); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(cmd.ErrOrStderr(), describeLogin(credentials))
+			return nil
+		},
+	}
+	loginCmd.Flags().BoolVar(&withToken, "with-token", false, "read a token from stdin instead of logging in interactively")
+
+	logoutCmd := &cobra.Command{
+		Use:   "logout",
+		Short: "log out of " + a.setupConfig.ID.Name + ", removing the stored credentials",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return a.logout(cmd)
+		},
+	}
+
+	whoamiCmd := &cobra.Command{
+		Use:   "whoami",
+		Short: "show who is logged in",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			credentials, err := a.state.Credentials(cmd.Context())
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), describeLogin(credentials))
+			return nil
+		},
+	}
+
+	a.root.AddCommand(a.SetupCommand(loginCmd), a.SetupCommand(logoutCmd), a.SetupCommand(whoamiCmd))
+}
+
+func (a *application) login(cmd *cobra.Command, withToken bool) (*Credentials, error) {
+	if !withToken {
+		return a.state.auth.Login(cmd.Context(), &a.state, AuthPrompt{In: cmd.InOrStdin(), Out: cmd.ErrOrStderr()})
+	}
+
+	token, err := io.ReadAll(cmd.InOrStdin())
+	if err != nil {
+		return nil, fmt.Errorf("unable to read token: %w", err)
+	}
+	t := strings.TrimSpace(string(token))
+	if t == "" {
+		return nil, errors.New("no token given on stdin")
+	}
+	if ta, ok := a.state.auth.(TokenAuthenticator); ok {
+		return ta.LoginWithToken(cmd.Context(), &a.state, t)
+	}
+	return &Credentials{Token: t}, nil
+}
+
+func (a *application) logout(cmd *cobra.Command) error {
+	credentials, err := a.state.storedCredentials()
+	if errors.Is(err, ErrNotAuthenticated) {
+		_, _ = fmt.Fprintln(cmd.ErrOrStderr(), "not logged in")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if revoker, ok := a.state.auth.(CredentialsRevoker); ok {
+		if err := revoker.Revoke(cmd.Context(), &a.state, credentials); err != nil {
+			// the credentials are removed regardless, since the user wants to be logged out
+			a.state.Logger.Warnf("unable to revoke credentials: %v", err)
+		}
+	}
+	if err := a.state.credentials.Delete(credentialsKey); err != nil && !errors.Is(err, ErrCredentialNotFound) {
+		return fmt.Errorf("unable to remove credentials: %w", err)
+	}
+	_, _ = fmt.Fprintln(cmd.ErrOrStderr(), "logged out")
+	return nil
+}
+
+// describeLogin describes who is logged in, and until when.
+func describeLogin(credentials *Credentials) string {
+	msg := "logged in"
+	if credentials.Identity != "" {
+		msg += " as " + credentials.Identity
+	}
+	if !credentials.Expiry.IsZero() {
+		msg += fmt.Sprintf(" (expires in %s)", HumanDuration(credentials.Expiry.Sub(now()).Round(time.Second)))
+	}
+	return msg
+}
@@ -0,0 +1,211 @@
+package clio
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryCredentialStore keeps secrets in memory, for tests.
+type memoryCredentialStore struct {
+	lock   sync.Mutex
+	values map[string]string
+	err    error
+}
+
+func (m *memoryCredentialStore) Get(key string) (string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.err != nil {
+		return "", m.err
+	}
+	v, ok := m.values[key]
+	if !ok {
+		return "", ErrCredentialNotFound
+	}
+	return v, nil
+}
+
+func (m *memoryCredentialStore) Set(key, value string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	if m.values == nil {
+		m.values = map[string]string{}
+	}
+	m.values[key] = value
+	return nil
+}
+
+func (m *memoryCredentialStore) Delete(key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	if _, ok := m.values[key]; !ok {
+		return ErrCredentialNotFound
+	}
+	delete(m.values, key)
+	return nil
+}
+
+// refreshingAuth is an authenticator which renews credentials, and records revoked tokens.
+type refreshingAuth struct {
+	APIKeyAuth
+	revoked []string
+}
+
+func (r *refreshingAuth) Refresh(_ context.Context, _ *State, c *Credentials) (*Credentials, error) {
+	if c.RefreshToken == "bad" {
+		return nil, errors.New("refresh token revoked")
+	}
+	return &Credentials{Token: "renewed", Identity: c.Identity, Expiry: time.Now().Add(time.Hour)}, nil
+}
+
+func (r *refreshingAuth) Revoke(_ context.Context, _ *State, c *Credentials) error {
+	r.revoked = append(r.revoked, c.Token)
+	return nil
+}
+
+func runAuthTestApp(t *testing.T, auth Authenticator, store CredentialStore, stdin string, args ...string) (string, string, error) {
+	t.Helper()
+	app := New(*NewSetupConfig(Identification{Name: "app"}).
+		WithNoBus().
+		WithAuth(auth).
+		WithCredentialStore(store))
+	return runTestApp(app.SetupRootCommand(&cobra.Command{}), stdin, args...)
+}
+
+func Test_authCommands(t *testing.T) {
+	store := &memoryCredentialStore{}
+	auth := &refreshingAuth{APIKeyAuth: APIKeyAuth{
+		Validate: func(_ context.Context, _ *State, key string) (*Credentials, error) {
+			if key != "secret-key" {
+				return nil, errors.New("invalid API key")
+			}
+			return &Credentials{Token: key, Identity: "alice"}, nil
+		},
+	}}
+
+	_, _, err := runAuthTestApp(t, auth, store, "", "whoami")
+	require.ErrorContains(t, err, `not logged in (run "app login")`)
+	require.ErrorIs(t, err, ErrNotAuthenticated)
+
+	_, _, err = runAuthTestApp(t, auth, store, "wrong\n", "login")
+	require.ErrorContains(t, err, "unable to log in: invalid API key")
+
+	_, stderr, err := runAuthTestApp(t, auth, store, "secret-key\n", "login")
+	require.NoError(t, err)
+	assert.Equal(t, "API key: logged in as alice\n", stderr)
+
+	stdout, _, err := runAuthTestApp(t, auth, store, "", "whoami")
+	require.NoError(t, err)
+	assert.Equal(t, "logged in as alice\n", stdout)
+
+	_, stderr, err = runAuthTestApp(t, auth, store, "", "logout")
+	require.NoError(t, err)
+	assert.Equal(t, "logged out\n", stderr)
+	assert.Equal(t, []string{"secret-key"}, auth.revoked)
+	assert.Empty(t, store.values)
+
+	_, stderr, err = runAuthTestApp(t, auth, store, "", "logout")
+	require.NoError(t, err)
+	assert.Equal(t, "not logged in\n", stderr)
+}
+
+func Test_authCommands_withToken(t *testing.T) {
+	store := &memoryCredentialStore{}
+	_, _, err := runAuthTestApp(t, &APIKeyAuth{}, store, "  ci-token\n", "login", "--with-token")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"token": "ci-token", "expiry": "0001-01-01T00:00:00Z"}`, store.values[credentialsKey])
+
+	_, _, err = runAuthTestApp(t, &APIKeyAuth{}, store, "", "login", "--with-token")
+	require.ErrorContains(t, err, "unable to log in: no token given on stdin")
+}
+
+func Test_State_Credentials_expiry(t *testing.T) {
+	store := &memoryCredentialStore{}
+	s := &State{id: Identification{Name: "app"}, auth: &refreshingAuth{}, credentials: store}
+
+	require.NoError(t, s.saveCredentials(&Credentials{Token: "old", RefreshToken: "refresh", Identity: "alice", Expiry: time.Now().Add(10 * time.Second)}))
+	c, err := s.Credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "renewed", c.Token, "credentials about to expire are renewed")
+	assert.Equal(t, "refresh", c.RefreshToken, "the refresh token is kept")
+	assert.Equal(t, "alice", c.Identity)
+
+	stored, err := s.storedCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, "renewed", stored.Token, "renewed credentials are saved")
+
+	require.NoError(t, s.saveCredentials(&Credentials{Token: "old", RefreshToken: "bad", Expiry: time.Now().Add(-time.Hour)}))
+	_, err = s.Credentials(context.Background())
+	require.ErrorIs(t, err, ErrNotAuthenticated)
+	assert.EqualError(t, err, `not logged in: the login expired and could not be renewed: refresh token revoked (run "app login")`)
+
+	s.auth = &APIKeyAuth{}
+	require.NoError(t, s.saveCredentials(&Credentials{Token: "old", Expiry: time.Now().Add(-time.Hour)}))
+	_, err = s.Credentials(context.Background())
+	assert.EqualError(t, err, `not logged in: the login has expired (run "app login")`)
+}
+
+func Test_RequireLogin(t *testing.T) {
+	store := &memoryCredentialStore{}
+	s := &State{id: Identification{Name: "app"}, auth: &APIKeyAuth{}, credentials: store}
+	require.ErrorIs(t, RequireLogin().Check(s), ErrNotAuthenticated)
+
+	require.NoError(t, s.saveCredentials(&Credentials{Token: "token"}))
+	require.NoError(t, RequireLogin().Check(s))
+}
+
+func Test_fileCredentialStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app", "credentials.json")
+	f := &fileCredentialStore{path: path}
+
+	_, err := f.Get("key")
+	require.ErrorIs(t, err, ErrCredentialNotFound)
+
+	require.NoError(t, f.Set("key", "value"))
+	v, err := f.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", v)
+
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+	}
+
+	require.NoError(t, f.Delete("key"))
+	require.ErrorIs(t, f.Delete("key"), ErrCredentialNotFound)
+}
+
+func Test_fallbackCredentialStore(t *testing.T) {
+	keyring := &memoryCredentialStore{err: errKeyringUnavailable}
+	file := &memoryCredentialStore{}
+	fellBack := 0
+	s := &fallbackCredentialStore{keyring: keyring, file: file, fallback: func() { fellBack++ }}
+
+	require.NoError(t, s.Set("key", "value"))
+	v, err := s.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", v)
+	assert.Equal(t, map[string]string{"key": "value"}, file.values)
+	assert.Equal(t, 1, fellBack, "the fallback is only reported once")
+
+	keyring.err = errors.New("locked")
+	_, err = s.Get("key")
+	require.EqualError(t, err, "locked", "other keyring errors are not hidden")
+}
@@ -0,0 +1,35 @@
+package clio
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// errNoBrowser is returned when there is no browser to open (e.g. over ssh, or within a container).
+var errNoBrowser = errors.New("no browser available")
+
+// OpenBrowser opens the URL in the default browser of the user, without waiting for the browser to exit. An error is
+// returned when there is no browser to open, in which case the user should be asked to open the URL themselves.
+var OpenBrowser = func(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return errNoBrowser
+		}
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return errNoBrowser
+	}
+	go func() {
+		_ = cmd.Wait()
+	}()
+	return nil
+}
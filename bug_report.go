@@ -0,0 +1,204 @@
+package clio
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// bugReportLogLines is the number of lines from the end of the log file included in the bug report.
+const bugReportLogLines = 200
+
+// bugReportFile is a single file within the bug report archive.
+type bugReportFile struct {
+	name     string
+	contents string
+}
+
+// setupBugReportCommand adds the "bug-report" command, which writes an archive of diagnostic information that can be
+// attached to an issue.
+func (a *application) setupBugReportCommand() {
+	var output string
+	var files []bugReportFile
+
+	cmd := &cobra.Command{
+		Use:   "bug-report",
+		Short: "write diagnostic information to attach to a bug report",
+		Long: "Write an archive with the version, configuration (and where each value came from), detected environment, " +
+			"the end of the log file, and stats from the most recent run. Secrets are redacted, however please review " +
+			"the contents before sharing.",
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			// gather the contents before the command runs, since loading the configuration of every command updates
+			// the application state (which is in use while the command runs)
+			var err error
+			if files, err = a.bugReportContents(cmd); err != nil {
+				return fmt.Errorf("unable to write bug report: %w", err)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output == "" {
+				output = fmt.Sprintf("%s-bug-report-%s.zip", a.setupConfig.ID.Name, now().Format("20060102-150405"))
+			}
+			if err := a.writeBugReport(output, files); err != nil {
+				return fmt.Errorf("unable to write bug report: %w", err)
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "bug report written to %s\n", DisplayPath(output))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "file", "f", "", "the archive to write (default: <app>-bug-report-<time>.zip)")
+
+	a.bugReportCmd = a.SetupCommand(cmd)
+	a.root.AddCommand(a.bugReportCmd)
+}
+
+// bugReportContents returns the files of the bug report, with the configuration of every command (not only this one).
+// Config values that look like secrets are added to the redact store.
+func (a *application) bugReportContents(cmd *cobra.Command) ([]bugReportFile, error) {
+	cfgs, err := a.loadConfigs(cmd, false, a.state.Config.FromCommands...)
+	if err != nil {
+		return nil, err
+	}
+	a.redactSecretConfigValues(cfgs...)
+
+	return a.bugReportFiles(cmd, cfgs...)
+}
+
+// writeBugReport writes the bug report archive, redacting all secrets known to the application.
+func (a *application) writeBugReport(output string, files []bugReportFile) error {
+	f, err := a.state.CreateFile(output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	archive := zip.NewWriter(f)
+	for _, file := range files {
+		contents := file.contents
+		if a.state.RedactStore != nil {
+			contents = a.state.RedactStore.RedactString(contents)
+		}
+		w, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, contents); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func (a *application) bugReportFiles(cmd *cobra.Command, cfgs ...any) ([]bugReportFile, error) {
+	version, err := json.MarshalIndent(newRuntimeInfo(a.setupConfig.ID), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	environment, err := json.MarshalIndent(a.state.Environment(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	files := []bugReportFile{
+		{name: "version.json", contents: string(version)},
+		{name: "config.yaml", contents: formatConfiguration(a.encodeConfigs(a.withAppHome(cfgs))...)},
+		{name: "config-sources.txt", contents: a.configProvenance(cmd, cfgs...)},
+		{name: "environment.json", contents: string(environment)},
+	}
+
+	if log := a.state.Config.Log; log != nil && log.FileLocation != "" {
+		tail, err := tailFile(log.FileLocation, bugReportLogLines)
+		if err != nil {
+			a.state.Logger.Debugf("unable to read log file for bug report: %v", err)
+		} else {
+			files = append(files, bugReportFile{name: "log.txt", contents: tail})
+		}
+	}
+
+	stats, err := readRunStats(a.setupConfig.ID.Name)
+	if err != nil {
+		a.state.Logger.Debugf("unable to read run stats for bug report: %v", err)
+	} else if stats != nil {
+		files = append(files, bugReportFile{name: runStatsFile, contents: string(stats)})
+	}
+
+	return files, nil
+}
+
+// redactSecretConfigValues adds string config values with secret-looking keys (e.g. "registry.password") to the
+// redact store.
+func (a *application) redactSecretConfigValues(cfgs ...any) {
+	if a.state.RedactStore == nil {
+		return
+	}
+	for _, cfg := range cfgs {
+		visitConfigFields(a.configTagName(), reflect.ValueOf(cfg), nil, nil, func(_ uintptr, f configField) {
+			if len(f.path) == 0 || !dotEnvSecretPattern.MatchString(f.path[len(f.path)-1]) {
+				return
+			}
+			if f.value.Kind() == reflect.String && f.value.String() != "" {
+				a.state.RedactStore.Add(f.value.String())
+			}
+		})
+	}
+}
+
+// configProvenance describes where each config value came from: a flag, an environment variable, a config file, or
+// the default.
+func (a *application) configProvenance(cmd *cobra.Command, cfgs ...any) string {
+	flags := flagsByRef(cmd)
+	seen := map[string]bool{}
+
+	var sb strings.Builder
+	for _, cfg := range cfgs {
+		visitConfigFields(a.configTagName(), reflect.ValueOf(cfg), nil, nil, func(ptr uintptr, f configField) {
+			key := strings.ToLower(strings.Join(f.path, "."))
+			if seen[key] {
+				return
+			}
+			seen[key] = true
+
+			fmt.Fprintf(&sb, "%s: %s\n", key, a.configFieldSource(flags, ptr, f.path))
+		})
+	}
+	return sb.String()
+}
+
+// configFieldSource describes where the value of a config field came from (see configProvenance).
+func (a *application) configFieldSource(flags map[uintptr]*pflag.Flag, ptr uintptr, path []string) string {
+	if flag, ok := flags[ptr]; ok && flag.Changed {
+		return "flag --" + flag.Name
+	}
+	if env := envVarName(a.setupConfig.FangsConfig.AppName, path); os.Getenv(env) != "" {
+		return "env " + env
+	}
+	if file := a.state.ConfigSource(strings.ToLower(strings.Join(path, "."))); file != "" {
+		return "file " + file
+	}
+	return "default"
+}
+
+// tailFile returns (up to) the last n lines of the file.
+func tailFile(path string, n int) (string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.SplitAfter(string(contents), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, ""), nil
+}
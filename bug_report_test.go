@@ -0,0 +1,94 @@
+package clio
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bugReportTestConfig struct {
+	Registry struct {
+		URL      string `mapstructure:"url"`
+		Password string `mapstructure:"password"`
+	} `mapstructure:"registry"`
+	Name string `mapstructure:"name"`
+}
+
+func Test_Application_bugReport(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("APP_NAME", "from-env")
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "app.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("registry:\n  url: https://registry.example\n  password: hunter2\n"), 0o600))
+
+	logFile := filepath.Join(dir, "app.log")
+	require.NoError(t, os.WriteFile(logFile, []byte("first\nsecond\n"), 0o600))
+
+	cfg := NewSetupConfig(Identification{Name: "app", Version: "v1.2.3"}).WithNoBus().WithBugReportCommand()
+	cfg.FangsConfig.File = configFile
+	cfg.DefaultLoggingConfig.FileLocation = logFile
+	app := New(*cfg)
+
+	root := app.SetupRootCommand(&cobra.Command{})
+	got := &bugReportTestConfig{}
+	root.AddCommand(app.SetupCommand(&cobra.Command{
+		Use:  "scan",
+		RunE: func(*cobra.Command, []string) error { return nil },
+	}, got))
+
+	root.SetArgs([]string{"scan"})
+	require.NoError(t, root.Execute())
+
+	archive := filepath.Join(dir, "report.zip")
+	root.SetArgs([]string{"bug-report", "--file", archive})
+	require.NoError(t, root.Execute())
+
+	files := readZip(t, archive)
+	assert.Contains(t, files["version.json"], `"version": "v1.2.3"`)
+	assert.Contains(t, files["config.yaml"], "url: https://registry.example")
+	assert.NotContains(t, files["config.yaml"], "hunter2")
+	assert.Contains(t, files["config-sources.txt"], "registry.url: file "+configFile+"\n")
+	assert.Contains(t, files["config-sources.txt"], "name: env APP_NAME\n")
+	assert.Contains(t, files["config-sources.txt"], "log.format: default\n")
+	assert.Contains(t, files, "environment.json")
+	assert.Contains(t, files["log.txt"], "first\nsecond\n")
+	assert.Contains(t, files[runStatsFile], `"command": "app scan"`)
+}
+
+func Test_tailFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "log")
+	require.NoError(t, os.WriteFile(file, []byte("1\n2\n3\n4\n"), 0o600))
+
+	tail, err := tailFile(file, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "3\n4\n", tail)
+
+	tail, err = tailFile(file, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "1\n2\n3\n4\n", tail)
+}
+
+func readZip(t *testing.T, path string) map[string]string {
+	t.Helper()
+	r, err := zip.OpenReader(path)
+	require.NoError(t, err)
+	defer r.Close()
+
+	files := map[string]string{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		contents, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		_ = rc.Close()
+		files[f.Name] = string(contents)
+	}
+	return files
+}
@@ -0,0 +1,382 @@
+package clio
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wagoodman/go-partybus"
+
+	"github.com/boss-net/go-logger/adapter/redact"
+)
+
+// busBridgeTokenFile is the file within the state dir holding the token remote subscribers must present to publish
+// control events on the bus of the running application (see SetupConfig.WithBusBridge).
+const busBridgeTokenFile = "bus-bridge.token"
+
+// busBridgeBuffer is the number of events buffered for each remote subscriber; events are dropped for subscribers
+// that fall further behind than this (the application is never blocked by a slow subscriber).
+const busBridgeBuffer = 256
+
+// RemoteEvent is the representation of a bus event sent to (and received from) remote subscribers.
+type RemoteEvent struct {
+	Type       string          `json:"type"`
+	Value      json.RawMessage `json:"value,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Invocation string          `json:"invocation,omitempty"` // the invocation ID of the publishing application (see State.InvocationID)
+}
+
+// BusBridge streams bus events to remote subscribers and accepts control events from them, allowing a dashboard or
+// companion process to mirror the progress of the application. It serves over http:
+//
+//	GET  /ws       a websocket, where all events are sent as text messages (RemoteEvent json), and the subscriber
+//	               sends control events to publish on the bus as text messages in the same form
+//	GET  /events   a server-sent event stream of all events (as RemoteEvent json)
+//	POST /control  a RemoteEvent to publish on the bus
+//
+// Event values that cannot be encoded as json are sent without a value, and all values are redacted. Control events
+// are only accepted for the allowed control event types, with the token given to WithControlToken (as an
+// "Authorization: Bearer <token>" header, or the "token" query parameter for browsers, which cannot set headers on
+// websockets). Websockets are closed when the subscriber sends a control event which is not accepted.
+type BusBridge struct {
+	bus          *partybus.Bus
+	redactor     redact.Redactor
+	controlTypes map[partybus.EventType]struct{}
+	controlToken string
+	invocation   string
+
+	lock        sync.Mutex
+	subscribers map[chan []byte]struct{}
+	closed      bool
+	done        chan struct{}
+	mux         *http.ServeMux
+}
+
+var _ http.Handler = (*BusBridge)(nil)
+
+// NewBusBridge creates a bridge for the given bus, accepting the given event types from remote subscribers.
+func NewBusBridge(bus *partybus.Bus, redactor redact.Redactor, controlTypes ...partybus.EventType) *BusBridge {
+	b := &BusBridge{
+		bus:          bus,
+		redactor:     redactor,
+		controlTypes: map[partybus.EventType]struct{}{},
+		subscribers:  map[chan []byte]struct{}{},
+		done:         make(chan struct{}),
+		mux:          http.NewServeMux(),
+	}
+	for _, t := range controlTypes {
+		b.controlTypes[t] = struct{}{}
+	}
+	b.mux.HandleFunc("/ws", b.serveWebSocket)
+	b.mux.HandleFunc("/events", b.serveEvents)
+	b.mux.HandleFunc("/control", b.serveControl)
+	return b
+}
+
+// WithInvocationID includes the invocation ID of the application (see State.InvocationID) in all events sent to
+// remote subscribers, so that events from multiple processes can be correlated.
+func (b *BusBridge) WithInvocationID(id string) *BusBridge {
+	b.invocation = id
+	return b
+}
+
+// WithControlToken sets the token remote subscribers must present to publish control events (no control events are
+// accepted without a token).
+func (b *BusBridge) WithControlToken(token string) *BusBridge {
+	b.controlToken = token
+	return b
+}
+
+func (b *BusBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mux.ServeHTTP(w, r)
+}
+
+// Forward sends the event to all remote subscribers.
+func (b *BusBridge) Forward(e partybus.Event) {
+	// events published with State.Publish carry the trace context, which is not sent to remote subscribers
+	_, e = eventContext(context.Background(), nil, e)
+
+	by, err := json.Marshal(b.remoteEvent(e))
+	if err != nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for sub := range b.subscribers {
+		select {
+		case sub <- by:
+		default:
+			// drop the event for slow subscribers
+		}
+	}
+}
+
+// Close ends all event streams.
+func (b *BusBridge) Close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	close(b.done)
+}
+
+func (b *BusBridge) remoteEvent(e partybus.Event) RemoteEvent {
+	re := RemoteEvent{Type: string(e.Type), Invocation: b.invocation}
+	if e.Value != nil {
+		if by, err := json.Marshal(e.Value); err == nil {
+			re.Value = json.RawMessage(b.redact(string(by)))
+		}
+	}
+	if e.Error != nil {
+		re.Error = b.redact(e.Error.Error())
+	}
+	if re.Value != nil && !json.Valid(re.Value) {
+		// redaction should not break the json, but never send an invalid document
+		re.Value = nil
+	}
+	return re
+}
+
+func (b *BusBridge) redact(s string) string {
+	if b.redactor == nil {
+		return s
+	}
+	return b.redactor.RedactString(s)
+}
+
+func (b *BusBridge) subscribe() (chan []byte, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		return nil, false
+	}
+	sub := make(chan []byte, busBridgeBuffer)
+	b.subscribers[sub] = struct{}{}
+	return sub, true
+}
+
+func (b *BusBridge) unsubscribe(sub chan []byte) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.subscribers, sub)
+}
+
+func (b *BusBridge) serveEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sub, ok := b.subscribe()
+	if !ok {
+		http.Error(w, "the application has exited", http.StatusGone)
+		return
+	}
+	defer b.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case by := <-sub:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", by); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-b.done:
+			// send any remaining events before ending the stream
+			for {
+				select {
+				case by := <-sub:
+					fmt.Fprintf(w, "data: %s\n\n", by)
+				default:
+					flusher.Flush()
+					return
+				}
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (b *BusBridge) serveControl(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var re RemoteEvent
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&re); err != nil {
+		http.Error(w, fmt.Sprintf("invalid event: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if !b.authorized(r) {
+		http.Error(w, "a valid control token is required", http.StatusUnauthorized)
+		return
+	}
+
+	if err := b.publishControl(re, r.RemoteAddr); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// publishControl publishes a control event from the remote subscriber at the source address, when the type is
+// accepted.
+func (b *BusBridge) publishControl(re RemoteEvent, source string) error {
+	t := partybus.EventType(re.Type)
+	if _, ok := b.controlTypes[t]; !ok {
+		return fmt.Errorf("event type %q is not accepted", re.Type)
+	}
+
+	e := partybus.Event{Type: t, Source: source}
+	if len(re.Value) > 0 {
+		e.Value = re.Value
+	}
+	if re.Error != "" {
+		e.Error = errors.New(re.Error)
+	}
+	b.bus.Publish(e)
+	return nil
+}
+
+// authorized indicates if the request has the control token.
+func (b *BusBridge) authorized(r *http.Request) bool {
+	if b.controlToken == "" {
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(b.controlToken)) == 1
+}
+
+var _ UI = (*bridgeUI)(nil)
+
+// bridgeUI forwards all events handled by the UI to the bus bridge. When there is no UI the bridge is still given
+// all events.
+type bridgeUI struct {
+	UI
+	bridge *BusBridge
+}
+
+func (u bridgeUI) Setup(subscription partybus.Unsubscribable) error {
+	if u.UI == nil {
+		return nil
+	}
+	return u.UI.Setup(subscription)
+}
+
+func (u bridgeUI) Handle(e partybus.Event) error {
+	u.bridge.Forward(e)
+	if u.UI == nil {
+		return nil
+	}
+	return u.UI.Handle(e)
+}
+
+func (u bridgeUI) Teardown(force bool) error {
+	if u.UI == nil {
+		return nil
+	}
+	return u.UI.Teardown(force)
+}
+
+// startBusBridge serves the bus bridge on the configured address (see SetupConfig.WithBusBridge) for the duration
+// of the eventloop.
+func (a *application) startBusBridge(uis []UI) ([]UI, func(), error) {
+	addr := a.setupConfig.BusBridgeAddress
+	if addr == "" || a.state.Bus == nil {
+		return uis, func() {}, nil
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to start bus bridge: %w", err)
+	}
+
+	bridge := NewBusBridge(a.state.Bus, a.state.RedactStore, a.setupConfig.BusBridgeControlTypes...).
+		WithInvocationID(a.state.InvocationID())
+
+	removeToken := func() {}
+	if len(a.setupConfig.BusBridgeControlTypes) > 0 {
+		token, tokenFile, err := a.writeBusBridgeToken()
+		if err != nil {
+			_ = listener.Close()
+			return nil, nil, fmt.Errorf("unable to start bus bridge: %w", err)
+		}
+		bridge.WithControlToken(token)
+		removeToken = func() { _ = os.Remove(tokenFile) }
+	}
+
+	server := &http.Server{Handler: bridge, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	a.state.Logger.WithFields("address", listener.Addr().String()).Debug("serving bus bridge")
+
+	var out []UI
+	for _, ui := range uis {
+		out = append(out, bridgeUI{UI: ui, bridge: bridge})
+	}
+	if len(out) == 0 {
+		out = append(out, bridgeUI{bridge: bridge})
+	}
+
+	return out, func() {
+		bridge.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+		removeToken()
+	}, nil
+}
+
+// writeBusBridgeToken creates a random control token for the bus bridge, written to a file only the current user can
+// read (where companion processes read it from).
+func (a *application) writeBusBridgeToken() (string, string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", "", fmt.Errorf("unable to create control token: %w", err)
+	}
+	token := hex.EncodeToString(b[:])
+
+	dir, err := stateDir(a.setupConfig.ID.Name)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to write control token: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", "", fmt.Errorf("unable to write control token: %w", err)
+	}
+	file := filepath.Join(dir, busBridgeTokenFile)
+	if err := os.WriteFile(file, []byte(token), 0o600); err != nil {
+		return "", "", fmt.Errorf("unable to write control token: %w", err)
+	}
+	return token, file, nil
+}
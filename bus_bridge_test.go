@@ -0,0 +1,243 @@
+package clio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wagoodman/go-partybus"
+
+	"github.com/boss-net/go-logger/adapter/discard"
+	"github.com/boss-net/go-logger/adapter/redact"
+)
+
+func Test_BusBridge_events(t *testing.T) {
+	store := redact.NewStore("s3cr3t")
+	bridge := NewBusBridge(partybus.NewBus(), store).WithInvocationID("invocation-1")
+	server := httptest.NewServer(bridge)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	bridge.Forward(partybus.Event{Type: "progress", Value: map[string]string{"token": "s3cr3t"}})
+	bridge.Forward(partybus.Event{Type: "traced", Value: tracedValue{value: []int{1, 2}, carrier: map[string]string{"traceparent": "00-1"}}})
+	bridge.Forward(partybus.Event{Type: "unencodable", Value: make(chan int), Error: errors.New("failed")})
+	bridge.Close()
+
+	var got []RemoteEvent
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var re RemoteEvent
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &re))
+		got = append(got, re)
+	}
+
+	require.Len(t, got, 3)
+	assert.Equal(t, "progress", got[0].Type)
+	assert.JSONEq(t, `{"token":"*******"}`, string(got[0].Value))
+	assert.Equal(t, "invocation-1", got[0].Invocation)
+	assert.Equal(t, "traced", got[1].Type)
+	assert.JSONEq(t, `[1,2]`, string(got[1].Value))
+	assert.Equal(t, "unencodable", got[2].Type)
+	assert.Nil(t, got[2].Value)
+	assert.Equal(t, "failed", got[2].Error)
+}
+
+func Test_BusBridge_control(t *testing.T) {
+	bus := partybus.NewBus()
+	sub := bus.Subscribe()
+	server := httptest.NewServer(NewBusBridge(bus, nil, "cancel").WithControlToken("t0ken"))
+	defer server.Close()
+
+	post := func(token, body string) int {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/control", strings.NewReader(body))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, post("", `{"type":"cancel"}`))
+	assert.Equal(t, http.StatusUnauthorized, post("wrong", `{"type":"cancel"}`))
+	assert.Equal(t, http.StatusForbidden, post("t0ken", `{"type":"shutdown"}`))
+	assert.Equal(t, http.StatusAccepted, post("t0ken", `{"type":"cancel","value":{"job":1}}`))
+
+	select {
+	case e := <-sub.Events():
+		assert.Equal(t, partybus.EventType("cancel"), e.Type)
+		assert.JSONEq(t, `{"job":1}`, string(e.Value.(json.RawMessage)))
+	case <-time.After(5 * time.Second):
+		t.Fatal("control event was not published")
+	}
+}
+
+// dialWebSocket connects to the websocket of the bus bridge, returning the connection and a reader of its frames.
+func dialWebSocket(t *testing.T, server *httptest.Server, query string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	_, err = fmt.Fprintf(conn, "GET /ws%s HTTP/1.1\r\nHost: bridge\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", query)
+	require.NoError(t, err)
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	// the example from RFC 6455
+	require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+	return conn, r
+}
+
+func Test_BusBridge_webSocket(t *testing.T) {
+	bus := partybus.NewBus()
+	sub := bus.Subscribe()
+	bridge := NewBusBridge(bus, redact.NewStore("s3cr3t"), "cancel").WithControlToken("t0ken")
+	server := httptest.NewServer(bridge)
+	defer server.Close()
+
+	conn, r := dialWebSocket(t, server, "?token=t0ken")
+	mask := []byte{1, 2, 3, 4}
+
+	// events are streamed to the subscriber
+	require.Eventually(t, func() bool {
+		bridge.lock.Lock()
+		defer bridge.lock.Unlock()
+		return len(bridge.subscribers) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	bridge.Forward(partybus.Event{Type: "progress", Value: map[string]string{"token": "s3cr3t"}})
+	f, err := readWebSocketFrame(r, wsMaxMessage)
+	require.NoError(t, err)
+	assert.Equal(t, byte(wsText), f.opcode)
+	assert.False(t, f.masked)
+	var re RemoteEvent
+	require.NoError(t, json.Unmarshal(f.payload, &re))
+	assert.Equal(t, "progress", re.Type)
+	assert.JSONEq(t, `{"token":"*******"}`, string(re.Value))
+
+	// pings are answered
+	require.NoError(t, writeWebSocketFrame(conn, wsPing, []byte("hi"), mask))
+	f, err = readWebSocketFrame(r, wsMaxMessage)
+	require.NoError(t, err)
+	assert.Equal(t, byte(wsPong), f.opcode)
+	assert.Equal(t, "hi", string(f.payload))
+
+	// control events are published on the bus
+	require.NoError(t, writeWebSocketFrame(conn, wsText, []byte(`{"type":"cancel","value":{"job":1}}`), mask))
+	select {
+	case e := <-sub.Events():
+		assert.Equal(t, partybus.EventType("cancel"), e.Type)
+		assert.JSONEq(t, `{"job":1}`, string(e.Value.(json.RawMessage)))
+	case <-time.After(5 * time.Second):
+		t.Fatal("control event was not published")
+	}
+
+	// the connection is closed when a control event is not accepted
+	require.NoError(t, writeWebSocketFrame(conn, wsText, []byte(`{"type":"shutdown"}`), mask))
+	f, err = readWebSocketFrame(r, wsMaxMessage)
+	require.NoError(t, err)
+	assert.Equal(t, byte(wsClose), f.opcode)
+	assert.Equal(t, uint16(wsClosePolicyViolation), binary.BigEndian.Uint16(f.payload))
+	assert.Equal(t, `event type "shutdown" is not accepted`, string(f.payload[2:]))
+}
+
+func Test_BusBridge_webSocketRejected(t *testing.T) {
+	server := httptest.NewServer(NewBusBridge(partybus.NewBus(), nil, "cancel").WithControlToken("t0ken"))
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		query  string
+		frame  []byte
+		mask   []byte
+		code   uint16
+		reason string
+	}{
+		{name: "without token", frame: []byte(`{"type":"cancel"}`), mask: []byte{1, 2, 3, 4}, code: wsClosePolicyViolation, reason: "a valid control token is required"},
+		{name: "wrong token", query: "?token=wrong", frame: []byte(`{"type":"cancel"}`), mask: []byte{1, 2, 3, 4}, code: wsClosePolicyViolation, reason: "a valid control token is required"},
+		{name: "invalid json", query: "?token=t0ken", frame: []byte(`{`), mask: []byte{1, 2, 3, 4}, code: wsCloseInvalidPayload, reason: "invalid event: unexpected end of JSON input"},
+		{name: "unmasked", query: "?token=t0ken", frame: []byte(`{"type":"cancel"}`), code: wsCloseProtocolError, reason: "frames from clients must be masked"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, r := dialWebSocket(t, server, tt.query)
+			require.NoError(t, writeWebSocketFrame(conn, wsText, tt.frame, tt.mask))
+			f, err := readWebSocketFrame(r, wsMaxMessage)
+			require.NoError(t, err)
+			require.Equal(t, byte(wsClose), f.opcode)
+			assert.Equal(t, tt.code, binary.BigEndian.Uint16(f.payload))
+			assert.Equal(t, tt.reason, string(f.payload[2:]))
+		})
+	}
+
+	resp, err := http.Get(server.URL + "/ws")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func Test_BusBridge_controlWithoutToken(t *testing.T) {
+	server := httptest.NewServer(NewBusBridge(partybus.NewBus(), nil, "cancel"))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/control", "application/json", strings.NewReader(`{"type":"cancel"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func Test_Application_busBridgeToken(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithBusBridge("127.0.0.1:0", "cancel")).(*application)
+	app.state.Bus = partybus.NewBus()
+	app.state.Logger = discard.New()
+
+	_, stop, err := app.startBusBridge(nil)
+	require.NoError(t, err)
+
+	file := filepath.Join(os.Getenv("XDG_STATE_HOME"), "app", busBridgeTokenFile)
+	token, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Len(t, string(token), 64)
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(file)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+
+	stop()
+	assert.NoFileExists(t, file)
+}
+
+func Test_bridgeUI_withoutUI(t *testing.T) {
+	bridge := NewBusBridge(partybus.NewBus(), nil)
+	ui := bridgeUI{bridge: bridge}
+	assert.NoError(t, ui.Setup(nil))
+	assert.NoError(t, ui.Handle(partybus.Event{Type: "test"}))
+	assert.NoError(t, ui.Teardown(false))
+}
@@ -0,0 +1,356 @@
+package clio
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec // required by the websocket handshake (RFC 6455), not used for security
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// the websocket protocol (RFC 6455), as much as the bus bridge needs: text messages both ways, ping/pong, and close
+const (
+	webSocketGUID    = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	webSocketVersion = "13"
+
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+
+	wsCloseNormal          = 1000
+	wsCloseGoingAway       = 1001
+	wsCloseProtocolError   = 1002
+	wsCloseUnsupportedData = 1003
+	wsCloseInvalidPayload  = 1007
+	wsClosePolicyViolation = 1008
+	wsCloseTooBig          = 1009
+
+	// the largest message accepted from remote subscribers (the same as control events sent with POST)
+	wsMaxMessage = 1 << 20
+)
+
+// webSocketError closes the connection with the status code and reason.
+type webSocketError struct {
+	code   uint16
+	reason string
+}
+
+func (e *webSocketError) Error() string {
+	return fmt.Sprintf("websocket closed (%d): %s", e.code, e.reason)
+}
+
+// webSocketConn is a websocket connection accepted by the bus bridge.
+type webSocketConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	// frames are written by the event stream and when answering pings and close frames
+	lock   sync.Mutex
+	closed bool
+}
+
+// webSocketFrame is a single frame of a websocket message.
+type webSocketFrame struct {
+	fin     bool
+	opcode  byte
+	masked  bool
+	payload []byte
+}
+
+// upgradeWebSocket accepts the websocket handshake of the request, or responds with an error (returning nil).
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) *webSocketConn {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "a websocket handshake is required", http.StatusBadRequest)
+		return nil
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != webSocketVersion {
+		w.Header().Set("Sec-WebSocket-Version", webSocketVersion)
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websockets are not supported", http.StatusInternalServerError)
+		return nil
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil
+	}
+
+	_, err = fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", webSocketAccept(key))
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil
+	}
+	return &webSocketConn{conn: conn, r: rw.Reader}
+}
+
+// webSocketAccept is the Sec-WebSocket-Accept header of the handshake response for the given key.
+func webSocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + webSocketGUID)) //nolint:gosec // see the import
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerContains indicates if the comma separated values of the header include the token (ignoring case).
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeText sends the message as a text frame.
+func (c *webSocketConn) writeText(message []byte) error {
+	return c.write(wsText, message)
+}
+
+func (c *webSocketConn) write(opcode byte, payload []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	return writeWebSocketFrame(c.conn, opcode, payload, nil)
+}
+
+// close sends a close frame with the status code and reason (once), then closes the connection.
+func (c *webSocketConn) close(code uint16, reason string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	if len(reason) > 123 {
+		// the payload of control frames is at most 125 bytes
+		reason = reason[:123]
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, code)
+	_ = writeWebSocketFrame(c.conn, wsClose, append(payload, reason...), nil)
+	_ = c.conn.Close()
+}
+
+// readMessage returns the next text message from the client, answering pings meanwhile. The error is a
+// *webSocketError when the connection must be closed with a status code, or io.EOF when the client closed it.
+func (c *webSocketConn) readMessage() ([]byte, error) {
+	var message []byte
+	fragmented := false
+	for {
+		f, err := readWebSocketFrame(c.r, wsMaxMessage-len(message))
+		if err != nil {
+			return nil, err
+		}
+		if !f.masked {
+			return nil, &webSocketError{code: wsCloseProtocolError, reason: "frames from clients must be masked"}
+		}
+		switch f.opcode {
+		case wsPing:
+			if err := c.write(wsPong, f.payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			return nil, io.EOF
+		case wsBinary:
+			return nil, &webSocketError{code: wsCloseUnsupportedData, reason: "only text messages are accepted"}
+		case wsText:
+			if fragmented {
+				return nil, &webSocketError{code: wsCloseProtocolError, reason: "expected a continuation frame"}
+			}
+		case wsContinuation:
+			if !fragmented {
+				return nil, &webSocketError{code: wsCloseProtocolError, reason: "unexpected continuation frame"}
+			}
+		default:
+			return nil, &webSocketError{code: wsCloseProtocolError, reason: fmt.Sprintf("unknown opcode %d", f.opcode)}
+		}
+		message = append(message, f.payload...)
+		if f.fin {
+			return message, nil
+		}
+		fragmented = true
+	}
+}
+
+// readWebSocketFrame reads a frame (unmasking the payload), with a payload of at most the given size.
+func readWebSocketFrame(r io.Reader, limit int) (webSocketFrame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return webSocketFrame{}, err
+	}
+	f := webSocketFrame{fin: header[0]&0x80 != 0, opcode: header[0] & 0x0f, masked: header[1]&0x80 != 0}
+	if header[0]&0x70 != 0 {
+		return f, &webSocketError{code: wsCloseProtocolError, reason: "no extensions are supported"}
+	}
+
+	size := uint64(header[1] & 0x7f)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return f, err
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return f, err
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+	if f.opcode >= wsClose && (size > 125 || !f.fin) {
+		return f, &webSocketError{code: wsCloseProtocolError, reason: "invalid control frame"}
+	}
+	if size > uint64(limit) {
+		return f, &webSocketError{code: wsCloseTooBig, reason: "message too big"}
+	}
+
+	var mask [4]byte
+	if f.masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return f, err
+		}
+	}
+	f.payload = make([]byte, size)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return f, err
+	}
+	if f.masked {
+		for i := range f.payload {
+			f.payload[i] ^= mask[i%4]
+		}
+	}
+	return f, nil
+}
+
+// writeWebSocketFrame writes the payload as a single (final) frame, masked when given a mask (as clients must).
+func writeWebSocketFrame(w io.Writer, opcode byte, payload []byte, mask []byte) error {
+	frame := []byte{0x80 | opcode}
+	maskBit := byte(0)
+	if mask != nil {
+		maskBit = 0x80
+	}
+	switch size := len(payload); {
+	case size <= 125:
+		frame = append(frame, maskBit|byte(size))
+	case size <= 0xffff:
+		frame = append(frame, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[len(frame)-2:], uint16(size))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[len(frame)-8:], uint64(size))
+	}
+	if mask != nil {
+		frame = append(frame, mask...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		for i := range frame[start:] {
+			frame[start+i] ^= mask[i%4]
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+// serveWebSocket streams all events to the remote subscriber as text messages (RemoteEvent json) over a websocket,
+// publishing the control events it sends back on the bus (as text messages in the same form). The connection is
+// closed when the subscriber sends an invalid or rejected control event.
+func (b *BusBridge) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn := upgradeWebSocket(w, r)
+	if conn == nil {
+		return
+	}
+	sub, ok := b.subscribe()
+	if !ok {
+		conn.close(wsCloseGoingAway, "the application has exited")
+		return
+	}
+	defer b.unsubscribe(sub)
+
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		conn.close(b.receiveControl(conn, b.authorized(r), r.RemoteAddr))
+	}()
+	defer func() {
+		conn.close(wsCloseNormal, "")
+		<-received
+	}()
+
+	for {
+		select {
+		case by := <-sub:
+			if err := conn.writeText(by); err != nil {
+				return
+			}
+		case <-b.done:
+			// send any remaining events before ending the stream
+			for {
+				select {
+				case by := <-sub:
+					_ = conn.writeText(by)
+				default:
+					conn.close(wsCloseGoingAway, "the application has exited")
+					return
+				}
+			}
+		case <-received:
+			return
+		}
+	}
+}
+
+// receiveControl publishes the control events sent over the websocket until the connection ends, returning the
+// status code and reason to close the connection with.
+func (b *BusBridge) receiveControl(conn *webSocketConn, authorized bool, source string) (uint16, string) {
+	for {
+		message, err := conn.readMessage()
+		if err != nil {
+			var wsErr *webSocketError
+			if errors.As(err, &wsErr) {
+				return wsErr.code, wsErr.reason
+			}
+			return wsCloseNormal, ""
+		}
+
+		var re RemoteEvent
+		if err := json.Unmarshal(message, &re); err != nil {
+			return wsCloseInvalidPayload, fmt.Sprintf("invalid event: %v", err)
+		}
+		if !authorized {
+			return wsClosePolicyViolation, "a valid control token is required"
+		}
+		if err := b.publishControl(re, source); err != nil {
+			return wsClosePolicyViolation, err.Error()
+		}
+	}
+}
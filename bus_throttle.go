@@ -0,0 +1,173 @@
+package clio
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/wagoodman/go-partybus"
+)
+
+// DefaultThrottleRate is the number of events per second published for each task by a ThrottledPublisher (see
+// State.ThrottledPublisher), which is about as often as a terminal UI can usefully redraw.
+const DefaultThrottleRate = 30
+
+var _ partybus.Publisher = (*ThrottledPublisher)(nil)
+
+// ThrottledPublisher wraps a publisher (such as the bus), coalescing high-frequency events (e.g. progress updates)
+// so that UIs and remote subscribers (see SetupConfig.WithBusBridge) are not flooded. At most the given rate of
+// events per second is published for each task, where a task is identified by the event type and source (see
+// WithKey). Events arriving faster than that replace the pending event of the task, which is published once the
+// interval has passed, so the final value of each task is always delivered. Events with an error are published
+// immediately (replacing the pending event). Call Flush to publish all pending events immediately (e.g. once the work
+// is complete).
+type ThrottledPublisher struct {
+	next     partybus.Publisher
+	interval time.Duration
+	key      func(partybus.Event) any
+
+	lock    sync.Mutex
+	tasks   map[any]*throttledTask
+	sweepAt int
+}
+
+// throttledTask is the throttling state of a single task.
+type throttledTask struct {
+	published time.Time       // when an event was last published
+	pending   *partybus.Event // the latest event not yet published
+	timer     *time.Timer     // publishes the pending event once the interval has passed
+}
+
+// NewThrottledPublisher wraps the publisher, publishing at most the given number of events per second for each task
+// (DefaultThrottleRate when not positive).
+func NewThrottledPublisher(next partybus.Publisher, perSecond int) *ThrottledPublisher {
+	if perSecond <= 0 {
+		perSecond = DefaultThrottleRate
+	}
+	return &ThrottledPublisher{
+		next:     next,
+		interval: time.Second / time.Duration(perSecond),
+		key:      throttleKey,
+		tasks:    map[any]*throttledTask{},
+	}
+}
+
+// WithKey identifies the task of each event with the given function (the event type and source by default), where
+// events of the same task are coalesced. Keys must be comparable.
+func (p *ThrottledPublisher) WithKey(fn func(partybus.Event) any) *ThrottledPublisher {
+	p.key = fn
+	return p
+}
+
+// throttleKey identifies the task of the event by type and source (or only the type when the source can't be used as
+// a key).
+func throttleKey(e partybus.Event) any {
+	type key struct {
+		eventType partybus.EventType
+		source    any
+	}
+	if e.Source != nil && !reflect.TypeOf(e.Source).Comparable() {
+		return key{eventType: e.Type}
+	}
+	return key{eventType: e.Type, source: e.Source}
+}
+
+func (p *ThrottledPublisher) Publish(e partybus.Event) {
+	if p == nil || p.next == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	k := p.key(e)
+	task, ok := p.tasks[k]
+	if !ok {
+		p.sweep()
+		task = &throttledTask{}
+		p.tasks[k] = task
+	}
+
+	now := time.Now()
+	wait := p.interval - now.Sub(task.published)
+	if e.Error == nil && (task.pending != nil || wait > 0) {
+		task.pending = &e
+		if task.timer == nil {
+			task.timer = time.AfterFunc(wait, func() { p.publishPending(task) })
+		}
+		return
+	}
+
+	if task.timer != nil {
+		task.timer.Stop()
+		task.timer = nil
+	}
+	task.pending = nil
+	task.published = now
+	p.next.Publish(e)
+}
+
+// publishPending publishes the pending event of the task (if it has not been flushed since).
+func (p *ThrottledPublisher) publishPending(task *throttledTask) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	task.timer = nil
+	if task.pending == nil {
+		return
+	}
+	e := *task.pending
+	task.pending = nil
+	task.published = time.Now()
+	p.next.Publish(e)
+}
+
+// Flush publishes all pending events immediately.
+func (p *ThrottledPublisher) Flush() {
+	if p == nil || p.next == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+	for _, task := range p.tasks {
+		if task.timer != nil {
+			task.timer.Stop()
+			task.timer = nil
+		}
+		if task.pending == nil {
+			continue
+		}
+		e := *task.pending
+		task.pending = nil
+		task.published = now
+		p.next.Publish(e)
+	}
+}
+
+// sweep forgets tasks that are no longer being throttled, once there are enough of them to be worth it (so that
+// short-lived tasks, such as progress for each file, don't accumulate).
+func (p *ThrottledPublisher) sweep() {
+	if len(p.tasks) < p.sweepAt {
+		return
+	}
+	now := time.Now()
+	for k, task := range p.tasks {
+		if task.pending == nil && now.Sub(task.published) >= p.interval {
+			delete(p.tasks, k)
+		}
+	}
+	p.sweepAt = 2 * len(p.tasks)
+	if p.sweepAt < 64 {
+		p.sweepAt = 64
+	}
+}
+
+// ThrottledPublisher returns a publisher to the bus which coalesces high-frequency events (see ThrottledPublisher),
+// publishing at most the given number of events per second for each task (DefaultThrottleRate when not positive).
+func (s *State) ThrottledPublisher(perSecond int) *ThrottledPublisher {
+	if s.Bus == nil {
+		return NewThrottledPublisher(nil, perSecond)
+	}
+	return NewThrottledPublisher(s.Bus, perSecond)
+}
@@ -0,0 +1,102 @@
+package clio
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wagoodman/go-partybus"
+)
+
+type recordingPublisher struct {
+	lock   sync.Mutex
+	events []partybus.Event
+}
+
+func (r *recordingPublisher) Publish(e partybus.Event) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recordingPublisher) values() []any {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var values []any
+	for _, e := range r.events {
+		values = append(values, e.Value)
+	}
+	return values
+}
+
+func Test_ThrottledPublisher(t *testing.T) {
+	rec := &recordingPublisher{}
+	p := NewThrottledPublisher(rec, 10)
+
+	// the first event is published immediately, the rest are coalesced into the latest
+	for i := 1; i <= 5; i++ {
+		p.Publish(partybus.Event{Type: "progress", Source: "a", Value: i})
+	}
+	assert.Equal(t, []any{1}, rec.values())
+
+	// the final value is delivered once the interval has passed
+	require.Eventually(t, func() bool {
+		return len(rec.values()) == 2
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, []any{1, 5}, rec.values())
+}
+
+func Test_ThrottledPublisher_tasks(t *testing.T) {
+	rec := &recordingPublisher{}
+	p := NewThrottledPublisher(rec, 1)
+
+	p.Publish(partybus.Event{Type: "progress", Source: "a", Value: "a1"})
+	p.Publish(partybus.Event{Type: "progress", Source: "b", Value: "b1"})
+	p.Publish(partybus.Event{Type: "other", Source: "a", Value: "o1"})
+	p.Publish(partybus.Event{Type: "progress", Source: "a", Value: "a2"})
+	p.Publish(partybus.Event{Type: "progress", Source: "a", Value: "a3"})
+	assert.Equal(t, []any{"a1", "b1", "o1"}, rec.values())
+
+	// errors are not delayed
+	p.Publish(partybus.Event{Type: "progress", Source: "b", Value: "b2", Error: errors.New("failed")})
+	assert.Equal(t, []any{"a1", "b1", "o1", "b2"}, rec.values())
+
+	p.Flush()
+	assert.Equal(t, []any{"a1", "b1", "o1", "b2", "a3"}, rec.values())
+
+	// nothing is left to publish
+	p.Flush()
+	assert.Len(t, rec.values(), 5)
+}
+
+func Test_ThrottledPublisher_WithKey(t *testing.T) {
+	rec := &recordingPublisher{}
+	p := NewThrottledPublisher(rec, 1).WithKey(func(e partybus.Event) any {
+		return e.Type
+	})
+
+	p.Publish(partybus.Event{Type: "progress", Source: []string{"not", "comparable"}, Value: 1})
+	p.Publish(partybus.Event{Type: "progress", Source: []string{"other"}, Value: 2})
+	p.Flush()
+	assert.Equal(t, []any{1, 2}, rec.values())
+}
+
+func Test_ThrottledPublisher_uncomparableSource(t *testing.T) {
+	rec := &recordingPublisher{}
+	p := NewThrottledPublisher(rec, 1)
+
+	p.Publish(partybus.Event{Type: "progress", Source: map[string]int{}, Value: 1})
+	p.Publish(partybus.Event{Type: "progress", Source: map[string]int{}, Value: 2})
+	p.Flush()
+	assert.Equal(t, []any{1, 2}, rec.values())
+}
+
+func Test_State_ThrottledPublisher_withoutBus(t *testing.T) {
+	s := &State{}
+	p := s.ThrottledPublisher(0)
+	p.Publish(partybus.Event{Type: "progress"})
+	p.Flush()
+}
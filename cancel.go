@@ -0,0 +1,210 @@
+package clio
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/wagoodman/go-partybus"
+)
+
+// CancelEvent is published on the bus when the command being run is cancelled (see State.Cancel), with the
+// CancelReason as the event value.
+const CancelEvent partybus.EventType = "clio-cancel"
+
+// ExitCodeTimeout is the exit code for a command that was stopped after running longer than allowed (see
+// SetupConfig.WithRunTimeout).
+const ExitCodeTimeout = 124
+
+// CancelReason is why a command run ended early.
+type CancelReason string
+
+const (
+	// CancelInterrupt is an interrupt of the process (SIGINT or SIGTERM).
+	CancelInterrupt CancelReason = "interrupt"
+	// CancelTimeout is the command running longer than allowed (see SetupConfig.WithRunTimeout).
+	CancelTimeout CancelReason = "timeout"
+	// CancelUIQuit is the user quitting from the UI (see ErrUIQuit).
+	CancelUIQuit CancelReason = "ui-quit"
+	// CancelRemote is a cancel request from the control API (e.g. "ctl cancel") or the forwarding invocation of a daemon.
+	CancelRemote CancelReason = "remote"
+	// CancelParent is the context given to Application.Execute being cancelled (for any other reason).
+	CancelParent CancelReason = "parent"
+)
+
+// ErrUIQuit can be returned by UI.Handle to end the run (e.g. when the user presses "q"), which cancels the command
+// being run with CancelUIQuit.
+var ErrUIQuit = errors.New("quit from the UI")
+
+// CancelledError is returned when a command run ends early, giving the reason (see CancelReasonOf). It matches
+// context.Canceled with errors.Is (and context.DeadlineExceeded on timeout, and ErrCancelledByControl on a remote
+// cancel), so existing checks keep working.
+type CancelledError struct {
+	Reason CancelReason
+	Err    error // what the command returned once cancelled (if anything)
+}
+
+var _ ExitCoder = (*CancelledError)(nil)
+
+func (e *CancelledError) Error() string {
+	switch e.Reason {
+	case CancelInterrupt:
+		return "interrupted"
+	case CancelTimeout:
+		return "timed out"
+	case CancelUIQuit:
+		return "quit from the UI"
+	case CancelRemote:
+		return ErrCancelledByControl.Error()
+	}
+	return "cancelled"
+}
+
+func (e *CancelledError) Unwrap() error {
+	return e.Err
+}
+
+func (e *CancelledError) Is(target error) bool {
+	switch target {
+	case context.Canceled:
+		return true
+	case context.DeadlineExceeded:
+		return e.Reason == CancelTimeout
+	case ErrCancelledByControl:
+		return e.Reason == CancelRemote
+	}
+	return false
+}
+
+// ExitCode is ExitCodeTimeout on timeout, otherwise ExitCodeInterrupted.
+func (e *CancelledError) ExitCode() int {
+	if e.Reason == CancelTimeout {
+		return ExitCodeTimeout
+	}
+	return ExitCodeInterrupted
+}
+
+// CancelReasonOf returns why the run ended early, if the error is (or wraps) a CancelledError.
+func CancelReasonOf(err error) (CancelReason, bool) {
+	var cerr *CancelledError
+	if errors.As(err, &cerr) {
+		return cerr.Reason, true
+	}
+	return "", false
+}
+
+// cancellation tracks why the command being run was cancelled.
+type cancellation struct {
+	lock   sync.Mutex
+	reason CancelReason
+	cancel func()
+}
+
+// Cancel ends the command being run early, recording the reason (the first reason given is kept), which is reported
+// by the error the run ends with (see CancelledError), in the RunSummary, and as a CancelEvent.
+func (s *State) Cancel(reason CancelReason) {
+	c := &s.cancellation
+	c.lock.Lock()
+	if c.reason != "" {
+		c.lock.Unlock()
+		return
+	}
+	c.reason = reason
+	cancel := c.cancel
+	c.lock.Unlock()
+
+	if log := s.currentLogger(); log != nil {
+		log.WithFields("reason", reason).Debug("cancelling the command")
+	}
+	if s.Bus != nil {
+		s.Bus.Publish(partybus.Event{Type: CancelEvent, Value: reason})
+	}
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// CancelReason returns why the command being run was cancelled (empty when it was not).
+func (s *State) CancelReason() CancelReason {
+	s.cancellation.lock.Lock()
+	defer s.cancellation.lock.Unlock()
+	return s.cancellation.reason
+}
+
+// startCancellation returns the context for the command being run, which is cancelled by State.Cancel and once the
+// timeout (if any) passes, along with a function releasing the context once the run completes.
+func (s *State) startCancellation(ctx context.Context, timeout time.Duration) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	c := &s.cancellation
+	c.lock.Lock()
+	if ctx.Err() == nil {
+		// a reason given before the run started (e.g. an interrupt) is kept
+		c.reason = ""
+	}
+	c.cancel = cancel
+	c.lock.Unlock()
+
+	stopTimer := func() bool { return false }
+	if timeout > 0 {
+		stopTimer = time.AfterFunc(timeout, func() { s.Cancel(CancelTimeout) }).Stop
+	}
+	return ctx, func() {
+		stopTimer()
+		c.lock.Lock()
+		c.cancel = nil
+		c.lock.Unlock()
+		cancel()
+	}
+}
+
+// cancelled returns the error the run ended with, as a CancelledError when the run was cancelled (unless the command
+// failed for another reason).
+func (s *State) cancelled(ctx context.Context, err error) error {
+	reason := s.cancelReason(ctx)
+	if reason == "" {
+		return err
+	}
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return &CancelledError{Reason: reason, Err: err}
+}
+
+// cancelReason returns why the run (with the given context) was cancelled, which is the parent context when no other
+// reason was given.
+func (s *State) cancelReason(ctx context.Context) CancelReason {
+	if ctx.Err() == nil {
+		return ""
+	}
+	if reason := s.CancelReason(); reason != "" {
+		return reason
+	}
+	return CancelParent
+}
+
+var _ UI = (*quittableUI)(nil)
+
+// quittableUI cancels the command being run when the UI returns ErrUIQuit.
+type quittableUI struct {
+	UI
+	state *State
+}
+
+func withQuit(s *State, uis []UI) []UI {
+	var out []UI
+	for _, ui := range uis {
+		out = append(out, &quittableUI{UI: ui, state: s})
+	}
+	return out
+}
+
+func (u *quittableUI) Handle(e partybus.Event) error {
+	err := u.UI.Handle(e)
+	if errors.Is(err, ErrUIQuit) {
+		u.state.Cancel(CancelUIQuit)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,99 @@
+package clio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wagoodman/go-partybus"
+)
+
+func Test_CancelledError(t *testing.T) {
+	err := fmt.Errorf("scan failed: %w", &CancelledError{Reason: CancelTimeout, Err: context.Canceled})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, ErrCancelledByControl)
+	assert.Equal(t, "scan failed: timed out", err.Error())
+
+	reason, ok := CancelReasonOf(err)
+	assert.True(t, ok)
+	assert.Equal(t, CancelTimeout, reason)
+
+	_, ok = CancelReasonOf(errors.New("failed"))
+	assert.False(t, ok)
+
+	assert.Equal(t, ExitCodeTimeout, (&CancelledError{Reason: CancelTimeout}).ExitCode())
+	assert.Equal(t, ExitCodeInterrupted, (&CancelledError{Reason: CancelUIQuit}).ExitCode())
+	assert.ErrorIs(t, &CancelledError{Reason: CancelRemote}, ErrCancelledByControl)
+}
+
+func Test_State_Cancel(t *testing.T) {
+	s := &State{}
+	ctx, stop := s.startCancellation(context.Background(), 0)
+	defer stop()
+
+	s.Cancel(CancelUIQuit)
+	s.Cancel(CancelInterrupt)
+	<-ctx.Done()
+
+	// the first reason is kept
+	assert.Equal(t, CancelUIQuit, s.CancelReason())
+	err := s.cancelled(ctx, context.Canceled)
+	reason, ok := CancelReasonOf(err)
+	require.True(t, ok)
+	assert.Equal(t, CancelUIQuit, reason)
+
+	// other failures are kept as-is
+	failed := errors.New("failed")
+	assert.Equal(t, failed, s.cancelled(ctx, failed))
+}
+
+func Test_State_Cancel_timeout(t *testing.T) {
+	s := &State{}
+	ctx, stop := s.startCancellation(context.Background(), 10*time.Millisecond)
+	defer stop()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("command was not cancelled")
+	}
+	assert.Equal(t, CancelTimeout, s.CancelReason())
+}
+
+func Test_State_Cancel_parent(t *testing.T) {
+	s := &State{}
+	parent, cancel := context.WithCancel(context.Background())
+	ctx, stop := s.startCancellation(parent, 0)
+	defer stop()
+
+	assert.NoError(t, s.cancelled(ctx, nil))
+	cancel()
+	reason, _ := CancelReasonOf(s.cancelled(ctx, nil))
+	assert.Equal(t, CancelParent, reason)
+}
+
+var _ UI = (*quittingUI)(nil)
+
+type quittingUI struct {
+	mockUI
+}
+
+func (quittingUI) Handle(_ partybus.Event) error {
+	return ErrUIQuit
+}
+
+func Test_withQuit(t *testing.T) {
+	s := &State{}
+	ctx, stop := s.startCancellation(context.Background(), 0)
+	defer stop()
+
+	uis := withQuit(s, []UI{quittingUI{}})
+	require.NoError(t, uis[0].Handle(partybus.Event{}))
+	<-ctx.Done()
+	assert.Equal(t, CancelUIQuit, s.CancelReason())
+}
@@ -0,0 +1,339 @@
+package clio
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/boss-net/fangs"
+)
+
+const (
+	defaultCheckpointInterval = 30 * time.Second
+
+	// checkpointVersion is the version of the checkpoint file envelope (not the application data within it)
+	checkpointVersion = 1
+)
+
+// Checkpointable is the serialization contract for command progress that can be checkpointed and resumed (see
+// State.Resume and State.Checkpoint). MarshalCheckpoint may be called concurrently with the command making progress
+// (for periodic checkpoints), so implementations must synchronize access to their state. Data given to
+// UnmarshalCheckpoint is always from the same command with the same working directory, arguments, flags, and
+// configuration, but may be from an older version of the application.
+type Checkpointable interface {
+	MarshalCheckpoint() ([]byte, error)
+	UnmarshalCheckpoint(data []byte) error
+}
+
+// CheckpointConfig is the user-facing configuration for resuming interrupted commands (see
+// SetupConfig.WithCheckpoints).
+type CheckpointConfig struct {
+	Resume bool `yaml:"resume" json:"resume" mapstructure:"resume"`
+}
+
+var _ fangs.FlagAdder = (*CheckpointConfig)(nil)
+
+func (c *CheckpointConfig) AddFlags(flags fangs.FlagSet) {
+	flags.BoolVarP(&c.Resume, "resume", "", "continue from where a previous (interrupted) run of the same command left off")
+}
+
+// checkpointFile is the envelope written to the state dir for each checkpoint.
+type checkpointFile struct {
+	Version   int       `json:"version"`
+	Signature string    `json:"signature"`
+	Command   string    `json:"command"`
+	Saved     time.Time `json:"saved"`
+	Data      []byte    `json:"data"`
+}
+
+// checkpoints tracks checkpointing for a single run.
+type checkpoints struct {
+	lock     sync.Mutex
+	cfg      CheckpointConfig
+	enabled  bool
+	dir      string
+	interval time.Duration
+	command  string
+	path     string
+	saved    bool
+}
+
+// stateDir returns the directory for persistent application state, following the XDG base directory spec
+// ($XDG_STATE_HOME) where it applies, or within the app home when overridden (see SetupConfig.WithAppHome).
+func stateDir(appName string) (string, error) {
+	if home := appHome(appName); home != "" {
+		return filepath.Join(home, "state", appName), nil
+	}
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, appName), nil
+	}
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, appName, "state"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", appName), nil
+}
+
+// startCheckpoints determines the checkpoint for the command being run, which is keyed by the invocation signature
+// (see invocationSignature).
+func (a *application) startCheckpoints(cmd *cobra.Command, args []string) error {
+	c := &a.state.checkpoints
+	if !a.setupConfig.Checkpoints {
+		return nil
+	}
+
+	dir := a.setupConfig.CheckpointDir
+	if dir == "" {
+		sd, err := stateDir(a.setupConfig.ID.Name)
+		if err != nil {
+			return fmt.Errorf("unable to determine checkpoint dir: %w", err)
+		}
+		dir = filepath.Join(sd, "checkpoints")
+	}
+
+	interval := a.setupConfig.CheckpointInterval
+	if interval <= 0 {
+		interval = defaultCheckpointInterval
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.enabled = true
+	c.dir = dir
+	c.interval = interval
+	c.command = cmd.CommandPath()
+	c.path = filepath.Join(dir, a.checkpointSignature(cmd, args)+".json")
+	c.saved = false
+	return nil
+}
+
+// finishCheckpoints removes the checkpoint once the command completes successfully, otherwise the checkpoint is kept
+// so that the command can be resumed.
+func (a *application) finishCheckpoints(err error) {
+	c := &a.state.checkpoints
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.enabled || c.path == "" {
+		return
+	}
+	if err == nil {
+		if rmErr := os.Remove(c.path); rmErr != nil && !os.IsNotExist(rmErr) && a.state.Logger != nil {
+			a.state.Logger.Warnf("unable to remove checkpoint: %v", rmErr)
+		}
+		return
+	}
+	if c.saved && a.state.Logger != nil {
+		a.state.Logger.Infof("progress has been saved, run again with --resume to continue")
+	}
+}
+
+// checkpointSignature identifies the invocation of the command being run, with the configuration of the command (but
+// not the core configuration, such as logging, which does not change the result of the command).
+func (a *application) checkpointSignature(cmd *cobra.Command, args []string) string {
+	dir, _ := os.Getwd()
+
+	var cfgs []any
+	for _, cfg := range a.loadedConfigs {
+		if cfg == &a.state.Config || cfg == a.uiSections || cfg == a || cfg == &a.state.checkpoints.cfg {
+			continue
+		}
+		cfgs = append(cfgs, cfg)
+	}
+	return invocationSignature(cmd, dir, args, formatConfiguration(cfgs...))
+}
+
+// invocationSignature identifies a command invocation by the command path, working directory, arguments (with paths
+// to existing files made absolute), the flags that were set (excluding --resume), and the effective configuration.
+func invocationSignature(cmd *cobra.Command, dir string, args []string, config string) string {
+	parts := []string{cmd.CommandPath(), dir}
+	for _, arg := range args {
+		parts = append(parts, absolutePathArg(dir, arg))
+	}
+
+	var flags []string
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if f.Name == "resume" {
+			return
+		}
+		flags = append(flags, f.Name+"="+f.Value.String())
+	})
+	sort.Strings(flags)
+	parts = append(parts, flags...)
+
+	configSum := sha256.Sum256([]byte(config))
+	parts = append(parts, hex.EncodeToString(configSum[:]))
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// absolutePathArg returns the absolute path when the argument is the relative path to an existing file (relative to
+// the given dir), otherwise the argument as-is.
+func absolutePathArg(dir, arg string) string {
+	if arg == "" || filepath.IsAbs(arg) {
+		return arg
+	}
+	path := filepath.Join(dir, arg)
+	if _, err := os.Stat(path); err != nil {
+		return arg
+	}
+	return path
+}
+
+// Resume restores the progress from the checkpoint of a previous run of the same command (with the same working
+// directory, arguments, flags, and configuration), returning true when progress was restored. Progress is only restored when the user asks to resume
+// (--resume); otherwise the command starts over.
+func (s *State) Resume(v Checkpointable) (bool, error) {
+	c := &s.checkpoints
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.enabled {
+		return false, errors.New("checkpoints are not enabled (see SetupConfig.WithCheckpoints)")
+	}
+	if !c.cfg.Resume {
+		return false, nil
+	}
+
+	contents, err := os.ReadFile(c.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			if log := s.currentLogger(); log != nil {
+				log.Warn("no previous progress found to resume from, starting over")
+			}
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to read checkpoint: %w", err)
+	}
+
+	var f checkpointFile
+	if err := json.Unmarshal(contents, &f); err != nil {
+		return false, fmt.Errorf("unable to parse checkpoint %q: %w", c.path, err)
+	}
+	if f.Version != checkpointVersion {
+		return false, fmt.Errorf("unsupported checkpoint version %d (%s)", f.Version, c.path)
+	}
+
+	if err := v.UnmarshalCheckpoint(f.Data); err != nil {
+		return false, fmt.Errorf("unable to restore checkpoint: %w", err)
+	}
+
+	if log := s.currentLogger(); log != nil {
+		log.WithFields("saved", f.Saved.Format(time.RFC3339)).Info("resuming from previous progress")
+	}
+	return true, nil
+}
+
+// Checkpoint saves the current progress, which is kept until the command completes successfully.
+func (s *State) Checkpoint(v Checkpointable) error {
+	data, err := v.MarshalCheckpoint()
+	if err != nil {
+		return fmt.Errorf("unable to checkpoint: %w", err)
+	}
+
+	c := &s.checkpoints
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.enabled {
+		return errors.New("checkpoints are not enabled (see SetupConfig.WithCheckpoints)")
+	}
+
+	contents, err := json.Marshal(checkpointFile{
+		Version:   checkpointVersion,
+		Signature: strings.TrimSuffix(filepath.Base(c.path), ".json"),
+		Command:   c.command,
+		Saved:     time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to checkpoint: %w", err)
+	}
+
+	if !s.WritableDir(c.dir) {
+		return nil
+	}
+	fileMode, dirMode := s.currentConfig().Permissions.modes()
+	if err := mkdirAll(c.dir, dirMode); err != nil {
+		return fmt.Errorf("unable to create checkpoint dir: %w", err)
+	}
+
+	// write to a temporary file first so that an interrupt never leaves a partial checkpoint
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, contents, fileMode); err != nil {
+		return fmt.Errorf("unable to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("unable to write checkpoint: %w", err)
+	}
+
+	c.saved = true
+	return nil
+}
+
+// CheckpointPeriodically saves the progress at the configured interval (see SetupConfig.WithCheckpoints) until the
+// context is cancelled or the returned function is called, which saves the progress one last time.
+func (s *State) CheckpointPeriodically(ctx context.Context, v Checkpointable) func() {
+	s.checkpoints.lock.Lock()
+	interval := s.checkpoints.interval
+	s.checkpoints.lock.Unlock()
+	if interval <= 0 {
+		interval = defaultCheckpointInterval
+	}
+
+	save := func() {
+		if err := s.Checkpoint(v); err != nil {
+			if log := s.currentLogger(); log != nil {
+				log.Warnf("%v", err)
+			}
+		}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				save()
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-done
+			save()
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package clio
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type counterProgress struct {
+	lock sync.Mutex
+	next int
+}
+
+func (p *counterProgress) MarshalCheckpoint() ([]byte, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return []byte(strconv.Itoa(p.next)), nil
+}
+
+func (p *counterProgress) UnmarshalCheckpoint(data []byte) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	n, err := strconv.Atoi(string(data))
+	p.next = n
+	return err
+}
+
+func Test_Application_checkpoints(t *testing.T) {
+	dir := t.TempDir()
+
+	run := func(failAt int, args ...string) ([]int, error) {
+		cfg := NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithCheckpoints(0)
+		cfg.CheckpointDir = dir
+		app := New(*cfg)
+		root := app.SetupRootCommand(&cobra.Command{})
+
+		var processed []int
+		root.AddCommand(app.SetupCommand(&cobra.Command{
+			Use: "process",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				state := app.(*application).State()
+				progress := &counterProgress{}
+				if _, err := state.Resume(progress); err != nil {
+					return err
+				}
+				for progress.next < 5 {
+					if progress.next == failAt {
+						return fmt.Errorf("failed at %d", failAt)
+					}
+					processed = append(processed, progress.next)
+					progress.next++
+					if err := state.Checkpoint(progress); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		}))
+		root.SetArgs(append([]string{"process"}, args...))
+		return processed, root.Execute()
+	}
+
+	processed, err := run(3, "input")
+	require.ErrorContains(t, err, "failed at 3")
+	assert.Equal(t, []int{0, 1, 2}, processed)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	// a different invocation does not share progress
+	processed, err = run(-1, "other", "--resume")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, processed)
+
+	// without --resume the command starts over
+	processed, err = run(4, "input")
+	require.Error(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3}, processed)
+
+	processed, err = run(-1, "input", "--resume")
+	require.NoError(t, err)
+	assert.Equal(t, []int{4}, processed)
+
+	// the checkpoint is removed once the command succeeds
+	_, err = os.Stat(files[0])
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_State_CheckpointPeriodically(t *testing.T) {
+	s := &State{}
+	s.checkpoints.enabled = true
+	s.checkpoints.dir = t.TempDir()
+	s.checkpoints.path = filepath.Join(s.checkpoints.dir, "sig.json")
+	s.checkpoints.interval = 1
+
+	progress := &counterProgress{next: 7}
+	stop := s.CheckpointPeriodically(context.Background(), progress)
+	stop()
+	stop()
+
+	s.checkpoints.cfg.Resume = true
+	restored := &counterProgress{}
+	resumed, err := s.Resume(restored)
+	require.NoError(t, err)
+	assert.True(t, resumed)
+	assert.Equal(t, 7, restored.next)
+}
+
+func Test_invocationSignature(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{Use: "process"}
+		cmd.Flags().String("mode", "", "")
+		cmd.Flags().Bool("resume", false, "")
+		require.NoError(t, cmd.Flags().Parse(args))
+		return cmd
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "input"), nil, 0o600))
+	other := t.TempDir()
+
+	base := invocationSignature(newCmd(), dir, []string{"input"}, "level: 1")
+	assert.Equal(t, base, invocationSignature(newCmd("--resume"), dir, []string{"input"}, "level: 1"))
+	assert.NotEqual(t, base, invocationSignature(newCmd(), dir, []string{"other"}, "level: 1"))
+	assert.NotEqual(t, base, invocationSignature(newCmd("--mode", "fast"), dir, []string{"input"}, "level: 1"))
+	assert.NotEqual(t, base, invocationSignature(newCmd(), other, []string{"input"}, "level: 1"))
+	assert.NotEqual(t, base, invocationSignature(newCmd(), dir, []string{"input"}, "level: 2"))
+}
+
+func Test_absolutePathArg(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "input.txt"), nil, 0o600))
+
+	assert.Equal(t, filepath.Join(dir, "input.txt"), absolutePathArg(dir, "input.txt"))
+	assert.Equal(t, "missing.txt", absolutePathArg(dir, "missing.txt"))
+	assert.Equal(t, "fast", absolutePathArg(dir, "fast"))
+	assert.Equal(t, "", absolutePathArg(dir, ""))
+}
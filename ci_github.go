@@ -0,0 +1,179 @@
+package clio
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/boss-net/go-logger"
+)
+
+// annotationProperties are the log fields that are passed along as properties of GitHub Actions annotations
+// (e.g. log.WithFields("file", "go.mod", "line", 3).Warn("...")).
+var annotationProperties = []string{"title", "file", "line", "endLine", "col", "endColumn"}
+
+var _ logger.Logger = (*githubActionsLogger)(nil)
+
+// githubActionsLogger passes all log entries to the wrapped logger, and additionally emits warnings and errors as
+// GitHub Actions workflow commands so that they are shown as annotations on the workflow run.
+type githubActionsLogger struct {
+	log        logger.MessageLogger
+	out        io.Writer
+	lock       *sync.Mutex
+	properties map[string]string
+}
+
+// NewGitHubActionsLogger wraps the given logger, additionally writing warnings and errors to the given writer as
+// GitHub Actions workflow commands (::warning:: and ::error::). This is used automatically by the default logger
+// when running under GitHub Actions.
+func NewGitHubActionsLogger(log logger.Logger, out io.Writer) logger.Logger {
+	return &githubActionsLogger{
+		log:  log,
+		out:  out,
+		lock: &sync.Mutex{},
+	}
+}
+
+func (g *githubActionsLogger) Errorf(format string, args ...interface{}) {
+	g.log.Errorf(format, args...)
+	g.command("error", fmt.Sprintf(format, args...))
+}
+
+func (g *githubActionsLogger) Error(args ...interface{}) {
+	g.log.Error(args...)
+	g.command("error", fmt.Sprint(args...))
+}
+
+func (g *githubActionsLogger) Warnf(format string, args ...interface{}) {
+	g.log.Warnf(format, args...)
+	g.command("warning", fmt.Sprintf(format, args...))
+}
+
+func (g *githubActionsLogger) Warn(args ...interface{}) {
+	g.log.Warn(args...)
+	g.command("warning", fmt.Sprint(args...))
+}
+
+func (g *githubActionsLogger) Infof(format string, args ...interface{}) {
+	g.log.Infof(format, args...)
+}
+
+func (g *githubActionsLogger) Info(args ...interface{}) {
+	g.log.Info(args...)
+}
+
+func (g *githubActionsLogger) Debugf(format string, args ...interface{}) {
+	g.log.Debugf(format, args...)
+}
+
+func (g *githubActionsLogger) Debug(args ...interface{}) {
+	g.log.Debug(args...)
+}
+
+func (g *githubActionsLogger) Tracef(format string, args ...interface{}) {
+	g.log.Tracef(format, args...)
+}
+
+func (g *githubActionsLogger) Trace(args ...interface{}) {
+	g.log.Trace(args...)
+}
+
+func (g *githubActionsLogger) WithFields(fields ...interface{}) logger.MessageLogger {
+	next := g.with(fields)
+	if l, ok := g.log.(logger.FieldLogger); ok {
+		next.log = l.WithFields(fields...)
+	}
+	return next
+}
+
+func (g *githubActionsLogger) Nested(fields ...interface{}) logger.Logger {
+	next := g.with(fields)
+	if l, ok := g.log.(logger.NestedLogger); ok {
+		next.log = l.Nested(fields...)
+	}
+	return next
+}
+
+func (g *githubActionsLogger) with(fields []interface{}) *githubActionsLogger {
+	properties := map[string]string{}
+	for k, v := range g.properties {
+		properties[k] = v
+	}
+	for k, v := range logFields(fields) {
+		if contains(annotationProperties, k) {
+			properties[k] = fmt.Sprint(v)
+		}
+	}
+	return &githubActionsLogger{
+		log:        g.log,
+		out:        g.out,
+		lock:       g.lock,
+		properties: properties,
+	}
+}
+
+func (g *githubActionsLogger) command(name, msg string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	_, _ = io.WriteString(g.out, githubCommand(name, g.properties, msg))
+}
+
+// githubCommand formats a workflow command, e.g. "::error file=app.go,line=1::something went wrong".
+func githubCommand(name string, properties map[string]string, msg string) string {
+	var props []string
+	for _, k := range annotationProperties {
+		if v, ok := properties[k]; ok {
+			props = append(props, k+"="+githubEscapeProperty(v))
+		}
+	}
+	cmd := name
+	if len(props) > 0 {
+		cmd += " " + strings.Join(props, ",")
+	}
+	return fmt.Sprintf("::%s::%s\n", cmd, githubEscapeData(msg))
+}
+
+var (
+	githubDataEscaper     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	githubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+func githubEscapeData(s string) string {
+	return githubDataEscaper.Replace(s)
+}
+
+func githubEscapeProperty(s string) string {
+	return githubPropertyEscaper.Replace(s)
+}
+
+// githubActionsGroup starts a collapsible group in the GitHub Actions log, returning a function to end the group.
+func githubActionsGroup(out io.Writer, title string) func() {
+	_, _ = fmt.Fprintf(out, "::group::%s\n", githubEscapeData(title))
+	return func() {
+		_, _ = io.WriteString(out, "::endgroup::\n")
+	}
+}
+
+// logFields converts the fields given to WithFields or Nested (either key-value pairs or logger.Fields) to a map.
+func logFields(fields []interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	for i := 0; i < len(fields); i++ {
+		switch f := fields[i].(type) {
+		case logger.Fields:
+			for k, v := range f {
+				out[k] = v
+			}
+		case map[string]interface{}:
+			for k, v := range f {
+				out[k] = v
+			}
+		default:
+			if i+1 < len(fields) {
+				out[fmt.Sprint(f)] = fields[i+1]
+				i++
+			}
+		}
+	}
+	return out
+}
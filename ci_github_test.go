@@ -0,0 +1,93 @@
+package clio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/boss-net/go-logger"
+	"github.com/boss-net/go-logger/adapter/discard"
+)
+
+func Test_githubActionsLogger(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(l logger.Logger)
+		want string
+	}{
+		{
+			name: "warning",
+			log: func(l logger.Logger) {
+				l.Warn("something ", "happened")
+			},
+			want: "::warning::something happened\n",
+		},
+		{
+			name: "error with format",
+			log: func(l logger.Logger) {
+				l.Errorf("failed: %d", 3)
+			},
+			want: "::error::failed: 3\n",
+		},
+		{
+			name: "info and debug are not annotations",
+			log: func(l logger.Logger) {
+				l.Info("info")
+				l.Debugf("debug %s", "entry")
+				l.Trace("trace")
+			},
+		},
+		{
+			name: "escaped message",
+			log: func(l logger.Logger) {
+				l.Warn("100% done\nnext line")
+			},
+			want: "::warning::100%25 done%0Anext line\n",
+		},
+		{
+			name: "properties from fields",
+			log: func(l logger.Logger) {
+				l.WithFields("file", "cmd/app: main.go", "line", 12, "other", "ignored").Error("bad")
+			},
+			want: "::error file=cmd/app%3A main.go,line=12::bad\n",
+		},
+		{
+			name: "properties from nested logger",
+			log: func(l logger.Logger) {
+				l.Nested(logger.Fields{"title": "Lint"}).WithFields("file", "a.go").Warn("style")
+			},
+			want: "::warning title=Lint,file=a.go::style\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			tt.log(NewGitHubActionsLogger(discard.New(), out))
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func Test_githubActionsGroup(t *testing.T) {
+	out := &bytes.Buffer{}
+	end := githubActionsGroup(out, "configuration")
+	out.WriteString("details\n")
+	end()
+	assert.Equal(t, "::group::configuration\ndetails\n::endgroup::\n", out.String())
+}
+
+func Test_DefaultLogger_githubActions(t *testing.T) {
+	original := ciProvider
+	ciProvider = func() string { return "github-actions" }
+	defer func() { ciProvider = original }()
+
+	l, err := DefaultLogger(Config{Log: &LoggingConfig{Level: logger.WarnLevel}}, nil)
+	assert.NoError(t, err)
+	assert.IsType(t, &githubActionsLogger{}, l)
+
+	l, err = DefaultLogger(Config{Log: &LoggingConfig{Level: logger.WarnLevel, Quiet: true}}, nil)
+	assert.NoError(t, err)
+	_, ok := l.(*githubActionsLogger)
+	assert.False(t, ok, "annotations should not be emitted when quiet")
+}
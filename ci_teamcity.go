@@ -0,0 +1,56 @@
+package clio
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// teamCityTimestampFormat is the timestamp format required by TeamCity service messages.
+const teamCityTimestampFormat = "2006-01-02T15:04:05.000-0700"
+
+var _ logrus.Formatter = (*teamCityFormatter)(nil)
+
+// teamCityFormatter formats log entries as TeamCity service messages, so that warnings and errors are highlighted
+// in the build log.
+type teamCityFormatter struct{}
+
+func (f teamCityFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	status := "NORMAL"
+	switch entry.Level {
+	case logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel:
+		status = "ERROR"
+	case logrus.WarnLevel:
+		status = "WARNING"
+	}
+
+	text := entry.Message
+	if len(entry.Data) > 0 {
+		var keys []string
+		for k := range entry.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			text += fmt.Sprintf(" %s=%v", k, entry.Data[k])
+		}
+	}
+
+	return []byte(fmt.Sprintf("##teamcity[message text='%s' status='%s' timestamp='%s']\n",
+		teamCityEscape(text), status, teamCityEscape(entry.Time.Format(teamCityTimestampFormat)))), nil
+}
+
+var teamCityEscaper = strings.NewReplacer(
+	"|", "||",
+	"'", "|'",
+	"\n", "|n",
+	"\r", "|r",
+	"[", "|[",
+	"]", "|]",
+)
+
+func teamCityEscape(s string) string {
+	return teamCityEscaper.Replace(s)
+}
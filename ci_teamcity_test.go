@@ -0,0 +1,76 @@
+package clio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_teamCityFormatter(t *testing.T) {
+	ts := time.Date(2023, 7, 1, 12, 30, 45, 123000000, time.UTC)
+
+	tests := []struct {
+		name  string
+		entry *logrus.Entry
+		want  string
+	}{
+		{
+			name:  "info",
+			entry: &logrus.Entry{Time: ts, Level: logrus.InfoLevel, Message: "starting"},
+			want:  "##teamcity[message text='starting' status='NORMAL' timestamp='2023-07-01T12:30:45.123+0000']\n",
+		},
+		{
+			name:  "warning with fields",
+			entry: &logrus.Entry{Time: ts, Level: logrus.WarnLevel, Message: "slow", Data: logrus.Fields{"b": 2, "a": "x"}},
+			want:  "##teamcity[message text='slow a=x b=2' status='WARNING' timestamp='2023-07-01T12:30:45.123+0000']\n",
+		},
+		{
+			name:  "escaped error",
+			entry: &logrus.Entry{Time: ts, Level: logrus.ErrorLevel, Message: "can't read [file]\n|done"},
+			want:  "##teamcity[message text='can|'t read |[file|]|n||done' status='ERROR' timestamp='2023-07-01T12:30:45.123+0000']\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := teamCityFormatter{}.Format(tt.entry)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestLoggingConfig_format(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		ci     string
+		want   string
+	}{
+		{name: "no CI", want: LogFormatText},
+		{name: "github actions", ci: "github-actions", want: LogFormatGitHubActions},
+		{name: "teamcity", ci: "teamcity", want: LogFormatTeamCity},
+		{name: "jenkins", ci: "jenkins", want: LogFormatJenkins},
+		{name: "other CI", ci: "gitlab", want: LogFormatPlain},
+		{name: "explicit auto", format: LogFormatAuto, ci: "teamcity", want: LogFormatTeamCity},
+		{name: "explicit format", format: LogFormatTeamCity, want: LogFormatTeamCity},
+		{name: "explicit format in CI", format: LogFormatText, ci: "jenkins", want: LogFormatText},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := ciProvider
+			ciProvider = func() string { return tt.ci }
+			defer func() { ciProvider = original }()
+
+			cfg := &LoggingConfig{Format: tt.format}
+			assert.Equal(t, tt.want, cfg.format())
+		})
+	}
+}
+
+func TestLoggingConfig_PostLoad_format(t *testing.T) {
+	require.NoError(t, (&LoggingConfig{Format: LogFormatJenkins}).PostLoad())
+	require.Error(t, (&LoggingConfig{Format: "xml"}).PostLoad())
+}
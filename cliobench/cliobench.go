@@ -0,0 +1,205 @@
+// Package cliobench synthesizes event storms, workers, and UIs to measure the throughput and latency of the clio
+// eventloop (the path dispatching bus events to the UI of each command), so that changes to it can be checked for
+// performance regressions against the baseline benchmarks of this package (see "make benchmark").
+package cliobench
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wagoodman/go-partybus"
+
+	"github.com/boss-net/clio"
+	"github.com/boss-net/clio/internal/eventloop"
+	"github.com/boss-net/go-logger/adapter/discard"
+)
+
+// StormEvent is the type of the events published by a storm, numbered by the event type index (e.g. "storm-0").
+const StormEvent partybus.EventType = "storm"
+
+// Storm describes a burst of events published to the bus while a command runs.
+type Storm struct {
+	Events     int           // the total number of events published
+	Publishers int           // the number of goroutines publishing concurrently (1 when not positive)
+	Types      int           // the number of distinct event types published round-robin (1 when not positive)
+	Payload    int           // the number of bytes carried by each event
+	HandleCost time.Duration // the time the UI spends handling each event (e.g. redrawing)
+}
+
+// DefaultStorm is a storm representative of a busy command (e.g. progress updates from several concurrent tasks).
+var DefaultStorm = Storm{Events: 10000, Publishers: 8, Types: 4, Payload: 64}
+
+// Stamp is the value of each event published by a storm, recording when it was published.
+type Stamp struct {
+	Seq       int
+	Published time.Time
+	Payload   []byte
+}
+
+// Result is the outcome of running a storm through the eventloop.
+type Result struct {
+	Events    int             // the number of events handled by the UI
+	Elapsed   time.Duration   // from the first event published until the eventloop returned
+	Latencies []time.Duration // from publishing each event until the UI handled it, sorted
+}
+
+// Throughput is the number of events handled per second.
+func (r Result) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Events) / r.Elapsed.Seconds()
+}
+
+// Percentile returns the latency within which the given percentage of events (0-100) were handled.
+func (r Result) Percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(r.Latencies)-1) * p / 100)
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(r.Latencies) {
+		i = len(r.Latencies) - 1
+	}
+	return r.Latencies[i]
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%d events in %s (%.0f events/s, p50 %s, p99 %s)",
+		r.Events, r.Elapsed, r.Throughput(), r.Percentile(50), r.Percentile(99))
+}
+
+// normalized returns the storm with defaults applied.
+func (s Storm) normalized() Storm {
+	if s.Publishers <= 0 {
+		s.Publishers = 1
+	}
+	if s.Types <= 0 {
+		s.Types = 1
+	}
+	if s.Events < 0 {
+		s.Events = 0
+	}
+	return s
+}
+
+// Publish publishes the events of the storm from concurrent publishers, returning once all are published.
+func Publish(pub partybus.Publisher, storm Storm) {
+	storm = storm.normalized()
+	types := make([]partybus.EventType, storm.Types)
+	for i := range types {
+		types[i] = partybus.EventType(fmt.Sprintf("%s-%d", StormEvent, i))
+	}
+	payload := make([]byte, storm.Payload)
+
+	var wg sync.WaitGroup
+	for p := 0; p < storm.Publishers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for seq := p; seq < storm.Events; seq += storm.Publishers {
+				pub.Publish(partybus.Event{
+					Type:   types[seq%len(types)],
+					Source: p,
+					Value:  Stamp{Seq: seq, Published: time.Now(), Payload: payload},
+				})
+			}
+		}(p)
+	}
+	wg.Wait()
+}
+
+// Worker returns the errors channel of a fake command which publishes the storm, then completes (closing the
+// channel).
+func Worker(pub partybus.Publisher, storm Storm) <-chan error {
+	errs := make(chan error)
+	go func() {
+		defer close(errs)
+		Publish(pub, storm)
+	}()
+	return errs
+}
+
+var _ clio.UI = (*UI)(nil)
+
+// UI is a fake UI which records the latency of each storm event, spending the handling cost of the storm on each,
+// and unsubscribes once all events of the storm are handled (so that the eventloop completes).
+type UI struct {
+	expected     int
+	cost         time.Duration
+	subscription partybus.Unsubscribable
+	latencies    []time.Duration
+}
+
+// NewUI returns a UI expecting the events of the given storm.
+func NewUI(storm Storm) *UI {
+	storm = storm.normalized()
+	return &UI{
+		expected:  storm.Events,
+		cost:      storm.HandleCost,
+		latencies: make([]time.Duration, 0, storm.Events),
+	}
+}
+
+func (u *UI) Setup(subscription partybus.Unsubscribable) error {
+	u.subscription = subscription
+	if u.expected == 0 {
+		return subscription.Unsubscribe()
+	}
+	return nil
+}
+
+func (u *UI) Handle(e partybus.Event) error {
+	stamp, ok := e.Value.(Stamp)
+	if !ok {
+		return nil
+	}
+	if u.cost > 0 {
+		spin(u.cost)
+	}
+	u.latencies = append(u.latencies, time.Since(stamp.Published))
+	if len(u.latencies) == u.expected {
+		return u.subscription.Unsubscribe()
+	}
+	return nil
+}
+
+func (u *UI) Teardown(_ bool) error {
+	return nil
+}
+
+// Latencies returns the latency of each event handled, sorted.
+func (u *UI) Latencies() []time.Duration {
+	latencies := append([]time.Duration(nil), u.latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies
+}
+
+// spin busy-waits for the given duration, which (unlike sleeping) models a UI doing work and is accurate for
+// durations shorter than the timer resolution.
+func spin(d time.Duration) {
+	for start := time.Now(); time.Since(start) < d; {
+	}
+}
+
+// Run runs the storm through the eventloop, with a worker publishing the events to a UI (see Worker and UI).
+func Run(ctx context.Context, storm Storm) (Result, error) {
+	bus := partybus.NewBus()
+	defer bus.Close()
+	subscription := bus.Subscribe()
+	ui := NewUI(storm)
+
+	start := time.Now()
+	err := eventloop.Run(ctx, discard.New(), subscription, Worker(bus, storm), ui)
+	result := Result{
+		Elapsed:   time.Since(start),
+		Latencies: ui.Latencies(),
+	}
+	result.Events = len(result.Latencies)
+	return result, err
+}
@@ -0,0 +1,94 @@
+package cliobench
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Run(t *testing.T) {
+	result, err := Run(context.Background(), Storm{Events: 1000, Publishers: 4, Types: 3, Payload: 8})
+	require.NoError(t, err)
+	assert.Equal(t, 1000, result.Events)
+	assert.Len(t, result.Latencies, 1000)
+	assert.Positive(t, result.Throughput())
+	assert.LessOrEqual(t, result.Percentile(50), result.Percentile(99))
+
+	result, err = Run(context.Background(), Storm{})
+	require.NoError(t, err)
+	assert.Zero(t, result.Events)
+}
+
+func Test_Run_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = Run(ctx, Storm{Events: 1000, HandleCost: time.Millisecond})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the eventloop did not stop once cancelled")
+	}
+}
+
+func Test_Result_Percentile(t *testing.T) {
+	r := Result{Latencies: []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}}
+	assert.Equal(t, time.Duration(1), r.Percentile(0))
+	assert.Equal(t, time.Duration(5), r.Percentile(50))
+	assert.Equal(t, time.Duration(10), r.Percentile(100))
+	assert.Zero(t, Result{}.Percentile(50))
+}
+
+// the baseline benchmarks of the eventloop (see "make benchmark"), which report the events handled per second and the
+// p50/p99 latency from publishing each event until the UI handled it.
+
+func BenchmarkEventLoop(b *testing.B) {
+	for _, storm := range []Storm{
+		{Events: 10000, Publishers: 1, Types: 1, Payload: 64},
+		DefaultStorm,
+		{Events: 10000, Publishers: 64, Types: 16, Payload: 64},
+		{Events: 1000, Publishers: 8, Types: 4, Payload: 64, HandleCost: 10 * time.Microsecond},
+	} {
+		name := fmt.Sprintf("publishers=%d/types=%d/cost=%s", storm.Publishers, storm.Types, storm.HandleCost)
+		b.Run(name, func(b *testing.B) {
+			benchmark(b, storm)
+		})
+	}
+}
+
+func BenchmarkEventLoop_payload(b *testing.B) {
+	for _, payload := range []int{0, 1024, 64 * 1024} {
+		storm := DefaultStorm
+		storm.Payload = payload
+		b.Run(fmt.Sprintf("bytes=%d", payload), func(b *testing.B) {
+			benchmark(b, storm)
+		})
+	}
+}
+
+func benchmark(b *testing.B, storm Storm) {
+	b.ReportAllocs()
+	var events int
+	var elapsed, p50, p99 time.Duration
+	for i := 0; i < b.N; i++ {
+		result, err := Run(context.Background(), storm)
+		if err != nil {
+			b.Fatal(err)
+		}
+		events += result.Events
+		elapsed += result.Elapsed
+		p50 += result.Percentile(50)
+		p99 += result.Percentile(99)
+	}
+	b.ReportMetric(float64(events)/elapsed.Seconds(), "events/s")
+	b.ReportMetric(float64(p50.Nanoseconds())/float64(b.N), "p50-ns")
+	b.ReportMetric(float64(p99.Nanoseconds())/float64(b.N), "p99-ns")
+}
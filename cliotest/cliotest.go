@@ -0,0 +1,64 @@
+// Package cliotest runs the examples declared on the commands of a clio application (see
+// clio.Application.AddExamples) as smoke tests within go tests, so that the examples shown in the help keep working:
+//
+//	func Test_examples(t *testing.T) {
+//		cliotest.RunExamples(t, func() (clio.Application, *cobra.Command) {
+//			return cli.New()
+//		})
+//	}
+package cliotest
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/boss-net/clio"
+)
+
+// NewApp returns a new application along with its root command. A new application is needed for each example, since
+// flags keep the values given to the previous run.
+type NewApp func() (clio.Application, *cobra.Command)
+
+// RunExamples runs each example declared on the commands of the application in-process as a subtest, failing when it
+// exits with another exit code than expected (the output is shown on failure). Manual examples are skipped.
+func RunExamples(t *testing.T, newApp NewApp) {
+	t.Helper()
+	_, root := newApp()
+	examples := clio.AllExamples(root)
+	if len(examples) == 0 {
+		t.Skip("no examples are declared")
+	}
+
+	for _, e := range examples {
+		e := e
+		name := e.Description
+		if name == "" {
+			name = strings.Join(e.Args, " ")
+		}
+		t.Run(name, func(t *testing.T) {
+			if e.Manual {
+				t.Skip("manual example")
+			}
+			code, output := Run(newApp, e.Args...)
+			if code != e.ExitCode {
+				t.Errorf("%s exited with code %d (expected %d):\n%s", strings.Join(e.Args, " "), code, e.ExitCode, output)
+			}
+		})
+	}
+}
+
+// Run executes a new application with the given command line (after the application name), returning the exit code
+// and the output (stdout and stderr combined).
+func Run(newApp NewApp, args ...string) (int, string) {
+	app, root := newApp()
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetErr(&out)
+	root.SetArgs(args)
+	code := app.Execute(context.Background())
+	return code, out.String()
+}
@@ -0,0 +1,50 @@
+package cliotest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/boss-net/clio"
+)
+
+func newApp() (clio.Application, *cobra.Command) {
+	app := clio.New(*clio.NewSetupConfig(clio.Identification{Name: "app"}).WithNoBus())
+	root := app.SetupRootCommand(&cobra.Command{})
+
+	var name string
+	greet := &cobra.Command{
+		Use: "greet",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if name == "" {
+				return &clio.ExitError{Err: fmt.Errorf("nobody to greet"), Code: 2}
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "hello %s\n", name)
+			return nil
+		},
+	}
+	greet.Flags().StringVar(&name, "name", "", "")
+	app.AddExamples(greet,
+		clio.Example{Description: "greet the world", Args: []string{"greet", "--name", "world"}},
+		clio.Example{Description: "fail without a name", Args: []string{"greet"}, ExitCode: 2},
+		clio.Example{Description: "greet a remote host", Args: []string{"greet", "--remote"}, Manual: true},
+	)
+	root.AddCommand(app.SetupCommand(greet))
+	return app, root
+}
+
+func Test_RunExamples(t *testing.T) {
+	RunExamples(t, newApp)
+}
+
+func Test_Run(t *testing.T) {
+	code, out := Run(newApp, "greet", "--name", "world")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "hello world\n", out)
+
+	code, out = Run(newApp, "greet")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, out, "nobody to greet")
+}
@@ -0,0 +1,115 @@
+package clio
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+// cobraMessagePattern matches the messages that cobra and pflag print directly to the command output (as opposed to
+// returning as errors), such as deprecation notices for commands and flags.
+var cobraMessagePattern = regexp.MustCompile(`^(Command ".*" is deprecated, |Flag --\S+ has been deprecated, |Flag shorthand -\S+ has been deprecated, )`)
+
+// cobraMessages wraps the output of the root command, capturing messages from cobra so they can be shown through the
+// application logger (formatted and redacted like all other log entries). All other output (e.g. help) passes
+// through to the wrapped output.
+type cobraMessages struct {
+	lock    sync.Mutex
+	out     io.Writer // the wrapped output, or nil for stdout
+	pending []string
+}
+
+var _ io.Writer = (*cobraMessages)(nil)
+
+// wrap captures the messages written to the output of the command, keeping the output given by the caller (if any)
+// for everything else.
+func (m *cobraMessages) wrap(cmd *cobra.Command) {
+	out := cmd.OutOrStdout()
+	if out == io.Writer(m) {
+		return
+	}
+	if out == io.Writer(os.Stdout) {
+		// note: stdout is not captured at construction time since it is common to swap it out (e.g. in tests)
+		out = nil
+	}
+
+	m.lock.Lock()
+	m.out = out
+	m.lock.Unlock()
+	cmd.SetOut(m)
+}
+
+// redirect replaces the wrapped output until the returned function is called (e.g. for a command run by the daemon on
+// behalf of another invocation).
+func (m *cobraMessages) redirect(out io.Writer) func() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	previous := m.out
+	m.out = out
+	return func() {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		m.out = previous
+	}
+}
+
+func (m *cobraMessages) Write(p []byte) (int, error) {
+	lines := strings.Split(strings.TrimRight(string(p), "\n"), "\n")
+	for _, line := range lines {
+		if !cobraMessagePattern.MatchString(line) {
+			return m.output().Write(p)
+		}
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.pending = append(m.pending, lines...)
+	return len(p), nil
+}
+
+func (m *cobraMessages) output() io.Writer {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.out == nil {
+		return os.Stdout
+	}
+	return m.out
+}
+
+func (m *cobraMessages) take() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	pending := m.pending
+	m.pending = nil
+	return pending
+}
+
+// reportCobraMessages shows any captured cobra messages through the logger, falling back to stderr when the
+// logger has not been set up (e.g. the configuration could not be loaded).
+func (a *application) reportCobraMessages() {
+	for _, msg := range a.cobraMessages.take() {
+		if a.state.Config.Strict.enabled() {
+			// using deprecated commands and flags fails the run in strict mode
+			a.state.Warn(DeprecatedWarning, msg, nil)
+		}
+		if a.state.Logger != nil {
+			a.state.Logger.Warn(msg)
+			continue
+		}
+		if a.state.RedactStore != nil {
+			msg = a.state.RedactStore.RedactString(msg)
+		}
+		fmt.Fprintln(os.Stderr, color.Yellow.Sprint(msg))
+	}
+}
+
+// flagError adds a usage hint to errors from parsing flags, since usage is not shown on errors.
+func flagError(cmd *cobra.Command, err error) error {
+	return fmt.Errorf("%w (see '%s --help' for usage)", err, cmd.CommandPath())
+}
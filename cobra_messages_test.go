@@ -0,0 +1,145 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/go-logger"
+	"github.com/boss-net/go-logger/adapter/discard"
+	"github.com/boss-net/go-logger/adapter/redact"
+)
+
+var _ logger.Logger = (*warnRecorder)(nil)
+
+type warnRecorder struct {
+	logger.Logger
+	warnings  []string
+	warningsf []string
+}
+
+func (w *warnRecorder) Warn(args ...interface{}) {
+	w.warnings = append(w.warnings, fmt.Sprint(args...))
+}
+
+func (w *warnRecorder) Warnf(format string, args ...interface{}) {
+	w.warningsf = append(w.warningsf, fmt.Sprintf(format, args...))
+}
+
+func (w *warnRecorder) WithFields(...interface{}) logger.MessageLogger {
+	return w
+}
+
+func Test_cobraMessages_routedToLogger(t *testing.T) {
+	rec := &warnRecorder{Logger: discard.New()}
+
+	cfg := NewSetupConfig(Identification{Name: "app", Version: "1.0"}).
+		WithLoggerConstructor(func(_ Config, _ redact.Store) (logger.Logger, error) {
+			return rec, nil
+		})
+
+	app := New(*cfg)
+	root := app.SetupRootCommand(&cobra.Command{})
+
+	var legacy string
+	old := &cobra.Command{
+		Use:        "old",
+		Deprecated: "use 'new' instead",
+		Run:        func(cmd *cobra.Command, args []string) {},
+	}
+	old.Flags().StringVar(&legacy, "legacy", "", "a legacy flag")
+	require.NoError(t, old.Flags().MarkDeprecated("legacy", "it does nothing"))
+
+	root.AddCommand(app.SetupCommand(old))
+	root.SetArgs([]string{"old", "--legacy", "value"})
+
+	stdout, _ := captureStd(func() {
+		require.NoError(t, root.Execute())
+	})
+
+	assert.Empty(t, stdout)
+	assert.Equal(t, []string{
+		`Command "old" is deprecated, use 'new' instead`,
+		"Flag --legacy has been deprecated, it does nothing",
+	}, rec.warnings)
+}
+
+func Test_cobraMessages_passThrough(t *testing.T) {
+	m := &cobraMessages{}
+
+	stdout, _ := captureStd(func() {
+		_, err := m.Write([]byte("Usage:\n  app [flags]\n"))
+		require.NoError(t, err)
+	})
+
+	assert.Equal(t, "Usage:\n  app [flags]\n", stdout)
+	assert.Empty(t, m.take())
+}
+
+func Test_cobraMessages_callerOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		execute func(app Application, root *cobra.Command, out io.Writer)
+	}{
+		{
+			name: "set before setup",
+			execute: func(app Application, root *cobra.Command, _ io.Writer) {
+				require.NoError(t, root.Execute())
+			},
+		},
+		{
+			name: "set after setup",
+			execute: func(app Application, root *cobra.Command, out io.Writer) {
+				root.SetOut(out)
+				assert.Equal(t, 0, app.Execute(context.Background()))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &warnRecorder{Logger: discard.New()}
+			app := New(*NewSetupConfig(Identification{Name: "app", Version: "1.0"}).
+				WithNoBus().
+				WithLoggerConstructor(func(_ Config, _ redact.Store) (logger.Logger, error) {
+					return rec, nil
+				}))
+
+			out := &bytes.Buffer{}
+			root := &cobra.Command{}
+			root.SetOut(out)
+			root = app.SetupRootCommand(root)
+			root.AddCommand(app.SetupCommand(&cobra.Command{
+				Use:        "old",
+				Deprecated: "use 'new' instead",
+				Run: func(cmd *cobra.Command, args []string) {
+					cmd.Println("output")
+				},
+			}))
+			root.SetArgs([]string{"old"})
+
+			tt.execute(app, root, out)
+
+			assert.Equal(t, "output\n", out.String())
+			assert.Equal(t, []string{`Command "old" is deprecated, use 'new' instead`}, rec.warnings)
+		})
+	}
+}
+
+func Test_flagError(t *testing.T) {
+	cfg := NewSetupConfig(Identification{Name: "app", Version: "1.0"})
+	app := New(*cfg)
+	root := app.SetupRootCommand(&cobra.Command{
+		Run: func(cmd *cobra.Command, args []string) {},
+	})
+	root.SetArgs([]string{"--bogus"})
+
+	err := root.Execute()
+	require.Error(t, err)
+	assert.Equal(t, "unknown flag: --bogus (see 'app --help' for usage)", err.Error())
+}
@@ -0,0 +1,216 @@
+package clio
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const defaultFindLimit = 10
+
+// commandMatch is a command found by the "find" command, along with why it matched.
+type commandMatch struct {
+	cmd   *cobra.Command
+	score float64
+	flags []string // the flags that matched the query
+}
+
+// how much a match within each part of a command counts towards its score
+const (
+	findNameWeight  = 3
+	findFlagWeight  = 2
+	findShortWeight = 1.5
+	findLongWeight  = 1
+)
+
+// setupFindCommand adds the "find" command, which fuzzy-searches the names, aliases, flags, and help text of all
+// commands.
+func (a *application) setupFindCommand() {
+	limit := defaultFindLimit
+
+	find := &cobra.Command{
+		Use:   "find QUERY...",
+		Short: "find commands by name, flag, or description",
+		Long: "Find commands by name, alias, flag, or description. Every word of the query must match (allowing for " +
+			"typos and abbreviations), and the best matches are shown first.",
+		Args: cobra.MinimumNArgs(1),
+		// note: this is not run through the application infrastructure since no app config is required
+		RunE: func(cmd *cobra.Command, args []string) error {
+			matches := findCommands(cmd.Root(), cmd, args)
+			if len(matches) == 0 {
+				return fmt.Errorf("no commands match %q", strings.Join(args, " "))
+			}
+			if limit > 0 && len(matches) > limit {
+				matches = matches[:limit]
+			}
+			writeCommandMatches(cmd, matches)
+			return nil
+		},
+	}
+	find.Flags().IntVarP(&limit, "limit", "n", limit, "the maximum number of commands to show (0 shows all)")
+
+	a.root.AddCommand(find)
+}
+
+// findCommands returns the available commands (other than the excluded command) matching every term of the query,
+// best matches first.
+func findCommands(root, exclude *cobra.Command, query []string) []commandMatch {
+	var terms []string
+	for _, q := range query {
+		terms = append(terms, strings.Fields(strings.ToLower(q))...)
+	}
+
+	var matches []commandMatch
+	var visit func(cmd *cobra.Command)
+	visit = func(cmd *cobra.Command) {
+		for _, sub := range cmd.Commands() {
+			if !sub.IsAvailableCommand() || sub == exclude {
+				continue
+			}
+			if m, ok := matchCommand(sub, terms); ok {
+				matches = append(matches, m)
+			}
+			visit(sub)
+		}
+	}
+	visit(root)
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].cmd.CommandPath() < matches[j].cmd.CommandPath()
+	})
+	return matches
+}
+
+func matchCommand(cmd *cobra.Command, terms []string) (commandMatch, bool) {
+	m := commandMatch{cmd: cmd}
+	matchedFlags := map[string]bool{}
+
+	for _, term := range terms {
+		best := 0.0
+		for _, name := range append([]string{cmd.Name()}, cmd.Aliases...) {
+			best = maxScore(best, findNameWeight*fuzzyScore(term, name))
+		}
+		best = maxScore(best, findShortWeight*fuzzyScore(term, cmd.Short))
+		best = maxScore(best, findLongWeight*fuzzyScore(term, cmd.Long))
+
+		cmd.NonInheritedFlags().VisitAll(func(flag *pflag.Flag) {
+			if flag.Hidden {
+				return
+			}
+			score := findFlagWeight * fuzzyScore(term, flag.Name)
+			if score > 0 {
+				matchedFlags[flag.Name] = true
+			}
+			best = maxScore(best, score)
+		})
+
+		if best == 0 {
+			return m, false
+		}
+		m.score += best
+	}
+
+	for name := range matchedFlags {
+		m.flags = append(m.flags, "--"+name)
+	}
+	sort.Strings(m.flags)
+	return m, true
+}
+
+func maxScore(a, b float64) float64 {
+	if b > a {
+		return b
+	}
+	return a
+}
+
+// fuzzyScore returns how well the term matches the text (0 is no match, 1 is an exact match). Terms match whole words
+// best, then word prefixes, then anywhere within the text, then as an abbreviation (the letters in order) or with a
+// typo.
+func fuzzyScore(term, text string) float64 {
+	text = strings.ToLower(text)
+	if term == "" || text == "" {
+		return 0
+	}
+	if term == text {
+		return 1
+	}
+
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	best := 0.0
+	for _, word := range words {
+		switch {
+		case word == term:
+			best = maxScore(best, 0.9)
+		case strings.HasPrefix(word, term):
+			best = maxScore(best, 0.7)
+		case isTypo(term, word):
+			best = maxScore(best, 0.5)
+		}
+	}
+	if best > 0 {
+		return best
+	}
+
+	if strings.Contains(text, term) {
+		return 0.4
+	}
+	if len(term) >= 2 && len(text) <= 40 && isSubsequence(term, text) {
+		// abbreviations are only considered for short text (names), since long text contains most letters
+		return 0.2
+	}
+	return 0
+}
+
+// isTypo indicates the term is likely a misspelling of the word (allowing more edits for longer words).
+func isTypo(term, word string) bool {
+	allowed := 0
+	switch {
+	case len(term) >= 7:
+		allowed = 2
+	case len(term) >= 4:
+		allowed = 1
+	}
+	return allowed > 0 && levenshtein(term, word) <= allowed
+}
+
+func isSubsequence(term, text string) bool {
+	t := []rune(term)
+	i := 0
+	for _, r := range text {
+		if i < len(t) && r == t[i] {
+			i++
+		}
+	}
+	return i == len(t)
+}
+
+func writeCommandMatches(cmd *cobra.Command, matches []commandMatch) {
+	width := 0
+	for _, m := range matches {
+		if n := len(m.cmd.CommandPath()); n > width {
+			width = n
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	for i, m := range matches {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "%-*s   %s\n", width, m.cmd.CommandPath(), m.cmd.Short)
+		fmt.Fprintf(out, "  usage: %s\n", m.cmd.UseLine())
+		if len(m.flags) > 0 {
+			fmt.Fprintf(out, "  flags: %s\n", strings.Join(m.flags, ", "))
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package clio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_fuzzyScore(t *testing.T) {
+	tests := []struct {
+		term string
+		text string
+		want float64
+	}{
+		{term: "pull", text: "pull", want: 1},
+		{term: "pull", text: "pull an image", want: 0.9},
+		{term: "img", text: "show images", want: 0.2},
+		{term: "ima", text: "show images", want: 0.7},
+		{term: "imagr", text: "image", want: 0.5},
+		{term: "platfrom", text: "platform", want: 0.5},
+		{term: "mag", text: "image", want: 0.4},
+		{term: "pr", text: "prune", want: 0.7},
+		{term: "ls", text: "list", want: 0.2},
+		{term: "xyz", text: "list", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.term+"/"+tt.text, func(t *testing.T) {
+			assert.Equal(t, tt.want, fuzzyScore(tt.term, tt.text))
+		})
+	}
+}
+
+func Test_Application_findCommand(t *testing.T) {
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithFindCommand())
+	root := app.SetupRootCommand(&cobra.Command{})
+
+	noop := func(*cobra.Command, []string) error { return nil }
+	image := &cobra.Command{Use: "image", Short: "manage images"}
+	pull := &cobra.Command{Use: "pull IMAGE", Short: "pull an image from a registry", RunE: noop}
+	pull.Flags().String("platform", "", "the platform to pull")
+	list := &cobra.Command{Use: "list", Aliases: []string{"ls"}, Short: "list local images", RunE: noop}
+	image.AddCommand(pull, list)
+	login := &cobra.Command{Use: "login", Short: "authenticate with a registry", RunE: noop}
+	hidden := &cobra.Command{Use: "debug-image", Short: "internal", Hidden: true, RunE: noop}
+	root.AddCommand(image, login, hidden)
+
+	run := func(args ...string) (string, error) {
+		out := &bytes.Buffer{}
+		root.SetOut(out)
+		root.SetArgs(append([]string{"find"}, args...))
+		err := root.Execute()
+		return out.String(), err
+	}
+
+	out, err := run("pull", "image")
+	require.NoError(t, err)
+	assert.Equal(t, "app image pull   pull an image from a registry\n  usage: app image pull IMAGE [flags]\n", out)
+
+	out, err = run("registry")
+	require.NoError(t, err)
+	assert.Equal(t, `app image pull   pull an image from a registry
+  usage: app image pull IMAGE [flags]
+
+app login        authenticate with a registry
+  usage: app login
+`, out)
+
+	out, err = run("platfrom")
+	require.NoError(t, err)
+	assert.Contains(t, out, "app image pull")
+	assert.Contains(t, out, "  flags: --platform\n")
+
+	out, err = run("ls")
+	require.NoError(t, err)
+	assert.Contains(t, out, "app image list")
+
+	out, err = run("-n", "1", "image")
+	require.NoError(t, err)
+	assert.Equal(t, "app image   manage images\n  usage: app image\n", out)
+
+	_, err = run("debug")
+	require.EqualError(t, err, `no commands match "debug"`)
+}
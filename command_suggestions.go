@@ -0,0 +1,190 @@
+package clio
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// PluginCatalog provides the plugins known to the application, which are considered when suggesting commands for an
+// unknown command.
+type PluginCatalog interface {
+	// InstalledPlugins returns the command names of all plugins that are installed.
+	InstalledPlugins() []string
+	// AvailablePlugins returns the command names of all plugins that can be installed (installed or not).
+	AvailablePlugins() []string
+}
+
+const defaultPluginInstallCommand = "plugin install"
+
+type commandSuggestion struct {
+	name    string
+	install string // the command to run to install the plugin, if the suggestion is not installed
+}
+
+func (s commandSuggestion) String() string {
+	if s.install == "" {
+		return s.name
+	}
+	return fmt.Sprintf("%s (not installed, run `%s`)", s.name, s.install)
+}
+
+// setupUnknownCommands replaces cobra's unknown command handling for the root command (when enabled, see
+// SetupConfig.WithCommandSuggestions), so that suggestions also consider command aliases and plugins. Cobra only checks
+// for unknown commands when the root command has no Args validation, so the same condition applies here.
+func (a *application) setupUnknownCommands(root *cobra.Command) {
+	if !a.setupConfig.CommandSuggestions || root.Args != nil {
+		return
+	}
+
+	root.Args = func(cmd *cobra.Command, args []string) error {
+		if !cmd.HasSubCommands() || cmd.HasParent() {
+			return nil
+		}
+		if len(args) > 0 {
+			return a.unknownCommandError(cmd, args[0])
+		}
+		if a.rootShowsHelp {
+			return pflag.ErrHelp
+		}
+		return nil
+	}
+
+	if root.Run == nil && root.RunE == nil {
+		// the root command must be runnable for the args to be validated, but otherwise shows help (as cobra does for
+		// commands that are not runnable) before any setup is done.
+		a.rootShowsHelp = true
+		root.RunE = func(_ *cobra.Command, _ []string) error {
+			return pflag.ErrHelp
+		}
+	}
+}
+
+func (a *application) unknownCommandError(cmd *cobra.Command, name string) error {
+	msg := fmt.Sprintf("unknown command %q for %q", name, cmd.CommandPath())
+
+	if install := a.pluginInstallCommand(cmd, name); install != "" {
+		return fmt.Errorf("%s\n\n%q is a plugin that is not installed, run `%s` to install it", msg, name, install)
+	}
+
+	if suggestions := a.commandSuggestions(cmd, name); len(suggestions) > 0 {
+		msg += "\n\nDid you mean this?\n"
+		for _, s := range suggestions {
+			msg += fmt.Sprintf("\t%s\n", s)
+		}
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// pluginInstallCommand returns the full command to install the named plugin, if it is available but not installed.
+func (a *application) pluginInstallCommand(cmd *cobra.Command, name string) string {
+	catalog := a.setupConfig.PluginCatalog
+	if catalog == nil {
+		return ""
+	}
+	if contains(catalog.InstalledPlugins(), name) || !contains(catalog.AvailablePlugins(), name) {
+		return ""
+	}
+	install := a.setupConfig.PluginInstallCommand
+	if install == "" {
+		install = defaultPluginInstallCommand
+	}
+	return fmt.Sprintf("%s %s %s", cmd.Root().CommandPath(), install, name)
+}
+
+// commandSuggestions returns the commands (by name, alias, or SuggestFor entry) and plugins similar to the given name.
+func (a *application) commandSuggestions(cmd *cobra.Command, typed string) []commandSuggestion {
+	if cmd.DisableSuggestions {
+		return nil
+	}
+
+	distance := cmd.SuggestionsMinimumDistance
+	if distance <= 0 {
+		distance = 2
+	}
+
+	similar := func(candidate string) bool {
+		return levenshtein(strings.ToLower(typed), strings.ToLower(candidate)) <= distance ||
+			strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(typed))
+	}
+
+	seen := map[string]bool{}
+	var suggestions []commandSuggestion
+	add := func(s commandSuggestion) {
+		if seen[s.name] {
+			return
+		}
+		seen[s.name] = true
+		suggestions = append(suggestions, s)
+	}
+
+	for _, sub := range cmd.Commands() {
+		if !sub.IsAvailableCommand() {
+			continue
+		}
+		if similar(sub.Name()) || contains(sub.SuggestFor, typed) {
+			add(commandSuggestion{name: sub.Name()})
+			continue
+		}
+		for _, alias := range sub.Aliases {
+			if similar(alias) {
+				add(commandSuggestion{name: sub.Name()})
+				break
+			}
+		}
+	}
+
+	if catalog := a.setupConfig.PluginCatalog; catalog != nil {
+		installed := catalog.InstalledPlugins()
+		for _, name := range installed {
+			if similar(name) {
+				add(commandSuggestion{name: name})
+			}
+		}
+		for _, name := range catalog.AvailablePlugins() {
+			if !contains(installed, name) && similar(name) {
+				add(commandSuggestion{name: name, install: a.pluginInstallCommand(cmd, name)})
+			}
+		}
+	}
+
+	// installed commands are suggested before plugins that need to be installed, otherwise by name
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if (suggestions[i].install == "") != (suggestions[j].install == "") {
+			return suggestions[i].install == ""
+		}
+		return suggestions[i].name < suggestions[j].name
+	})
+
+	return suggestions
+}
+
+func levenshtein(s, t string) int {
+	a, b := []rune(s), []rune(t)
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,142 @@
+package clio
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticPlugins struct {
+	installed []string
+	available []string
+}
+
+func (p staticPlugins) InstalledPlugins() []string {
+	return p.installed
+}
+
+func (p staticPlugins) AvailablePlugins() []string {
+	return p.available
+}
+
+func Test_Application_unknownCommand(t *testing.T) {
+	plugins := staticPlugins{
+		installed: []string{"github"},
+		available: []string{"github", "gitlab", "bitbucket"},
+	}
+
+	tests := []struct {
+		name    string
+		cfg     *SetupConfig
+		args    []string
+		wantErr string
+	}{
+		{
+			name: "suggest command by name",
+			cfg:  NewSetupConfig(Identification{Name: "app"}).WithCommandSuggestions(),
+			args: []string{"scna"},
+			wantErr: `unknown command "scna" for "app"
+
+Did you mean this?
+	scan
+`,
+		},
+		{
+			name: "suggest command by alias",
+			cfg:  NewSetupConfig(Identification{Name: "app"}).WithCommandSuggestions(),
+			args: []string{"isnpect"},
+			wantErr: `unknown command "isnpect" for "app"
+
+Did you mean this?
+	show
+`,
+		},
+		{
+			name:    "no suggestions",
+			cfg:     NewSetupConfig(Identification{Name: "app"}).WithCommandSuggestions(),
+			args:    []string{"something"},
+			wantErr: `unknown command "something" for "app"`,
+		},
+		{
+			name: "suggest plugins",
+			cfg:  NewSetupConfig(Identification{Name: "app"}).WithPluginCatalog(plugins, ""),
+			args: []string{"gitlub"},
+			wantErr: `unknown command "gitlub" for "app"
+
+Did you mean this?
+	github
+	gitlab (not installed, run ` + "`app plugin install gitlab`" + `)
+`,
+		},
+		{
+			name: "known plugin that is not installed",
+			cfg:  NewSetupConfig(Identification{Name: "app"}).WithPluginCatalog(plugins, "plugins add"),
+			args: []string{"bitbucket"},
+			wantErr: `unknown command "bitbucket" for "app"
+
+"bitbucket" is a plugin that is not installed, run ` + "`app plugins add bitbucket`" + ` to install it`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := New(*tt.cfg)
+			root := app.SetupRootCommand(&cobra.Command{})
+			root.AddCommand(
+				app.SetupCommand(&cobra.Command{Use: "scan", Run: func(cmd *cobra.Command, args []string) {}}),
+				app.SetupCommand(&cobra.Command{Use: "show", Aliases: []string{"inspect"}, Run: func(cmd *cobra.Command, args []string) {}}),
+			)
+
+			root.SetArgs(tt.args)
+			err := root.Execute()
+			require.Error(t, err)
+			assert.Equal(t, tt.wantErr, err.Error())
+		})
+	}
+}
+
+func Test_Application_rootShowsHelp(t *testing.T) {
+	for _, cfg := range []*SetupConfig{
+		NewSetupConfig(Identification{Name: "app"}),
+		NewSetupConfig(Identification{Name: "app"}).WithCommandSuggestions(),
+	} {
+		app := New(*cfg)
+		root := app.SetupRootCommand(&cobra.Command{})
+		root.AddCommand(app.SetupCommand(&cobra.Command{Use: "scan", Run: func(cmd *cobra.Command, args []string) {}}))
+		assert.Equal(t, cfg.CommandSuggestions, root.Runnable(), "the root command is only made runnable for suggestions")
+
+		var helped bool
+		root.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+			helped = true
+		})
+
+		_, code := executeTestApp(app, root)
+		assert.Equal(t, 0, code)
+		assert.True(t, helped)
+		// setup is not done just to show help
+		assert.Nil(t, app.(*application).state.Logger)
+
+		out, code := executeTestApp(app, root, "isnpect")
+		assert.Equal(t, ExitCodeError, code)
+		assert.Contains(t, out, `unknown command "isnpect" for "app"`)
+	}
+}
+
+func Test_Application_unknownCommand_disabled(t *testing.T) {
+	app := New(*NewSetupConfig(Identification{Name: "app"}))
+	root := app.SetupRootCommand(&cobra.Command{})
+	root.AddCommand(app.SetupCommand(&cobra.Command{Use: "show", Aliases: []string{"inspect"}, Run: func(cmd *cobra.Command, args []string) {}}))
+
+	_, _, err := runTestApp(root, "", "isnpect")
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "Did you mean this?", "cobra does not suggest commands by alias")
+	assert.Nil(t, root.RunE)
+}
+
+func Test_levenshtein(t *testing.T) {
+	assert.Equal(t, 0, levenshtein("scan", "scan"))
+	assert.Equal(t, 2, levenshtein("scna", "scan"))
+	assert.Equal(t, 1, levenshtein("scan", "scans"))
+	assert.Equal(t, 4, levenshtein("", "scan"))
+}
@@ -0,0 +1,206 @@
+package clio
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// CompletionFieldsDescriber can be implemented by config structs in order to describe how values of their fields are
+// completed in the shell (for the flags bound to the fields). Without a description, completion is inferred from the
+// field: enum fields (see EnumFieldsDescriber) complete their allowed values, fields named like files or directories
+// (e.g. "--config-file" or "--cache-dir") complete paths, and other non-string fields (numbers, durations, sizes, and
+// URLs) do not complete files.
+type CompletionFieldsDescriber interface {
+	DescribeCompletionFields(set CompletionFieldSet)
+}
+
+// CompletionFieldSet accepts pointers to config fields along with how their values are completed.
+type CompletionFieldSet interface {
+	// Values suggests the given values (unlike enum fields, other values are still allowed).
+	Values(ptr any, values ...string)
+	// Files completes file paths, limited to the given extensions (without the ".") when any are given.
+	Files(ptr any, extensions ...string)
+	// Dirs completes directory paths.
+	Dirs(ptr any)
+	// None completes nothing (the shell does not fall back to completing file paths).
+	None(ptr any)
+}
+
+type cobraCompletionFunc func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+type fieldCompletionKind int
+
+const (
+	completeNone fieldCompletionKind = iota
+	completeValues
+	completeFiles
+	completeDirs
+)
+
+type fieldCompletion struct {
+	kind   fieldCompletionKind
+	values []string // the values (or file extensions) to complete
+}
+
+func (c fieldCompletion) complete(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	switch c.kind {
+	case completeValues:
+		return c.values, cobra.ShellCompDirectiveNoFileComp
+	case completeFiles:
+		if len(c.values) > 0 {
+			return c.values, cobra.ShellCompDirectiveFilterFileExt
+		}
+		return nil, cobra.ShellCompDirectiveDefault
+	case completeDirs:
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	default:
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+type completionFieldSet map[uintptr]fieldCompletion
+
+var _ CompletionFieldSet = (completionFieldSet)(nil)
+
+func (s completionFieldSet) add(ptr any, c fieldCompletion) {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("completion fields require a pointer, but got: %#v", ptr))
+	}
+	s[v.Pointer()] = c
+}
+
+func (s completionFieldSet) Values(ptr any, values ...string) {
+	s.add(ptr, fieldCompletion{kind: completeValues, values: values})
+}
+
+func (s completionFieldSet) Files(ptr any, extensions ...string) {
+	s.add(ptr, fieldCompletion{kind: completeFiles, values: extensions})
+}
+
+func (s completionFieldSet) Dirs(ptr any) {
+	s.add(ptr, fieldCompletion{kind: completeDirs})
+}
+
+func (s completionFieldSet) None(ptr any) {
+	s.add(ptr, fieldCompletion{kind: completeNone})
+}
+
+// addFlagCompletions registers shell completion for the flags bound to config fields (and enum flags), based on the
+// config metadata.
+func (a *application) addFlagCompletions(cmd *cobra.Command, flags *pflag.FlagSet, cfgs ...any) {
+	enums := a.collectEnumFields(cfgs...)
+	described := completionFieldSet{}
+	fields := map[uintptr]configField{}
+	for _, cfg := range cfgs {
+		visitConfigFields(a.configTagName(), reflect.ValueOf(cfg), nil, func(obj any) {
+			if d, ok := obj.(CompletionFieldsDescriber); ok {
+				d.DescribeCompletionFields(described)
+			}
+		}, func(ptr uintptr, f configField) {
+			fields[ptr] = f
+		})
+	}
+
+	flags.VisitAll(func(flag *pflag.Flag) {
+		ref := flagRef(flag)
+
+		var c fieldCompletion
+		switch {
+		case isAllowedValuesFlag(flag):
+			c = fieldCompletion{kind: completeValues, values: flag.Value.(allowedValuesFlag).allowedValues()}
+		case hasKey(enums, ref):
+			c = fieldCompletion{kind: completeValues, values: enums[ref]}
+		case hasKey(described, ref):
+			c = described[ref]
+		case hasKey(fields, ref):
+			var ok bool
+			if c, ok = inferCompletion(flag, fields[ref]); !ok {
+				return
+			}
+		default:
+			// not a flag created by clio
+			return
+		}
+
+		a.setFlagCompletion(cmd, flag, c.complete)
+	})
+}
+
+// setFlagCompletion sets the completion function for the flag. Completion is dispatched through the application so
+// that completion inferred from config metadata can later be replaced (see RegisterFlagCompletion).
+func (a *application) setFlagCompletion(cmd *cobra.Command, flag *pflag.Flag, fn cobraCompletionFunc) {
+	if a.flagCompletions == nil {
+		a.flagCompletions = make(map[*pflag.Flag]cobraCompletionFunc)
+	}
+	_, registered := a.flagCompletions[flag]
+	a.flagCompletions[flag] = fn
+	if registered {
+		return
+	}
+	// an error means a completion function was already registered directly with cobra, which is left in place
+	_ = cmd.RegisterFlagCompletionFunc(flag.Name, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return a.flagCompletions[flag](cmd, args, toComplete)
+	})
+}
+
+func isAllowedValuesFlag(flag *pflag.Flag) bool {
+	_, ok := flag.Value.(allowedValuesFlag)
+	return ok
+}
+
+func hasKey[K comparable, V any](m map[K]V, key K) bool {
+	_, ok := m[key]
+	return ok
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	byteSizeType = reflect.TypeOf(ByteSize(""))
+	urlType      = reflect.TypeOf(URL(""))
+)
+
+// inferCompletion determines how to complete the flag from the config field type and name, returning false when
+// there is nothing better than the shell default (completing file paths).
+func inferCompletion(flag *pflag.Flag, f configField) (fieldCompletion, bool) {
+	t := f.value.Type()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == durationType || t == byteSizeType || t == urlType:
+		return fieldCompletion{kind: completeNone}, true
+	case t.Kind() == reflect.Bool:
+		// boolean flags do not take a value
+		return fieldCompletion{}, false
+	case t.Kind() != reflect.String:
+		return fieldCompletion{kind: completeNone}, true
+	}
+
+	name := strings.ToLower(flag.Name)
+	switch {
+	case hasAnySuffix(name, "dir", "directory", "folder"):
+		return fieldCompletion{kind: completeDirs}, true
+	case hasAnySuffix(name, "file", "path"):
+		return fieldCompletion{kind: completeFiles}, true
+	}
+	return fieldCompletion{}, false
+}
+
+func hasAnySuffix(s string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package clio
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/boss-net/go-logger/adapter/discard"
+)
+
+const defaultCompletionTimeout = 2 * time.Second
+
+// CompletionFunc suggests values for the flag or argument being completed in the shell, where toComplete is the
+// partial value typed so far. The state is partially loaded: all configuration for the command is loaded (including
+// any flags given so far on the command line), but no UI, bus, or log output is set up. The context is cancelled once
+// the completion timeout elapses (see SetupConfig.WithCompletionTimeout), at which point no values are suggested.
+type CompletionFunc func(ctx context.Context, state *State, args []string, toComplete string) ([]string, error)
+
+// completionState is the configuration loaded (once) for shell completion.
+type completionState struct {
+	once sync.Once
+	err  error
+}
+
+// RegisterFlagCompletion registers a function to complete the values of the flag (replacing any completion inferred
+// from config metadata). This panics if the flag does not exist, the same as a missing flag binding would.
+func (a *application) RegisterFlagCompletion(cmd *cobra.Command, name string, fn CompletionFunc) {
+	flag := cmd.Flag(name)
+	if flag == nil {
+		panic(fmt.Sprintf("unable to register completion: flag %q does not exist on command %q", name, cmd.Name()))
+	}
+	a.setFlagCompletion(cmd, flag, a.dynamicCompletion(fn))
+}
+
+// RegisterArgsCompletion registers a function to complete the positional arguments of the command.
+func (a *application) RegisterArgsCompletion(cmd *cobra.Command, fn CompletionFunc) {
+	cmd.ValidArgsFunction = a.dynamicCompletion(fn)
+}
+
+func (a *application) dynamicCompletion(fn CompletionFunc) cobraCompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		timeout := a.setupConfig.CompletionTimeout
+		if timeout <= 0 {
+			timeout = defaultCompletionTimeout
+		}
+
+		ctx := cmd.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		type result struct {
+			values []string
+			err    error
+		}
+		// buffered so that a completion that finishes after the timeout does not block forever
+		results := make(chan result, 1)
+		go func() {
+			if err := a.loadCompletionState(cmd); err != nil {
+				results <- result{err: err}
+				return
+			}
+			values, err := fn(ctx, &a.state, args, toComplete)
+			results <- result{values: values, err: err}
+		}()
+
+		select {
+		case r := <-results:
+			if r.err != nil {
+				cobra.CompDebugln(fmt.Sprintf("unable to complete: %v", r.err), true)
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return r.values, cobra.ShellCompDirectiveNoFileComp
+		case <-ctx.Done():
+			cobra.CompDebugln(fmt.Sprintf("completion did not finish within %s", timeout), true)
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+	}
+}
+
+// loadCompletionState loads the configuration for the command being completed, without setting up any resources
+// (such as the UI or bus) that would write to the terminal.
+func (a *application) loadCompletionState(cmd *cobra.Command) error {
+	a.completion.once.Do(func() {
+		if a.state.Logger == nil {
+			a.state.Logger = discard.New()
+		}
+		if err := a.loadDotEnv(); err != nil {
+			a.completion.err = err
+			return
+		}
+		_, a.completion.err = a.loadConfigs(cmd, false, append(a.inheritedConfigs(cmd), a.commandConfigs[cmd]...)...)
+	})
+	return a.completion.err
+}
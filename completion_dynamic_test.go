@@ -0,0 +1,98 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/fangs"
+)
+
+type dynamicCompletionTestConfig struct {
+	Output   OutputConfig      `mapstructure:",squash"`
+	Region   string            `mapstructure:"region"`
+	Profiles map[string]string `mapstructure:"profiles"`
+}
+
+var _ fangs.FlagAdder = (*dynamicCompletionTestConfig)(nil)
+
+func (c *dynamicCompletionTestConfig) AddFlags(flags fangs.FlagSet) {
+	flags.StringVarP(&c.Region, "region", "", "")
+}
+
+func Test_Application_dynamicCompletion(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("region: us\nprofiles:\n  dev: a\n  prod: b\n"), 0o600))
+
+	cfg := NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithCompletionTimeout(50 * time.Millisecond)
+	cfg.FangsConfig.File = file
+	app := New(*cfg)
+
+	root := app.SetupRootCommand(&cobra.Command{RunE: func(*cobra.Command, []string) error { return nil }})
+	useCfg := &dynamicCompletionTestConfig{Output: OutputConfig{Format: "json"}}
+	use := app.SetupCommand(&cobra.Command{Use: "use", RunE: func(*cobra.Command, []string) error { return nil }}, useCfg)
+	root.AddCommand(use)
+
+	app.RegisterArgsCompletion(use, func(_ context.Context, state *State, args []string, toComplete string) ([]string, error) {
+		require.NotNil(t, state.Logger)
+		var out []string
+		for name := range useCfg.Profiles {
+			if strings.HasPrefix(name, toComplete) && !contains(args, name) {
+				out = append(out, useCfg.Region+"/"+name)
+			}
+		}
+		return out, nil
+	})
+	app.RegisterFlagCompletion(use, "output", func(context.Context, *State, []string, string) ([]string, error) {
+		return nil, errors.New("lookup failed")
+	})
+	app.RegisterFlagCompletion(use, "region", func(ctx context.Context, _ *State, _ []string, _ string) ([]string, error) {
+		<-ctx.Done()
+		return []string{"too-late"}, nil
+	})
+
+	assert.Panics(t, func() {
+		app.RegisterFlagCompletion(use, "missing", nil)
+	})
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "configuration (including flags given so far) is loaded",
+			args: []string{"__complete", "use", "--region", "eu", "pr"},
+			want: "eu/prod\n:4\n",
+		},
+		{
+			name: "errors result in no suggestions",
+			args: []string{"__complete", "use", "--output", ""},
+			want: ":4\n",
+		},
+		{
+			name: "slow completions are cut off",
+			args: []string{"__complete", "use", "--region", ""},
+			want: ":4\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app.(*application).completion = completionState{}
+			out := &bytes.Buffer{}
+			root.SetOut(out)
+			root.SetArgs(tt.args)
+			require.NoError(t, root.Execute())
+			assert.True(t, strings.HasPrefix(out.String(), tt.want), "got %q", out.String())
+		})
+	}
+}
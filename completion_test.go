@@ -0,0 +1,87 @@
+package clio
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/fangs"
+)
+
+type completionTestConfig struct {
+	Output     OutputConfig     `mapstructure:",squash"`
+	WorkingDir WorkingDirConfig `mapstructure:",squash"`
+	Profile    string           `mapstructure:"profile"`
+	Policy     string           `mapstructure:"policy"`
+	ConfigFile string           `mapstructure:"config-file"`
+	CacheDir   string           `mapstructure:"cache-dir"`
+	Name       string           `mapstructure:"name"`
+	Timeout    time.Duration    `mapstructure:"timeout"`
+	Retries    int              `mapstructure:"retries"`
+	Force      bool             `mapstructure:"force"`
+}
+
+var _ interface {
+	fangs.FlagAdder
+	CompletionFieldsDescriber
+} = (*completionTestConfig)(nil)
+
+func (c *completionTestConfig) AddFlags(flags fangs.FlagSet) {
+	flags.StringVarP(&c.Profile, "profile", "", "")
+	flags.StringVarP(&c.Policy, "policy", "", "")
+	flags.StringVarP(&c.ConfigFile, "config-file", "", "")
+	flags.StringVarP(&c.CacheDir, "cache-dir", "", "")
+	flags.StringVarP(&c.Name, "name", "", "")
+	flags.IntVarP(&c.Retries, "retries", "", "")
+	flags.BoolVarP(&c.Force, "force", "", "")
+}
+
+func (c *completionTestConfig) DescribeCompletionFields(set CompletionFieldSet) {
+	set.Values(&c.Profile, "dev", "prod")
+	set.Files(&c.Policy, "rego", "yaml")
+}
+
+func Test_Application_flagCompletions(t *testing.T) {
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus())
+
+	cfg := &completionTestConfig{Output: OutputConfig{Format: "json"}}
+	root := &cobra.Command{RunE: func(*cobra.Command, []string) error { return nil }}
+	root.Flags().DurationVar(&cfg.Timeout, "timeout", 0, "")
+	root.Flags().StringVar(new(string), "unrelated-file", "", "")
+	root = app.SetupRootCommand(root, cfg)
+	require.NoError(t, root.RegisterFlagCompletionFunc("unrelated-file", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return []string{"app-registered"}, cobra.ShellCompDirectiveNoFileComp
+	}))
+
+	tests := []struct {
+		flag string
+		want string
+	}{
+		{flag: "output", want: strings.Join(cfg.Output.Formats(), "\n") + "\n:4\n"},
+		{flag: "template", want: ":4\n"},
+		{flag: "diff", want: "json\n:8\n"},
+		{flag: "cwd", want: ":16\n"},
+		{flag: "profile", want: "dev\nprod\n:4\n"},
+		{flag: "policy", want: "rego\nyaml\n:8\n"},
+		{flag: "config-file", want: ":0\n"},
+		{flag: "cache-dir", want: ":16\n"},
+		{flag: "name", want: ":0\n"},
+		{flag: "timeout", want: ":4\n"},
+		{flag: "retries", want: ":4\n"},
+		{flag: "unrelated-file", want: "app-registered\n:4\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flag, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			root.SetOut(out)
+			root.SetArgs([]string{"__complete", "--" + tt.flag, ""})
+			require.NoError(t, root.Execute())
+			assert.True(t, strings.HasPrefix(out.String(), tt.want), "got %q", out.String())
+		})
+	}
+}
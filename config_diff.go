@@ -0,0 +1,142 @@
+package clio
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// setupConfigDiffCommand adds the "config diff" command, which shows the configuration values that differ from the
+// defaults, along with where each value came from.
+func (a *application) setupConfigDiffCommand() {
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "show the configuration values that differ from the defaults",
+		Long: "Show only the configuration values that differ from the defaults (from the config file, environment " +
+			"variables, or flags), along with the default and where each value came from. Secrets are redacted.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := a.writeConfigDiff(cmd, cmd.OutOrStdout()); err != nil {
+				return fmt.Errorf("unable to show configuration: %w", err)
+			}
+			return nil
+		},
+	}
+
+	a.configCommand().AddCommand(cmd)
+}
+
+// configDiffEntry is a config value that differs from the default.
+type configDiffEntry struct {
+	key      string
+	value    string
+	defaults string
+	source   string
+}
+
+func (a *application) writeConfigDiff(cmd *cobra.Command, w io.Writer) error {
+	uiSections, err := a.uiSectionsConfig()
+	if err != nil {
+		return err
+	}
+	cfgs := nonNil(append([]any{&a.state.Config, uiSections}, a.state.Config.FromCommands...)...)
+
+	// the configs hold the defaults until loaded
+	defaults := a.formatConfigFields(cfgs...)
+
+	if _, err := a.loadConfigs(cmd, false, a.state.Config.FromCommands...); err != nil {
+		return err
+	}
+	a.redactSecretConfigValues(cfgs...)
+
+	entries := a.configDiff(cmd, defaults, cfgs...)
+	if len(entries) == 0 {
+		_, _ = fmt.Fprintln(w, "no configuration values differ from the defaults")
+		return nil
+	}
+
+	var sb strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&sb, "%s: %s  # %s (default: %s)\n", e.key, e.value, e.source, e.defaults)
+	}
+	out := sb.String()
+	if a.state.RedactStore != nil {
+		out = a.state.RedactStore.RedactString(out)
+	}
+	_, err = io.WriteString(w, out)
+	return err
+}
+
+// configDiff returns the values that differ from the given defaults (keyed by config path), in config graph order.
+func (a *application) configDiff(cmd *cobra.Command, defaults map[string]string, cfgs ...any) []configDiffEntry {
+	flags := flagsByRef(cmd)
+	current := a.formatConfigFields(cfgs...)
+	seen := map[string]bool{}
+
+	var entries []configDiffEntry
+	for _, cfg := range cfgs {
+		visitConfigFields(a.configTagName(), reflect.ValueOf(cfg), nil, nil, func(ptr uintptr, f configField) {
+			key := strings.ToLower(strings.Join(f.path, "."))
+			if seen[key] {
+				return
+			}
+			seen[key] = true
+
+			def, ok := defaults[key]
+			if !ok {
+				// the value is within a section that is only allocated when loaded
+				def = "unset"
+			}
+			source := a.configFieldSource(flags, ptr, f.path)
+			if current[key] == def || source == "default" {
+				// note: values set when loading (e.g. by PostLoad) are derived from other values, not customized
+				return
+			}
+
+			entries = append(entries, configDiffEntry{
+				key:      key,
+				value:    current[key],
+				defaults: def,
+				source:   source,
+			})
+		})
+	}
+	return entries
+}
+
+// formatConfigFields returns each config value (in flow style yaml) keyed by config path.
+func (a *application) formatConfigFields(cfgs ...any) map[string]string {
+	values := map[string]string{}
+	for _, cfg := range cfgs {
+		visitConfigFields(a.configTagName(), reflect.ValueOf(cfg), nil, nil, func(_ uintptr, f configField) {
+			key := strings.ToLower(strings.Join(f.path, "."))
+			if _, ok := values[key]; !ok {
+				values[key] = formatConfigValue(f.value)
+			}
+		})
+	}
+	return values
+}
+
+func formatConfigValue(v reflect.Value) string {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		return formatWizardValue(v)
+	}
+
+	node := &yaml.Node{}
+	if err := node.Encode(v.Interface()); err != nil {
+		return fmt.Sprintf("%+v", v.Interface())
+	}
+	node.Style = yaml.FlowStyle
+
+	b, err := yaml.Marshal(node)
+	if err != nil {
+		return fmt.Sprintf("%+v", v.Interface())
+	}
+	return strings.TrimSpace(string(b))
+}
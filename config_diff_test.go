@@ -0,0 +1,53 @@
+package clio
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Application_configDiff(t *testing.T) {
+	t.Setenv("APP_WORKERS", "8")
+
+	configFile := filepath.Join(t.TempDir(), "app.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("registry:\n  password: hunter2\ntimeout: 1m\ntags: [a, b]\n"), 0o600))
+
+	cfg := NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithConfigDiffCommand()
+	cfg.FangsConfig.File = configFile
+	app := New(*cfg)
+
+	root := app.SetupRootCommand(&cobra.Command{})
+	root.AddCommand(app.SetupCommand(&cobra.Command{
+		Use:  "scan",
+		RunE: func(*cobra.Command, []string) error { return nil },
+	}, &wizardTestConfig{Workers: 2, Mode: "fast"}))
+
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetArgs([]string{"config", "diff"})
+	require.NoError(t, root.Execute())
+
+	assert.Equal(t, `registry.password: *******  # file `+configFile+` (default: "")
+workers: 8  # env APP_WORKERS (default: 2)
+timeout: 1m0s  # file `+configFile+` (default: 0s)
+tags: [a, b]  # file `+configFile+` (default: [])
+`, out.String())
+}
+
+func Test_Application_configDiff_noChanges(t *testing.T) {
+	cfg := NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithConfigDiffCommand()
+	app := New(*cfg)
+	root := app.SetupRootCommand(&cobra.Command{})
+
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetArgs([]string{"config", "diff"})
+	require.NoError(t, root.Execute())
+
+	assert.Equal(t, "no configuration values differ from the defaults\n", out.String())
+}
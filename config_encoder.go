@@ -0,0 +1,72 @@
+package clio
+
+import (
+	"reflect"
+)
+
+// ConfigEncoder is implemented by configs controlling how they appear in debug output (the configuration logged when
+// a command starts, bug reports, and the control API), e.g. returning a map of only the interesting (or redacted)
+// values of a complex config. The value returned is shown as yaml in place of the config (strings are shown as-is),
+// and a nil value hides the config.
+type ConfigEncoder interface {
+	EncodeConfig() (any, error)
+}
+
+// ConfigEncoderFunc encodes configs of a type for debug output (see SetupConfig.WithConfigEncoder), as a
+// ConfigEncoder does for its own type.
+type ConfigEncoderFunc func(cfg any) (any, error)
+
+// WithConfigEncoder controls how configs of the same type as the given config appear in debug output (see
+// ConfigEncoder), for config types which can't implement ConfigEncoder (e.g. those of other packages). Configs are
+// matched by their type, or the type they point to.
+func (c *SetupConfig) WithConfigEncoder(cfg any, fn ConfigEncoderFunc) *SetupConfig {
+	if c.ConfigEncoders == nil {
+		c.ConfigEncoders = make(map[reflect.Type]ConfigEncoderFunc)
+	}
+	c.ConfigEncoders[reflect.TypeOf(cfg)] = fn
+	return c
+}
+
+var _ ConfigEncoder = (*encodedConfig)(nil)
+
+// encodedConfig is a config encoded by a ConfigEncoderFunc.
+type encodedConfig struct {
+	value any
+	err   error
+}
+
+func (e encodedConfig) EncodeConfig() (any, error) {
+	return e.value, e.err
+}
+
+// encodeConfigs encodes the configs which have an encoder (see SetupConfig.WithConfigEncoder) for debug output (see
+// formatConfiguration).
+func (a *application) encodeConfigs(cfgs []any) []any {
+	if len(a.setupConfig.ConfigEncoders) == 0 {
+		return cfgs
+	}
+	encoded := make([]any, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		if fn := configEncoderFor(a.setupConfig.ConfigEncoders, cfg); fn != nil {
+			value, err := fn(cfg)
+			cfg = encodedConfig{value: value, err: err}
+		}
+		encoded = append(encoded, cfg)
+	}
+	return encoded
+}
+
+// configEncoderFor returns the encoder registered for the type of the config (or the type it points to).
+func configEncoderFor(encoders map[reflect.Type]ConfigEncoderFunc, cfg any) ConfigEncoderFunc {
+	t := reflect.TypeOf(cfg)
+	if t == nil {
+		return nil
+	}
+	if fn, ok := encoders[t]; ok {
+		return fn
+	}
+	if t.Kind() == reflect.Ptr {
+		return encoders[t.Elem()]
+	}
+	return nil
+}
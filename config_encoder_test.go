@@ -0,0 +1,57 @@
+package clio
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type redactedRegistryConfig struct {
+	URL      string `yaml:"url"`
+	Password string `yaml:"password"`
+}
+
+func (c redactedRegistryConfig) EncodeConfig() (any, error) {
+	return map[string]any{"registry": map[string]string{"url": c.URL, "password": "*****"}}, nil
+}
+
+type hiddenConfig struct {
+	Token string `yaml:"token"`
+}
+
+func (hiddenConfig) EncodeConfig() (any, error) {
+	return nil, nil
+}
+
+func Test_formatConfiguration_encoders(t *testing.T) {
+	got := formatConfiguration(
+		redactedRegistryConfig{URL: "registry.example.com", Password: "hunter2"},
+		hiddenConfig{Token: "secret"},
+		encodedConfig{value: "name: app"},
+		encodedConfig{err: errors.New("unable to encode")},
+	)
+	assert.Equal(t, "registry:\n    password: '*****'\n    url: registry.example.com\nname: app\nunable to encode\n", got)
+}
+
+func Test_application_encodeConfigs(t *testing.T) {
+	type external struct {
+		Host string `yaml:"host"`
+		Key  string `yaml:"key"`
+	}
+	type other struct {
+		Name string `yaml:"name"`
+	}
+
+	cfg := NewSetupConfig(Identification{Name: "app"}).
+		WithConfigEncoder(external{}, func(cfg any) (any, error) {
+			return map[string]string{"host": cfg.(*external).Host}, nil
+		})
+	a := &application{setupConfig: *cfg}
+
+	got := formatConfiguration(a.encodeConfigs([]any{&external{Host: "example.com", Key: "secret"}, &other{Name: "app"}})...)
+	assert.Equal(t, "host: example.com\nname: app\n", got)
+
+	// configs are shown as-is without encoders
+	assert.Equal(t, []any{1}, (&application{}).encodeConfigs([]any{1}))
+}
@@ -0,0 +1,188 @@
+package clio
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// EnumFieldsDescriber can be implemented by config structs in order to restrict fields to a set of allowed values.
+// Values are validated once all configuration has been loaded (from flags, environment variables, and config files),
+// and the allowed values are shown in the help and offered as shell completions for the flag bound to the field.
+type EnumFieldsDescriber interface {
+	DescribeEnumFields(set EnumFieldSet)
+}
+
+// EnumFieldSet accepts pointers to config fields along with their allowed values (see Enum for a typed alternative).
+type EnumFieldSet interface {
+	Add(ptr any, values ...string)
+}
+
+// EnumValues is a set of allowed values for a string-based config field or flag.
+type EnumValues[T ~string] struct {
+	values []T
+}
+
+// Enum returns the set of allowed values, for example:
+//
+//	clio.Enum[ColorMode]("auto", "always", "never").Describe(set, &c.Color)  // within DescribeEnumFields
+//	clio.Enum[ColorMode]("auto", "always", "never").Var(cmd.Flags(), &c.Color, "color", "", "when to use color")
+func Enum[T ~string](values ...T) *EnumValues[T] {
+	return &EnumValues[T]{values: values}
+}
+
+// Values returns the allowed values.
+func (e *EnumValues[T]) Values() []T {
+	return append([]T{}, e.values...)
+}
+
+// Validate returns an error if the value is not allowed (an empty value is considered unset, so is allowed).
+func (e *EnumValues[T]) Validate(value T) error {
+	return validateEnum(string(value), e.strings())
+}
+
+// Describe adds the config field to the set of enum fields (for use within EnumFieldsDescriber.DescribeEnumFields).
+func (e *EnumValues[T]) Describe(set EnumFieldSet, ptr *T) {
+	set.Add(ptr, e.strings()...)
+}
+
+// Var adds a flag bound to the given value which only accepts the allowed values.
+func (e *EnumValues[T]) Var(flags *pflag.FlagSet, ptr *T, name, shorthand, usage string) {
+	flags.VarP(&enumFlag[T]{value: ptr, allowed: e.strings()}, name, shorthand, usage+enumUsage(e.strings()))
+}
+
+func (e *EnumValues[T]) strings() []string {
+	var out []string
+	for _, v := range e.values {
+		out = append(out, string(v))
+	}
+	return out
+}
+
+// allowedValuesFlag is implemented by flag values that only accept a fixed set of values.
+type allowedValuesFlag interface {
+	allowedValues() []string
+}
+
+// enumFlag is a pflag.Value restricted to a set of values. The field is named "value" so that the flag is matched
+// to the config field it is bound to (see flagRef).
+type enumFlag[T ~string] struct {
+	value   *T
+	allowed []string
+}
+
+var _ pflag.Value = (*enumFlag[string])(nil)
+
+func (f *enumFlag[T]) Set(s string) error {
+	if err := validateEnum(s, f.allowed); err != nil {
+		return err
+	}
+	*f.value = T(s)
+	return nil
+}
+
+func (f *enumFlag[T]) String() string {
+	if f.value == nil {
+		return ""
+	}
+	return string(*f.value)
+}
+
+func (f *enumFlag[T]) Type() string {
+	return "string"
+}
+
+func (f *enumFlag[T]) allowedValues() []string {
+	return f.allowed
+}
+
+func validateEnum(value string, allowed []string) error {
+	if value == "" || contains(allowed, value) {
+		return nil
+	}
+	return fmt.Errorf("%q is not allowed (allowed values: %s)", value, strings.Join(allowed, ", "))
+}
+
+func enumUsage(allowed []string) string {
+	return fmt.Sprintf(" (one of: %s)", strings.Join(allowed, ", "))
+}
+
+type enumFieldSet map[uintptr][]string
+
+func (s enumFieldSet) Add(ptr any, values ...string) {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("Add() requires a pointer, but got: %#v", ptr))
+	}
+	s[v.Pointer()] = values
+}
+
+// collectEnumFields returns the allowed values of all enum fields described by the configs, keyed by field address.
+func (a *application) collectEnumFields(cfgs ...any) enumFieldSet {
+	set := enumFieldSet{}
+	for _, cfg := range cfgs {
+		visitConfigFields(a.configTagName(), reflect.ValueOf(cfg), nil, func(obj any) {
+			if d, ok := obj.(EnumFieldsDescriber); ok {
+				d.DescribeEnumFields(set)
+			}
+		}, func(uintptr, configField) {})
+	}
+	return set
+}
+
+// describeEnumFlags shows the allowed values in the usage of flags bound to enum fields.
+func (a *application) describeEnumFlags(flags *pflag.FlagSet, cfgs ...any) {
+	fields := a.collectEnumFields(cfgs...)
+	if len(fields) == 0 {
+		return
+	}
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if _, ok := flag.Value.(allowedValuesFlag); ok {
+			// already described when the flag was added
+			return
+		}
+		if values, ok := fields[flagRef(flag)]; ok {
+			flag.Usage += enumUsage(values)
+		}
+	})
+}
+
+// checkEnumFields validates the values of all enum fields (described by the configs or bound to enum flags).
+func (a *application) checkEnumFields(cmd *cobra.Command, cfgs ...any) error {
+	fields := a.collectEnumFields(cfgs...)
+	for ref, flag := range flagsByRef(cmd) {
+		if f, ok := flag.Value.(allowedValuesFlag); ok {
+			fields[ref] = f.allowedValues()
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	var errs []string
+	for _, cfg := range cfgs {
+		visitConfigFields(a.configTagName(), reflect.ValueOf(cfg), nil, nil, func(ptr uintptr, f configField) {
+			allowed, ok := fields[ptr]
+			if !ok {
+				return
+			}
+			v := f.value
+			for v.Kind() == reflect.Ptr && !v.IsNil() {
+				v = v.Elem()
+			}
+			if v.Kind() != reflect.String {
+				return
+			}
+			if err := validateEnum(v.String(), allowed); err != nil {
+				errs = append(errs, fmt.Sprintf("invalid value for %s: %v", strings.Join(f.path, "."), err))
+			}
+		})
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid application config: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package clio
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/fangs"
+)
+
+type colorMode string
+
+type enumTestConfig struct {
+	Color  colorMode `mapstructure:"color"`
+	Format string    `mapstructure:"format"`
+}
+
+var _ interface {
+	fangs.FlagAdder
+	EnumFieldsDescriber
+} = (*enumTestConfig)(nil)
+
+func (c *enumTestConfig) AddFlags(flags fangs.FlagSet) {
+	flags.StringVarP(&c.Format, "format", "o", "the output format")
+}
+
+func (c *enumTestConfig) DescribeEnumFields(set EnumFieldSet) {
+	set.Add(&c.Format, "table", "json")
+}
+
+func Test_EnumValues(t *testing.T) {
+	e := Enum[colorMode]("auto", "always", "never")
+	assert.Equal(t, []colorMode{"auto", "always", "never"}, e.Values())
+	assert.NoError(t, e.Validate("always"))
+	assert.NoError(t, e.Validate(""))
+	assert.EqualError(t, e.Validate("sometimes"), `"sometimes" is not allowed (allowed values: auto, always, never)`)
+}
+
+func Test_Application_enumFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		env     map[string]string
+		args    []string
+		want    enumTestConfig
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			want: enumTestConfig{Color: "auto", Format: "table"},
+		},
+		{
+			name:   "allowed values from all sources",
+			config: "format: json\n",
+			env:    map[string]string{"APP_COLOR": "never"},
+			want:   enumTestConfig{Color: "never", Format: "json"},
+		},
+		{
+			name: "allowed flag values",
+			args: []string{"--color", "always", "-o", "json"},
+			want: enumTestConfig{Color: "always", Format: "json"},
+		},
+		{
+			name:    "invalid enum flag values are rejected while parsing",
+			args:    []string{"--color", "sometimes"},
+			wantErr: `invalid argument "sometimes" for "--color" flag: "sometimes" is not allowed (allowed values: auto, always, never)`,
+		},
+		{
+			name:    "invalid described field flag values",
+			args:    []string{"--format", "xml"},
+			wantErr: `invalid value for format: "xml" is not allowed (allowed values: table, json)`,
+		},
+		{
+			name:    "invalid config values",
+			config:  "format: yaml\n",
+			env:     map[string]string{"APP_COLOR": "rainbow"},
+			wantErr: `invalid value for color: "rainbow" is not allowed (allowed values: auto, always, never); invalid value for format: "yaml"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			file := filepath.Join(t.TempDir(), "app.yaml")
+			require.NoError(t, os.WriteFile(file, []byte(tt.config), 0o600))
+
+			cfg := NewSetupConfig(Identification{Name: "app"}).WithNoBus()
+			cfg.FangsConfig.File = file
+			app := New(*cfg)
+
+			got := &enumTestConfig{Color: "auto", Format: "table"}
+			root := &cobra.Command{RunE: func(*cobra.Command, []string) error { return nil }}
+			Enum[colorMode]("auto", "always", "never").Var(root.Flags(), &got.Color, "color", "", "when to use color")
+			root = app.SetupRootCommand(root, got)
+
+			root.SetArgs(tt.args)
+			err := root.Execute()
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, *got)
+		})
+	}
+}
+
+func Test_Application_enumFlagHelpAndCompletion(t *testing.T) {
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus())
+
+	cfg := &enumTestConfig{Color: "auto", Format: "table"}
+	root := &cobra.Command{RunE: func(*cobra.Command, []string) error { return nil }}
+	Enum[colorMode]("auto", "always", "never").Var(root.Flags(), &cfg.Color, "color", "", "when to use color")
+	root = app.SetupRootCommand(root, cfg)
+
+	usage := root.Flags().FlagUsages()
+	assert.Contains(t, usage, "when to use color (one of: auto, always, never)")
+	assert.Contains(t, usage, "the output format (one of: table, json)")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"__complete", "--color", "a"}, want: "auto\nalways\nnever\n:4\n"},
+		{args: []string{"__complete", "--format", ""}, want: "table\njson\n:4\n"},
+	}
+	for _, tt := range tests {
+		out := &bytes.Buffer{}
+		root.SetOut(out)
+		root.SetArgs(tt.args)
+		require.NoError(t, root.Execute())
+		assert.Contains(t, out.String(), tt.want)
+	}
+}
@@ -0,0 +1,338 @@
+package clio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// configSnippetContext is the number of lines shown before and after the offending line of a config file.
+const configSnippetContext = 2
+
+// ConfigFileError is a problem with the syntax of a config file or with a value within it, which is shown along with
+// the offending lines of the file.
+type ConfigFileError struct {
+	File    string
+	Line    int    // 1-based (0 when unknown)
+	Column  int    // 1-based (0 when unknown)
+	Key     string // the config key with the invalid value (empty for syntax errors)
+	Message string
+
+	snippet string
+}
+
+func (e *ConfigFileError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("invalid config file ")
+	sb.WriteString(e.File)
+	if e.Line > 0 {
+		sb.WriteString(":" + strconv.Itoa(e.Line))
+		if e.Column > 0 {
+			sb.WriteString(":" + strconv.Itoa(e.Column))
+		}
+	}
+	sb.WriteString(": ")
+	if e.Key != "" {
+		sb.WriteString(e.Key + ": ")
+	}
+	sb.WriteString(e.Message)
+	if e.snippet != "" {
+		sb.WriteString("\n\n" + e.snippet)
+	}
+	return sb.String()
+}
+
+// ConfigFileErrors are all problems found with values within config files.
+type ConfigFileErrors []*ConfigFileError
+
+func (e ConfigFileErrors) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n\n")
+}
+
+var yamlErrorLinePattern = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// newConfigSyntaxError returns a ConfigFileError for a yaml (or json) syntax error.
+func newConfigSyntaxError(file string, contents []byte, err error) error {
+	if typeErr, ok := err.(*yaml.TypeError); ok && len(typeErr.Errors) > 0 {
+		err = fmt.Errorf("yaml: %s", typeErr.Errors[0])
+	}
+	m := yamlErrorLinePattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return &ConfigFileError{File: file, Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+	line, _ := strconv.Atoi(m[1])
+	return &ConfigFileError{
+		File:    file,
+		Line:    line,
+		Message: m[2],
+		snippet: configSnippet(contents, line, 0),
+	}
+}
+
+// newTOMLSyntaxError returns a ConfigFileError for a toml syntax error.
+func newTOMLSyntaxError(file string, contents []byte, err error) error {
+	var decodeErr *toml.DecodeError
+	if !errors.As(err, &decodeErr) {
+		return &ConfigFileError{File: file, Message: strings.TrimPrefix(err.Error(), "toml: ")}
+	}
+	line, column := decodeErr.Position()
+	return &ConfigFileError{
+		File:    file,
+		Line:    line,
+		Column:  column,
+		Message: strings.TrimPrefix(decodeErr.Error(), "toml: "),
+		snippet: configSnippet(contents, line, column),
+	}
+}
+
+// patterns for the errors from decoding config values, which are matched to the config key and expected type
+var configDecodeErrorPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^'(?P<key>[^']+)' expected type '(?P<expected>[^']+)', got unconvertible type '(?P<got>[^']+)'`),
+	regexp.MustCompile(`^cannot parse '(?P<key>[^']+)' as (?P<expected>\w+): (?P<reason>.*)$`),
+	regexp.MustCompile(`^'(?P<key>[^']+)' expected a map, got '(?P<got>[^']+)'`),
+	regexp.MustCompile(`^'(?P<key>[^']+)': source data must be an array or slice, got (?P<got>\w+)`),
+	regexp.MustCompile(`^error decoding '(?P<key>[^']*)': (?P<reason>.*)$`),
+}
+
+// configDecodeErrors converts the errors from decoding config values into ConfigFileErrors showing where each value is
+// within the config file it came from (see State.ConfigSource). Nil is returned when no problem is with a value from a
+// config file (e.g. the value came from an environment variable), in which case the original error is best.
+func (a *application) configDecodeErrors(err error) error {
+	var errs ConfigFileErrors
+	found := false
+	for _, line := range strings.Split(err.Error(), "\n") {
+		line = strings.TrimPrefix(strings.TrimSpace(line), "* ")
+		if line == "" || strings.HasSuffix(line, "error(s) decoding:") {
+			continue
+		}
+
+		e := &ConfigFileError{Message: line}
+		for _, pattern := range configDecodeErrorPatterns {
+			m := pattern.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			e.Key = m[pattern.SubexpIndex("key")]
+			e.Message = decodeErrorMessage(pattern, m)
+			break
+		}
+
+		if e.Key != "" {
+			if file := a.configSourceOf(e.Key); file != "" {
+				e.File = file
+				found = true
+				if contents, readErr := os.ReadFile(file); readErr == nil {
+					if strings.EqualFold(filepath.Ext(file), ".toml") {
+						e.Line, e.Column = findTOMLKey(contents, e.Key)
+					} else {
+						e.Line, e.Column = findConfigKey(contents, e.Key)
+					}
+					e.snippet = configSnippet(contents, e.Line, e.Column)
+				}
+			}
+		}
+		errs = append(errs, e)
+	}
+
+	if !found {
+		return nil
+	}
+	for _, e := range errs {
+		if e.File == "" {
+			// not from a config file (e.g. from an environment variable or flag)
+			e.File = "(not from a config file)"
+		}
+	}
+	return errs
+}
+
+// configSourceOf returns the config file that provided the value of the key, which may be a section (where the value
+// is not a leaf, so the source of any value within the section is used).
+func (a *application) configSourceOf(key string) string {
+	if file := a.state.ConfigSource(key); file != "" {
+		return file
+	}
+	prefix := strings.ToLower(key) + "."
+	for k, file := range a.state.configSources {
+		if strings.HasPrefix(k, prefix) {
+			return file
+		}
+	}
+	return ""
+}
+
+var parseValuePattern = regexp.MustCompile(`parsing "(.*)": `)
+
+func decodeErrorMessage(pattern *regexp.Regexp, m []string) string {
+	group := func(name string) string {
+		if i := pattern.SubexpIndex(name); i > 0 {
+			return m[i]
+		}
+		return ""
+	}
+	expected, got, reason := group("expected"), group("got"), group("reason")
+	switch {
+	case expected != "" && got != "":
+		return fmt.Sprintf("expected a value of type %s, but got %s", expected, describeDecodedType(got))
+	case expected != "":
+		if v := parseValuePattern.FindStringSubmatch(reason); v != nil {
+			return fmt.Sprintf("expected a value of type %s, but got %q", expected, v[1])
+		}
+		return fmt.Sprintf("expected a value of type %s (%s)", expected, reason)
+	case got != "" && strings.Contains(m[0], "expected a map"):
+		return fmt.Sprintf("expected a section (map) of values, but got %s", describeDecodedType(got))
+	case got != "":
+		return fmt.Sprintf("expected a list of values, but got %s", describeDecodedType(got))
+	default:
+		return reason
+	}
+}
+
+// describeDecodedType describes the (go) type of a decoded config value in terms of the config file.
+func describeDecodedType(t string) string {
+	switch {
+	case strings.HasPrefix(t, "map["):
+		return "a section (map)"
+	case strings.HasPrefix(t, "[]"):
+		return "a list"
+	case t == "string":
+		return "a string"
+	default:
+		return "a " + t
+	}
+}
+
+// findConfigKey returns the line and column of the value of the (dotted) config key within the config file, matching
+// keys case-insensitively (as config keys are). For multi-document files, the last document with the key is used
+// (since later documents take precedence). Zeros are returned when the key is not found.
+func findConfigKey(contents []byte, key string) (int, int) {
+	var docs []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			break
+		}
+		docs = append(docs, &doc)
+	}
+
+	for i := len(docs) - 1; i >= 0; i-- {
+		if len(docs[i].Content) == 0 {
+			continue
+		}
+		if node := findConfigNode(docs[i].Content[0], strings.Split(key, ".")); node != nil {
+			return node.Line, node.Column
+		}
+	}
+	return 0, 0
+}
+
+func findConfigNode(node *yaml.Node, path []string) *yaml.Node {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if len(path) == 0 {
+		return node
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k, v := node.Content[i], node.Content[i+1]
+		if strings.EqualFold(k.Value, path[0]) && k.Tag != "!!merge" {
+			return findConfigNode(v, path[1:])
+		}
+	}
+	// values may come from a merge key ("<<: *anchor" or "<<: [*a, *b]", where earlier maps take precedence)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Tag != "!!merge" {
+			continue
+		}
+		merged := []*yaml.Node{node.Content[i+1]}
+		if merged[0].Kind == yaml.SequenceNode {
+			merged = merged[0].Content
+		}
+		for _, m := range merged {
+			if found := findConfigNode(m, path); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	tomlTablePattern = regexp.MustCompile(`^\s*\[+\s*([^\]]+?)\s*\]+`)
+	tomlKeyPattern   = regexp.MustCompile(`^(\s*)([A-Za-z0-9_\-."' ]+?)\s*=\s*`)
+)
+
+// findTOMLKey returns the line and column of the value of the (dotted) config key within the toml file, supporting
+// tables and dotted keys. Zeros are returned when the key is not found.
+func findTOMLKey(contents []byte, key string) (int, int) {
+	key = strings.ToLower(key)
+	table := ""
+	for i, line := range strings.Split(string(contents), "\n") {
+		if m := tomlTablePattern.FindStringSubmatch(line); m != nil {
+			table = normalizeTOMLKey(m[1])
+			continue
+		}
+		m := tomlKeyPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		full := normalizeTOMLKey(m[2])
+		if table != "" {
+			full = table + "." + full
+		}
+		if full == key {
+			return i + 1, len(m[0]) + 1
+		}
+	}
+	return 0, 0
+}
+
+func normalizeTOMLKey(key string) string {
+	parts := strings.Split(key, ".")
+	for i, p := range parts {
+		parts[i] = strings.ToLower(strings.Trim(strings.TrimSpace(p), `"'`))
+	}
+	return strings.Join(parts, ".")
+}
+
+// configSnippet renders the lines of the config file around the given line, with a marker under the given column.
+func configSnippet(contents []byte, line, column int) string {
+	lines := strings.Split(strings.TrimRight(string(contents), "\n"), "\n")
+	if line <= 0 || line > len(lines) {
+		return ""
+	}
+
+	first, last := line-configSnippetContext, line+configSnippetContext
+	if first < 1 {
+		first = 1
+	}
+	if last > len(lines) {
+		last = len(lines)
+	}
+	width := len(strconv.Itoa(last))
+
+	var sb strings.Builder
+	for n := first; n <= last; n++ {
+		sb.WriteString(fmt.Sprintf("  %*d | %s\n", width, n, lines[n-1]))
+		if n == line && column > 0 {
+			sb.WriteString(fmt.Sprintf("  %*s | %s^\n", width, "", strings.Repeat(" ", column-1)))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
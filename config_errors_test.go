@@ -0,0 +1,127 @@
+package clio
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Application_configFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		config  string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:   "yaml syntax error",
+			file:   "app.yaml",
+			config: "registry:\n  timeout: 1\n bad: : x\n",
+			wantErr: `invalid config file {file}:2: did not find expected key
+
+  1 | registry:
+  2 |   timeout: 1
+  3 |  bad: : x`,
+		},
+		{
+			name:   "toml syntax error",
+			file:   "app.toml",
+			config: "[registry]\ntimeout = = 1\n",
+			wantErr: `invalid config file {file}:2:11: incomplete number
+
+  1 | [registry]
+  2 | timeout = = 1
+    |           ^`,
+		},
+		{
+			name:   "yaml values of the wrong type",
+			file:   "app.yaml",
+			config: "name: {a: b}\nregistry:\n  timeout: abc\n  url: https://example.com\n",
+			wantErr: `invalid config file {file}:1:7: name: expected a value of type string, but got a section (map)
+
+  1 | name: {a: b}
+    |       ^
+  2 | registry:
+  3 |   timeout: abc
+
+invalid config file {file}:3:12: registry.timeout: expected a value of type int, but got "abc"
+
+  1 | name: {a: b}
+  2 | registry:
+  3 |   timeout: abc
+    |            ^
+  4 |   url: https://example.com`,
+		},
+		{
+			name:   "toml values of the wrong type",
+			file:   "app.toml",
+			config: "name = \"x\"\n\n[registry]\nurl = [1, 2]\n",
+			wantErr: `invalid config file {file}:4:7: registry.url: expected a value of type string, but got a list
+
+  2 | 
+  3 | [registry]
+  4 | url = [1, 2]
+    |       ^`,
+		},
+		{
+			name:    "values not from a config file are reported as before",
+			file:    "app.yaml",
+			config:  "name: x\n",
+			env:     map[string]string{"APP_REGISTRY_TIMEOUT": "abc"},
+			wantErr: `invalid application config: 1 error(s) decoding:`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			file := filepath.Join(t.TempDir(), tt.file)
+			require.NoError(t, os.WriteFile(file, []byte(tt.config), 0o600))
+
+			cfg := NewSetupConfig(Identification{Name: "app"}).WithNoBus()
+			cfg.FangsConfig.File = file
+			app := New(*cfg)
+
+			root := app.SetupRootCommand(&cobra.Command{
+				RunE: func(*cobra.Command, []string) error { return nil },
+			}, &systemTestConfig{})
+
+			err := root.Execute()
+			require.Error(t, err)
+			want := strings.ReplaceAll(tt.wantErr, "{file}", file)
+			if strings.Contains(want, "\n") {
+				assert.Equal(t, want, err.Error())
+			} else {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
+
+func Test_findTOMLKey(t *testing.T) {
+	contents := []byte("name = \"x\"\nregistry.url = \"a\"\n\n[log]\n  Level = \"info\"\n[[items]]\nid = 1\n")
+	tests := []struct {
+		key      string
+		wantLine int
+		wantCol  int
+	}{
+		{key: "name", wantLine: 1, wantCol: 8},
+		{key: "registry.url", wantLine: 2, wantCol: 16},
+		{key: "log.level", wantLine: 5, wantCol: 11},
+		{key: "missing", wantLine: 0, wantCol: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			line, col := findTOMLKey(contents, tt.key)
+			assert.Equal(t, tt.wantLine, line)
+			assert.Equal(t, tt.wantCol, col)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package clio
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envReferencePattern matches "${VAR}" and "${VAR:-default}" references, as well as "$$" (an escaped "$").
+var envReferencePattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}`)
+
+// ConfigExpansion records the expansion of environment variable references within a config value (see
+// SetupConfig.WithEnvExpansion).
+type ConfigExpansion struct {
+	Value     any      // the value as written in the config file
+	Variables []string // the environment variables referenced
+}
+
+// ConfigExpansion returns how environment variable references were expanded within the value of the given config key
+// (e.g. "registry.url"), returning false when the value contained no references.
+func (s *State) ConfigExpansion(key string) (ConfigExpansion, bool) {
+	e, ok := s.configExpansions[strings.ToLower(key)]
+	return e, ok
+}
+
+// expandEnv replaces "${VAR}" references with the value of the environment variable, using the default for
+// "${VAR:-default}" when the variable is unset or empty. "$$" is replaced with "$", so "$${VAR}" results in "${VAR}".
+func expandEnv(s string) (string, []string) {
+	var vars []string
+	expanded := envReferencePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$$" {
+			return "$"
+		}
+		m := envReferencePattern.FindStringSubmatch(ref)
+		vars = append(vars, m[1])
+		if v := os.Getenv(m[1]); v != "" {
+			return v
+		}
+		return strings.TrimPrefix(m[2], ":-")
+	})
+	return expanded, vars
+}
+
+// expandConfigValues expands environment variable references within all string values (including strings within
+// lists), returning the expansions keyed by (lowercase) dotted path.
+func expandConfigValues(values map[string]any, path []string, expansions map[string]ConfigExpansion) {
+	for k, v := range values {
+		p := appendPath(path, strings.ToLower(k))
+		switch v := v.(type) {
+		case map[string]any:
+			expandConfigValues(v, p, expansions)
+		case string:
+			expanded, vars := expandEnv(v)
+			if expanded != v || len(vars) > 0 {
+				values[k] = expanded
+				expansions[strings.Join(p, ".")] = ConfigExpansion{Value: v, Variables: vars}
+			}
+		case []any:
+			var allVars []string
+			changed := false
+			items := make([]any, len(v))
+			for i, item := range v {
+				items[i] = item
+				if s, ok := item.(string); ok {
+					expanded, vars := expandEnv(s)
+					items[i] = expanded
+					allVars = append(allVars, vars...)
+					changed = changed || expanded != s
+				}
+			}
+			if changed || len(allVars) > 0 {
+				values[k] = items
+				expansions[strings.Join(p, ".")] = ConfigExpansion{Value: v, Variables: allVars}
+			}
+		}
+	}
+}
+
+// redactExpansions adds the values of sensitive environment variables referenced by config values to the redaction
+// store.
+func (a *application) redactExpansions(expansions map[string]ConfigExpansion) {
+	if a.state.RedactStore == nil {
+		return
+	}
+	for _, e := range expansions {
+		for _, name := range e.Variables {
+			if v := os.Getenv(name); v != "" && dotEnvSecretPattern.MatchString(name) {
+				a.state.RedactStore.Add(v)
+			}
+		}
+	}
+}
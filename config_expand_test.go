@@ -0,0 +1,96 @@
+package clio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_expandEnv(t *testing.T) {
+	t.Setenv("CLIO_TEST_HOST", "example.com")
+	t.Setenv("CLIO_TEST_EMPTY", "")
+
+	tests := []struct {
+		name     string
+		value    string
+		want     string
+		wantVars []string
+	}{
+		{
+			name:  "no references",
+			value: "plain $HOME value",
+			want:  "plain $HOME value",
+		},
+		{
+			name:     "reference",
+			value:    "https://${CLIO_TEST_HOST}/path",
+			want:     "https://example.com/path",
+			wantVars: []string{"CLIO_TEST_HOST"},
+		},
+		{
+			name:     "unset without a default",
+			value:    "[${CLIO_TEST_UNSET}]",
+			want:     "[]",
+			wantVars: []string{"CLIO_TEST_UNSET"},
+		},
+		{
+			name:     "defaults apply when unset or empty",
+			value:    "${CLIO_TEST_UNSET:-a}-${CLIO_TEST_EMPTY:-b}-${CLIO_TEST_HOST:-c}",
+			want:     "a-b-example.com",
+			wantVars: []string{"CLIO_TEST_UNSET", "CLIO_TEST_EMPTY", "CLIO_TEST_HOST"},
+		},
+		{
+			name:  "escaped references",
+			value: "$${CLIO_TEST_HOST} costs $$5",
+			want:  "${CLIO_TEST_HOST} costs $5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, vars := expandEnv(tt.value)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantVars, vars)
+		})
+	}
+}
+
+func Test_Application_envExpansion(t *testing.T) {
+	t.Setenv("CLIO_TEST_HOST", "example.com")
+	t.Setenv("CLIO_TEST_TIMEOUT", "30")
+	t.Setenv("CLIO_TEST_TOKEN", "s3cr3t-value")
+
+	file := filepath.Join(t.TempDir(), "app.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+name: "${CLIO_TEST_TOKEN}"
+registry:
+  url: https://${CLIO_TEST_HOST}
+  timeout: ${CLIO_TEST_TIMEOUT:-10}
+`), 0o600))
+
+	cfg := NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithEnvExpansion()
+	cfg.FangsConfig.File = file
+	app := New(*cfg)
+
+	got := &systemTestConfig{}
+	root := app.SetupRootCommand(&cobra.Command{
+		RunE: func(*cobra.Command, []string) error { return nil },
+	}, got)
+
+	require.NoError(t, root.Execute())
+	assert.Equal(t, "https://example.com", got.Registry.URL)
+	assert.Equal(t, 30, got.Registry.Timeout)
+
+	state := &app.(*application).state
+	expansion, ok := state.ConfigExpansion("registry.url")
+	require.True(t, ok)
+	assert.Equal(t, ConfigExpansion{Value: "https://${CLIO_TEST_HOST}", Variables: []string{"CLIO_TEST_HOST"}}, expansion)
+
+	_, ok = state.ConfigExpansion("log.level")
+	assert.False(t, ok)
+
+	assert.Equal(t, "token=*******", state.RedactStore.RedactString("token=s3cr3t-value"))
+}
@@ -0,0 +1,187 @@
+package clio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configIncludeKey is the config file key listing other config files (or glob patterns) to include.
+const configIncludeKey = "include"
+
+// configLayer is the result of reading a config file along with all files it includes.
+type configLayer struct {
+	values  map[string]any
+	sources map[string]string // the file that provided each leaf value, keyed by (lowercase) dotted path
+	files   []string          // all files read, in the order they were merged
+
+	// at least one file has multiple documents (which only the first of is read by the config loader)
+	multiDocument bool
+
+	// at least one file was migrated from an older config version (see SetupConfig.WithConfigVersion)
+	migrated bool
+}
+
+// configPreparer updates the values read from a config file before they are merged, indicating if they changed.
+type configPreparer func(file string, values map[string]any) (bool, error)
+
+// readConfigFile reads the config file and all files it includes ("include: [other.yaml, conf.d/*.yaml]"). Included
+// files are merged in order, and the values in the including file take precedence over included values. Relative
+// paths are resolved from the directory of the including file, and patterns matching no files are ignored. For a
+// multi-document yaml file, the given document (1-based) is selected, or all documents are merged when it is 0.
+func readConfigFile(file string, document int) (*configLayer, error) {
+	return readConfigFileIncludes(file, document, nil, nil)
+}
+
+func readConfigFileIncludes(file string, document int, chain []string, prepare configPreparer) (*configLayer, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve config file %q: %w", file, err)
+	}
+	for i, f := range chain {
+		if f == abs {
+			return nil, fmt.Errorf("config include cycle: %s", strings.Join(append(chain[i:], abs), " -> "))
+		}
+	}
+	chain = append(chain, abs)
+
+	values, documents, err := readConfigFileDocuments(file, document)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrated bool
+	if prepare != nil && values != nil {
+		if migrated, err = prepare(file, values); err != nil {
+			return nil, err
+		}
+	}
+
+	includes, err := configIncludes(values, file)
+	if err != nil {
+		return nil, err
+	}
+	delete(values, configIncludeKey)
+
+	layer := &configLayer{values: map[string]any{}, sources: map[string]string{}}
+	for _, pattern := range includes {
+		matches, err := resolveConfigInclude(file, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			included, err := readConfigFileIncludes(match, 0, chain, prepare)
+			if err != nil {
+				return nil, err
+			}
+			layer.merge(included)
+		}
+	}
+
+	layer.merge(&configLayer{values: values, sources: sourcesOf(values, file), files: []string{file}, multiDocument: documents > 1, migrated: migrated})
+	return layer, nil
+}
+
+func (l *configLayer) merge(other *configLayer) {
+	l.values = mergeConfigValues(l.values, other.values)
+	for k, v := range other.sources {
+		l.sources[k] = v
+	}
+	l.files = append(l.files, other.files...)
+	l.multiDocument = l.multiDocument || other.multiDocument
+	l.migrated = l.migrated || other.migrated
+}
+
+// clone returns a deep copy of the layer, since layers are changed as they are merged.
+func (l *configLayer) clone() *configLayer {
+	out := *l
+	out.values, _ = cloneConfigValue(l.values).(map[string]any)
+	out.sources = map[string]string{}
+	for k, v := range l.sources {
+		out.sources[k] = v
+	}
+	out.files = append([]string(nil), l.files...)
+	return &out
+}
+
+func cloneConfigValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		if v == nil {
+			return v
+		}
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = cloneConfigValue(e)
+		}
+		return out
+	case []any:
+		if v == nil {
+			return v
+		}
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = cloneConfigValue(e)
+		}
+		return out
+	}
+	return v
+}
+
+func sourcesOf(values map[string]any, file string) map[string]string {
+	sources := map[string]string{}
+	for k := range flattenConfigValues(values) {
+		sources[k] = file
+	}
+	return sources
+}
+
+// configIncludes returns the include patterns of the config file, which may be a single value or a list.
+func configIncludes(values map[string]any, file string) ([]string, error) {
+	switch v := values[configIncludeKey].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []any:
+		var includes []string
+		for _, i := range v {
+			s, ok := i.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %q in config file %s: expected a list of file names", configIncludeKey, file)
+			}
+			includes = append(includes, s)
+		}
+		return includes, nil
+	default:
+		return nil, fmt.Errorf("invalid %q in config file %s: expected a list of file names", configIncludeKey, file)
+	}
+}
+
+// resolveConfigInclude returns the files matching an include pattern, relative to the including file.
+func resolveConfigInclude(from, pattern string) ([]string, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(from), pattern)
+	}
+
+	if !strings.ContainsAny(pattern, "*?[") {
+		if _, err := os.Stat(pattern); err != nil {
+			return nil, fmt.Errorf("unable to include config file %q (from %s): %w", pattern, from, err)
+		}
+		return []string{pattern}, nil
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config include pattern %q (from %s): %w", pattern, from, err)
+	}
+	return matches, nil
+}
+
+// ConfigSource returns the config file that provided the value for the given config key (e.g. "log.level"), which is
+// useful when the configuration is split across included files. An empty string is returned when the value did not
+// come from a config file. Note that environment variables and flags take precedence over config file values.
+func (s *State) ConfigSource(key string) string {
+	return s.configSources[strings.ToLower(key)]
+}
@@ -0,0 +1,144 @@
+package clio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_readConfigFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		want        map[string]any
+		wantSources map[string]string
+		wantErr     string
+	}{
+		{
+			name: "includes are merged in order under the including file",
+			files: map[string]string{
+				"app.yaml":          "include: [base.yaml, conf.d/*.yaml]\nname: app\n",
+				"base.yaml":         "name: base\nregistry:\n  url: https://base\n  timeout: 1\n",
+				"conf.d/10-a.yaml":  "registry:\n  timeout: 10\n",
+				"conf.d/20-b.yaml":  "registry:\n  timeout: 20\n",
+				"conf.d/ignore.txt": "ignored: true\n",
+			},
+			want: map[string]any{
+				"name":     "app",
+				"registry": map[string]any{"url": "https://base", "timeout": 20},
+			},
+			wantSources: map[string]string{
+				"name":             "app.yaml",
+				"registry.url":     "base.yaml",
+				"registry.timeout": "conf.d/20-b.yaml",
+			},
+		},
+		{
+			name: "nested includes and a single include",
+			files: map[string]string{
+				"app.yaml":        "include: sub/one.yaml\n",
+				"sub/one.yaml":    "include: [two.yaml]\na: 1\n",
+				"sub/two.yaml":    "b: 2\n",
+				"conf.d/none.yml": "",
+			},
+			want:        map[string]any{"a": 1, "b": 2},
+			wantSources: map[string]string{"a": "sub/one.yaml", "b": "sub/two.yaml"},
+		},
+		{
+			name: "patterns matching nothing are ignored",
+			files: map[string]string{
+				"app.yaml": "include: [missing.d/*.yaml]\na: 1\n",
+			},
+			want:        map[string]any{"a": 1},
+			wantSources: map[string]string{"a": "app.yaml"},
+		},
+		{
+			name: "missing files are an error",
+			files: map[string]string{
+				"app.yaml": "include: [missing.yaml]\n",
+			},
+			wantErr: "unable to include config file",
+		},
+		{
+			name: "cycles are detected",
+			files: map[string]string{
+				"app.yaml": "include: [a.yaml]\n",
+				"a.yaml":   "include: [b.yaml]\n",
+				"b.yaml":   "include: [a.yaml]\n",
+			},
+			wantErr: "config include cycle: ",
+		},
+		{
+			name: "invalid include",
+			files: map[string]string{
+				"app.yaml": "include: {a: b}\n",
+			},
+			wantErr: "expected a list of file names",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, contents := range tt.files {
+				path := filepath.Join(dir, filepath.FromSlash(name))
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+				require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+			}
+
+			got, err := readConfigFile(filepath.Join(dir, "app.yaml"), 0)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.values)
+
+			sources := map[string]string{}
+			for k, v := range got.sources {
+				rel, err := filepath.Rel(dir, v)
+				require.NoError(t, err)
+				sources[k] = filepath.ToSlash(rel)
+			}
+			assert.Equal(t, tt.wantSources, sources)
+		})
+	}
+}
+
+func Test_Application_configInclude(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.yaml"), []byte("include: [registry.yaml]\nname: app\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "registry.yaml"), []byte("registry:\n  url: https://included\n  tiemout: 1\n"), 0o600))
+
+	cfg := NewSetupConfig(Identification{Name: "app"}).WithNoBus()
+	cfg.FangsConfig.File = filepath.Join(dir, "app.yaml")
+	app := New(*cfg)
+
+	got := &systemTestConfig{}
+	root := app.SetupRootCommand(&cobra.Command{
+		RunE: func(*cobra.Command, []string) error { return nil },
+	}, got)
+
+	require.NoError(t, root.Execute())
+	assert.Equal(t, "app", got.Name)
+	assert.Equal(t, "https://included", got.Registry.URL)
+
+	state := &app.(*application).state
+	assert.Equal(t, filepath.Join(dir, "registry.yaml"), state.ConfigSource("registry.url"))
+	assert.Equal(t, filepath.Join(dir, "app.yaml"), state.ConfigSource("name"))
+	assert.Empty(t, state.ConfigSource("registry.timeout"))
+
+	// unknown keys in included files are reported with the file containing them
+	cfg.WithStrictConfig()
+	app = New(*cfg)
+	root = app.SetupRootCommand(&cobra.Command{
+		RunE: func(*cobra.Command, []string) error { return nil },
+	}, &systemTestConfig{})
+	err := root.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "registry.tiemout in "+filepath.Join(dir, "registry.yaml"))
+}
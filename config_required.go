@@ -0,0 +1,242 @@
+package clio
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// RequiredFieldsDescriber can be implemented by config structs in order to declare fields that must have a value
+// (from a flag, environment variable, or config file) once all configuration has been loaded. Fields with a zero
+// value are considered missing.
+type RequiredFieldsDescriber interface {
+	DescribeRequiredFields(set RequiredFieldSet)
+}
+
+// RequiredFieldSet accepts pointers to required config fields.
+type RequiredFieldSet interface {
+	Add(ptr any)
+}
+
+// MissingConfigField describes all the ways a user could provide a missing config value.
+type MissingConfigField struct {
+	Key  string // the key within the config file (e.g. "registry.token")
+	Env  string // the environment variable (e.g. "APP_REGISTRY_TOKEN")
+	Flag string // the flag, if one is bound to the field (e.g. "--token")
+}
+
+// MissingConfigError is returned when required config values have not been provided.
+type MissingConfigError struct {
+	Fields []MissingConfigField
+}
+
+func (e *MissingConfigError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("missing required configuration:")
+	for _, f := range e.Fields {
+		var options []string
+		if f.Flag != "" {
+			options = append(options, f.Flag)
+		}
+		options = append(options, f.Env, fmt.Sprintf("%q in the config file", f.Key))
+		sb.WriteString(fmt.Sprintf("\n  - %s: set with %s", f.Key, strings.Join(options, ", ")))
+	}
+	return sb.String()
+}
+
+type requiredFieldSet map[uintptr]struct{}
+
+func (s requiredFieldSet) Add(ptr any) {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("Add() requires a pointer, but got: %#v", ptr))
+	}
+	s[v.Pointer()] = struct{}{}
+}
+
+// configField is a leaf value within a config object graph along with its path (following the same rules as fangs).
+type configField struct {
+	path  []string
+	value reflect.Value
+}
+
+// checkRequiredFields returns a MissingConfigError listing all required fields that do not have a value.
+func (a *application) checkRequiredFields(cmd *cobra.Command, cfgs ...any) error {
+	tagName := a.configTagName()
+
+	required := requiredFieldSet{}
+	fields := map[uintptr]configField{}
+	for _, cfg := range cfgs {
+		collectConfigFields(tagName, reflect.ValueOf(cfg), nil, required, fields)
+	}
+
+	if len(required) == 0 {
+		return nil
+	}
+
+	flags := flagsByRef(cmd)
+
+	var missing []MissingConfigField
+	for _, cfg := range cfgs {
+		// iterate in config graph order (not map order) so that the error is stable
+		var ordered []uintptr
+		orderConfigFields(tagName, reflect.ValueOf(cfg), &ordered)
+		for _, ptr := range ordered {
+			if _, ok := required[ptr]; !ok {
+				continue
+			}
+			delete(required, ptr)
+
+			f := fields[ptr]
+			if !isUnset(f.value) {
+				continue
+			}
+
+			m := MissingConfigField{
+				Key: strings.Join(f.path, "."),
+				Env: envVarName(a.setupConfig.FangsConfig.AppName, f.path),
+			}
+			if flag, ok := flags[ptr]; ok {
+				m.Flag = "--" + flag.Name
+			}
+			missing = append(missing, m)
+		}
+	}
+
+	if len(missing) > 0 {
+		return &MissingConfigError{Fields: missing}
+	}
+	return nil
+}
+
+// isUnset indicates the value has not been provided. Pointers are dereferenced, since the config loader
+// may populate a pointer field with a zero value when no value is given.
+func isUnset(v reflect.Value) bool {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v.IsZero()
+}
+
+func collectConfigFields(tagName string, v reflect.Value, path []string, required requiredFieldSet, fields map[uintptr]configField) {
+	visitConfigFields(tagName, v, path, func(obj any) {
+		if d, ok := obj.(RequiredFieldsDescriber); ok {
+			d.DescribeRequiredFields(required)
+		}
+	}, func(ptr uintptr, f configField) {
+		fields[ptr] = f
+	})
+}
+
+func orderConfigFields(tagName string, v reflect.Value, ordered *[]uintptr) {
+	visitConfigFields(tagName, v, nil, nil, func(ptr uintptr, _ configField) {
+		*ordered = append(*ordered, ptr)
+	})
+}
+
+// visitConfigFields walks the config object graph, calling onStruct with a pointer to each struct and onField with
+// each non-struct field (keyed by the field address). The given value must be a pointer.
+func visitConfigFields(tagName string, v reflect.Value, path []string, onStruct func(any), onField func(uintptr, configField)) {
+	if !v.IsValid() || v.Kind() != reflect.Ptr || v.IsNil() {
+		return
+	}
+
+	base := v.Elem()
+	for base.Kind() == reflect.Ptr && !base.IsNil() {
+		base = base.Elem()
+	}
+
+	if base.Kind() != reflect.Struct {
+		onField(v.Pointer(), configField{path: path, value: v.Elem()})
+		return
+	}
+
+	if onStruct != nil {
+		onStruct(base.Addr().Interface())
+	}
+
+	t := base.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		fieldPath := path
+		if tag, ok := f.Tag.Lookup(tagName); ok {
+			parts := strings.Split(tag, ",")
+			switch {
+			case parts[0] == "-":
+				continue
+			case contains(parts[1:], "squash"):
+				// use the current path
+			case parts[0] == "":
+				fieldPath = appendPath(path, f.Name)
+			default:
+				fieldPath = appendPath(path, parts[0])
+			}
+		} else {
+			fieldPath = appendPath(path, f.Name)
+		}
+
+		visitConfigFields(tagName, base.Field(i).Addr(), fieldPath, onStruct, onField)
+	}
+}
+
+func appendPath(path []string, name string) []string {
+	return append(append([]string{}, path...), name)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+var envVarPattern = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+// envVarName returns the environment variable fangs binds for the given config path.
+func envVarName(appName string, path []string) string {
+	v := strings.Join(path, "_")
+	if appName != "" {
+		v = appName + "_" + v
+	}
+	return strings.ToUpper(envVarPattern.ReplaceAllString(v, "_"))
+}
+
+// flagsByRef returns all flags available to the command keyed by the address of the value they are bound to.
+func flagsByRef(cmd *cobra.Command) map[uintptr]*pflag.Flag {
+	refs := map[uintptr]*pflag.Flag{}
+	if cmd == nil {
+		return refs
+	}
+	for _, flags := range []*pflag.FlagSet{cmd.InheritedFlags(), cmd.PersistentFlags(), cmd.Flags()} {
+		flags.VisitAll(func(flag *pflag.Flag) {
+			refs[flagRef(flag)] = flag
+		})
+	}
+	return refs
+}
+
+func flagRef(flag *pflag.Flag) uintptr {
+	value := flag.Value
+	if r, ok := value.(*resetSliceValue); ok {
+		value = r.sliceValue
+	}
+	v := reflect.ValueOf(value)
+
+	// check for struct types like stringArrayValue
+	if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct {
+		if vf := v.Elem().FieldByName("value"); vf.IsValid() && vf.Kind() == reflect.Ptr {
+			return vf.Pointer()
+		}
+	}
+	return v.Pointer()
+}
@@ -0,0 +1,95 @@
+package clio
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/fangs"
+)
+
+type registryConfig struct {
+	Host  string `mapstructure:"host"`
+	Token string `mapstructure:"token"`
+	Port  *int   `mapstructure:"port"`
+}
+
+type requiredConfig struct {
+	Name     string         `mapstructure:"name"`
+	Registry registryConfig `mapstructure:"registry"`
+}
+
+var _ interface {
+	fangs.FlagAdder
+	RequiredFieldsDescriber
+} = (*requiredConfig)(nil)
+
+func (c *requiredConfig) AddFlags(flags fangs.FlagSet) {
+	flags.StringVarP(&c.Registry.Token, "token", "", "the registry token")
+}
+
+func (c *requiredConfig) DescribeRequiredFields(set RequiredFieldSet) {
+	set.Add(&c.Registry.Token)
+	set.Add(&c.Registry.Host)
+}
+
+func (c *registryConfig) DescribeRequiredFields(set RequiredFieldSet) {
+	set.Add(&c.Port)
+}
+
+func Test_Application_RequiredFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name: "all missing",
+			wantErr: `missing required configuration:
+  - registry.host: set with APP_REGISTRY_HOST, "registry.host" in the config file
+  - registry.token: set with --token, APP_REGISTRY_TOKEN, "registry.token" in the config file
+  - registry.port: set with APP_REGISTRY_PORT, "registry.port" in the config file`,
+		},
+		{
+			name: "some provided",
+			args: []string{"--token", "secret"},
+			env:  map[string]string{"APP_REGISTRY_PORT": "5000"},
+			wantErr: `missing required configuration:
+  - registry.host: set with APP_REGISTRY_HOST, "registry.host" in the config file`,
+		},
+		{
+			name: "all provided",
+			args: []string{"--token", "secret"},
+			env: map[string]string{
+				"APP_REGISTRY_PORT": "5000",
+				"APP_REGISTRY_HOST": "localhost",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			app := New(*NewSetupConfig(Identification{Name: "app", Version: "1.0"}))
+			root := app.SetupRootCommand(&cobra.Command{
+				Run: func(cmd *cobra.Command, args []string) {},
+			}, &requiredConfig{})
+
+			root.SetArgs(tt.args)
+			err := root.Execute()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			var missing *MissingConfigError
+			require.ErrorAs(t, err, &missing)
+			assert.Equal(t, tt.wantErr, err.Error())
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package clio
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// UnknownConfigKey is a key within the config file that is not used by any configuration.
+type UnknownConfigKey struct {
+	Key        string
+	File       string // the file containing the key (which may be an included file)
+	Suggestion string // the closest known key (if any are close)
+}
+
+// UnknownConfigKeysError is returned in strict config mode when the config file contains unknown keys.
+type UnknownConfigKeysError struct {
+	File string
+	Keys []UnknownConfigKey
+}
+
+func (e *UnknownConfigKeysError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("unknown keys in config file %s:", e.File))
+	for _, k := range e.Keys {
+		sb.WriteString("\n  - " + k.Key)
+		if k.File != "" && k.File != e.File {
+			sb.WriteString(fmt.Sprintf(" in %s", k.File))
+		}
+		if k.Suggestion != "" {
+			sb.WriteString(fmt.Sprintf(" (did you mean %q?)", k.Suggestion))
+		}
+	}
+	return sb.String()
+}
+
+// configFileUsed returns the config file that is loaded, following the same search as fangs (the first file found).
+func (a *application) configFileUsed() string {
+	cfg := a.setupConfig.FangsConfig
+	for _, finder := range a.configFinders() {
+		for _, file := range finder(cfg) {
+			if fi, err := os.Stat(file); err == nil && !fi.IsDir() {
+				return file
+			}
+		}
+	}
+	return ""
+}
+
+// readConfigFileKeys returns the contents of the config file as a generic map (only yaml, json, and toml files are
+// supported, returning nil for other formats). All documents within a multi-document yaml file are merged, with
+// values in later documents taking precedence.
+func readConfigFileKeys(file string) (map[string]any, error) {
+	values, _, err := readConfigFileDocuments(file, 0)
+	return values, err
+}
+
+// readConfigFileDocuments returns the contents of the given document (1-based) of the config file, or all documents
+// merged when the document is 0, along with the number of documents in the file.
+func readConfigFileDocuments(file string, document int) (map[string]any, int, error) {
+	ext := strings.ToLower(filepath.Ext(file))
+	switch ext {
+	case ".yaml", ".yml", ".json", ".toml":
+	default:
+		return nil, 0, nil
+	}
+
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to read config file: %w", err)
+	}
+
+	if ext == ".toml" {
+		var values map[string]any
+		if err := toml.Unmarshal(contents, &values); err != nil {
+			return nil, 0, newTOMLSyntaxError(file, contents, err)
+		}
+		return values, 1, selectConfigDocument(file, document, 1)
+	}
+
+	var docs []map[string]any
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	for {
+		var values map[string]any
+		err := decoder.Decode(&values)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, newConfigSyntaxError(file, contents, err)
+		}
+		docs = append(docs, values)
+	}
+
+	if err := selectConfigDocument(file, document, len(docs)); err != nil {
+		return nil, 0, err
+	}
+	if document > 0 {
+		return docs[document-1], len(docs), nil
+	}
+
+	var values map[string]any
+	for _, doc := range docs {
+		if doc == nil {
+			// an empty document
+			continue
+		}
+		if values == nil {
+			values = doc
+			continue
+		}
+		values = mergeConfigValues(values, doc)
+	}
+	return values, len(docs), nil
+}
+
+func selectConfigDocument(file string, document, documents int) error {
+	if document > documents {
+		return fmt.Errorf("unable to select document %d of config file %s, which has %d document(s)", document, file, documents)
+	}
+	return nil
+}
+
+// checkUnknownConfigKeys returns an UnknownConfigKeysError when strict config mode is enabled (by the application,
+// the --strict-config flag, "config.strict: true" in the config file, or strict mode, see StrictModeConfig) and the config file contains keys that
+// are not used by any of the given configs or the configs of any other command.
+func (a *application) checkUnknownConfigKeys(cfgs ...any) error {
+	file := a.configFileUsed()
+	if file == "" {
+		return nil
+	}
+
+	layer, err := a.readConfigFile(file)
+	if err != nil || layer.values == nil {
+		return err
+	}
+	values := layer.values
+
+	if !a.setupConfig.StrictConfig && !a.strictConfig && !strictInFile(values) && !a.state.Config.Strict.enabled() {
+		return nil
+	}
+
+	known := map[string]bool{}
+	// keys under these paths are free-form (e.g. maps)
+	var open []string
+	for _, cfg := range append(append([]any{}, cfgs...), a.state.Config.FromCommands...) {
+		visitConfigFields(a.configTagName(), reflect.ValueOf(cfg), nil, nil, func(_ uintptr, f configField) {
+			path := strings.ToLower(strings.Join(f.path, "."))
+			for i := range f.path {
+				known[strings.ToLower(strings.Join(f.path[:i+1], "."))] = true
+			}
+			t := f.value.Type()
+			for t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			if t.Kind() == reflect.Map || t.Kind() == reflect.Interface {
+				open = append(open, path)
+			}
+		})
+	}
+
+	var keys []string
+	collectConfigKeys(values, nil, &keys)
+
+	var unknown []UnknownConfigKey
+	for _, key := range keys {
+		lower := strings.ToLower(key)
+		if known[lower] || lower == "config" || strings.HasPrefix(lower, "config.") || underAny(lower, open) {
+			continue
+		}
+		unknown = append(unknown, UnknownConfigKey{Key: key, File: layer.sources[lower], Suggestion: closestKey(lower, known)})
+	}
+
+	if len(unknown) > 0 {
+		return &UnknownConfigKeysError{File: file, Keys: unknown}
+	}
+	return nil
+}
+
+// strictInFile indicates the config file enables strict mode ("config.strict: true").
+func strictInFile(values map[string]any) bool {
+	cfg, ok := values["config"].(map[string]any)
+	if !ok {
+		return false
+	}
+	strict, _ := cfg["strict"].(bool)
+	return strict
+}
+
+// collectConfigKeys collects the paths of all leaf values (and empty sections) in the config file.
+func collectConfigKeys(values map[string]any, path []string, keys *[]string) {
+	names := make([]string, 0, len(values))
+	for k := range values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		p := appendPath(path, k)
+		if nested, ok := values[k].(map[string]any); ok && len(nested) > 0 {
+			collectConfigKeys(nested, p, keys)
+			continue
+		}
+		*keys = append(*keys, strings.Join(p, "."))
+	}
+}
+
+func underAny(key string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(key, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// closestKey returns the known key with the smallest edit distance to the given key, when it is close enough to
+// likely be a typo.
+func closestKey(key string, known map[string]bool) string {
+	best, bestDistance := "", -1
+	for k := range known {
+		d := levenshtein(key, k)
+		if bestDistance < 0 || d < bestDistance || (d == bestDistance && k < best) {
+			best, bestDistance = k, d
+		}
+	}
+	limit := len(key) / 3
+	if limit < 2 {
+		limit = 2
+	}
+	if bestDistance < 0 || bestDistance > limit {
+		return ""
+	}
+	return best
+}
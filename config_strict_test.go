@@ -0,0 +1,182 @@
+package clio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type strictTestConfig struct {
+	Registry struct {
+		Token string `mapstructure:"token"`
+	} `mapstructure:"registry"`
+	Labels map[string]string `mapstructure:"labels"`
+}
+
+func Test_Application_strictConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		args    []string
+		strict  bool
+		wantErr string
+	}{
+		{
+			name:   "unknown keys are ignored by default",
+			config: "registry:\n  tokne: secret\n",
+		},
+		{
+			name:    "strict mode from the application",
+			config:  "registry:\n  tokne: secret\n",
+			strict:  true,
+			wantErr: `registry.tokne (did you mean "registry.token"?)`,
+		},
+		{
+			name:    "strict mode from the config file",
+			config:  "config:\n  strict: true\nregistri:\n  token: secret\nbogus: true\n",
+			wantErr: "unknown keys in config file",
+		},
+		{
+			name:    "strict mode from the flag",
+			config:  "regsitry: {}\n",
+			args:    []string{"--strict-config"},
+			wantErr: "regsitry",
+		},
+		{
+			name:   "known keys, free-form maps, and the configs of other commands are accepted",
+			config: "registry:\n  token: secret\nlabels:\n  team: core\nlog:\n  level: info\nother:\n  name: x\n",
+			strict: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "app.yaml")
+			require.NoError(t, os.WriteFile(file, []byte(tt.config), 0o600))
+
+			cfg := NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithStrictConfigFlag()
+			cfg.FangsConfig.File = file
+			if tt.strict {
+				cfg.WithStrictConfig()
+			}
+			app := New(*cfg)
+
+			root := app.SetupRootCommand(&cobra.Command{
+				RunE: func(*cobra.Command, []string) error { return nil },
+			}, &strictTestConfig{})
+			root.AddCommand(app.SetupCommand(&cobra.Command{Use: "other"}, &struct {
+				Other struct {
+					Name string `mapstructure:"name"`
+				} `mapstructure:"other"`
+			}{}))
+
+			root.SetArgs(tt.args)
+			err := root.Execute()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+			assert.NotContains(t, err.Error(), "config.strict")
+		})
+	}
+}
+
+func Test_Application_yamlDocumentsAndAnchors(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		document int
+		want     systemTestConfig
+		wantErr  string
+	}{
+		{
+			name: "anchors, aliases, and merge keys",
+			config: `
+defaults: &defaults
+  url: https://default.example.com
+  timeout: 10
+name: &name shared
+registry:
+  <<: *defaults
+  timeout: 20
+other: *name
+`,
+			want: func() (c systemTestConfig) {
+				c.Name = "shared"
+				c.Registry.URL = "https://default.example.com"
+				c.Registry.Timeout = 20
+				return c
+			}(),
+		},
+		{
+			name:   "multiple merge keys",
+			config: "a: &a\n  url: https://a\nb: &b\n  url: https://b\n  timeout: 2\nregistry:\n  <<: [*a, *b]\n",
+			want: func() (c systemTestConfig) {
+				c.Registry.URL = "https://a"
+				c.Registry.Timeout = 2
+				return c
+			}(),
+		},
+		{
+			name:   "later documents take precedence",
+			config: "---\n---\nname: first\nregistry:\n  url: https://first\n---\nregistry:\n  timeout: 3\n---\nname: last\n",
+			want: func() (c systemTestConfig) {
+				c.Name = "last"
+				c.Registry.URL = "https://first"
+				c.Registry.Timeout = 3
+				return c
+			}(),
+		},
+		{
+			name:     "a document can be selected",
+			config:   "name: first\n---\nname: second\n---\nname: third\n",
+			document: 2,
+			want:     systemTestConfig{Name: "second"},
+		},
+		{
+			name:     "the selected document must exist",
+			config:   "name: first\n---\nname: second\n",
+			document: 3,
+			wantErr:  "unable to select document 3 of config file",
+		},
+		{
+			name:    "errors within merged values point to the anchor",
+			config:  "defaults: &defaults\n  timeout: soon\nregistry:\n  <<: *defaults\n",
+			wantErr: ":2:12: registry.timeout: expected a value of type int, but got \"soon\"",
+		},
+		{
+			name:    "errors within later documents",
+			config:  "registry:\n  timeout: 1\n---\nregistry:\n  timeout: soon\n",
+			wantErr: ":5:12: registry.timeout: expected a value of type int, but got \"soon\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "app.yaml")
+			require.NoError(t, os.WriteFile(file, []byte(tt.config), 0o600))
+
+			cfg := NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithConfigDocument(tt.document)
+			cfg.FangsConfig.File = file
+			app := New(*cfg)
+
+			got := &systemTestConfig{}
+			root := app.SetupRootCommand(&cobra.Command{
+				RunE: func(*cobra.Command, []string) error { return nil },
+			}, got)
+
+			err := root.Execute()
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, *got)
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package clio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/boss-net/fangs"
+)
+
+// systemConfigDir returns the directory of the system-wide config file: /etc/<app> (or %ProgramData%\<app> on
+// windows), unless configured with SetupConfig.WithSystemConfig.
+func (a *application) systemConfigDir() string {
+	if a.setupConfig.SystemConfigDir != "" {
+		return a.setupConfig.SystemConfigDir
+	}
+	if runtime.GOOS == "windows" {
+		programData := os.Getenv("ProgramData")
+		if programData == "" {
+			programData = `C:\ProgramData`
+		}
+		return filepath.Join(programData, a.setupConfig.ID.Name)
+	}
+	return filepath.Join("/etc", a.setupConfig.ID.Name)
+}
+
+// systemConfigFile returns the system-wide config file, if one exists.
+func (a *application) systemConfigFile() string {
+	dir := a.systemConfigDir()
+	for _, name := range []string{"config.yaml", "config.yml", "config.json"} {
+		file := filepath.Join(dir, name)
+		if fi, err := os.Stat(file); err == nil && !fi.IsDir() {
+			return file
+		}
+	}
+	return ""
+}
+
+// layeredConfig returns the fangs config to load with, merging the user (or project) config file with all files it
+// includes, layered over the system config file (see SetupConfig.WithSystemConfig) and under the workspace config file
+// (see SetupConfig.WithWorkspace), with environment variable
+// references expanded (see SetupConfig.WithEnvExpansion). The returned function removes the merged config file.
+func (a *application) layeredConfig(cmd *cobra.Command, cfgs ...any) (fangs.Config, func(), error) {
+	cfg := a.setupConfig.FangsConfig
+	cfg.Finders = a.configFinders()
+
+	layer := &configLayer{values: map[string]any{}, sources: map[string]string{}}
+
+	var systemFile string
+	if a.setupConfig.SystemConfig {
+		systemFile = a.systemConfigFile()
+	}
+	if systemFile != "" {
+		system, documents, err := readConfigFileDocuments(systemFile, 0)
+		if err != nil {
+			return cfg, nil, err
+		}
+		var migrated bool
+		if system != nil {
+			if migrated, err = a.migrateConfigFile(systemFile, system); err != nil {
+				return cfg, nil, err
+			}
+		}
+		layer.merge(&configLayer{values: system, sources: sourcesOf(system, systemFile), files: []string{systemFile}, multiDocument: documents > 1, migrated: migrated})
+	}
+
+	user := &configLayer{values: map[string]any{}, sources: map[string]string{}}
+	if userFile := a.configFileUsed(); userFile != "" {
+		var err error
+		if user, err = a.readConfigFile(userFile); err != nil {
+			return cfg, nil, err
+		}
+	}
+
+	if err := a.detectWorkspace(); err != nil {
+		return cfg, nil, err
+	}
+	var workspaceLayered bool
+	if wsFile := a.state.workspace.ConfigFile; wsFile != "" && !sameFile(wsFile, a.configFileUsed()) {
+		// the workspace config takes precedence over the user config
+		ws, err := a.readConfigFile(wsFile)
+		if err != nil {
+			return cfg, nil, err
+		}
+		user.merge(ws)
+		workspaceLayered = true
+	}
+
+	if systemFile != "" {
+		if err := a.checkLockedConfigKeys(cmd, systemFile, layer.values, user, cfgs...); err != nil {
+			return cfg, nil, err
+		}
+	}
+
+	layer.merge(user)
+	a.state.configSources = layer.sources
+
+	a.state.configExpansions = map[string]ConfigExpansion{}
+	if a.setupConfig.ExpandEnv {
+		expandConfigValues(layer.values, nil, a.state.configExpansions)
+		a.redactExpansions(a.state.configExpansions)
+	}
+
+	if len(layer.files) <= 1 && !layer.multiDocument && !layer.migrated && !workspaceLayered && len(a.state.configExpansions) == 0 {
+		// there is nothing to merge
+		return cfg, func() {}, nil
+	}
+
+	contents, err := yaml.Marshal(layer.values)
+	if err != nil {
+		return cfg, nil, fmt.Errorf("unable to merge config files: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", a.setupConfig.ID.Name+"-config-")
+	if err != nil {
+		return cfg, nil, fmt.Errorf("unable to merge config files: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(dir) }
+
+	merged := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(merged, contents, 0o600); err != nil {
+		cleanup()
+		return cfg, nil, fmt.Errorf("unable to merge config files: %w", err)
+	}
+
+	cfg.File = merged
+	cfg.Finders = []fangs.Finder{fangs.FindDirect}
+	return cfg, cleanup, nil
+}
+
+// mergeConfigValues returns the base values with the override values layered on top (nested sections are merged).
+func mergeConfigValues(base, override map[string]any) map[string]any {
+	out := make(map[string]any, len(base)+len(override))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range override {
+		if bm, ok := out[k].(map[string]any); ok {
+			if om, ok := v.(map[string]any); ok {
+				out[k] = mergeConfigValues(bm, om)
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// LockedConfigError is returned when the user attempts to change a config key that is locked by the system
+// configuration (see SetupConfig.WithSystemConfig).
+type LockedConfigError struct {
+	Key    string
+	Source string // where the user attempted to change the key (e.g. the environment variable or flag)
+	File   string // the system config file
+}
+
+func (e *LockedConfigError) Error() string {
+	return fmt.Sprintf("config key %q is locked by the system configuration (%s) and cannot be changed with %s", e.Key, e.File, e.Source)
+}
+
+// checkLockedConfigKeys ensures locked keys from the system config file are not changed by the user config file,
+// environment variables, or flags.
+func (a *application) checkLockedConfigKeys(cmd *cobra.Command, systemFile string, system map[string]any, user *configLayer, cfgs ...any) error {
+	locked := a.setupConfig.LockedConfigKeys
+	if len(locked) == 0 {
+		return nil
+	}
+
+	systemValues := flattenConfigValues(system)
+	userValues := flattenConfigValues(user.values)
+
+	var userKeys []string
+	for k := range userValues {
+		userKeys = append(userKeys, k)
+	}
+	sort.Strings(userKeys)
+
+	for _, key := range userKeys {
+		if !isLockedKey(key, locked) {
+			continue
+		}
+		if sv, ok := systemValues[key]; ok && reflect.DeepEqual(sv, userValues[key]) {
+			continue
+		}
+		return &LockedConfigError{Key: key, Source: user.sources[key], File: systemFile}
+	}
+
+	flags := flagsByRef(cmd)
+	var lockedErr error
+	for _, cfg := range cfgs {
+		visitConfigFields(a.configTagName(), reflect.ValueOf(cfg), nil, nil, func(ptr uintptr, f configField) {
+			key := strings.ToLower(strings.Join(f.path, "."))
+			if lockedErr != nil || !isLockedKey(key, locked) {
+				return
+			}
+			if flag, ok := flags[ptr]; ok && flag.Changed {
+				lockedErr = &LockedConfigError{Key: key, Source: "--" + flag.Name, File: systemFile}
+				return
+			}
+			if env := envVarName(a.setupConfig.FangsConfig.AppName, f.path); os.Getenv(env) != "" {
+				lockedErr = &LockedConfigError{Key: key, Source: env, File: systemFile}
+			}
+		})
+	}
+	return lockedErr
+}
+
+// flattenConfigValues returns all leaf values keyed by their (lowercase) dotted path.
+func flattenConfigValues(values map[string]any) map[string]any {
+	out := map[string]any{}
+	var flatten func(map[string]any, []string)
+	flatten = func(m map[string]any, path []string) {
+		for k, v := range m {
+			p := appendPath(path, strings.ToLower(k))
+			if nested, ok := v.(map[string]any); ok {
+				flatten(nested, p)
+				continue
+			}
+			out[strings.Join(p, ".")] = v
+		}
+	}
+	flatten(values, nil)
+	return out
+}
+
+// isLockedKey indicates the key (or a section containing the key) is locked.
+func isLockedKey(key string, locked []string) bool {
+	for _, l := range locked {
+		l = strings.ToLower(l)
+		if key == l || strings.HasPrefix(key, l+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package clio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type systemTestConfig struct {
+	Registry struct {
+		URL     string `mapstructure:"url"`
+		Timeout int    `mapstructure:"timeout"`
+	} `mapstructure:"registry"`
+	Name string `mapstructure:"name"`
+}
+
+func Test_Application_systemConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		system  string
+		user    string
+		locked  []string
+		env     map[string]string
+		args    []string
+		want    systemTestConfig
+		wantErr string
+	}{
+		{
+			name:   "system values are used when not set by the user",
+			system: "registry:\n  url: https://corp\n  timeout: 10\nname: system\n",
+			user:   "registry:\n  timeout: 20\n",
+			want: func() (c systemTestConfig) {
+				c.Registry.URL = "https://corp"
+				c.Registry.Timeout = 20
+				c.Name = "system"
+				return c
+			}(),
+		},
+		{
+			name:   "flags override system values",
+			system: "name: system\n",
+			args:   []string{"--name", "flag"},
+			want:   systemTestConfig{Name: "flag"},
+		},
+		{
+			name:    "locked keys cannot be set in the user config",
+			system:  "registry:\n  url: https://corp\n",
+			user:    "registry:\n  url: https://other\n",
+			locked:  []string{"registry"},
+			wantErr: `config key "registry.url" is locked`,
+		},
+		{
+			name:   "locked keys may be set to the same value",
+			system: "registry:\n  url: https://corp\n",
+			user:   "registry:\n  url: https://corp\n",
+			locked: []string{"registry.url"},
+			want: func() (c systemTestConfig) {
+				c.Registry.URL = "https://corp"
+				return c
+			}(),
+		},
+		{
+			name:    "locked keys cannot be set with environment variables",
+			system:  "registry:\n  url: https://corp\n",
+			locked:  []string{"registry.url"},
+			env:     map[string]string{"APP_REGISTRY_URL": "https://other"},
+			wantErr: "APP_REGISTRY_URL",
+		},
+		{
+			name:    "locked keys cannot be set with flags",
+			system:  "name: system\n",
+			locked:  []string{"name"},
+			args:    []string{"--name", "flag"},
+			wantErr: "--name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			systemDir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(systemDir, "config.yaml"), []byte(tt.system), 0o600))
+
+			cfg := NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithSystemConfig(systemDir, tt.locked...)
+			if tt.user != "" {
+				file := filepath.Join(t.TempDir(), "app.yaml")
+				require.NoError(t, os.WriteFile(file, []byte(tt.user), 0o600))
+				cfg.FangsConfig.File = file
+			}
+			app := New(*cfg)
+
+			got := &systemTestConfig{}
+			root := app.SetupRootCommand(&cobra.Command{
+				RunE: func(*cobra.Command, []string) error { return nil },
+			}, got)
+			root.Flags().StringVar(&got.Name, "name", got.Name, "")
+
+			root.SetArgs(tt.args)
+			err := root.Execute()
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, *got)
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package clio
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// configValue is implemented by config field types that are decoded from their string form (from config files,
+// environment variables, and flags) and validated once all configuration is loaded, so that errors name the config
+// key. Durations need no special type: time.Duration fields accept values such as "30s" or "1h30m".
+type configValue interface {
+	validateConfigValue() error
+}
+
+var (
+	_ configValue = ByteSize("")
+	_ pflag.Value = (*ByteSize)(nil)
+	_ configValue = URL("")
+	_ pflag.Value = (*URL)(nil)
+)
+
+// ByteSize is a size in bytes written in human-readable form: a (possibly fractional) number followed by an optional
+// unit, either decimal (B, KB, MB, GB, TB, PB) or binary (KiB, MiB, GiB, TiB, PiB). Units are not case-sensitive and a
+// number without a unit is a number of bytes (e.g. "512MiB", "1.5GB", "1024").
+type ByteSize string
+
+var byteSizePattern = regexp.MustCompile(`^\s*([0-9]+(?:\.[0-9]+)?)\s*([a-zA-Z]*)\s*$`)
+
+var byteSizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1e3,
+	"kb":  1e3,
+	"m":   1e6,
+	"mb":  1e6,
+	"g":   1e9,
+	"gb":  1e9,
+	"t":   1e12,
+	"tb":  1e12,
+	"p":   1e15,
+	"pb":  1e15,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+	"pib": 1 << 50,
+}
+
+// NewByteSize returns the ByteSize for the number of bytes, using the largest binary unit that represents it exactly.
+func NewByteSize(bytes int64) ByteSize {
+	units := []string{"PiB", "TiB", "GiB", "MiB", "KiB"}
+	for i, unit := range units {
+		size := int64(1) << (10 * (len(units) - i))
+		if bytes != 0 && bytes%size == 0 {
+			return ByteSize(fmt.Sprintf("%d%s", bytes/size, unit))
+		}
+	}
+	return ByteSize(strconv.FormatInt(bytes, 10))
+}
+
+// ParseByteSize returns the number of bytes for the human-readable size.
+func ParseByteSize(s string) (int64, error) {
+	m := byteSizePattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("unable to parse %q as a byte size (e.g. \"512MiB\" or \"2GB\")", s)
+	}
+	unit, ok := byteSizeUnits[strings.ToLower(m[2])]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q in byte size %q (expected one of B, KB, MB, GB, TB, PB, KiB, MiB, GiB, TiB, PiB)", m[2], s)
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse %q as a byte size: %w", s, err)
+	}
+	bytes := n * unit
+	if bytes > math.MaxInt64 {
+		return 0, fmt.Errorf("byte size %q is too large", s)
+	}
+	return int64(math.Round(bytes)), nil
+}
+
+// Bytes returns the number of bytes (an empty ByteSize is 0 bytes). Values loaded as configuration are always valid.
+func (b ByteSize) Bytes() int64 {
+	if b == "" {
+		return 0
+	}
+	n, _ := ParseByteSize(string(b))
+	return n
+}
+
+func (b ByteSize) validateConfigValue() error {
+	if b == "" {
+		return nil
+	}
+	_, err := ParseByteSize(string(b))
+	return err
+}
+
+func (b *ByteSize) Set(s string) error {
+	if _, err := ParseByteSize(s); err != nil {
+		return err
+	}
+	*b = ByteSize(strings.TrimSpace(s))
+	return nil
+}
+
+func (b *ByteSize) String() string {
+	return string(*b)
+}
+
+func (b *ByteSize) Type() string {
+	return "size"
+}
+
+// URL is an absolute URL (with a scheme and host), such as "https://registry.example.com/v2".
+type URL string
+
+// ParseURL parses and validates an absolute URL.
+func ParseURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %q as a URL: %w", s, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an absolute URL (e.g. \"https://example.com\")", s)
+	}
+	return u, nil
+}
+
+// URL returns the parsed URL, or nil when empty. Values loaded as configuration are always valid.
+func (u URL) URL() *url.URL {
+	if u == "" {
+		return nil
+	}
+	parsed, _ := ParseURL(string(u))
+	return parsed
+}
+
+func (u URL) validateConfigValue() error {
+	if u == "" {
+		return nil
+	}
+	_, err := ParseURL(string(u))
+	return err
+}
+
+func (u *URL) Set(s string) error {
+	if _, err := ParseURL(s); err != nil {
+		return err
+	}
+	*u = URL(s)
+	return nil
+}
+
+func (u *URL) String() string {
+	return string(*u)
+}
+
+func (u *URL) Type() string {
+	return "url"
+}
+
+// validateConfigValues validates all config fields of the clio config types (e.g. ByteSize and URL).
+func validateConfigValues(tagName string, cfgs ...any) error {
+	var errs []string
+	for _, cfg := range cfgs {
+		visitConfigFields(tagName, reflect.ValueOf(cfg), nil, nil, func(_ uintptr, f configField) {
+			if f.value.Kind() == reflect.Ptr && f.value.IsNil() {
+				return
+			}
+			v, ok := f.value.Interface().(configValue)
+			if !ok {
+				return
+			}
+			if err := v.validateConfigValue(); err != nil {
+				errs = append(errs, fmt.Sprintf("invalid value for %s: %v", strings.Join(f.path, "."), err))
+			}
+		})
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package clio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseByteSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr string
+	}{
+		{value: "0", want: 0},
+		{value: "1024", want: 1024},
+		{value: "10B", want: 10},
+		{value: "2KB", want: 2000},
+		{value: "2kib", want: 2048},
+		{value: "1.5GB", want: 1_500_000_000},
+		{value: "2GiB", want: 2 << 30},
+		{value: " 512 MiB ", want: 512 << 20},
+		{value: "1PiB", want: 1 << 50},
+		{value: "2XB", wantErr: `unknown unit "XB"`},
+		{value: "-1GB", wantErr: "unable to parse"},
+		{value: "lots", wantErr: "unable to parse"},
+		{value: "99999999PB", wantErr: "too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseByteSize(tt.value)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_NewByteSize(t *testing.T) {
+	assert.Equal(t, ByteSize("0"), NewByteSize(0))
+	assert.Equal(t, ByteSize("1000"), NewByteSize(1000))
+	assert.Equal(t, ByteSize("1KiB"), NewByteSize(1024))
+	assert.Equal(t, ByteSize("3GiB"), NewByteSize(3<<30))
+	assert.Equal(t, ByteSize("1536MiB"), NewByteSize(1536<<20))
+	assert.Equal(t, int64(3<<30), NewByteSize(3<<30).Bytes())
+}
+
+func Test_ParseURL(t *testing.T) {
+	u, err := ParseURL("https://example.com/v2")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", u.Host)
+
+	_, err = ParseURL("example.com/v2")
+	require.ErrorContains(t, err, "not an absolute URL")
+
+	_, err = ParseURL("http://[::1")
+	require.ErrorContains(t, err, "unable to parse")
+
+	assert.Nil(t, URL("").URL())
+	assert.Equal(t, "/v2", URL("https://example.com/v2").URL().Path)
+}
+
+type typesTestConfig struct {
+	Timeout  time.Duration `mapstructure:"timeout"`
+	MaxSize  ByteSize      `mapstructure:"max-size"`
+	Registry URL           `mapstructure:"registry"`
+}
+
+func Test_Application_configTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		env     map[string]string
+		args    []string
+		want    typesTestConfig
+		wantErr string
+	}{
+		{
+			name:   "defaults",
+			config: "",
+			want:   typesTestConfig{Timeout: time.Minute, MaxSize: "1GiB", Registry: "https://default.example.com"},
+		},
+		{
+			name:   "from the config file",
+			config: "timeout: 30s\nmax-size: 512MiB\nregistry: https://file.example.com\n",
+			want:   typesTestConfig{Timeout: 30 * time.Second, MaxSize: "512MiB", Registry: "https://file.example.com"},
+		},
+		{
+			name:   "from the environment",
+			env:    map[string]string{"APP_TIMEOUT": "1h", "APP_MAX_SIZE": "2GB"},
+			config: "max-size: 4096\n",
+			want:   typesTestConfig{Timeout: time.Hour, MaxSize: "2GB", Registry: "https://default.example.com"},
+		},
+		{
+			name: "from flags",
+			args: []string{"--timeout", "5s", "--max-size", "10KiB", "--registry", "http://flag.example.com"},
+			want: typesTestConfig{Timeout: 5 * time.Second, MaxSize: "10KiB", Registry: "http://flag.example.com"},
+		},
+		{
+			name:    "invalid config file values name the key",
+			config:  "max-size: 2 lots\nregistry: example.com\n",
+			wantErr: `invalid value for max-size: unknown unit "lots"`,
+		},
+		{
+			name:    "invalid environment values",
+			env:     map[string]string{"APP_REGISTRY": "not a url"},
+			wantErr: `invalid value for registry: "not a url" is not an absolute URL`,
+		},
+		{
+			name:    "invalid flag values",
+			args:    []string{"--max-size", "big"},
+			wantErr: `invalid argument "big" for "--max-size" flag`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			file := filepath.Join(t.TempDir(), "app.yaml")
+			require.NoError(t, os.WriteFile(file, []byte(tt.config), 0o600))
+
+			cfg := NewSetupConfig(Identification{Name: "app"}).WithNoBus()
+			cfg.FangsConfig.File = file
+			app := New(*cfg)
+
+			got := &typesTestConfig{Timeout: time.Minute, MaxSize: "1GiB", Registry: "https://default.example.com"}
+			root := app.SetupRootCommand(&cobra.Command{
+				RunE: func(*cobra.Command, []string) error { return nil },
+			}, got)
+			root.Flags().DurationVar(&got.Timeout, "timeout", got.Timeout, "")
+			root.Flags().Var(&got.MaxSize, "max-size", "")
+			root.Flags().Var(&got.Registry, "registry", "")
+
+			root.SetArgs(tt.args)
+			err := root.Execute()
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, *got)
+		})
+	}
+}
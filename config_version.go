@@ -0,0 +1,122 @@
+package clio
+
+import "fmt"
+
+// configVersionKey is the config file key declaring the version of the config format the file was written for.
+const configVersionKey = "config-version"
+
+// ConfigMigration updates the values of a config file from one config version to the next, in place (see
+// SetupConfig.WithConfigMigration).
+type ConfigMigration func(values map[string]any) error
+
+// ConfigVersionError is returned when a config file declares a config version which is not supported by the
+// application (see SetupConfig.WithConfigVersion).
+type ConfigVersionError struct {
+	File    string
+	Version int
+	Min     int // the oldest supported version
+	Max     int // the current version
+	AppName string
+}
+
+func (e *ConfigVersionError) Error() string {
+	if e.Version > e.Max {
+		return fmt.Sprintf("config file %s has %s %d, which is newer than this version of %s supports (%s), please upgrade %s",
+			e.File, configVersionKey, e.Version, e.AppName, e.supported(), e.AppName)
+	}
+	return fmt.Sprintf("config file %s has %s %d, which is no longer supported by this version of %s (%s), please update the config file",
+		e.File, configVersionKey, e.Version, e.AppName, e.supported())
+}
+
+func (e *ConfigVersionError) supported() string {
+	if e.Min == e.Max {
+		return fmt.Sprintf("only %d", e.Max)
+	}
+	return fmt.Sprintf("%d to %d", e.Min, e.Max)
+}
+
+// readConfigFile reads the config file (and all files it includes) with each file checked against the supported
+// config versions, and migrated to the current version as needed. Files are read (and migrated) once while loading
+// the configuration for a command, so that migration warnings are raised once.
+func (a *application) readConfigFile(file string) (*configLayer, error) {
+	if layer, ok := a.configLayers[file]; ok {
+		return layer.clone(), nil
+	}
+	layer, err := readConfigFileIncludes(file, a.setupConfig.ConfigDocument, nil, a.migrateConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	if a.configLayers == nil {
+		a.configLayers = map[string]*configLayer{}
+	}
+	a.configLayers[file] = layer.clone()
+	return layer, nil
+}
+
+// migrateConfigFile checks the config version declared by the file, migrating older (supported) versions to the
+// current version. Files that do not declare a version are expected to be for the current version.
+func (a *application) migrateConfigFile(file string, values map[string]any) (bool, error) {
+	current := a.setupConfig.ConfigVersion
+	if current == 0 {
+		return false, nil
+	}
+
+	raw, ok := values[configVersionKey]
+	delete(values, configVersionKey)
+	if !ok {
+		return false, nil
+	}
+	version, ok := configVersionValue(raw)
+	if !ok {
+		return false, fmt.Errorf("invalid %s in config file %s: expected a whole number, got %v", configVersionKey, file, raw)
+	}
+
+	minimum := a.setupConfig.MinConfigVersion
+	if minimum == 0 || minimum > current {
+		minimum = current
+	}
+	versionErr := &ConfigVersionError{File: file, Version: version, Min: minimum, Max: current, AppName: a.setupConfig.ID.Name}
+	if version > current {
+		return false, versionErr
+	}
+	if version < minimum {
+		// versions older than supported can only be read when there are migrations for every step up to the minimum
+		for v := version; v < minimum; v++ {
+			if a.setupConfig.ConfigMigrations[v] == nil {
+				return false, versionErr
+			}
+		}
+	}
+
+	migrated := false
+	for v := version; v < current; v++ {
+		migrate := a.setupConfig.ConfigMigrations[v]
+		if migrate == nil {
+			continue
+		}
+		if err := migrate(values); err != nil {
+			return false, fmt.Errorf("unable to migrate config file %s from %s %d to %d: %w", file, configVersionKey, v, v+1, err)
+		}
+		migrated = true
+	}
+	if !migrated {
+		return false, nil
+	}
+
+	a.state.Warn("config-migrated",
+		fmt.Sprintf("config file %s was written for %s %d and was migrated to %d, please update the file", DisplayPath(file), configVersionKey, version, current),
+		map[string]any{"file": file, "version": version, "current": current})
+	return true, nil
+}
+
+func configVersionValue(raw any) (int, bool) {
+	switch v := raw.(type) {
+	case int:
+		return v, true
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,177 @@
+package clio
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type versionTestConfig struct {
+	Registry struct {
+		URL string `mapstructure:"url"`
+	} `mapstructure:"registry"`
+	Name string `mapstructure:"name"`
+}
+
+func Test_Application_configVersion(t *testing.T) {
+	// version 1 had a top-level "registry-url", moved under "registry" in version 2
+	moveRegistryURL := func(values map[string]any) error {
+		url, ok := values["registry-url"]
+		if !ok {
+			return nil
+		}
+		delete(values, "registry-url")
+		values["registry"] = map[string]any{"url": url}
+		return nil
+	}
+	// version 2 called the name "title"
+	renameTitle := func(values map[string]any) error {
+		if title, ok := values["title"]; ok {
+			delete(values, "title")
+			values["name"] = title
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name         string
+		contents     string
+		migrations   map[int]ConfigMigration
+		want         versionTestConfig
+		wantErr      string
+		wantMigrated bool
+	}{
+		{
+			name:     "current version",
+			contents: "config-version: 3\nname: current\n",
+			want:     versionTestConfig{Name: "current"},
+		},
+		{
+			name:     "no version is read as the current version",
+			contents: "name: unversioned\n",
+			want:     versionTestConfig{Name: "unversioned"},
+		},
+		{
+			name:     "newer version",
+			contents: "config-version: 4\nname: future\n",
+			wantErr:  "has config-version 4, which is newer than this version of app supports (2 to 3), please upgrade app",
+		},
+		{
+			name:     "older version without migrations",
+			contents: "config-version: 1\nregistry-url: https://old\n",
+			wantErr:  "has config-version 1, which is no longer supported by this version of app (2 to 3)",
+		},
+		{
+			name:         "older version with migrations",
+			contents:     "config-version: 1\nregistry-url: https://old\ntitle: old\n",
+			migrations:   map[int]ConfigMigration{1: moveRegistryURL, 2: renameTitle},
+			wantMigrated: true,
+			want: func() (c versionTestConfig) {
+				c.Registry.URL = "https://old"
+				c.Name = "old"
+				return c
+			}(),
+		},
+		{
+			name:         "supported version with a migration",
+			contents:     "config-version: 2\ntitle: two\n",
+			migrations:   map[int]ConfigMigration{2: renameTitle},
+			wantMigrated: true,
+			want:         versionTestConfig{Name: "two"},
+		},
+		{
+			name:       "failed migration",
+			contents:   "config-version: 2\n",
+			migrations: map[int]ConfigMigration{2: func(map[string]any) error { return errors.New("bad") }},
+			wantErr:    "unable to migrate config file",
+		},
+		{
+			name:     "invalid version",
+			contents: "config-version: two\n",
+			wantErr:  "invalid config-version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "app.yaml")
+			require.NoError(t, os.WriteFile(file, []byte(tt.contents), 0o600))
+
+			setup := NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithConfigVersion(3, 2)
+			for from, m := range tt.migrations {
+				setup.WithConfigMigration(from, m)
+			}
+			setup.FangsConfig.File = file
+
+			app := New(*setup)
+			root := app.SetupRootCommand(&cobra.Command{})
+
+			cfg := &versionTestConfig{}
+			root.AddCommand(app.SetupCommand(&cobra.Command{
+				Use:  "sub",
+				RunE: func(cmd *cobra.Command, args []string) error { return nil },
+			}, cfg))
+
+			root.SetArgs([]string{"sub"})
+			err := root.Execute()
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, *cfg)
+
+			var migrated bool
+			for _, w := range app.(*application).State().Warnings() {
+				migrated = migrated || w.Code == "config-migrated"
+			}
+			assert.Equal(t, tt.wantMigrated, migrated)
+		})
+	}
+}
+
+func Test_ConfigVersionError(t *testing.T) {
+	err := &ConfigVersionError{File: "app.yaml", Version: 5, Min: 3, Max: 3, AppName: "app"}
+	assert.Equal(t, "config file app.yaml has config-version 5, which is newer than this version of app supports (only 3), please upgrade app", err.Error())
+}
+
+func Test_Application_configVersion_migratedOnce(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("config-version: 1\ntitle: old\n"), 0o600))
+
+	var migrations int
+	setup := NewSetupConfig(Identification{Name: "app"}).
+		WithNoBus().
+		WithStrictConfig().
+		WithConfigVersion(2, 1).
+		WithConfigMigration(1, func(values map[string]any) error {
+			migrations++
+			values["name"] = values["title"]
+			delete(values, "title")
+			return nil
+		})
+	setup.FangsConfig.File = file
+
+	app := New(*setup)
+	root := app.SetupRootCommand(&cobra.Command{})
+	cfg := &versionTestConfig{}
+	root.AddCommand(app.SetupCommand(&cobra.Command{
+		Use:  "sub",
+		RunE: func(cmd *cobra.Command, args []string) error { return nil },
+	}, cfg))
+
+	root.SetArgs([]string{"sub"})
+	require.NoError(t, root.Execute())
+	assert.Equal(t, "old", cfg.Name)
+
+	// the strict config check uses the migrated values rather than migrating again
+	assert.Equal(t, 1, migrations)
+	warnings := app.(*application).State().Warnings()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "config-migrated", warnings[0].Code)
+	assert.Equal(t, 1, warnings[0].Count)
+}
@@ -0,0 +1,356 @@
+package clio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// configCommand returns the "config" command (adding it to the root command on first use), which groups the built-in
+// commands for working with the application configuration.
+func (a *application) configCommand() *cobra.Command {
+	if a.configCmd == nil {
+		a.configCmd = &cobra.Command{
+			Use:   "config",
+			Short: "work with the application configuration",
+			Args:  cobra.NoArgs,
+		}
+		a.root.AddCommand(a.configCmd)
+	}
+	return a.configCmd
+}
+
+// setupConfigWizardCommand adds the "config wizard" command, which prompts for each value of the application
+// configuration and writes the result to a config file.
+func (a *application) setupConfigWizardCommand() {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "wizard",
+		Short: "interactively create a configuration file",
+		Long: "Prompt for each configuration value (the current value is the default, so running the wizard again " +
+			"edits an existing configuration) and write the result to a configuration file.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if output == "" {
+				output = a.setupConfig.FangsConfig.File
+			}
+			if output == "" {
+				output = fmt.Sprintf(".%s.yaml", a.setupConfig.ID.Name)
+			}
+			if err := a.runConfigWizard(cmd, output); err != nil {
+				return fmt.Errorf("unable to write configuration: %w", err)
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "configuration written to %s\n", DisplayPath(output))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "file", "f", "", "the config file to write (default: the --config file, or .<app>.yaml)")
+
+	a.configCommand().AddCommand(cmd)
+}
+
+// wizardField is a single config value prompted for by the config wizard.
+type wizardField struct {
+	configField
+	description string
+	allowed     []string
+	secret      bool
+}
+
+func (a *application) runConfigWizard(cmd *cobra.Command, output string) error {
+	// start from the current configuration (file, environment, and defaults)
+	if _, err := a.loadConfigs(cmd, false, a.state.Config.FromCommands...); err != nil {
+		return err
+	}
+
+	p := &wizardPrompter{
+		in:  bufio.NewReader(cmd.InOrStdin()),
+		out: cmd.OutOrStdout(),
+	}
+	if f, ok := cmd.InOrStdin().(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		p.terminal = f
+	}
+
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range a.wizardFields() {
+		if promptable(f.value) {
+			if err := p.prompt(f); err != nil {
+				return err
+			}
+		}
+		if err := setYAMLValue(doc, f.path, f.value); err != nil {
+			return err
+		}
+	}
+
+	if err := validateConfigValues(a.configTagName(), a.state.Config.FromCommands...); err != nil {
+		return err
+	}
+
+	contents, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	f, err := a.state.CreateFile(output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(contents)
+	return err
+}
+
+// wizardFields returns all fields of the registered command configs, in config graph order.
+func (a *application) wizardFields() []wizardField {
+	enums := a.collectEnumFields(a.state.Config.FromCommands...)
+	flags := allFlagsByRef(a.root)
+
+	var fields []wizardField
+	seen := map[uintptr]bool{}
+	for _, cfg := range a.state.Config.FromCommands {
+		visitConfigFields(a.configTagName(), reflect.ValueOf(cfg), nil, nil, func(ptr uintptr, f configField) {
+			if seen[ptr] || len(f.path) == 0 {
+				return
+			}
+			seen[ptr] = true
+
+			wf := wizardField{
+				configField: f,
+				allowed:     enums[ptr],
+				secret:      dotEnvSecretPattern.MatchString(f.path[len(f.path)-1]),
+			}
+			if flag, ok := flags[ptr]; ok {
+				wf.description = flag.Usage
+			}
+			fields = append(fields, wf)
+		})
+	}
+	return fields
+}
+
+// allFlagsByRef returns the flags of the command and all subcommands keyed by the address of the value they are bound to.
+func allFlagsByRef(cmd *cobra.Command) map[uintptr]*pflag.Flag {
+	refs := flagsByRef(cmd)
+	for _, sub := range cmd.Commands() {
+		for ref, flag := range allFlagsByRef(sub) {
+			if _, ok := refs[ref]; !ok {
+				refs[ref] = flag
+			}
+		}
+	}
+	return refs
+}
+
+// promptable indicates the value can be entered as a single line of text (lists and maps are written as-is).
+func promptable(v reflect.Value) bool {
+	if _, ok := v.Addr().Interface().(pflag.Value); ok {
+		return true
+	}
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// wizardPrompter asks for config values, one per line, re-prompting until each answer is valid.
+type wizardPrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	// set when reading from a terminal, in order to read secrets without echoing them
+	terminal *os.File
+	// once the input is exhausted all remaining values keep their current value
+	eof bool
+}
+
+func (p *wizardPrompter) prompt(f wizardField) error {
+	if f.description != "" {
+		_, _ = fmt.Fprintf(p.out, "# %s\n", f.description)
+	}
+	label := strings.Join(f.path, ".")
+	if len(f.allowed) > 0 {
+		label += enumUsage(f.allowed)
+	}
+
+	current := formatWizardValue(f.value)
+	if f.secret && current != "" {
+		current = strings.Repeat("*", 8)
+	}
+
+	for {
+		if current != "" {
+			_, _ = fmt.Fprintf(p.out, "%s [%s]: ", label, current)
+		} else {
+			_, _ = fmt.Fprintf(p.out, "%s: ", label)
+		}
+
+		answer, err := p.readLine(f.secret)
+		if err != nil {
+			return err
+		}
+		if answer == "" {
+			return nil
+		}
+
+		if len(f.allowed) > 0 {
+			err = validateEnum(answer, f.allowed)
+		}
+		if err == nil {
+			err = setWizardValue(f.value, answer)
+		}
+		if err == nil {
+			return nil
+		}
+		_, _ = fmt.Fprintf(p.out, "invalid value: %v\n", err)
+	}
+}
+
+func (p *wizardPrompter) readLine(secret bool) (string, error) {
+	if p.eof {
+		_, _ = fmt.Fprintln(p.out)
+		return "", nil
+	}
+	if secret && p.terminal != nil {
+		b, err := term.ReadPassword(int(p.terminal.Fd()))
+		_, _ = fmt.Fprintln(p.out)
+		return strings.TrimSpace(string(b)), err
+	}
+
+	line, err := p.in.ReadString('\n')
+	if errors.Is(err, io.EOF) {
+		p.eof = true
+		_, _ = fmt.Fprintln(p.out)
+		err = nil
+	}
+	return strings.TrimSpace(line), err
+}
+
+// formatWizardValue returns the value as it is entered (and shown as the default).
+func formatWizardValue(v reflect.Value) string {
+	if s, ok := v.Addr().Interface().(pflag.Value); ok {
+		return s.String()
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// setWizardValue parses the answer as the type of the value, allocating pointers as needed.
+func setWizardValue(v reflect.Value, answer string) error {
+	if s, ok := v.Addr().Interface().(pflag.Value); ok {
+		return s.Set(answer)
+	}
+	if v.Kind() == reflect.Ptr {
+		elem := reflect.New(v.Type().Elem())
+		if err := setWizardValue(elem.Elem(), answer); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(answer)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(answer)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(answer)
+		if err != nil {
+			return fmt.Errorf("expected true or false")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(answer, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected a whole number")
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(answer, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected a non-negative whole number")
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(answer, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected a number")
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	if cv, ok := v.Interface().(configValue); ok {
+		return cv.validateConfigValue()
+	}
+	return nil
+}
+
+// setYAMLValue sets the value at the path within the yaml mapping, creating intermediate mappings as needed (nil
+// values are omitted).
+func setYAMLValue(doc *yaml.Node, path []string, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil
+	}
+
+	node := doc
+	for i, key := range path {
+		var next *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == key {
+				next = node.Content[j+1]
+				break
+			}
+		}
+		if next == nil {
+			next = &yaml.Node{Kind: yaml.MappingNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, next)
+		}
+		if i == len(path)-1 {
+			value := v.Interface()
+			if _, ok := v.Addr().Interface().(pflag.Value); ok || v.Type() == reflect.TypeOf(time.Duration(0)) {
+				// e.g. durations and byte sizes are written as they are entered
+				value = formatWizardValue(v)
+			}
+			return next.Encode(value)
+		}
+		node = next
+	}
+	return nil
+}
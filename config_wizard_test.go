@@ -0,0 +1,93 @@
+package clio
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type wizardTestConfig struct {
+	Registry struct {
+		URL      URL    `mapstructure:"url"`
+		Password string `mapstructure:"password"`
+	} `mapstructure:"registry"`
+	Workers int           `mapstructure:"workers"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	Mode    string        `mapstructure:"mode"`
+	Tags    []string      `mapstructure:"tags"`
+}
+
+func (c *wizardTestConfig) DescribeEnumFields(set EnumFieldSet) {
+	set.Add(&c.Mode, "fast", "slow")
+}
+
+func Test_Application_configWizard(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "config.yaml")
+
+	cfg := NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithConfigWizardCommand()
+	app := New(*cfg)
+
+	root := app.SetupRootCommand(&cobra.Command{})
+	wizardCfg := &wizardTestConfig{Workers: 2, Mode: "fast", Tags: []string{"a"}}
+	scan := app.SetupCommand(&cobra.Command{
+		Use:  "scan",
+		RunE: func(*cobra.Command, []string) error { return nil },
+	}, wizardCfg)
+	scan.Flags().IntVar(&wizardCfg.Workers, "workers", wizardCfg.Workers, "the number of workers")
+	root.AddCommand(scan)
+
+	answers := []string{
+		"not-a-url",                // registry.url: rejected
+		"https://registry.example", // registry.url
+		"hunter2",                  // registry.password
+		"many",                     // workers: rejected
+		"",                         // workers: keep the default
+		"1m",                       // timeout
+		"medium",                   // mode: rejected
+		"slow",                     // mode
+	}
+	var out bytes.Buffer
+	root.SetIn(strings.NewReader(strings.Join(answers, "\n") + "\n"))
+	root.SetOut(&out)
+	root.SetArgs([]string{"config", "wizard", "--file", output})
+	require.NoError(t, root.Execute())
+
+	prompts := out.String()
+	assert.Contains(t, prompts, "# the number of workers\nworkers [2]: invalid value: expected a whole number\n")
+	assert.Contains(t, prompts, "mode (one of: fast, slow) [fast]: invalid value:")
+	assert.Contains(t, prompts, "configuration written to "+output)
+
+	contents, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, `registry:
+    url: https://registry.example
+    password: hunter2
+workers: 2
+timeout: 1m0s
+mode: slow
+tags:
+    - a
+`, string(contents))
+}
+
+func Test_wizardPrompter_secretDefaultIsMasked(t *testing.T) {
+	var out bytes.Buffer
+	p := &wizardPrompter{in: bufio.NewReader(strings.NewReader("")), out: &out}
+
+	password := "hunter2"
+	require.NoError(t, p.prompt(wizardField{
+		configField: configField{path: []string{"password"}, value: reflect.ValueOf(&password).Elem()},
+		secret:      true,
+	}))
+	assert.Equal(t, "password [********]: \n", out.String())
+	assert.Equal(t, "hunter2", password)
+}
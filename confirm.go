@@ -0,0 +1,191 @@
+package clio
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+// ErrNotConfirmed is returned when the user declines to confirm an operation (see State.Confirm).
+var ErrNotConfirmed = errors.New("not confirmed")
+
+// Confirmation describes the impact of a destructive or expensive operation, which the user is asked to confirm
+// before it is carried out (see State.Confirm and Application.RequiresConfirmation).
+type Confirmation struct {
+	// Summary is a template of the impact of the operation, rendered with the data (e.g. "delete {{ .count }} images
+	// ({{ humanBytes .size }})"), with the output template functions along with humanNumber and humanBytes (of int64
+	// values), and humanDuration. No confirmation is needed when empty (e.g. when there is nothing to delete).
+	Summary string
+	// Data is given to the summary template (e.g. estimated by counting what would be deleted).
+	Data any
+	// Destructive operations can't be undone, which the prompt says.
+	Destructive bool
+}
+
+// ConfirmationRequiredError is returned when an operation needs confirmation but there is no terminal to prompt on
+// (e.g. in CI), and --yes was not given.
+type ConfirmationRequiredError struct {
+	Summary string // the rendered summary of the operation
+}
+
+func (e *ConfirmationRequiredError) Error() string {
+	return fmt.Sprintf("%s: confirmation required, run with --yes to proceed non-interactively", e.Summary)
+}
+
+// Estimator returns the confirmation needed to run the command with the given args (see
+// Application.RequiresConfirmation), typically estimating the impact (e.g. what would be deleted).
+type Estimator func(ctx context.Context, s *State, args []string) (Confirmation, error)
+
+// confirmState is how operations are confirmed during the run.
+type confirmState struct {
+	yes         bool // --yes: assume all operations are confirmed
+	in          io.Reader
+	out         io.Writer
+	interactive func() bool // for testing
+}
+
+// WithConfirmations adds the --yes flag to all commands, to proceed without confirming operations (see State.Confirm).
+// This is only needed when operations are confirmed with State.Confirm, since commands declaring confirmation (see
+// Application.RequiresConfirmation) get the flag.
+func (c *SetupConfig) WithConfirmations() *SetupConfig {
+	return c.withPostConstructs(func(a *application) {
+		a.addYesFlag(a.root)
+	})
+}
+
+// RequiresConfirmation declares that the command (and all of its children) carries out a destructive or expensive
+// operation, which the user confirms before the command runs (after the configuration is loaded and any locks are
+// acquired). The estimator returns the impact of running the command with the given args, and the command runs
+// without asking when the summary is empty. The command gets a --yes flag to proceed without asking, and fails when
+// there is no terminal to ask on (see ConfirmationRequiredError).
+func (a *application) RequiresConfirmation(cmd *cobra.Command, estimate Estimator) {
+	if a.confirmations == nil {
+		a.confirmations = make(map[*cobra.Command][]Estimator)
+	}
+	a.confirmations[cmd] = append(a.confirmations[cmd], estimate)
+	a.addYesFlag(cmd)
+}
+
+// addYesFlag adds the --yes flag to the command (and its children), unless it already has it.
+func (a *application) addYesFlag(cmd *cobra.Command) {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.PersistentFlags().Lookup("yes") != nil {
+			return
+		}
+	}
+	if cmd.Flags().Lookup("yes") != nil {
+		return
+	}
+	usage := "proceed without confirming destructive or expensive operations"
+	if cmd.Flags().ShorthandLookup("y") != nil || cmd.PersistentFlags().ShorthandLookup("y") != nil {
+		cmd.PersistentFlags().BoolVar(&a.state.confirm.yes, "yes", false, usage)
+		return
+	}
+	cmd.PersistentFlags().BoolVarP(&a.state.confirm.yes, "yes", "y", false, usage)
+}
+
+// confirmCommand asks for the confirmations declared for the command (and its parents).
+func (a *application) confirmCommand(ctx context.Context, cmd *cobra.Command, args []string) error {
+	a.state.confirm.in = cmd.InOrStdin()
+	a.state.confirm.out = cmd.ErrOrStderr()
+
+	var estimators []Estimator
+	for c := cmd; c != nil; c = c.Parent() {
+		estimators = append(append([]Estimator(nil), a.confirmations[c]...), estimators...)
+	}
+	for _, estimate := range estimators {
+		c, err := estimate(ctx, &a.state, args)
+		if err != nil {
+			return fmt.Errorf("unable to estimate the impact of the command: %w", err)
+		}
+		if err := a.state.Confirm(ctx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Confirm asks the user to confirm the operation (which is skipped when the summary is empty), returning
+// ErrNotConfirmed when declined. Operations are confirmed without asking with --yes (see WithConfirmations), and fail
+// with a ConfirmationRequiredError when there is no terminal to ask on, or once the command has completed (see
+// PhaseTeardown). Prefer declaring the confirmation on the command (see Application.RequiresConfirmation), since
+// asking while the command runs interrupts the UI.
+func (s *State) Confirm(ctx context.Context, c Confirmation) error {
+	if c.Summary == "" {
+		return nil
+	}
+	summary, err := renderConfirmation(c)
+	if err != nil {
+		return err
+	}
+	if s.confirm.yes {
+		if log := s.currentLogger(); log != nil {
+			log.Infof("confirmed with --yes: %s", summary)
+		}
+		return nil
+	}
+	if PhaseFromContext(ctx) == PhaseTeardown || !s.confirmInteractive() {
+		return &ConfirmationRequiredError{Summary: summary}
+	}
+
+	out := s.confirm.out
+	if out == nil {
+		out = os.Stderr
+	}
+	msg := summary
+	if c.Destructive {
+		msg += color.Red.Sprint(" (this can't be undone)")
+	}
+	_, _ = fmt.Fprintf(out, "%s\ncontinue? [y/N] ", msg)
+
+	answer, err := bufio.NewReader(s.confirmInput()).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return ErrNotConfirmed
+}
+
+func (s *State) confirmInput() io.Reader {
+	if s.confirm.in == nil {
+		return os.Stdin
+	}
+	return s.confirm.in
+}
+
+// confirmInteractive indicates the user can be asked to confirm, which needs a terminal outside of CI.
+func (s *State) confirmInteractive() bool {
+	if s.confirm.interactive != nil {
+		return s.confirm.interactive()
+	}
+	f, ok := s.confirmInput().(*os.File)
+	return ok && isTerminal(f) && ciProvider() == ""
+}
+
+// renderConfirmation renders the summary template of the confirmation.
+func renderConfirmation(c Confirmation) (string, error) {
+	funcs := templateFuncs()
+	funcs["humanNumber"] = HumanNumber
+	funcs["humanBytes"] = HumanBytes
+	funcs["humanDuration"] = HumanDuration
+	tmpl, err := template.New("confirmation").Funcs(funcs).Parse(c.Summary)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse confirmation summary: %w", err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, c.Data); err != nil {
+		return "", fmt.Errorf("unable to render confirmation summary: %w", err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
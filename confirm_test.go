@@ -0,0 +1,97 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_State_Confirm(t *testing.T) {
+	deleteImages := Confirmation{
+		Summary:     "delete {{ .count }} images ({{ humanBytes .size }})",
+		Data:        map[string]any{"count": 3, "size": int64(512)},
+		Destructive: true,
+	}
+	interactive := true
+	newState := func(answer string) (*State, *bytes.Buffer) {
+		var out bytes.Buffer
+		s := &State{}
+		s.confirm.in = strings.NewReader(answer)
+		s.confirm.out = &out
+		s.confirm.interactive = func() bool { return interactive }
+		return s, &out
+	}
+
+	s, out := newState("y\n")
+	require.NoError(t, s.Confirm(context.Background(), deleteImages))
+	assert.Contains(t, out.String(), "delete 3 images (512 B)")
+	assert.Contains(t, out.String(), "continue? [y/N]")
+
+	s, _ = newState("\n")
+	require.ErrorIs(t, s.Confirm(context.Background(), deleteImages), ErrNotConfirmed)
+
+	// nothing to confirm
+	s, out = newState("")
+	require.NoError(t, s.Confirm(context.Background(), Confirmation{}))
+	assert.Empty(t, out.String())
+
+	// not asked once the command has completed
+	s, _ = newState("y\n")
+	err := s.Confirm(withPhase(context.Background(), PhaseTeardown), deleteImages)
+	var required *ConfirmationRequiredError
+	require.True(t, errors.As(err, &required))
+
+	interactive = false
+	s, _ = newState("y\n")
+	err = s.Confirm(context.Background(), deleteImages)
+	require.EqualError(t, err, "delete 3 images (512 B): confirmation required, run with --yes to proceed non-interactively")
+
+	s.confirm.yes = true
+	require.NoError(t, s.Confirm(context.Background(), deleteImages))
+}
+
+func Test_application_RequiresConfirmation(t *testing.T) {
+	run := func(args ...string) (bool, error) {
+		app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus())
+		root := app.SetupRootCommand(&cobra.Command{})
+
+		var ran bool
+		prune := &cobra.Command{
+			Use: "prune",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				ran = true
+				return nil
+			},
+		}
+		app.RequiresConfirmation(prune, func(_ context.Context, _ *State, args []string) (Confirmation, error) {
+			if len(args) > 0 && args[0] == "none" {
+				return Confirmation{}, nil
+			}
+			return Confirmation{Summary: "delete all cached data", Destructive: true}, nil
+		})
+		root.AddCommand(app.SetupCommand(prune))
+		root.SetIn(strings.NewReader(""))
+		root.SetErr(&bytes.Buffer{})
+		root.SetArgs(args)
+		err := root.Execute()
+		return ran, err
+	}
+
+	ran, err := run("prune")
+	require.ErrorContains(t, err, "delete all cached data: confirmation required")
+	assert.False(t, ran)
+
+	ran, err = run("prune", "--yes")
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	ran, err = run("prune", "none")
+	require.NoError(t, err)
+	assert.True(t, ran)
+}
@@ -0,0 +1,98 @@
+package clio
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/gookit/color"
+)
+
+// ansiEscapePattern matches ANSI escape sequences: CSI sequences (colors, cursor movement) and OSC sequences (titles, links).
+var ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)`)
+
+// StripANSI removes all ANSI escape sequences from the given string.
+func StripANSI(s string) string {
+	return ansiEscapePattern.ReplaceAllString(s, "")
+}
+
+// consoleMode describes the capabilities of a console attached to a file.
+type consoleMode struct {
+	console bool // the file is attached to a console
+	ansi    bool // the console renders ANSI escape sequences
+}
+
+var (
+	consoleModesLock sync.Mutex
+	consoleModes     = map[uintptr]consoleMode{}
+)
+
+// consoleModeOf returns the capabilities of the console attached to the file, enabling virtual terminal processing
+// on windows the first time the file is seen.
+func consoleModeOf(f *os.File) consoleMode {
+	consoleModesLock.Lock()
+	defer consoleModesLock.Unlock()
+
+	if m, ok := consoleModes[f.Fd()]; ok {
+		return m
+	}
+	m := enableVirtualTerminal(f)
+	consoleModes[f.Fd()] = m
+	return m
+}
+
+// ConsoleWriter returns a writer for the given console output (e.g. os.Stdout or os.Stderr) that renders colored
+// output correctly: on windows virtual terminal processing is enabled, and ANSI escape sequences are stripped on
+// legacy consoles that do not support it. Files not attached to a console are returned as-is.
+func ConsoleWriter(f *os.File) io.Writer {
+	if m := consoleModeOf(f); m.console && !m.ansi {
+		return &ansiStripWriter{w: f}
+	}
+	return f
+}
+
+// setupConsole prepares stdout and stderr for colored output, disabling color when either is attached to a legacy
+// console that would otherwise render escape sequences as garbage.
+func setupConsole() {
+	for _, f := range []*os.File{os.Stdout, os.Stderr} {
+		if m := consoleModeOf(f); m.console && !m.ansi {
+			color.Enable = false
+		}
+	}
+}
+
+// ansiStripWriter removes ANSI escape sequences from everything written through it. Note that each write is stripped
+// independently, so an escape sequence must not be split across writes.
+type ansiStripWriter struct {
+	w io.Writer
+}
+
+func (s *ansiStripWriter) Write(p []byte) (int, error) {
+	if _, err := s.w.Write(ansiEscapePattern.ReplaceAll(p, nil)); err != nil {
+		return 0, err
+	}
+	// report the original length, since the caller is unaware of what was stripped
+	return len(p), nil
+}
+
+// DisplayPath normalizes a path for display to the user: it is cleaned and uses the native path separator, and on
+// windows the extended-length prefix (\\?\) is removed.
+func DisplayPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	path = filepath.Clean(filepath.FromSlash(path))
+	if runtime.GOOS == "windows" {
+		switch {
+		case strings.HasPrefix(path, `\\?\UNC\`):
+			path = `\\` + strings.TrimPrefix(path, `\\?\UNC\`)
+		case strings.HasPrefix(path, `\\?\`):
+			path = strings.TrimPrefix(path, `\\?\`)
+		}
+	}
+	return path
+}
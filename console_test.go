@@ -0,0 +1,76 @@
+package clio
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StripANSI(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "plain",
+			input: "no escapes here",
+			want:  "no escapes here",
+		},
+		{
+			name:  "colors",
+			input: "\x1b[1;31merror\x1b[0m: failed",
+			want:  "error: failed",
+		},
+		{
+			name:  "cursor movement",
+			input: "\x1b[2K\x1b[1Aprogress 50%\x1b[?25l",
+			want:  "progress 50%",
+		},
+		{
+			name:  "hyperlink",
+			input: "\x1b]8;;https://example.com\x07link\x1b]8;;\x1b\\",
+			want:  "link",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, StripANSI(tt.input))
+		})
+	}
+}
+
+func Test_ansiStripWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := &ansiStripWriter{w: buf}
+
+	input := "\x1b[32mok\x1b[0m\n"
+	n, err := w.Write([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, len(input), n)
+	assert.Equal(t, "ok\n", buf.String())
+}
+
+func Test_ConsoleWriter_notAConsole(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.txt"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	// redirected output is left untouched
+	assert.Same(t, f, ConsoleWriter(f))
+}
+
+func Test_DisplayPath(t *testing.T) {
+	assert.Equal(t, "", DisplayPath(""))
+	assert.Equal(t, filepath.Join("a", "c"), DisplayPath("a/b/../c/"))
+
+	if runtime.GOOS == "windows" {
+		assert.Equal(t, `C:\dir\file.txt`, DisplayPath(`\\?\C:\dir\file.txt`))
+		assert.Equal(t, `\\server\share\file.txt`, DisplayPath(`\\?\UNC\server\share\file.txt`))
+	}
+}
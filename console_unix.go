@@ -0,0 +1,23 @@
+//go:build !windows
+
+package clio
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+func enableVirtualTerminal(f *os.File) consoleMode {
+	// unix terminals render escape sequences natively
+	return consoleMode{console: term.IsTerminal(int(f.Fd())), ansi: true}
+}
+
+func consoleUnicode() bool {
+	// without a locale the encoding is assumed to be ASCII (the "C" locale)
+	return false
+}
+
+func systemScreenReader() bool {
+	return false
+}
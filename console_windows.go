@@ -0,0 +1,61 @@
+//go:build windows
+
+package clio
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+func enableVirtualTerminal(f *os.File) consoleMode {
+	h := windows.Handle(f.Fd())
+
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		// not a console (e.g. redirected to a file or pipe)
+		return consoleMode{}
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return consoleMode{console: true, ansi: true}
+	}
+	if err := windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err != nil {
+		// legacy console (before windows 10) which cannot render escape sequences
+		return consoleMode{console: true}
+	}
+	return consoleMode{console: true, ansi: true}
+}
+
+// codePageUTF8 is the windows code page identifier for UTF-8
+const codePageUTF8 = 65001
+
+// spiGetScreenReader queries whether a screen reader is running (see SystemParametersInfo)
+const spiGetScreenReader = 0x0046
+
+var (
+	procGetConsoleOutputCP   = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetConsoleOutputCP")
+	procSystemParametersInfo = windows.NewLazySystemDLL("user32.dll").NewProc("SystemParametersInfoW")
+)
+
+// consoleUnicode indicates the console renders UTF-8: either within windows terminal, or when the console output
+// code page is UTF-8 (65001).
+func consoleUnicode() bool {
+	if os.Getenv("WT_SESSION") != "" {
+		return true
+	}
+	if procGetConsoleOutputCP.Find() != nil {
+		return false
+	}
+	cp, _, _ := procGetConsoleOutputCP.Call()
+	return cp == codePageUTF8
+}
+
+func systemScreenReader() bool {
+	if procSystemParametersInfo.Find() != nil {
+		return false
+	}
+	var running uint32
+	ret, _, _ := procSystemParametersInfo.Call(spiGetScreenReader, 0, uintptr(unsafe.Pointer(&running)), 0)
+	return ret != 0 && running != 0
+}
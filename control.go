@@ -0,0 +1,463 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/boss-net/go-logger"
+)
+
+// ErrCancelledByControl matches the error returned when a running command is cancelled with "ctl cancel" (or "ctl
+// shutdown"), see CancelledError.
+var ErrCancelledByControl = errors.New("cancelled by control request")
+
+// ControlStatus is the status of a running instance, as shown by "ctl status".
+type ControlStatus struct {
+	PID      int       `json:"pid" yaml:"pid"`
+	Name     string    `json:"name" yaml:"name"`
+	Version  string    `json:"version" yaml:"version"`
+	Command  string    `json:"command" yaml:"command"`
+	Started  time.Time `json:"started" yaml:"started"`
+	Uptime   string    `json:"uptime" yaml:"uptime"`
+	LogLevel string    `json:"logLevel" yaml:"logLevel"`
+}
+
+// controlServer serves the control API for a running instance over a unix socket (which is also supported on
+// windows 10 and later). The API is plain http:
+//
+//	GET  /status     the ControlStatus as json
+//	GET  /config     the (redacted) configuration
+//	PUT  /log-level  change the log level (the request body is the level)
+//	POST /cancel     cancel the running command
+//	POST /shutdown   cancel the running command and exit (for a daemon, stop serving)
+//	GET  /jobs       all jobs in the job queue as json (when the job queue is enabled)
+//	POST /jobs       add a job to the job queue (the request body is a Job with a type and payload)
+type controlServer struct {
+	app      *application
+	command  string
+	started  time.Time
+	cancel   func()
+	shutdown func()
+
+	// serializes log level changes
+	lock sync.Mutex
+}
+
+// controlSocketDir returns the directory where running instances create their control sockets (one per process).
+func (a *application) controlSocketDir() string {
+	if a.setupConfig.ControlSocketDir != "" {
+		return a.setupConfig.ControlSocketDir
+	}
+	dir, err := userCacheDir(a.setupConfig.ID.Name)
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, a.setupConfig.ID.Name, "ctl")
+}
+
+// startControl serves the control API (see SetupConfig.WithControlServer) until the returned function is called.
+func (a *application) startControl(command string, cancel, shutdown func()) (func(), error) {
+	if !a.setupConfig.ControlServer {
+		return func() {}, nil
+	}
+
+	dir := a.controlSocketDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("unable to create control socket dir: %w", err)
+	}
+
+	socket := filepath.Join(dir, fmt.Sprintf("%d.sock", os.Getpid()))
+	// a socket for this pid can only be left behind by a process that no longer exists
+	_ = os.Remove(socket)
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start control server: %w", err)
+	}
+	if err := os.Chmod(socket, 0o600); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("unable to restrict control socket: %w", err)
+	}
+
+	c := &controlServer{app: a, command: command, started: time.Now(), cancel: cancel, shutdown: shutdown}
+	server := &http.Server{Handler: c.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+		_ = os.Remove(socket)
+	}, nil
+}
+
+// withControl serves the control API while the command runs, returning the context for the command (which is
+// cancelled with "ctl cancel", see State.Cancel) and a function that stops the server once the command completes with
+// the given error (which is returned).
+func (a *application) withControl(cmd *cobra.Command) (context.Context, func(error) error, error) {
+	ctx, cancel := a.state.startCancellation(cmd.Context(), a.setupConfig.RunTimeout)
+
+	cancelByControl := func() {
+		a.state.Cancel(CancelRemote)
+	}
+
+	stop := func() {}
+	var err error
+	if !a.daemon.isServing() {
+		// the daemon serves the control API for all commands it runs
+		stop, err = a.startControl(cmd.CommandPath(), cancelByControl, cancelByControl)
+	}
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	return ctx, func(err error) error {
+		stop()
+		cancel()
+		return err
+	}, nil
+}
+
+func (c *controlServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", c.method(http.MethodGet, c.serveStatus))
+	mux.HandleFunc("/config", c.method(http.MethodGet, c.serveConfig))
+	mux.HandleFunc("/log-level", c.method(http.MethodPut, c.serveLogLevel))
+	mux.HandleFunc("/cancel", c.method(http.MethodPost, func(w http.ResponseWriter, _ *http.Request) {
+		c.cancel()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	mux.HandleFunc("/shutdown", c.method(http.MethodPost, func(w http.ResponseWriter, _ *http.Request) {
+		c.shutdown()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	if c.app.setupConfig.JobHandler != nil {
+		mux.HandleFunc("/jobs", c.serveJobs)
+	}
+	return mux
+}
+
+func (c *controlServer) serveJobs(w http.ResponseWriter, r *http.Request) {
+	q, err := c.app.state.JobQueue()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var result any
+	switch r.Method {
+	case http.MethodGet:
+		jobs, err := q.Jobs()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result = jobs
+	case http.MethodPost:
+		var req Job
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid job: %v", err), http.StatusBadRequest)
+			return
+		}
+		var payload any
+		if len(req.Payload) > 0 {
+			payload = req.Payload
+		}
+		job, err := q.Enqueue(req.Type, payload)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result = job
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+func (c *controlServer) method(method string, fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		fn(w, r)
+	}
+}
+
+func (c *controlServer) serveStatus(w http.ResponseWriter, _ *http.Request) {
+	status := ControlStatus{
+		PID:     os.Getpid(),
+		Name:    c.app.setupConfig.ID.Name,
+		Version: c.app.setupConfig.ID.Version,
+		Command: c.command,
+		Started: c.started,
+		Uptime:  time.Since(c.started).Round(time.Second).String(),
+	}
+	if c.app.state.currentConfig().Log != nil {
+		status.LogLevel = string(c.app.logLevel())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+func (c *controlServer) serveConfig(w http.ResponseWriter, _ *http.Request) {
+	// the configs are loaded again while setting up each command the daemon runs, so they are shown from a snapshot
+	snapshot := c.app.state.Snapshot()
+	cfgs := append([]any{&snapshot.Config}, snapshot.loadedConfigs...)
+	cfg := formatConfiguration(c.app.encodeConfigs(c.app.withAppHome(cfgs))...)
+	if snapshot.RedactStore != nil {
+		cfg = snapshot.RedactStore.RedactString(cfg)
+	}
+	w.Header().Set("Content-Type", "text/yaml")
+	_, _ = io.WriteString(w, cfg)
+}
+
+func (c *controlServer) serveLogLevel(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1024))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.app.setLogLevel(strings.TrimSpace(string(body))); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setLogLevel replaces the logger used by the application with one at the given level (see State.SetLogLevel).
+func (a *application) setLogLevel(level string) error {
+	lvl, err := logger.LevelFromString(level)
+	if err != nil {
+		return fmt.Errorf("invalid log level %q (available: %s)", level, logger.Levels())
+	}
+	if err := a.state.SetLogLevel(lvl); err != nil {
+		return err
+	}
+	a.state.currentLogger().Infof("log level changed to %s", lvl)
+	return nil
+}
+
+// logLevel returns the current log level (which may have been changed through the control API).
+func (a *application) logLevel() logger.Level {
+	if swappable, ok := a.state.currentLogger().(*swappableLogger); ok {
+		return swappable.currentLevel()
+	}
+	return a.state.currentConfig().Log.Level
+}
+
+// setupControlCommand adds the "ctl" command, which is the client for the control API of running instances.
+func (a *application) setupControlCommand() {
+	var pid int
+
+	ctl := &cobra.Command{
+		Use:   "ctl",
+		Short: "control running instances of " + a.setupConfig.ID.Name,
+		Args:  cobra.NoArgs,
+	}
+	ctl.PersistentFlags().IntVar(&pid, "pid", 0, "the process to control (required when more than one instance is running)")
+
+	request := func(method, path string, body io.Reader) ([]byte, error) {
+		client, err := a.controlClient(pid)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest(method, "http://ctl"+path, body)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("unable to reach instance: %w", err)
+		}
+		defer resp.Body.Close()
+		contents, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 300 {
+			return nil, fmt.Errorf("request failed: %s", strings.TrimSpace(string(contents)))
+		}
+		return contents, nil
+	}
+
+	show := func(method, path string) func(*cobra.Command, []string) error {
+		return func(cmd *cobra.Command, _ []string) error {
+			contents, err := request(method, path, nil)
+			if err != nil {
+				return err
+			}
+			_, err = cmd.OutOrStdout().Write(contents)
+			return err
+		}
+	}
+
+	ctl.AddCommand(
+		&cobra.Command{
+			Use:   "list",
+			Short: "list the process IDs of all running instances",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				for _, p := range a.runningInstances() {
+					fmt.Fprintln(cmd.OutOrStdout(), p)
+				}
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "status",
+			Short: "show the status of a running instance",
+			Args:  cobra.NoArgs,
+			RunE:  show(http.MethodGet, "/status"),
+		},
+		&cobra.Command{
+			Use:   "config",
+			Short: "show the configuration of a running instance",
+			Args:  cobra.NoArgs,
+			RunE:  show(http.MethodGet, "/config"),
+		},
+		&cobra.Command{
+			Use:   "log-level LEVEL",
+			Short: "change the log level of a running instance",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(_ *cobra.Command, args []string) error {
+				_, err := request(http.MethodPut, "/log-level", strings.NewReader(args[0]))
+				return err
+			},
+		},
+		&cobra.Command{
+			Use:   "cancel",
+			Short: "cancel the command being run by a running instance",
+			Args:  cobra.NoArgs,
+			RunE:  show(http.MethodPost, "/cancel"),
+		},
+		&cobra.Command{
+			Use:   "shutdown",
+			Short: "stop a running instance",
+			Args:  cobra.NoArgs,
+			RunE:  show(http.MethodPost, "/shutdown"),
+		},
+	)
+
+	if a.setupConfig.JobHandler != nil {
+		ctl.AddCommand(
+			&cobra.Command{
+				Use:   "jobs",
+				Short: "list the jobs in the job queue of a running instance",
+				Args:  cobra.NoArgs,
+				RunE:  show(http.MethodGet, "/jobs"),
+			},
+			&cobra.Command{
+				Use:   "enqueue TYPE [PAYLOAD]",
+				Short: "add a job to the job queue of a running instance (the payload is json)",
+				Args:  cobra.RangeArgs(1, 2),
+				RunE: func(cmd *cobra.Command, args []string) error {
+					job := Job{Type: args[0]}
+					if len(args) > 1 {
+						if !json.Valid([]byte(args[1])) {
+							return fmt.Errorf("invalid payload: must be json")
+						}
+						job.Payload = json.RawMessage(args[1])
+					}
+					body, err := json.Marshal(job)
+					if err != nil {
+						return err
+					}
+					contents, err := request(http.MethodPost, "/jobs", bytes.NewReader(body))
+					if err != nil {
+						return err
+					}
+					_, err = cmd.OutOrStdout().Write(contents)
+					return err
+				},
+			},
+		)
+	}
+
+	a.root.AddCommand(ctl)
+}
+
+// runningInstances returns the process IDs of all instances serving the control API, removing sockets left behind
+// by processes that have exited.
+func (a *application) runningInstances() []int {
+	dir := a.controlSocketDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var pids []int
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, ".sock") {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.TrimSuffix(name, ".sock"))
+		if err != nil {
+			continue
+		}
+		socket := filepath.Join(dir, name)
+		conn, err := net.DialTimeout("unix", socket, daemonDialTimeout)
+		if err != nil {
+			_ = os.Remove(socket)
+			continue
+		}
+		_ = conn.Close()
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	return pids
+}
+
+// controlClient returns an http client connected to the control socket of the given process (or the only running
+// instance when no process is given).
+func (a *application) controlClient(pid int) (*http.Client, error) {
+	if pid == 0 {
+		pids := a.runningInstances()
+		switch len(pids) {
+		case 0:
+			return nil, fmt.Errorf("no running instances of %s", a.setupConfig.ID.Name)
+		case 1:
+			pid = pids[0]
+		default:
+			return nil, fmt.Errorf("multiple instances are running, select one with --pid (one of: %s)", strings.Trim(fmt.Sprint(pids), "[]"))
+		}
+	}
+
+	socket := filepath.Join(a.controlSocketDir(), fmt.Sprintf("%d.sock", pid))
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,98 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type controlTestConfig struct {
+	Registry string `yaml:"registry" mapstructure:"registry"`
+}
+
+func Test_Application_control(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APP_REGISTRY", "example.com")
+
+	app := New(*NewSetupConfig(Identification{Name: "app", Version: "1.0"}).WithNoBus().WithControlServer(dir))
+	root := app.SetupRootCommand(&cobra.Command{})
+	started := make(chan struct{})
+	root.AddCommand(app.SetupCommand(&cobra.Command{
+		Use: "work",
+		RunE: app.RunWithState(func(ctx context.Context, state *State, _ []string) error {
+			close(started)
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(time.Millisecond):
+					// the log level may be changed while logging
+					state.Logger.Debug("working")
+				}
+			}
+		}),
+	}, &controlTestConfig{}))
+	root.SetArgs([]string{"work"})
+
+	result := make(chan error)
+	go func() {
+		result <- root.Execute()
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("command did not start")
+	}
+
+	ctl := func(args ...string) (string, error) {
+		client := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithControlServer(dir))
+		r := client.SetupRootCommand(&cobra.Command{})
+		var out bytes.Buffer
+		r.SetOut(&out)
+		r.SetArgs(append([]string{"ctl"}, args...))
+		err := r.Execute()
+		return out.String(), err
+	}
+
+	out, err := ctl("status")
+	require.NoError(t, err)
+	var status ControlStatus
+	require.NoError(t, json.Unmarshal([]byte(out), &status))
+	assert.Equal(t, "app work", status.Command)
+	assert.Equal(t, "1.0", status.Version)
+	assert.Equal(t, "warn", status.LogLevel)
+
+	out, err = ctl("config")
+	require.NoError(t, err)
+	assert.Contains(t, out, "registry: example.com")
+
+	_, err = ctl("log-level", "bogus")
+	require.ErrorContains(t, err, "invalid log level")
+
+	_, err = ctl("log-level", "debug")
+	require.NoError(t, err)
+	out, err = ctl("status")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(out), &status))
+	assert.Equal(t, "debug", status.LogLevel)
+
+	_, err = ctl("cancel")
+	require.NoError(t, err)
+
+	select {
+	case err := <-result:
+		require.ErrorIs(t, err, ErrCancelledByControl)
+	case <-time.After(5 * time.Second):
+		t.Fatal("command was not cancelled")
+	}
+
+	_, err = ctl("status")
+	require.ErrorContains(t, err, "no running instances")
+}
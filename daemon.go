@@ -0,0 +1,500 @@
+package clio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const daemonDialTimeout = time.Second
+
+// invocationArgs returns the command line forwarded to a running instance (overridden in tests).
+var invocationArgs = func() []string {
+	return os.Args[1:]
+}
+
+// Invocation is the command line, working directory, and environment of an invocation of the application, which is
+// forwarded to a running instance (see SetupConfig.WithDaemon and SetupConfig.WithSingleInstance).
+type Invocation struct {
+	Args []string `json:"args"`
+	Dir  string   `json:"dir"`
+	Env  []string `json:"env"`
+
+	// the standard streams of the invocation which are terminals ("stdin", "stdout", and "stderr")
+	Terminals []string `json:"terminals,omitempty"`
+
+	// the input of the invocation, which is streamed once the invocation is sent (see invocationInput)
+	Stdin io.Reader `json:"-"`
+
+	// the identification of the forwarding invocation, set when it negotiates versions with the running instance (see
+	// SetupConfig.WithDaemonVersionSkew)
+	Client *Identification `json:"client,omitempty"`
+}
+
+// invocationFrame is streamed back to the forwarding invocation for each write to stdout or stderr, ending with a frame with Done set.
+type invocationFrame struct {
+	Stdout []byte `json:"stdout,omitempty"`
+	Stderr []byte `json:"stderr,omitempty"`
+	Done   bool   `json:"done,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// set when the command failed after showing the error itself
+	ExitCode int `json:"exitCode,omitempty"`
+	// the identification of the running instance, sent first to invocations negotiating versions
+	Version *Identification `json:"version,omitempty"`
+}
+
+// invocationInput is streamed to the running instance for each read from stdin by the forwarding invocation, ending
+// with EOF set.
+type invocationInput struct {
+	Stdin []byte `json:"stdin,omitempty"`
+	EOF   bool   `json:"eof,omitempty"`
+}
+
+// daemon tracks client/daemon execution (see SetupConfig.WithDaemon).
+type daemon struct {
+	socket string
+	// serving is set within the daemon process, where commands run on behalf of other invocations are never forwarded
+	// again
+	serving bool
+	// restores the flags and configs of all commands before each command the daemon runs
+	baseline *commandsBaseline
+
+	lock sync.Mutex
+	// cancels the command currently being run by the daemon (if any)
+	cancelCommand func()
+}
+
+// invocationLock is held while the daemon runs a command, or a job of the job queue (see processJobs). Commands run one
+// at a time, since the commands (their flags and configs) and the State are shared, and jobs run in between, since
+// commands take over the working directory, environment, and standard streams of the process (see enterInvocation).
+var invocationLock sync.Mutex
+
+func (d *daemon) isServing() bool {
+	return d != nil && d.serving
+}
+
+// cancel cancels the command currently being run by the daemon (for the control API).
+func (d *daemon) cancel() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.cancelCommand != nil {
+		d.cancelCommand()
+	}
+}
+
+func (d *daemon) setCancel(cancel func()) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.cancelCommand = cancel
+}
+
+// daemonSocketPath returns the socket configured with SetupConfig.WithDaemon, defaulting to a location within the
+// user cache dir (which is only accessible to the current user).
+func (a *application) daemonSocketPath() string {
+	if a.setupConfig.DaemonSocket != "" {
+		return a.setupConfig.DaemonSocket
+	}
+	dir, err := userCacheDir(a.setupConfig.ID.Name)
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, a.setupConfig.ID.Name, "daemon.sock")
+}
+
+// setupDaemon adds the daemon command, which serves commands for subsequent invocations of the application.
+func (a *application) setupDaemon() {
+	a.daemon = &daemon{socket: a.daemonSocketPath()}
+	if a.root.Annotations == nil {
+		a.root.Annotations = map[string]string{}
+	}
+	a.root.Annotations[daemonSocketAnnotation] = a.daemon.socket
+	a.root.AddCommand(&cobra.Command{
+		Use:   "daemon",
+		Short: "run in the background, serving commands for other invocations",
+		Long: "Run in the background, serving commands for other invocations (which are forwarded to the daemon automatically).\n" +
+			"Commands run within the daemon one at a time, sharing what the daemon has set up (the bus, the store, and the initializers), " +
+			"with the working directory, environment, and standard streams of the invocation.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			// taken before the configuration is loaded, so that commands only get the configuration of their own invocation
+			a.daemon.baseline = snapshotCommands(a.root, append([]any{&a.state.Config}, a.state.Config.FromCommands...))
+
+			// the State is set up once, for all commands run by the daemon
+			err := a.Setup()(cmd, nil)
+			a.reportCobraMessages()
+			if err != nil {
+				return err
+			}
+			return a.serveDaemon(cmd.Context(), cmd.ErrOrStderr())
+		},
+	})
+}
+
+// dispatchToDaemon runs the command within the daemon when one is running, returning true when it has (in which case
+// the command returns the result from the daemon instead of running in this process).
+func (a *application) dispatchToDaemon(cmd *cobra.Command) bool {
+	d := a.daemon
+	if d == nil || d.isServing() || cmd.Name() == "daemon" {
+		return false
+	}
+
+	return forwardInvocation(d.socket, cmd, a.daemonVersionNegotiation())
+}
+
+// forwardInvocation sends the invocation to the instance listening on the given socket, showing the output. Returns
+// false when there is no running instance; otherwise the command returns the result from the running instance
+// instead of running in this process. The version of the running instance is checked first when negotiating.
+func forwardInvocation(socket string, cmd *cobra.Command, n *versionNegotiation) bool {
+	conn, err := net.DialTimeout("unix", socket, daemonDialTimeout)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	stdin, stdout, stderr := cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr()
+	dir, _ := os.Getwd()
+	req := Invocation{Args: invocationArgs(), Dir: dir, Env: os.Environ()}
+	if f, ok := stdin.(*os.File); ok && isTerminal(f) {
+		req.Terminals = append(req.Terminals, "stdin")
+	}
+	if isTerminal(stdout) {
+		req.Terminals = append(req.Terminals, "stdout")
+	}
+	if isTerminal(stderr) {
+		req.Terminals = append(req.Terminals, "stderr")
+	}
+	if n != nil {
+		req.Client = &n.client
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return false
+	}
+
+	dec := json.NewDecoder(conn)
+	var result error
+	if n != nil {
+		result = n.handshake(socket, conn, dec, stderr)
+	}
+	if result == nil {
+		go forwardInvocationInput(conn, stdin)
+		result = receiveInvocationOutput(dec, stdout, stderr)
+	}
+	cmd.Run = nil
+	cmd.RunE = func(*cobra.Command, []string) error {
+		return result
+	}
+	return true
+}
+
+// forwardInvocationInput sends the input of the invocation to the running instance as it is read (e.g. answers to
+// prompts), until the input ends or the connection is closed. Note that reading from a terminal only ends once the
+// user presses enter, so this is left running once the command is done.
+func forwardInvocationInput(conn io.Writer, stdin io.Reader) {
+	enc := json.NewEncoder(conn)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := stdin.Read(buf)
+		if n > 0 {
+			if enc.Encode(invocationInput{Stdin: buf[:n]}) != nil {
+				return
+			}
+		}
+		if err != nil {
+			_ = enc.Encode(invocationInput{EOF: true})
+			return
+		}
+	}
+}
+
+func receiveInvocationOutput(dec *json.Decoder, stdout, stderr io.Writer) error {
+	for {
+		var frame invocationFrame
+		if err := dec.Decode(&frame); err != nil {
+			return fmt.Errorf("lost connection to the running instance: %w", err)
+		}
+		_, _ = stdout.Write(frame.Stdout)
+		_, _ = stderr.Write(frame.Stderr)
+		if frame.Done {
+			if frame.ExitCode != 0 {
+				return &forwardedExitError{code: frame.ExitCode}
+			}
+			if frame.Error != "" {
+				return errors.New(frame.Error)
+			}
+			return nil
+		}
+	}
+}
+
+// serveDaemon accepts connections on the daemon socket until the context is cancelled.
+func (a *application) serveDaemon(ctx context.Context, stderr io.Writer) error {
+	d := a.daemon
+	if conn, err := net.DialTimeout("unix", d.socket, daemonDialTimeout); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("a daemon is already running (%s)", d.socket)
+	}
+	// remove any socket left behind by a daemon that did not exit cleanly
+	_ = os.Remove(d.socket)
+
+	if err := os.MkdirAll(filepath.Dir(d.socket), 0o700); err != nil {
+		return fmt.Errorf("unable to create daemon socket dir: %w", err)
+	}
+
+	listener, err := net.Listen("unix", d.socket)
+	if err != nil {
+		return fmt.Errorf("unable to listen on daemon socket: %w", err)
+	}
+	defer listener.Close()
+
+	if err := os.Chmod(d.socket, 0o600); err != nil {
+		return fmt.Errorf("unable to restrict daemon socket: %w", err)
+	}
+
+	d.serving = true
+	defer func() { d.serving = false }()
+
+	// the commands being run are cancelled (below) before waiting for them
+	var conns sync.WaitGroup
+	defer conns.Wait()
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	stopControl, err := a.startControl("daemon", d.cancel, stop)
+	if err != nil {
+		return err
+	}
+	defer stopControl()
+
+	stopJobs, err := a.processJobs(ctx)
+	if err != nil {
+		return err
+	}
+	defer stopJobs()
+
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+
+	fmt.Fprintf(stderr, "serving commands on %s\n", d.socket)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("unable to accept daemon connection: %w", err)
+		}
+		// connections are served while a command runs (e.g. probing the version of the daemon), though commands
+		// themselves run one at a time
+		conns.Add(1)
+		go func() {
+			defer conns.Done()
+			a.serveDaemonConn(ctx, conn)
+		}()
+	}
+}
+
+func (a *application) serveDaemonConn(ctx context.Context, conn net.Conn) {
+	serveInvocation(ctx, conn, a.setupConfig.ID, func(ctx context.Context, req Invocation, out *invocationWriter) error {
+		invocationLock.Lock()
+		defer invocationLock.Unlock()
+		if ctx.Err() != nil {
+			// the daemon stopped while waiting for the previous command
+			return ctx.Err()
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		a.daemon.setCancel(func() {
+			a.state.Cancel(CancelRemote)
+			cancel()
+		})
+		defer a.daemon.setCancel(nil)
+		return a.runDaemonRequest(ctx, req, out)
+	})
+}
+
+// serveInvocation reads a forwarded invocation from the connection and handles it, sending the result back. The
+// identification of this instance is sent first to invocations negotiating versions, which may decline to run.
+func serveInvocation(ctx context.Context, conn net.Conn, id Identification, handle func(context.Context, Invocation, *invocationWriter) error) {
+	defer conn.Close()
+
+	dec := json.NewDecoder(conn)
+	var req Invocation
+	if err := dec.Decode(&req); err != nil {
+		return
+	}
+
+	out := &invocationWriter{enc: json.NewEncoder(conn)}
+	if req.Client != nil && !acknowledgeVersion(dec, out, id) {
+		return
+	}
+
+	// the input of the invocation follows, and the forwarding invocation going away (e.g. an interrupt) cancels the
+	// handling
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stdin, input := io.Pipe()
+	defer stdin.Close()
+	req.Stdin = stdin
+	go func() {
+		defer cancel()
+		defer input.Close()
+		for {
+			var msg invocationInput
+			if err := dec.Decode(&msg); err != nil {
+				return
+			}
+			if len(msg.Stdin) > 0 {
+				_, _ = input.Write(msg.Stdin)
+			}
+			if msg.EOF {
+				_ = input.Close()
+			}
+		}
+	}()
+
+	err := handle(ctx, req, out)
+
+	done := invocationFrame{Done: true}
+	var exitErr *forwardedExitError
+	switch {
+	case errors.As(err, &exitErr):
+		done.ExitCode = exitErr.code
+	case err != nil:
+		done.Error = err.Error()
+	}
+	out.send(done)
+}
+
+// runDaemonRequest runs the command line from the request within the daemon, with the working directory, environment,
+// and standard streams of the request (see enterInvocation), sending all output to the client. The flags and configs of
+// all commands are restored first, so that nothing is left over from the previous command.
+func (a *application) runDaemonRequest(ctx context.Context, req Invocation, out *invocationWriter) error {
+	a.daemon.baseline.restore()
+
+	root := a.root
+	in, stdout, stderr := root.InOrStdin(), root.OutOrStdout(), root.ErrOrStderr()
+	leave, err := enterInvocation(req, out)
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	root.SetIn(os.Stdin)
+	root.SetOut(&a.cobraMessages)
+	root.SetErr(os.Stderr)
+	restoreMessages := a.cobraMessages.redirect(os.Stdout)
+	root.SetArgs(req.Args)
+	defer func() {
+		root.SetArgs(nil)
+		restoreMessages()
+		root.SetIn(in)
+		root.SetOut(stdout)
+		root.SetErr(stderr)
+	}()
+
+	err = root.ExecuteContext(ctx)
+	if err == nil {
+		return nil
+	}
+	a.presentError(os.Stderr, err)
+	if ctx.Err() != nil {
+		return &forwardedExitError{code: ExitCodeInterrupted}
+	}
+	return &forwardedExitError{code: a.exitCode(err)}
+}
+
+// forwardedTerminals are the standard streams which are terminals for the invocation a command is run on behalf of
+// (see enterInvocation), since the streams of the command itself are connected to the daemon. This is nil when not
+// run on behalf of another invocation. Guarded by presentationLock.
+var forwardedTerminals map[*os.File]bool
+
+// setForwardedTerminals records which of the standard streams (as currently set) are terminals for the invocation a
+// command is run on behalf of, given the streams listed by the invocation. Nil clears this.
+func setForwardedTerminals(terminals []string) {
+	presentationLock.Lock()
+	defer presentationLock.Unlock()
+
+	if terminals == nil {
+		forwardedTerminals = nil
+		return
+	}
+	forwardedTerminals = map[*os.File]bool{
+		os.Stdin:  contains(terminals, "stdin"),
+		os.Stdout: contains(terminals, "stdout"),
+		os.Stderr: contains(terminals, "stderr"),
+	}
+}
+
+// forwardedTerminal indicates if the file is a terminal for the invocation the command is run on behalf of, with ok
+// set when this is known.
+func forwardedTerminal(f *os.File) (terminal, ok bool) {
+	presentationLock.RLock()
+	defer presentationLock.RUnlock()
+	terminal, ok = forwardedTerminals[f]
+	return terminal, ok
+}
+
+// forwardedExitError is returned when a forwarded command fails, with the exit code of the command (which has shown
+// the error already).
+type forwardedExitError struct {
+	code int
+}
+
+var _ ExitCoder = (*forwardedExitError)(nil)
+
+func (e *forwardedExitError) Error() string {
+	return fmt.Sprintf("exited with code %d", e.code)
+}
+
+func (e *forwardedExitError) ExitCode() int {
+	return e.code
+}
+
+// invocationWriter sends output frames to the client.
+type invocationWriter struct {
+	lock sync.Mutex
+	enc  *json.Encoder
+}
+
+func (w *invocationWriter) send(frame invocationFrame) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	_ = w.enc.Encode(frame)
+}
+
+// stream returns a writer for stdout (or stderr) of the forwarding invocation.
+func (w *invocationWriter) stream(stderr bool) io.Writer {
+	return invocationStream{out: w, stderr: stderr}
+}
+
+type invocationStream struct {
+	out    *invocationWriter
+	stderr bool
+}
+
+func (s invocationStream) Write(p []byte) (int, error) {
+	by := append([]byte{}, p...)
+	if s.stderr {
+		s.out.send(invocationFrame{Stderr: by})
+	} else {
+		s.out.send(invocationFrame{Stdout: by})
+	}
+	return len(p), nil
+}
@@ -0,0 +1,277 @@
+package clio
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// enterInvocation gives the process the working directory, environment, and standard streams of the invocation (see
+// runDaemonRequest) until the returned function is called, which returns once all output has been sent. These are
+// shared by everything running in the daemon, so this must only be called while holding invocationLock.
+func enterInvocation(req Invocation, out *invocationWriter) (func(), error) {
+	var undo []func()
+	leave := func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}
+
+	if req.Dir != "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get working directory: %w", err)
+		}
+		if err := os.Chdir(req.Dir); err != nil {
+			return nil, fmt.Errorf("unable to enter the working directory of the invocation: %w", err)
+		}
+		undo = append(undo, func() { _ = os.Chdir(wd) })
+	}
+
+	env := os.Environ()
+	setEnviron(req.Env)
+	undo = append(undo, func() { setEnviron(env) })
+
+	stdin, closeStdin, err := pipeInvocationInput(req.Stdin)
+	if err != nil {
+		leave()
+		return nil, fmt.Errorf("unable to forward stdin: %w", err)
+	}
+	undo = append(undo, closeStdin)
+
+	stdout, closeStdout, err := pipeInvocationOutput(out, false)
+	if err != nil {
+		leave()
+		return nil, fmt.Errorf("unable to forward stdout: %w", err)
+	}
+	undo = append(undo, closeStdout)
+
+	stderr, closeStderr, err := pipeInvocationOutput(out, true)
+	if err != nil {
+		leave()
+		return nil, fmt.Errorf("unable to forward stderr: %w", err)
+	}
+	undo = append(undo, closeStderr)
+
+	originalStdin, originalStdout, originalStderr := os.Stdin, os.Stdout, os.Stderr
+	os.Stdin, os.Stdout, os.Stderr = stdin, stdout, stderr
+	setForwardedTerminals(append([]string{}, req.Terminals...))
+	undo = append(undo, func() {
+		setForwardedTerminals(nil)
+		os.Stdin, os.Stdout, os.Stderr = originalStdin, originalStdout, originalStderr
+	})
+
+	return leave, nil
+}
+
+// setEnviron replaces the environment of the process.
+func setEnviron(env []string) {
+	os.Clearenv()
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok && k != "" {
+			_ = os.Setenv(k, v)
+		}
+	}
+}
+
+// pipeInvocationInput returns a file reading the input of the invocation, and a function closing it.
+func pipeInvocationInput(in io.Reader) (*os.File, func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	go func() {
+		if in != nil {
+			// ends once the reader is closed, or the input ends
+			_, _ = io.Copy(w, in)
+		}
+		_ = w.Close()
+	}()
+	return r, func() { _ = r.Close() }, nil
+}
+
+// pipeInvocationOutput returns a file where everything written is sent to the client as stdout (or stderr), and a
+// function closing it, which returns once everything written has been sent.
+func pipeInvocationOutput(out *invocationWriter, stderr bool) (*os.File, func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		_, _ = io.Copy(out.stream(stderr), r)
+		_ = r.Close()
+	}()
+	return w, func() {
+		_ = w.Close()
+		<-sent
+	}, nil
+}
+
+// commandsBaseline restores the flags and configs of all commands to how they were before the daemon ran any command,
+// since each command the daemon runs parses its flags and loads its configuration into the same values.
+type commandsBaseline struct {
+	root    *cobra.Command
+	configs []configSnapshot
+}
+
+type configSnapshot struct {
+	cfg   reflect.Value // the pointer to the config
+	saved reflect.Value
+}
+
+// snapshotCommands takes the baseline of the commands from the root, and the given configs (pointers).
+func snapshotCommands(root *cobra.Command, cfgs []any) *commandsBaseline {
+	b := &commandsBaseline{root: root}
+	for _, cfg := range cfgs {
+		v := reflect.ValueOf(cfg)
+		if v.Kind() != reflect.Ptr || v.IsNil() {
+			continue
+		}
+		b.configs = append(b.configs, configSnapshot{cfg: v, saved: deepCopy(v.Elem())})
+	}
+	return b
+}
+
+// restore restores all configs, then resets all commands (which sets the config fields their flags are bound to).
+// Configs are restored in place, since flags are bound to their fields; unexported fields are left as they are.
+func (b *commandsBaseline) restore() {
+	if b == nil {
+		return
+	}
+	for _, c := range b.configs {
+		restoreValue(c.cfg.Elem(), c.saved)
+	}
+	resetCommands(b.root)
+}
+
+// resetCommands resets the flags of the command and all of its children to their defaults, and clears the context of
+// the previous command.
+func resetCommands(cmd *cobra.Command) {
+	for _, flags := range []*pflag.FlagSet{cmd.PersistentFlags(), cmd.Flags()} {
+		flags.VisitAll(resetFlag)
+	}
+	cmd.SetContext(nil) //nolint:staticcheck // cobra only gives the context of the root to commands without one
+	for _, c := range cmd.Commands() {
+		resetCommands(c)
+	}
+}
+
+func resetFlag(f *pflag.Flag) {
+	if r, ok := f.Value.(*resetSliceValue); ok {
+		f.Value = r.sliceValue
+	}
+	if s, ok := f.Value.(sliceValue); ok {
+		// slices render their default as "[a,b]", and setting a slice flag appends to it
+		var values []string
+		if def := strings.Trim(f.DefValue, "[]"); def != "" {
+			values = strings.Split(def, ",")
+		}
+		_ = s.Replace(values)
+		f.Value = &resetSliceValue{sliceValue: s, flag: f}
+	} else {
+		_ = f.Value.Set(f.DefValue)
+	}
+	f.Changed = false
+}
+
+// sliceValue is the value of a slice flag (e.g. StringSlice).
+type sliceValue interface {
+	pflag.Value
+	pflag.SliceValue
+}
+
+// resetSliceValue is the value of a slice flag which has been reset to its default (see resetFlag). pflag replaces the
+// default with the first value given (rather than appending to it) until the flag has been set, which it offers no way
+// to reset, so the slice is emptied before the first value is set instead (the flag then gets its value back).
+type resetSliceValue struct {
+	sliceValue
+	flag *pflag.Flag
+}
+
+func (v *resetSliceValue) Set(value string) error {
+	if err := v.sliceValue.Replace(nil); err != nil {
+		return err
+	}
+	v.flag.Value = v.sliceValue
+	return v.sliceValue.Set(value)
+}
+
+// restoreValue sets dst to a copy of src, following pointers rather than replacing them.
+func restoreValue(dst, src reflect.Value) {
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if !dst.IsNil() && !src.IsNil() {
+			restoreValue(dst.Elem(), src.Elem())
+			return
+		}
+	case reflect.Struct:
+		if hasExportedFields(dst.Type()) {
+			for i := 0; i < dst.NumField(); i++ {
+				if dst.Field(i).CanSet() {
+					restoreValue(dst.Field(i), src.Field(i))
+				}
+			}
+			return
+		}
+	}
+	dst.Set(deepCopy(src))
+}
+
+// deepCopy copies the value, along with everything it references through exported fields (unexported fields are
+// copied as-is).
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return c
+	}
+	return v
+}
+
+func hasExportedFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			return true
+		}
+	}
+	return false
+}
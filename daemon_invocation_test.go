@@ -0,0 +1,46 @@
+package clio
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_commandsBaseline_restore(t *testing.T) {
+	type nested struct {
+		Depth int
+	}
+	type config struct {
+		Name   string
+		Tags   []string
+		Nested *nested
+	}
+	cfg := &config{Name: "default", Nested: &nested{Depth: 1}}
+
+	root := &cobra.Command{Use: "app"}
+	sub := &cobra.Command{Use: "sub"}
+	root.AddCommand(sub)
+	sub.Flags().IntVar(&cfg.Nested.Depth, "depth", cfg.Nested.Depth, "")
+	sub.Flags().StringSliceVar(&cfg.Tags, "tag", []string{"a"}, "")
+	baseline := snapshotCommands(root, []any{cfg})
+
+	require.NoError(t, sub.Flags().Parse([]string{"--depth", "3", "--tag", "b"}))
+	cfg.Name = "changed"
+	bound := cfg.Nested
+	baseline.restore()
+
+	assert.Equal(t, "default", cfg.Name)
+	assert.Equal(t, []string{"a"}, cfg.Tags)
+	assert.Equal(t, 1, cfg.Nested.Depth)
+	assert.False(t, sub.Flags().Changed("depth"))
+	// the flags are still bound to the config
+	assert.Same(t, bound, cfg.Nested)
+	assert.Equal(t, reflect.ValueOf(&cfg.Tags).Pointer(), flagRef(sub.Flags().Lookup("tag")))
+
+	require.NoError(t, sub.Flags().Parse([]string{"--depth", "4", "--tag", "c"}))
+	assert.Equal(t, 4, cfg.Nested.Depth)
+	assert.Equal(t, []string{"c"}, cfg.Tags)
+}
@@ -0,0 +1,177 @@
+package clio
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// daemonTestCommands tracks the commands of newDaemonTestApp.
+type daemonTestCommands struct {
+	ran     int32         // set once a command runs
+	waiting chan struct{} // closed once the wait command runs
+	release chan struct{} // ends the wait command
+}
+
+func newDaemonTestApp(socket string, cmds *daemonTestCommands) (Application, *cobra.Command) {
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithDaemon(socket))
+	root := app.SetupRootCommand(&cobra.Command{})
+
+	var upper bool
+	echo := &cobra.Command{
+		Use: "echo",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			atomic.StoreInt32(&cmds.ran, 1)
+			if len(args) == 0 {
+				return fmt.Errorf("nothing to echo")
+			}
+			out := strings.Join(args, " ")
+			if upper {
+				out = strings.ToUpper(out)
+			}
+			wd, _ := os.Getwd()
+			fmt.Fprintln(cmd.OutOrStdout(), out, os.Getenv("APP_GREETING"), filepath.Base(wd), isTerminal(os.Stdout))
+			return nil
+		},
+	}
+	echo.Flags().BoolVar(&upper, "upper", false, "")
+
+	ask := &cobra.Command{
+		Use: "ask",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			atomic.StoreInt32(&cmds.ran, 1)
+			fmt.Fprint(cmd.ErrOrStderr(), "name? ")
+			answer, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "hello %s", answer)
+			return nil
+		},
+	}
+
+	wait := &cobra.Command{
+		Use: "wait",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			atomic.StoreInt32(&cmds.ran, 1)
+			close(cmds.waiting)
+			<-cmds.release
+			fmt.Fprintln(cmd.OutOrStdout(), "done")
+			return nil
+		},
+	}
+
+	root.AddCommand(app.SetupCommand(echo), app.SetupCommand(ask), app.SetupCommand(wait))
+	return app, root
+}
+
+func Test_Application_daemon(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "d.sock")
+
+	inDaemon := &daemonTestCommands{waiting: make(chan struct{}), release: make(chan struct{})}
+	_, daemonRoot := newDaemonTestApp(socket, inDaemon)
+	daemonRoot.SetArgs([]string{"daemon"})
+	daemonRoot.SetErr(io.Discard)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() {
+		served <- daemonRoot.ExecuteContext(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("unix", socket)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	run := func(stdin string, args ...string) (string, int) {
+		originalArgs := invocationArgs
+		defer func() { invocationArgs = originalArgs }()
+		invocationArgs = func() []string { return args }
+
+		locally := &daemonTestCommands{}
+		app, root := newDaemonTestApp(socket, locally)
+		var stdout, stderr bytes.Buffer
+		root.SetIn(strings.NewReader(stdin))
+		root.SetOut(&stdout)
+		root.SetErr(&stderr)
+		root.SetArgs(args)
+		code := app.Execute(context.Background())
+		assert.Zero(t, atomic.LoadInt32(&locally.ran))
+		return stdout.String() + stderr.String(), code
+	}
+
+	t.Setenv("APP_GREETING", "from-client")
+	dir := filepath.Join(t.TempDir(), "client-dir")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer func() { require.NoError(t, os.Chdir(wd)) }()
+
+	out, code := run("", "echo", "--upper", "hello")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "HELLO from-client client-dir false\n", out)
+
+	// each command runs with the flags and environment of its own invocation
+	t.Setenv("APP_GREETING", "changed")
+	out, code = run("", "echo", "hello")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "hello changed client-dir false\n", out)
+
+	// the error is shown by the daemon, with the exit code of the command
+	out, code = run("", "echo")
+	assert.Equal(t, ExitCodeError, code)
+	assert.Contains(t, out, "nothing to echo")
+	assert.Equal(t, 1, strings.Count(out, "nothing to echo"))
+
+	// the input of the invocation is forwarded
+	out, code = run("world\n", "ask")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "hello world\nname? ", out)
+
+	// connections are served while a command runs
+	waited := make(chan string)
+	go func() {
+		out, _ := run("", "wait")
+		waited <- out
+	}()
+	<-inDaemon.waiting
+	assert.NotNil(t, probeDaemonVersion(socket, Identification{Name: "app"}))
+	close(inDaemon.release)
+	assert.Equal(t, "done\n", <-waited)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&inDaemon.ran))
+
+	cancel()
+	select {
+	case err := <-served:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon did not stop")
+	}
+}
+
+func Test_Application_daemon_notRunning(t *testing.T) {
+	locally := &daemonTestCommands{}
+	_, root := newDaemonTestApp(filepath.Join(t.TempDir(), "d.sock"), locally)
+	root.SetOut(io.Discard)
+	root.SetArgs([]string{"echo", "hello"})
+	require.NoError(t, root.Execute())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&locally.ran))
+}
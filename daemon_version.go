@@ -0,0 +1,154 @@
+package clio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/gookit/color"
+)
+
+// VersionSkewPolicy is how an invocation handles a running daemon of an incompatible version (see
+// SetupConfig.WithDaemonVersionSkew).
+type VersionSkewPolicy string
+
+const (
+	// VersionSkewWarn forwards the invocation to the daemon anyway, showing a warning (the default).
+	VersionSkewWarn VersionSkewPolicy = "warn"
+	// VersionSkewRefuse fails the invocation with a VersionSkewError, without running the command.
+	VersionSkewRefuse VersionSkewPolicy = "refuse"
+)
+
+// VersionCompatibility indicates if an invocation of the client version may be run by a daemon of the daemon version.
+type VersionCompatibility func(client, daemon Identification) bool
+
+// SameVersion is the default VersionCompatibility, where any difference in the version is incompatible.
+func SameVersion(client, daemon Identification) bool {
+	return client.Version == daemon.Version
+}
+
+// VersionSkewError is returned when the running daemon is of a version incompatible with the invocation, and the
+// policy is VersionSkewRefuse.
+type VersionSkewError struct {
+	Client string // the version of the invocation
+	Daemon string // the version of the running daemon
+	Socket string // where the daemon is serving
+}
+
+func (e *VersionSkewError) Error() string {
+	return fmt.Sprintf("the running daemon (%s) is incompatible with this invocation (%s): restart the daemon (serving on %s)",
+		displayVersion(e.Daemon), displayVersion(e.Client), e.Socket)
+}
+
+func displayVersion(v string) string {
+	if v == "" {
+		return "unknown version"
+	}
+	return "version " + v
+}
+
+// WithDaemonVersionSkew sets how invocations handle a running daemon (see WithDaemon) of an incompatible version,
+// which is typically left running across an upgrade of the application. The versions are negotiated when connecting,
+// and are incompatible when they differ unless a compatibility check is given (e.g. only comparing major versions).
+// The version command also shows the version of the running daemon.
+func (c *SetupConfig) WithDaemonVersionSkew(policy VersionSkewPolicy, compatible VersionCompatibility) *SetupConfig {
+	c.DaemonVersionSkew = policy
+	c.DaemonVersionCompatible = compatible
+	return c
+}
+
+// daemonSocketAnnotation is set on the root command to the socket of the daemon (see SetupConfig.WithDaemon), where
+// the version command finds the version of the running daemon.
+const daemonSocketAnnotation = "clio.daemon-socket"
+
+// versionNegotiation is how an invocation checks the version of the running instance it is forwarded to.
+type versionNegotiation struct {
+	client     Identification
+	policy     VersionSkewPolicy
+	compatible VersionCompatibility
+}
+
+// daemonVersionNegotiation returns how invocations forwarded to the daemon check its version.
+func (a *application) daemonVersionNegotiation() *versionNegotiation {
+	n := &versionNegotiation{
+		client:     a.setupConfig.ID,
+		policy:     a.setupConfig.DaemonVersionSkew,
+		compatible: a.setupConfig.DaemonVersionCompatible,
+	}
+	if n.policy == "" {
+		n.policy = VersionSkewWarn
+	}
+	if n.compatible == nil {
+		n.compatible = SameVersion
+	}
+	return n
+}
+
+// invocationAck is sent once the invocation has checked the version of the running instance, to either run the
+// command or not.
+type invocationAck struct {
+	Proceed bool `json:"proceed"`
+}
+
+// negotiate checks the version of the running instance (the first frame it sends), returning an error when the
+// invocation must not be run by it. Warnings are shown on stderr.
+func (n *versionNegotiation) negotiate(socket string, running Identification, stderr io.Writer) error {
+	if n.compatible(n.client, running) {
+		return nil
+	}
+	if n.policy == VersionSkewRefuse {
+		return &VersionSkewError{Client: n.client.Version, Daemon: running.Version, Socket: socket}
+	}
+	_, _ = fmt.Fprintln(stderr, color.Yellow.Sprintf("warning: the running daemon (%s) differs from this invocation (%s), restart the daemon to use the same version",
+		displayVersion(running.Version), displayVersion(n.client.Version)))
+	return nil
+}
+
+// acknowledgeVersion reads the version of the running instance from the invocation, letting the invocation decide
+// whether it is run (see versionNegotiation). Returns false when the command must not be run.
+func acknowledgeVersion(dec *json.Decoder, out *invocationWriter, id Identification) bool {
+	out.send(invocationFrame{Version: &id})
+	var ack invocationAck
+	if err := dec.Decode(&ack); err != nil {
+		return false
+	}
+	return ack.Proceed
+}
+
+// probeDaemonVersion returns the identification of the daemon serving on the socket, or nil if none is running.
+func probeDaemonVersion(socket string, client Identification) *Identification {
+	conn, err := net.DialTimeout("unix", socket, daemonDialTimeout)
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(Invocation{Client: &client}); err != nil {
+		return nil
+	}
+	var frame invocationFrame
+	if err := json.NewDecoder(conn).Decode(&frame); err != nil || frame.Version == nil {
+		return nil
+	}
+	_ = json.NewEncoder(conn).Encode(invocationAck{Proceed: false})
+	return frame.Version
+}
+
+// handshake checks the version of the running instance (sent first once the invocation is), acknowledging whether the
+// command is run by it.
+func (n *versionNegotiation) handshake(socket string, conn io.Writer, dec *json.Decoder, stderr io.Writer) error {
+	var frame invocationFrame
+	if err := dec.Decode(&frame); err != nil {
+		return fmt.Errorf("lost connection to the running instance: %w", err)
+	}
+	var running Identification
+	if frame.Version != nil {
+		running = *frame.Version
+	}
+	err := n.negotiate(socket, running, stderr)
+	if ackErr := json.NewEncoder(conn).Encode(invocationAck{Proceed: err == nil}); ackErr != nil && err == nil {
+		return fmt.Errorf("lost connection to the running instance: %w", ackErr)
+	}
+	return err
+}
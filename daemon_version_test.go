@@ -0,0 +1,137 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveVersionTestInvocations serves invocations as the instance with the given identification, counting those run.
+func serveVersionTestInvocations(t *testing.T, id Identification, ran *int32) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "d.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			serveInvocation(context.Background(), conn, id, func(_ context.Context, _ Invocation, out *invocationWriter) error {
+				atomic.AddInt32(ran, 1)
+				_, _ = io.WriteString(out.stream(false), "ran\n")
+				return nil
+			})
+		}
+	}()
+	return socket
+}
+
+func Test_forwardInvocation_versionSkew(t *testing.T) {
+	majorOnly := func(client, daemon Identification) bool {
+		return client.Version[:2] == daemon.Version[:2]
+	}
+	tests := []struct {
+		name       string
+		daemon     string
+		policy     VersionSkewPolicy
+		compatible VersionCompatibility
+		wantRun    bool
+		wantStderr string
+		wantErr    string
+	}{
+		{
+			name:    "same version",
+			daemon:  "v1.2.0",
+			policy:  VersionSkewRefuse,
+			wantRun: true,
+		},
+		{
+			name:       "warn on skew",
+			daemon:     "v1.1.0",
+			policy:     VersionSkewWarn,
+			wantRun:    true,
+			wantStderr: "the running daemon (version v1.1.0) differs from this invocation (version v1.2.0)",
+		},
+		{
+			name:    "refuse on skew",
+			daemon:  "v1.1.0",
+			policy:  VersionSkewRefuse,
+			wantErr: "the running daemon (version v1.1.0) is incompatible with this invocation (version v1.2.0)",
+		},
+		{
+			name:       "compatible skew",
+			daemon:     "v1.1.0",
+			policy:     VersionSkewRefuse,
+			compatible: majorOnly,
+			wantRun:    true,
+		},
+		{
+			name:       "incompatible skew",
+			daemon:     "v2.0.0",
+			policy:     VersionSkewRefuse,
+			compatible: majorOnly,
+			wantErr:    "restart the daemon",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ran int32
+			socket := serveVersionTestInvocations(t, Identification{Name: "app", Version: tt.daemon}, &ran)
+
+			cfg := NewSetupConfig(Identification{Name: "app", Version: "v1.2.0"}).WithDaemonVersionSkew(tt.policy, tt.compatible)
+			app := New(*cfg).(*application)
+
+			cmd := &cobra.Command{}
+			var stdout, stderr bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetErr(&stderr)
+			require.True(t, forwardInvocation(socket, cmd, app.daemonVersionNegotiation()))
+
+			err := cmd.RunE(cmd, nil)
+			if tt.wantErr != "" {
+				var skewErr *VersionSkewError
+				require.ErrorAs(t, err, &skewErr)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			if tt.wantRun {
+				assert.Equal(t, "ran\n", stdout.String())
+				assert.Equal(t, int32(1), atomic.LoadInt32(&ran))
+			} else {
+				assert.Empty(t, stdout.String())
+				assert.Zero(t, atomic.LoadInt32(&ran))
+			}
+			if tt.wantStderr != "" {
+				assert.Contains(t, stderr.String(), tt.wantStderr)
+			} else {
+				assert.Empty(t, stderr.String())
+			}
+		})
+	}
+}
+
+func Test_probeDaemonVersion(t *testing.T) {
+	var ran int32
+	id := Identification{Name: "app", Version: "v1.1.0", GitCommit: "abc"}
+	socket := serveVersionTestInvocations(t, id, &ran)
+
+	got := probeDaemonVersion(socket, Identification{Name: "app", Version: "v1.2.0"})
+	require.NotNil(t, got)
+	assert.Equal(t, id, *got)
+	assert.Zero(t, atomic.LoadInt32(&ran))
+
+	assert.Nil(t, probeDaemonVersion(filepath.Join(t.TempDir(), "none.sock"), id))
+}
@@ -0,0 +1,75 @@
+package clio
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// loggingFrames are the function prefixes of stack frames within the logging implementation, which are skipped when
+// finding the caller of a log function.
+var loggingFrames = []string{
+	"runtime.",
+	"github.com/sirupsen/logrus.",
+	"github.com/boss-net/go-logger",
+	"github.com/boss-net/clio.(*githubActionsLogger)",
+	"github.com/boss-net/clio.(*telemetryLogger)",
+	"github.com/boss-net/clio.callerFormatter",
+	"github.com/boss-net/clio.logCaller",
+}
+
+var _ logrus.Formatter = (*callerFormatter)(nil)
+
+// callerFormatter adds the source location of the log call (as the "caller" field) to each entry. The caller that
+// logrus reports is within the logger adapters, not the application, so the stack is walked here instead.
+type callerFormatter struct {
+	next logrus.Formatter
+}
+
+func withCallerInfo(f logrus.Formatter) logrus.Formatter {
+	return callerFormatter{next: f}
+}
+
+func (f callerFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	caller := logCaller()
+	if caller == "" {
+		return f.next.Format(entry)
+	}
+
+	data := make(logrus.Fields, len(entry.Data)+1)
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+	data["caller"] = caller
+
+	withCaller := *entry
+	withCaller.Data = data
+	return f.next.Format(&withCaller)
+}
+
+// logCaller returns the file and line of the first stack frame outside the logging implementation.
+func logCaller() string {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(1, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !isLoggingFrame(frame.Function) {
+			return fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+func isLoggingFrame(function string) bool {
+	for _, prefix := range loggingFrames {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
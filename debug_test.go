@@ -0,0 +1,45 @@
+package clio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_callerFormatter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := logrus.New()
+	l.SetOutput(buf)
+	l.SetFormatter(withCallerInfo(&logrus.JSONFormatter{}))
+
+	l.WithField("key", "value").Info("hello")
+
+	assert.Regexp(t, `"caller":"debug_test.go:\d+"`, buf.String())
+	assert.Contains(t, buf.String(), `"key":"value"`)
+}
+
+func Test_isLoggingFrame(t *testing.T) {
+	assert.True(t, isLoggingFrame("github.com/sirupsen/logrus.(*Entry).Log"))
+	assert.True(t, isLoggingFrame("github.com/boss-net/go-logger/adapter/redact.(*redactingLogger).Debug"))
+	assert.True(t, isLoggingFrame("github.com/boss-net/clio.(*githubActionsLogger).Warn"))
+	assert.False(t, isLoggingFrame("github.com/boss-net/clio.Test_callerFormatter"))
+	assert.False(t, isLoggingFrame("main.main"))
+}
+
+func Test_Application_debugFlag(t *testing.T) {
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithGlobalConfigFlag())
+	var level string
+	cmd := app.SetupRootCommand(&cobra.Command{
+		RunE: func(cmd *cobra.Command, args []string) error {
+			level = string(app.(*application).state.Config.Log.Level)
+			return nil
+		},
+	})
+	cmd.SetArgs([]string{"--debug"})
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, "debug", level)
+}
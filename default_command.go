@@ -0,0 +1,76 @@
+package clio
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// defaultCommand is the subcommand that is run when the root command is invoked without a subcommand
+// (see SetupConfig.WithDefaultCommand).
+type defaultCommand struct {
+	cmd     *cobra.Command
+	cfgs    []any
+	preRunE func(cmd *cobra.Command, args []string) error
+	linked  bool
+}
+
+func (a *application) isDefaultCommand(cmd *cobra.Command) bool {
+	name := a.setupConfig.DefaultCommand
+	return name != "" && cmd != a.root && cmd.Name() == name
+}
+
+// defaultCommandFor returns the default command when the given command is the root command that will run it.
+func (a *application) defaultCommandFor(cmd *cobra.Command) *defaultCommand {
+	if a.defaultCommand == nil || !a.defaultCommand.linked || cmd != a.root {
+		return nil
+	}
+	return a.defaultCommand
+}
+
+// linkDefaultCommand makes the root command run the default command, once both have been setup. The local flags of the
+// default command are added to the root command so that they can be passed without naming the subcommand.
+func (a *application) linkDefaultCommand() {
+	d := a.defaultCommand
+	root := a.root
+	if d == nil || d.linked || root == nil {
+		return
+	}
+
+	if (root.Run != nil || root.RunE != nil) && !a.rootShowsHelp {
+		// the root command has its own behavior, which takes precedence
+		return
+	}
+
+	d.cmd.LocalFlags().VisitAll(func(flag *pflag.Flag) {
+		if root.Flags().Lookup(flag.Name) != nil || root.PersistentFlags().Lookup(flag.Name) != nil {
+			return
+		}
+		if flag.Shorthand != "" && (root.Flags().ShorthandLookup(flag.Shorthand) != nil || root.PersistentFlags().ShorthandLookup(flag.Shorthand) != nil) {
+			// keep the long flag, but avoid a conflicting shorthand
+			dup := *flag
+			dup.Shorthand = ""
+			flag = &dup
+		}
+		root.Flags().AddFlag(flag)
+	})
+
+	a.rootShowsHelp = false
+	root.RunE = func(cmd *cobra.Command, args []string) error {
+		// the default command is not executed by cobra, so it does not otherwise have the context of the invocation
+		d.cmd.SetContext(cmd.Context())
+		if d.preRunE != nil {
+			if err := d.preRunE(d.cmd, args); err != nil {
+				return err
+			}
+		}
+		if d.cmd.RunE != nil {
+			return d.cmd.RunE(d.cmd, args)
+		}
+		if d.cmd.Run != nil {
+			d.cmd.Run(d.cmd, args)
+		}
+		return nil
+	}
+
+	d.linked = true
+}
@@ -0,0 +1,141 @@
+package clio
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/fangs"
+)
+
+type scanConfig struct {
+	Registry struct {
+		Token string `mapstructure:"token"`
+	} `mapstructure:"registry"`
+}
+
+func (c *scanConfig) AddFlags(flags fangs.FlagSet) {
+	flags.StringVarP(&c.Registry.Token, "token", "t", "the registry token")
+}
+
+func Test_Application_DefaultCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		env       map[string]string
+		wantRan   string
+		wantToken string
+		wantErr   require.ErrorAssertionFunc
+	}{
+		{
+			name:    "no arguments runs the default command",
+			wantRan: "scan",
+		},
+		{
+			name:      "flags are passed to the default command",
+			args:      []string{"--token", "secret"},
+			wantRan:   "scan",
+			wantToken: "secret",
+		},
+		{
+			name:      "default command configs are loaded",
+			env:       map[string]string{"APP_REGISTRY_TOKEN": "from-env"},
+			wantRan:   "scan",
+			wantToken: "from-env",
+		},
+		{
+			name:    "subcommands can still be named",
+			args:    []string{"other"},
+			wantRan: "other",
+		},
+		{
+			name:    "the default command can be named",
+			args:    []string{"scan", "--token", "secret"},
+			wantRan: "scan", wantToken: "secret",
+		},
+		{
+			name:    "unknown commands are not passed to the default command",
+			args:    []string{"bogus"},
+			wantErr: require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			app := New(*NewSetupConfig(Identification{Name: "app"}).WithDefaultCommand("scan"))
+			root := app.SetupRootCommand(&cobra.Command{})
+
+			var ran string
+			cfg := &scanConfig{}
+			root.AddCommand(
+				app.SetupCommand(&cobra.Command{
+					Use: "scan",
+					Run: func(cmd *cobra.Command, args []string) {
+						ran = cmd.Name()
+					},
+				}, cfg),
+				app.SetupCommand(&cobra.Command{
+					Use: "other",
+					Run: func(cmd *cobra.Command, args []string) {
+						ran = cmd.Name()
+					},
+				}),
+			)
+
+			root.SetArgs(tt.args)
+			tt.wantErr(t, root.Execute())
+			assert.Equal(t, tt.wantRan, ran)
+			assert.Equal(t, tt.wantToken, cfg.Registry.Token)
+		})
+	}
+}
+
+func Test_Application_DefaultCommand_rootRunTakesPrecedence(t *testing.T) {
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithDefaultCommand("scan"))
+
+	var ran string
+	root := app.SetupRootCommand(&cobra.Command{
+		Run: func(cmd *cobra.Command, args []string) {
+			ran = "root"
+		},
+	})
+	root.AddCommand(app.SetupCommand(&cobra.Command{
+		Use: "scan",
+		Run: func(cmd *cobra.Command, args []string) {
+			ran = cmd.Name()
+		},
+	}))
+
+	root.SetArgs([]string{})
+	require.NoError(t, root.Execute())
+	assert.Equal(t, "root", ran)
+}
+
+func Test_Application_DefaultCommand_context(t *testing.T) {
+	type ctxKey struct{}
+
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithDefaultCommand("scan").WithNoBus())
+	root := app.SetupRootCommand(&cobra.Command{})
+
+	var got any
+	root.AddCommand(app.SetupCommand(&cobra.Command{
+		Use: "scan",
+		RunE: app.RunWithState(func(ctx context.Context, _ *State, _ []string) error {
+			got = ctx.Value(ctxKey{})
+			return nil
+		}),
+	}))
+
+	root.SetArgs([]string{})
+	require.NoError(t, root.ExecuteContext(context.WithValue(context.Background(), ctxKey{}, "value")))
+	assert.Equal(t, "value", got)
+}
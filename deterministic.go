@@ -0,0 +1,117 @@
+package clio
+
+import (
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gookit/color"
+)
+
+// deterministicSeed seeds the randomness exposed by State.Rand in deterministic mode.
+const deterministicSeed = 1
+
+var (
+	deterministicMode bool
+	frozenTime        time.Time
+)
+
+// DeterministicMode indicates output should be reproducible byte-for-byte between runs (see
+// DevelopmentConfig.Deterministic): timestamps are frozen, there are no spinners or colors, randomness exposed by
+// State.Rand is seeded with a fixed seed, and tables are sorted when no sort order is given.
+func DeterministicMode() bool {
+	presentationLock.RLock()
+	defer presentationLock.RUnlock()
+	return deterministicMode
+}
+
+// setDeterministic enables or disables deterministic mode, freezing the time at SOURCE_DATE_EPOCH (see
+// https://reproducible-builds.org/specs/source-date-epoch/) or the unix epoch when not set.
+func setDeterministic(enabled bool, getenv func(string) string) {
+	presentationLock.Lock()
+	defer presentationLock.Unlock()
+	deterministicMode = enabled
+	frozenTime = time.Unix(0, 0).UTC()
+	if epoch, err := strconv.ParseInt(getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		frozenTime = time.Unix(epoch, 0).UTC()
+	}
+	if enabled {
+		color.Enable = false
+	}
+}
+
+// presentationEnv returns the environment lookup for presentation hints (e.g. the locale), which are ignored in
+// deterministic mode so that output does not depend on the environment it is produced in.
+func presentationEnv(getenv func(string) string) func(string) string {
+	if DeterministicMode() {
+		return func(string) string { return "" }
+	}
+	return getenv
+}
+
+// now returns the current time, which is frozen in deterministic mode.
+func now() time.Time {
+	presentationLock.RLock()
+	defer presentationLock.RUnlock()
+	if deterministicMode {
+		return frozenTime
+	}
+	return time.Now()
+}
+
+// since returns the time elapsed since t, which is always zero in deterministic mode.
+func since(t time.Time) time.Duration {
+	return now().Sub(t)
+}
+
+// Now returns the current time for timestamps shown to the user, which is frozen in deterministic mode (see
+// DeterministicMode).
+func (s *State) Now() time.Time {
+	return now()
+}
+
+// Rand returns the source of randomness for the application, which is safe for concurrent use (except for Read). In
+// deterministic mode (see DeterministicMode) it is seeded with a fixed seed, so the same sequence of values is produced
+// on every run.
+func (s *State) Rand() *rand.Rand {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.random == nil {
+		s.random = newRand()
+	}
+	return s.random
+}
+
+// newRand returns a source of randomness seeded for the current mode.
+func newRand() *rand.Rand {
+	seed := time.Now().UnixNano()
+	if DeterministicMode() {
+		seed = deterministicSeed
+	}
+	return rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+}
+
+// lockedSource is a rand.Source which is safe for concurrent use.
+type lockedSource struct {
+	lock sync.Mutex
+	src  rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.src.Seed(seed)
+}
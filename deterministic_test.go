@@ -0,0 +1,97 @@
+package clio
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type deterministicRun struct {
+	now        time.Time
+	random     []int64
+	invocation string
+	spinner    []string
+}
+
+func runDeterministicTestApp(t *testing.T, args ...string) deterministicRun {
+	t.Helper()
+	t.Cleanup(func() {
+		setDeterministic(false, func(string) string { return "" })
+		setPresentation(true, false)
+	})
+
+	var run deterministicRun
+	app := New(*NewSetupConfig(Identification{Name: "app"}).
+		WithNoBus().
+		WithGlobalConfigFlag().
+		WithDevelopmentConfig(DevelopmentConfig{}))
+	root := app.SetupRootCommand(&cobra.Command{
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state := app.(*application).State()
+			run.now = state.Now()
+			for i := 0; i < 3; i++ {
+				run.random = append(run.random, state.Rand().Int63())
+			}
+			run.invocation = state.InvocationID()
+			run.spinner = CurrentSymbols().Spinner
+			return nil
+		},
+	})
+	_, _, err := runTestApp(root, "", args...)
+	require.NoError(t, err)
+	return run
+}
+
+func Test_DeterministicMode(t *testing.T) {
+	first := runDeterministicTestApp(t, "--deterministic")
+	second := runDeterministicTestApp(t, "--deterministic")
+
+	assert.Equal(t, first, second, "runs are reproducible")
+	assert.Equal(t, time.Unix(0, 0).UTC(), first.now)
+	expected := rand.New(rand.NewSource(deterministicSeed))
+	assert.Equal(t, []int64{expected.Int63(), expected.Int63(), expected.Int63()}, first.random)
+	assert.Empty(t, first.spinner)
+	assert.Equal(t, time.Duration(0), since(first.now))
+}
+
+func Test_DeterministicMode_sourceDateEpoch(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	run := runDeterministicTestApp(t, "--deterministic")
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), run.now)
+}
+
+func Test_DeterministicMode_disabled(t *testing.T) {
+	first := runDeterministicTestApp(t)
+	second := runDeterministicTestApp(t)
+
+	assert.WithinDuration(t, time.Now(), first.now, time.Minute)
+	assert.NotEqual(t, first.invocation, second.invocation)
+	assert.NotEqual(t, first.random, second.random)
+}
+
+func Test_TableConfig_deterministicRows(t *testing.T) {
+	t.Cleanup(func() {
+		setDeterministic(false, func(string) string { return "" })
+	})
+	type row struct {
+		Name  string
+		Count int
+	}
+	rows := []row{{Name: "b", Count: 2}, {Name: "a", Count: 10}, {Name: "a", Count: 9}}
+
+	render := func() string {
+		var out bytes.Buffer
+		require.NoError(t, tableEncoder{wide: true}.Encode(&out, rows))
+		return out.String()
+	}
+
+	assert.Equal(t, "NAME   COUNT\nb      2\na      10\na      9\n", render())
+
+	setDeterministic(true, func(string) string { return "" })
+	assert.Equal(t, "NAME   COUNT\na      9\na      10\nb      2\n", render())
+}
@@ -16,11 +16,29 @@ const (
 type Profile string
 
 type DevelopmentConfig struct {
-	Profile Profile `yaml:"profile" json:"profile" mapstructure:"profile"`
+	Profile       Profile           `yaml:"profile" json:"profile" mapstructure:"profile"`
+	Faults        map[string]string `yaml:"faults" json:"faults" mapstructure:"faults"`                      // faults to inject at the named fault points (see State.Fault)
+	Deterministic bool              `yaml:"deterministic" json:"deterministic" mapstructure:"deterministic"` // --deterministic, reproducible output (see DeterministicMode)
+}
+
+var _ interface {
+	fangs.FlagAdder
+	fangs.FieldDescriber
+	fangs.PostLoader
+} = (*DevelopmentConfig)(nil)
+
+func (d *DevelopmentConfig) AddFlags(flags fangs.FlagSet) {
+	flags.BoolVarP(&d.Deterministic, "deterministic", "", "produce byte-identical output between runs (frozen timestamps, no spinners or colors, and sorted tables)")
 }
 
 func (d *DevelopmentConfig) DescribeFields(set fangs.FieldDescriptionSet) {
 	set.Add(&d.Profile, fmt.Sprintf("capture resource profiling data (available: [%s])", strings.Join([]string{string(ProfileCPU), string(ProfileMem)}, ", ")))
+	set.Add(&d.Faults, `faults to inject for testing failure paths, by fault point (e.g. download: "delay:2s,error:timeout")`)
+}
+
+// deterministic indicates output should be reproducible (see DeterministicMode).
+func (d *DevelopmentConfig) deterministic() bool {
+	return d != nil && d.Deterministic
 }
 
 func (d *DevelopmentConfig) PostLoad() error {
@@ -29,6 +47,11 @@ func (d *DevelopmentConfig) PostLoad() error {
 		return fmt.Errorf("invalid profile: %q", d.Profile)
 	}
 	d.Profile = p
+	for name, spec := range d.Faults {
+		if _, err := parseFault(spec); err != nil {
+			return fmt.Errorf("invalid dev.faults.%s: %w", name, err)
+		}
+	}
 	return nil
 }
 
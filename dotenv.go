@@ -0,0 +1,137 @@
+package clio
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const defaultDotEnvFile = ".env"
+
+var (
+	dotEnvKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+	// dotEnvSecretPattern matches variable names whose values are added to the redaction store
+	dotEnvSecretPattern = regexp.MustCompile(`(?i)(token|secret|password|passwd|pass|key|credential|auth)`)
+)
+
+// loadDotEnv sets environment variables from the .env file (see SetupConfig.WithDotEnv). Variables that are already
+// set in the environment take precedence over the file. Values of variables that look sensitive (e.g. *_TOKEN) are
+// redacted from all output.
+func (a *application) loadDotEnv() error {
+	if !a.setupConfig.DotEnv {
+		return nil
+	}
+
+	path := a.setupConfig.DotEnvFile
+	if path == "" {
+		path = defaultDotEnvFile
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && a.setupConfig.DotEnvFile == "" {
+			// the default file is optional
+			return nil
+		}
+		return fmt.Errorf("unable to read env file: %w", err)
+	}
+
+	vars, err := parseDotEnv(contents)
+	if err != nil {
+		return fmt.Errorf("unable to parse env file %s: %w", path, err)
+	}
+
+	for _, v := range vars {
+		if v.value != "" && dotEnvSecretPattern.MatchString(v.key) && a.state.RedactStore != nil {
+			a.state.RedactStore.Add(v.value)
+		}
+		if _, exists := os.LookupEnv(v.key); exists {
+			continue
+		}
+		if err := os.Setenv(v.key, v.value); err != nil {
+			return fmt.Errorf("unable to set %s from env file: %w", v.key, err)
+		}
+	}
+	return nil
+}
+
+type dotEnvVar struct {
+	key   string
+	value string
+}
+
+// parseDotEnv parses KEY=VALUE lines (optionally prefixed with "export"), ignoring blank lines and comments. Values
+// may be single quoted (taken literally), double quoted (supporting \n, \t, \", and \\ escapes), or unquoted (where
+// " #" starts a comment).
+func parseDotEnv(contents []byte) ([]dotEnvVar, error) {
+	var vars []dotEnvVar
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimSpace(strings.TrimPrefix(text, "export "))
+
+		key, value, ok := strings.Cut(text, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !dotEnvKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", line)
+		}
+
+		value, err := parseDotEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		vars = append(vars, dotEnvVar{key: key, value: value})
+	}
+	return vars, scanner.Err()
+}
+
+var dotEnvEscapes = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`)
+
+func parseDotEnvValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	switch quote := value[0]; quote {
+	case '\'', '"':
+		end := closingQuote(value, quote)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value")
+		}
+		if rest := strings.TrimSpace(value[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected content after quoted value")
+		}
+		inner := value[1:end]
+		if quote == '"' {
+			inner = dotEnvEscapes.Replace(inner)
+		}
+		return inner, nil
+	}
+
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// closingQuote returns the index of the closing quote (skipping escaped quotes within double quoted values).
+func closingQuote(value string, quote byte) int {
+	for i := 1; i < len(value); i++ {
+		switch {
+		case quote == '"' && value[i] == '\\':
+			i++
+		case value[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
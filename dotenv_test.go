@@ -0,0 +1,72 @@
+package clio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseDotEnv(t *testing.T) {
+	vars, err := parseDotEnv([]byte(`
+# comment
+APP_NAME=value # trailing comment
+export APP_EXPORTED=exported
+APP_EMPTY=
+APP_SINGLE='literal \n # not a comment'
+APP_DOUBLE="line\nbreak \"quoted\"" # comment
+APP_SPACES =  spaced value  
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []dotEnvVar{
+		{key: "APP_NAME", value: "value"},
+		{key: "APP_EXPORTED", value: "exported"},
+		{key: "APP_EMPTY", value: ""},
+		{key: "APP_SINGLE", value: `literal \n # not a comment`},
+		{key: "APP_DOUBLE", value: "line\nbreak \"quoted\""},
+		{key: "APP_SPACES", value: "spaced value"},
+	}, vars)
+
+	_, err = parseDotEnv([]byte("APP_OK=1\nnot a var\n"))
+	require.ErrorContains(t, err, "line 2")
+
+	_, err = parseDotEnv([]byte(`APP_X="unterminated`))
+	require.ErrorContains(t, err, "unterminated")
+}
+
+func Test_Application_dotEnv(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "test.env")
+	require.NoError(t, os.WriteFile(file, []byte("APP_REGISTRY_TOKEN=from-file-secret\nAPP_FROM_ENV=from-file\n"), 0o600))
+
+	t.Setenv("APP_FROM_ENV", "from-env")
+	t.Cleanup(func() {
+		_ = os.Unsetenv("APP_REGISTRY_TOKEN")
+	})
+
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithDotEnv(file))
+	cfg := &scanConfig{}
+	root := app.SetupRootCommand(&cobra.Command{
+		RunE: func(*cobra.Command, []string) error { return nil },
+	}, cfg)
+	root.SetArgs([]string{})
+	require.NoError(t, root.Execute())
+
+	assert.Equal(t, "from-file-secret", cfg.Registry.Token)
+	assert.Equal(t, "from-env", os.Getenv("APP_FROM_ENV"))
+
+	store := app.(*application).state.RedactStore
+	assert.NotContains(t, store.RedactString("token: from-file-secret"), "from-file-secret")
+	assert.Contains(t, store.RedactString("from-env"), "from-env")
+}
+
+func Test_Application_dotEnv_missing(t *testing.T) {
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithDotEnv(filepath.Join(t.TempDir(), "missing.env")))
+	root := app.SetupRootCommand(&cobra.Command{
+		RunE: func(*cobra.Command, []string) error { return nil },
+	})
+	root.SetArgs([]string{})
+	require.ErrorContains(t, root.Execute(), "unable to read env file")
+}
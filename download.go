@@ -0,0 +1,47 @@
+package clio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Download fetches the URL to the file at the given path (see ResolvePath) with the client from State.HTTPClient, so
+// that the network policy is enforced (see SetupConfig.WithNetworkPolicy), returning the number of bytes written. The
+// contents are written to a temporary file beside the destination first (following the configured permissions
+// policy) so that a failed or interrupted download never leaves a partial file.
+func (s *State) Download(ctx context.Context, url, path string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("unable to download %s: %w", url, err)
+	}
+	resp, err := s.HTTPClient().Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("unable to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unable to download %s: %s", url, resp.Status)
+	}
+
+	path = s.ResolvePath(path)
+	tmp, err := s.CreateFile(filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".download"))
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(tmp, resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return 0, fmt.Errorf("unable to download %s: %w", url, err)
+	}
+	return n, nil
+}
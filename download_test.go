@@ -0,0 +1,49 @@
+package clio
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_State_Download(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("contents"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	s := &State{Config: Config{Network: &NetworkConfig{}}}
+
+	path := filepath.Join(dir, "sub", "file")
+	n, err := s.Download(context.Background(), server.URL+"/file", path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), n)
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "contents", string(contents))
+
+	_, err = s.Download(context.Background(), server.URL+"/missing", filepath.Join(dir, "missing"))
+	require.ErrorContains(t, err, "404 Not Found")
+	assert.NoFileExists(t, filepath.Join(dir, "missing"))
+
+	s.Config.Network.Offline = true
+	_, err = s.Download(context.Background(), server.URL+"/file", filepath.Join(dir, "offline"))
+	var policyErr *NetworkPolicyError
+	require.ErrorAs(t, err, &policyErr)
+	assert.NoFileExists(t, filepath.Join(dir, "offline"))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,277 @@
+package clio
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/wagoodman/go-partybus"
+)
+
+// EnvironmentEvent is published on the bus once the application has been setup, with the detected Environment
+// as the event value.
+const EnvironmentEvent partybus.EventType = "clio-environment"
+
+// Environment describes where the application is running, as detected once during setup (see State.Environment).
+type Environment struct {
+	// OS and Arch are the operating system and architecture (as GOOS and GOARCH).
+	OS   string
+	Arch string
+
+	// Kernel is the kernel release (e.g. "6.5.0-14-generic") or, on windows, the version (e.g. "10.0.22631"), or
+	// empty if unknown.
+	Kernel string
+
+	// Container is the detected container runtime (e.g. "docker", "podman", "kubernetes", "lxc"), "unknown" if
+	// running in a container of an unknown runtime, or empty if not running in a container.
+	Container string
+
+	// CI is the detected CI provider (e.g. "github-actions", "gitlab", "jenkins", "circleci", "buildkite", "teamcity"), "unknown"
+	// if running in CI of an unknown provider, or empty if not running in CI.
+	CI string
+
+	// Terminal describes which of the standard streams are attached to a terminal.
+	Terminal TerminalInfo
+
+	// EnvVars lists the names (never the values) of the environment variables that affect the application which are
+	// set, such as proxy settings, color preferences, and variables prefixed with the application name.
+	EnvVars []string
+}
+
+// TerminalInfo describes the terminal the application is attached to.
+type TerminalInfo struct {
+	Stdin  bool
+	Stdout bool
+	Stderr bool
+
+	// Type is the terminal type (the value of TERM).
+	Type string
+}
+
+// InContainer indicates if the application is running within a container.
+func (e Environment) InContainer() bool {
+	return e.Container != ""
+}
+
+// InCI indicates if the application is running within a CI system.
+func (e Environment) InCI() bool {
+	return e.CI != ""
+}
+
+// resourceAttributes describes the environment with (opentelemetry semantic convention) telemetry attributes, omitting
+// anything that was not detected.
+func (e Environment) resourceAttributes() map[string]string {
+	attrs := map[string]string{}
+	for k, v := range map[string]string{
+		"os.type":           e.OS,
+		"os.version":        e.Kernel,
+		"host.arch":         e.Arch,
+		"container.runtime": e.Container,
+		"cicd.provider":     e.CI,
+	} {
+		if v != "" {
+			attrs[k] = v
+		}
+	}
+	return attrs
+}
+
+// Environment returns the environment detected during setup. This is the same snapshot published as the
+// EnvironmentEvent and included in bug reports and telemetry.
+func (s *State) Environment() Environment {
+	return s.environment
+}
+
+// CI returns the detected CI provider (see Environment.CI), or empty if not running in CI.
+func (s *State) CI() string {
+	return s.environment.CI
+}
+
+// ciProviders maps environment variables set by well-known CI systems to the provider name.
+var ciProviders = []struct {
+	env      string
+	provider string
+}{
+	{"GITHUB_ACTIONS", "github-actions"},
+	{"GITLAB_CI", "gitlab"},
+	{"JENKINS_URL", "jenkins"},
+	{"CIRCLECI", "circleci"},
+	{"BUILDKITE", "buildkite"},
+	{"TEAMCITY_VERSION", "teamcity"},
+}
+
+// ciProvider is swappable for testing
+var ciProvider = func() string {
+	return detectCI(os.Getenv)
+}
+
+func detectCI(getenv func(string) string) string {
+	for _, p := range ciProviders {
+		if getenv(p.env) != "" {
+			return p.provider
+		}
+	}
+	switch strings.ToLower(getenv("CI")) {
+	case "", "0", "false":
+		return ""
+	}
+	return "unknown"
+}
+
+// containerCgroupMarkers maps substrings of the cgroup of PID 1 to the container runtime.
+var containerCgroupMarkers = []struct {
+	marker  string
+	runtime string
+}{
+	{"kubepods", "kubernetes"},
+	{"docker", "docker"},
+	{"libpod", "podman"},
+	{"containerd", "containerd"},
+	{"lxc", "lxc"},
+}
+
+// relevantEnvVars are environment variables (other than those prefixed with the application name) that commonly
+// explain differences in behavior between environments.
+var relevantEnvVars = []string{
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy",
+	"SSL_CERT_FILE", "SSL_CERT_DIR",
+	"NO_COLOR", "FORCE_COLOR", "COLORTERM", "TERM",
+	"LANG", "LC_ALL", "LC_CTYPE",
+	"HOME", "XDG_CONFIG_HOME", "XDG_CACHE_HOME", "XDG_STATE_HOME",
+	"CI",
+}
+
+// detectEnvironment inspects the environment variables and filesystem (rooted at the given FS, normally "/").
+func detectEnvironment(getenv func(string) string, root fs.FS) Environment {
+	return Environment{
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Container: detectContainer(getenv, root),
+		CI:        detectCI(getenv),
+		Terminal:  TerminalInfo{Type: getenv("TERM")},
+	}
+}
+
+// setEnvVars returns the names of the relevant environment variables that are set (see Environment.EnvVars).
+func setEnvVars(appName string, environ []string) []string {
+	relevant := append([]string{}, relevantEnvVars...)
+	for _, p := range ciProviders {
+		relevant = append(relevant, p.env)
+	}
+
+	prefix := envVarName(appName, nil) // e.g. "APP_"
+	var names []string
+	seen := map[string]bool{}
+	for _, kv := range environ {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if seen[name] || (!contains(relevant, name) && (appName == "" || !strings.HasPrefix(name, prefix))) {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func detectContainer(getenv func(string) string, root fs.FS) string {
+	// well-known environment variables
+	if getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return "kubernetes"
+	}
+	if runtime := getenv("container"); runtime != "" {
+		// set by podman, systemd-nspawn, and others
+		return runtime
+	}
+
+	// well-known files
+	if fileExists(root, ".dockerenv") {
+		return "docker"
+	}
+	if fileExists(root, "run/.containerenv") {
+		return "podman"
+	}
+
+	// cgroup heuristics (cgroup v1 names the runtime in the path)
+	if contents, err := fs.ReadFile(root, "proc/1/cgroup"); err == nil {
+		for _, m := range containerCgroupMarkers {
+			if bytes.Contains(contents, []byte(m.marker)) {
+				return m.runtime
+			}
+		}
+	}
+
+	// overlay heuristics (cgroup v2 does not name the runtime, however container root filesystems are typically
+	// overlays, which is uncommon for hosts)
+	if rootIsOverlay(root) {
+		return "unknown"
+	}
+	return ""
+}
+
+func rootIsOverlay(root fs.FS) bool {
+	f, err := root.Open("proc/self/mountinfo")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// format: id parent major:minor root mount-point options [optional fields...] - fstype source super-options
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[4] != "/" {
+			continue
+		}
+		for i, field := range fields {
+			if field == "-" && i+1 < len(fields) {
+				return fields[i+1] == "overlay"
+			}
+		}
+	}
+	return false
+}
+
+func fileExists(root fs.FS, name string) bool {
+	_, err := fs.Stat(root, name)
+	return err == nil
+}
+
+// setupEnvironment detects the environment, adjusts defaults accordingly, and publishes the result on the bus.
+func (s *State) setupEnvironment() {
+	s.environment = detectEnvironment(os.Getenv, os.DirFS("/"))
+	s.environment.Kernel = kernelVersion()
+	s.environment.EnvVars = setEnvVars(s.id.Name, os.Environ())
+	s.environment.Terminal.Stdin = isTerminal(os.Stdin)
+	s.environment.Terminal.Stdout = isTerminal(os.Stdout)
+	s.environment.Terminal.Stderr = isTerminal(os.Stderr)
+
+	var tempRoot string
+	if s.environment.InContainer() {
+		if os.Getenv("TERM") == "" {
+			// containers are commonly run without a terminal, in which case escape sequences are just noise in the logs
+			color.Enable = false
+		}
+		// the output of containers is commonly collected as logs, where redrawing a rich UI is noise
+		s.Config.PlainUI = true
+		// containers commonly have a read-only root filesystem (or TMPDIR pointing somewhere not mounted), with /tmp
+		// mounted writable
+		_, dirMode := s.Config.Permissions.modes()
+		tempRoot = writableTempRoot(os.Getenv, dirMode)
+	}
+	s.temp.lock.Lock()
+	s.temp.root = tempRoot
+	s.temp.lock.Unlock()
+
+	if s.Bus != nil {
+		s.Bus.Publish(partybus.Event{
+			Type:  EnvironmentEvent,
+			Value: s.environment,
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package clio
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wagoodman/go-partybus"
+)
+
+func Test_detectContainer(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		fs   fstest.MapFS
+		want string
+	}{
+		{
+			name: "host",
+			fs: fstest.MapFS{
+				"proc/1/cgroup":       {Data: []byte("0::/init.scope\n")},
+				"proc/self/mountinfo": {Data: []byte("22 1 259:2 / / rw,relatime shared:1 - ext4 /dev/nvme0n1p2 rw\n")},
+			},
+		},
+		{
+			name: "kubernetes env",
+			env:  map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1"},
+			want: "kubernetes",
+		},
+		{
+			name: "container env",
+			env:  map[string]string{"container": "podman"},
+			want: "podman",
+		},
+		{
+			name: "docker env file",
+			fs:   fstest.MapFS{".dockerenv": {}},
+			want: "docker",
+		},
+		{
+			name: "podman env file",
+			fs:   fstest.MapFS{"run/.containerenv": {}},
+			want: "podman",
+		},
+		{
+			name: "cgroup v1",
+			fs: fstest.MapFS{
+				"proc/1/cgroup": {Data: []byte("12:memory:/docker/3f2a9c\n")},
+			},
+			want: "docker",
+		},
+		{
+			name: "cgroup v1 kubernetes",
+			fs: fstest.MapFS{
+				"proc/1/cgroup": {Data: []byte("11:cpu:/kubepods/besteffort/pod1234/abcd\n")},
+			},
+			want: "kubernetes",
+		},
+		{
+			name: "overlay root",
+			fs: fstest.MapFS{
+				"proc/1/cgroup":       {Data: []byte("0::/\n")},
+				"proc/self/mountinfo": {Data: []byte("1012 940 0:93 / / rw,relatime master:422 - overlay overlay rw,lowerdir=/a,upperdir=/b\n")},
+			},
+			want: "unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string {
+				return tt.env[key]
+			}
+			if tt.fs == nil {
+				tt.fs = fstest.MapFS{}
+			}
+			env := detectEnvironment(getenv, tt.fs)
+			assert.Equal(t, tt.want, env.Container)
+			assert.Equal(t, tt.want != "", env.InContainer())
+		})
+	}
+}
+
+func Test_detectCI(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "not CI",
+		},
+		{
+			name: "github actions",
+			env:  map[string]string{"GITHUB_ACTIONS": "true", "CI": "true"},
+			want: "github-actions",
+		},
+		{
+			name: "gitlab",
+			env:  map[string]string{"GITLAB_CI": "true", "CI": "true"},
+			want: "gitlab",
+		},
+		{
+			name: "jenkins",
+			env:  map[string]string{"JENKINS_URL": "https://jenkins.example.com/"},
+			want: "jenkins",
+		},
+		{
+			name: "circleci",
+			env:  map[string]string{"CIRCLECI": "true"},
+			want: "circleci",
+		},
+		{
+			name: "buildkite",
+			env:  map[string]string{"BUILDKITE": "true"},
+			want: "buildkite",
+		},
+		{
+			name: "unknown provider",
+			env:  map[string]string{"CI": "1"},
+			want: "unknown",
+		},
+		{
+			name: "CI explicitly disabled",
+			env:  map[string]string{"CI": "false"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectCI(func(key string) string {
+				return tt.env[key]
+			})
+			assert.Equal(t, tt.want, got)
+
+			s := &State{environment: Environment{CI: got}}
+			assert.Equal(t, tt.want, s.CI())
+		})
+	}
+}
+
+func Test_setEnvVars(t *testing.T) {
+	environ := []string{
+		"APP_REGISTRY_TOKEN=secret",
+		"HTTPS_PROXY=http://proxy.example",
+		"GITHUB_ACTIONS=true",
+		"PATH=/usr/bin",
+		"APPLE=1",
+		"NO_COLOR=",
+	}
+	assert.Equal(t, []string{"APP_REGISTRY_TOKEN", "GITHUB_ACTIONS", "HTTPS_PROXY", "NO_COLOR"}, setEnvVars("app", environ))
+	assert.Equal(t, []string{"GITHUB_ACTIONS", "HTTPS_PROXY", "NO_COLOR"}, setEnvVars("", environ))
+}
+
+func Test_Environment_resourceAttributes(t *testing.T) {
+	env := Environment{OS: "linux", Arch: "amd64", Container: "docker"}
+	assert.Equal(t, map[string]string{
+		"os.type":           "linux",
+		"host.arch":         "amd64",
+		"container.runtime": "docker",
+	}, env.resourceAttributes())
+}
+
+func TestState_setupEnvironment(t *testing.T) {
+	defer func(enabled bool) { color.Enable = enabled }(color.Enable)
+
+	bus := partybus.NewBus()
+	sub := bus.Subscribe(EnvironmentEvent)
+
+	s := &State{Bus: bus, id: Identification{Name: "app"}}
+	s.setupEnvironment()
+
+	env := s.Environment()
+	assert.Equal(t, runtime.GOOS, env.OS)
+	assert.Equal(t, runtime.GOARCH, env.Arch)
+
+	select {
+	case e := <-sub.Events():
+		assert.Equal(t, env, e.Value)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the environment event")
+	}
+}
+
+func TestState_setupEnvironment_container(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires /tmp")
+	}
+	defer func(enabled bool) { color.Enable = enabled }(color.Enable)
+	t.Setenv("container", "podman")
+
+	// TMPDIR is preferred when writable
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	s := &State{id: Identification{Name: "app"}}
+	s.setupEnvironment()
+	assert.Equal(t, "podman", s.Environment().Container)
+	assert.True(t, s.Config.PlainUI, "containers select the plain UI")
+	dir, err := s.TempDir()
+	require.NoError(t, err)
+	assert.Equal(t, tmp, filepath.Dir(filepath.Dir(dir)))
+	s.removeTempDirs()
+
+	// otherwise /tmp is used
+	t.Setenv("TMPDIR", filepath.Join(tmp, "missing"))
+	s = &State{id: Identification{Name: "app"}}
+	s.setupEnvironment()
+	assert.Equal(t, "/tmp", s.temp.root)
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package clio
+
+import "golang.org/x/sys/unix"
+
+func kernelVersion() string {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return ""
+	}
+	return unix.ByteSliceToString(uts.Release[:])
+}
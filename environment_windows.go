@@ -0,0 +1,14 @@
+//go:build windows
+
+package clio
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+func kernelVersion() string {
+	v := windows.RtlGetVersion()
+	return fmt.Sprintf("%d.%d.%d", v.MajorVersion, v.MinorVersion, v.BuildNumber)
+}
@@ -0,0 +1,208 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/pborman/indent"
+	"github.com/spf13/cobra"
+)
+
+// DefaultExampleTimeout bounds how long each example may take when run as a smoke test (see
+// SetupConfig.WithExamplesCommand).
+const DefaultExampleTimeout = time.Minute
+
+// examplesAnnotation holds the examples declared on a command (see Application.AddExamples), encoded as json, so that
+// they can be read without the application (e.g. by cliotest).
+const examplesAnnotation = "clio.examples"
+
+// Example is a declared invocation of a command, which is shown in the help (and in docs generated from the
+// commands), and is run as a smoke test by the "examples test" command (see SetupConfig.WithExamplesCommand) and
+// cliotest.RunExamples, so that the documentation stays in sync with the behavior.
+type Example struct {
+	Description string   `json:"description"`
+	Args        []string `json:"args"`                // the command line after the application name (e.g. "scan", "--depth", "2")
+	ExitCode    int      `json:"exit-code,omitempty"` // the expected exit code
+	Manual      bool     `json:"manual,omitempty"`    // not run as a smoke test (e.g. needs credentials or network access)
+}
+
+// commandLine returns the example as it would be typed, quoting arguments where needed.
+func (e Example) commandLine(appName string) string {
+	words := []string{appName}
+	for _, arg := range e.Args {
+		words = append(words, quoteExampleArg(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+func quoteExampleArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`*?&|;<>()[]{}!#~") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// AddExamples declares examples of the command, which are appended to the examples shown in its help (see Example).
+func (a *application) AddExamples(cmd *cobra.Command, examples ...Example) {
+	all := append(Examples(cmd), examples...)
+	encoded, err := json.Marshal(all)
+	if err != nil {
+		// examples only hold strings and numbers
+		panic(err)
+	}
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[examplesAnnotation] = string(encoded)
+
+	var sb strings.Builder
+	sb.WriteString(cmd.Example)
+	for _, e := range examples {
+		if sb.Len() > 0 {
+			sb.WriteString("\n\n")
+		}
+		if e.Description != "" {
+			fmt.Fprintf(&sb, "  # %s\n", e.Description)
+		}
+		sb.WriteString("  " + e.commandLine(a.setupConfig.ID.Name))
+	}
+	cmd.Example = sb.String()
+}
+
+// Examples returns the examples declared on the command (see Application.AddExamples).
+func Examples(cmd *cobra.Command) []Example {
+	var examples []Example
+	if encoded := cmd.Annotations[examplesAnnotation]; encoded != "" {
+		_ = json.Unmarshal([]byte(encoded), &examples)
+	}
+	return examples
+}
+
+// AllExamples returns the examples declared on the command and all of its children.
+func AllExamples(cmd *cobra.Command) []Example {
+	examples := Examples(cmd)
+	for _, c := range cmd.Commands() {
+		examples = append(examples, AllExamples(c)...)
+	}
+	return examples
+}
+
+// setupExamplesCommand adds the hidden "examples" command listing the examples of all commands, and the "examples
+// test" command running them as smoke tests.
+func (a *application) setupExamplesCommand() {
+	examplesCmd := &cobra.Command{
+		Use:    "examples [command]",
+		Short:  "show the examples of all commands",
+		Hidden: true,
+		Args:   cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			examples, err := a.examplesOf(args)
+			if err != nil {
+				return err
+			}
+			for _, e := range examples {
+				_, _ = fmt.Fprintln(cmd.OutOrStdout(), e.commandLine(a.setupConfig.ID.Name))
+			}
+			return nil
+		},
+	}
+
+	var timeout time.Duration
+	var showOutput bool
+	testCmd := &cobra.Command{
+		Use:   "test [command]",
+		Short: "run the examples of all commands (or the given command) as smoke tests",
+		Long: "Run the examples of all commands (or the given command and its children) as smoke tests, each in a child " +
+			"process, failing when any exits with another exit code than expected. Manual examples are skipped.",
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			examples, err := a.examplesOf(args)
+			if err != nil {
+				return err
+			}
+			return a.testExamples(cmd.Context(), cmd.OutOrStdout(), examples, timeout, showOutput)
+		},
+	}
+	testCmd.Flags().DurationVar(&timeout, "timeout", DefaultExampleTimeout, "how long each example may take")
+	testCmd.Flags().BoolVar(&showOutput, "show-output", false, "show the output of all examples (not only those failing)")
+
+	examplesCmd.AddCommand(a.SetupCommand(testCmd))
+	a.root.AddCommand(a.SetupCommand(examplesCmd))
+}
+
+// examplesOf returns the examples of the named command (and its children), or of all commands when none is named.
+func (a *application) examplesOf(args []string) ([]Example, error) {
+	cmd := a.root
+	if len(args) > 0 {
+		found, rest, err := a.root.Find(args)
+		if err != nil || len(rest) > 0 {
+			return nil, fmt.Errorf("unknown command %q", strings.Join(args, " "))
+		}
+		cmd = found
+	}
+	return AllExamples(cmd), nil
+}
+
+// testExamples runs each example in a child process, reporting whether it exited with the expected exit code.
+func (a *application) testExamples(ctx context.Context, w io.Writer, examples []Example, timeout time.Duration, showOutput bool) error {
+	var ran, failed int
+	for _, e := range examples {
+		line := e.commandLine(a.setupConfig.ID.Name)
+		if e.Manual {
+			_, _ = fmt.Fprintf(w, "%s %s\n", color.Gray.Sprint("SKIP"), line)
+			continue
+		}
+		ran++
+
+		code, output, err := a.runExample(ctx, e, timeout)
+		switch {
+		case err != nil:
+			failed++
+			_, _ = fmt.Fprintf(w, "%s %s: %v\n", color.Red.Sprint("FAIL"), line, err)
+		case code != e.ExitCode:
+			failed++
+			_, _ = fmt.Fprintf(w, "%s %s: exited with code %d (expected %d)\n", color.Red.Sprint("FAIL"), line, code, e.ExitCode)
+		default:
+			_, _ = fmt.Fprintf(w, "%s %s\n", color.Green.Sprint("PASS"), line)
+			if !showOutput {
+				continue
+			}
+		}
+		if out := strings.TrimSpace(output); out != "" {
+			_, _ = fmt.Fprintln(w, indent.String("    ", out))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d examples failed", failed, ran)
+	}
+	return nil
+}
+
+// runExample runs the example in a child process, returning the exit code and the output.
+func (a *application) runExample(ctx context.Context, e Example, timeout time.Duration) (int, string, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	c, err := applicationCommand(e.Args)
+	if err != nil {
+		return 0, "", err
+	}
+	var out bytes.Buffer
+	c.Stdout = &out
+	c.Stderr = &out
+
+	result, err := a.state.Exec(ctx, c)
+	if result.ExitCode < 0 || ctx.Err() != nil {
+		return result.ExitCode, out.String(), err
+	}
+	return result.ExitCode, out.String(), nil
+}
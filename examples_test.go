@@ -0,0 +1,86 @@
+package clio
+
+import (
+	"testing"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newExamplesTestApp(examples ...Example) (Application, *cobra.Command) {
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithExamplesCommand())
+	root := app.SetupRootCommand(&cobra.Command{})
+
+	greet := &cobra.Command{
+		Use:     "greet",
+		Example: "  app greet",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+	}
+	app.AddExamples(greet, examples...)
+	root.AddCommand(app.SetupCommand(greet))
+	return app, root
+}
+
+func Test_Application_AddExamples(t *testing.T) {
+	_, root := newExamplesTestApp(
+		Example{Description: "greet the world", Args: []string{"greet", "--name", "the world"}},
+		Example{Args: []string{"greet", "--fail"}, ExitCode: 2},
+	)
+	greet, _, err := root.Find([]string{"greet"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "  app greet\n\n  # greet the world\n  app greet --name 'the world'\n\n  app greet --fail", greet.Example)
+	assert.Equal(t, []Example{
+		{Description: "greet the world", Args: []string{"greet", "--name", "the world"}},
+		{Args: []string{"greet", "--fail"}, ExitCode: 2},
+	}, AllExamples(root))
+	assert.Empty(t, Examples(root))
+}
+
+func Test_quoteExampleArg(t *testing.T) {
+	assert.Equal(t, "--depth=2", quoteExampleArg("--depth=2"))
+	assert.Equal(t, "''", quoteExampleArg(""))
+	assert.Equal(t, "'*.go'", quoteExampleArg("*.go"))
+	assert.Equal(t, `'it'\''s'`, quoteExampleArg("it's"))
+}
+
+func Test_Application_examplesTest(t *testing.T) {
+	useTestChildProcess(t)
+	defer func(enabled bool) { color.Enable = enabled }(color.Enable)
+	color.Enable = false
+
+	run := func(examples []Example, args ...string) (string, int) {
+		app, root := newExamplesTestApp(examples...)
+		return executeTestApp(app, root, args...)
+	}
+
+	passing := []Example{
+		{Description: "greet", Args: []string{"greet"}},
+		{Description: "fail to greet", Args: []string{"greet", "--exit=2"}, ExitCode: 2},
+		{Description: "greet a remote host", Args: []string{"greet", "--remote"}, Manual: true},
+	}
+	out, code := run(passing, "examples", "test")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "PASS app greet\n")
+	assert.Contains(t, out, "PASS app greet --exit=2\n")
+	assert.Contains(t, out, "SKIP app greet --remote\n")
+	assert.NotContains(t, out, "ran:")
+
+	out, code = run(passing, "examples")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "app greet\napp greet --exit=2\napp greet --remote\n", out)
+
+	failing := []Example{{Args: []string{"greet", "--exit=2"}}}
+	out, code = run(failing, "examples", "test", "greet")
+	assert.Equal(t, ExitCodeError, code)
+	assert.Contains(t, out, "FAIL app greet --exit=2: exited with code 2 (expected 0)")
+	assert.Contains(t, out, "    ran: greet --exit=2")
+	assert.Contains(t, out, "1 of 1 examples failed")
+
+	_, code = run(failing, "examples", "test", "nope")
+	assert.Equal(t, ExitCodeError, code)
+}
@@ -0,0 +1,118 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boss-net/go-logger"
+	"github.com/boss-net/go-logger/adapter/discard"
+)
+
+// ExecResult describes a completed child process (see State.Exec).
+type ExecResult struct {
+	ExitCode int           // the exit code, or -1 if the process did not start or was killed
+	Duration time.Duration // from start until exit
+}
+
+// Exec runs the external command, returning once it exits. Output streams that are not set on the command (Stdout
+// and Stderr) are logged line-by-line (at debug level, tagged with the command name as the component and the stream),
+// with the usual redaction applied. Canceling the context kills the process along with any processes it started (its
+// process group). The run is logged with the exit code and duration, which are also returned.
+func (s *State) Exec(ctx context.Context, cmd *exec.Cmd) (ExecResult, error) {
+	log := s.currentLogger()
+	if log == nil {
+		log = discard.New()
+	}
+	name := filepath.Base(cmd.Path)
+	log = log.Nested("component", name)
+
+	if cmd.Stdout == nil {
+		w := newLineLogger(log.Nested("stream", "stdout"))
+		defer w.Flush()
+		cmd.Stdout = w
+	}
+	if cmd.Stderr == nil {
+		w := newLineLogger(log.Nested("stream", "stderr"))
+		defer w.Flush()
+		cmd.Stderr = w
+	}
+	setProcessGroup(cmd)
+	setChildPriority(cmd, s.currentConfig().Priority)
+
+	log.Debugf("running %s", strings.Join(cmd.Args, " "))
+	started := now()
+	if err := cmd.Start(); err != nil {
+		return ExecResult{ExitCode: -1}, fmt.Errorf("unable to run %s: %w", name, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := killProcessGroup(cmd); err != nil {
+				log.Debugf("unable to kill process group: %v", err)
+			}
+		case <-done:
+		}
+	}()
+	err := cmd.Wait()
+	close(done)
+
+	result := ExecResult{
+		ExitCode: cmd.ProcessState.ExitCode(),
+		Duration: since(started),
+	}
+	log.WithFields("exit-code", result.ExitCode, "duration", result.Duration.Round(time.Millisecond)).Debugf("%s exited", name)
+
+	switch {
+	case ctx.Err() != nil:
+		return result, fmt.Errorf("%s was stopped: %w", name, ctx.Err())
+	case err != nil:
+		return result, fmt.Errorf("%s failed: %w", name, err)
+	}
+	return result, nil
+}
+
+// lineLogger logs each line written to it.
+type lineLogger struct {
+	lock sync.Mutex
+	log  logger.Logger
+	buf  bytes.Buffer
+}
+
+func newLineLogger(log logger.Logger) *lineLogger {
+	return &lineLogger{log: log}
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.buf.Write(p)
+	for {
+		i := bytes.IndexByte(l.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := string(l.buf.Next(i + 1))
+		l.log.Debug(strings.TrimRight(line, "\r\n"))
+	}
+	return len(p), nil
+}
+
+// Flush logs any remaining output that did not end with a newline.
+func (l *lineLogger) Flush() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.buf.Len() > 0 {
+		l.log.Debug(strings.TrimRight(l.buf.String(), "\r\n"))
+		l.buf.Reset()
+	}
+}
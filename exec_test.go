@@ -0,0 +1,73 @@
+package clio
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/go-logger"
+	"github.com/boss-net/go-logger/adapter/discard"
+	"github.com/boss-net/go-logger/adapter/redact"
+)
+
+func TestState_Exec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+
+	rec := &recordingExporter{}
+	s := &State{Logger: redact.New(newTelemetryLogger(discard.New(), rec, logger.DebugLevel, "app"), redact.NewStore("s3cr3t"))}
+
+	result, err := s.Exec(context.Background(), exec.Command("sh", "-c", `echo "token=s3cr3t"; echo warning >&2; printf partial`))
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.ExitCode)
+	assert.Greater(t, result.Duration, time.Duration(0))
+
+	lines := map[string]string{}
+	for _, r := range rec.logs {
+		if stream, ok := r.Attributes["stream"]; ok {
+			assert.Equal(t, "sh", r.Attributes["component"])
+			lines[r.Message] = stream.(string)
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"token=*******": "stdout",
+		"warning":       "stderr",
+		"partial":       "stdout",
+	}, lines)
+}
+
+func TestState_Exec_failure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+
+	s := &State{}
+	result, err := s.Exec(context.Background(), exec.Command("sh", "-c", "exit 3"))
+	require.Error(t, err)
+	assert.Equal(t, 3, result.ExitCode)
+
+	_, err = s.Exec(context.Background(), exec.Command("this-command-does-not-exist"))
+	require.Error(t, err)
+}
+
+func TestState_Exec_cancelKillsProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	s := &State{}
+	started := time.Now()
+	// the child sleep holds the output pipe open, so this only returns early if the whole group is killed
+	_, err := s.Exec(ctx, exec.Command("sh", "-c", "sleep 10; echo done"))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), 5*time.Second)
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package clio
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the command in a new process group, so that it can be killed along with its children.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// setChildPriority does nothing, since child processes inherit the priority of the process (see setProcessPriority).
+func setChildPriority(*exec.Cmd, *PriorityConfig) {}
@@ -0,0 +1,39 @@
+//go:build windows
+
+package clio
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// setProcessGroup starts the command in a new process group, so that it can be killed along with its children.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= windows.CREATE_NEW_PROCESS_GROUP
+}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	// windows has no process group signals, however taskkill can end the process tree
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
+
+// setChildPriority starts the command with the configured priority class, since windows child processes only inherit
+// lowered priority classes.
+func setChildPriority(cmd *exec.Cmd, cfg *PriorityConfig) {
+	if cfg == nil || cfg.Nice == 0 {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= priorityClass(cfg.Nice)
+}
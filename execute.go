@@ -0,0 +1,160 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gookit/color"
+	"github.com/hashicorp/go-multierror"
+)
+
+const (
+	// ExitCodeError is the exit code for a command that failed.
+	ExitCodeError = 1
+	// ExitCodeInterrupted is the exit code for a command that was interrupted (by SIGINT or SIGTERM).
+	ExitCodeInterrupted = 130
+)
+
+// ExitCoder is implemented by errors that determine the exit code of the application.
+type ExitCoder interface {
+	ExitCode() int
+}
+
+// ExitError is an error with the exit code the application exits with when a command fails with it.
+type ExitError struct {
+	Err  error
+	Code int
+}
+
+var _ ExitCoder = (*ExitError)(nil)
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// ErrorPresenter shows the error a command failed with to the user.
+type ErrorPresenter func(w io.Writer, err error)
+
+var _ ErrorPresenter = DefaultErrorPresenter
+
+// DefaultErrorPresenter writes the error on a single line, prefixed with "error:".
+func DefaultErrorPresenter(w io.Writer, err error) {
+	_, _ = fmt.Fprintf(w, "%s %v\n", color.Red.Sprint("error:"), err)
+}
+
+// errorExitCode maps errors matching the target (see errors.Is) to an exit code.
+type errorExitCode struct {
+	target error
+	code   int
+}
+
+// Execute runs the root command of the application and exits the process with the resulting exit code. This replaces
+// the usual main.go boilerplate:
+//
+//	if err := root.Execute(); err != nil {
+//		os.Exit(1)
+//	}
+func Execute(app Application) {
+	os.Exit(app.Execute(context.Background()))
+}
+
+// Execute runs the root command with the given context, which is canceled on SIGINT or SIGTERM (a second signal
+// exits immediately). The error the command failed with is shown with the configured ErrorPresenter (redacted), and
+// the exit code is returned: 0 on success, the code mapped with SetupConfig.WithErrorExitCode or given by an
+// ExitCoder error, ExitCodeInterrupted when interrupted, and otherwise ExitCodeError.
+func (a *application) Execute(ctx context.Context) int {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-signals:
+			// the first signal gives the command a chance to shutdown gracefully...
+			a.state.Cancel(CancelInterrupt)
+			cancel()
+		case <-done:
+			return
+		}
+		select {
+		case <-signals:
+			// ...the second does not wait
+			os.Exit(ExitCodeInterrupted)
+		case <-done:
+		}
+	}()
+
+	// the output may have been replaced since the root command was set up
+	a.cobraMessages.wrap(a.root)
+
+	err := a.root.ExecuteContext(ctx)
+	var forwarded *forwardedExitError
+	if err != nil && !errors.As(err, &forwarded) {
+		a.presentError(a.root.ErrOrStderr(), err)
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		// note: the eventloop does not wait for the worker once interrupted, so there may be no error
+		return ExitCodeInterrupted
+	case err != nil:
+		return a.exitCode(err)
+	}
+	return 0
+}
+
+// presentError shows the error with the configured presenter, redacting all secrets.
+func (a *application) presentError(w io.Writer, err error) {
+	present := a.setupConfig.ErrorPresenter
+	if present == nil {
+		present = DefaultErrorPresenter
+	}
+
+	var merr *multierror.Error
+	if errors.As(err, &merr) && len(merr.Errors) == 1 {
+		// the eventloop collects errors, however there is usually only the one from the command
+		err = merr.Errors[0]
+	}
+
+	var buf bytes.Buffer
+	present(&buf, err)
+
+	out := buf.String()
+	if a.state.RedactStore != nil {
+		out = a.state.RedactStore.RedactString(out)
+	}
+	_, _ = io.WriteString(w, out)
+}
+
+func (a *application) exitCode(err error) int {
+	for _, m := range a.setupConfig.errorExitCodes {
+		if errors.Is(err, m.target) {
+			return m.code
+		}
+	}
+	var coder ExitCoder
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+	return ExitCodeError
+}
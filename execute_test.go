@@ -0,0 +1,104 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+var errTestNotFound = errors.New("not found")
+
+func Test_Application_Execute(t *testing.T) {
+	defer func(enabled bool) { color.Enable = enabled }(color.Enable)
+	color.Enable = false
+
+	tests := []struct {
+		name      string
+		err       error
+		cfg       func(*SetupConfig)
+		wantCode  int
+		wantError string
+	}{
+		{
+			name:     "success",
+			wantCode: 0,
+		},
+		{
+			name:      "error",
+			err:       errors.New("failed"),
+			wantCode:  ExitCodeError,
+			wantError: "error: failed\n",
+		},
+		{
+			name:      "exit coder",
+			err:       fmt.Errorf("wrapped: %w", &ExitError{Err: errors.New("policy violation"), Code: 3}),
+			wantCode:  3,
+			wantError: "error: wrapped: policy violation\n",
+		},
+		{
+			name: "mapped error",
+			err:  fmt.Errorf("image: %w", errTestNotFound),
+			cfg: func(c *SetupConfig) {
+				c.WithErrorExitCode(errTestNotFound, 4)
+			},
+			wantCode:  4,
+			wantError: "error: image: not found\n",
+		},
+		{
+			name: "custom presenter with redaction",
+			err:  errors.New("bad token s3cret"),
+			cfg: func(c *SetupConfig) {
+				c.WithErrorPresenter(func(w io.Writer, err error) {
+					_, _ = fmt.Fprintf(w, "oops: %v\n", err)
+				}).WithInitializers(func(s *State) error {
+					s.RedactStore.Add("s3cret")
+					return nil
+				})
+			},
+			wantCode:  ExitCodeError,
+			wantError: "oops: bad token *******\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewSetupConfig(Identification{Name: "app"}).WithNoBus()
+			if tt.cfg != nil {
+				tt.cfg(cfg)
+			}
+			app := New(*cfg)
+			root := app.SetupRootCommand(&cobra.Command{
+				RunE: func(*cobra.Command, []string) error { return tt.err },
+			})
+			stderr := &bytes.Buffer{}
+			root.SetErr(stderr)
+			root.SetArgs(nil)
+
+			assert.Equal(t, tt.wantCode, app.Execute(context.Background()))
+			assert.Equal(t, tt.wantError, stderr.String())
+		})
+	}
+}
+
+func Test_Application_Execute_interrupted(t *testing.T) {
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	root := app.SetupRootCommand(&cobra.Command{
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cancel()
+			<-cmd.Context().Done()
+			return cmd.Context().Err()
+		},
+	})
+	root.SetErr(io.Discard)
+	root.SetArgs(nil)
+
+	assert.Equal(t, ExitCodeInterrupted, app.Execute(ctx))
+}
@@ -0,0 +1,127 @@
+package clio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrFaultInjected is matched (see errors.Is) by all errors returned by State.Fault.
+var ErrFaultInjected = errors.New("fault injected")
+
+// FaultError is the error injected at a fault point (see State.Fault).
+type FaultError struct {
+	Point   string
+	Message string
+}
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("fault injected at %s: %s", e.Point, e.Message)
+}
+
+func (e *FaultError) Is(target error) bool {
+	return target == ErrFaultInjected
+}
+
+// faultAction is a single step of an injected fault.
+type faultAction struct {
+	delay time.Duration // wait this long (when set)
+	err   string        // then fail with this message (when set)
+	panic string        // then panic with this message (when set)
+}
+
+// parseFault parses the fault to inject at a fault point (see DevelopmentConfig.Faults): a comma separated list of
+// actions run in order, each "delay:<duration>", "error:<message>", or "panic:<message>".
+func parseFault(spec string) ([]faultAction, error) {
+	var actions []faultAction
+	for _, part := range strings.Split(spec, ",") {
+		kind, arg, _ := strings.Cut(strings.TrimSpace(part), ":")
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		arg = strings.TrimSpace(arg)
+		switch kind {
+		case "delay":
+			d, err := time.ParseDuration(arg)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("invalid fault delay %q: expected a duration (e.g. 2s)", arg)
+			}
+			actions = append(actions, faultAction{delay: d})
+		case "error":
+			if arg == "" {
+				arg = "error"
+			}
+			actions = append(actions, faultAction{err: arg})
+		case "panic":
+			if arg == "" {
+				arg = "panic"
+			}
+			actions = append(actions, faultAction{panic: arg})
+		default:
+			return nil, fmt.Errorf("invalid fault %q: expected delay:<duration>, error:<message>, or panic:<message>", part)
+		}
+	}
+	return actions, nil
+}
+
+// setupFaults determines the faults to inject from the development config, which may only name fault points
+// registered by the application (see SetupConfig.WithFaultPoints).
+func (s *State) setupFaults(points []string) error {
+	s.faults = nil
+	if s.Config.Dev == nil || len(s.Config.Dev.Faults) == 0 {
+		return nil
+	}
+
+	faults := map[string][]faultAction{}
+	for name, spec := range s.Config.Dev.Faults {
+		if !contains(points, name) {
+			available := append([]string(nil), points...)
+			sort.Strings(available)
+			return fmt.Errorf("unknown fault point %q in dev.faults (available: %s)", name, strings.Join(available, ", "))
+		}
+		actions, err := parseFault(spec)
+		if err != nil {
+			return fmt.Errorf("invalid dev.faults.%s: %w", name, err)
+		}
+		faults[name] = actions
+	}
+	s.faults = faults
+
+	if s.Logger != nil {
+		var names []string
+		for name := range faults {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		s.Logger.Warnf("fault injection is enabled for: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// Fault is a named point in the application where a fault may be injected for testing failure paths (see
+// SetupConfig.WithFaultPoints), e.g. "if err := state.Fault(ctx, "download"); err != nil { return err }". When a fault
+// is configured for the point (with "dev.faults.<name>" in the application config) this waits, returns an error
+// (matching ErrFaultInjected), or panics as configured; otherwise this returns nil immediately. Delays end early when
+// the context is canceled, returning the context error.
+func (s *State) Fault(ctx context.Context, name string) error {
+	actions := s.faults[name]
+	for _, a := range actions {
+		if a.delay > 0 {
+			timer := time.NewTimer(a.delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		if a.err != "" {
+			return &FaultError{Point: name, Message: a.err}
+		}
+		if a.panic != "" {
+			panic(fmt.Sprintf("fault injected at %s: %s", name, a.panic))
+		}
+	}
+	return nil
+}
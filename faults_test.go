@@ -0,0 +1,98 @@
+package clio
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseFault(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []faultAction
+		wantErr string
+	}{
+		{spec: "error:timeout", want: []faultAction{{err: "timeout"}}},
+		{spec: "delay:2s, error:timeout", want: []faultAction{{delay: 2 * time.Second}, {err: "timeout"}}},
+		{spec: "panic", want: []faultAction{{panic: "panic"}}},
+		{spec: "delay:soon", wantErr: "invalid fault delay"},
+		{spec: "explode", wantErr: "invalid fault"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseFault(tt.spec)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func runFaultTestApp(t *testing.T, config string, fn func(s *State) error) error {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "app.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(config), 0o600))
+
+	setup := NewSetupConfig(Identification{Name: "app"}).
+		WithNoBus().
+		WithFaultPoints("download", "upload")
+	setup.FangsConfig.File = file
+
+	app := New(*setup)
+	root := app.SetupRootCommand(&cobra.Command{
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return fn(app.(*application).State())
+		},
+	})
+	_, _, err := runTestApp(root, "")
+	return err
+}
+
+func Test_State_Fault(t *testing.T) {
+	err := runFaultTestApp(t, "dev:\n  faults:\n    download: \"delay:10ms,error:timeout\"\n", func(s *State) error {
+		started := time.Now()
+		err := s.Fault(context.Background(), "download")
+		assert.GreaterOrEqual(t, time.Since(started), 10*time.Millisecond)
+		assert.True(t, errors.Is(err, ErrFaultInjected))
+		assert.EqualError(t, err, "fault injected at download: timeout")
+
+		// no fault is configured for this point
+		assert.NoError(t, s.Fault(context.Background(), "upload"))
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func Test_State_Fault_canceled(t *testing.T) {
+	s := &State{faults: map[string][]faultAction{"download": {{delay: time.Hour}}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, s.Fault(ctx, "download"), context.Canceled)
+}
+
+func Test_State_Fault_panic(t *testing.T) {
+	s := &State{faults: map[string][]faultAction{"download": {{panic: "boom"}}}}
+	assert.PanicsWithValue(t, "fault injected at download: boom", func() {
+		_ = s.Fault(context.Background(), "download")
+	})
+}
+
+func Test_State_Fault_invalidConfig(t *testing.T) {
+	noop := func(*State) error { return nil }
+
+	err := runFaultTestApp(t, "dev:\n  faults:\n    database: \"error:down\"\n", noop)
+	require.ErrorContains(t, err, `unknown fault point "database" in dev.faults (available: download, upload)`)
+
+	err = runFaultTestApp(t, "dev:\n  faults:\n    download: \"delay:later\"\n", noop)
+	require.ErrorContains(t, err, "invalid dev.faults.download")
+}
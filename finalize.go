@@ -0,0 +1,115 @@
+package clio
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/spf13/cobra"
+)
+
+// RunSummary describes a completed command run, as given to finalizers (see SetupConfig.WithFinalizers) and as the data
+// of notification templates (see SetupConfig.WithNotifications).
+type RunSummary struct {
+	App        string        `json:"app"`
+	Version    string        `json:"version,omitempty"`
+	Host       string        `json:"host,omitempty"`
+	Command    string        `json:"command"`
+	Args       []string      `json:"args,omitempty"`
+	Invocation string        `json:"invocation"`
+	Started    time.Time     `json:"started"`
+	Duration   time.Duration `json:"duration"`
+	Succeeded  bool          `json:"succeeded"`
+	ExitCode   int           `json:"exit-code"`
+	Error      string        `json:"error,omitempty"`
+	Warnings   []Warning     `json:"warnings,omitempty"`
+	// Cancelled is why the run ended early (if it did).
+	Cancelled CancelReason `json:"cancelled,omitempty"`
+	// Decisions are the policy decisions made during the run (see State.Decide).
+	Decisions []PolicyDecision `json:"decisions,omitempty"`
+	// Result is the result of the command, as given to State.SetResult (if at all).
+	Result any `json:"result,omitempty"`
+}
+
+// Status is "succeeded", "failed", or "interrupted" (when cancelled for any reason).
+func (r RunSummary) Status() string {
+	switch {
+	case r.Succeeded:
+		return "succeeded"
+	case r.ExitCode == ExitCodeInterrupted || r.Cancelled != "":
+		return "interrupted"
+	}
+	return "failed"
+}
+
+// runResult holds the result of the command being run (see State.SetResult).
+type runResult struct {
+	lock  sync.Mutex
+	value any
+}
+
+// SetResult records the result of the command, which is included in the summary given to finalizers (e.g. to render
+// notifications with).
+func (s *State) SetResult(result any) {
+	s.result.lock.Lock()
+	defer s.result.lock.Unlock()
+	s.result.value = result
+}
+
+func (s *State) runResult() any {
+	s.result.lock.Lock()
+	defer s.result.lock.Unlock()
+	return s.result.value
+}
+
+// runFinalizers gives the summary of the completed run to each finalizer. Finalizers that fail raise a warning rather
+// than failing the run, since the command itself has already completed.
+func (a *application) runFinalizers(ctx context.Context, cmd *cobra.Command, args []string, started time.Time, err error) {
+	if len(a.setupConfig.Finalizers) == 0 {
+		return
+	}
+
+	host, _ := os.Hostname()
+	summary := RunSummary{
+		App:        a.setupConfig.ID.Name,
+		Version:    a.setupConfig.ID.Version,
+		Host:       host,
+		Command:    cmd.CommandPath(),
+		Args:       a.redactHistory(args),
+		Invocation: a.state.InvocationID(),
+		Started:    started,
+		Duration:   since(started).Round(time.Millisecond),
+		Succeeded:  err == nil && ctx.Err() == nil,
+		Warnings:   a.state.Warnings(),
+		Decisions:  a.state.PolicyDecisions(),
+		Result:     a.state.runResult(),
+		Cancelled:  a.state.cancelReason(ctx),
+	}
+	switch {
+	case ctx.Err() != nil:
+		summary.ExitCode = (&CancelledError{Reason: summary.Cancelled}).ExitCode()
+	case err != nil:
+		summary.ExitCode = a.exitCode(err)
+	}
+	if err != nil {
+		var merr *multierror.Error
+		if errors.As(err, &merr) && len(merr.Errors) == 1 {
+			// the eventloop collects errors, however there is usually only the one from the command
+			err = merr.Errors[0]
+		}
+		summary.Error = err.Error()
+		if a.state.RedactStore != nil {
+			summary.Error = a.state.RedactStore.RedactString(summary.Error)
+		}
+	}
+
+	for _, finalize := range a.setupConfig.Finalizers {
+		if err := finalize(&a.state, summary); err != nil {
+			a.state.Logger.Debugf("finalizer failed: %v", err)
+			a.state.Warn("finalizer-failed", err.Error(), nil)
+		}
+	}
+}
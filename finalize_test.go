@@ -0,0 +1,78 @@
+package clio
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Application_runsFinalizers(t *testing.T) {
+	var summaries []RunSummary
+	run := func(args ...string) (*State, error) {
+		app := New(*NewSetupConfig(Identification{Name: "app", Version: "1.0"}).
+			WithNoBus().
+			WithFinalizers(
+				func(_ *State, run RunSummary) error {
+					summaries = append(summaries, run)
+					return nil
+				},
+				func(_ *State, _ RunSummary) error {
+					return errors.New("unable to report")
+				},
+			))
+
+		root := app.SetupRootCommand(&cobra.Command{})
+		root.AddCommand(app.SetupCommand(&cobra.Command{
+			Use: "scan",
+			RunE: app.RunWithState(func(_ context.Context, s *State, args []string) error {
+				s.SetResult(map[string]int{"findings": 2})
+				if len(args) > 0 {
+					return errors.New("scan failed")
+				}
+				return nil
+			}),
+		}))
+		root.SetArgs(append([]string{"scan"}, args...))
+		err := root.Execute()
+		return &app.(*application).state, err
+	}
+
+	state, err := run()
+	require.NoError(t, err, "finalizers do not fail the command")
+	var codes []string
+	for _, w := range state.Warnings() {
+		codes = append(codes, w.Code)
+	}
+	assert.Equal(t, []string{"finalizer-failed"}, codes)
+
+	_, err = run("fail")
+	require.ErrorContains(t, err, "scan failed")
+
+	require.Len(t, summaries, 2)
+	ok := summaries[0]
+	assert.Equal(t, "app", ok.App)
+	assert.Equal(t, "1.0", ok.Version)
+	assert.Equal(t, "app scan", ok.Command)
+	assert.True(t, ok.Succeeded)
+	assert.Equal(t, "succeeded", ok.Status())
+	assert.Equal(t, 0, ok.ExitCode)
+	assert.NotEmpty(t, ok.Invocation)
+	assert.Equal(t, map[string]int{"findings": 2}, ok.Result)
+
+	failed := summaries[1]
+	assert.False(t, failed.Succeeded)
+	assert.Equal(t, "failed", failed.Status())
+	assert.Equal(t, ExitCodeError, failed.ExitCode)
+	assert.Equal(t, "scan failed", failed.Error)
+	assert.Equal(t, []string{"fail"}, failed.Args)
+}
+
+func Test_RunSummary_Status(t *testing.T) {
+	assert.Equal(t, "interrupted", RunSummary{ExitCode: ExitCodeInterrupted}.Status())
+	assert.Equal(t, "failed", RunSummary{ExitCode: ExitCodeError}.Status())
+	assert.Equal(t, "succeeded", RunSummary{Succeeded: true}.Status())
+}
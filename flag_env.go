@@ -0,0 +1,43 @@
+package clio
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// describeFlagEnv appends the environment variable bound to the same config field as each flag (e.g. "[APP_LOG_LEVEL]")
+// to the flag usage, so help output also documents the environment variables.
+func (a *application) describeFlagEnv(flags *pflag.FlagSet, cfgs ...any) {
+	if !a.setupConfig.ShowEnvInFlagHelp {
+		return
+	}
+
+	paths := map[uintptr][]string{}
+	for _, cfg := range cfgs {
+		visitConfigFields(a.configTagName(), reflect.ValueOf(cfg), nil, nil, func(ptr uintptr, f configField) {
+			paths[ptr] = f.path
+		})
+	}
+
+	flags.VisitAll(func(flag *pflag.Flag) {
+		path, ok := paths[flagRef(flag)]
+		if !ok {
+			return
+		}
+		env := envVarName(a.setupConfig.FangsConfig.AppName, path)
+		suffix := fmt.Sprintf(" [%s]", env)
+		if !strings.HasSuffix(flag.Usage, suffix) {
+			flag.Usage += suffix
+		}
+	})
+}
+
+func (a *application) configTagName() string {
+	if tagName := a.setupConfig.FangsConfig.TagName; tagName != "" {
+		return tagName
+	}
+	return "mapstructure"
+}
@@ -0,0 +1,50 @@
+package clio
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Application_EnvInFlagHelp(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   *SetupConfig
+		flag  string
+		usage string
+	}{
+		{
+			name:  "disabled by default",
+			cfg:   NewSetupConfig(Identification{Name: "app"}).WithGlobalConfigFlag(),
+			flag:  "token",
+			usage: "the registry token",
+		},
+		{
+			name:  "nested command config",
+			cfg:   NewSetupConfig(Identification{Name: "app"}).WithGlobalConfigFlag().WithEnvInFlagHelp(),
+			flag:  "token",
+			usage: "the registry token [APP_REGISTRY_TOKEN]",
+		},
+		{
+			name:  "global config",
+			cfg:   NewSetupConfig(Identification{Name: "app"}).WithGlobalConfigFlag().WithEnvInFlagHelp(),
+			flag:  "quiet",
+			usage: "suppress all logging output [APP_LOG_QUIET]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := New(*tt.cfg)
+			root := app.SetupRootCommand(&cobra.Command{}, &requiredConfig{})
+
+			flag := root.Flags().Lookup(tt.flag)
+			if flag == nil {
+				flag = root.PersistentFlags().Lookup(tt.flag)
+			}
+			if assert.NotNil(t, flag) {
+				assert.Equal(t, tt.usage, flag.Usage)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package clio
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// FlagRulesAdder can be implemented by config structs in order to declare relationships between their flags,
+// which are enforced once the configuration is loaded (counting values given with the environment variables and config
+// files of the flags as well) and shown in the help for each flag.
+type FlagRulesAdder interface {
+	AddFlagRules(rules *FlagRules)
+}
+
+type flagRuleKind int
+
+const (
+	flagsMutuallyExclusive flagRuleKind = iota
+	flagsRequiredTogether
+	flagsOneRequired
+)
+
+type flagRule struct {
+	kind  flagRuleKind
+	flags []string
+}
+
+// FlagRules is the set of relationships between flags (referenced by their long names).
+type FlagRules struct {
+	rules []flagRule
+}
+
+// MutuallyExclusive indicates that at most one of the given flags may be set.
+func (r *FlagRules) MutuallyExclusive(flags ...string) {
+	r.rules = append(r.rules, flagRule{kind: flagsMutuallyExclusive, flags: flags})
+}
+
+// RequiredTogether indicates that if any of the given flags is set then all of them must be set.
+func (r *FlagRules) RequiredTogether(flags ...string) {
+	r.rules = append(r.rules, flagRule{kind: flagsRequiredTogether, flags: flags})
+}
+
+// OneRequired indicates that at least one of the given flags must be set.
+func (r *FlagRules) OneRequired(flags ...string) {
+	r.rules = append(r.rules, flagRule{kind: flagsOneRequired, flags: flags})
+}
+
+// collectFlagRules traverses the config object graphs (the same as flags are added) collecting all flag rules.
+func collectFlagRules(cfgs ...any) []flagRule {
+	rules := &FlagRules{}
+	for _, cfg := range cfgs {
+		addFlagRules(rules, reflect.ValueOf(cfg))
+	}
+	return rules.rules
+}
+
+func addFlagRules(rules *FlagRules, v reflect.Value) {
+	if !v.IsValid() || v.Kind() != reflect.Ptr || v.IsNil() {
+		return
+	}
+
+	if adder, ok := v.Interface().(FlagRulesAdder); ok {
+		adder.AddFlagRules(rules)
+	}
+
+	v = v.Elem()
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		f := v.Field(i)
+		if f.Kind() == reflect.Struct {
+			f = f.Addr()
+		}
+		if f.Kind() == reflect.Ptr && f.Type().Elem().Kind() == reflect.Struct {
+			addFlagRules(rules, f)
+		}
+	}
+}
+
+// describe appends the rule to the usage of each flag it references, so the rule is reflected in help output.
+func (r flagRule) describe(flags *pflag.FlagSet) {
+	for _, name := range r.flags {
+		f := flags.Lookup(name)
+		if f == nil {
+			continue
+		}
+
+		others := flagNames(without(r.flags, name))
+		switch r.kind {
+		case flagsMutuallyExclusive:
+			f.Usage += fmt.Sprintf(" (cannot be used with %s)", strings.Join(others, ", "))
+		case flagsRequiredTogether:
+			f.Usage += fmt.Sprintf(" (requires %s)", strings.Join(others, ", "))
+		case flagsOneRequired:
+			f.Usage += fmt.Sprintf(" (required unless %s is given)", strings.Join(others, " or "))
+		}
+	}
+}
+
+// validate checks the rule against the flags that were given a value by the user (see flagGiven).
+func (r flagRule) validate(flags *pflag.FlagSet, given func(f *pflag.Flag) bool) error {
+	var set, unset []string
+	for _, name := range r.flags {
+		if f := flags.Lookup(name); f != nil && given(f) {
+			set = append(set, name)
+		} else {
+			unset = append(unset, name)
+		}
+	}
+
+	switch r.kind {
+	case flagsMutuallyExclusive:
+		if len(set) > 1 {
+			return fmt.Errorf("flags %s cannot be used together", strings.Join(flagNames(set), " and "))
+		}
+	case flagsRequiredTogether:
+		if len(set) > 0 && len(unset) > 0 {
+			return fmt.Errorf("flags %s must be used together (missing %s)", strings.Join(flagNames(r.flags), ", "), strings.Join(flagNames(unset), ", "))
+		}
+	case flagsOneRequired:
+		if len(set) == 0 {
+			return fmt.Errorf("at least one of the flags %s is required", strings.Join(flagNames(r.flags), ", "))
+		}
+	}
+	return nil
+}
+
+// validateFlagRules checks all rules for the command once the given configs are loaded: the rules of its flags, the
+// rules of persistent flags of all parent commands, and the rules of the default command (when the root command will
+// run it). Rules of the local flags of parent commands do not apply, since the command does not have those flags.
+func (a *application) validateFlagRules(cmd *cobra.Command, cfgs ...any) error {
+	rules := append(append([]flagRule{}, a.flagRules[cmd.Flags()]...), a.flagRules[cmd.PersistentFlags()]...)
+	for c := cmd.Parent(); c != nil; c = c.Parent() {
+		rules = append(rules, a.flagRules[c.PersistentFlags()]...)
+	}
+	if d := a.defaultCommandFor(cmd); d != nil {
+		rules = append(append(rules, a.flagRules[d.cmd.Flags()]...), a.flagRules[d.cmd.PersistentFlags()]...)
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	given := a.flagGiven(cfgs...)
+	for _, rule := range rules {
+		if err := rule.validate(cmd.Flags(), given); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flagGiven returns whether the user gave a value for a flag: with the flag itself, or for the config field bound to
+// the flag with its environment variable or within a config file.
+func (a *application) flagGiven(cfgs ...any) func(f *pflag.Flag) bool {
+	paths := map[uintptr][]string{}
+	for _, cfg := range cfgs {
+		visitConfigFields(a.configTagName(), reflect.ValueOf(cfg), nil, nil, func(ptr uintptr, f configField) {
+			paths[ptr] = f.path
+		})
+	}
+
+	return func(f *pflag.Flag) bool {
+		if f.Changed {
+			return true
+		}
+		path, ok := paths[flagRef(f)]
+		if !ok {
+			return false
+		}
+		if os.Getenv(envVarName(a.setupConfig.FangsConfig.AppName, path)) != "" {
+			return true
+		}
+		return a.state.ConfigSource(strings.Join(path, ".")) != ""
+	}
+}
+
+// addFlagRules describes the rules declared by the configs within the usage of the flags, and registers them to be
+// enforced for the commands the flags belong to.
+func (a *application) addFlagRules(flags *pflag.FlagSet, cfgs ...any) {
+	rules := collectFlagRules(cfgs...)
+	if len(rules) == 0 {
+		return
+	}
+	for _, rule := range rules {
+		rule.describe(flags)
+	}
+
+	if a.flagRules == nil {
+		a.flagRules = make(map[*pflag.FlagSet][]flagRule)
+	}
+	a.flagRules[flags] = append(a.flagRules[flags], rules...)
+}
+
+func flagNames(names []string) []string {
+	var out []string
+	for _, n := range names {
+		out = append(out, "--"+n)
+	}
+	return out
+}
+
+func without(values []string, value string) []string {
+	var out []string
+	for _, v := range values {
+		if v != value {
+			out = append(out, v)
+		}
+	}
+	return out
+}
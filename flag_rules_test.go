@@ -0,0 +1,171 @@
+package clio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/fangs"
+)
+
+type sourceConfig struct {
+	File     string
+	Image    string
+	Username string
+	Password string
+}
+
+var _ interface {
+	fangs.FlagAdder
+	FlagRulesAdder
+} = (*sourceConfig)(nil)
+
+func (s *sourceConfig) AddFlags(flags fangs.FlagSet) {
+	flags.StringVarP(&s.File, "file", "", "the file to use")
+	flags.StringVarP(&s.Image, "image", "", "the image to use")
+	flags.StringVarP(&s.Username, "username", "", "the registry username")
+	flags.StringVarP(&s.Password, "password", "", "the registry password")
+}
+
+func (s *sourceConfig) AddFlagRules(rules *FlagRules) {
+	rules.MutuallyExclusive("file", "image")
+	rules.OneRequired("file", "image")
+	rules.RequiredTogether("username", "password")
+}
+
+type nestedSourceConfig struct {
+	Source sourceConfig
+}
+
+func Test_FlagRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			args: []string{"--image", "alpine", "--username", "u", "--password", "p"},
+		},
+		{
+			name:    "mutually exclusive",
+			args:    []string{"--image", "alpine", "--file", "f"},
+			wantErr: "flags --file and --image cannot be used together",
+		},
+		{
+			name:    "one required",
+			args:    []string{},
+			wantErr: "at least one of the flags --file, --image is required",
+		},
+		{
+			name:    "required together",
+			args:    []string{"--file", "f", "--username", "u"},
+			wantErr: "flags --username, --password must be used together (missing --password)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := New(*NewSetupConfig(Identification{Name: "app", Version: "1.0"}))
+
+			var ran bool
+			root := app.SetupRootCommand(&cobra.Command{
+				Run: func(cmd *cobra.Command, args []string) {
+					ran = true
+				},
+			}, &nestedSourceConfig{})
+
+			root.SetArgs(tt.args)
+			err := root.Execute()
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				assert.False(t, ran)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, ran)
+		})
+	}
+}
+
+func Test_FlagRules_help(t *testing.T) {
+	app := New(*NewSetupConfig(Identification{Name: "app", Version: "1.0"}))
+
+	root := app.SetupRootCommand(&cobra.Command{
+		Run: func(cmd *cobra.Command, args []string) {},
+	})
+
+	child := app.SetupCommand(&cobra.Command{
+		Use: "child",
+		Run: func(cmd *cobra.Command, args []string) {},
+	})
+	app.AddPersistentFlags(root, &sourceConfig{})
+	root.AddCommand(child)
+
+	usage := child.UsageString()
+	assert.Contains(t, usage, "the file to use (cannot be used with --image) (required unless --image is given)")
+	assert.Contains(t, usage, "the registry username (requires --password)")
+
+	// rules from persistent flags apply to children
+	root.SetArgs([]string{"child", "--file", "f", "--image", "i"})
+	require.EqualError(t, root.Execute(), "flags --file and --image cannot be used together")
+}
+
+func Test_FlagRules_localFlagsOfParent(t *testing.T) {
+	app := New(*NewSetupConfig(Identification{Name: "app", Version: "1.0"}))
+
+	root := app.SetupRootCommand(&cobra.Command{
+		Run: func(cmd *cobra.Command, args []string) {},
+	}, &sourceConfig{})
+
+	var ran bool
+	child := app.SetupCommand(&cobra.Command{
+		Use: "child",
+		Run: func(cmd *cobra.Command, args []string) {
+			ran = true
+		},
+	})
+	root.AddCommand(child)
+
+	// rules from local flags of the root do not apply to children (which do not have the flags)
+	root.SetArgs([]string{"child"})
+	require.NoError(t, root.Execute())
+	assert.True(t, ran)
+}
+
+func Test_FlagRules_envAndConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("username: u\n"), 0o600))
+
+	run := func(args ...string) error {
+		cfg := NewSetupConfig(Identification{Name: "app", Version: "1.0"})
+		cfg.FangsConfig.File = file
+		app := New(*cfg)
+		root := app.SetupRootCommand(&cobra.Command{
+			Run: func(cmd *cobra.Command, args []string) {},
+		}, &sourceConfig{})
+		_, _, err := runTestApp(root, "", args...)
+		return err
+	}
+
+	// values given with environment variables and config files count as given
+	t.Setenv("APP_IMAGE", "alpine")
+	require.NoError(t, run("--password", "p"))
+	require.EqualError(t, run(), "flags --username, --password must be used together (missing --password)")
+	require.EqualError(t, run("--file", "f", "--password", "p"), "flags --file and --image cannot be used together")
+}
+
+func Test_FlagRules_AddFlags(t *testing.T) {
+	app := New(*NewSetupConfig(Identification{Name: "app", Version: "1.0"}))
+	root := app.SetupRootCommand(&cobra.Command{
+		Run: func(cmd *cobra.Command, args []string) {},
+	})
+	app.AddFlags(root.Flags(), &sourceConfig{})
+
+	assert.Contains(t, root.UsageString(), "the file to use (cannot be used with --image)")
+	_, _, err := runTestApp(root, "", "--file", "f", "--image", "i")
+	require.EqualError(t, err, "flags --file and --image cannot be used together")
+}
@@ -7,12 +7,17 @@ require (
 	github.com/boss-net/go-logger v0.0.0-20230531193951-db5ae83e7dbe
 	github.com/gookit/color v1.5.3
 	github.com/hashicorp/go-multierror v1.1.1
+	github.com/open-policy-agent/opa v0.53.1
 	github.com/pborman/indent v1.2.1
+	github.com/pelletier/go-toml/v2 v2.0.6
 	github.com/pkg/profile v1.7.0
+	github.com/sirupsen/logrus v1.8.1
 	github.com/spf13/cobra v1.7.0
 	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.8.4
 	github.com/wagoodman/go-partybus v0.0.0-20230516145632-8ccac152c651
+	go.starlark.net v0.0.0-20230525235612-a134d8f9ddca
+	golang.org/x/sys v0.9.0
 	golang.org/x/term v0.9.0
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -33,10 +38,8 @@ require (
 	github.com/mgutz/ansi v0.0.0-20200706080929-d51e80ef957d // indirect
 	github.com/mitchellh/go-homedir v1.1.0 // indirect
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
-	github.com/pelletier/go-toml/v2 v2.0.6 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/scylladb/go-set v1.0.2 // indirect
-	github.com/sirupsen/logrus v1.8.1 // indirect
 	github.com/spf13/afero v1.9.3 // indirect
 	github.com/spf13/cast v1.5.0 // indirect
 	github.com/spf13/jwalterweatherman v1.1.0 // indirect
@@ -44,7 +47,6 @@ require (
 	github.com/stretchr/objx v0.5.0 // indirect
 	github.com/subosito/gotenv v1.4.2 // indirect
 	github.com/xo/terminfo v0.0.0-20210125001918-ca9a967f8778 // indirect
-	golang.org/x/sys v0.9.0 // indirect
 	golang.org/x/text v0.5.0 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
 )
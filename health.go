@@ -0,0 +1,170 @@
+package clio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// HealthCheck reports whether a dependency of the application (e.g. a database connection) is healthy.
+type HealthCheck func(ctx context.Context) error
+
+// healthChecks tracks the readiness of the application: initializers must have completed and every registered
+// health check must pass.
+type healthChecks struct {
+	lock        sync.RWMutex
+	initialized bool
+	checks      map[string]HealthCheck
+}
+
+func (h *healthChecks) setInitialized() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.initialized = true
+}
+
+// AddHealthCheck registers a check that must pass for the application to be ready (see HealthHandler). Adding a
+// check with the same name as an existing check replaces it.
+func (s *State) AddHealthCheck(name string, check HealthCheck) {
+	s.health.lock.Lock()
+	defer s.health.lock.Unlock()
+	if s.health.checks == nil {
+		s.health.checks = map[string]HealthCheck{}
+	}
+	s.health.checks[name] = check
+}
+
+// HealthHandler serves the health of the application over http, for apps embedding an http server (e.g. for
+// kubernetes probes):
+//
+//	GET /healthz  liveness, which is always ok while the process is serving
+//	GET /readyz   readiness, which is ok once all initializers have completed and all health checks pass (503 otherwise)
+//	GET /version  version information (as json)
+//	GET /metrics  runtime metrics in the prometheus text format (only when enabled, see WithMetrics)
+//
+// All error messages are redacted.
+type HealthHandler struct {
+	state   *State
+	metrics bool
+	mux     *http.ServeMux
+}
+
+var _ http.Handler = (*HealthHandler)(nil)
+
+// HealthHandler returns a handler serving the health of the application.
+func (s *State) HealthHandler() *HealthHandler {
+	h := &HealthHandler{
+		state: s,
+		mux:   http.NewServeMux(),
+	}
+	h.mux.HandleFunc("/healthz", h.serveLiveness)
+	h.mux.HandleFunc("/readyz", h.serveReadiness)
+	h.mux.HandleFunc("/version", h.serveVersion)
+	h.mux.HandleFunc("/metrics", h.serveMetrics)
+	return h
+}
+
+// WithMetrics additionally serves runtime metrics at /metrics.
+func (h *HealthHandler) WithMetrics() *HealthHandler {
+	h.metrics = true
+	return h
+}
+
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *HealthHandler) serveLiveness(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = fmt.Fprintln(w, "ok")
+}
+
+// Readiness is the body of the /readyz response, with the result of each check ("ok", "pending", or the error).
+type Readiness struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+func (h *HealthHandler) serveReadiness(w http.ResponseWriter, r *http.Request) {
+	readiness := h.state.readiness(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	if !readiness.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(readiness)
+}
+
+// readiness runs all health checks.
+func (s *State) readiness(ctx context.Context) Readiness {
+	s.health.lock.RLock()
+	initialized := s.health.initialized
+	checks := make(map[string]HealthCheck, len(s.health.checks))
+	for name, check := range s.health.checks {
+		checks[name] = check
+	}
+	s.health.lock.RUnlock()
+
+	readiness := Readiness{Ready: initialized, Checks: map[string]string{"initializers": "ok"}}
+	if !initialized {
+		readiness.Checks["initializers"] = "pending"
+	}
+
+	for name, check := range checks {
+		result := "ok"
+		if err := check(ctx); err != nil {
+			readiness.Ready = false
+			result = err.Error()
+			if s.RedactStore != nil {
+				result = s.RedactStore.RedactString(result)
+			}
+		}
+		readiness.Checks[name] = result
+	}
+	return readiness
+}
+
+func (h *HealthHandler) serveVersion(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(newRuntimeInfo(h.state.id))
+}
+
+func (h *HealthHandler) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if !h.metrics {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	collector := newMetricsCollector(nil, h.state.id.Name)
+	collector.resource = h.state.environment.resourceAttributes()
+	writePrometheusMetrics(w, collector.collect(nil))
+}
+
+// writePrometheusMetrics writes the metrics as gauges in the prometheus text exposition format.
+func writePrometheusMetrics(w io.Writer, metrics []Metric) {
+	for _, m := range metrics {
+		name := strings.NewReplacer(".", "_", "-", "_").Replace(m.Name)
+
+		var keys []string
+		for k := range m.Attributes {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var labels []string
+		for _, k := range keys {
+			labels = append(labels, fmt.Sprintf("%s=%q", strings.ReplaceAll(k, ".", "_"), m.Attributes[k]))
+		}
+		var labelSet string
+		if len(labels) > 0 {
+			labelSet = "{" + strings.Join(labels, ",") + "}"
+		}
+
+		_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s%s %v\n", name, m.Description, name, name, labelSet, m.Value)
+	}
+}
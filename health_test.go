@@ -0,0 +1,97 @@
+package clio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/go-logger/adapter/redact"
+)
+
+func Test_HealthHandler(t *testing.T) {
+	state := &State{id: Identification{Name: "app", Version: "v1.0.0"}, RedactStore: redact.NewStore("s3cret")}
+	server := httptest.NewServer(state.HealthHandler())
+	defer server.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := get("/healthz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok\n", body)
+
+	readiness := func() (int, Readiness) {
+		status, body := get("/readyz")
+		var r Readiness
+		require.NoError(t, json.Unmarshal([]byte(body), &r))
+		return status, r
+	}
+
+	status, r := readiness()
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, Readiness{Checks: map[string]string{"initializers": "pending"}}, r)
+
+	state.health.setInitialized()
+	checkErr := errors.New("unable to connect with s3cret")
+	state.AddHealthCheck("db", func(context.Context) error { return checkErr })
+
+	status, r = readiness()
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "unable to connect with *******", r.Checks["db"])
+
+	checkErr = nil
+	status, r = readiness()
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, Readiness{Ready: true, Checks: map[string]string{"initializers": "ok", "db": "ok"}}, r)
+
+	status, body = get("/version")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"version":"v1.0.0"`)
+
+	// metrics must be enabled
+	status, _ = get("/metrics")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func Test_HealthHandler_metrics(t *testing.T) {
+	state := &State{id: Identification{Name: "app"}}
+	rec := httptest.NewRecorder()
+	state.HealthHandler().WithMetrics().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "# TYPE process_runtime_go_goroutines gauge\n")
+	assert.Regexp(t, `process_runtime_go_goroutines\{service_name="app"\} \d+`, rec.Body.String())
+}
+
+func Test_Application_readyAfterInitializers(t *testing.T) {
+	var handler *HealthHandler
+	cfg := NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithInitializers(func(s *State) error {
+		handler = s.HealthHandler()
+		assert.False(t, s.readiness(context.Background()).Ready)
+		return nil
+	})
+	app := New(*cfg)
+	root := app.SetupRootCommand(&cobra.Command{
+		RunE: func(*cobra.Command, []string) error { return nil },
+	})
+	root.SetArgs(nil)
+	require.NoError(t, root.Execute())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
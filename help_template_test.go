@@ -69,7 +69,7 @@ func captureStd(fn func()) (stdout string, stderr string) {
 	os.Stdout = oldOut // restoring the real stdout
 
 	_ = errW.Close()
-	os.Stdout = oldErr // restoring the real stderr
+	os.Stderr = oldErr // restoring the real stderr
 
 	return <-out, <-err
 }
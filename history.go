@@ -0,0 +1,258 @@
+package clio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// historyStoreKey is the state store key holding the run history (see SetupConfig.WithHistory).
+	historyStoreKey = "history"
+	// DefaultHistoryLimit is the number of invocations kept in the run history.
+	DefaultHistoryLimit = 100
+)
+
+// historyEntry is a command invocation recorded in the run history. Flags that look like they hold secrets are left
+// out, and values known to the redact store are redacted.
+type historyEntry struct {
+	ID       int       `json:"id"`
+	Command  string    `json:"command"`
+	Args     []string  `json:"args,omitempty"`
+	Flags    []string  `json:"flags,omitempty"`
+	Dir      string    `json:"dir,omitempty"`
+	Started  time.Time `json:"started"`
+	Duration string    `json:"duration"`
+	ExitCode int       `json:"exit-code"`
+}
+
+// argv returns the arguments to run the invocation again (without the application name).
+func (e historyEntry) argv() []string {
+	argv := strings.Fields(e.Command)
+	if len(argv) > 0 {
+		argv = argv[1:]
+	}
+	argv = append(argv, e.Flags...)
+	if len(e.Args) > 0 {
+		argv = append(append(argv, "--"), e.Args...)
+	}
+	return argv
+}
+
+// commandLine returns the invocation as it would be typed (not quoted for any particular shell).
+func (e historyEntry) commandLine(appName string) string {
+	return strings.Join(append([]string{appName}, e.argv()...), " ")
+}
+
+// startHistory starts recording the invocation of the command being run, which is only done when the run history is
+// enabled (and never for the history commands themselves).
+func (a *application) startHistory(cmd *cobra.Command, args []string) {
+	a.historyEntry = nil
+	if !a.setupConfig.History || cmd.Annotations[historyAnnotation] != "" {
+		return
+	}
+	dir, _ := os.Getwd()
+	a.historyEntry = &historyEntry{
+		Command: cmd.CommandPath(),
+		Args:    a.redactHistory(args),
+		Flags:   a.historyFlags(cmd),
+		Dir:     dir,
+		Started: now(),
+	}
+}
+
+// finishHistory records the completed invocation in the run history, keeping only the most recent invocations.
+func (a *application) finishHistory(ctx context.Context, err error) {
+	entry := a.historyEntry
+	if entry == nil {
+		return
+	}
+	entry.Duration = since(entry.Started).Round(time.Millisecond).String()
+	switch {
+	case ctx.Err() != nil:
+		entry.ExitCode = (&CancelledError{Reason: a.state.cancelReason(ctx)}).ExitCode()
+	case err != nil:
+		entry.ExitCode = a.exitCode(err)
+	}
+
+	store := a.state.Store()
+	var history []historyEntry
+	if _, err := store.Get(historyStoreKey, &history); err != nil {
+		a.state.Logger.Debugf("unable to read run history: %v", err)
+		return
+	}
+	if n := len(history); n > 0 {
+		entry.ID = history[n-1].ID + 1
+	} else {
+		entry.ID = 1
+	}
+	history = append(history, *entry)
+
+	limit := a.setupConfig.HistoryLimit
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	if err := store.Set(historyStoreKey, history); err != nil {
+		a.state.Logger.Debugf("unable to save run history: %v", err)
+	}
+}
+
+// historyFlags returns the flags given for the command, leaving out flags that look like they hold secrets.
+func (a *application) historyFlags(cmd *cobra.Command) []string {
+	var flags []string
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if dotEnvSecretPattern.MatchString(f.Name) {
+			return
+		}
+		values := []string{f.Value.String()}
+		if s, ok := f.Value.(pflag.SliceValue); ok {
+			values = s.GetSlice()
+		}
+		for _, v := range a.redactHistory(values) {
+			flags = append(flags, fmt.Sprintf("--%s=%s", f.Name, v))
+		}
+	})
+	return flags
+}
+
+func (a *application) redactHistory(values []string) []string {
+	if a.state.RedactStore == nil {
+		return values
+	}
+	redacted := make([]string, len(values))
+	for i, v := range values {
+		redacted[i] = a.state.RedactStore.RedactString(v)
+	}
+	return redacted
+}
+
+// historyAnnotation marks the history commands, which are not recorded in the run history.
+const historyAnnotation = "clio.history"
+
+// setupHistoryCommands adds the "history" command, which lists the recorded invocations, and the "rerun" command,
+// which runs one of them again.
+func (a *application) setupHistoryCommands() {
+	historyCmd := &cobra.Command{
+		Use:         "history",
+		Short:       "show recent invocations of the application",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{historyAnnotation: "true"},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			history, err := a.readHistory()
+			if err != nil {
+				return err
+			}
+			return writeHistory(cmd.OutOrStdout(), a.setupConfig.ID.Name, history)
+		},
+	}
+
+	var dryRun bool
+	rerunCmd := &cobra.Command{
+		Use:   "rerun [id]",
+		Short: "run a recorded invocation again (the most recent by default)",
+		Long: "Run an invocation listed by the history command again, with the same arguments and flags. Flags that " +
+			"hold secrets are not recorded, so these must be given again with an environment variable or the config file.",
+		Args:        cobra.MaximumNArgs(1),
+		Annotations: map[string]string{historyAnnotation: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entry, err := a.historyEntryFor(args)
+			if err != nil {
+				return err
+			}
+			if dryRun {
+				_, _ = fmt.Fprintln(cmd.OutOrStdout(), entry.commandLine(a.setupConfig.ID.Name))
+				return nil
+			}
+			return a.rerun(cmd, entry.argv())
+		},
+	}
+	rerunCmd.Flags().BoolVar(&dryRun, "dry-run", false, "show the invocation without running it")
+
+	a.root.AddCommand(a.SetupCommand(historyCmd), a.SetupCommand(rerunCmd))
+}
+
+func (a *application) readHistory() ([]historyEntry, error) {
+	var history []historyEntry
+	if _, err := a.state.Store().Get(historyStoreKey, &history); err != nil {
+		return nil, fmt.Errorf("unable to read run history: %w", err)
+	}
+	return history, nil
+}
+
+// historyEntryFor returns the invocation with the given ID (or the most recent invocation when no ID is given).
+func (a *application) historyEntryFor(args []string) (*historyEntry, error) {
+	history, err := a.readHistory()
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, fmt.Errorf("there are no recorded invocations to run again")
+	}
+	if len(args) == 0 {
+		return &history[len(history)-1], nil
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid invocation ID %q: expected a number listed by the history command", args[0])
+	}
+	for i := range history {
+		if history[i].ID == id {
+			return &history[i], nil
+		}
+	}
+	return nil, fmt.Errorf("there is no recorded invocation %d", id)
+}
+
+// applicationCommand returns the command running the application again as a child process with the given arguments
+// (overridden in tests).
+var applicationCommand = func(args []string) (*exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return exec.Command(exe, args...), nil
+}
+
+// rerun runs the application again with the given arguments, connected to the same input and output, exiting with
+// the same exit code.
+func (a *application) rerun(cmd *cobra.Command, argv []string) error {
+	c, err := applicationCommand(argv)
+	if err != nil {
+		return fmt.Errorf("unable to run again: %w", err)
+	}
+	c.Stdin = cmd.InOrStdin()
+	c.Stdout = cmd.OutOrStdout()
+	c.Stderr = cmd.ErrOrStderr()
+
+	result, err := a.state.Exec(cmd.Context(), c)
+	if result.ExitCode > 0 {
+		// the command has shown the error already
+		return &forwardedExitError{code: result.ExitCode}
+	}
+	return err
+}
+
+func writeHistory(w io.Writer, appName string, history []historyEntry) error {
+	if len(history) == 0 {
+		_, err := fmt.Fprintln(w, "no recorded invocations")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tSTARTED\tDURATION\tEXIT\tINVOCATION")
+	for _, e := range history {
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\n", e.ID, e.Started.Local().Format("2006-01-02 15:04:05"), e.Duration, e.ExitCode, e.commandLine(appName))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,107 @@
+package clio
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newHistoryTestApp(limit int) (Application, *cobra.Command) {
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithHistory(limit))
+	root := app.SetupRootCommand(&cobra.Command{})
+
+	var depth int
+	var token string
+	var tags []string
+	scan := &cobra.Command{
+		Use: "scan",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if depth < 0 {
+				return &ExitError{Err: fmt.Errorf("invalid depth"), Code: 3}
+			}
+			return nil
+		},
+	}
+	scan.Flags().IntVar(&depth, "depth", 1, "")
+	scan.Flags().StringVar(&token, "token", "", "")
+	scan.Flags().StringSliceVar(&tags, "tag", nil, "")
+	scan.Flags().Int("exit", 0, "the exit code when run again (see Test_childProcess)")
+	root.AddCommand(app.SetupCommand(scan))
+	return app, root
+}
+
+func runHistoryTestApp(t *testing.T, limit int, args ...string) (string, error) {
+	t.Helper()
+	_, root := newHistoryTestApp(limit)
+	out, _, err := runTestApp(root, "", args...)
+	return out, err
+}
+
+func Test_Application_history(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	out, err := runHistoryTestApp(t, 0, "history")
+	require.NoError(t, err)
+	assert.Equal(t, "no recorded invocations\n", out)
+
+	_, err = runHistoryTestApp(t, 0, "scan", "--depth", "2", "--token", "hunter2", "--tag", "a,b", "./dir")
+	require.NoError(t, err)
+	_, err = runHistoryTestApp(t, 0, "scan", "--depth=-1")
+	require.Error(t, err)
+
+	out, err = runHistoryTestApp(t, 0, "history")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	assert.Regexp(t, `^ID\s+STARTED\s+DURATION\s+EXIT\s+INVOCATION$`, lines[0])
+	assert.Regexp(t, `^1\s+.*\s+0\s+app scan --depth=2 --tag=a --tag=b -- ./dir$`, lines[1])
+	assert.Regexp(t, `^2\s+.*\s+3\s+app scan --depth=-1$`, lines[2])
+	assert.NotContains(t, out, "hunter2")
+
+	// the history commands are not recorded
+	out, err = runHistoryTestApp(t, 0, "rerun", "1", "--dry-run")
+	require.NoError(t, err)
+	assert.Equal(t, "app scan --depth=2 --tag=a --tag=b -- ./dir\n", out)
+
+	out, err = runHistoryTestApp(t, 0, "rerun", "--dry-run")
+	require.NoError(t, err)
+	assert.Equal(t, "app scan --depth=-1\n", out)
+
+	_, err = runHistoryTestApp(t, 0, "rerun", "7")
+	require.ErrorContains(t, err, "there is no recorded invocation 7")
+	_, err = runHistoryTestApp(t, 0, "rerun", "latest")
+	require.ErrorContains(t, err, "invalid invocation ID")
+}
+
+func Test_Application_history_limit(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	for i := 1; i <= 3; i++ {
+		_, err := runHistoryTestApp(t, 2, "scan", fmt.Sprintf("--depth=%d", i))
+		require.NoError(t, err)
+	}
+
+	out, err := runHistoryTestApp(t, 2, "history")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	assert.Regexp(t, `^2\s+.*--depth=2$`, lines[1])
+	assert.Regexp(t, `^3\s+.*--depth=3$`, lines[2])
+}
+
+func Test_Application_history_rerun(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	useTestChildProcess(t)
+
+	_, err := runHistoryTestApp(t, 0, "scan", "--depth", "4", "--exit", "3")
+	require.NoError(t, err)
+
+	app, root := newHistoryTestApp(0)
+	out, code := executeTestApp(app, root, "rerun")
+	assert.Equal(t, 3, code)
+	assert.Equal(t, "ran: scan --depth=4 --exit=3\n", out)
+}
@@ -0,0 +1,288 @@
+package clio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/boss-net/fangs"
+	"go.starlark.net/starlark"
+)
+
+// hookScriptExt is the extension of hook scripts within the hooks dirs.
+const hookScriptExt = ".star"
+
+// ErrHookVetoed is matched (see errors.Is) by all errors returned when a hook script vetoes an action.
+var ErrHookVetoed = errors.New("vetoed by hook")
+
+// HookVetoError is returned (see State.RunHook) when a hook script vetoes an action by calling veto(reason).
+type HookVetoError struct {
+	Point  string
+	Hook   string // the path of the hook script
+	Reason string
+}
+
+func (e *HookVetoError) Error() string {
+	return fmt.Sprintf("%s vetoed by hook %s: %s", e.Point, filepath.Base(e.Hook), e.Reason)
+}
+
+func (e *HookVetoError) Is(target error) bool {
+	return target == ErrHookVetoed
+}
+
+// HooksConfig is the user-facing configuration of hook scripts (see SetupConfig.WithHookPoints).
+type HooksConfig struct {
+	Enabled bool     `yaml:"enabled" json:"enabled" mapstructure:"enabled"`
+	Dirs    []string `yaml:"dirs" json:"dirs" mapstructure:"dirs"` // searched in order (default: the user and workspace hooks dirs)
+}
+
+var _ interface {
+	fangs.FieldDescriber
+} = (*HooksConfig)(nil)
+
+func (c *HooksConfig) DescribeFields(set fangs.FieldDescriptionSet) {
+	set.Add(&c.Enabled, "run the hook scripts (*.star) within the hooks dirs")
+	set.Add(&c.Dirs, "directories of hook scripts, run in order (default: hooks within the user config dir, then .<app>/hooks within the workspace)")
+}
+
+// hookScript is a loaded hook script.
+type hookScript struct {
+	path   string
+	module *scriptModule
+}
+
+// hookFunction is the function of a hook script for an extension point.
+type hookFunction struct {
+	script *hookScript
+	fn     *starlark.Function
+}
+
+// setupHooks loads the hook scripts within the hooks dirs, when the application has registered extension points
+// (see SetupConfig.WithHookPoints). Scripts are run in order of their directory, and then by name.
+func (s *State) setupHooks(points []string) error {
+	s.hooks = nil
+	s.hookPoints = points
+	if len(points) == 0 || s.Config.Hooks == nil || !s.Config.Hooks.Enabled {
+		return nil
+	}
+
+	for _, dir := range s.hookDirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("unable to read hooks dir: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != hookScriptExt {
+				continue
+			}
+			hook, err := s.loadHook(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return err
+			}
+			s.hooks = append(s.hooks, hook)
+		}
+	}
+	return nil
+}
+
+// hookDirs returns the directories of hook scripts: as configured, or else the hooks dir within the user config dir,
+// then within the workspace (.<app>/hooks).
+func (s *State) hookDirs() []string {
+	var dirs []string
+	if configured := s.Config.Hooks.Dirs; len(configured) > 0 {
+		for _, dir := range configured {
+			dirs = append(dirs, s.ResolvePath(dir))
+		}
+		return dirs
+	}
+	if dir, err := userConfigDir(s.id.Name); err == nil {
+		dirs = append(dirs, filepath.Join(dir, s.id.Name, "hooks"))
+	}
+	if root := s.workspace.Root; root != "" {
+		dirs = append(dirs, filepath.Join(root, "."+s.id.Name, "hooks"))
+	}
+	return dirs
+}
+
+func (s *State) loadHook(path string) (*hookScript, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read hook script: %w", err)
+	}
+	log := s.currentLogger()
+	print := func(msg string) {
+		if log != nil {
+			log.WithFields("hook", path).Debug(msg)
+		}
+	}
+	module, err := loadScript(context.Background(), path, string(contents), s.hookAPI(path), print)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load hook script: %w", err)
+	}
+
+	var defined []string
+	for _, point := range s.hookPoints {
+		fn, ok := module.function(point)
+		if !ok {
+			continue
+		}
+		if !scriptTakesOneArgument(fn) {
+			return nil, fmt.Errorf("unable to load hook script: %s:%d: hook function %s must take one argument", path, fn.Position().Line, point)
+		}
+		defined = append(defined, point)
+	}
+	if log != nil {
+		if len(defined) == 0 {
+			available := append([]string(nil), s.hookPoints...)
+			sort.Strings(available)
+			log.Warnf("hook script %s defines no hook functions (available: %s)", path, strings.Join(available, ", "))
+		} else {
+			log.WithFields("hook", path, "points", strings.Join(defined, ", ")).Debug("loaded hook script")
+		}
+	}
+	return &hookScript{path: path, module: module}, nil
+}
+
+// hookAPI returns the functions given to a hook script, which are all it can reach of the application.
+func (s *State) hookAPI(path string) starlark.StringDict {
+	return starlark.StringDict{
+		"app": newScriptDict(map[string]string{"name": s.id.Name, "version": s.id.Version}),
+		"log": starlark.NewBuiltin("log", func(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, _ []starlark.Tuple) (starlark.Value, error) {
+			parts := make([]string, len(args))
+			for i, a := range args {
+				parts[i] = scriptString(a)
+			}
+			if log := s.currentLogger(); log != nil {
+				log.WithFields("hook", path).Info(strings.Join(parts, " "))
+			}
+			return starlark.None, nil
+		}),
+		"warn": starlark.NewBuiltin("warn", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var code, message string
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "code", &code, "message", &message); err != nil {
+				return nil, err
+			}
+			s.Warn(code, message, map[string]any{"hook": path})
+			return starlark.None, nil
+		}),
+		"veto": starlark.NewBuiltin("veto", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var reason string
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "reason", &reason); err != nil {
+				return nil, err
+			}
+			return nil, &HookVetoError{Hook: path, Reason: reason}
+		}),
+	}
+}
+
+// hookFunctions returns the functions of the hook scripts for the extension point, in order.
+func (s *State) hookFunctions(point string) ([]hookFunction, error) {
+	if !contains(s.hookPoints, point) {
+		return nil, fmt.Errorf("unknown hook point %q (see SetupConfig.WithHookPoints)", point)
+	}
+	var fns []hookFunction
+	for _, hook := range s.hooks {
+		if fn, ok := hook.module.function(point); ok {
+			fns = append(fns, hookFunction{script: hook, fn: fn})
+		}
+	}
+	return fns, nil
+}
+
+func (h hookFunction) call(ctx context.Context, point string, arg starlark.Value) (starlark.Value, error) {
+	v, err := h.script.module.call(ctx, h.fn, arg)
+	var veto *HookVetoError
+	if errors.As(err, &veto) {
+		veto.Point = point
+		return nil, veto
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s hook failed: %w", point, err)
+	}
+	return v, nil
+}
+
+// RunHook calls the function named after the extension point (see SetupConfig.WithHookPoints) within each hook
+// script, in order, with the value as represented in JSON (as dicts, lists, strings, numbers, bools, and None). A
+// hook function that returns a value (other than None) replaces the value given to the next, and the final value is
+// decoded into the result (when not nil, as with json.Unmarshal). A hook function may call veto(reason) to stop the
+// action, returning a HookVetoError (matching ErrHookVetoed), e.g.
+//
+//	def before_delete(target):
+//	    if target["path"].startswith("/"):
+//	        veto("refusing to delete absolute paths")
+func (s *State) RunHook(ctx context.Context, point string, value any, result any) error {
+	fns, err := s.hookFunctions(point)
+	if err != nil {
+		return err
+	}
+	doc, err := normalizeJSON(value)
+	if err != nil {
+		return fmt.Errorf("unable to run %s hooks: %w", point, err)
+	}
+	v := toScriptValue(doc)
+	for _, h := range fns {
+		ret, err := h.call(ctx, point, v)
+		if err != nil {
+			return err
+		}
+		if ret != starlark.None {
+			v = ret
+		}
+	}
+	if result == nil {
+		return nil
+	}
+	contents, err := json.Marshal(fromScriptValue(v))
+	if err != nil {
+		return fmt.Errorf("unable to decode the result of %s hooks: %w", point, err)
+	}
+	if err := json.Unmarshal(contents, result); err != nil {
+		return fmt.Errorf("unable to decode the result of %s hooks: %w", point, err)
+	}
+	return nil
+}
+
+// FilterHook returns the items that no hook function for the extension point rejects (see State.RunHook), in order,
+// e.g. to let users drop results. Hook functions are called with each item, which is kept unless they return a false
+// value (returning None keeps the item), e.g.
+//
+//	def filter_result(result):
+//	    return result["severity"] != "low"
+func FilterHook[T any](ctx context.Context, s *State, point string, items []T) ([]T, error) {
+	fns, err := s.hookFunctions(point)
+	if err != nil || len(fns) == 0 {
+		return items, err
+	}
+
+	kept := make([]T, 0, len(items))
+	for _, item := range items {
+		doc, err := normalizeJSON(item)
+		if err != nil {
+			return nil, fmt.Errorf("unable to run %s hooks: %w", point, err)
+		}
+		keep := true
+		for _, h := range fns {
+			ret, err := h.call(ctx, point, toScriptValue(doc))
+			if err != nil {
+				return nil, err
+			}
+			if ret != starlark.None && !ret.Truth() {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			kept = append(kept, item)
+		}
+	}
+	return kept, nil
+}
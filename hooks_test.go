@@ -0,0 +1,138 @@
+package clio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeHook(t *testing.T, dir, name, src string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0o600))
+}
+
+func hookState(t *testing.T, dirs ...string) *State {
+	t.Helper()
+	s := &State{
+		id:     Identification{Name: "app", Version: "1.2.3"},
+		Config: Config{Hooks: &HooksConfig{Enabled: true, Dirs: dirs}},
+	}
+	require.NoError(t, s.setupHooks([]string{"filter_result", "before_delete", "label"}))
+	return s
+}
+
+func Test_State_RunHook(t *testing.T) {
+	user, workspace := t.TempDir(), t.TempDir()
+	writeHook(t, user, "10-label.star", `
+def label(result):
+    result["labels"] = (result.get("labels") or []) + [app["name"] + "-" + app["version"]]
+    return result
+
+def before_delete(target):
+    if target["path"] == "/":
+        veto("refusing to delete " + target["path"])
+`)
+	writeHook(t, workspace, "00-label.star", `
+def label(result):
+    result["labels"].append("workspace")
+`)
+	writeHook(t, workspace, "README.md", "not a hook")
+	s := hookState(t, user, workspace)
+	require.Len(t, s.hooks, 2)
+
+	type result struct {
+		Name   string   `json:"name"`
+		Labels []string `json:"labels"`
+	}
+	var got result
+	require.NoError(t, s.RunHook(context.Background(), "label", result{Name: "a"}, &got))
+	assert.Equal(t, result{Name: "a", Labels: []string{"app-1.2.3", "workspace"}}, got, "hooks run in order of their dirs, and may modify values in place")
+
+	require.NoError(t, s.RunHook(context.Background(), "before_delete", map[string]string{"path": "/tmp"}, nil))
+	err := s.RunHook(context.Background(), "before_delete", map[string]string{"path": "/"}, nil)
+	require.ErrorIs(t, err, ErrHookVetoed)
+	require.EqualError(t, err, "before_delete vetoed by hook 10-label.star: refusing to delete /")
+
+	err = s.RunHook(context.Background(), "after_delete", nil, nil)
+	require.EqualError(t, err, `unknown hook point "after_delete" (see SetupConfig.WithHookPoints)`)
+}
+
+func Test_FilterHook(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, "filter.star", `
+IGNORED = ("vendor/", "testdata/")
+
+def filter_result(result):
+    if result["path"].startswith(IGNORED):
+        return False
+    if result["severity"] == "low":
+        warn("low-severity", "dropped " + result["path"])
+        return False
+`)
+	s := hookState(t, dir)
+
+	type finding struct {
+		Path     string `json:"path"`
+		Severity string `json:"severity"`
+	}
+	findings := []finding{
+		{Path: "main.go", Severity: "high"},
+		{Path: "vendor/lib.go", Severity: "high"},
+		{Path: "util.go", Severity: "low"},
+	}
+	kept, err := FilterHook(context.Background(), s, "filter_result", findings)
+	require.NoError(t, err)
+	assert.Equal(t, []finding{{Path: "main.go", Severity: "high"}}, kept)
+
+	warnings := s.Warnings()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "low-severity", warnings[0].Code)
+	assert.Equal(t, filepath.Join(dir, "filter.star"), warnings[0].Fields["hook"])
+
+	// without hook functions for the point, items are returned as they are
+	kept, err = FilterHook(context.Background(), hookState(t), "filter_result", findings)
+	require.NoError(t, err)
+	assert.Equal(t, findings, kept)
+}
+
+func Test_State_setupHooks_errors(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, "broken.star", "def label(result):\nreturn result\n")
+	s := &State{id: Identification{Name: "app"}, Config: Config{Hooks: &HooksConfig{Enabled: true, Dirs: []string{dir}}}}
+	err := s.setupHooks([]string{"label"})
+	require.EqualError(t, err, "unable to load hook script: "+filepath.Join(dir, "broken.star")+":2:7: got return, want indent")
+
+	writeHook(t, dir, "broken.star", "def label():\n    pass\n")
+	err = s.setupHooks([]string{"label"})
+	require.EqualError(t, err, "unable to load hook script: "+filepath.Join(dir, "broken.star")+":1: hook function label must take one argument")
+
+	// failures within hook functions name the extension point and the line
+	writeHook(t, dir, "broken.star", "def label(result):\n    return result['missing']\n")
+	require.NoError(t, s.setupHooks([]string{"label"}))
+	err = s.RunHook(context.Background(), "label", map[string]string{}, nil)
+	require.EqualError(t, err, `label hook failed: `+filepath.Join(dir, "broken.star")+`:2: key "missing" not in dict`)
+
+	// disabled hooks are not loaded
+	s.Config.Hooks.Enabled = false
+	require.NoError(t, s.setupHooks([]string{"label"}))
+	assert.Empty(t, s.hooks)
+}
+
+func Test_State_hookDirs(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/config")
+	t.Setenv("HOME", "/home/user")
+	s := &State{id: Identification{Name: "app"}, Config: Config{Hooks: &HooksConfig{Enabled: true}}}
+	s.workspace.Root = "/work"
+	dirs := s.hookDirs()
+	require.Len(t, dirs, 2)
+	assert.Equal(t, filepath.Join("/work", ".app", "hooks"), dirs[1])
+
+	s.Config.Hooks.Dirs = []string{"hooks"}
+	s.cwd.Dir = "/cwd"
+	assert.Equal(t, []string{filepath.Join("/cwd", "hooks")}, s.hookDirs())
+}
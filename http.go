@@ -0,0 +1,213 @@
+package clio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/boss-net/fangs"
+	"github.com/boss-net/go-logger"
+)
+
+// HTTPConfig determines how requests made with the client from State.HTTPClient are retried and cached (see
+// SetupConfig.WithHTTPConfig).
+type HTTPConfig struct {
+	Retries      int           `yaml:"retries" json:"retries" mapstructure:"retries"`                      // retry requests failing with 429 or 5xx (or a connection error)
+	RetryMaxWait time.Duration `yaml:"retry-max-wait" json:"retry-max-wait" mapstructure:"retry-max-wait"` // the longest to wait before retrying, including when asked to by Retry-After
+	Cache        bool          `yaml:"cache" json:"cache" mapstructure:"cache"`                            // cache responses on disk, revalidated with ETag or Last-Modified
+}
+
+var _ interface {
+	fangs.FieldDescriber
+	fangs.PostLoader
+} = (*HTTPConfig)(nil)
+
+func (c *HTTPConfig) DescribeFields(set fangs.FieldDescriptionSet) {
+	set.Add(&c.Retries, "times to retry requests which are rate limited (429) or fail with a server error (5xx)")
+	set.Add(&c.RetryMaxWait, "the longest to wait before retrying a request, including when asked to by the server")
+	set.Add(&c.Cache, "cache responses on disk, revalidating them with the server before each use")
+}
+
+func (c *HTTPConfig) PostLoad() error {
+	if c.Retries < 0 {
+		return fmt.Errorf("invalid http.retries %d: must not be negative", c.Retries)
+	}
+	if c.RetryMaxWait < 0 {
+		return fmt.Errorf("invalid http.retry-max-wait %s: must not be negative", c.RetryMaxWait)
+	}
+	return nil
+}
+
+// defaultRetryMaxWait is the longest to wait before retrying when not configured.
+const defaultRetryMaxWait = 30 * time.Second
+
+// HTTPMiddleware wraps an http.RoundTripper, e.g. to add headers to each request (see SetupConfig.WithHTTPMiddleware).
+type HTTPMiddleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is an adapter to allow the use of ordinary functions as an http.RoundTripper.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// ChainHTTP wraps the transport with the middlewares, the first of which sees each request first.
+func ChainHTTP(transport http.RoundTripper, middlewares ...HTTPMiddleware) http.RoundTripper {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		transport = middlewares[i](transport)
+	}
+	return transport
+}
+
+// httpTransport assembles the transport of the HTTP client: the middlewares of the application, then the response
+// cache and retries (as configured), and request logging, on top of the transport enforcing the network policy.
+func (s *State) httpTransport() http.RoundTripper {
+	cfg := s.currentConfig()
+	middlewares := append([]HTTPMiddleware(nil), s.httpMiddlewares...)
+	if c := cfg.HTTP; c != nil {
+		if c.Cache {
+			if dir, err := s.cacheDir(); err == nil && s.WritableDir(filepath.Join(dir, "http")) {
+				fileMode, dirMode := cfg.Permissions.modes()
+				middlewares = append(middlewares, HTTPCache(filepath.Join(dir, "http"), fileMode, dirMode))
+			}
+		}
+		if c.Retries > 0 {
+			middlewares = append(middlewares, HTTPRetry(c.Retries, c.RetryMaxWait))
+		}
+	}
+	if log := s.currentLogger(); log != nil {
+		middlewares = append(middlewares, HTTPLogging(log.Nested("component", "http")))
+	}
+	return ChainHTTP(newNetworkPolicyTransport(cfg.Network), middlewares...)
+}
+
+// HTTPLogging logs each request (at trace level) with the response status and how long it took.
+func HTTPLogging(log logger.Logger) HTTPMiddleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			started := time.Now()
+			resp, err := next.RoundTrip(req)
+			elapsed := time.Since(started).Round(time.Millisecond)
+			if err != nil {
+				log.Tracef("%s %s failed after %s: %v", req.Method, req.URL.Redacted(), elapsed, err)
+				return resp, err
+			}
+			log.Tracef("%s %s: %s (%s)", req.Method, req.URL.Redacted(), resp.Status, elapsed)
+			return resp, err
+		})
+	}
+}
+
+// HTTPRetry retries requests which are rate limited (429), fail with a server error (5xx, except 501), or (for
+// idempotent requests) fail to connect, up to the given number of times. Retries are made with exponential backoff
+// (with jitter), or after the time given by a Retry-After header, waiting at most maxWait (default: 30s) before each;
+// when the server asks to wait longer, the response is returned as-is. Requests with a body are only retried when the
+// body can be read again (see http.Request.GetBody).
+func HTTPRetry(retries int, maxWait time.Duration) HTTPMiddleware {
+	if maxWait <= 0 {
+		maxWait = defaultRetryMaxWait
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			for attempt := 0; ; attempt++ {
+				resp, err := next.RoundTrip(req)
+				if attempt >= retries || !retryable(req, resp, err) {
+					return resp, err
+				}
+
+				wait := backoff(attempt, maxWait)
+				if resp != nil {
+					if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+						if after > maxWait {
+							return resp, err
+						}
+						wait = after
+					}
+				}
+
+				if req.Body != nil && req.Body != http.NoBody {
+					body, bodyErr := req.GetBody()
+					if bodyErr != nil {
+						return resp, err
+					}
+					req = req.Clone(req.Context())
+					req.Body = body
+				}
+				if resp != nil {
+					// the connection is reused once the body is read
+					_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+					_ = resp.Body.Close()
+				}
+
+				if waitErr := sleepContext(req.Context(), wait); waitErr != nil {
+					return nil, waitErr
+				}
+			}
+		})
+	}
+}
+
+// retryable indicates the request may succeed when made again.
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		var policyErr *NetworkPolicyError
+		if errors.As(err, &policyErr) || req.Context().Err() != nil {
+			return false
+		}
+		switch req.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+			return true
+		}
+		return false
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || (resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented)
+}
+
+// backoff returns how long to wait before the retry following the given attempt: exponential from 500ms, with jitter.
+func backoff(attempt int, maxWait time.Duration) time.Duration {
+	wait := 500 * time.Millisecond << attempt
+	if wait > maxWait || wait <= 0 {
+		wait = maxWait
+	}
+	// wait between half and the full backoff, so that clients do not retry in lockstep
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// retryAfter parses the Retry-After header, which is either a number of seconds or a date.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		wait := time.Until(t)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// sleepContext waits for the duration, or until the context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,148 @@
+package clio
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxCachedResponse is the largest response body kept in the HTTP cache.
+const maxCachedResponse = 16 << 20
+
+// HTTPCache keeps the responses to GET requests in the directory, which are revalidated with the server before each
+// use (with If-None-Match or If-Modified-Since): when the server responds with 304 Not Modified, the cached response is
+// returned instead. Only responses with an ETag or Last-Modified header (and without "Cache-Control: no-store") are
+// kept, and responses are kept separately for each Authorization header.
+func HTTPCache(dir string, fileMode, dirMode os.FileMode) HTTPMiddleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		c := &httpCache{dir: dir, fileMode: fileMode, dirMode: dirMode}
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return c.roundTrip(next, req)
+		})
+	}
+}
+
+type httpCache struct {
+	dir      string
+	fileMode os.FileMode
+	dirMode  os.FileMode
+}
+
+func (c *httpCache) roundTrip(next http.RoundTripper, req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" || req.Header.Get("Range") != "" {
+		// conditional and partial requests are made by the caller, so the response is theirs to handle
+		return next.RoundTrip(req)
+	}
+
+	path := c.path(req)
+	cached := c.read(path, req)
+	if cached != nil {
+		req = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if modified := cached.Header.Get("Last-Modified"); modified != "" {
+			req.Header.Set("If-Modified-Since", modified)
+		}
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		_ = resp.Body.Close()
+		return cached, nil
+	}
+	if cached != nil {
+		_ = cached.Body.Close()
+	}
+	if resp.StatusCode != http.StatusOK || !cacheable(resp) {
+		if resp.StatusCode == http.StatusOK {
+			_ = os.Remove(path)
+		}
+		return resp, nil
+	}
+	return c.write(path, resp), nil
+}
+
+// path returns the file of the cached response to the request.
+func (c *httpCache) path(req *http.Request) string {
+	h := sha256.New()
+	_, _ = io.WriteString(h, req.URL.String())
+	_, _ = io.WriteString(h, "\n")
+	_, _ = io.WriteString(h, req.Header.Get("Authorization"))
+	return filepath.Join(c.dir, hex.EncodeToString(h.Sum(nil)))
+}
+
+func cacheable(resp *http.Response) bool {
+	if strings.Contains(strings.ToLower(resp.Header.Get("Cache-Control")), "no-store") {
+		return false
+	}
+	return resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+// read returns the cached response to the request, or nil when there is none.
+func (c *httpCache) read(path string, req *http.Request) *http.Response {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(contents)), req)
+	if err != nil {
+		_ = os.Remove(path)
+		return nil
+	}
+	return resp
+}
+
+// write keeps the response in the cache (when small enough), returning the response with its body intact.
+func (c *httpCache) write(path string, resp *http.Response) *http.Response {
+	if resp.ContentLength > maxCachedResponse {
+		return resp
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedResponse+1))
+	if err != nil || len(body) > maxCachedResponse {
+		// the body is returned as it was, whether partially read or not
+		resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return resp
+	}
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	stored := *resp
+	stored.Body = io.NopCloser(bytes.NewReader(body))
+	stored.ContentLength = int64(len(body))
+	stored.TransferEncoding = nil
+	stored.Header = resp.Header.Clone()
+	stored.Header.Del("Content-Encoding")
+	dump, err := httputil.DumpResponse(&stored, true)
+	if err != nil {
+		return resp
+	}
+
+	if err := mkdirAll(c.dir, c.dirMode); err != nil {
+		return resp
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, dump, c.fileMode); err != nil {
+		return resp
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+	}
+	return resp
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
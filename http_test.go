@@ -0,0 +1,202 @@
+package clio
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, transport http.RoundTripper, url string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func Test_HTTPRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			body, _ := io.ReadAll(r.Body)
+			_, _ = w.Write(append([]byte("ok "), body...))
+		}
+	}))
+	defer server.Close()
+
+	transport := ChainHTTP(http.DefaultTransport, HTTPRetry(2, 10*time.Millisecond))
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("body"))
+	require.NoError(t, err)
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "ok body", string(body), "the body is sent again with each retry")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func Test_HTTPRetry_givesUp(t *testing.T) {
+	tests := []struct {
+		name       string
+		retries    int
+		retryAfter string
+		status     int
+		calls      int32
+	}{
+		{name: "retries exhausted", retries: 2, status: http.StatusBadGateway, calls: 3},
+		{name: "not implemented", retries: 2, status: http.StatusNotImplemented, calls: 1},
+		{name: "client error", retries: 2, status: http.StatusNotFound, calls: 1},
+		{name: "retry after too long", retries: 2, retryAfter: "120", status: http.StatusTooManyRequests, calls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			resp, _ := get(t, ChainHTTP(http.DefaultTransport, HTTPRetry(tt.retries, time.Millisecond)), server.URL)
+			assert.Equal(t, tt.status, resp.StatusCode)
+			assert.Equal(t, tt.calls, atomic.LoadInt32(&calls))
+		})
+	}
+}
+
+func Test_HTTPRetry_canceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	_, err = ChainHTTP(http.DefaultTransport, HTTPRetry(5, time.Minute)).RoundTrip(req)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func Test_retryAfter(t *testing.T) {
+	d, ok := retryAfter("3")
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, d)
+
+	d, ok = retryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), d)
+
+	_, ok = retryAfter("soon")
+	assert.False(t, ok)
+}
+
+func Test_HTTPCache(t *testing.T) {
+	var calls, revalidated int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&revalidated, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	transport := ChainHTTP(http.DefaultTransport, HTTPCache(t.TempDir(), 0o600, 0o700))
+	resp, body := get(t, transport, server.URL)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "content", body)
+
+	resp, body = get(t, transport, server.URL)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "the cached response is returned when not modified")
+	assert.Equal(t, "content", body)
+	assert.Equal(t, `"v1"`, resp.Header.Get("ETag"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&revalidated))
+}
+
+func Test_HTTPCache_notCacheable(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		assert.Empty(t, r.Header.Get("If-None-Match"))
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	transport := ChainHTTP(http.DefaultTransport, HTTPCache(dir, 0o600, 0o700))
+	get(t, transport, server.URL)
+	_, body := get(t, transport, server.URL)
+	assert.Equal(t, "secret", body)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "responses which must not be stored are not cached")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func Test_State_HTTPClient_config(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "app/1.0", r.Header.Get("User-Agent"))
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	userAgent := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set("User-Agent", "app/1.0")
+			return next.RoundTrip(req)
+		})
+	}
+	s := &State{
+		Config: Config{
+			HTTP:    &HTTPConfig{Retries: 1, RetryMaxWait: time.Millisecond},
+			Network: &NetworkConfig{},
+		},
+		httpMiddlewares: []HTTPMiddleware{userAgent},
+	}
+
+	resp, err := s.HTTPClient().Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func Test_HTTPConfig_PostLoad(t *testing.T) {
+	require.NoError(t, (&HTTPConfig{Retries: 3}).PostLoad())
+	require.EqualError(t, (&HTTPConfig{Retries: -1}).PostLoad(), "invalid http.retries -1: must not be negative")
+}
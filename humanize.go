@@ -0,0 +1,187 @@
+package clio
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// numberFormat is how numbers are written for the user's locale.
+type numberFormat struct {
+	thousands string
+	decimal   string
+}
+
+var (
+	defaultNumberFormat = numberFormat{thousands: ",", decimal: "."}
+
+	// the number format by language, for languages that differ from the default
+	localeNumberFormats = map[string]numberFormat{}
+
+	// guarded by presentationLock
+	activeNumberFormat = defaultNumberFormat
+	rawValues          bool
+)
+
+func init() {
+	for _, lang := range []string{"da", "de", "el", "es", "hr", "id", "it", "nl", "pt", "ro", "sl", "sr", "tr"} {
+		localeNumberFormats[lang] = numberFormat{thousands: ".", decimal: ","}
+	}
+	for _, lang := range []string{"bg", "cs", "et", "fi", "fr", "hu", "lt", "lv", "nb", "no", "pl", "ru", "sk", "sv", "uk"} {
+		localeNumberFormats[lang] = numberFormat{thousands: " ", decimal: ","}
+	}
+}
+
+// setFormatting selects how the Human* helpers write values: raw machine values, or human-friendly values in the
+// number format of the locale (the first of LC_ALL, LC_NUMERIC, and LANG which is set).
+func setFormatting(raw bool, getenv func(string) string) {
+	presentationLock.Lock()
+	defer presentationLock.Unlock()
+	rawValues = raw
+	activeNumberFormat = localeNumberFormat(getenv)
+}
+
+func localeNumberFormat(getenv func(string) string) numberFormat {
+	for _, name := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		locale := getenv(name)
+		if locale == "" {
+			continue
+		}
+		// e.g. "de_DE.UTF-8" or "de-DE"
+		lang := strings.ToLower(locale)
+		if i := strings.IndexAny(lang, "_-.@"); i >= 0 {
+			lang = lang[:i]
+		}
+		if f, ok := localeNumberFormats[lang]; ok {
+			return f
+		}
+		return defaultNumberFormat
+	}
+	return defaultNumberFormat
+}
+
+func currentFormatting() (numberFormat, bool) {
+	presentationLock.RLock()
+	defer presentationLock.RUnlock()
+	return activeNumberFormat, rawValues
+}
+
+// HumanNumber writes the number with thousands separators for the user's locale (e.g. "1,234,567"), or as a plain
+// number when raw values are configured (see UIConfig.RawValues).
+func HumanNumber(n int64) string {
+	f, raw := currentFormatting()
+	s := strconv.FormatInt(n, 10)
+	if raw {
+		return s
+	}
+
+	// note: the digits are taken from the formatted value, since -math.MinInt64 overflows
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	var sb strings.Builder
+	for i, r := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			sb.WriteString(f.thousands)
+		}
+		sb.WriteRune(r)
+	}
+	return sign + sb.String()
+}
+
+// HumanBytes writes the byte size with binary units for the user's locale (e.g. "1.5 GiB"), or as the exact number
+// of bytes when raw values are configured (see UIConfig.RawValues).
+func HumanBytes(bytes int64) string {
+	f, raw := currentFormatting()
+	if raw {
+		return strconv.FormatInt(bytes, 10)
+	}
+
+	sign := ""
+	// note: math.MinInt64 cannot be negated, but is exactly representable as a float
+	abs := math.Abs(float64(bytes))
+	if bytes < 0 {
+		sign = "-"
+	}
+
+	const unit = 1024
+	if abs < unit {
+		return fmt.Sprintf("%s%d B", sign, int64(abs))
+	}
+
+	// round before selecting the unit, so that values just under a unit boundary (e.g. 1048575 bytes) are shown in
+	// the larger unit ("1.0 MiB") rather than overflowing the smaller one ("1024.0 KiB")
+	exp := 0
+	value := abs / unit
+	for exp < len(byteUnitPrefixes)-1 && math.Round(value*10)/10 >= unit {
+		value /= unit
+		exp++
+	}
+	formatted := strconv.FormatFloat(value, 'f', 1, 64)
+	return fmt.Sprintf("%s%s %ciB", sign, strings.Replace(formatted, ".", f.decimal, 1), byteUnitPrefixes[exp])
+}
+
+// byteUnitPrefixes are the binary unit prefixes from KiB upwards.
+const byteUnitPrefixes = "KMGTPE"
+
+// HumanDuration writes the duration with the precision that matters to a person (e.g. "250ms", "4.2s", "3m 5s", or
+// "2h 10m"), or as an exact duration (e.g. "3m5.123456s") when raw values are configured (see UIConfig.RawValues).
+func HumanDuration(d time.Duration) string {
+	f, raw := currentFormatting()
+	if raw {
+		return d.String()
+	}
+
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		if d == math.MinInt64 {
+			// this cannot be negated, and is shown in days (where a nanosecond makes no difference)
+			d++
+		}
+		d = -d
+	}
+
+	// round to the precision shown before selecting the units, so that values just under a unit boundary (e.g.
+	// 59.96s) are shown in the larger unit ("1m") rather than overflowing the smaller one ("60s")
+	switch {
+	case d == 0:
+		return "0s"
+	case d.Round(time.Millisecond) < time.Second:
+		return fmt.Sprintf("%s%dms", sign, d.Round(time.Millisecond).Milliseconds())
+	case d.Round(100*time.Millisecond) < time.Minute:
+		value := strconv.FormatFloat(d.Round(100*time.Millisecond).Seconds(), 'f', -1, 64)
+		return sign + strings.Replace(value, ".", f.decimal, 1) + "s"
+	case d.Round(time.Second) < time.Hour:
+		d = d.Round(time.Second)
+		return sign + joinDurationParts(int64(d/time.Minute), "m", int64(d%time.Minute/time.Second), "s")
+	case d.Round(time.Minute) < 24*time.Hour:
+		d = d.Round(time.Minute)
+		return sign + joinDurationParts(int64(d/time.Hour), "h", int64(d%time.Hour/time.Minute), "m")
+	default:
+		d = d.Round(time.Hour)
+		return sign + joinDurationParts(int64(d/(24*time.Hour)), "d", int64(d%(24*time.Hour)/time.Hour), "h")
+	}
+}
+
+// joinDurationParts writes the major and minor parts of a duration, omitting the minor part when zero.
+func joinDurationParts(major int64, majorUnit string, minor int64, minorUnit string) string {
+	if minor == 0 {
+		return fmt.Sprintf("%d%s", major, majorUnit)
+	}
+	return fmt.Sprintf("%d%s %d%s", major, majorUnit, minor, minorUnit)
+}
+
+// humanInt writes the integer in the given format: "bytes" (see HumanBytes), "number" (see HumanNumber), or as-is.
+func humanInt(n int64, format string) string {
+	switch format {
+	case "bytes":
+		return HumanBytes(n)
+	case "number":
+		return HumanNumber(n)
+	}
+	return strconv.FormatInt(n, 10)
+}
@@ -0,0 +1,123 @@
+package clio
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HumanNumber(t *testing.T) {
+	defer setFormatting(false, func(string) string { return "" })
+
+	tests := []struct {
+		name   string
+		locale string
+		raw    bool
+		n      int64
+		want   string
+	}{
+		{name: "small", n: 999, want: "999"},
+		{name: "thousands", n: 1000, want: "1,000"},
+		{name: "millions", n: 1234567, want: "1,234,567"},
+		{name: "negative", n: -1234567, want: "-1,234,567"},
+		{name: "negative small", n: -12, want: "-12"},
+		{name: "min int", n: math.MinInt64, want: "-9,223,372,036,854,775,808"},
+		{name: "max int", n: math.MaxInt64, want: "9,223,372,036,854,775,807"},
+		{name: "german", locale: "de_DE.UTF-8", n: 1234567, want: "1.234.567"},
+		{name: "french", locale: "fr_FR", n: 1234567, want: "1\u00a0234\u00a0567"},
+		{name: "unknown locale", locale: "C", n: 1234567, want: "1,234,567"},
+		{name: "raw", raw: true, n: -1234567, want: "-1234567"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFormatting(tt.raw, func(name string) string {
+				if name == "LANG" {
+					return tt.locale
+				}
+				return ""
+			})
+			assert.Equal(t, tt.want, HumanNumber(tt.n))
+		})
+	}
+}
+
+func Test_HumanBytes(t *testing.T) {
+	defer setFormatting(false, func(string) string { return "" })
+
+	tests := []struct {
+		name   string
+		locale string
+		raw    bool
+		bytes  int64
+		want   string
+	}{
+		{name: "zero", bytes: 0, want: "0 B"},
+		{name: "bytes", bytes: 1023, want: "1023 B"},
+		{name: "one KiB", bytes: 1024, want: "1.0 KiB"},
+		{name: "fractional", bytes: 1536, want: "1.5 KiB"},
+		{name: "just under a MiB", bytes: 1024*1024 - 1, want: "1.0 MiB"},
+		{name: "just under the rounding boundary", bytes: 1024*1024 - 52, want: "1023.9 KiB"},
+		{name: "GiB", bytes: 2 << 30, want: "2.0 GiB"},
+		{name: "negative", bytes: -1536, want: "-1.5 KiB"},
+		{name: "negative bytes", bytes: -10, want: "-10 B"},
+		{name: "min int", bytes: math.MinInt64, want: "-8.0 EiB"},
+		{name: "max int", bytes: math.MaxInt64, want: "8.0 EiB"},
+		{name: "german", locale: "de_DE.UTF-8", bytes: 1536, want: "1,5 KiB"},
+		{name: "raw", raw: true, bytes: 1536, want: "1536"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFormatting(tt.raw, func(name string) string {
+				if name == "LC_ALL" {
+					return tt.locale
+				}
+				return ""
+			})
+			assert.Equal(t, tt.want, HumanBytes(tt.bytes))
+		})
+	}
+}
+
+func Test_HumanDuration(t *testing.T) {
+	defer setFormatting(false, func(string) string { return "" })
+
+	tests := []struct {
+		name     string
+		locale   string
+		raw      bool
+		duration time.Duration
+		want     string
+	}{
+		{name: "zero", duration: 0, want: "0s"},
+		{name: "sub-millisecond", duration: 400 * time.Microsecond, want: "0ms"},
+		{name: "milliseconds", duration: 250 * time.Millisecond, want: "250ms"},
+		{name: "just under a second", duration: 999600 * time.Microsecond, want: "1s"},
+		{name: "seconds", duration: 4200 * time.Millisecond, want: "4.2s"},
+		{name: "whole seconds", duration: 2 * time.Second, want: "2s"},
+		{name: "just under a minute", duration: 59960 * time.Millisecond, want: "1m"},
+		{name: "minutes", duration: 3*time.Minute + 5*time.Second, want: "3m 5s"},
+		{name: "just under an hour", duration: 59*time.Minute + 59600*time.Millisecond, want: "1h"},
+		{name: "hours", duration: 2*time.Hour + 10*time.Minute, want: "2h 10m"},
+		{name: "just under a day", duration: 23*time.Hour + 59*time.Minute + 40*time.Second, want: "1d"},
+		{name: "days", duration: 50 * time.Hour, want: "2d 2h"},
+		{name: "negative", duration: -(3*time.Minute + 5*time.Second), want: "-3m 5s"},
+		{name: "negative seconds", duration: -1500 * time.Millisecond, want: "-1.5s"},
+		{name: "min duration", duration: math.MinInt64, want: "-106751d 23h"},
+		{name: "max duration", duration: math.MaxInt64, want: "106751d 23h"},
+		{name: "german", locale: "de_DE.UTF-8", duration: 4200 * time.Millisecond, want: "4,2s"},
+		{name: "raw", raw: true, duration: 3*time.Minute + 5*time.Second, want: "3m5s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFormatting(tt.raw, func(name string) string {
+				if name == "LC_NUMERIC" {
+					return tt.locale
+				}
+				return ""
+			})
+			assert.Equal(t, tt.want, HumanDuration(tt.duration))
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package clio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// InstanceHandler handles an invocation forwarded to the running instance (see SetupConfig.WithSingleInstance).
+// Output written to stdout and stderr is shown by the forwarding invocation, which exits with the returned error.
+// The input of the forwarding invocation is read from inv.Stdin. The context is cancelled when the forwarding
+// invocation is interrupted. Handlers may be called concurrently.
+type InstanceHandler func(ctx context.Context, inv Invocation, stdout, stderr io.Writer) error
+
+// instanceSocketPath returns the socket configured with SetupConfig.WithSingleInstance, defaulting to a location
+// within the user cache dir (which is only accessible to the current user).
+func (a *application) instanceSocketPath() string {
+	if a.setupConfig.InstanceSocket != "" {
+		return a.setupConfig.InstanceSocket
+	}
+	dir, err := userCacheDir(a.setupConfig.ID.Name)
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, a.setupConfig.ID.Name, "instance.sock")
+}
+
+// forwardToInstance forwards the invocation to the running instance (if any), returning true when it has.
+func (a *application) forwardToInstance(cmd *cobra.Command) bool {
+	if a.setupConfig.InstanceHandler == nil || a.daemon.isServing() {
+		return false
+	}
+	return forwardInvocation(a.instanceSocketPath(), cmd, nil)
+}
+
+// startInstance makes this process the running instance until the returned function is called, handling all
+// invocations forwarded to it (see SetupConfig.WithSingleInstance).
+func (a *application) startInstance(ctx context.Context) (func(), error) {
+	handler := a.setupConfig.InstanceHandler
+	if handler == nil || a.daemon.isServing() {
+		return func() {}, nil
+	}
+
+	socket := a.instanceSocketPath()
+	if conn, err := net.DialTimeout("unix", socket, daemonDialTimeout); err == nil {
+		// another instance started after this invocation checked for one
+		_ = conn.Close()
+		return nil, fmt.Errorf("another instance of %s is already running", a.setupConfig.ID.Name)
+	}
+	// remove any socket left behind by an instance that did not exit cleanly
+	_ = os.Remove(socket)
+
+	if err := os.MkdirAll(filepath.Dir(socket), 0o700); err != nil {
+		return nil, fmt.Errorf("unable to create instance socket dir: %w", err)
+	}
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on instance socket: %w", err)
+	}
+	if err := os.Chmod(socket, 0o600); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("unable to restrict instance socket: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				serveInvocation(ctx, conn, a.setupConfig.ID, func(ctx context.Context, inv Invocation, out *invocationWriter) error {
+					return handler(ctx, inv, out.stream(false), out.stream(true))
+				})
+			}()
+		}
+	}()
+
+	return func() {
+		_ = listener.Close()
+		cancel()
+		wg.Wait()
+		_ = os.Remove(socket)
+	}, nil
+}
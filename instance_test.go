@@ -0,0 +1,88 @@
+package clio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newInstanceTestApp(socket string, ranLocally *bool, done <-chan struct{}) *cobra.Command {
+	handler := func(_ context.Context, inv Invocation, stdout, _ io.Writer) error {
+		if len(inv.Args) < 2 {
+			return fmt.Errorf("nothing to open")
+		}
+		fmt.Fprintf(stdout, "opened %s\n", strings.Join(inv.Args[1:], " "))
+		return nil
+	}
+
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithSingleInstance(socket, handler))
+	root := app.SetupRootCommand(&cobra.Command{})
+	root.AddCommand(app.SetupCommand(&cobra.Command{
+		Use: "open",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			*ranLocally = true
+			if done != nil {
+				<-done
+			}
+			return nil
+		},
+	}))
+	return root
+}
+
+func Test_Application_singleInstance(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "i.sock")
+
+	done := make(chan struct{})
+	var ranFirst bool
+	first := newInstanceTestApp(socket, &ranFirst, done)
+	first.SetArgs([]string{"open", "a.txt"})
+	result := make(chan error)
+	go func() {
+		result <- first.Execute()
+	}()
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("unix", socket)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	run := func(args ...string) (string, bool, error) {
+		original := invocationArgs
+		defer func() { invocationArgs = original }()
+		invocationArgs = func() []string { return args }
+
+		var ranLocally bool
+		stdout, _, err := runTestApp(newInstanceTestApp(socket, &ranLocally, nil), "", args...)
+		return stdout, ranLocally, err
+	}
+
+	out, ranLocally, err := run("open", "b.txt", "c.txt")
+	require.NoError(t, err)
+	assert.False(t, ranLocally)
+	assert.Equal(t, "opened b.txt c.txt\n", out)
+
+	_, ranLocally, err = run("open")
+	require.ErrorContains(t, err, "nothing to open")
+	assert.False(t, ranLocally)
+
+	close(done)
+	require.NoError(t, <-result)
+	assert.True(t, ranFirst)
+
+	// once the instance exits, the next invocation runs
+	_, ranLocally, err = run("open", "d.txt")
+	require.NoError(t, err)
+	assert.True(t, ranLocally)
+}
@@ -0,0 +1,110 @@
+package clio
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// invocation identifies the current run of the application (and the run that started it, if any), in order to
+// correlate the logs and events of multiple processes (e.g. for a support ticket).
+type invocation struct {
+	id     string
+	parent string
+}
+
+// InvocationIDEnvVar returns the environment variable holding the invocation ID of the running application (e.g.
+// "APP_INVOCATION_ID"), which is inherited by child processes.
+func InvocationIDEnvVar(appName string) string {
+	return envVarName(appName, []string{"invocation", "id"})
+}
+
+// InvocationID returns the unique ID of this run of the application, which is attached to log records written to a
+// file or in a CI format (and given to LoggerConstructors as Config.InvocationID), exported log records (see
+// TelemetryExporters), events sent to remote subscribers (see SetupConfig.WithBusBridge), and run stats kept for bug
+// reports.
+func (s *State) InvocationID() string {
+	return s.invocation.id
+}
+
+// ParentInvocationID returns the invocation ID of the application that started this process (as inherited through
+// the InvocationIDEnvVar), or empty if not started by the application.
+func (s *State) ParentInvocationID() string {
+	return s.invocation.parent
+}
+
+// setupInvocation generates the invocation ID, exposing it to child processes through the environment.
+func (s *State) setupInvocation() {
+	if s.invocation.id != "" {
+		return
+	}
+	env := InvocationIDEnvVar(s.id.Name)
+	s.invocation = invocation{
+		id:     invocationIDFrom(s.invocationRandom()),
+		parent: os.Getenv(env),
+	}
+	_ = os.Setenv(env, s.invocation.id)
+}
+
+// fields are the logger fields identifying the invocation.
+func (i invocation) fields() []interface{} {
+	fields := []interface{}{"invocation", i.id}
+	if i.parent != "" {
+		fields = append(fields, "parent-invocation", i.parent)
+	}
+	return fields
+}
+
+// invocationRandom returns the source of the invocation ID, which is seeded with a fixed seed in deterministic mode
+// (separately from State.Rand, so the values it produces do not depend on the invocation ID).
+func (s *State) invocationRandom() io.Reader {
+	if DeterministicMode() {
+		return mathrand.New(mathrand.NewSource(deterministicSeed))
+	}
+	return rand.Reader
+}
+
+// newInvocationID returns a random (version 4) UUID.
+func newInvocationID() string {
+	return invocationIDFrom(rand.Reader)
+}
+
+// invocationIDFrom returns a (version 4) UUID read from the given source of randomness.
+func invocationIDFrom(random io.Reader) string {
+	var b [16]byte
+	if _, err := io.ReadFull(random, b[:]); err != nil {
+		// this never happens in practice, and a predictable ID is only a problem for correlation
+		return fmt.Sprintf("%x", os.Getpid())
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// invocationFormatter adds the invocation fields to every log entry.
+type invocationFormatter struct {
+	next   logrus.Formatter
+	fields []interface{}
+}
+
+func withInvocation(f logrus.Formatter, inv invocation) logrus.Formatter {
+	return invocationFormatter{next: f, fields: inv.fields()}
+}
+
+func (f invocationFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	data := make(logrus.Fields, len(entry.Data)+len(f.fields)/2)
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+	for k, v := range logFields(f.fields) {
+		data[k] = v
+	}
+
+	withInvocation := *entry
+	withInvocation.Data = data
+	return f.next.Format(&withInvocation)
+}
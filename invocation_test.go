@@ -0,0 +1,72 @@
+package clio
+
+import (
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/go-logger"
+	"github.com/boss-net/go-logger/adapter/discard"
+	"github.com/boss-net/go-logger/adapter/redact"
+)
+
+func Test_newInvocationID(t *testing.T) {
+	id := newInvocationID()
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, id)
+	assert.NotEqual(t, id, newInvocationID())
+}
+
+func Test_InvocationIDEnvVar(t *testing.T) {
+	assert.Equal(t, "MY_APP_INVOCATION_ID", InvocationIDEnvVar("my-app"))
+}
+
+func TestState_setupInvocation(t *testing.T) {
+	t.Setenv("APP_INVOCATION_ID", "parent-id")
+
+	s := &State{id: Identification{Name: "app"}}
+	s.setupInvocation()
+
+	id := s.InvocationID()
+	require.NotEmpty(t, id)
+	assert.NotEqual(t, "parent-id", id)
+	assert.Equal(t, "parent-id", s.ParentInvocationID())
+	assert.Equal(t, []interface{}{"invocation", id, "parent-invocation", "parent-id"}, s.invocation.fields())
+
+	// child processes inherit the ID
+	assert.Equal(t, id, os.Getenv("APP_INVOCATION_ID"))
+
+	// the ID is stable for the life of the state
+	s.setupInvocation()
+	assert.Equal(t, id, s.InvocationID())
+	assert.Equal(t, "parent-id", s.ParentInvocationID())
+}
+
+func Test_withInvocation(t *testing.T) {
+	f := withInvocation(&logrus.JSONFormatter{DisableTimestamp: true}, invocation{id: "id-1", parent: "id-0"})
+
+	out, err := f.Format(&logrus.Entry{Message: "hello", Level: logrus.InfoLevel, Data: logrus.Fields{"component": "test"}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"component":"test","invocation":"id-1","parent-invocation":"id-0","level":"info","msg":"hello"}`, string(out))
+}
+
+func Test_Application_invocationLoggerConstructor(t *testing.T) {
+	t.Setenv("APP_INVOCATION_ID", "")
+
+	var got Config
+	app := New(*NewSetupConfig(Identification{Name: "app"}).
+		WithNoBus().
+		WithLoggerConstructor(func(cfg Config, _ redact.Store) (logger.Logger, error) {
+			got = cfg
+			return discard.New(), nil
+		}))
+	root := app.SetupRootCommand(&cobra.Command{RunE: func(*cobra.Command, []string) error { return nil }})
+	root.SetArgs(nil)
+	require.NoError(t, root.Execute())
+
+	require.NotEmpty(t, got.InvocationID)
+	assert.Equal(t, app.(*application).State().InvocationID(), got.InvocationID)
+}
@@ -0,0 +1,523 @@
+package clio
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boss-net/go-logger"
+)
+
+const (
+	defaultJobMaxAttempts  = 3
+	defaultJobPollInterval = time.Second
+	jobRetryBaseDelay      = time.Second
+	jobRetryMaxDelay       = 5 * time.Minute
+	jobPruneInterval       = time.Hour
+
+	// DefaultJobRetention is how long finished jobs are kept by the application job queue (see
+	// SetupConfig.WithJobRetention)
+	DefaultJobRetention = 7 * 24 * time.Hour
+)
+
+type JobState string
+
+const (
+	JobPending JobState = "pending"
+	JobRunning JobState = "running"
+	JobDone    JobState = "done"
+	JobFailed  JobState = "failed"
+)
+
+// Job is a unit of work in the JobQueue.
+type Job struct {
+	ID          string          `json:"id" yaml:"id" table:"ID"`
+	Type        string          `json:"type" yaml:"type" table:"TYPE"`
+	State       JobState        `json:"state" yaml:"state" table:"STATE"`
+	Attempts    int             `json:"attempts" yaml:"attempts" table:"ATTEMPTS"`
+	MaxAttempts int             `json:"maxAttempts" yaml:"maxAttempts" table:"-"`
+	Payload     json.RawMessage `json:"payload,omitempty" yaml:"-" table:"-"`
+	LastError   string          `json:"lastError,omitempty" yaml:"lastError,omitempty" table:"ERROR"`
+	Created     time.Time       `json:"created" yaml:"created" table:"CREATED"`
+	Updated     time.Time       `json:"updated" yaml:"updated" table:"-"`
+	NotBefore   time.Time       `json:"notBefore,omitempty" yaml:"notBefore,omitempty" table:"-"`
+}
+
+// JobHandler processes a claimed job. Returning an error retries the job (with backoff) until the job has been
+// attempted MaxAttempts times, after which the job is marked as failed.
+type JobHandler func(ctx context.Context, job Job) error
+
+// JobQueue is a persistent queue of jobs, stored as one file per job within a directory so that jobs survive
+// restarts. Any process may enqueue jobs, but only a single process should claim them (e.g. a daemon, see
+// SetupConfig.WithJobQueue). Job files that can't be parsed are moved aside (renamed with a ".corrupt" suffix) rather
+// than failing the queue.
+type JobQueue struct {
+	dir         string
+	fileMode    os.FileMode
+	maxAttempts int
+	retention   time.Duration // how long finished jobs are kept while processing (0 keeps them until removed)
+	log         logger.Logger // where problems with job files are reported (optional)
+
+	lock   sync.Mutex
+	notify chan struct{}
+}
+
+// OpenJobQueue opens (creating if needed) the job queue within the given directory.
+func OpenJobQueue(dir string) (*JobQueue, error) {
+	return openJobQueue(dir, nil)
+}
+
+func openJobQueue(dir string, perms *PermissionsConfig) (*JobQueue, error) {
+	fileMode, dirMode := perms.modes()
+	if err := mkdirAll(dir, dirMode); err != nil {
+		return nil, fmt.Errorf("unable to create job queue dir: %w", err)
+	}
+	return &JobQueue{
+		dir:         dir,
+		fileMode:    fileMode,
+		maxAttempts: defaultJobMaxAttempts,
+		notify:      make(chan struct{}, 1),
+	}, nil
+}
+
+// Enqueue adds a job of the given type, where the payload is encoded as json.
+func (q *JobQueue) Enqueue(jobType string, payload any) (Job, error) {
+	if jobType == "" {
+		return Job{}, errors.New("a job type is required")
+	}
+
+	var raw json.RawMessage
+	if payload != nil {
+		by, err := json.Marshal(payload)
+		if err != nil {
+			return Job{}, fmt.Errorf("unable to encode job payload: %w", err)
+		}
+		raw = by
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+
+	now := time.Now().UTC()
+	job := Job{
+		ID:          id,
+		Type:        jobType,
+		State:       JobPending,
+		MaxAttempts: q.maxAttempts,
+		Payload:     raw,
+		Created:     now,
+		Updated:     now,
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if err := q.write(job); err != nil {
+		return Job{}, err
+	}
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Jobs returns all jobs in the queue, oldest first.
+func (q *JobQueue) Jobs() ([]Job, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.read()
+}
+
+// Claim marks the oldest pending job that is ready to run as running, returning false when there is none.
+func (q *JobQueue) Claim() (Job, bool, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	jobs, err := q.read()
+	if err != nil {
+		return Job{}, false, err
+	}
+
+	now := time.Now().UTC()
+	for _, job := range jobs {
+		if job.State != JobPending || job.NotBefore.After(now) {
+			continue
+		}
+		job.State = JobRunning
+		job.Attempts++
+		job.Updated = now
+		if err := q.write(job); err != nil {
+			return Job{}, false, err
+		}
+		return job, true, nil
+	}
+	return Job{}, false, nil
+}
+
+// Complete records the result of a claimed job. Failed jobs are retried with exponential backoff until they have
+// been attempted MaxAttempts times.
+func (q *JobQueue) Complete(job Job, result error) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	job.Updated = time.Now().UTC()
+	switch {
+	case result == nil:
+		job.State = JobDone
+		job.LastError = ""
+	case job.Attempts >= job.MaxAttempts:
+		job.State = JobFailed
+		job.LastError = result.Error()
+	default:
+		job.State = JobPending
+		job.LastError = result.Error()
+		job.NotBefore = job.Updated.Add(jobRetryDelay(job.Attempts))
+	}
+	return q.write(job)
+}
+
+// Remove deletes jobs that finished (are done or failed) before the given time from the queue.
+func (q *JobQueue) Remove(finishedBefore time.Time) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	jobs, err := q.read()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if (job.State == JobDone || job.State == JobFailed) && job.Updated.Before(finishedBefore) {
+			if err := os.Remove(q.path(job.ID)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("unable to remove job %s: %w", job.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Process claims and handles jobs with the given number of workers until the context is cancelled. Jobs that were
+// running when a previous process exited are run again. Finished jobs are removed periodically once they are older
+// than the retention of the queue (see SetupConfig.WithJobRetention). When a worker fails (e.g. the queue can no
+// longer be written), all other workers are stopped and the first error is returned.
+func (q *JobQueue) Process(ctx context.Context, workers int, handler JobHandler) error {
+	if err := q.requeueRunning(); err != nil {
+		return err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers+1)
+	run := func(fn func(ctx context.Context) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(ctx); err != nil {
+				errs <- err
+				cancel()
+			}
+		}()
+	}
+	if q.retention > 0 {
+		run(q.prune)
+	}
+	for i := 0; i < workers; i++ {
+		run(func(ctx context.Context) error {
+			return q.work(ctx, handler)
+		})
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+func (q *JobQueue) work(ctx context.Context, handler JobHandler) error {
+	ticker := time.NewTicker(defaultJobPollInterval)
+	defer ticker.Stop()
+	for {
+		for {
+			if ctx.Err() != nil {
+				return nil
+			}
+			job, ok, err := q.Claim()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+			result := runJob(ctx, handler, job)
+			if ctx.Err() != nil {
+				// interrupted by shutdown, so the job is run again on the next start
+				return q.release(job)
+			}
+			if err := q.Complete(job, result); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-q.notify:
+		case <-ticker.C:
+		}
+	}
+}
+
+// prune removes finished jobs older than the retention of the queue until the context is cancelled.
+func (q *JobQueue) prune(ctx context.Context) error {
+	ticker := time.NewTicker(jobPruneInterval)
+	defer ticker.Stop()
+	for {
+		if err := q.Remove(time.Now().Add(-q.retention)); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func runJob(ctx context.Context, handler JobHandler, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// release returns a claimed job to the queue without counting the attempt.
+func (q *JobQueue) release(job Job) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	job.State = JobPending
+	job.Attempts--
+	job.Updated = time.Now().UTC()
+	return q.write(job)
+}
+
+// requeueRunning marks jobs left running (by a process that exited while handling them) as pending.
+func (q *JobQueue) requeueRunning() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	jobs, err := q.read()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.State != JobRunning {
+			continue
+		}
+		job.State = JobPending
+		job.Updated = time.Now().UTC()
+		if err := q.write(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *JobQueue) path(id string) string {
+	return filepath.Join(q.dir, id+".json")
+}
+
+func (q *JobQueue) read() ([]Job, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read job queue: %w", err)
+	}
+
+	var jobs []Job
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		contents, err := os.ReadFile(filepath.Join(q.dir, e.Name()))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("unable to read job: %w", err)
+		}
+		var job Job
+		if err := json.Unmarshal(contents, &job); err != nil || job.ID == "" {
+			q.quarantine(e.Name(), err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		if !jobs[i].Created.Equal(jobs[j].Created) {
+			return jobs[i].Created.Before(jobs[j].Created)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+	return jobs, nil
+}
+
+// quarantine moves the job file that could not be parsed aside (so that it is no longer read) for inspection.
+func (q *JobQueue) quarantine(name string, err error) {
+	if err == nil {
+		err = errors.New("missing job ID")
+	}
+	path := filepath.Join(q.dir, name)
+	if renameErr := os.Rename(path, path+".corrupt"); renameErr != nil && !os.IsNotExist(renameErr) {
+		err = fmt.Errorf("%v (unable to move aside: %w)", err, renameErr)
+	}
+	if q.log != nil {
+		q.log.WithFields("file", path+".corrupt").Warnf("skipping unreadable job: %v", err)
+	}
+}
+
+// write saves the job atomically (to a temporary file that replaces the job file).
+func (q *JobQueue) write(job Job) error {
+	contents, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("unable to encode job: %w", err)
+	}
+	tmp := filepath.Join(q.dir, "."+job.ID+".tmp")
+	if err := os.WriteFile(tmp, contents, q.fileMode); err != nil {
+		return fmt.Errorf("unable to write job: %w", err)
+	}
+	if err := os.Rename(tmp, q.path(job.ID)); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("unable to write job: %w", err)
+	}
+	return nil
+}
+
+func jobRetryDelay(attempts int) time.Duration {
+	delay := jobRetryBaseDelay
+	for i := 1; i < attempts && delay < jobRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > jobRetryMaxDelay {
+		delay = jobRetryMaxDelay
+	}
+	return delay
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate job ID: %w", err)
+	}
+	return fmt.Sprintf("%d-%s", time.Now().UnixNano(), hex.EncodeToString(b)), nil
+}
+
+// jobQueueDir returns the directory of the job queue (see SetupConfig.WithJobQueue).
+func (a *application) jobQueueDir() (string, error) {
+	if a.setupConfig.JobQueueDir != "" {
+		return a.setupConfig.JobQueueDir, nil
+	}
+	dir, err := stateDir(a.setupConfig.ID.Name)
+	if err != nil {
+		return "", fmt.Errorf("unable to determine job queue dir: %w", err)
+	}
+	return filepath.Join(dir, "jobs"), nil
+}
+
+// JobQueue returns the application job queue (see SetupConfig.WithJobQueue), which any command may add jobs to.
+func (s *State) JobQueue() (*JobQueue, error) {
+	s.jobs.lock.Lock()
+	defer s.jobs.lock.Unlock()
+
+	if s.jobs.queue != nil {
+		return s.jobs.queue, nil
+	}
+	if s.jobs.dir == "" {
+		return nil, errors.New("the job queue is not enabled (see SetupConfig.WithJobQueue)")
+	}
+	q, err := openJobQueue(s.jobs.dir, s.currentConfig().Permissions)
+	if err != nil {
+		return nil, err
+	}
+	q.retention = s.jobs.retention
+	q.log = s.currentLogger()
+	s.jobs.queue = q
+	return q, nil
+}
+
+// jobQueueState holds the job queue for the State.
+type jobQueueState struct {
+	lock      sync.Mutex
+	dir       string
+	retention time.Duration
+	queue     *JobQueue
+}
+
+// setupJobQueue determines the job queue location (the queue is opened on first use).
+func (a *application) setupJobQueue() error {
+	if a.setupConfig.JobHandler == nil {
+		return nil
+	}
+	dir, err := a.jobQueueDir()
+	if err != nil {
+		return err
+	}
+	a.state.jobs.lock.Lock()
+	defer a.state.jobs.lock.Unlock()
+	a.state.jobs.dir = dir
+	a.state.jobs.retention = a.setupConfig.JobRetention
+	if a.state.jobs.retention == 0 {
+		a.state.jobs.retention = DefaultJobRetention
+	}
+	return nil
+}
+
+// processJobs handles jobs in the background until the returned function is called (used by the daemon). Each job is
+// handled while holding invocationLock, so jobs never run alongside the commands of invocations.
+func (a *application) processJobs(ctx context.Context) (func(), error) {
+	handler := a.setupConfig.JobHandler
+	if handler == nil {
+		return func() {}, nil
+	}
+	if err := a.setupJobQueue(); err != nil {
+		return nil, err
+	}
+	q, err := a.state.JobQueue()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := q.Process(ctx, a.setupConfig.JobWorkers, func(ctx context.Context, job Job) error {
+			invocationLock.Lock()
+			defer invocationLock.Unlock()
+			return handler(ctx, job)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "job queue stopped: %v\n", err)
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}, nil
+}
@@ -0,0 +1,252 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/go-logger/adapter/discard"
+)
+
+func Test_JobQueue_retries(t *testing.T) {
+	q, err := OpenJobQueue(t.TempDir())
+	require.NoError(t, err)
+
+	queued, err := q.Enqueue("scan", map[string]string{"image": "alpine"})
+	require.NoError(t, err)
+	assert.Equal(t, JobPending, queued.State)
+
+	job, ok, err := q.Claim()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, queued.ID, job.ID)
+	assert.Equal(t, 1, job.Attempts)
+	assert.JSONEq(t, `{"image":"alpine"}`, string(job.Payload))
+
+	// nothing else is ready
+	_, ok, err = q.Claim()
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, q.Complete(job, errors.New("registry unavailable")))
+	jobs, err := q.Jobs()
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, JobPending, jobs[0].State)
+	assert.Equal(t, "registry unavailable", jobs[0].LastError)
+	assert.True(t, jobs[0].NotBefore.After(time.Now()), "retries are delayed")
+
+	// once all attempts are used the job fails
+	job = jobs[0]
+	job.Attempts = job.MaxAttempts
+	require.NoError(t, q.Complete(job, errors.New("registry unavailable")))
+	jobs, err = q.Jobs()
+	require.NoError(t, err)
+	assert.Equal(t, JobFailed, jobs[0].State)
+
+	require.NoError(t, q.Remove(time.Now().Add(time.Second)))
+	jobs, err = q.Jobs()
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+func Test_JobQueue_Process(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenJobQueue(dir)
+	require.NoError(t, err)
+
+	// a job left running by a previous process is run again
+	_, err = q.Enqueue("first", nil)
+	require.NoError(t, err)
+	_, _, err = q.Claim()
+	require.NoError(t, err)
+
+	_, err = q.Enqueue("second", nil)
+	require.NoError(t, err)
+
+	var lock sync.Mutex
+	var handled []string
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- q.Process(ctx, 2, func(_ context.Context, job Job) error {
+			lock.Lock()
+			defer lock.Unlock()
+			handled = append(handled, job.Type)
+			return nil
+		})
+	}()
+
+	require.Eventually(t, func() bool {
+		jobs, err := q.Jobs()
+		require.NoError(t, err)
+		for _, j := range jobs {
+			if j.State != JobDone {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.ElementsMatch(t, []string{"first", "second"}, handled)
+}
+
+func Test_JobQueue_Process_failure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "jobs")
+	q, err := OpenJobQueue(dir)
+	require.NoError(t, err)
+	_, err = q.Enqueue("scan", nil)
+	require.NoError(t, err)
+
+	// the worker handling the job is unable to complete it, which stops the idle workers
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = q.Process(ctx, 3, func(context.Context, Job) error {
+		return os.RemoveAll(dir)
+	})
+	require.Error(t, err)
+	assert.NoError(t, ctx.Err(), "returned before the context ended")
+}
+
+func Test_JobQueue_corruptJob(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenJobQueue(dir)
+	require.NoError(t, err)
+	rec := &warnRecorder{Logger: discard.New()}
+	q.log = rec
+
+	_, err = q.Enqueue("scan", nil)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1-bad.json"), []byte("{truncated"), 0o600))
+
+	jobs, err := q.Jobs()
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "scan", jobs[0].Type)
+
+	assert.NoFileExists(t, filepath.Join(dir, "1-bad.json"))
+	assert.FileExists(t, filepath.Join(dir, "1-bad.json.corrupt"))
+	require.Len(t, rec.warningsf, 1)
+	assert.Contains(t, rec.warningsf[0], "skipping unreadable job")
+
+	// the corrupt file is only reported once
+	_, err = q.Jobs()
+	require.NoError(t, err)
+	assert.Len(t, rec.warningsf, 1)
+}
+
+func Test_JobQueue_Process_retention(t *testing.T) {
+	q, err := OpenJobQueue(t.TempDir())
+	require.NoError(t, err)
+	q.retention = time.Hour
+
+	old, err := q.Enqueue("old", nil)
+	require.NoError(t, err)
+	old.State = JobDone
+	old.Updated = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, q.write(old))
+
+	recent, err := q.Enqueue("recent", nil)
+	require.NoError(t, err)
+	recent.State = JobFailed
+	recent.Updated = time.Now()
+	require.NoError(t, q.write(recent))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- q.Process(ctx, 1, func(context.Context, Job) error { return nil })
+	}()
+
+	require.Eventually(t, func() bool {
+		jobs, err := q.Jobs()
+		require.NoError(t, err)
+		return len(jobs) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	jobs, err := q.Jobs()
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "recent", jobs[0].Type)
+}
+
+func Test_Application_control_jobs(t *testing.T) {
+	dir := t.TempDir()
+	newCfg := func() *SetupConfig {
+		cfg := NewSetupConfig(Identification{Name: "app"}).
+			WithNoBus().
+			WithControlServer(dir).
+			WithJobQueue(1, func(context.Context, Job) error { return nil })
+		cfg.JobQueueDir = dir + "/jobs"
+		return cfg
+	}
+
+	app := New(*newCfg())
+	root := app.SetupRootCommand(&cobra.Command{})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	root.AddCommand(app.SetupCommand(&cobra.Command{
+		Use: "serve",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			close(started)
+			<-release
+			return nil
+		},
+	}))
+	root.SetArgs([]string{"serve"})
+	result := make(chan error)
+	go func() {
+		result <- root.Execute()
+	}()
+	<-started
+
+	ctl := func(args ...string) (string, error) {
+		client := New(*newCfg())
+		r := client.SetupRootCommand(&cobra.Command{})
+		var out bytes.Buffer
+		r.SetOut(&out)
+		r.SetArgs(append([]string{"ctl"}, args...))
+		err := r.Execute()
+		return out.String(), err
+	}
+
+	_, err := ctl("enqueue", "scan", "not-json")
+	require.ErrorContains(t, err, "invalid payload")
+
+	out, err := ctl("enqueue", "scan", `{"image":"alpine"}`)
+	require.NoError(t, err)
+	var job Job
+	require.NoError(t, json.Unmarshal([]byte(out), &job))
+	assert.Equal(t, "scan", job.Type)
+
+	out, err = ctl("jobs")
+	require.NoError(t, err)
+	var jobs []Job
+	require.NoError(t, json.Unmarshal([]byte(out), &jobs))
+	require.Len(t, jobs, 1)
+	assert.Equal(t, job.ID, jobs[0].ID)
+	assert.JSONEq(t, `{"image":"alpine"}`, string(jobs[0].Payload))
+
+	close(release)
+	require.NoError(t, <-result)
+}
+
+func Test_jobRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Second, jobRetryDelay(1))
+	assert.Equal(t, 4*time.Second, jobRetryDelay(3))
+	assert.Equal(t, jobRetryMaxDelay, jobRetryDelay(100))
+}
@@ -0,0 +1,183 @@
+package clio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrCredentialNotFound is returned by a CredentialStore when there is no value for the key.
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// errKeyringUnavailable is returned when the system keyring can't be used (e.g. there is no secret service running).
+var errKeyringUnavailable = errors.New("the system keyring is not available")
+
+// CredentialStore keeps secrets (such as the credentials from logging in, see SetupConfig.WithAuth) for the
+// application. By default these are kept in the system keyring: the macOS keychain, the windows credential manager,
+// or the secret service (with secret-tool) elsewhere, falling back to a file only readable by the user when there is
+// no keyring available.
+type CredentialStore interface {
+	Get(key string) (string, error)
+	Set(key, value string) error
+	Delete(key string) error
+}
+
+// keyringCredentialStore keeps secrets in the system keyring, under the name of the application.
+type keyringCredentialStore struct {
+	service string
+}
+
+func (k keyringCredentialStore) Get(key string) (string, error) {
+	return keyringGet(k.service, key)
+}
+
+func (k keyringCredentialStore) Set(key, value string) error {
+	return keyringSet(k.service, key, value)
+}
+
+func (k keyringCredentialStore) Delete(key string) error {
+	return keyringDelete(k.service, key)
+}
+
+// fileCredentialStore keeps secrets in a file which is only readable by the user, for systems without a keyring.
+type fileCredentialStore struct {
+	path string
+	lock sync.Mutex
+}
+
+func (f *fileCredentialStore) Get(key string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	values, err := f.read()
+	if err != nil {
+		return "", err
+	}
+	value, ok := values[key]
+	if !ok {
+		return "", ErrCredentialNotFound
+	}
+	return value, nil
+}
+
+func (f *fileCredentialStore) Set(key, value string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	values, err := f.read()
+	if err != nil {
+		return err
+	}
+	values[key] = value
+	return f.write(values)
+}
+
+func (f *fileCredentialStore) Delete(key string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	values, err := f.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := values[key]; !ok {
+		return ErrCredentialNotFound
+	}
+	delete(values, key)
+	return f.write(values)
+}
+
+func (f *fileCredentialStore) read() (map[string]string, error) {
+	values := map[string]string{}
+	contents, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read credentials: %w", err)
+	}
+	if err := json.Unmarshal(contents, &values); err != nil {
+		return nil, fmt.Errorf("unable to read credentials from %s: %w", DisplayPath(f.path), err)
+	}
+	return values, nil
+}
+
+func (f *fileCredentialStore) write(values map[string]string) error {
+	contents, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	// note: secrets are never readable by others, regardless of the permissions policy
+	if err := mkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return fmt.Errorf("unable to write credentials: %w", err)
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, contents, 0o600); err != nil {
+		return fmt.Errorf("unable to write credentials: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("unable to write credentials: %w", err)
+	}
+	return nil
+}
+
+// fallbackCredentialStore uses the system keyring, falling back to a file when the keyring is not available.
+type fallbackCredentialStore struct {
+	keyring  CredentialStore
+	file     CredentialStore
+	fallback func() // called the first time the file is used
+	once     sync.Once
+}
+
+func (s *fallbackCredentialStore) Get(key string) (string, error) {
+	value, err := s.keyring.Get(key)
+	if errors.Is(err, errKeyringUnavailable) {
+		return s.fileStore().Get(key)
+	}
+	return value, err
+}
+
+func (s *fallbackCredentialStore) Set(key, value string) error {
+	err := s.keyring.Set(key, value)
+	if errors.Is(err, errKeyringUnavailable) {
+		return s.fileStore().Set(key, value)
+	}
+	return err
+}
+
+func (s *fallbackCredentialStore) Delete(key string) error {
+	err := s.keyring.Delete(key)
+	if errors.Is(err, errKeyringUnavailable) {
+		return s.fileStore().Delete(key)
+	}
+	return err
+}
+
+func (s *fallbackCredentialStore) fileStore() CredentialStore {
+	if s.fallback != nil {
+		s.once.Do(s.fallback)
+	}
+	return s.file
+}
+
+// credentialStore returns the store for secrets of the application (see SetupConfig.WithCredentialStore).
+func (s *State) credentialStore(cfg SetupConfig) CredentialStore {
+	if cfg.CredentialStore != nil {
+		return cfg.CredentialStore
+	}
+	dir, err := stateDir(cfg.ID.Name)
+	if err != nil {
+		return keyringCredentialStore{service: cfg.ID.Name}
+	}
+	file := filepath.Join(dir, "credentials.json")
+	return &fallbackCredentialStore{
+		keyring: keyringCredentialStore{service: cfg.ID.Name},
+		file:    &fileCredentialStore{path: file},
+		fallback: func() {
+			if log := s.currentLogger(); log != nil {
+				log.Warnf("%v, credentials are kept in %s instead", errKeyringUnavailable, DisplayPath(file))
+			}
+		},
+	}
+}
@@ -0,0 +1,53 @@
+//go:build darwin
+
+package clio
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// errSecItemNotFound is the exit code of the security command when there is no matching keychain item.
+const errSecItemNotFound = 44
+
+func keyringGet(service, key string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", key, "-w").Output()
+	if err != nil {
+		return "", keychainError(err, "read from")
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func keyringSet(service, key, value string) error {
+	// the command is given on stdin (with the value hex encoded), so the value is not visible in the process list
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %q -a %q -X %s\n", service, key, hex.EncodeToString([]byte(value))))
+	if out, err := cmd.CombinedOutput(); err != nil || len(strings.TrimSpace(string(out))) > 0 {
+		if err == nil {
+			err = errors.New(strings.TrimSpace(string(out)))
+		}
+		return keychainError(err, "write to")
+	}
+	return nil
+}
+
+func keyringDelete(service, key string) error {
+	if err := exec.Command("security", "delete-generic-password", "-s", service, "-a", key).Run(); err != nil {
+		return keychainError(err, "delete from")
+	}
+	return nil
+}
+
+func keychainError(err error, action string) error {
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == errSecItemNotFound:
+		return ErrCredentialNotFound
+	case errors.Is(err, exec.ErrNotFound):
+		return errKeyringUnavailable
+	}
+	return fmt.Errorf("unable to %s the keychain: %w", action, err)
+}
@@ -0,0 +1,63 @@
+//go:build !windows && !darwin
+
+package clio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// the secret service is used through secret-tool (from libsecret), with the same attributes as other tools use
+
+func keyringGet(service, key string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", service, "username", key)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() == 0 {
+			// nothing is written when there is no matching secret
+			return "", ErrCredentialNotFound
+		}
+		return "", secretToolError(err, &stderr, "read from")
+	}
+	return stdout.String(), nil
+}
+
+func keyringSet(service, key, value string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "store", "--label", fmt.Sprintf("%s credentials", service), "service", service, "username", key)
+	// the secret is given on stdin, so it is not visible in the process list
+	cmd.Stdin = strings.NewReader(value)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return secretToolError(err, &stderr, "write to")
+	}
+	return nil
+}
+
+func keyringDelete(service, key string) error {
+	if _, err := keyringGet(service, key); err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "clear", "service", service, "username", key)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return secretToolError(err, &stderr, "delete from")
+	}
+	return nil
+}
+
+func secretToolError(err error, stderr *bytes.Buffer, action string) error {
+	var exitErr *exec.ExitError
+	if errors.Is(err, exec.ErrNotFound) || errors.As(err, &exitErr) {
+		// secret-tool is not installed, or there is no secret service to connect to (e.g. over ssh)
+		return errKeyringUnavailable
+	}
+	return fmt.Errorf("unable to %s the keyring: %w: %s", action, err, strings.TrimSpace(stderr.String()))
+}
@@ -0,0 +1,107 @@
+//go:build windows
+
+package clio
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	advapi32       = windows.NewLazySystemDLL("advapi32.dll")
+	procCredReadW  = advapi32.NewProc("CredReadW")
+	procCredWriteW = advapi32.NewProc("CredWriteW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+)
+
+// credential is the CREDENTIALW structure of the windows credential manager.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// credentialTarget is the name of the credential for the key, within the credentials of the application.
+func credentialTarget(service, key string) (*uint16, error) {
+	return windows.UTF16PtrFromString(fmt.Sprintf("%s:%s", service, key))
+}
+
+func keyringGet(service, key string) (string, error) {
+	target, err := credentialTarget(service, key)
+	if err != nil {
+		return "", err
+	}
+	var cred *credential
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		return "", credentialManagerError(err, "read from")
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+func keyringSet(service, key, value string) error {
+	target, err := credentialTarget(service, key)
+	if err != nil {
+		return err
+	}
+	user, err := windows.UTF16PtrFromString(key)
+	if err != nil {
+		return err
+	}
+	blob := []byte(value)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return credentialManagerError(err, "write to")
+	}
+	return nil
+}
+
+func keyringDelete(service, key string) error {
+	target, err := credentialTarget(service, key)
+	if err != nil {
+		return err
+	}
+	if r, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0); r == 0 {
+		return credentialManagerError(err, "delete from")
+	}
+	return nil
+}
+
+func credentialManagerError(err error, action string) error {
+	switch {
+	case errors.Is(err, windows.ERROR_NOT_FOUND):
+		return ErrCredentialNotFound
+	case errors.Is(err, windows.ERROR_NO_SUCH_LOGON_SESSION):
+		// e.g. when running as a service without a user profile
+		return errKeyringUnavailable
+	}
+	return fmt.Errorf("unable to %s the credential manager: %w", action, err)
+}
@@ -0,0 +1,216 @@
+package clio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/boss-net/fangs"
+)
+
+const (
+	// DefaultLockWait is how long a command waits for a lock held by another process (see Application.RequiresLock).
+	DefaultLockWait = 30 * time.Second
+	// lockRetryInterval is how often a lock held by another process is tried again.
+	lockRetryInterval = 100 * time.Millisecond
+)
+
+// errLocked is returned by tryLockFile when the lock is held by another process.
+var errLocked = errors.New("locked")
+
+// lockNamePattern restricts lock names to those that are safe as file names.
+var lockNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// LocksConfig is the user-facing configuration of how commands wait for named locks (see Application.RequiresLock).
+type LocksConfig struct {
+	Wait time.Duration `yaml:"wait" json:"wait" mapstructure:"wait"` // how long to wait for a lock held by another process (0 fails immediately)
+}
+
+var _ interface {
+	fangs.FieldDescriber
+	fangs.PostLoader
+} = (*LocksConfig)(nil)
+
+func (c *LocksConfig) DescribeFields(set fangs.FieldDescriptionSet) {
+	set.Add(&c.Wait, "how long to wait for a lock held by another process (e.g. another command using the same database) before failing, where 0 fails immediately")
+}
+
+func (c *LocksConfig) PostLoad() error {
+	if c.Wait < 0 {
+		return fmt.Errorf("invalid locks.wait %s: must not be negative", c.Wait)
+	}
+	return nil
+}
+
+// LockHolder identifies the process holding a lock.
+type LockHolder struct {
+	PID        int       `json:"pid"`
+	Host       string    `json:"host,omitempty"`
+	Command    string    `json:"command,omitempty"`
+	Invocation string    `json:"invocation,omitempty"`
+	Since      time.Time `json:"since"`
+}
+
+func (h *LockHolder) String() string {
+	if h == nil {
+		return "another process"
+	}
+	who := fmt.Sprintf("pid %d", h.PID)
+	if h.Host != "" {
+		who += " on " + h.Host
+	}
+	if h.Command != "" {
+		who = fmt.Sprintf("%q (%s)", h.Command, who)
+	}
+	return fmt.Sprintf("%s since %s", who, h.Since.Local().Format(time.RFC3339))
+}
+
+// LockedError is returned when a lock is held by another process for longer than the command waits (see LocksConfig).
+type LockedError struct {
+	Name   string
+	Holder *LockHolder // nil when the holder is not known
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("the %s lock is held by %s", e.Name, e.Holder)
+}
+
+// RequiresLock declares that the command (and all of its children) uses shared on-disk resources protected by the
+// named locks (e.g. "db"), which are acquired before the command runs and released once it completes, so that such
+// commands run one at a time across processes. A command waits for a lock held by another process as configured
+// (see LocksConfig), failing with a LockedError naming the holder.
+func (a *application) RequiresLock(cmd *cobra.Command, names ...string) {
+	if a.locks == nil {
+		a.locks = make(map[*cobra.Command][]string)
+	}
+	a.locks[cmd] = append(a.locks[cmd], names...)
+}
+
+// acquireLocks acquires the locks of the command (and its parents), returning a function which releases them.
+func (a *application) acquireLocks(ctx context.Context, cmd *cobra.Command) (func(), error) {
+	var names []string
+	seen := map[string]bool{}
+	for c := cmd; c != nil; c = c.Parent() {
+		for _, name := range a.locks[c] {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	// locks are acquired in a consistent order, so that commands requiring several locks do not deadlock
+	sort.Strings(names)
+
+	// the command is recorded as the holder of all locks acquired while it runs
+	a.state.lock.Lock()
+	a.state.lockCommand = cmd.CommandPath()
+	a.state.lock.Unlock()
+
+	var unlocks []func()
+	release := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+	for _, name := range names {
+		unlock, err := a.state.Lock(ctx, name)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		unlocks = append(unlocks, unlock)
+	}
+	return release, nil
+}
+
+// Lock acquires the named lock (shared by all processes of the application), waiting for it as configured (see
+// LocksConfig) or until the context is cancelled, returning a function which releases it.
+func (s *State) Lock(ctx context.Context, name string) (func(), error) {
+	if !lockNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid lock name %q", name)
+	}
+	dir, err := stateDir(s.id.Name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine lock dir: %w", err)
+	}
+	cfg := s.currentConfig()
+	_, dirMode := cfg.Permissions.modes()
+	if err := mkdirAll(filepath.Join(dir, "locks"), dirMode); err != nil {
+		return nil, fmt.Errorf("unable to create lock dir: %w", err)
+	}
+	path := filepath.Join(dir, "locks", name+".lock")
+
+	wait := DefaultLockWait
+	if cfg.Locks != nil {
+		wait = cfg.Locks.Wait
+	}
+	// the wall clock is used, since now() is frozen in deterministic mode
+	deadline := time.Now().Add(wait)
+
+	logged := false
+	for {
+		f, err := tryLockFile(path)
+		if err == nil {
+			s.writeLockHolder(f)
+			return func() { unlockFile(f) }, nil
+		}
+		if err != errLocked {
+			return nil, fmt.Errorf("unable to acquire the %s lock: %w", name, err)
+		}
+
+		holder := readLockHolder(path)
+		if !time.Now().Before(deadline) {
+			return nil, &LockedError{Name: name, Holder: holder}
+		}
+		if log := s.currentLogger(); log != nil && !logged {
+			log.Infof("waiting for the %s lock held by %s", name, holder)
+			logged = true
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// writeLockHolder records this process as the holder of the lock within the lock file. Only the path of the command
+// is recorded (not its arguments, which may hold secrets), since the lock file is readable by other processes.
+func (s *State) writeLockHolder(f *os.File) {
+	s.lock.RLock()
+	command := s.lockCommand
+	s.lock.RUnlock()
+
+	host, _ := os.Hostname()
+	holder := LockHolder{PID: os.Getpid(), Host: host, Command: command, Invocation: s.InvocationID(), Since: now().UTC()}
+	if holder.Command == "" && len(os.Args) > 0 {
+		holder.Command = filepath.Base(os.Args[0])
+	}
+	contents, err := json.Marshal(holder)
+	if err != nil {
+		return
+	}
+	_ = f.Truncate(0)
+	_, _ = f.WriteAt(contents, 0)
+}
+
+// readLockHolder returns the holder recorded within the lock file (nil when not known).
+func readLockHolder(path string) *LockHolder {
+	contents, err := os.ReadFile(path)
+	if err != nil || len(contents) == 0 {
+		return nil
+	}
+	var holder LockHolder
+	if err := json.Unmarshal(contents, &holder); err != nil {
+		return nil
+	}
+	return &holder
+}
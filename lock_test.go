@@ -0,0 +1,99 @@
+package clio
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_State_Lock(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	s := &State{id: Identification{Name: "app"}, Config: Config{Locks: &LocksConfig{}}}
+
+	release, err := s.Lock(context.Background(), "db")
+	require.NoError(t, err)
+
+	// the lock is held, naming the holder
+	_, err = s.Lock(context.Background(), "db")
+	var locked *LockedError
+	require.True(t, errors.As(err, &locked))
+	assert.Equal(t, "db", locked.Name)
+	require.NotNil(t, locked.Holder)
+	assert.Equal(t, os.Getpid(), locked.Holder.PID)
+	assert.Contains(t, err.Error(), "the db lock is held by")
+
+	// other locks are independent
+	releaseOther, err := s.Lock(context.Background(), "index")
+	require.NoError(t, err)
+	releaseOther()
+
+	release()
+	release, err = s.Lock(context.Background(), "db")
+	require.NoError(t, err)
+	release()
+
+	_, err = s.Lock(context.Background(), "../db")
+	require.ErrorContains(t, err, "invalid lock name")
+}
+
+func Test_State_Lock_wait(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	s := &State{id: Identification{Name: "app"}, Config: Config{Locks: &LocksConfig{Wait: 5 * time.Second}}}
+
+	first, err := s.Lock(context.Background(), "db")
+	require.NoError(t, err)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		first()
+	}()
+
+	// the lock is acquired once released
+	release, err := s.Lock(context.Background(), "db")
+	require.NoError(t, err)
+
+	// waiting stops once the context is cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = s.Lock(ctx, "db")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	release()
+}
+
+func Test_LocksConfig_PostLoad(t *testing.T) {
+	assert.NoError(t, (&LocksConfig{}).PostLoad())
+	assert.ErrorContains(t, (&LocksConfig{Wait: -time.Second}).PostLoad(), "must not be negative")
+}
+
+func Test_State_Lock_deterministic(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	setDeterministic(true, func(string) string { return "" })
+	t.Cleanup(func() { setDeterministic(false, func(string) string { return "" }) })
+
+	s := &State{id: Identification{Name: "app"}, Config: Config{Locks: &LocksConfig{Wait: 50 * time.Millisecond}}}
+	s.lockCommand = "app scan"
+	release, err := s.Lock(context.Background(), "db")
+	require.NoError(t, err)
+	defer release()
+
+	// waiting ends although the clock is frozen
+	done := make(chan error)
+	go func() {
+		_, err := s.Lock(context.Background(), "db")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		var locked *LockedError
+		require.True(t, errors.As(err, &locked))
+		require.NotNil(t, locked.Holder)
+		// only the command path is recorded, not the arguments
+		assert.Equal(t, "app scan", locked.Holder.Command)
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting for the lock did not end")
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !windows
+
+package clio
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// tryLockFile opens and exclusively locks the lock file at the given path without waiting, returning errLocked
+// when another process holds the lock. The lock is released by the OS should the process exit without unlocking.
+func tryLockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		_ = f.Close()
+		if errors.Is(err, unix.EWOULDBLOCK) {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// unlockFile clears the holder recorded within the lock file and releases the lock. The file is kept, since
+// removing it could let two processes hold locks on different files of the same name.
+func unlockFile(f *os.File) {
+	_ = f.Truncate(0)
+	_ = unix.Flock(int(f.Fd()), unix.LOCK_UN)
+	_ = f.Close()
+}
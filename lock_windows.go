@@ -0,0 +1,43 @@
+//go:build windows
+
+package clio
+
+import (
+	"errors"
+	"math"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// tryLockFile opens and exclusively locks the lock file at the given path without waiting, returning errLocked
+// when another process holds the lock. Only a byte far past the end of the file is locked, so that other processes
+// can still read the holder recorded within it.
+func tryLockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	ol := lockOverlapped()
+	err = windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &ol)
+	if err != nil {
+		_ = f.Close()
+		if errors.Is(err, windows.ERROR_LOCK_VIOLATION) || errors.Is(err, windows.ERROR_IO_PENDING) {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// unlockFile clears the holder recorded within the lock file and releases the lock.
+func unlockFile(f *os.File) {
+	_ = f.Truncate(0)
+	ol := lockOverlapped()
+	_ = windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &ol)
+	_ = f.Close()
+}
+
+func lockOverlapped() windows.Overlapped {
+	return windows.Overlapped{Offset: math.MaxUint32, OffsetHigh: math.MaxInt32}
+}
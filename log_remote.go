@@ -0,0 +1,393 @@
+package clio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/boss-net/go-logger"
+	"github.com/boss-net/go-logger/adapter/redact"
+)
+
+const (
+	// defaultRemoteLogBatchSize is the number of log records buffered before they are shipped (see RemoteLogConfig)
+	defaultRemoteLogBatchSize = 256
+	// defaultRemoteLogInterval is how long log records may be buffered before they are shipped
+	defaultRemoteLogInterval = 5 * time.Second
+	// remoteLogSpoolLimit bounds the size of the spool of records that could not be shipped, past which further
+	// records are dropped
+	remoteLogSpoolLimit = 16 << 20
+
+	remoteLogTimeout = 10 * time.Second
+)
+
+// RemoteLogFormat is the JSON encoding used to ship log records (see RemoteLogConfig).
+type RemoteLogFormat string
+
+const (
+	// RemoteLogLoki is the Loki push API (e.g. http://loki:3100/loki/api/v1/push).
+	RemoteLogLoki RemoteLogFormat = "loki"
+	// RemoteLogElastic is the Elasticsearch bulk API (e.g. http://elastic:9200/logs/_bulk).
+	RemoteLogElastic RemoteLogFormat = "elastic"
+)
+
+var remoteLogFormats = Enum(RemoteLogLoki, RemoteLogElastic)
+
+// RemoteLogConfig is the user-facing configuration for shipping log records to an HTTP endpoint (under "log.remote"),
+// for fleets of agents whose local logs are hard to collect. Records are shipped in batches, and those that can't be
+// shipped are spooled within the state dir, to be shipped by a later run.
+type RemoteLogConfig struct {
+	URL           string            `yaml:"url" json:"url" mapstructure:"url"`                                  // the endpoint to ship log records to (disabled when empty)
+	Format        RemoteLogFormat   `yaml:"format" json:"format" mapstructure:"format"`                         // the encoding of shipped records (loki, elastic)
+	Level         logger.Level      `yaml:"level" json:"level" mapstructure:"level"`                            // the level of shipped records (default: the log level)
+	Labels        map[string]string `yaml:"labels" json:"labels" mapstructure:"labels"`                         // labels attached to all shipped records (e.g. the host group)
+	BatchSize     int               `yaml:"batch-size" json:"batch-size" mapstructure:"batch-size"`             // the number of records shipped together
+	FlushInterval time.Duration     `yaml:"flush-interval" json:"flush-interval" mapstructure:"flush-interval"` // how long records may be buffered before they are shipped
+}
+
+// validate checks the remote log configuration, applying defaults.
+func (c *RemoteLogConfig) validate() error {
+	if c.URL == "" {
+		return nil
+	}
+	if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid log.remote.url %q", c.URL)
+	}
+	if c.Format == "" {
+		c.Format = RemoteLogLoki
+	}
+	if err := remoteLogFormats.Validate(c.Format); err != nil {
+		return fmt.Errorf("invalid log.remote.format: %w", err)
+	}
+	if c.Level != "" {
+		lvl, err := logger.LevelFromString(string(c.Level))
+		if err != nil {
+			return fmt.Errorf("invalid log.remote.level: %w", err)
+		}
+		c.Level = lvl
+	}
+	if c.BatchSize < 0 {
+		return fmt.Errorf("invalid log.remote.batch-size %d: must not be negative", c.BatchSize)
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = defaultRemoteLogBatchSize
+	}
+	if c.FlushInterval < 0 {
+		return fmt.Errorf("invalid log.remote.flush-interval %s: must not be negative", c.FlushInterval)
+	}
+	if c.FlushInterval == 0 {
+		c.FlushInterval = defaultRemoteLogInterval
+	}
+	return nil
+}
+
+var _ interface {
+	LogExporter
+	telemetryFlusher
+} = (*remoteLogExporter)(nil)
+
+// remoteLogExporter ships log records to the remote log endpoint in batches, spooling records to a file when they
+// can't be shipped, which are shipped again along with the next batch.
+type remoteLogExporter struct {
+	cfg    RemoteLogConfig
+	client *http.Client
+	labels map[string]string
+	spool  string // empty when records can't be spooled
+
+	lock    sync.Mutex
+	records []LogRecord
+	flushed time.Time
+
+	// guards shipping the spool (which is claimed by this process under the same name)
+	spoolLock sync.Mutex
+}
+
+func newRemoteLogExporter(cfg RemoteLogConfig, client *http.Client, labels map[string]string, spool string) *remoteLogExporter {
+	all := map[string]string{}
+	for k, v := range labels {
+		all[k] = v
+	}
+	for k, v := range cfg.Labels {
+		all[k] = v
+	}
+	return &remoteLogExporter{
+		cfg:     cfg,
+		client:  client,
+		labels:  all,
+		spool:   spool,
+		flushed: time.Now(),
+	}
+}
+
+func (e *remoteLogExporter) ExportLog(record LogRecord) {
+	e.lock.Lock()
+	e.records = append(e.records, record)
+	due := len(e.records) >= e.cfg.BatchSize || time.Since(e.flushed) >= e.cfg.FlushInterval
+	e.lock.Unlock()
+
+	if due {
+		// note: there is nowhere to report the error to, since logging it would ship it again
+		_ = e.Flush()
+	}
+}
+
+// Flush ships all buffered records (spooling them when they can't be shipped), then any spooled records.
+func (e *remoteLogExporter) Flush() error {
+	e.lock.Lock()
+	records := e.records
+	e.records = nil
+	e.flushed = time.Now()
+	e.lock.Unlock()
+
+	if len(records) > 0 {
+		if err := e.ship(records); err != nil {
+			e.spoolRecords(records)
+			return err
+		}
+	}
+	return e.shipSpooled()
+}
+
+// ship sends the records in a single request.
+func (e *remoteLogExporter) ship(records []LogRecord) error {
+	var body []byte
+	var contentType string
+	var err error
+	switch e.cfg.Format {
+	case RemoteLogElastic:
+		body, err = e.elasticRequest(records)
+		contentType = "application/x-ndjson"
+	default:
+		body, err = json.Marshal(e.lokiRequest(records))
+		contentType = "application/json"
+	}
+	if err != nil {
+		return fmt.Errorf("unable to encode log records: %w", err)
+	}
+
+	resp, err := e.client.Post(e.cfg.URL, contentType, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to ship log records: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unable to ship log records: %s", resp.Status)
+	}
+	return nil
+}
+
+// spoolRecords appends the records to the spool, dropping them once the spool is full.
+func (e *remoteLogExporter) spoolRecords(records []LogRecord) {
+	if e.spool == "" {
+		return
+	}
+	if fi, err := os.Stat(e.spool); err == nil && fi.Size() >= remoteLogSpoolLimit {
+		return
+	}
+	f, err := os.OpenFile(e.spool, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		_ = enc.Encode(r)
+	}
+	_ = w.Flush()
+}
+
+// shipSpooled ships the records spooled by this and previous runs, which are claimed first so that concurrent runs
+// don't ship them twice.
+func (e *remoteLogExporter) shipSpooled() error {
+	if e.spool == "" {
+		return nil
+	}
+	e.spoolLock.Lock()
+	defer e.spoolLock.Unlock()
+
+	claimed := e.spool + "." + strconv.Itoa(os.Getpid())
+	if err := os.Rename(e.spool, claimed); err != nil {
+		// nothing is spooled (or another run claimed it)
+		return nil
+	}
+	defer os.Remove(claimed)
+
+	f, err := os.Open(claimed)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var batch []LogRecord
+	dec := json.NewDecoder(f)
+	for {
+		var r LogRecord
+		if err := dec.Decode(&r); err != nil {
+			// the end of the spool (a partially written record is dropped)
+			break
+		}
+		batch = append(batch, r)
+		if len(batch) == e.cfg.BatchSize {
+			if err := e.ship(batch); err != nil {
+				e.spoolRemaining(batch, dec)
+				return err
+			}
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		if err := e.ship(batch); err != nil {
+			e.spoolRecords(batch)
+			return err
+		}
+	}
+	return nil
+}
+
+// spoolRemaining spools the batch that could not be shipped, along with the rest of the claimed spool.
+func (e *remoteLogExporter) spoolRemaining(batch []LogRecord, dec *json.Decoder) {
+	for {
+		var r LogRecord
+		if err := dec.Decode(&r); err != nil {
+			break
+		}
+		batch = append(batch, r)
+	}
+	e.spoolRecords(batch)
+}
+
+// the following types are the JSON encoding of the Loki push API (see
+// https://grafana.com/docs/loki/latest/reference/loki-http-api/#ingest-logs)
+
+type lokiPushRequest struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// lokiRequest groups the records into a stream for each service and level, with each line being the JSON of the
+// message and attributes of the record (since attributes may have high cardinality, they are not labels).
+func (e *remoteLogExporter) lokiRequest(records []LogRecord) lokiPushRequest {
+	var req lokiPushRequest
+	index := map[[2]string]int{}
+	for _, r := range records {
+		key := [2]string{r.ServiceName, string(r.Level)}
+		i, ok := index[key]
+		if !ok {
+			i = len(req.Streams)
+			index[key] = i
+			stream := map[string]string{"service_name": r.ServiceName, "level": string(r.Level)}
+			for k, v := range e.labels {
+				stream[k] = v
+			}
+			req.Streams = append(req.Streams, lokiStream{Stream: stream})
+		}
+
+		line := map[string]any{"msg": r.Message}
+		for k, v := range r.Attributes {
+			line[k] = v
+		}
+		contents, err := json.Marshal(line)
+		if err != nil {
+			contents = []byte(r.Message)
+		}
+		req.Streams[i].Values = append(req.Streams[i].Values, [2]string{otlpTime(r.Time), string(contents)})
+	}
+	return req
+}
+
+// elasticRequest encodes the records as index actions of a bulk request, following the Elastic Common Schema field
+// names.
+func (e *remoteLogExporter) elasticRequest(records []LogRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		doc := map[string]any{}
+		for k, v := range r.Attributes {
+			doc[k] = v
+		}
+		for k, v := range e.labels {
+			doc["labels."+k] = v
+		}
+		doc["@timestamp"] = r.Time.UTC().Format(time.RFC3339Nano)
+		doc["log.level"] = string(r.Level)
+		doc["message"] = r.Message
+		doc["service.name"] = r.ServiceName
+
+		if err := enc.Encode(map[string]any{"index": map[string]any{}}); err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(doc); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// remoteLogExporterOnce creates the exporter for the remote log endpoint once (since the logger may be constructed
+// again, see State.SetLogLevel).
+type remoteLogExporterOnce struct {
+	sync.Once
+	exporter *remoteLogExporter
+}
+
+// remoteLogExporter returns the exporter for the remote log endpoint, or nil when not configured. Records are only
+// spooled when the state dir is writable.
+func (s *State) remoteLogExporter(config Config) *remoteLogExporter {
+	cfg := config.Log
+	if cfg == nil || cfg.Remote.URL == "" {
+		return nil
+	}
+	s.remoteLogs.Do(func() {
+		client := s.HTTPClient()
+		client.Timeout = remoteLogTimeout
+
+		var spool string
+		if dir, err := stateDir(s.id.Name); err == nil {
+			_, dirMode := config.Permissions.modes()
+			if probeWritable(dir, dirMode) {
+				spool = filepath.Join(dir, "remote-logs.jsonl")
+			}
+		}
+
+		labels := map[string]string{"app": s.id.Name}
+		if host, err := os.Hostname(); err == nil {
+			labels["host"] = host
+		}
+		s.remoteLogs.exporter = newRemoteLogExporter(cfg.Remote, client, labels, spool)
+	})
+	return s.remoteLogs.exporter
+}
+
+// withRemoteLogs tees all log entries to the remote log endpoint (when configured). Note: the logger is redacted
+// again since the exporter receives entries before the constructed logger redacts them.
+func (s *State) withRemoteLogs(cfg SetupConfig, config Config, lgr logger.Logger) logger.Logger {
+	exporter := s.remoteLogExporter(config)
+	if exporter == nil || lgr == nil {
+		return lgr
+	}
+
+	level := config.Log.Remote.Level
+	if level == "" {
+		level = config.Log.Level
+	}
+
+	lgr = newTelemetryLogger(lgr, exporter, level, config.Telemetry.serviceName(cfg.ID)).with(s.invocation.fields())
+	if s.RedactStore != nil {
+		lgr = redact.New(lgr, s.RedactStore)
+	}
+	return lgr
+}
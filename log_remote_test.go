@@ -0,0 +1,113 @@
+package clio
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/go-logger"
+)
+
+type remoteLogServer struct {
+	*httptest.Server
+	lock   sync.Mutex
+	fail   bool
+	bodies []string
+}
+
+func newRemoteLogServer(t *testing.T) *remoteLogServer {
+	s := &remoteLogServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if s.fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		s.bodies = append(s.bodies, string(body))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *remoteLogServer) setFail(fail bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.fail = fail
+}
+
+func Test_remoteLogExporter_loki(t *testing.T) {
+	server := newRemoteLogServer(t)
+	spool := filepath.Join(t.TempDir(), "remote-logs.jsonl")
+	cfg := RemoteLogConfig{URL: server.URL, Labels: map[string]string{"env": "prod"}}
+	require.NoError(t, cfg.validate())
+
+	e := newRemoteLogExporter(cfg, server.Client(), map[string]string{"app": "app"}, spool)
+	at := time.Unix(0, 1000)
+
+	// records that can't be shipped are spooled
+	server.setFail(true)
+	e.ExportLog(LogRecord{Time: at, Level: logger.WarnLevel, Message: "first", ServiceName: "app"})
+	require.Error(t, e.Flush())
+	assert.FileExists(t, spool)
+
+	// and shipped along with the next batch
+	server.setFail(false)
+	e.ExportLog(LogRecord{Time: at, Level: logger.InfoLevel, Message: "second", ServiceName: "app", Attributes: map[string]any{"invocation": "abc"}})
+	require.NoError(t, e.Flush())
+	assert.NoFileExists(t, spool)
+
+	require.Len(t, server.bodies, 2)
+	var req lokiPushRequest
+	require.NoError(t, json.Unmarshal([]byte(server.bodies[0]), &req))
+	assert.Equal(t, lokiPushRequest{Streams: []lokiStream{{
+		Stream: map[string]string{"app": "app", "env": "prod", "service_name": "app", "level": "info"},
+		Values: [][2]string{{"1000", `{"invocation":"abc","msg":"second"}`}},
+	}}}, req)
+	assert.Contains(t, server.bodies[1], `"msg\":\"first\"`)
+}
+
+func Test_remoteLogExporter_elastic(t *testing.T) {
+	server := newRemoteLogServer(t)
+	cfg := RemoteLogConfig{URL: server.URL, Format: RemoteLogElastic, BatchSize: 2}
+	require.NoError(t, cfg.validate())
+
+	e := newRemoteLogExporter(cfg, server.Client(), map[string]string{"host": "agent-1"}, "")
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	e.ExportLog(LogRecord{Time: at, Level: logger.ErrorLevel, Message: "failed", ServiceName: "app"})
+	assert.Empty(t, server.bodies)
+	// the batch is shipped once full
+	e.ExportLog(LogRecord{Time: at, Level: logger.InfoLevel, Message: "done", ServiceName: "app"})
+
+	require.Len(t, server.bodies, 1)
+	lines := strings.Split(strings.TrimSpace(server.bodies[0]), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, `{"index":{}}`, lines[0])
+	assert.JSONEq(t, `{"@timestamp":"2024-01-02T03:04:05Z","labels.host":"agent-1","log.level":"error","message":"failed","service.name":"app"}`, lines[1])
+}
+
+func Test_RemoteLogConfig_validate(t *testing.T) {
+	cfg := RemoteLogConfig{}
+	require.NoError(t, cfg.validate())
+	assert.Equal(t, RemoteLogConfig{}, cfg)
+
+	cfg = RemoteLogConfig{URL: "http://localhost:3100/loki/api/v1/push", Level: "debug"}
+	require.NoError(t, cfg.validate())
+	assert.Equal(t, RemoteLogLoki, cfg.Format)
+	assert.Equal(t, defaultRemoteLogBatchSize, cfg.BatchSize)
+	assert.Equal(t, defaultRemoteLogInterval, cfg.FlushInterval)
+
+	assert.ErrorContains(t, (&RemoteLogConfig{URL: "localhost"}).validate(), "invalid log.remote.url")
+	assert.ErrorContains(t, (&RemoteLogConfig{URL: "http://localhost", Format: "splunk"}).validate(), "invalid log.remote.format")
+	assert.ErrorContains(t, (&RemoteLogConfig{URL: "http://localhost", BatchSize: -1}).validate(), "must not be negative")
+}
@@ -4,8 +4,8 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
-
-	"golang.org/x/term"
+	"path/filepath"
+	"strings"
 
 	"github.com/boss-net/fangs"
 	"github.com/boss-net/go-logger"
@@ -22,11 +22,11 @@ type terminalDetector interface {
 type stockTerminalDetector struct{}
 
 func (s stockTerminalDetector) StdoutIsTerminal() bool {
-	return term.IsTerminal(int(os.Stdout.Fd()))
+	return isTerminal(os.Stdout)
 }
 
 func (s stockTerminalDetector) StderrIsTerminal() bool {
-	return term.IsTerminal(int(os.Stderr.Fd()))
+	return isTerminal(os.Stderr)
 }
 
 type LoggerConstructor func(Config, redact.Store) (logger.Logger, error)
@@ -37,17 +37,59 @@ func DefaultLogger(clioCfg Config, store redact.Store) (logger.Logger, error) {
 		return discard.New(), nil
 	}
 
-	l, err := logrus.New(
-		logrus.Config{
-			EnableConsole: cfg.Verbosity > 0 && !cfg.Quiet,
-			FileLocation:  cfg.FileLocation,
-			Level:         cfg.Level,
-		},
-	)
+	if cfg.FileLocation != "" {
+		// create the log file upfront so that it follows the permissions policy
+		if err := createLogFile(cfg.FileLocation, clioCfg.Permissions); err != nil {
+			return nil, err
+		}
+	}
+
+	if clioCfg.Dev.deterministic() {
+		// log records are part of the output to reproduce: there are no timestamps or colors, and fields are sorted
+		c := *cfg
+		c.Timestamps = LogTimestampNone
+		c.FieldOrder = ""
+		cfg = &c
+	}
+
+	lCfg := logrus.Config{
+		EnableConsole: cfg.Verbosity > 0 && !cfg.Quiet,
+		FileLocation:  cfg.FileLocation,
+		Level:         cfg.Level,
+	}
+
+	format := cfg.format()
+	switch format {
+	case LogFormatPlain, LogFormatJenkins, LogFormatGitHubActions:
+		// CI logs are not shown on a terminal, so use plain output with full timestamps
+		lCfg.Formatter = cfg.textFormatter(LogTimestampDateTime, false)
+	case LogFormatTeamCity:
+		lCfg.Formatter = teamCityFormatter{}
+	default:
+		lCfg.Formatter = cfg.textFormatter(LogTimestampRelative, !clioCfg.Dev.deterministic())
+	}
+
+	if cfg.Multiline != "" && cfg.Multiline != LogMultilineKeep {
+		lCfg.Formatter = withMultiline(lCfg.Formatter, cfg.Multiline)
+	}
+	if cfg.Caller {
+		lCfg.Formatter = withCallerInfo(lCfg.Formatter)
+	}
+	if clioCfg.InvocationID != "" && (cfg.FileLocation != "" || format != LogFormatText) {
+		// correlate records written to files and CI logs (this is noise for a user watching the terminal)
+		lCfg.Formatter = withInvocation(lCfg.Formatter, clioCfg.invocation())
+	}
+
+	l, err := logrus.New(lCfg)
 	if err != nil {
 		return nil, err
 	}
 
+	if format == LogFormatGitHubActions && !cfg.Quiet {
+		// surface warnings and errors as annotations on the workflow run
+		l = NewGitHubActionsLogger(l, os.Stderr)
+	}
+
 	if store != nil {
 		l = redact.New(l, store)
 	}
@@ -57,12 +99,50 @@ func DefaultLogger(clioCfg Config, store redact.Store) (logger.Logger, error) {
 
 var _ LoggerConstructor = DefaultLogger
 
+func createLogFile(path string, perms *PermissionsConfig) error {
+	fileMode, dirMode := perms.modes()
+	if err := mkdirAll(filepath.Dir(path), dirMode); err != nil {
+		return fmt.Errorf("unable to create log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, fileMode)
+	if err != nil {
+		return fmt.Errorf("unable to create log file: %w", err)
+	}
+	defer f.Close()
+	return f.Chmod(fileMode)
+}
+
+const (
+	LogFormatAuto          = "auto"
+	LogFormatText          = "text"
+	LogFormatPlain         = "plain"
+	LogFormatGitHubActions = "github-actions"
+	LogFormatTeamCity      = "teamcity"
+	LogFormatJenkins       = "jenkins"
+)
+
+// LogFormats are all available values for the log format option.
+func LogFormats() []string {
+	return []string{LogFormatAuto, LogFormatText, LogFormatPlain, LogFormatGitHubActions, LogFormatTeamCity, LogFormatJenkins}
+}
+
 // LoggingConfig contains all logging-related configuration options available to the user via the application config.
 type LoggingConfig struct {
-	Quiet        bool         `yaml:"quiet" json:"quiet" mapstructure:"quiet"` // -q, indicates to not show any status output to stderr
-	Verbosity    int          `yaml:"-" json:"-" mapstructure:"verbosity"`     // -v or -vv , controlling which UI (ETUI vs logging) and what the log level should be
-	Level        logger.Level `yaml:"level" json:"level" mapstructure:"level"` // the log level string hint
-	FileLocation string       `yaml:"file" json:"file" mapstructure:"file"`    // the file path to write logs to
+	Quiet        bool         `yaml:"quiet" json:"quiet" mapstructure:"quiet"`    // -q, indicates to not show any status output to stderr
+	Verbosity    int          `yaml:"-" json:"-" mapstructure:"verbosity"`        // -v or -vv , controlling which UI (ETUI vs logging) and what the log level should be
+	Level        logger.Level `yaml:"level" json:"level" mapstructure:"level"`    // the log level string hint
+	FileLocation string       `yaml:"file" json:"file" mapstructure:"file"`       // the file path to write logs to
+	Format       string       `yaml:"format" json:"format" mapstructure:"format"` // the format of log entries (default: selected based on the CI environment)
+	Debug        bool         `yaml:"-" json:"-" mapstructure:"debug"`            // --debug, debug logging with timestamps and caller info, and no rich UI
+
+	// formatting options for the text-based log formats
+	Timestamps string `yaml:"timestamps" json:"timestamps" mapstructure:"timestamps"`    // how entries are timestamped (default: relative on a terminal, datetime in CI)
+	Caller     bool   `yaml:"caller" json:"caller" mapstructure:"caller"`                // include the source location of each log call
+	FieldOrder string `yaml:"field-order" json:"field-order" mapstructure:"field-order"` // the order of fields within each entry
+	Multiline  string `yaml:"multiline" json:"multiline" mapstructure:"multiline"`       // how messages spanning multiple lines are shown
+
+	// shipping log records to an HTTP endpoint (see RemoteLogConfig)
+	Remote RemoteLogConfig `yaml:"remote" json:"remote" mapstructure:"remote"`
 
 	terminalDetector terminalDetector // for testing
 
@@ -74,9 +154,34 @@ var _ interface {
 	fangs.PostLoader
 	fangs.FlagAdder
 	fangs.FieldDescriber
+	EnumFieldsDescriber
 } = (*LoggingConfig)(nil)
 
 func (l *LoggingConfig) PostLoad() error {
+	if l.Format != "" && !contains(LogFormats(), l.Format) {
+		return fmt.Errorf("invalid log format %q (available: %s)", l.Format, strings.Join(LogFormats(), ", "))
+	}
+
+	if err := l.validateFormatting(); err != nil {
+		return err
+	}
+
+	if err := l.Remote.validate(); err != nil {
+		return err
+	}
+
+	if l.Debug {
+		// debugging output is explicitly asked for, so it trumps quiet
+		l.Quiet = false
+		if l.Verbosity < 2 {
+			l.Verbosity = 2
+		}
+		l.Caller = true
+		if l.Timestamps == "" {
+			l.Timestamps = LogTimestampDateTime
+		}
+	}
+
 	lvl, err := l.selectLevel()
 	if err != nil {
 		return fmt.Errorf("unable to select logging level: %w", err)
@@ -90,6 +195,21 @@ func (l *LoggingConfig) PostLoad() error {
 func (l *LoggingConfig) DescribeFields(d fangs.FieldDescriptionSet) {
 	d.Add(&l.Level, fmt.Sprintf("explicitly set the logging level (available: %s)", logger.Levels()))
 	d.Add(&l.FileLocation, "file path to write logs to")
+	d.Add(&l.Format, fmt.Sprintf("format of log entries, selected based on the CI environment by default (available: %s)", strings.Join(LogFormats(), ", ")))
+	d.Add(&l.Timestamps, fmt.Sprintf("how log entries are timestamped, relative to startup on a terminal and datetime in CI by default (available: %s)", strings.Join(LogTimestamps(), ", ")))
+	d.Add(&l.Caller, "include the source location of each log call")
+	d.Add(&l.FieldOrder, fmt.Sprintf("the order of fields within each log entry (available: %s)", strings.Join(LogFieldOrders(), ", ")))
+	d.Add(&l.Multiline, fmt.Sprintf("how log messages spanning multiple lines are shown (available: %s)", strings.Join(LogMultilineModes(), ", ")))
+	d.Add(&l.Remote.URL, "the HTTP endpoint to ship log records to, for collecting the logs of many hosts (e.g. http://loki:3100/loki/api/v1/push)")
+	d.Add(&l.Remote.Format, "the encoding of shipped log records, as the Loki push API or the Elasticsearch bulk API")
+	d.Add(&l.Remote.Level, fmt.Sprintf("the level of shipped log records, the log level by default (available: %s)", logger.Levels()))
+	d.Add(&l.Remote.Labels, "labels attached to all shipped log records (e.g. the environment)")
+	d.Add(&l.Remote.BatchSize, "the number of log records shipped together")
+	d.Add(&l.Remote.FlushInterval, "how long log records may be buffered before they are shipped (e.g. 5s)")
+}
+
+func (l *LoggingConfig) DescribeEnumFields(set EnumFieldSet) {
+	remoteLogFormats.Describe(set, &l.Remote.Format)
 }
 
 func (l *LoggingConfig) selectLevel() (logger.Level, error) {
@@ -124,6 +244,29 @@ func (l *LoggingConfig) selectLevel() (logger.Level, error) {
 	return l.Level, nil
 }
 
+// format returns the configured log format, selecting one based on the CI environment when not configured.
+func (l *LoggingConfig) format() string {
+	if l.Format != "" && l.Format != LogFormatAuto {
+		return l.Format
+	}
+	switch ci := ciProvider(); ci {
+	case "":
+		return LogFormatText
+	case LogFormatGitHubActions, LogFormatTeamCity, LogFormatJenkins:
+		return ci
+	default:
+		return LogFormatPlain
+	}
+}
+
+// showsDebug indicates if debug log entries are shown on the console.
+func (l *LoggingConfig) showsDebug() bool {
+	if l == nil || l.Quiet || l.Verbosity == 0 {
+		return false
+	}
+	return l.Level == logger.DebugLevel || l.Level == logger.TraceLevel
+}
+
 func (l *LoggingConfig) AllowUI(stdin fs.File) bool {
 	pipedInput, err := isPipedInput(stdin)
 	if err != nil || pipedInput {
@@ -135,6 +278,11 @@ func (l *LoggingConfig) AllowUI(stdin fs.File) bool {
 		return true
 	}
 
+	if ciProvider() != "" {
+		// CI systems are not interactive
+		return false
+	}
+
 	if l.terminalDetector == nil {
 		l.terminalDetector = stockTerminalDetector{}
 	}
@@ -171,4 +319,5 @@ func isPipedInput(stdin fs.File) (bool, error) {
 func (l *LoggingConfig) AddFlags(flags fangs.FlagSet) {
 	flags.CountVarP(&l.Verbosity, "verbose", "v", "increase verbosity (-v = info, -vv = debug)")
 	flags.BoolVarP(&l.Quiet, "quiet", "q", "suppress all logging output")
+	flags.BoolVarP(&l.Debug, "debug", "", "show debug logging with timestamps and caller info, and the loaded configuration (disables the rich UI)")
 }
@@ -0,0 +1,116 @@
+package clio
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	sirupsen "github.com/sirupsen/logrus"
+
+	"github.com/boss-net/go-logger/adapter/logrus"
+)
+
+const (
+	LogTimestampRelative = "relative" // seconds since startup (e.g. "[0012]")
+	LogTimestampDateTime = "datetime" // local date and time (e.g. "2006-01-02 15:04:05")
+	LogTimestampRFC3339  = "rfc3339"  // RFC 3339, with the timezone
+	LogTimestampNone     = "none"
+)
+
+// LogTimestamps are all available values for the log timestamps option.
+func LogTimestamps() []string {
+	return []string{LogTimestampRelative, LogTimestampDateTime, LogTimestampRFC3339, LogTimestampNone}
+}
+
+const (
+	LogFieldOrderSorted   = "sorted"   // fields are sorted by key (the default)
+	LogFieldOrderUnsorted = "unsorted" // fields are shown in no particular order, which avoids sorting each entry
+)
+
+// LogFieldOrders are all available values for the log field order option.
+func LogFieldOrders() []string {
+	return []string{LogFieldOrderSorted, LogFieldOrderUnsorted}
+}
+
+const (
+	LogMultilineKeep   = "keep"   // messages are shown as-is (the default)
+	LogMultilineIndent = "indent" // continuation lines are indented beneath the entry
+	LogMultilineEscape = "escape" // newlines are escaped so that each entry is a single line
+)
+
+// LogMultilineModes are all available values for the log multiline option.
+func LogMultilineModes() []string {
+	return []string{LogMultilineKeep, LogMultilineIndent, LogMultilineEscape}
+}
+
+func (l *LoggingConfig) validateFormatting() error {
+	options := []struct {
+		name      string
+		value     string
+		available []string
+	}{
+		{name: "log timestamps", value: l.Timestamps, available: LogTimestamps()},
+		{name: "log field order", value: l.FieldOrder, available: LogFieldOrders()},
+		{name: "log multiline", value: l.Multiline, available: LogMultilineModes()},
+	}
+	for _, o := range options {
+		if o.value != "" && !contains(o.available, o.value) {
+			return fmt.Errorf("invalid %s %q (available: %s)", o.name, o.value, strings.Join(o.available, ", "))
+		}
+	}
+	return nil
+}
+
+// textFormatter returns the formatter for the text-based log formats, applying the configured formatting options.
+func (l *LoggingConfig) textFormatter(defaultTimestamps string, colors bool) sirupsen.Formatter {
+	f := &logrus.TextFormatter{
+		TimestampFormat: "2006-01-02 15:04:05",
+		ForceColors:     colors,
+		DisableColors:   !colors,
+		ForceFormatting: true,
+		DisableSorting:  l.FieldOrder == LogFieldOrderUnsorted,
+	}
+
+	timestamps := l.Timestamps
+	if timestamps == "" {
+		timestamps = defaultTimestamps
+	}
+	switch timestamps {
+	case LogTimestampDateTime:
+		f.FullTimestamp = true
+	case LogTimestampRFC3339:
+		f.FullTimestamp = true
+		f.TimestampFormat = time.RFC3339
+	case LogTimestampNone:
+		f.DisableTimestamp = true
+	}
+	return f
+}
+
+var _ sirupsen.Formatter = (*multilineFormatter)(nil)
+
+// multilineFormatter rewrites messages spanning multiple lines so that log entries remain easy to tell apart.
+type multilineFormatter struct {
+	next sirupsen.Formatter
+	mode string
+}
+
+func withMultiline(f sirupsen.Formatter, mode string) sirupsen.Formatter {
+	return multilineFormatter{next: f, mode: mode}
+}
+
+func (f multilineFormatter) Format(entry *sirupsen.Entry) ([]byte, error) {
+	message := strings.TrimRight(entry.Message, "\n")
+	if !strings.Contains(message, "\n") {
+		return f.next.Format(entry)
+	}
+
+	rewritten := *entry
+	switch f.mode {
+	case LogMultilineIndent:
+		rewritten.Message = strings.ReplaceAll(message, "\n", "\n    ")
+	case LogMultilineEscape:
+		rewritten.Message = strings.ReplaceAll(message, "\n", `\n`)
+	}
+	return f.next.Format(&rewritten)
+}
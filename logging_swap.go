@@ -0,0 +1,139 @@
+package clio
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/boss-net/go-logger"
+)
+
+var _ logger.Logger = (*swappableLogger)(nil)
+
+// swappableLogger delegates to a logger which can be replaced while it is in use (see State.SetLogger and
+// State.SetLogLevel). Nested loggers (e.g. those held by components) follow the replacement: each is derived from the
+// current logger again (with the same fields) the first time it is used after the logger is replaced.
+type swappableLogger struct {
+	root *swappableRoot
+	// derives this logger from the root logger (nil for the root itself)
+	derive func(logger.Logger) logger.Logger
+
+	lock    sync.Mutex
+	version uint64
+	derived logger.Logger
+}
+
+// swappableRoot is the logger shared by a swappable logger and all loggers nested within it.
+type swappableRoot struct {
+	lock    sync.RWMutex
+	log     logger.Logger
+	level   logger.Level
+	version uint64 // incremented with each swap
+}
+
+func newSwappableLogger(log logger.Logger, cfg *LoggingConfig) *swappableLogger {
+	root := &swappableRoot{log: log}
+	if cfg != nil {
+		root.level = cfg.Level
+	}
+	return &swappableLogger{root: root}
+}
+
+func (s *swappableLogger) swap(log logger.Logger, level logger.Level) {
+	s.root.lock.Lock()
+	defer s.root.lock.Unlock()
+	s.root.log = log
+	s.root.level = level
+	s.root.version++
+}
+
+func (s *swappableLogger) current() logger.Logger {
+	s.root.lock.RLock()
+	log, version := s.root.log, s.root.version
+	s.root.lock.RUnlock()
+
+	if s.derive == nil {
+		return log
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.derived == nil || s.version != version {
+		s.derived = s.derive(log)
+		s.version = version
+	}
+	return s.derived
+}
+
+func (s *swappableLogger) currentLevel() logger.Level {
+	s.root.lock.RLock()
+	defer s.root.lock.RUnlock()
+	return s.root.level
+}
+
+func (s *swappableLogger) Errorf(format string, args ...interface{}) {
+	s.current().Errorf(format, args...)
+}
+func (s *swappableLogger) Error(args ...interface{}) { s.current().Error(args...) }
+func (s *swappableLogger) Warnf(format string, args ...interface{}) {
+	s.current().Warnf(format, args...)
+}
+func (s *swappableLogger) Warn(args ...interface{}) { s.current().Warn(args...) }
+func (s *swappableLogger) Infof(format string, args ...interface{}) {
+	s.current().Infof(format, args...)
+}
+func (s *swappableLogger) Info(args ...interface{}) { s.current().Info(args...) }
+func (s *swappableLogger) Debugf(format string, args ...interface{}) {
+	s.current().Debugf(format, args...)
+}
+func (s *swappableLogger) Debug(args ...interface{}) { s.current().Debug(args...) }
+func (s *swappableLogger) Tracef(format string, args ...interface{}) {
+	s.current().Tracef(format, args...)
+}
+func (s *swappableLogger) Trace(args ...interface{}) { s.current().Trace(args...) }
+
+// WithFields returns a logger for a single message, which uses the current logger (message loggers are not meant to
+// be kept, use Nested instead).
+func (s *swappableLogger) WithFields(fields ...interface{}) logger.MessageLogger {
+	return s.current().WithFields(fields...)
+}
+
+func (s *swappableLogger) Nested(fields ...interface{}) logger.Logger {
+	parent := s.derive
+	return &swappableLogger{
+		root: s.root,
+		derive: func(log logger.Logger) logger.Logger {
+			if parent != nil {
+				log = parent(log)
+			}
+			return log.Nested(fields...)
+		},
+	}
+}
+
+// errLoggerNotReplaceable is returned when changing the log level of a logger which can't be replaced.
+var errLoggerNotReplaceable = errors.New("the logger can't be replaced while in use (see SetupConfig.WithReplaceableLogger)")
+
+// SetLogLevel replaces the logger of the application with one for the same configuration at the given level, which
+// is safe to do while the logger is in use (e.g. from a signal handler or a UI keybinding). Loggers nested from the
+// logger of the application follow the change. This requires SetupConfig.WithReplaceableLogger (or the control
+// server, see SetupConfig.WithControlServer).
+func (s *State) SetLogLevel(level logger.Level) error {
+	swappable, ok := s.currentLogger().(*swappableLogger)
+	config := s.currentConfig()
+	if !ok || s.loggerFactory == nil || config.Log == nil {
+		return errLoggerNotReplaceable
+	}
+
+	// note: the configuration in use is not modified, since it may be read concurrently
+	log := *config.Log
+	log.Level = level
+	config.Log = &log
+
+	lgr, err := s.loggerFactory(config)
+	if err != nil {
+		return fmt.Errorf("unable to change log level: %w", err)
+	}
+	swappable.swap(lgr, level)
+	return nil
+}
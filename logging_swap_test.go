@@ -0,0 +1,109 @@
+package clio
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/go-logger"
+	"github.com/boss-net/go-logger/adapter/discard"
+	"github.com/boss-net/go-logger/adapter/redact"
+)
+
+// taggedLogger records info messages with the tag of the logger and the fields of nested loggers.
+type taggedLogger struct {
+	logger.Logger
+	tag    string
+	fields []interface{}
+	lock   *sync.Mutex
+	out    *[]string
+}
+
+func newTaggedLogger(tag string, out *[]string) *taggedLogger {
+	return &taggedLogger{Logger: discard.New(), tag: tag, lock: &sync.Mutex{}, out: out}
+}
+
+func (l *taggedLogger) Info(args ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	*l.out = append(*l.out, fmt.Sprintf("%s %v %s", l.tag, l.fields, fmt.Sprint(args...)))
+}
+
+func (l *taggedLogger) Nested(fields ...interface{}) logger.Logger {
+	n := *l
+	n.fields = append(append([]interface{}{}, l.fields...), fields...)
+	return &n
+}
+
+func Test_swappableLogger_nested(t *testing.T) {
+	var out []string
+	s := newSwappableLogger(newTaggedLogger("first", &out), &LoggingConfig{Level: logger.InfoLevel})
+
+	component := s.Nested("component", "worker").Nested("id", 1)
+	component.Info("before")
+
+	s.swap(newTaggedLogger("second", &out), logger.DebugLevel)
+	component.Info("after")
+	s.Info("root")
+
+	assert.Equal(t, []string{
+		"first [component worker id 1] before",
+		"second [component worker id 1] after",
+		"second [] root",
+	}, out)
+	assert.Equal(t, logger.DebugLevel, s.currentLevel())
+}
+
+func Test_State_SetLogger_replaceable(t *testing.T) {
+	var out []string
+	s := &State{Logger: newSwappableLogger(newTaggedLogger("first", &out), nil)}
+	component := s.Logger.Nested("component", "ui")
+
+	s.SetLogger(newTaggedLogger("second", &out))
+	component.Info("message")
+
+	assert.Equal(t, []string{"second [component ui] message"}, out)
+	_, ok := s.Logger.(*swappableLogger)
+	assert.True(t, ok, "the logger remains replaceable")
+}
+
+func Test_State_SetLogLevel(t *testing.T) {
+	var out []string
+	var levels []logger.Level
+	cfg := NewSetupConfig(Identification{Name: "app"}).
+		WithNoBus().
+		WithReplaceableLogger().
+		WithLoggingConfig(LoggingConfig{Level: logger.InfoLevel}).
+		WithLoggerConstructor(func(cfg Config, _ redact.Store) (logger.Logger, error) {
+			levels = append(levels, cfg.Log.Level)
+			return newTaggedLogger(string(cfg.Log.Level), &out), nil
+		})
+
+	app := New(*cfg)
+	var state *State
+	root := app.SetupRootCommand(&cobra.Command{
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state = app.(*application).State()
+			component := state.Logger.Nested("component", "scan")
+
+			require.NoError(t, state.SetLogLevel(logger.DebugLevel))
+			component.Info("changed")
+			return nil
+		},
+	})
+	root.SetArgs([]string{})
+	require.NoError(t, root.Execute())
+
+	assert.Equal(t, []logger.Level{logger.InfoLevel, logger.DebugLevel}, levels)
+	assert.Contains(t, out, "debug [component scan] changed")
+	assert.Equal(t, logger.InfoLevel, state.Config.Log.Level, "the configuration is not changed")
+}
+
+func Test_State_SetLogLevel_notReplaceable(t *testing.T) {
+	s := &State{Logger: discard.New(), Config: Config{Log: &LoggingConfig{}}}
+	require.ErrorIs(t, s.SetLogLevel(logger.DebugLevel), errLoggerNotReplaceable)
+}
@@ -80,6 +80,30 @@ func Test_newLogger(t *testing.T) {
 				assert.Equal(t, "[0000]  INFO test *******\n", stripAnsi(buf.String()))
 			},
 		},
+		{
+			name: "formatting options",
+			cfg:  &LoggingConfig{Level: "debug", Timestamps: LogTimestampNone, Multiline: LogMultilineIndent, Caller: true},
+			assertLogger: func(log logger.Logger) {
+				c := log.(logger.Controller)
+				buf := &bytes.Buffer{}
+				c.SetOutput(buf)
+				log.WithFields("b", 2, "a", 1).Info("first\nsecond")
+
+				assert.Regexp(t, `^ INFO first\n    second\s+a=1 b=2 caller=logging_test.go:\d+\n$`, stripAnsi(buf.String()))
+			},
+		},
+		{
+			name: "escaped multiline messages",
+			cfg:  &LoggingConfig{Level: "debug", Timestamps: LogTimestampNone, Multiline: LogMultilineEscape},
+			assertLogger: func(log logger.Logger) {
+				c := log.(logger.Controller)
+				buf := &bytes.Buffer{}
+				c.SetOutput(buf)
+				log.Info("first\nsecond\n")
+
+				assert.Equal(t, " INFO first\\nsecond\n", stripAnsi(buf.String()))
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -106,6 +130,7 @@ func TestLoggingConfig_AddFlags(t *testing.T) {
 			flags: map[string]string{
 				"quiet":   "q",
 				"verbose": "v",
+				"debug":   "",
 			},
 		},
 	}
@@ -174,12 +199,35 @@ func (m mockTerminalDetector) StderrIsTerminal() bool {
 	return m.stderr
 }
 
+func TestLoggingConfig_PostLoad_formatting(t *testing.T) {
+	require.NoError(t, (&LoggingConfig{Timestamps: LogTimestampRFC3339, FieldOrder: LogFieldOrderUnsorted, Multiline: LogMultilineEscape}).PostLoad())
+	require.EqualError(t, (&LoggingConfig{Timestamps: "unix"}).PostLoad(), `invalid log timestamps "unix" (available: relative, datetime, rfc3339, none)`)
+	require.EqualError(t, (&LoggingConfig{Multiline: "wrap"}).PostLoad(), `invalid log multiline "wrap" (available: keep, indent, escape)`)
+}
+
+func TestLoggingConfig_PostLoad_debug(t *testing.T) {
+	cfg := &LoggingConfig{Quiet: true, Level: logger.ErrorLevel, Debug: true}
+	require.NoError(t, cfg.PostLoad())
+
+	assert.False(t, cfg.Quiet)
+	assert.Equal(t, logger.DebugLevel, cfg.Level)
+	assert.True(t, cfg.showsDebug())
+	assert.False(t, cfg.AllowUI(nil))
+	assert.True(t, cfg.Caller)
+	assert.Equal(t, LogTimestampDateTime, cfg.Timestamps)
+
+	cfg = &LoggingConfig{Verbosity: 3, Debug: true}
+	require.NoError(t, cfg.PostLoad())
+	assert.Equal(t, logger.TraceLevel, cfg.Level)
+}
+
 func TestLoggingConfig_AllowUI(t *testing.T) {
 
 	tests := []struct {
 		name  string
 		cfg   *LoggingConfig
 		stdin fs.File
+		ci    string
 		want  bool
 	}{
 		{
@@ -247,9 +295,26 @@ func TestLoggingConfig_AllowUI(t *testing.T) {
 			stdin: &fakeFile{info: fakeInfo{mode: 0}},
 			want:  true,
 		},
+		{
+			name: "non-verbose config, running in CI = not allowed",
+			cfg: &LoggingConfig{
+				Verbosity: 0,
+				terminalDetector: mockTerminalDetector{
+					stdout: true,
+					stderr: true,
+				},
+			},
+			stdin: &fakeFile{info: fakeInfo{mode: 0}},
+			ci:    "github-actions",
+			want:  false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			original := ciProvider
+			ciProvider = func() string { return tt.ci }
+			defer func() { ciProvider = original }()
+
 			assert.Equal(t, tt.want, tt.cfg.AllowUI(tt.stdin))
 		})
 	}
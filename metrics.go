@@ -0,0 +1,337 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const pushgatewayTimeout = 10 * time.Second
+
+// Metrics records application metrics from commands: counters, gauges, and timers, each with optional attributes
+// given as key-value pairs (e.g. metrics.Count("files.scanned", 1, "language", "go")). Metrics are exported to the
+// backends configured in the "telemetry" section (see SetupConfig.WithTelemetry): the telemetry exporters (e.g. OTel)
+// with the runtime metrics, a statsd server as they are recorded, and a Prometheus pushgateway once the run is
+// complete. Without a backend all methods are no-ops, so commands can be instrumented unconditionally.
+type Metrics struct {
+	aggregate bool     // keep totals for the telemetry exporters and the pushgateway
+	statsd    net.Conn // send each measurement to statsd
+	push      string   // the pushgateway URL
+	job       string   // the pushgateway job (the service name)
+	state     *State
+
+	lock   sync.Mutex
+	series map[string]*metricSeries
+}
+
+type metricKind int
+
+const (
+	metricCounter metricKind = iota
+	metricGauge
+	metricTimer
+)
+
+// metricSeries is the aggregate of the measurements of a metric with the same attributes.
+type metricSeries struct {
+	kind  metricKind
+	name  string
+	attrs map[string]string
+	value float64 // the counter total, the gauge value, or the total of the timer (in seconds)
+	count int64   // the number of timer measurements
+}
+
+// Metrics returns the facade for recording application metrics (see Metrics), which is always safe to use.
+func (s *State) Metrics() *Metrics {
+	return s.metrics
+}
+
+// Enabled indicates if metrics are exported to a backend, for metrics which are costly to measure.
+func (m *Metrics) Enabled() bool {
+	return m != nil
+}
+
+// Count adds the delta to a counter.
+func (m *Metrics) Count(name string, delta float64, attrs ...string) {
+	m.record(metricCounter, name, delta, attrs)
+}
+
+// Gauge sets the current value of a gauge.
+func (m *Metrics) Gauge(name string, value float64, attrs ...string) {
+	m.record(metricGauge, name, value, attrs)
+}
+
+// Timing records the duration of an operation with a timer.
+func (m *Metrics) Timing(name string, d time.Duration, attrs ...string) {
+	m.record(metricTimer, name, d.Seconds(), attrs)
+}
+
+// Timer starts timing an operation, returning a function to call once the operation is complete (e.g.
+// defer metrics.Timer("download")()).
+func (m *Metrics) Timer(name string, attrs ...string) func() {
+	if m == nil {
+		return func() {}
+	}
+	started := time.Now()
+	return func() {
+		m.Timing(name, time.Since(started), attrs...)
+	}
+}
+
+func (m *Metrics) record(kind metricKind, name string, value float64, pairs []string) {
+	if m == nil {
+		return
+	}
+	attrs := metricAttributes(pairs)
+	if m.statsd != nil {
+		_, _ = m.statsd.Write([]byte(statsdLine(kind, name, value, attrs)))
+	}
+	if !m.aggregate {
+		return
+	}
+
+	key := metricKey(name, attrs)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	series, ok := m.series[key]
+	if !ok {
+		series = &metricSeries{kind: kind, name: name, attrs: attrs}
+		m.series[key] = series
+	}
+	if series.kind != kind {
+		// the metric was first recorded as a different kind
+		return
+	}
+	switch kind {
+	case metricCounter:
+		series.value += value
+	case metricGauge:
+		series.value = value
+	case metricTimer:
+		series.value += value
+		series.count++
+	}
+}
+
+// metricAttributes converts key-value pairs to attributes (ignoring a trailing key without a value).
+func metricAttributes(pairs []string) map[string]string {
+	if len(pairs) < 2 {
+		return nil
+	}
+	attrs := make(map[string]string, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		attrs[pairs[i]] = pairs[i+1]
+	}
+	return attrs
+}
+
+func metricKey(name string, attrs map[string]string) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	for _, k := range sortedKeys(attrs) {
+		sb.WriteString("\x00" + k + "=" + attrs[k])
+	}
+	return sb.String()
+}
+
+func sortedKeys(attrs map[string]string) []string {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// snapshot returns the aggregated series, ordered by name and attributes.
+func (m *Metrics) snapshot() []metricSeries {
+	if m == nil {
+		return nil
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	keys := make([]string, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]metricSeries, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, *m.series[k])
+	}
+	return out
+}
+
+// telemetryMetrics returns the aggregated series as metrics for the telemetry exporters, with timers as the number
+// of measurements (name.count) and their total (name.sum).
+func (m *Metrics) telemetryMetrics(now time.Time, resource map[string]string) []Metric {
+	var metrics []Metric
+	for _, series := range m.snapshot() {
+		attrs := map[string]string{}
+		for k, v := range resource {
+			attrs[k] = v
+		}
+		for k, v := range series.attrs {
+			attrs[k] = v
+		}
+		switch series.kind {
+		case metricTimer:
+			metrics = append(metrics,
+				Metric{Name: series.name + ".count", Unit: "1", Value: float64(series.count), Time: now, Attributes: attrs},
+				Metric{Name: series.name + ".sum", Unit: "s", Value: series.value, Time: now, Attributes: attrs},
+			)
+		default:
+			metrics = append(metrics, Metric{Name: series.name, Value: series.value, Time: now, Attributes: attrs})
+		}
+	}
+	return metrics
+}
+
+// flush pushes the metrics to the pushgateway (when configured) and closes the connection to statsd.
+func (m *Metrics) flush(ctx context.Context) error {
+	if m == nil {
+		return nil
+	}
+	if m.statsd != nil {
+		_ = m.statsd.Close()
+	}
+	if m.push == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pushgatewayTimeout)
+	defer cancel()
+	target := strings.TrimSuffix(m.push, "/") + "/metrics/job/" + url.PathEscape(m.job)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(prometheusText(m.snapshot())))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := m.state.HTTPClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to push metrics: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unable to push metrics: %s", resp.Status)
+	}
+	return nil
+}
+
+// statsdLine formats a measurement for statsd, with attributes as (DogStatsD) tags and timers in milliseconds.
+func statsdLine(kind metricKind, name string, value float64, attrs map[string]string) string {
+	var tags string
+	if len(attrs) > 0 {
+		var parts []string
+		for _, k := range sortedKeys(attrs) {
+			parts = append(parts, k+":"+attrs[k])
+		}
+		tags = "|#" + strings.Join(parts, ",")
+	}
+	format := func(v float64, typ string) string {
+		return name + ":" + strconv.FormatFloat(v, 'f', -1, 64) + "|" + typ + tags + "\n"
+	}
+	switch kind {
+	case metricGauge:
+		if value < 0 {
+			// a signed gauge value is a change to the gauge, so negative values are set from zero
+			return format(0, "g") + format(value, "g")
+		}
+		return format(value, "g")
+	case metricTimer:
+		return format(value*1000, "ms")
+	}
+	return format(value, "c")
+}
+
+// prometheusText formats the series in the Prometheus text exposition format, with timers as summaries.
+func prometheusText(all []metricSeries) []byte {
+	var buf bytes.Buffer
+	typed := map[string]bool{}
+	for _, series := range all {
+		name := prometheusName(series.name, true)
+		if !typed[name] {
+			typed[name] = true
+			typ := "counter"
+			switch series.kind {
+			case metricGauge:
+				typ = "gauge"
+			case metricTimer:
+				typ = "summary"
+			}
+			fmt.Fprintf(&buf, "# TYPE %s %s\n", name, typ)
+		}
+		labels := prometheusLabels(series.attrs)
+		value := strconv.FormatFloat(series.value, 'g', -1, 64)
+		if series.kind == metricTimer {
+			fmt.Fprintf(&buf, "%s_sum%s %s\n", name, labels, value)
+			fmt.Fprintf(&buf, "%s_count%s %d\n", name, labels, series.count)
+			continue
+		}
+		fmt.Fprintf(&buf, "%s%s %s\n", name, labels, value)
+	}
+	return buf.Bytes()
+}
+
+// prometheusName replaces the characters which are not valid in Prometheus metric (or label) names with underscores.
+func prometheusName(name string, metric bool) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == ':' && metric:
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func prometheusLabels(attrs map[string]string) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	var parts []string
+	for _, k := range sortedKeys(attrs) {
+		parts = append(parts, prometheusName(k, false)+`="`+escape.Replace(attrs[k])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// setupMetrics creates the metrics facade when telemetry is enabled with a metrics backend: the telemetry exporters
+// (see TelemetryExporters), statsd, or a Prometheus pushgateway.
+func (s *State) setupMetrics(cfg SetupConfig) error {
+	s.metrics = nil
+	tel := s.Config.Telemetry
+	if tel == nil || !tel.Enabled {
+		return nil
+	}
+	m := &Metrics{
+		aggregate: tel.Pushgateway != "" || (tel.exportsMetrics() && (cfg.TelemetryExporters.Metrics != nil || tel.Endpoint != "")),
+		push:      tel.Pushgateway,
+		job:       tel.serviceName(cfg.ID),
+		state:     s,
+		series:    map[string]*metricSeries{},
+	}
+	if tel.StatsD != "" {
+		if err := s.CheckNetwork(tel.StatsD); err != nil {
+			return err
+		}
+		conn, err := net.Dial("udp", tel.StatsD)
+		if err != nil {
+			return fmt.Errorf("unable to connect to statsd: %w", err)
+		}
+		m.statsd = conn
+	}
+	if !m.aggregate && m.statsd == nil {
+		return nil
+	}
+	s.metrics = m
+	return nil
+}
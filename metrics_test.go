@@ -0,0 +1,139 @@
+package clio
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Metrics_noop(t *testing.T) {
+	s := &State{Config: Config{Telemetry: &TelemetryConfig{Metrics: true, Endpoint: "http://localhost:4318"}}}
+	require.NoError(t, s.setupMetrics(SetupConfig{}))
+
+	m := s.Metrics()
+	assert.Nil(t, m, "telemetry is not enabled")
+	assert.False(t, m.Enabled())
+	m.Count("files", 1)
+	m.Gauge("queue", 3)
+	m.Timer("scan")()
+	assert.Empty(t, m.snapshot())
+
+	s.Config.Telemetry = &TelemetryConfig{Enabled: true}
+	require.NoError(t, s.setupMetrics(SetupConfig{}))
+	assert.Nil(t, s.Metrics(), "no backend is configured")
+}
+
+func Test_Metrics_telemetryExporters(t *testing.T) {
+	rec := &recordingExporter{}
+	s := &State{Config: Config{Telemetry: &TelemetryConfig{Enabled: true, Metrics: true}}}
+	require.NoError(t, s.setupMetrics(SetupConfig{TelemetryExporters: TelemetryExporters{Metrics: rec}}))
+
+	m := s.Metrics()
+	require.True(t, m.Enabled())
+	m.Count("files.scanned", 2, "language", "go")
+	m.Count("files.scanned", 3, "language", "go")
+	m.Count("files.scanned", 1, "language", "rust")
+	m.Gauge("queue.depth", 7)
+	m.Gauge("queue.depth", 4)
+	m.Timing("download", 1500*time.Millisecond)
+	m.Timing("download", 500*time.Millisecond)
+	m.Gauge("files.scanned", 1, "language", "go") // recorded as a different kind
+
+	c := newMetricsCollector(rec, "app")
+	c.app = m
+	var got []Metric
+	for _, metric := range c.collect(nil) {
+		if strings.HasPrefix(metric.Name, "process.") || strings.HasPrefix(metric.Name, "clio.") {
+			continue
+		}
+		attrs := map[string]string{}
+		for k, v := range metric.Attributes {
+			attrs[k] = v
+		}
+		assert.Equal(t, "app", attrs["service.name"])
+		delete(attrs, "service.name")
+		metric.Time, metric.Attributes = time.Time{}, attrs
+		got = append(got, metric)
+	}
+	assert.Equal(t, []Metric{
+		{Name: "download.count", Unit: "1", Value: 2, Attributes: map[string]string{}},
+		{Name: "download.sum", Unit: "s", Value: 2, Attributes: map[string]string{}},
+		{Name: "files.scanned", Value: 5, Attributes: map[string]string{"language": "go"}},
+		{Name: "files.scanned", Value: 1, Attributes: map[string]string{"language": "rust"}},
+		{Name: "queue.depth", Value: 4, Attributes: map[string]string{}},
+	}, got)
+}
+
+func Test_Metrics_statsd(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	s := &State{Config: Config{Telemetry: &TelemetryConfig{Enabled: true, StatsD: conn.LocalAddr().String()}}}
+	require.NoError(t, s.setupMetrics(SetupConfig{}))
+	m := s.Metrics()
+	m.Count("files.scanned", 1, "language", "go", "kind")
+	m.Gauge("queue.depth", -2)
+	m.Timing("download", 250*time.Millisecond)
+	assert.Empty(t, m.snapshot(), "measurements are only sent to statsd")
+
+	var lines []string
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for len(lines) < 3 {
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		lines = append(lines, string(buf[:n]))
+	}
+	assert.Equal(t, []string{
+		"files.scanned:1|c|#language:go\n",
+		"queue.depth:0|g\nqueue.depth:-2|g\n",
+		"download:250|ms\n",
+	}, lines)
+	require.NoError(t, m.flush(context.Background()))
+
+	s.Config.Network = &NetworkConfig{Offline: true}
+	require.Error(t, s.setupMetrics(SetupConfig{}), "statsd is subject to the network policy")
+}
+
+func Test_Metrics_pushgateway(t *testing.T) {
+	var path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contents, _ := io.ReadAll(r.Body)
+		path, body = r.Method+" "+r.URL.Path, string(contents)
+	}))
+	defer server.Close()
+
+	s := &State{Config: Config{Telemetry: &TelemetryConfig{Enabled: true, Pushgateway: server.URL}}}
+	require.NoError(t, s.setupMetrics(SetupConfig{ID: Identification{Name: "my-app"}}))
+	m := s.Metrics()
+	m.Count("files.scanned", 3, "language", `g"o`)
+	m.Gauge("queue-depth", 1)
+	m.Timing("download", 2*time.Second)
+	m.Timing("download", time.Second)
+	require.NoError(t, m.flush(context.Background()))
+
+	assert.Equal(t, "PUT /metrics/job/my-app", path)
+	assert.Equal(t, `# TYPE download summary
+download_sum 3
+download_count 2
+# TYPE files_scanned counter
+files_scanned{language="g\"o"} 3
+# TYPE queue_depth gauge
+queue_depth 1
+`, body)
+}
+
+func Test_TelemetryConfig_PostLoad_metrics(t *testing.T) {
+	require.NoError(t, (&TelemetryConfig{StatsD: "localhost:8125", Pushgateway: "http://localhost:9091"}).PostLoad())
+	require.EqualError(t, (&TelemetryConfig{StatsD: "localhost"}).PostLoad(), `invalid telemetry statsd address "localhost": expected host:port`)
+	require.EqualError(t, (&TelemetryConfig{Pushgateway: "localhost:9091"}).PostLoad(), `invalid telemetry pushgateway "localhost:9091"`)
+}
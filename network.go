@@ -0,0 +1,192 @@
+package clio
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/boss-net/fangs"
+)
+
+// NetworkConfig is the user-facing network policy, restricting which hosts the application may connect to (see
+// SetupConfig.WithNetworkPolicy). Hosts are matched by name patterns (e.g. "registry.example.com" or
+// "*.example.com") or, for IP addresses, by CIDR blocks (e.g. "10.0.0.0/8").
+type NetworkConfig struct {
+	Offline bool     `yaml:"offline" json:"offline" mapstructure:"offline"` // disable all network activity except to allowed hosts
+	Allow   []string `yaml:"allow" json:"allow" mapstructure:"allow"`       // when set, only these hosts may be connected to
+	Deny    []string `yaml:"deny" json:"deny" mapstructure:"deny"`          // these hosts may never be connected to (takes precedence over allow)
+}
+
+var _ interface {
+	fangs.FlagAdder
+	fangs.FieldDescriber
+	fangs.PostLoader
+} = (*NetworkConfig)(nil)
+
+func (c *NetworkConfig) AddFlags(flags fangs.FlagSet) {
+	flags.BoolVarP(&c.Offline, "offline", "", "disable all network activity except to hosts explicitly allowed in the network policy")
+}
+
+func (c *NetworkConfig) DescribeFields(set fangs.FieldDescriptionSet) {
+	set.Add(&c.Allow, "only connect to these hosts (e.g. registry.example.com, *.example.com, 10.0.0.0/8), also when offline")
+	set.Add(&c.Deny, "never connect to these hosts, even when allowed")
+}
+
+func (c *NetworkConfig) PostLoad() error {
+	for _, patterns := range [][]string{c.Allow, c.Deny} {
+		for _, p := range patterns {
+			if _, _, err := net.ParseCIDR(p); err == nil {
+				continue
+			}
+			if _, err := path.Match(p, ""); err != nil || p == "" {
+				return fmt.Errorf("invalid network host pattern %q", p)
+			}
+		}
+	}
+	return nil
+}
+
+// NetworkPolicyError is returned when a connection to a host is not allowed by the network policy.
+type NetworkPolicyError struct {
+	Host   string
+	Reason string
+}
+
+func (e *NetworkPolicyError) Error() string {
+	return fmt.Sprintf("connection to %s is not allowed by the network policy (%s)", e.Host, e.Reason)
+}
+
+// Check returns a NetworkPolicyError when connecting to the host (optionally with a port) is not allowed.
+func (c *NetworkConfig) Check(host string) error {
+	if c == nil {
+		return nil
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(strings.Trim(host, "[]"), "."))
+
+	if matchesHost(host, c.Deny) {
+		return &NetworkPolicyError{Host: host, Reason: "denied"}
+	}
+	if matchesHost(host, c.Allow) {
+		return nil
+	}
+	if c.Offline {
+		return &NetworkPolicyError{Host: host, Reason: "offline"}
+	}
+	if len(c.Allow) > 0 {
+		return &NetworkPolicyError{Host: host, Reason: "not allowed"}
+	}
+	return nil
+}
+
+func matchesHost(host string, patterns []string) bool {
+	ip := net.ParseIP(host)
+	for _, p := range patterns {
+		if _, block, err := net.ParseCIDR(p); err == nil {
+			if ip != nil && block.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(strings.ToLower(p), host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckNetwork returns a NetworkPolicyError when connecting to the host (optionally with a port) is not allowed by
+// the network policy (see SetupConfig.WithNetworkPolicy). This is enforced for all requests made with the client from
+// State.HTTPClient; connections made any other way should be checked with this first.
+func (s *State) CheckNetwork(host string) error {
+	return s.currentConfig().Network.Check(host)
+}
+
+// HTTPClient returns an http client enforcing the network policy (see SetupConfig.WithNetworkPolicy) on every request,
+// including redirects. The client has no timeout, requests are bounded by their context (or set Timeout on the
+// returned client).
+func (s *State) HTTPClient() *http.Client {
+	return &http.Client{
+		Transport: s.httpTransport(),
+	}
+}
+
+// networkPolicyTransport checks the host of each request against the network policy before sending it, and the
+// addresses the host resolves to before connecting to it (so that a name can't be used to reach a denied CIDR block).
+// Note: the host of the request is checked rather than the address of a proxy, so that requests sent through a proxy
+// are restricted by their destination.
+type networkPolicyTransport struct {
+	base   http.RoundTripper
+	policy *NetworkConfig
+}
+
+// destinationKey is the context key for the host of the request being sent through the networkPolicyTransport.
+type destinationKey struct{}
+
+func newNetworkPolicyTransport(policy *NetworkConfig) *networkPolicyTransport {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	base.DialContext = policy.dialContext(dialer.DialContext)
+	return &networkPolicyTransport{
+		base:   base,
+		policy: policy,
+	}
+}
+
+func (t *networkPolicyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.policy.Check(req.URL.Host); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	ctx := context.WithValue(req.Context(), destinationKey{}, req.URL.Hostname())
+	return t.base.RoundTrip(req.WithContext(ctx))
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dialContext wraps the dial function to resolve the destination of the request (see networkPolicyTransport) and
+// reject it when any of its addresses are denied, then connect to the checked addresses (rather than resolving the
+// host again, which could give different addresses).
+func (c *NetworkConfig) dialContext(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || c == nil || len(c.Deny) == 0 {
+			return dial(ctx, network, addr)
+		}
+		if destination, _ := ctx.Value(destinationKey{}).(string); !strings.EqualFold(destination, host) {
+			// connecting to a proxy
+			return dial(ctx, network, addr)
+		}
+
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			if matchesHost(ip.IP.String(), c.Deny) {
+				return nil, &NetworkPolicyError{Host: host, Reason: fmt.Sprintf("resolves to denied address %s", ip.IP)}
+			}
+		}
+
+		var lastErr error
+		for _, ip := range ips {
+			conn, err := dial(ctx, network, net.JoinHostPort(ip.IP.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+}
@@ -0,0 +1,143 @@
+package clio
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NetworkConfig_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *NetworkConfig
+		host    string
+		wantErr string
+	}{
+		{
+			name: "no policy",
+			cfg:  nil,
+			host: "example.com",
+		},
+		{
+			name: "unrestricted",
+			cfg:  &NetworkConfig{},
+			host: "example.com:443",
+		},
+		{
+			name:    "offline",
+			cfg:     &NetworkConfig{Offline: true},
+			host:    "example.com:443",
+			wantErr: "connection to example.com is not allowed by the network policy (offline)",
+		},
+		{
+			name: "offline with an allowed host",
+			cfg:  &NetworkConfig{Offline: true, Allow: []string{"*.example.com"}},
+			host: "Registry.Example.com:443",
+		},
+		{
+			name:    "not allowed",
+			cfg:     &NetworkConfig{Allow: []string{"*.example.com"}},
+			host:    "example.org",
+			wantErr: "(not allowed)",
+		},
+		{
+			name: "allowed by CIDR",
+			cfg:  &NetworkConfig{Allow: []string{"10.0.0.0/8"}},
+			host: "10.1.2.3:8080",
+		},
+		{
+			name:    "IPv6 not within CIDR",
+			cfg:     &NetworkConfig{Allow: []string{"10.0.0.0/8"}},
+			host:    "[::1]:8080",
+			wantErr: "connection to ::1",
+		},
+		{
+			name:    "deny takes precedence",
+			cfg:     &NetworkConfig{Allow: []string{"*.example.com"}, Deny: []string{"telemetry.example.com"}},
+			host:    "telemetry.example.com",
+			wantErr: "(denied)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Check(tt.host)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			var policyErr *NetworkPolicyError
+			require.ErrorAs(t, err, &policyErr)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func Test_NetworkConfig_PostLoad(t *testing.T) {
+	require.NoError(t, (&NetworkConfig{Allow: []string{"*.example.com", "10.0.0.0/8"}}).PostLoad())
+	require.ErrorContains(t, (&NetworkConfig{Deny: []string{"[bad"}}).PostLoad(), `invalid network host pattern "[bad"`)
+}
+
+func Test_State_HTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	s := &State{Config: Config{Network: &NetworkConfig{Offline: true}}}
+	_, err := s.HTTPClient().Get(server.URL)
+	var policyErr *NetworkPolicyError
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, "offline", policyErr.Reason)
+
+	s.Config.Network.Allow = []string{"127.0.0.1"}
+	resp, err := s.HTTPClient().Get(server.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func Test_State_HTTPClient_resolvedAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	// the name is not denied, but the address it resolves to is
+	s := &State{Config: Config{Network: &NetworkConfig{Deny: []string{"127.0.0.0/8"}}}}
+	_, err = s.HTTPClient().Get("http://localhost:" + port)
+	var policyErr *NetworkPolicyError
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, "localhost", policyErr.Host)
+	assert.Contains(t, policyErr.Reason, "resolves to denied address 127.0.0.1")
+
+	s.Config.Network.Deny = []string{"10.0.0.0/8"}
+	resp, err := s.HTTPClient().Get("http://localhost:" + port)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func Test_Application_OfflineFlag(t *testing.T) {
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithGlobalConfigFlag().WithNetworkPolicy(NetworkConfig{}))
+	root := app.SetupRootCommand(&cobra.Command{})
+
+	var err error
+	root.AddCommand(app.SetupCommand(&cobra.Command{
+		Use: "sub",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err = app.(*application).State().CheckNetwork("example.com")
+			return nil
+		},
+	}))
+
+	root.SetArgs([]string{"sub", "--offline"})
+	require.NoError(t, root.Execute())
+	require.ErrorContains(t, err, "offline")
+}
@@ -0,0 +1,417 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/boss-net/fangs"
+)
+
+// notification hook types
+const (
+	NotifyWebhook = "webhook"
+	NotifySlack   = "slack"
+	NotifyEmail   = "email"
+)
+
+// when notification hooks are sent
+const (
+	NotifyAlways    = "always"
+	NotifyOnSuccess = "success"
+	NotifyOnFailure = "failure"
+)
+
+const (
+	defaultNotifyRetries = 3
+	defaultNotifyTimeout = 30 * time.Second
+	// notifyRetryMaxWait is the longest to wait between attempts to send a notification.
+	notifyRetryMaxWait = 10 * time.Second
+)
+
+// the default templates, rendered with the RunSummary
+const (
+	defaultNotifySlackTemplate   = `{{ .App }} {{ .Command }} {{ .Status }} after {{ humanDuration .Duration }}{{ with .Error }}: {{ . }}{{ end }}`
+	defaultNotifySubjectTemplate = `{{ .App }}: {{ .Command }} {{ .Status }}`
+	defaultNotifyEmailTemplate   = `{{ .Command }} {{ .Status }} on {{ .Host }} after {{ humanDuration .Duration }} (invocation {{ .Invocation }}).
+{{- with .Error }}
+
+Error: {{ . }}
+{{- end }}
+{{- with .Warnings }}
+
+Warnings:
+{{- range . }}
+  - {{ .Message }}
+{{- end }}
+{{- end }}
+`
+)
+
+// NotificationsConfig is the user-facing configuration of notifications sent once commands complete, e.g. to be told
+// when a long-running command finishes (see SetupConfig.WithNotifications).
+type NotificationsConfig struct {
+	Hooks   []NotificationHook `yaml:"hooks" json:"hooks" mapstructure:"hooks"`
+	Retries int                `yaml:"retries" json:"retries" mapstructure:"retries"` // attempts to send each notification after the first fails
+	Timeout time.Duration      `yaml:"timeout" json:"timeout" mapstructure:"timeout"` // how long sending all notifications may take
+}
+
+// NotificationHook is where (and when) a notification is sent. Bodies (and email subjects) are go templates rendered
+// with the RunSummary of the command, with the same functions as the "template" output format, plus humanDuration.
+type NotificationHook struct {
+	Name string `yaml:"name" json:"name" mapstructure:"name"`
+	Type string `yaml:"type" json:"type" mapstructure:"type"` // webhook, slack, or email
+	On   string `yaml:"on" json:"on" mapstructure:"on"`       // always (default), success, or failure
+
+	// webhook and slack
+	URL     string            `yaml:"url" json:"url" mapstructure:"url"`
+	Headers map[string]string `yaml:"headers" json:"headers" mapstructure:"headers"`
+
+	// the body of a webhook (default: the run summary as json), the message text of a slack notification, or the body
+	// of an email
+	Body string `yaml:"body" json:"body" mapstructure:"body"`
+
+	// email
+	SMTP     string   `yaml:"smtp" json:"smtp" mapstructure:"smtp"` // the mail server, as host:port
+	Username string   `yaml:"username" json:"username" mapstructure:"username"`
+	Password string   `yaml:"password" json:"password" mapstructure:"password"`
+	From     string   `yaml:"from" json:"from" mapstructure:"from"`
+	To       []string `yaml:"to" json:"to" mapstructure:"to"`
+	Subject  string   `yaml:"subject" json:"subject" mapstructure:"subject"`
+}
+
+var _ interface {
+	fangs.FieldDescriber
+	fangs.PostLoader
+} = (*NotificationsConfig)(nil)
+
+func (c *NotificationsConfig) DescribeFields(set fangs.FieldDescriptionSet) {
+	set.Add(&c.Hooks, "notifications to send once a command completes (each with a type of webhook, slack, or email)")
+	set.Add(&c.Retries, "times to retry sending a notification that failed")
+	set.Add(&c.Timeout, "how long sending all notifications may take")
+}
+
+func (c *NotificationsConfig) PostLoad() error {
+	if c.Retries < 0 {
+		return fmt.Errorf("invalid notifications.retries %d: must not be negative", c.Retries)
+	}
+	for i, hook := range c.Hooks {
+		if err := hook.validate(); err != nil {
+			return fmt.Errorf("invalid notification hook %s: %w", hook.describe(i), err)
+		}
+	}
+	return nil
+}
+
+func (h NotificationHook) describe(i int) string {
+	if h.Name != "" {
+		return fmt.Sprintf("%q", h.Name)
+	}
+	return fmt.Sprintf("%d", i+1)
+}
+
+func (h NotificationHook) validate() error {
+	switch h.On {
+	case "", NotifyAlways, NotifyOnSuccess, NotifyOnFailure:
+	default:
+		return fmt.Errorf("on must be one of: always, success, failure (got %q)", h.On)
+	}
+
+	switch h.Type {
+	case NotifyWebhook, NotifySlack:
+		if !strings.HasPrefix(h.URL, "http://") && !strings.HasPrefix(h.URL, "https://") {
+			return fmt.Errorf("an http(s) url is required")
+		}
+	case NotifyEmail:
+		if _, _, err := net.SplitHostPort(h.SMTP); err != nil {
+			return fmt.Errorf("smtp must be the host:port of the mail server: %w", err)
+		}
+		if h.From == "" || len(h.To) == 0 {
+			return fmt.Errorf("from and to addresses are required")
+		}
+	default:
+		return fmt.Errorf("type must be one of: webhook, slack, email (got %q)", h.Type)
+	}
+
+	for _, text := range []string{h.Body, h.Subject} {
+		if _, err := notificationTemplate(text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches indicates the notification is sent for the run.
+func (h NotificationHook) matches(run RunSummary) bool {
+	switch h.On {
+	case NotifyOnSuccess:
+		return run.Succeeded
+	case NotifyOnFailure:
+		return !run.Succeeded
+	}
+	return true
+}
+
+// secrets returns the values of the hook to redact (e.g. webhook urls commonly include a token).
+func (h NotificationHook) secrets() []string {
+	values := []string{h.Password}
+	if h.Type == NotifySlack || h.Type == NotifyWebhook {
+		values = append(values, h.URL)
+	}
+	for name, value := range h.Headers {
+		if dotEnvSecretPattern.MatchString(name) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// redactNotificationSecrets adds the secrets of the configured hooks to the redact store.
+func redactNotificationSecrets(s *State) error {
+	cfg := s.currentConfig().Notifications
+	if cfg == nil || s.RedactStore == nil {
+		return nil
+	}
+	for _, hook := range cfg.Hooks {
+		for _, value := range hook.secrets() {
+			if value != "" {
+				s.RedactStore.Add(value)
+			}
+		}
+	}
+	return nil
+}
+
+func notificationTemplate(text string) (*template.Template, error) {
+	funcs := templateFuncs()
+	funcs["humanDuration"] = HumanDuration
+	tmpl, err := template.New("notification").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+func renderNotification(text, def string, run RunSummary) (string, error) {
+	if text == "" {
+		text = def
+	}
+	tmpl, err := notificationTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, run); err != nil {
+		return "", fmt.Errorf("unable to render template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// notify sends the notifications configured for the run, raising a warning for each that could not be sent.
+func notify(s *State, run RunSummary) error {
+	cfg := s.currentConfig().Notifications
+	if cfg == nil || len(cfg.Hooks) == 0 {
+		return nil
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultNotifyTimeout
+	}
+	// the command may have been interrupted, which should still be notified
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for i, hook := range cfg.Hooks {
+		if !hook.matches(run) {
+			continue
+		}
+		if err := sendNotification(ctx, s, hook, run, cfg.Retries); err != nil {
+			s.Warn("notification-failed", fmt.Sprintf("unable to send notification %s: %v", hook.describe(i), err), map[string]any{"type": hook.Type})
+		}
+	}
+	return nil
+}
+
+// sendNotification sends the notification, retrying (with backoff) when it fails.
+func sendNotification(ctx context.Context, s *State, hook NotificationHook, run RunSummary, retries int) error {
+	send, err := notificationSender(s, hook, run)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		err = send(ctx)
+		if err == nil || attempt >= retries {
+			return err
+		}
+		var policyErr *NetworkPolicyError
+		if errors.As(err, &policyErr) {
+			return err
+		}
+		if waitErr := sleepContext(ctx, backoff(attempt, notifyRetryMaxWait)); waitErr != nil {
+			return err
+		}
+	}
+}
+
+// notificationSender renders the notification, returning how to send it.
+func notificationSender(s *State, hook NotificationHook, run RunSummary) (func(context.Context) error, error) {
+	switch hook.Type {
+	case NotifySlack:
+		text, err := renderNotification(hook.Body, defaultNotifySlackTemplate, run)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := json.Marshal(map[string]string{"text": text})
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) error {
+			return postNotification(ctx, s, hook, "application/json", payload)
+		}, nil
+
+	case NotifyWebhook:
+		contentType := "text/plain; charset=utf-8"
+		var payload []byte
+		if hook.Body == "" {
+			contentType = "application/json"
+			encoded, err := json.Marshal(run)
+			if err != nil {
+				return nil, err
+			}
+			payload = encoded
+		} else {
+			body, err := renderNotification(hook.Body, "", run)
+			if err != nil {
+				return nil, err
+			}
+			if json.Valid([]byte(body)) {
+				contentType = "application/json"
+			}
+			payload = []byte(body)
+		}
+		return func(ctx context.Context) error {
+			return postNotification(ctx, s, hook, contentType, payload)
+		}, nil
+
+	case NotifyEmail:
+		subject, err := renderNotification(hook.Subject, defaultNotifySubjectTemplate, run)
+		if err != nil {
+			return nil, err
+		}
+		body, err := renderNotification(hook.Body, defaultNotifyEmailTemplate, run)
+		if err != nil {
+			return nil, err
+		}
+		message := emailMessage(hook.From, hook.To, subject, body)
+		return func(ctx context.Context) error {
+			return sendEmail(ctx, s, hook, message)
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported notification type %q", hook.Type)
+}
+
+func postNotification(ctx context.Context, s *State, hook NotificationHook, contentType string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range hook.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.HTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	return nil
+}
+
+// emailMessage formats a plain text email.
+func emailMessage(from string, to []string, subject, body string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject)))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	body = strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+	buf.WriteString(body)
+	return buf.Bytes()
+}
+
+// sendEmail delivers the message through the mail server of the hook, upgrading the connection with STARTTLS when
+// the server supports it (authentication is only done over TLS, or with a server on the local host).
+func sendEmail(ctx context.Context, s *State, hook NotificationHook, message []byte) error {
+	if err := s.CheckNetwork(hook.SMTP); err != nil {
+		return err
+	}
+	host, _, _ := net.SplitHostPort(hook.SMTP)
+
+	dialer := &net.Dialer{}
+	dial := s.currentConfig().Network.dialContext(dialer.DialContext)
+	conn, err := dial(context.WithValue(ctx, destinationKey{}, host), "tcp", hook.SMTP)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+	if hook.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", hook.Username, hook.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(hook.From); err != nil {
+		return err
+	}
+	for _, to := range hook.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,228 @@
+package clio
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/boss-net/go-logger/adapter/redact"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func notifyState(hooks ...NotificationHook) *State {
+	return &State{
+		Config: Config{
+			Network:       &NetworkConfig{},
+			Notifications: &NotificationsConfig{Hooks: hooks, Retries: 2, Timeout: 5 * time.Second},
+		},
+		RedactStore: redact.NewStore(),
+	}
+}
+
+var testRunSummary = RunSummary{
+	App:        "app",
+	Host:       "build-1",
+	Command:    "app scan",
+	Invocation: "abc123",
+	Duration:   83 * time.Second,
+	ExitCode:   ExitCodeError,
+	Error:      "3 vulnerabilities found",
+	Result:     map[string]int{"critical": 3},
+}
+
+func Test_notify_slack(t *testing.T) {
+	var calls int32
+	var payload map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+	}))
+	defer server.Close()
+
+	s := notifyState(
+		NotificationHook{Type: NotifySlack, URL: server.URL},
+		NotificationHook{Type: NotifySlack, URL: server.URL, On: NotifyOnSuccess},
+	)
+	require.NoError(t, notify(s, testRunSummary))
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls), "failures are retried, and only matching hooks are sent")
+	assert.Equal(t, map[string]string{"text": "app app scan failed after 1m 23s: 3 vulnerabilities found"}, payload)
+	assert.Empty(t, s.Warnings())
+}
+
+func Test_notify_webhook(t *testing.T) {
+	var bodies []string
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, r.Header.Get("Content-Type")+" "+string(body))
+		if a := r.Header.Get("Authorization"); a != "" {
+			auth = a
+		}
+	}))
+	defer server.Close()
+
+	s := notifyState(
+		NotificationHook{Type: NotifyWebhook, URL: server.URL, Headers: map[string]string{"Authorization": "Bearer t0ken"}},
+		NotificationHook{Type: NotifyWebhook, URL: server.URL, Body: `{{ .Command }}: {{ .Result.critical }} critical`},
+	)
+	require.NoError(t, notify(s, testRunSummary))
+
+	require.Len(t, bodies, 2)
+	assert.True(t, strings.HasPrefix(bodies[0], `application/json {"app":"app","host":"build-1","command":"app scan"`), bodies[0])
+	assert.Contains(t, bodies[0], `"result":{"critical":3}`)
+	assert.Equal(t, "text/plain; charset=utf-8 app scan: 3 critical", bodies[1])
+	assert.Equal(t, "Bearer t0ken", auth)
+}
+
+func Test_notify_failureRaisesWarning(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	hook := NotificationHook{Name: "team", Type: NotifySlack, URL: server.URL + "/hooks/T0SECRET"}
+	s := notifyState(hook)
+	s.Config.Notifications.Retries = 1
+	require.NoError(t, redactNotificationSecrets(s))
+	require.NoError(t, notify(s, testRunSummary))
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	warnings := s.Warnings()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "notification-failed", warnings[0].Code)
+	assert.Equal(t, `unable to send notification "team": unexpected response: 403 Forbidden`, warnings[0].Message)
+
+	denied := notifyState(hook)
+	denied.Config.Network.Offline = true
+	require.NoError(t, redactNotificationSecrets(denied))
+	require.NoError(t, notify(denied, testRunSummary))
+	require.Len(t, denied.Warnings(), 1)
+	assert.Contains(t, denied.Warnings()[0].Message, "not allowed by the network policy")
+	assert.NotContains(t, denied.Warnings()[0].Message, "T0SECRET")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls), "requests denied by the network policy are not retried")
+}
+
+// fakeSMTPServer accepts a single message, recording the commands and data it was sent.
+func fakeSMTPServer(t *testing.T) (string, func() []string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	var lock sync.Mutex
+	var received []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lock.Lock()
+			received = append(received, line)
+			lock.Unlock()
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				reply("250 localhost")
+			case line == "DATA":
+				reply("354 go ahead")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					data = strings.TrimRight(data, "\r\n")
+					if data == "." {
+						break
+					}
+					lock.Lock()
+					received = append(received, data)
+					lock.Unlock()
+				}
+				reply("250 queued")
+			case line == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return l.Addr().String(), func() []string {
+		<-done
+		lock.Lock()
+		defer lock.Unlock()
+		return received
+	}
+}
+
+func Test_notify_email(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	s := notifyState(NotificationHook{
+		Type: NotifyEmail,
+		SMTP: addr,
+		From: "app@example.com",
+		To:   []string{"ops@example.com"},
+	})
+	require.NoError(t, notify(s, testRunSummary))
+	assert.Empty(t, s.Warnings())
+
+	lines := received()
+	assert.Contains(t, lines, "MAIL FROM:<app@example.com>")
+	assert.Contains(t, lines, "RCPT TO:<ops@example.com>")
+	assert.Contains(t, lines, "Subject: app: app scan failed")
+	assert.Contains(t, lines, "app scan failed on build-1 after 1m 23s (invocation abc123).")
+	assert.Contains(t, lines, "Error: 3 vulnerabilities found")
+}
+
+func Test_NotificationsConfig_PostLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    NotificationHook
+		wantErr string
+	}{
+		{name: "valid slack", hook: NotificationHook{Type: NotifySlack, URL: "https://hooks.example.com/x"}},
+		{name: "valid email", hook: NotificationHook{Type: NotifyEmail, SMTP: "mail:25", From: "a@b", To: []string{"c@d"}}},
+		{name: "unknown type", hook: NotificationHook{Type: "pager"}, wantErr: `invalid notification hook 1: type must be one of: webhook, slack, email (got "pager")`},
+		{name: "missing url", hook: NotificationHook{Name: "ci", Type: NotifyWebhook}, wantErr: `invalid notification hook "ci": an http(s) url is required`},
+		{name: "invalid on", hook: NotificationHook{Type: NotifySlack, URL: "https://x", On: "never"}, wantErr: `on must be one of: always, success, failure (got "never")`},
+		{name: "invalid template", hook: NotificationHook{Type: NotifySlack, URL: "https://x", Body: "{{ .App"}, wantErr: "unable to parse template"},
+		{name: "missing recipients", hook: NotificationHook{Type: NotifyEmail, SMTP: "mail:25", From: "a@b"}, wantErr: "from and to addresses are required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&NotificationsConfig{Hooks: []NotificationHook{tt.hook}}).PostLoad()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
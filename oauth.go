@@ -0,0 +1,439 @@
+package clio
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OAuthConfig describes the OAuth2 endpoints and client of the service of the application, for the device code flow
+// (see DeviceFlowAuth) and the browser flow (see BrowserFlowAuth).
+type OAuthConfig struct {
+	ClientID     string
+	ClientSecret string // only for confidential clients, which most command line applications are not
+	Scopes       []string
+
+	DeviceAuthURL string // the device authorization endpoint (for DeviceFlowAuth)
+	AuthURL       string // the authorization endpoint (for BrowserFlowAuth)
+	TokenURL      string
+	RevokeURL     string // the token revocation endpoint, if any (tokens are revoked when logging out)
+}
+
+var _ interface {
+	CredentialsRefresher
+	CredentialsRevoker
+} = (*OAuthConfig)(nil)
+
+// OAuthError is an error response from an OAuth2 endpoint.
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("%s: %s", e.Code, e.Description)
+	}
+	return e.Code
+}
+
+// Refresh renews the credentials with the refresh token grant.
+func (c *OAuthConfig) Refresh(ctx context.Context, state *State, credentials *Credentials) (*Credentials, error) {
+	return c.token(ctx, state, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {credentials.RefreshToken},
+	})
+}
+
+// Revoke invalidates the refresh token (or the token, when there is no refresh token) with the revocation endpoint
+// (see RFC 7009), which does nothing when there is none.
+func (c *OAuthConfig) Revoke(ctx context.Context, state *State, credentials *Credentials) error {
+	if c.RevokeURL == "" {
+		return nil
+	}
+	form := url.Values{"token": {credentials.Token}, "token_type_hint": {"access_token"}}
+	if credentials.RefreshToken != "" {
+		form = url.Values{"token": {credentials.RefreshToken}, "token_type_hint": {"refresh_token"}}
+	}
+	return c.post(ctx, state, c.RevokeURL, form, nil)
+}
+
+// tokenResponse is the response of the token endpoint.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	IDToken      string `json:"id_token"`
+}
+
+func (r tokenResponse) credentials() *Credentials {
+	c := &Credentials{
+		Token:        r.AccessToken,
+		TokenType:    r.TokenType,
+		RefreshToken: r.RefreshToken,
+		Identity:     idTokenIdentity(r.IDToken),
+	}
+	if r.ExpiresIn > 0 {
+		c.Expiry = time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
+	}
+	return c
+}
+
+// token requests credentials from the token endpoint with the given grant.
+func (c *OAuthConfig) token(ctx context.Context, state *State, form url.Values) (*Credentials, error) {
+	var resp tokenResponse
+	if err := c.post(ctx, state, c.TokenURL, form, &resp); err != nil {
+		return nil, err
+	}
+	if resp.AccessToken == "" {
+		return nil, errors.New("no access token in the token response")
+	}
+	return resp.credentials(), nil
+}
+
+// post sends the form (with the client credentials) to the endpoint, decoding the JSON response into v (if given).
+func (c *OAuthConfig) post(ctx context.Context, state *State, endpoint string, form url.Values, v any) error {
+	form.Set("client_id", c.ClientID)
+	if c.ClientSecret != "" {
+		form.Set("client_secret", c.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// some services (e.g. GitHub) only respond with JSON when asked to
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := state.HTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	var oauthErr OAuthError
+	if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Code != "" {
+		return &oauthErr
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected response from %s: %s", endpoint, resp.Status)
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+// idTokenIdentity returns who the (OpenID Connect) ID token identifies, for display only: the token is not verified.
+func idTokenIdentity(idToken string) string {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Email    string `json:"email"`
+		Username string `json:"preferred_username"`
+		Subject  string `json:"sub"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return ""
+	}
+	for _, id := range []string{claims.Email, claims.Username, claims.Subject} {
+		if id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// DeviceFlowAuth is an Authenticator using the OAuth2 device authorization grant (see RFC 8628): the user is shown a
+// code to enter in the browser (on any device), while the application waits for the login to complete. This works
+// without a local browser (e.g. over ssh).
+type DeviceFlowAuth struct {
+	OAuthConfig
+	// NoBrowser disables opening the verification page in the browser automatically.
+	NoBrowser bool
+}
+
+var _ Authenticator = (*DeviceFlowAuth)(nil)
+
+// deviceAuthResponse is the response of the device authorization endpoint.
+type deviceAuthResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// devicePollInterval is how often the token endpoint is polled when the service does not say.
+var devicePollInterval = 5 * time.Second
+
+func (d *DeviceFlowAuth) Login(ctx context.Context, state *State, prompt AuthPrompt) (*Credentials, error) {
+	var auth deviceAuthResponse
+	form := url.Values{}
+	if len(d.Scopes) > 0 {
+		form.Set("scope", strings.Join(d.Scopes, " "))
+	}
+	if err := d.post(ctx, state, d.DeviceAuthURL, form, &auth); err != nil {
+		return nil, fmt.Errorf("unable to start device login: %w", err)
+	}
+
+	prompt.Printf("To log in, open %s and enter the code: %s\n", auth.VerificationURI, auth.UserCode)
+	if !d.NoBrowser {
+		page := auth.VerificationURIComplete
+		if page == "" {
+			page = auth.VerificationURI
+		}
+		_ = OpenBrowser(page)
+	}
+	prompt.Printf("Waiting for the login to complete%s\n", CurrentSymbols().Ellipsis)
+
+	interval := time.Duration(auth.Interval) * time.Second
+	if interval <= 0 {
+		interval = devicePollInterval
+	}
+	if auth.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(auth.ExpiresIn)*time.Second)
+		defer cancel()
+	}
+	return d.poll(ctx, state, auth.DeviceCode, interval)
+}
+
+// poll requests a token until the user completes the login (or it is denied or expires).
+func (d *DeviceFlowAuth) poll(ctx context.Context, state *State, deviceCode string, interval time.Duration) (*Credentials, error) {
+	for {
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, errors.New("the login code expired before the login was completed")
+			}
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		credentials, err := d.token(ctx, state, url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"device_code": {deviceCode},
+		})
+		var oauthErr *OAuthError
+		if !errors.As(err, &oauthErr) {
+			return credentials, err
+		}
+		switch oauthErr.Code {
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		case "access_denied":
+			return nil, errors.New("the login was denied")
+		case "expired_token":
+			return nil, errors.New("the login code expired before the login was completed")
+		default:
+			return nil, err
+		}
+	}
+}
+
+// BrowserFlowAuth is an Authenticator using the OAuth2 authorization code grant with PKCE (see RFC 8252): the
+// authorization page is opened in the browser, which redirects back to a server listening on localhost once the user
+// has logged in, showing a page telling the user to return to the terminal.
+type BrowserFlowAuth struct {
+	OAuthConfig
+	// CallbackPort is the localhost port the redirect is received on, for services which require the redirect URI to
+	// be registered (default: any free port).
+	CallbackPort int
+	// SuccessPage is the HTML shown in the browser once logged in (default: a page telling the user to return to the
+	// terminal, see DefaultOAuthSuccessPage).
+	SuccessPage string
+}
+
+var _ Authenticator = (*BrowserFlowAuth)(nil)
+
+// DefaultOAuthSuccessPage is shown in the browser once logged in with a BrowserFlowAuth. The page is a template given
+// the application name (as .App).
+const DefaultOAuthSuccessPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.App}}: logged in</title>
+<style>
+  body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; background: #f5f6f8; color: #1f2328; display: flex; align-items: center; justify-content: center; height: 100vh; margin: 0; }
+  main { background: #fff; border-radius: 12px; box-shadow: 0 4px 24px rgba(0, 0, 0, .08); padding: 40px 48px; text-align: center; }
+  .check { color: #1a7f37; font-size: 48px; line-height: 1; }
+  h1 { font-size: 22px; margin: 16px 0 8px; }
+  p { color: #59636e; margin: 0; }
+</style>
+</head>
+<body>
+<main>
+  <div class="check">&#10003;</div>
+  <h1>You are logged in to {{.App}}</h1>
+  <p>You can close this window and return to the terminal.</p>
+</main>
+</body>
+</html>
+`
+
+// callbackResult is the outcome of the redirect back to the application.
+type callbackResult struct {
+	code string
+	err  error
+}
+
+func (b *BrowserFlowAuth) Login(ctx context.Context, state *State, prompt AuthPrompt) (*Credentials, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", b.CallbackPort))
+	if err != nil {
+		return nil, fmt.Errorf("unable to receive the login callback: %w", err)
+	}
+	defer listener.Close()
+	redirectURI := fmt.Sprintf("http://%s/callback", listener.Addr().String())
+
+	csrf, err := randomURLString(16)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomURLString(32)
+	if err != nil {
+		return nil, err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	authURL, err := url.Parse(b.AuthURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid authorization URL: %w", err)
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", b.ClientID)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("state", csrf)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	if len(b.Scopes) > 0 {
+		query.Set("scope", strings.Join(b.Scopes, " "))
+	}
+	authURL.RawQuery = query.Encode()
+
+	page, err := b.successPage(state.id.Name)
+	if err != nil {
+		return nil, err
+	}
+	results := make(chan callbackResult, 1)
+	server := &http.Server{
+		Handler:           b.callbackHandler(csrf, page, results),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Close()
+
+	if err := OpenBrowser(authURL.String()); err != nil {
+		prompt.Printf("Open this page in your browser to log in:\n\n  %s\n\n", authURL.String())
+	} else {
+		prompt.Printf("Opened the login page in your browser. If it did not open, visit:\n\n  %s\n\n", authURL.String())
+	}
+	prompt.Printf("Waiting for the login to complete%s\n", CurrentSymbols().Ellipsis)
+
+	var result callbackResult
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result = <-results:
+	}
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	return b.token(ctx, state, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {result.code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	})
+}
+
+func (b *BrowserFlowAuth) successPage(appName string) ([]byte, error) {
+	src := b.SuccessPage
+	if src == "" {
+		src = DefaultOAuthSuccessPage
+	}
+	tmpl, err := template.New("success").Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid login success page: %w", err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, map[string]string{"App": appName}); err != nil {
+		return nil, fmt.Errorf("invalid login success page: %w", err)
+	}
+	return []byte(sb.String()), nil
+}
+
+// callbackHandler receives the redirect from the authorization page, reporting the first valid result.
+func (b *BrowserFlowAuth) callbackHandler(csrf string, page []byte, results chan<- callbackResult) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("state") != csrf {
+			// not a redirect for this login (e.g. a stale browser tab), so it is ignored
+			http.Error(w, "invalid login state, start the login again", http.StatusBadRequest)
+			return
+		}
+
+		var result callbackResult
+		switch {
+		case query.Get("error") != "":
+			result.err = &OAuthError{Code: query.Get("error"), Description: query.Get("error_description")}
+			http.Error(w, "login failed: "+result.err.Error(), http.StatusForbidden)
+		case query.Get("code") == "":
+			result.err = errors.New("no authorization code in the login callback")
+			http.Error(w, result.err.Error(), http.StatusBadRequest)
+		default:
+			result.code = query.Get("code")
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write(page)
+		}
+
+		select {
+		case results <- result:
+		default:
+		}
+	})
+	return mux
+}
+
+// randomURLString returns a random string of n bytes, encoded for use in a URL.
+func randomURLString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate login state: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
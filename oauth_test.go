@@ -0,0 +1,224 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOAuthServer implements the OAuth2 endpoints used by the device and browser flows.
+type fakeOAuthServer struct {
+	*httptest.Server
+	lock      sync.Mutex
+	pending   int // token requests answered with authorization_pending before the token is issued
+	challenge string
+	revoked   []string
+}
+
+func newFakeOAuthServer(t *testing.T) *fakeOAuthServer {
+	f := &fakeOAuthServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "cli", r.FormValue("client_id"))
+		assert.Equal(t, "read write", r.FormValue("scope"))
+		writeJSON(w, http.StatusOK, map[string]any{
+			"device_code":      "device-123",
+			"user_code":        "ABCD-EFGH",
+			"verification_uri": f.URL + "/activate",
+			"expires_in":       60,
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		assert.Equal(t, "S256", q.Get("code_challenge_method"))
+		f.lock.Lock()
+		f.challenge = q.Get("code_challenge")
+		f.lock.Unlock()
+		http.Redirect(w, r, q.Get("redirect_uri")+"?code=code-123&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		switch r.FormValue("grant_type") {
+		case "urn:ietf:params:oauth:grant-type:device_code":
+			assert.Equal(t, "device-123", r.FormValue("device_code"))
+			if f.pending > 0 {
+				f.pending--
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "authorization_pending"})
+				return
+			}
+		case "authorization_code":
+			verifier := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+			if r.FormValue("code") != "code-123" || base64.RawURLEncoding.EncodeToString(verifier[:]) != f.challenge {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant", "error_description": "bad code"})
+				return
+			}
+		case "refresh_token":
+			writeJSON(w, http.StatusOK, map[string]any{"access_token": "access-2", "expires_in": 3600})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"access_token":  "access-1",
+			"token_type":    "Bearer",
+			"refresh_token": "refresh-1",
+			"expires_in":    3600,
+			"id_token":      fakeIDToken(`{"sub": "123", "email": "alice@example.com"}`),
+		})
+	})
+	mux.HandleFunc("/revoke", func(w http.ResponseWriter, r *http.Request) {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		f.revoked = append(f.revoked, r.FormValue("token_type_hint")+":"+r.FormValue("token"))
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeOAuthServer) config() OAuthConfig {
+	return OAuthConfig{
+		ClientID:      "cli",
+		Scopes:        []string{"read", "write"},
+		DeviceAuthURL: f.URL + "/device",
+		AuthURL:       f.URL + "/authorize",
+		TokenURL:      f.URL + "/token",
+		RevokeURL:     f.URL + "/revoke",
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func fakeIDToken(claims string) string {
+	return "e30." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".signature"
+}
+
+// withBrowser replaces opening the browser for the duration of the test.
+func withBrowser(t *testing.T, open func(url string) error) {
+	original := OpenBrowser
+	OpenBrowser = open
+	t.Cleanup(func() { OpenBrowser = original })
+}
+
+func Test_DeviceFlowAuth(t *testing.T) {
+	server := newFakeOAuthServer(t)
+	server.pending = 2
+	original := devicePollInterval
+	devicePollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { devicePollInterval = original })
+
+	var opened []string
+	withBrowser(t, func(url string) error {
+		opened = append(opened, url)
+		return nil
+	})
+
+	var out bytes.Buffer
+	auth := &DeviceFlowAuth{OAuthConfig: server.config()}
+	state := &State{id: Identification{Name: "app"}}
+	credentials, err := auth.Login(context.Background(), state, AuthPrompt{In: strings.NewReader(""), Out: &out})
+	require.NoError(t, err)
+
+	assert.Equal(t, "access-1", credentials.Token)
+	assert.Equal(t, "refresh-1", credentials.RefreshToken)
+	assert.Equal(t, "alice@example.com", credentials.Identity)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), credentials.Expiry, time.Minute)
+	assert.Contains(t, out.String(), "To log in, open "+server.URL+"/activate and enter the code: ABCD-EFGH\n")
+	assert.Equal(t, []string{server.URL + "/activate"}, opened)
+	assert.Equal(t, 0, server.pending, "polls until the login completes")
+
+	refreshed, err := auth.Refresh(context.Background(), state, credentials)
+	require.NoError(t, err)
+	assert.Equal(t, "access-2", refreshed.Token)
+
+	require.NoError(t, auth.Revoke(context.Background(), state, credentials))
+	assert.Equal(t, []string{"refresh_token:refresh-1"}, server.revoked)
+}
+
+func Test_DeviceFlowAuth_denied(t *testing.T) {
+	f := &fakeOAuthServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"device_code": "d", "user_code": "u", "verification_uri": "https://example.com", "interval": 0})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "access_denied"})
+	})
+	f.Server = httptest.NewServer(mux)
+	defer f.Close()
+	original := devicePollInterval
+	devicePollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { devicePollInterval = original })
+
+	auth := &DeviceFlowAuth{OAuthConfig: f.config(), NoBrowser: true}
+	_, err := auth.Login(context.Background(), &State{}, AuthPrompt{In: strings.NewReader(""), Out: io.Discard})
+	require.EqualError(t, err, "the login was denied")
+}
+
+func Test_BrowserFlowAuth(t *testing.T) {
+	server := newFakeOAuthServer(t)
+
+	page := make(chan string, 1)
+	withBrowser(t, func(url string) error {
+		// follow the redirect back to the application, as the browser would once the user logs in
+		go func() {
+			resp, err := http.Get(url)
+			if !assert.NoError(t, err) {
+				page <- ""
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			page <- string(body)
+		}()
+		return nil
+	})
+
+	var out bytes.Buffer
+	auth := &BrowserFlowAuth{OAuthConfig: server.config()}
+	credentials, err := auth.Login(context.Background(), &State{id: Identification{Name: "app"}}, AuthPrompt{In: strings.NewReader(""), Out: &out})
+	require.NoError(t, err)
+
+	assert.Equal(t, "access-1", credentials.Token)
+	assert.Equal(t, "alice@example.com", credentials.Identity)
+	assert.Contains(t, <-page, "You are logged in to app")
+	assert.Contains(t, out.String(), "Opened the login page in your browser")
+}
+
+func Test_BrowserFlowAuth_callbackErrors(t *testing.T) {
+	results := make(chan callbackResult, 1)
+	handler := (&BrowserFlowAuth{}).callbackHandler("expected", []byte("ok"), results)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/callback?state=other&code=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, results, "redirects for other logins are ignored")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/callback?state=expected&error=access_denied&error_description=nope", nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	result := <-results
+	require.EqualError(t, result.err, "access_denied: nope")
+}
+
+func Test_idTokenIdentity(t *testing.T) {
+	assert.Equal(t, "alice", idTokenIdentity(fakeIDToken(`{"sub": "123", "preferred_username": "alice"}`)))
+	assert.Equal(t, "123", idTokenIdentity(fakeIDToken(`{"sub": "123"}`)))
+	assert.Equal(t, "", idTokenIdentity("not-a-token"))
+}
@@ -0,0 +1,203 @@
+package clio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/boss-net/fangs"
+)
+
+// Encoder renders a command result to the given writer.
+type Encoder interface {
+	Encode(w io.Writer, result any) error
+}
+
+// EncoderFunc is an adapter to allow the use of ordinary functions as an Encoder.
+type EncoderFunc func(w io.Writer, result any) error
+
+func (f EncoderFunc) Encode(w io.Writer, result any) error {
+	return f(w, result)
+}
+
+// EncoderConstructor creates an Encoder for a single output format. The argument is anything given after
+// the format name and an "=" (e.g. "go-template-file=./report.tmpl" passes "./report.tmpl").
+type EncoderConstructor func(cfg OutputConfig, arg string) (Encoder, error)
+
+var defaultEncoders = map[string]EncoderConstructor{
+	"json":             newJSONEncoder,
+	"yaml":             newYAMLEncoder,
+	"template":         newTemplateEncoder,
+	"go-template":      newTemplateEncoder,
+	"go-template-file": newTemplateFileEncoder,
+	"jsonpath":         newJSONPathEncoder,
+	"jsonpath-file":    newJSONPathFileEncoder,
+	"table":            newTableEncoder,
+	"wide":             newWideTableEncoder,
+	"markdown":         newMarkdownEncoder,
+	"html":             newHTMLEncoder,
+	"csv":              newCSVEncoder,
+	"tsv":              newTSVEncoder,
+	"sarif":            newSARIFEncoder,
+	"junit":            newJUnitEncoder,
+}
+
+// OutputConfig contains the options for how a command result is shown to the user. It is intended to be
+// embedded within a command configuration, where the command then calls Encode with its result.
+type OutputConfig struct {
+	Format   string      `yaml:"output" json:"output" mapstructure:"output"`                // -o, the output format (and optional argument, e.g. "go-template-file=path")
+	Template string      `yaml:"template" json:"template" mapstructure:"template"`          // the go template (or jsonpath expression) to use with the "template" (or "jsonpath") format
+	Diff     string      `yaml:"diff" json:"diff" mapstructure:"diff"`                      // --diff, a previous json report to show the differences against
+	File     string      `yaml:"output-file" json:"output-file" mapstructure:"output-file"` // --output-file, a file (or upload destination URL) to write the results to instead (see State.Encode)
+	Table    TableConfig `yaml:"table" json:"table" mapstructure:"table"`
+
+	ShowSecrets bool `yaml:"-" json:"-" mapstructure:"show-secrets"` // --show-secrets, write Secrets within the results unmasked
+
+	encoders     map[string]EncoderConstructor
+	secretPolicy SecretPolicy
+}
+
+var _ interface {
+	fangs.PostLoader
+	fangs.FlagAdder
+	fangs.FieldDescriber
+	CompletionFieldsDescriber
+} = (*OutputConfig)(nil)
+
+func NewOutputConfig(defaultFormat string) *OutputConfig {
+	return &OutputConfig{
+		Format: defaultFormat,
+	}
+}
+
+// WithEncoder registers an application-specific output format (or replaces a built-in one).
+func (c *OutputConfig) WithEncoder(format string, constructor EncoderConstructor) *OutputConfig {
+	if c.encoders == nil {
+		c.encoders = make(map[string]EncoderConstructor)
+	}
+	c.encoders[strings.ToLower(format)] = constructor
+	return c
+}
+
+func (c *OutputConfig) AddFlags(flags fangs.FlagSet) {
+	flags.StringVarP(&c.Format, "output", "o", fmt.Sprintf("the format to show the results (available: [%s])", strings.Join(c.Formats(), ", ")))
+	flags.StringVarP(&c.Template, "template", "", "the go template or jsonpath expression to render results with (requires --output template or jsonpath)")
+	flags.StringVarP(&c.Diff, "diff", "", "show only the differences from a previous json report (exits non-zero when there are differences)")
+	flags.StringVarP(&c.File, "output-file", "", "write the results to this file or upload them to this URL (s3://, gs://, azblob://, or http(s)://) instead of stdout")
+	flags.BoolVarP(&c.ShowSecrets, "show-secrets", "", "show secrets within the results (masked on a terminal by default)")
+}
+
+func (c *OutputConfig) DescribeFields(d fangs.FieldDescriptionSet) {
+	d.Add(&c.Format, fmt.Sprintf("the format to show the results (available: [%s])", strings.Join(c.Formats(), ", ")))
+	d.Add(&c.Template, "the go template or jsonpath expression to render results with (requires output to be 'template' or 'jsonpath')")
+	d.Add(&c.Diff, "show only the differences from a previous json report")
+	d.Add(&c.File, "write the results to this file or upload them to this URL (s3://, gs://, azblob://, or http(s)://) instead of stdout")
+}
+
+func (c *OutputConfig) DescribeCompletionFields(set CompletionFieldSet) {
+	set.Values(&c.Format, c.Formats()...)
+	set.None(&c.Template)
+	set.Files(&c.Diff, "json")
+	set.Files(&c.File)
+}
+
+func (c *OutputConfig) PostLoad() error {
+	if err := validateSecretPolicy(c.secretPolicy); err != nil {
+		return err
+	}
+	_, err := c.Encoder()
+	return err
+}
+
+// Formats returns the names of all output formats available to the user.
+func (c OutputConfig) Formats() []string {
+	var formats []string
+	for name := range c.constructors() {
+		formats = append(formats, name)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// Encoder returns the encoder for the configured output format.
+func (c OutputConfig) Encoder() (Encoder, error) {
+	name, arg := parseOutputFormat(c.Format)
+	if name == "" {
+		name = "json"
+	}
+
+	constructor, ok := c.constructors()[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported output format: %q (available: [%s])", c.Format, strings.Join(c.Formats(), ", "))
+	}
+
+	enc, err := constructor(c, arg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure %q output: %w", name, err)
+	}
+	return enc, nil
+}
+
+// Encode writes the given command result to the writer using the configured output format. When a previous report
+// is configured (with --diff) only the differences are shown, returning ErrResultsDiffer if there are any. Secrets
+// within the result are guarded according to the secret policy (see WithSecretPolicy).
+func (c OutputConfig) Encode(w io.Writer, result any) error {
+	if c.File != "" {
+		return fmt.Errorf("unable to write results to %s: writing to files requires State.Encode", c.File)
+	}
+	enc, err := c.Encoder()
+	if err != nil {
+		return err
+	}
+	if result, err = c.guardSecrets(w, result); err != nil {
+		return err
+	}
+	if c.Diff != "" {
+		return encodeDiff(w, enc, c.Diff, result)
+	}
+	if err := enc.Encode(w, result); err != nil {
+		return fmt.Errorf("failed to show results: %w", err)
+	}
+	return nil
+}
+
+func (c OutputConfig) constructors() map[string]EncoderConstructor {
+	all := make(map[string]EncoderConstructor, len(defaultEncoders)+len(c.encoders))
+	for name, constructor := range defaultEncoders {
+		all[name] = constructor
+	}
+	for name, constructor := range c.encoders {
+		all[name] = constructor
+	}
+	return all
+}
+
+// parseOutputFormat splits "name=argument" values, where the argument is optional.
+func parseOutputFormat(format string) (string, string) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(format), "=")
+	return strings.ToLower(strings.TrimSpace(name)), arg
+}
+
+func newJSONEncoder(_ OutputConfig, _ string) (Encoder, error) {
+	return EncoderFunc(func(w io.Writer, result any) error {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", " ")
+		return enc.Encode(result)
+	}), nil
+}
+
+func newYAMLEncoder(_ OutputConfig, _ string) (Encoder, error) {
+	return EncoderFunc(func(w io.Writer, result any) error {
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+		return enc.Close()
+	}), nil
+}
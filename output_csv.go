@@ -0,0 +1,47 @@
+package clio
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// newCSVEncoder writes list-style results as comma-separated values. A different delimiter may be given with the
+// format (e.g. "csv=;"). Columns, sorting, and headers follow the table options, showing all columns by default.
+func newCSVEncoder(cfg OutputConfig, arg string) (Encoder, error) {
+	delimiter := ','
+	if arg != "" {
+		r, size := utf8.DecodeRuneInString(arg)
+		if size != len(arg) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+			return nil, fmt.Errorf("invalid delimiter: %q", arg)
+		}
+		delimiter = r
+	}
+	return newDelimitedEncoder(cfg.Table, delimiter), nil
+}
+
+// newTSVEncoder writes list-style results as tab-separated values.
+func newTSVEncoder(cfg OutputConfig, _ string) (Encoder, error) {
+	return newDelimitedEncoder(cfg.Table, '\t'), nil
+}
+
+func newDelimitedEncoder(cfg TableConfig, delimiter rune) Encoder {
+	return EncoderFunc(func(w io.Writer, result any) error {
+		t, err := cfg.table(result, true, false)
+		if err != nil {
+			return err
+		}
+
+		writer := csv.NewWriter(w)
+		writer.Comma = delimiter
+
+		if len(t.headers) > 0 {
+			if err := writer.Write(t.headers); err != nil {
+				return err
+			}
+		}
+		// note: WriteAll flushes the writer
+		return writer.WriteAll(t.rows)
+	})
+}
@@ -0,0 +1,68 @@
+package clio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_delimitedEncoders(t *testing.T) {
+	type entry struct {
+		Name  string `table:"NAME"`
+		Notes string `table:"NOTES,wide"`
+		Count int    `table:"COUNT"`
+	}
+
+	entries := []entry{
+		{Name: "b", Notes: `has "quotes", and commas`, Count: 2},
+		{Name: "a", Notes: "multi\nline", Count: 1},
+	}
+
+	tests := []struct {
+		name    string
+		format  string
+		table   TableConfig
+		want    string
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:   "csv quotes values and shows all columns",
+			format: "csv",
+			want:   "NAME,NOTES,COUNT\nb,\"has \"\"quotes\"\", and commas\",2\na,\"multi\nline\",1\n",
+		},
+		{
+			name:   "csv with custom delimiter",
+			format: "csv=;",
+			table:  TableConfig{Columns: []string{"name", "count"}},
+			want:   "NAME;COUNT\nb;2\na;1\n",
+		},
+		{
+			name:   "tsv with sorting and no headers",
+			format: "tsv",
+			table:  TableConfig{Columns: []string{"count,name"}, SortBy: "count", NoHeaders: true},
+			want:   "1\ta\n2\tb\n",
+		},
+		{
+			name:    "invalid delimiter",
+			format:  "csv=ab",
+			wantErr: require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+			cfg := OutputConfig{Format: tt.format, Table: tt.table}
+			buf := &bytes.Buffer{}
+			err := cfg.Encode(buf, entries)
+			tt.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package clio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+)
+
+// ErrResultsDiffer is returned (after the differences have been shown) when the result differs from the previous
+// report given with --diff, allowing for the application to exit with a non-zero status.
+var ErrResultsDiffer = errors.New("results differ from the previous report")
+
+// diffIdentityKeys are the object fields used to match up list entries between two reports (in order of preference).
+var diffIdentityKeys = []string{"id", "name"}
+
+type DiffKind string
+
+const (
+	DiffAdded   DiffKind = "added"
+	DiffRemoved DiffKind = "removed"
+	DiffChanged DiffKind = "changed"
+)
+
+// DiffEntry is a single structural difference between a previous report and the current result. The list of
+// entries is shown with the configured output format.
+type DiffEntry struct {
+	Kind   DiffKind `json:"kind" yaml:"kind" table:"KIND"`
+	Path   string   `json:"path" yaml:"path" table:"PATH"`
+	Before any      `json:"before,omitempty" yaml:"before,omitempty" table:"BEFORE"`
+	After  any      `json:"after,omitempty" yaml:"after,omitempty" table:"AFTER"`
+}
+
+// diffResult compares the JSON representation of the result against the previous report found at the given path.
+func diffResult(previousPath string, result any) ([]DiffEntry, error) {
+	contents, err := os.ReadFile(previousPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read previous report: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(contents))
+	dec.UseNumber()
+
+	var previous any
+	if err := dec.Decode(&previous); err != nil {
+		return nil, fmt.Errorf("unable to parse previous report %q (must be json): %w", previousPath, err)
+	}
+
+	current, err := normalizeJSON(result)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []DiffEntry
+	diffValues(&entries, "", previous, current)
+	return entries, nil
+}
+
+func diffValues(entries *[]DiffEntry, path string, before, after any) {
+	switch b := before.(type) {
+	case map[string]any:
+		if a, ok := after.(map[string]any); ok {
+			diffObjects(entries, path, b, a)
+			return
+		}
+	case []any:
+		if a, ok := after.([]any); ok {
+			diffLists(entries, path, b, a)
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		*entries = append(*entries, DiffEntry{Kind: DiffChanged, Path: diffPath(path), Before: before, After: after})
+	}
+}
+
+func diffObjects(entries *[]DiffEntry, path string, before, after map[string]any) {
+	keys := map[string]struct{}{}
+	for k := range before {
+		keys[k] = struct{}{}
+	}
+	for k := range after {
+		keys[k] = struct{}{}
+	}
+
+	var sorted []string
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		childPath := path + "." + k
+		b, inBefore := before[k]
+		a, inAfter := after[k]
+		switch {
+		case !inBefore:
+			*entries = append(*entries, DiffEntry{Kind: DiffAdded, Path: childPath, After: a})
+		case !inAfter:
+			*entries = append(*entries, DiffEntry{Kind: DiffRemoved, Path: childPath, Before: b})
+		default:
+			diffValues(entries, childPath, b, a)
+		}
+	}
+}
+
+// diffLists matches up entries by an identity field (see diffIdentityKeys) when possible, so that a changed
+// entry is reported as a change instead of an unrelated removal and addition. Otherwise entries are compared
+// by value, without regard to order.
+func diffLists(entries *[]DiffEntry, path string, before, after []any) {
+	if key := listIdentityKey(before, after); key != "" {
+		beforeByID := map[string]any{}
+		for _, b := range before {
+			beforeByID[identity(b, key)] = b
+		}
+
+		seen := map[string]bool{}
+		for _, a := range after {
+			id := identity(a, key)
+			seen[id] = true
+			childPath := fmt.Sprintf("%s[%s=%s]", path, key, id)
+			if b, ok := beforeByID[id]; ok {
+				diffValues(entries, childPath, b, a)
+				continue
+			}
+			*entries = append(*entries, DiffEntry{Kind: DiffAdded, Path: childPath, After: a})
+		}
+		for _, b := range before {
+			if id := identity(b, key); !seen[id] {
+				*entries = append(*entries, DiffEntry{Kind: DiffRemoved, Path: fmt.Sprintf("%s[%s=%s]", path, key, id), Before: b})
+			}
+		}
+		return
+	}
+
+	remaining := map[string]int{}
+	for _, b := range before {
+		remaining[canonicalJSON(b)]++
+	}
+	for i, a := range after {
+		c := canonicalJSON(a)
+		if remaining[c] > 0 {
+			remaining[c]--
+			continue
+		}
+		*entries = append(*entries, DiffEntry{Kind: DiffAdded, Path: fmt.Sprintf("%s[%d]", path, i), After: a})
+	}
+	for i, b := range before {
+		c := canonicalJSON(b)
+		if remaining[c] > 0 {
+			remaining[c]--
+			*entries = append(*entries, DiffEntry{Kind: DiffRemoved, Path: fmt.Sprintf("%s[%d]", path, i), Before: b})
+		}
+	}
+}
+
+// listIdentityKey returns the first identity field that every entry in both lists has a unique value for.
+func listIdentityKey(lists ...[]any) string {
+	for _, key := range diffIdentityKeys {
+		valid := true
+		for _, list := range lists {
+			seen := map[string]bool{}
+			for _, v := range list {
+				obj, ok := v.(map[string]any)
+				if !ok {
+					valid = false
+					break
+				}
+				if _, ok := obj[key]; !ok {
+					valid = false
+					break
+				}
+				id := identity(obj, key)
+				if seen[id] {
+					valid = false
+					break
+				}
+				seen[id] = true
+			}
+			if !valid {
+				break
+			}
+		}
+		if valid {
+			return key
+		}
+	}
+	return ""
+}
+
+func identity(v any, key string) string {
+	obj, _ := v.(map[string]any)
+	return jsonPathString(obj[key])
+}
+
+func canonicalJSON(v any) string {
+	// note: encoding/json sorts map keys, so this is stable for normalized values
+	by, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%#v", v)
+	}
+	return string(by)
+}
+
+func diffPath(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path
+}
+
+// encodeDiff shows the differences between the previous report and the result with the given encoder.
+func encodeDiff(w io.Writer, enc Encoder, previousPath string, result any) error {
+	entries, err := diffResult(previousPath, result)
+	if err != nil {
+		return err
+	}
+
+	if entries == nil {
+		// show an empty list instead of a null value
+		entries = []DiffEntry{}
+	}
+
+	if err := enc.Encode(w, entries); err != nil {
+		return fmt.Errorf("failed to show differences: %w", err)
+	}
+
+	if len(entries) > 0 {
+		return ErrResultsDiffer
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package clio
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type diffPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type diffReport struct {
+	Source   string        `json:"source"`
+	Packages []diffPackage `json:"packages"`
+	Tags     []string      `json:"tags"`
+}
+
+func writeReport(t *testing.T, report any) string {
+	t.Helper()
+	by, err := json.Marshal(report)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "previous.json")
+	require.NoError(t, os.WriteFile(path, by, 0600))
+	return path
+}
+
+func Test_diffResult(t *testing.T) {
+	previous := diffReport{
+		Source: "image:1",
+		Packages: []diffPackage{
+			{Name: "a", Version: "1.0"},
+			{Name: "b", Version: "1.0"},
+		},
+		Tags: []string{"x", "y"},
+	}
+
+	tests := []struct {
+		name    string
+		current diffReport
+		want    []DiffEntry
+	}{
+		{
+			name:    "no differences",
+			current: previous,
+		},
+		{
+			name: "entries matched by identity",
+			current: diffReport{
+				Source: "image:1",
+				Packages: []diffPackage{
+					{Name: "b", Version: "2.0"},
+					{Name: "c", Version: "1.0"},
+				},
+				Tags: []string{"y", "x"},
+			},
+			want: []DiffEntry{
+				{Kind: DiffChanged, Path: ".packages[name=b].version", Before: "1.0", After: "2.0"},
+				{Kind: DiffAdded, Path: ".packages[name=c]", After: map[string]any{"name": "c", "version": "1.0"}},
+				{Kind: DiffRemoved, Path: ".packages[name=a]", Before: map[string]any{"name": "a", "version": "1.0"}},
+			},
+		},
+		{
+			name: "scalar lists compared by value",
+			current: diffReport{
+				Source:   "image:2",
+				Packages: previous.Packages,
+				Tags:     []string{"x", "z"},
+			},
+			want: []DiffEntry{
+				{Kind: DiffChanged, Path: ".source", Before: "image:1", After: "image:2"},
+				{Kind: DiffAdded, Path: ".tags[1]", After: "z"},
+				{Kind: DiffRemoved, Path: ".tags[1]", Before: "y"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := diffResult(writeReport(t, previous), tt.current)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_OutputConfig_Encode_diff(t *testing.T) {
+	previous := writeReport(t, diffReport{Source: "image:1"})
+
+	cfg := OutputConfig{Format: "json", Diff: previous}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, cfg.Encode(buf, diffReport{Source: "image:1"}))
+	assert.Equal(t, "[]\n", buf.String())
+
+	buf.Reset()
+	cfg.Format = "table"
+	err := cfg.Encode(buf, diffReport{Source: "image:2"})
+	require.ErrorIs(t, err, ErrResultsDiffer)
+	assert.Equal(t, "KIND      PATH      BEFORE    AFTER\nchanged   .source   image:1   image:2\n", buf.String())
+
+	cfg.Diff = filepath.Join(t.TempDir(), "missing.json")
+	require.Error(t, cfg.Encode(buf, diffReport{}))
+}
@@ -0,0 +1,393 @@
+package clio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// newJSONPathEncoder renders portions of the result selected by a kubectl-style JSONPath template, given inline with
+// the format (e.g. "jsonpath={.items[*].name}") or from the --template option. The result is normalized through its
+// JSON representation first, so expressions address the same field names shown by the json output format.
+func newJSONPathEncoder(cfg OutputConfig, arg string) (Encoder, error) {
+	text := arg
+	if text == "" {
+		text = cfg.Template
+	}
+	if text == "" {
+		return nil, fmt.Errorf("no jsonpath expression provided (use --template)")
+	}
+	return newJSONPathTemplateEncoder(text)
+}
+
+// newJSONPathFileEncoder is the same as newJSONPathEncoder, but reads the template from the given file path.
+func newJSONPathFileEncoder(cfg OutputConfig, arg string) (Encoder, error) {
+	path := arg
+	if path == "" {
+		path = cfg.Template
+	}
+	if path == "" {
+		return nil, fmt.Errorf("no jsonpath file provided")
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read jsonpath file: %w", err)
+	}
+	return newJSONPathTemplateEncoder(string(contents))
+}
+
+func newJSONPathTemplateEncoder(text string) (Encoder, error) {
+	parts, err := parseJSONPathTemplate(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return EncoderFunc(func(w io.Writer, result any) error {
+		doc, err := normalizeJSON(result)
+		if err != nil {
+			return err
+		}
+
+		var sb strings.Builder
+		for _, p := range parts {
+			if p.path == nil {
+				sb.WriteString(p.literal)
+				continue
+			}
+
+			var values []string
+			for _, v := range p.path.evaluate(doc) {
+				values = append(values, jsonPathString(v))
+			}
+			sb.WriteString(strings.Join(values, " "))
+		}
+
+		_, err = io.WriteString(w, sb.String())
+		return err
+	}), nil
+}
+
+// normalizeJSON converts any value into the generic structure produced by decoding its JSON representation.
+func normalizeJSON(value any) (any, error) {
+	by, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(by))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func jsonPathString(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		by, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprintf("%+v", val)
+		}
+		return string(by)
+	}
+}
+
+type jsonPathPart struct {
+	literal string
+	path    *jsonPath
+}
+
+// parseJSONPathTemplate splits the template into literal text and "{...}" expressions. Expressions may also be
+// quoted strings (e.g. {"\n"}) to allow for escape sequences in the output.
+func parseJSONPathTemplate(text string) ([]jsonPathPart, error) {
+	var parts []jsonPathPart
+	for text != "" {
+		start := strings.Index(text, "{")
+		if start < 0 {
+			parts = append(parts, jsonPathPart{literal: text})
+			break
+		}
+		if start > 0 {
+			parts = append(parts, jsonPathPart{literal: text[:start]})
+		}
+
+		end := indexUnquoted(text[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed jsonpath expression: %q", text[start:])
+		}
+		expr := strings.TrimSpace(text[start+1 : start+end])
+		text = text[start+end+1:]
+
+		if strings.HasPrefix(expr, `"`) {
+			literal, err := strconv.Unquote(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jsonpath string literal %s: %w", expr, err)
+			}
+			parts = append(parts, jsonPathPart{literal: literal})
+			continue
+		}
+
+		path, err := parseJSONPath(expr)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, jsonPathPart{path: path})
+	}
+	return parts, nil
+}
+
+type jsonPathSegmentKind int
+
+const (
+	jsonPathField jsonPathSegmentKind = iota
+	jsonPathRecursiveField
+	jsonPathWildcard
+	jsonPathIndex
+	jsonPathSlice
+)
+
+type jsonPathSegment struct {
+	kind       jsonPathSegmentKind
+	name       string
+	index      int
+	start, end *int
+}
+
+type jsonPath struct {
+	expr     string
+	segments []jsonPathSegment
+}
+
+// parseJSONPath parses a single expression, supporting: fields (.name, ['name']), wildcards (.* and [*]),
+// recursive descent (..name), indexes ([0], [-1]), and slices ([1:3]).
+//
+//nolint:funlen,gocognit
+func parseJSONPath(expr string) (*jsonPath, error) {
+	p := &jsonPath{expr: expr}
+	rest := strings.TrimPrefix(expr, "$")
+
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			name, remaining := readJSONPathName(rest[2:])
+			if name == "" {
+				return nil, fmt.Errorf("invalid jsonpath %q: expected field name after '..'", expr)
+			}
+			p.segments = append(p.segments, jsonPathSegment{kind: jsonPathRecursiveField, name: name})
+			rest = remaining
+
+		case strings.HasPrefix(rest, ".*"):
+			p.segments = append(p.segments, jsonPathSegment{kind: jsonPathWildcard})
+			rest = rest[2:]
+
+		case strings.HasPrefix(rest, "."):
+			name, remaining := readJSONPathName(rest[1:])
+			if name == "" {
+				// a lone "." refers to the current object (e.g. "{.}")
+				if remaining == "" {
+					return p, nil
+				}
+				return nil, fmt.Errorf("invalid jsonpath %q: expected field name after '.'", expr)
+			}
+			p.segments = append(p.segments, jsonPathSegment{kind: jsonPathField, name: name})
+			rest = remaining
+
+		case strings.HasPrefix(rest, "["):
+			end := indexUnquoted(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid jsonpath %q: unclosed '['", expr)
+			}
+			seg, err := parseJSONPathBracket(strings.TrimSpace(rest[1:end]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid jsonpath %q: %w", expr, err)
+			}
+			p.segments = append(p.segments, seg)
+			rest = rest[end+1:]
+
+		default:
+			return nil, fmt.Errorf("invalid jsonpath %q: unexpected %q", expr, rest)
+		}
+	}
+	return p, nil
+}
+
+// indexUnquoted returns the index of the first c in s outside of quoted ('...' or "...") literals, or -1.
+func indexUnquoted(s string, c byte) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == '\\' {
+				i++ // the escaped character
+			} else if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '\'' || s[i] == '"':
+			quote = s[i]
+		case s[i] == c:
+			return i
+		}
+	}
+	return -1
+}
+
+func readJSONPathName(s string) (string, string) {
+	i := strings.IndexAny(s, ".[")
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+func parseJSONPathBracket(inner string) (jsonPathSegment, error) {
+	switch {
+	case inner == "*":
+		return jsonPathSegment{kind: jsonPathWildcard}, nil
+
+	case strings.HasPrefix(inner, "'") || strings.HasPrefix(inner, `"`):
+		if len(inner) < 2 || inner[len(inner)-1] != inner[0] {
+			return jsonPathSegment{}, fmt.Errorf("unterminated field name %s", inner)
+		}
+		return jsonPathSegment{kind: jsonPathField, name: inner[1 : len(inner)-1]}, nil
+
+	case strings.Contains(inner, ":"):
+		startStr, endStr, _ := strings.Cut(inner, ":")
+		seg := jsonPathSegment{kind: jsonPathSlice}
+		for _, bound := range []struct {
+			value string
+			dest  **int
+		}{{startStr, &seg.start}, {endStr, &seg.end}} {
+			if strings.TrimSpace(bound.value) == "" {
+				continue
+			}
+			i, err := strconv.Atoi(strings.TrimSpace(bound.value))
+			if err != nil {
+				return jsonPathSegment{}, fmt.Errorf("invalid slice bound %q", bound.value)
+			}
+			*bound.dest = &i
+		}
+		return seg, nil
+
+	default:
+		i, err := strconv.Atoi(inner)
+		if err != nil {
+			return jsonPathSegment{}, fmt.Errorf("invalid index %q", inner)
+		}
+		return jsonPathSegment{kind: jsonPathIndex, index: i}, nil
+	}
+}
+
+func (p *jsonPath) evaluate(doc any) []any {
+	values := []any{doc}
+	for _, seg := range p.segments {
+		var next []any
+		for _, v := range values {
+			next = append(next, seg.apply(v)...)
+		}
+		values = next
+	}
+	return values
+}
+
+func (s jsonPathSegment) apply(v any) []any {
+	switch s.kind {
+	case jsonPathField:
+		if m, ok := v.(map[string]any); ok {
+			if val, ok := m[s.name]; ok {
+				return []any{val}
+			}
+		}
+	case jsonPathRecursiveField:
+		return recursiveJSONField(v, s.name)
+	case jsonPathWildcard:
+		return jsonChildren(v)
+	case jsonPathIndex:
+		if list, ok := v.([]any); ok {
+			i := s.index
+			if i < 0 {
+				i += len(list)
+			}
+			if i >= 0 && i < len(list) {
+				return []any{list[i]}
+			}
+		}
+	case jsonPathSlice:
+		if list, ok := v.([]any); ok {
+			start, end := sliceBounds(len(list), s.start, s.end)
+			if start < end {
+				return list[start:end]
+			}
+		}
+	}
+	return nil
+}
+
+func sliceBounds(length int, start, end *int) (int, int) {
+	clamp := func(bound *int, def int) int {
+		if bound == nil {
+			return def
+		}
+		i := *bound
+		if i < 0 {
+			i += length
+		}
+		if i < 0 {
+			return 0
+		}
+		if i > length {
+			return length
+		}
+		return i
+	}
+	return clamp(start, 0), clamp(end, length)
+}
+
+// jsonChildren returns all values within an object (in key order) or list.
+func jsonChildren(v any) []any {
+	switch val := v.(type) {
+	case []any:
+		return val
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		children := make([]any, 0, len(keys))
+		for _, k := range keys {
+			children = append(children, val[k])
+		}
+		return children
+	}
+	return nil
+}
+
+func recursiveJSONField(v any, name string) []any {
+	var found []any
+	if m, ok := v.(map[string]any); ok {
+		if val, ok := m[name]; ok {
+			found = append(found, val)
+		}
+	}
+	for _, child := range jsonChildren(v) {
+		found = append(found, recursiveJSONField(child, name)...)
+	}
+	return found
+}
@@ -0,0 +1,123 @@
+package clio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_jsonPathEncoder(t *testing.T) {
+	type item struct {
+		Name string `json:"name"`
+		Size int    `json:"size"`
+	}
+
+	type result struct {
+		Items []item         `json:"items"`
+		Meta  map[string]any `json:"meta"`
+	}
+
+	data := result{
+		Items: []item{
+			{Name: "a", Size: 1},
+			{Name: "b", Size: 2},
+			{Name: "c", Size: 3},
+		},
+		Meta: map[string]any{
+			"name":  "meta",
+			"count": 3,
+			"a}b":   "brace",
+			"a]b":   "bracket",
+		},
+	}
+
+	tests := []struct {
+		name    string
+		expr    string
+		want    string
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name: "wildcard field",
+			expr: "{.items[*].name}",
+			want: "a b c",
+		},
+		{
+			name: "root prefix and index",
+			expr: "{$.items[0].size}",
+			want: "1",
+		},
+		{
+			name: "negative index",
+			expr: "{.items[-1].name}",
+			want: "c",
+		},
+		{
+			name: "slice",
+			expr: "{.items[1:].name}",
+			want: "b c",
+		},
+		{
+			name: "bracket field",
+			expr: "{.meta['count']}",
+			want: "3",
+		},
+		{
+			name: "recursive descent",
+			expr: "{..name}",
+			want: "a b c meta",
+		},
+		{
+			name: "literal text and quoted escapes",
+			expr: `count={.meta.count}{"\n"}`,
+			want: "count=3\n",
+		},
+		{
+			name: "quoted literals containing closing brackets",
+			expr: `{.meta['a}b']}{"}"}{.meta["a]b"]}`,
+			want: "brace}bracket",
+		},
+		{
+			name:    "unclosed quoted literal",
+			expr:    `{"}`,
+			wantErr: require.Error,
+		},
+		{
+			name: "object rendered as json",
+			expr: "{.items[0]}",
+			want: `{"name":"a","size":1}`,
+		},
+		{
+			name: "missing field is empty",
+			expr: "{.bogus}",
+			want: "",
+		},
+		{
+			name:    "unclosed expression",
+			expr:    "{.items",
+			wantErr: require.Error,
+		},
+		{
+			name:    "bad index",
+			expr:    "{.items[x]}",
+			wantErr: require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+			cfg := OutputConfig{Format: "jsonpath=" + tt.expr}
+			buf := &bytes.Buffer{}
+			err := cfg.Encode(buf, data)
+			tt.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package clio
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// JUnitReporter is implemented by command results which can be shown as a JUnit XML report (with "-o junit"), e.g. so
+// that CI systems show them in their test report views.
+type JUnitReporter interface {
+	JUnitSuites() []JUnitSuite
+}
+
+// JUnitStatus is the outcome of a test case.
+type JUnitStatus string
+
+const (
+	JUnitPassed  JUnitStatus = "passed"
+	JUnitFailed  JUnitStatus = "failed"  // the check was made and did not pass
+	JUnitErrored JUnitStatus = "errored" // the check could not be made
+	JUnitSkipped JUnitStatus = "skipped"
+)
+
+// JUnitSuite is a group of test cases, e.g. the checks made against a single target.
+type JUnitSuite struct {
+	Name       string
+	Timestamp  time.Time // when the suite started (optional)
+	Properties map[string]string
+	Cases      []JUnitCase
+}
+
+// JUnitCase is a single check within a suite.
+type JUnitCase struct {
+	Name      string
+	ClassName string // how CI systems group cases, commonly the suite name when not set
+	Duration  time.Duration
+	Status    JUnitStatus // when empty, the case passed
+	Message   string      // a one-line summary of why the case failed, errored, or was skipped
+	Details   string      // the full explanation of a failure or error
+	Stdout    string
+	Stderr    string
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr,omitempty"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties *junitProps     `xml:"properties,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProps struct {
+	Properties []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	Stdout    string        `xml:"system-out,omitempty"`
+	Stderr    string        `xml:"system-err,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr,omitempty"`
+	Details string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// newJUnitEncoder writes results implementing JUnitReporter as a JUnit XML report. The name of the report may be given
+// with the format (e.g. "junit=my-checks").
+func newJUnitEncoder(_ OutputConfig, arg string) (Encoder, error) {
+	return EncoderFunc(func(w io.Writer, result any) error {
+		reporter, ok := result.(JUnitReporter)
+		if !ok {
+			return fmt.Errorf("results cannot be shown as JUnit XML (%T does not implement clio.JUnitReporter)", result)
+		}
+		report, err := newJUnitReport(arg, reporter.JUnitSuites())
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, "\n")
+		return err
+	}), nil
+}
+
+func newJUnitReport(name string, suites []JUnitSuite) (*junitTestSuites, error) {
+	report := &junitTestSuites{Name: name, Suites: []junitTestSuite{}}
+	var total time.Duration
+	for _, suite := range suites {
+		s := junitTestSuite{Name: suite.Name, Cases: []junitTestCase{}}
+		if !suite.Timestamp.IsZero() {
+			s.Timestamp = suite.Timestamp.UTC().Format("2006-01-02T15:04:05")
+		}
+		s.Properties = junitProperties(suite.Properties)
+
+		var elapsed time.Duration
+		for _, c := range suite.Cases {
+			tc := junitTestCase{Name: c.Name, ClassName: c.ClassName, Time: junitSeconds(c.Duration), Stdout: c.Stdout, Stderr: c.Stderr}
+			if tc.ClassName == "" {
+				tc.ClassName = suite.Name
+			}
+			switch c.Status {
+			case "", JUnitPassed:
+			case JUnitFailed:
+				tc.Failure = &junitProblem{Message: c.Message, Details: c.Details}
+				s.Failures++
+			case JUnitErrored:
+				tc.Error = &junitProblem{Message: c.Message, Details: c.Details}
+				s.Errors++
+			case JUnitSkipped:
+				tc.Skipped = &junitSkipped{Message: c.Message}
+				s.Skipped++
+			default:
+				return nil, fmt.Errorf("test case %q in suite %q has an invalid status %q (must be one of: passed, failed, errored, skipped)", c.Name, suite.Name, c.Status)
+			}
+			elapsed += c.Duration
+			s.Cases = append(s.Cases, tc)
+		}
+		s.Tests = len(s.Cases)
+		s.Time = junitSeconds(elapsed)
+
+		report.Tests += s.Tests
+		report.Failures += s.Failures
+		report.Errors += s.Errors
+		report.Skipped += s.Skipped
+		total += elapsed
+		report.Suites = append(report.Suites, s)
+	}
+	report.Time = junitSeconds(total)
+	return report, nil
+}
+
+func junitProperties(properties map[string]string) *junitProps {
+	if len(properties) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	props := &junitProps{}
+	for _, name := range names {
+		props.Properties = append(props.Properties, junitProperty{Name: name, Value: properties[name]})
+	}
+	return props
+}
+
+// junitSeconds formats the duration as (fractional) seconds, as expected by JUnit consumers.
+func junitSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
@@ -0,0 +1,72 @@
+package clio
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type junitChecks []JUnitSuite
+
+func (c junitChecks) JUnitSuites() []JUnitSuite {
+	return c
+}
+
+func Test_junitEncoder(t *testing.T) {
+	result := junitChecks{
+		{
+			Name:       "image:latest",
+			Timestamp:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Properties: map[string]string{"platform": "linux/amd64", "digest": "sha256:abc"},
+			Cases: []JUnitCase{
+				{Name: "no-root", Duration: 1500 * time.Millisecond},
+				{Name: "no-secrets", Status: JUnitFailed, Message: "1 secret found", Details: "key in <config>", Duration: 250 * time.Millisecond},
+				{Name: "signed", ClassName: "signatures", Status: JUnitErrored, Message: "registry unavailable"},
+				{Name: "sbom", Status: JUnitSkipped, Message: "disabled", Stdout: "skipping"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, OutputConfig{Format: "junit=checks"}.Encode(&buf, result))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="checks" tests="4" failures="1" errors="1" skipped="1" time="1.750">
+  <testsuite name="image:latest" tests="4" failures="1" errors="1" skipped="1" time="1.750" timestamp="2024-01-02T03:04:05">
+    <properties>
+      <property name="digest" value="sha256:abc"></property>
+      <property name="platform" value="linux/amd64"></property>
+    </properties>
+    <testcase name="no-root" classname="image:latest" time="1.500"></testcase>
+    <testcase name="no-secrets" classname="image:latest" time="0.250">
+      <failure message="1 secret found">key in &lt;config&gt;</failure>
+    </testcase>
+    <testcase name="signed" classname="signatures" time="0.000">
+      <error message="registry unavailable"></error>
+    </testcase>
+    <testcase name="sbom" classname="image:latest" time="0.000">
+      <skipped message="disabled"></skipped>
+      <system-out>skipping</system-out>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, buf.String())
+}
+
+func Test_junitEncoder_invalid(t *testing.T) {
+	err := OutputConfig{Format: "junit"}.Encode(&bytes.Buffer{}, map[string]string{})
+	require.ErrorContains(t, err, "results cannot be shown as JUnit XML (map[string]string does not implement clio.JUnitReporter)")
+
+	err = OutputConfig{Format: "junit"}.Encode(&bytes.Buffer{}, junitChecks{{Name: "s", Cases: []JUnitCase{{Name: "c", Status: "flaky"}}}})
+	require.ErrorContains(t, err, `test case "c" in suite "s" has an invalid status "flaky"`)
+}
+
+func Test_junitEncoder_empty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, OutputConfig{Format: "junit"}.Encode(&buf, junitChecks{}))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="0" failures="0" errors="0" skipped="0" time="0.000"></testsuites>
+`, buf.String())
+}
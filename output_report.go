@@ -0,0 +1,147 @@
+package clio
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// ReportData is the data available to markdown and html report templates.
+type ReportData struct {
+	// Result is the command result object as given to Encode.
+	Result any
+	// Headers and Rows are the tabular view of the result (following the table options), populated when the result
+	// is a struct or list of structs.
+	Headers []string
+	Rows    [][]string
+}
+
+const defaultMarkdownTemplate = `{{- if .Headers -}}
+|{{ range .Headers }} {{ mdEscape . }} |{{ end }}
+|{{ range .Headers }} --- |{{ end }}
+{{ range .Rows }}|{{ range . }} {{ mdEscape . }} |{{ end }}
+{{ end -}}
+{{- else -}}
+` + "```yaml" + `
+{{ toYaml .Result }}
+` + "```" + `
+{{ end -}}
+`
+
+const defaultHTMLTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #f4f4f4; }
+</style>
+</head>
+<body>
+{{- if .Headers }}
+<table>
+<thead>
+<tr>{{ range .Headers }}<th>{{ . }}</th>{{ end }}</tr>
+</thead>
+<tbody>
+{{- range .Rows }}
+<tr>{{ range . }}<td>{{ . }}</td>{{ end }}</tr>
+{{- end }}
+</tbody>
+</table>
+{{- else }}
+<pre>{{ toYaml .Result }}</pre>
+{{- end }}
+</body>
+</html>
+`
+
+// NewMarkdownEncoder creates a markdown report encoder from the given template (see ReportData for what is available
+// to the template). Applications can customize the "markdown" format by registering this with OutputConfig.WithEncoder.
+func NewMarkdownEncoder(text string, cfg TableConfig) (Encoder, error) {
+	funcs := templateFuncs()
+	funcs["mdEscape"] = markdownEscape
+
+	tmpl, err := template.New("markdown").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse markdown template: %w", err)
+	}
+
+	return EncoderFunc(func(w io.Writer, result any) error {
+		return tmpl.Execute(w, newReportData(result, cfg))
+	}), nil
+}
+
+// NewHTMLEncoder creates an html report encoder from the given template (see ReportData for what is available
+// to the template). All values are escaped according to html/template rules. Applications can customize the "html"
+// format by registering this with OutputConfig.WithEncoder.
+func NewHTMLEncoder(text string, cfg TableConfig) (Encoder, error) {
+	tmpl, err := htmltemplate.New("html").Funcs(htmltemplate.FuncMap(templateFuncs())).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse html template: %w", err)
+	}
+
+	return EncoderFunc(func(w io.Writer, result any) error {
+		return tmpl.Execute(w, newReportData(result, cfg))
+	}), nil
+}
+
+// newMarkdownEncoder uses the built-in markdown template, or one read from the file given with the format
+// (e.g. "markdown=./report.md.tmpl").
+func newMarkdownEncoder(cfg OutputConfig, arg string) (Encoder, error) {
+	text, err := reportTemplate(arg, defaultMarkdownTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return NewMarkdownEncoder(text, cfg.Table)
+}
+
+// newHTMLEncoder uses the built-in html template, or one read from the file given with the format
+// (e.g. "html=./report.html.tmpl").
+func newHTMLEncoder(cfg OutputConfig, arg string) (Encoder, error) {
+	text, err := reportTemplate(arg, defaultHTMLTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return NewHTMLEncoder(text, cfg.Table)
+}
+
+func reportTemplate(path, def string) (string, error) {
+	if path == "" {
+		return def, nil
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read template file: %w", err)
+	}
+	return string(contents), nil
+}
+
+func newReportData(result any, cfg TableConfig) ReportData {
+	data := ReportData{Result: result}
+
+	// reports are not bound by terminal width, so show all columns by default (and headers are always needed)
+	cfg.NoHeaders = false
+	if t, err := cfg.table(result, true, true); err == nil {
+		data.Headers = t.headers
+		data.Rows = t.rows
+	}
+	return data
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`|`, `\|`,
+	"\n", "<br>",
+	"`", "\\`",
+	`*`, `\*`,
+	`_`, `\_`,
+)
+
+func markdownEscape(s string) string {
+	return markdownEscaper.Replace(s)
+}
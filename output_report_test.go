@@ -0,0 +1,84 @@
+package clio
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_reportEncoders(t *testing.T) {
+	type pkg struct {
+		Name    string `table:"NAME"`
+		Version string `table:"VERSION"`
+	}
+
+	pkgs := []pkg{
+		{Name: "a|b", Version: "1.0"},
+		{Name: "<script>", Version: "2.0"},
+	}
+
+	customTmpl := filepath.Join(t.TempDir(), "custom.tmpl")
+	require.NoError(t, os.WriteFile(customTmpl, []byte(`{{ len .Rows }} packages`), 0600))
+
+	tests := []struct {
+		name   string
+		format string
+		result any
+		want   []string
+	}{
+		{
+			name:   "markdown table",
+			format: "markdown",
+			result: pkgs,
+			want: []string{
+				"| NAME | VERSION |\n| --- | --- |\n| a\\|b | 1.0 |\n| <script> | 2.0 |\n",
+			},
+		},
+		{
+			name:   "markdown non-tabular",
+			format: "markdown",
+			result: map[string]string{"key": "value"},
+			want:   []string{"```yaml\nkey: value\n```\n"},
+		},
+		{
+			name:   "markdown custom template file",
+			format: "markdown=" + customTmpl,
+			result: pkgs,
+			want:   []string{"2 packages"},
+		},
+		{
+			name:   "html table is escaped",
+			format: "html",
+			result: pkgs,
+			want: []string{
+				"<tr><th>NAME</th><th>VERSION</th></tr>",
+				"<tr><td>&lt;script&gt;</td><td>2.0</td></tr>",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := OutputConfig{Format: tt.format}
+			buf := &bytes.Buffer{}
+			require.NoError(t, cfg.Encode(buf, tt.result))
+			for _, want := range tt.want {
+				assert.Contains(t, buf.String(), want)
+			}
+		})
+	}
+}
+
+func Test_reportEncoders_customizedByApp(t *testing.T) {
+	cfg := NewOutputConfig("markdown").WithEncoder("markdown", func(cfg OutputConfig, _ string) (Encoder, error) {
+		return NewMarkdownEncoder(`# Report{{ "\n" }}{{ range .Rows }}- {{ index . 0 }}{{ "\n" }}{{ end }}`, cfg.Table)
+	})
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, cfg.Encode(buf, []struct{ Name string }{{Name: "a"}, {Name: "b"}}))
+	assert.Equal(t, "# Report\n- a\n- b\n", buf.String())
+
+}
@@ -0,0 +1,294 @@
+package clio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// SARIFReporter is implemented by command results which can be shown as a SARIF 2.1 log (with "-o sarif"), e.g. for
+// upload to code scanning platforms.
+type SARIFReporter interface {
+	// SARIFTool describes the analysis tool, including the rules which results may refer to.
+	SARIFTool() SARIFTool
+	// SARIFResults returns the findings of the analysis.
+	SARIFResults() []SARIFResult
+}
+
+// SARIFLevel is the severity of a SARIF result or the default severity of a rule.
+type SARIFLevel string
+
+const (
+	SARIFLevelNone    SARIFLevel = "none"
+	SARIFLevelNote    SARIFLevel = "note"
+	SARIFLevelWarning SARIFLevel = "warning"
+	SARIFLevelError   SARIFLevel = "error"
+)
+
+// SARIFTool describes the tool which produced the results.
+type SARIFTool struct {
+	Name           string
+	Version        string
+	InformationURI string
+	Rules          []SARIFRule
+}
+
+// SARIFRule describes a kind of finding, which results refer to by ID.
+type SARIFRule struct {
+	ID               string
+	Name             string
+	ShortDescription string
+	FullDescription  string
+	Help             string // markdown explaining the rule and how to resolve findings
+	HelpURI          string
+	Level            SARIFLevel // the default level of results of this rule
+	Tags             []string
+	Properties       map[string]any // e.g. "security-severity" for code scanning platforms
+}
+
+// SARIFResult is a single finding.
+type SARIFResult struct {
+	RuleID    string
+	Level     SARIFLevel // when empty, the level of the rule applies
+	Message   string
+	Locations []SARIFLocation
+	// Fingerprints identify the result across runs (e.g. as the code around it moves), keyed by the kind of fingerprint
+	// (e.g. "primaryLocationLineHash").
+	Fingerprints map[string]string
+	Properties   map[string]any
+}
+
+// SARIFLocation is where a finding was made: a file (a path, relative to the root of the repository when uploading to
+// code scanning platforms, or a URI) and optionally the region within it. Lines and columns start at 1.
+type SARIFLocation struct {
+	Path        string
+	StartLine   int
+	StartColumn int
+	EndLine     int
+	EndColumn   int
+	Message     string
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifToolComponent `json:"tool"`
+	Results []sarifResult      `json:"results"`
+}
+
+type sarifToolComponent struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifMessage struct {
+	Text     string `json:"text,omitempty"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+type sarifRule struct {
+	ID                   string              `json:"id"`
+	Name                 string              `json:"name,omitempty"`
+	ShortDescription     *sarifMessage       `json:"shortDescription,omitempty"`
+	FullDescription      *sarifMessage       `json:"fullDescription,omitempty"`
+	Help                 *sarifMessage       `json:"help,omitempty"`
+	HelpURI              string              `json:"helpUri,omitempty"`
+	DefaultConfiguration *sarifConfiguration `json:"defaultConfiguration,omitempty"`
+	Properties           map[string]any      `json:"properties,omitempty"`
+}
+
+type sarifConfiguration struct {
+	Level SARIFLevel `json:"level"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           *int              `json:"ruleIndex,omitempty"`
+	Level               SARIFLevel        `json:"level,omitempty"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+	Properties          map[string]any    `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine,omitempty"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// newSARIFEncoder writes results implementing SARIFReporter as a SARIF 2.1 log.
+func newSARIFEncoder(_ OutputConfig, _ string) (Encoder, error) {
+	return EncoderFunc(func(w io.Writer, result any) error {
+		reporter, ok := result.(SARIFReporter)
+		if !ok {
+			return fmt.Errorf("results cannot be shown as SARIF (%T does not implement clio.SARIFReporter)", result)
+		}
+		log, err := newSARIFLog(reporter.SARIFTool(), reporter.SARIFResults())
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", " ")
+		return enc.Encode(log)
+	}), nil
+}
+
+func newSARIFLog(tool SARIFTool, results []SARIFResult) (*sarifLog, error) {
+	if tool.Name == "" {
+		return nil, fmt.Errorf("a SARIF tool name is required")
+	}
+
+	driver := sarifDriver{Name: tool.Name, Version: tool.Version, InformationURI: tool.InformationURI}
+	ruleIndex := make(map[string]int, len(tool.Rules))
+	for i, rule := range tool.Rules {
+		if rule.ID == "" {
+			return nil, fmt.Errorf("SARIF rule %d has no ID", i)
+		}
+		if _, ok := ruleIndex[rule.ID]; ok {
+			return nil, fmt.Errorf("duplicate SARIF rule: %q", rule.ID)
+		}
+		if err := validateSARIFLevel(rule.Level); err != nil {
+			return nil, fmt.Errorf("SARIF rule %q: %w", rule.ID, err)
+		}
+		ruleIndex[rule.ID] = i
+		driver.Rules = append(driver.Rules, newSARIFRule(rule))
+	}
+
+	run := sarifRun{Tool: sarifToolComponent{Driver: driver}, Results: []sarifResult{}}
+	for _, result := range results {
+		if result.RuleID == "" {
+			return nil, fmt.Errorf("SARIF result %q has no rule ID", result.Message)
+		}
+		if err := validateSARIFLevel(result.Level); err != nil {
+			return nil, fmt.Errorf("SARIF result for rule %q: %w", result.RuleID, err)
+		}
+		r := sarifResult{
+			RuleID:              result.RuleID,
+			Level:               result.Level,
+			Message:             sarifMessage{Text: result.Message},
+			PartialFingerprints: result.Fingerprints,
+			Properties:          result.Properties,
+		}
+		if i, ok := ruleIndex[result.RuleID]; ok {
+			i := i
+			r.RuleIndex = &i
+		}
+		for _, location := range result.Locations {
+			r.Locations = append(r.Locations, newSARIFLocation(location))
+		}
+		run.Results = append(run.Results, r)
+	}
+
+	return &sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}}, nil
+}
+
+func newSARIFRule(rule SARIFRule) sarifRule {
+	r := sarifRule{
+		ID:               rule.ID,
+		Name:             rule.Name,
+		ShortDescription: sarifText(rule.ShortDescription),
+		FullDescription:  sarifText(rule.FullDescription),
+		HelpURI:          rule.HelpURI,
+		Properties:       rule.Properties,
+	}
+	if rule.Help != "" {
+		r.Help = &sarifMessage{Text: rule.Help, Markdown: rule.Help}
+	}
+	if rule.Level != "" {
+		r.DefaultConfiguration = &sarifConfiguration{Level: rule.Level}
+	}
+	if len(rule.Tags) > 0 {
+		properties := make(map[string]any, len(rule.Properties)+1)
+		for k, v := range rule.Properties {
+			properties[k] = v
+		}
+		properties["tags"] = rule.Tags
+		r.Properties = properties
+	}
+	return r
+}
+
+func newSARIFLocation(location SARIFLocation) sarifLocation {
+	l := sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: sarifURI(location.Path)},
+		},
+		Message: sarifText(location.Message),
+	}
+	if location.StartLine > 0 {
+		l.PhysicalLocation.Region = &sarifRegion{
+			StartLine:   location.StartLine,
+			StartColumn: location.StartColumn,
+			EndLine:     location.EndLine,
+			EndColumn:   location.EndColumn,
+		}
+	}
+	return l
+}
+
+// sarifURI converts a file path to the URI form required by SARIF: relative paths use forward slashes (and are
+// resolved by the platform against the repository root), while absolute paths become file URIs.
+func sarifURI(path string) string {
+	if u, err := url.Parse(path); err == nil && len(u.Scheme) > 1 {
+		// already a URI (a single letter scheme is a windows drive)
+		return path
+	}
+	slashed := filepath.ToSlash(path)
+	if !filepath.IsAbs(path) {
+		return (&url.URL{Path: slashed}).String()
+	}
+	if !strings.HasPrefix(slashed, "/") {
+		slashed = "/" + slashed
+	}
+	return (&url.URL{Scheme: "file", Path: slashed}).String()
+}
+
+func sarifText(text string) *sarifMessage {
+	if text == "" {
+		return nil
+	}
+	return &sarifMessage{Text: text}
+}
+
+func validateSARIFLevel(level SARIFLevel) error {
+	switch level {
+	case "", SARIFLevelNone, SARIFLevelNote, SARIFLevelWarning, SARIFLevelError:
+		return nil
+	}
+	return fmt.Errorf("invalid level %q (must be one of: none, note, warning, error)", level)
+}
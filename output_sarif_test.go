@@ -0,0 +1,154 @@
+package clio
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sarifFindings struct {
+	tool     SARIFTool
+	findings []SARIFResult
+}
+
+func (f sarifFindings) SARIFTool() SARIFTool {
+	return f.tool
+}
+
+func (f sarifFindings) SARIFResults() []SARIFResult {
+	return f.findings
+}
+
+func Test_sarifEncoder(t *testing.T) {
+	result := sarifFindings{
+		tool: SARIFTool{
+			Name:    "scanner",
+			Version: "1.2.3",
+			Rules: []SARIFRule{
+				{
+					ID:               "SEC001",
+					ShortDescription: "Hardcoded secret",
+					Help:             "Move the secret to a **secret store**.",
+					Level:            SARIFLevelError,
+					Tags:             []string{"security"},
+					Properties:       map[string]any{"security-severity": "9.0"},
+				},
+			},
+		},
+		findings: []SARIFResult{
+			{
+				RuleID:       "SEC001",
+				Message:      "secret found",
+				Locations:    []SARIFLocation{{Path: filepath.Join("cmd", "main.go"), StartLine: 12, StartColumn: 3}},
+				Fingerprints: map[string]string{"primaryLocationLineHash": "abc"},
+			},
+			{
+				RuleID:  "UNKNOWN",
+				Level:   SARIFLevelNote,
+				Message: "not described by a rule",
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, OutputConfig{Format: "sarif"}.Encode(&buf, result))
+	assert.JSONEq(t, `{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": [{
+			"tool": {"driver": {
+				"name": "scanner",
+				"version": "1.2.3",
+				"rules": [{
+					"id": "SEC001",
+					"shortDescription": {"text": "Hardcoded secret"},
+					"help": {"text": "Move the secret to a **secret store**.", "markdown": "Move the secret to a **secret store**."},
+					"defaultConfiguration": {"level": "error"},
+					"properties": {"security-severity": "9.0", "tags": ["security"]}
+				}]
+			}},
+			"results": [
+				{
+					"ruleId": "SEC001",
+					"ruleIndex": 0,
+					"message": {"text": "secret found"},
+					"locations": [{"physicalLocation": {
+						"artifactLocation": {"uri": "cmd/main.go"},
+						"region": {"startLine": 12, "startColumn": 3}
+					}}],
+					"partialFingerprints": {"primaryLocationLineHash": "abc"}
+				},
+				{
+					"ruleId": "UNKNOWN",
+					"level": "note",
+					"message": {"text": "not described by a rule"}
+				}
+			]
+		}]
+	}`, buf.String())
+}
+
+func Test_sarifEncoder_noResults(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, OutputConfig{Format: "sarif"}.Encode(&buf, sarifFindings{tool: SARIFTool{Name: "scanner"}}))
+
+	var log map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	run := log["runs"].([]any)[0].(map[string]any)
+	assert.Equal(t, []any{}, run["results"], "an empty list of results indicates a clean run")
+}
+
+func Test_sarifEncoder_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  any
+		wantErr string
+	}{
+		{
+			name:    "not a reporter",
+			result:  []string{"a"},
+			wantErr: "results cannot be shown as SARIF ([]string does not implement clio.SARIFReporter)",
+		},
+		{
+			name:    "no tool name",
+			result:  sarifFindings{},
+			wantErr: "a SARIF tool name is required",
+		},
+		{
+			name: "duplicate rule",
+			result: sarifFindings{tool: SARIFTool{Name: "scanner", Rules: []SARIFRule{
+				{ID: "R1"}, {ID: "R1"},
+			}}},
+			wantErr: `duplicate SARIF rule: "R1"`,
+		},
+		{
+			name: "invalid level",
+			result: sarifFindings{
+				tool:     SARIFTool{Name: "scanner"},
+				findings: []SARIFResult{{RuleID: "R1", Level: "critical"}},
+			},
+			wantErr: `SARIF result for rule "R1": invalid level "critical" (must be one of: none, note, warning, error)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := OutputConfig{Format: "sarif"}.Encode(&bytes.Buffer{}, tt.result)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func Test_sarifURI(t *testing.T) {
+	assert.Equal(t, "src/a%20b.go", sarifURI(filepath.Join("src", "a b.go")))
+	assert.Equal(t, "https://example.com/a.go", sarifURI("https://example.com/a.go"))
+	if runtime.GOOS == "windows" {
+		assert.Equal(t, "file:///C:/src/a.go", sarifURI(`C:\src\a.go`))
+	} else {
+		assert.Equal(t, "file:///src/a.go", sarifURI("/src/a.go"))
+	}
+}
@@ -0,0 +1,250 @@
+package clio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Secret is a sensitive value within a command result (e.g. a token returned by a credential helper). Secrets are
+// guarded when the result is written with OutputConfig.Encode, according to the secret output policy (see
+// OutputConfig.WithSecretPolicy).
+type Secret string
+
+// secretMask is shown in place of the value of a masked Secret.
+const secretMask = "********"
+
+// SecretPolicy controls how Secrets within command results are written.
+type SecretPolicy string
+
+const (
+	// SecretsMaskOnTerminal masks secrets written to a terminal, where they may be seen over the shoulder or captured
+	// in scrollback, but writes them when piped (e.g. to another tool). This is the default.
+	SecretsMaskOnTerminal SecretPolicy = "mask-on-terminal"
+
+	// SecretsMask always masks secrets unless --show-secrets is given.
+	SecretsMask SecretPolicy = "mask"
+
+	// SecretsRequireFlag fails rather than writing a result containing secrets unless --show-secrets is given.
+	SecretsRequireFlag SecretPolicy = "require-flag"
+)
+
+// ErrSecretsNotShown is returned when the result contains secrets which may only be written with --show-secrets (see
+// SecretsRequireFlag).
+var ErrSecretsNotShown = errors.New("the results contain secrets, which are only shown with --show-secrets")
+
+// WithSecretPolicy sets how Secrets within results are written (SecretsMaskOnTerminal by default).
+func (c *OutputConfig) WithSecretPolicy(policy SecretPolicy) *OutputConfig {
+	c.secretPolicy = policy
+	return c
+}
+
+// guardSecrets returns the result to write, with all Secrets masked as required by the secret policy.
+func (c OutputConfig) guardSecrets(w io.Writer, result any) (any, error) {
+	if c.ShowSecrets || result == nil {
+		return result, nil
+	}
+
+	switch c.secretPolicy {
+	case SecretsRequireFlag:
+		if containsSecrets(reflect.ValueOf(result)) {
+			return nil, ErrSecretsNotShown
+		}
+		return result, nil
+	case SecretsMask:
+	default:
+		if !isTerminal(w) {
+			return result, nil
+		}
+	}
+
+	if !containsSecrets(reflect.ValueOf(result)) {
+		return result, nil
+	}
+	return maskSecrets(reflect.ValueOf(result)).Interface(), nil
+}
+
+var secretType = reflect.TypeOf(Secret(""))
+
+// containsSecrets indicates if there are any non-empty Secrets within the value.
+func containsSecrets(v reflect.Value) bool {
+	found := false
+	visitSecrets(v, func(reflect.Value) { found = true })
+	return found
+}
+
+// secretRef identifies a pointer, map, or slice already visited, since values may contain cycles.
+type secretRef struct {
+	ptr uintptr
+	len int
+	typ reflect.Type
+}
+
+func refOf(v reflect.Value) (secretRef, bool) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map:
+		return secretRef{ptr: v.Pointer(), typ: v.Type()}, !v.IsNil()
+	case reflect.Slice:
+		return secretRef{ptr: v.Pointer(), len: v.Len(), typ: v.Type()}, !v.IsNil()
+	}
+	return secretRef{}, false
+}
+
+// visitSecrets calls the function with each non-empty Secret within the value, including map keys.
+func visitSecrets(v reflect.Value, fn func(reflect.Value)) {
+	visitSecretsOnce(v, map[secretRef]bool{}, fn)
+}
+
+func visitSecretsOnce(v reflect.Value, visited map[secretRef]bool, fn func(reflect.Value)) {
+	if !v.IsValid() {
+		return
+	}
+	if v.Type() == secretType {
+		if v.String() != "" {
+			fn(v)
+		}
+		return
+	}
+	if ref, ok := refOf(v); ok {
+		if visited[ref] {
+			return
+		}
+		visited[ref] = true
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			visitSecretsOnce(v.Elem(), visited, fn)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				visitSecretsOnce(v.Field(i), visited, fn)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			visitSecretsOnce(v.Index(i), visited, fn)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			visitSecretsOnce(iter.Key(), visited, fn)
+			visitSecretsOnce(iter.Value(), visited, fn)
+		}
+	}
+}
+
+// maskSecrets returns a copy of the value with all non-empty Secrets masked, including map keys (the original value
+// is not modified). Cycles within the value are kept in the copy.
+func maskSecrets(v reflect.Value) reflect.Value {
+	if !v.IsValid() || !containsSecrets(v) {
+		return v
+	}
+	return (&secretMasker{copies: map[secretRef]reflect.Value{}}).mask(v)
+}
+
+type secretMasker struct {
+	copies map[secretRef]reflect.Value // the copy of each pointer, map, and slice (to keep cycles)
+}
+
+func (m *secretMasker) mask(v reflect.Value) reflect.Value {
+	if !v.IsValid() {
+		return v
+	}
+	if v.Type() == secretType {
+		if v.String() == "" {
+			return v
+		}
+		return reflect.ValueOf(Secret(secretMask))
+	}
+	ref, isRef := refOf(v)
+	if isRef {
+		if out, ok := m.copies[ref]; ok {
+			return out
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		m.copies[ref] = out
+		out.Elem().Set(m.mask(v.Elem()))
+		return out
+	case reflect.Interface:
+		out := reflect.New(v.Type()).Elem()
+		if !v.IsNil() {
+			out.Set(m.mask(v.Elem()))
+		}
+		return out
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				out.Field(i).Set(m.mask(v.Field(i)))
+			}
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		m.copies[ref] = out
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(m.mask(v.Index(i)))
+		}
+		return out
+	case reflect.Array:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(m.mask(v.Index(i)))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		m.copies[ref] = out
+		iter := v.MapRange()
+		for iter.Next() {
+			key := m.mask(iter.Key())
+			if isSecretValue(key) {
+				// masked keys are numbered so that they don't replace each other
+				for n := 2; out.MapIndex(key).IsValid(); n++ {
+					key = reflect.ValueOf(Secret(fmt.Sprintf("%s (%d)", secretMask, n)))
+				}
+			}
+			out.SetMapIndex(key, m.mask(iter.Value()))
+		}
+		return out
+	}
+	return v
+}
+
+func isSecretValue(v reflect.Value) bool {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v.Type() == secretType
+}
+
+// secretPolicies returns all available secret output policies.
+func secretPolicies() []string {
+	return []string{string(SecretsMaskOnTerminal), string(SecretsMask), string(SecretsRequireFlag)}
+}
+
+func validateSecretPolicy(policy SecretPolicy) error {
+	if policy != "" && !contains(secretPolicies(), string(policy)) {
+		return fmt.Errorf("invalid secret policy %q (available: %s)", policy, strings.Join(secretPolicies(), ", "))
+	}
+	return nil
+}
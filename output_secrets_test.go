@@ -0,0 +1,141 @@
+package clio
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type secretsTestCredential struct {
+	Server string `json:"server"`
+	Token  Secret `json:"token"`
+}
+
+type secretsTestResult struct {
+	Credentials []*secretsTestCredential `json:"credentials"`
+	Extra       map[string]any           `json:"extra,omitempty"`
+}
+
+func Test_OutputConfig_Encode_Secrets(t *testing.T) {
+	newResult := func() *secretsTestResult {
+		return &secretsTestResult{
+			Credentials: []*secretsTestCredential{
+				{Server: "registry.example.com", Token: "s3cret"},
+				{Server: "anonymous.example.com"},
+			},
+			Extra: map[string]any{"key": Secret("other")},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		policy      SecretPolicy
+		showSecrets bool
+		want        string
+		wantErr     error
+	}{
+		{
+			name: "shown when piped by default",
+			want: `{"credentials":[{"server":"registry.example.com","token":"s3cret"},{"server":"anonymous.example.com","token":""}],"extra":{"key":"other"}}`,
+		},
+		{
+			name:   "masked",
+			policy: SecretsMask,
+			want:   `{"credentials":[{"server":"registry.example.com","token":"********"},{"server":"anonymous.example.com","token":""}],"extra":{"key":"********"}}`,
+		},
+		{
+			name:        "masked unless shown",
+			policy:      SecretsMask,
+			showSecrets: true,
+			want:        `{"credentials":[{"server":"registry.example.com","token":"s3cret"},{"server":"anonymous.example.com","token":""}],"extra":{"key":"other"}}`,
+		},
+		{
+			name:    "require the flag",
+			policy:  SecretsRequireFlag,
+			wantErr: ErrSecretsNotShown,
+		},
+		{
+			name:        "require the flag given",
+			policy:      SecretsRequireFlag,
+			showSecrets: true,
+			want:        `{"credentials":[{"server":"registry.example.com","token":"s3cret"},{"server":"anonymous.example.com","token":""}],"extra":{"key":"other"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewOutputConfig("json").WithSecretPolicy(tt.policy)
+			cfg.ShowSecrets = tt.showSecrets
+			require.NoError(t, cfg.PostLoad())
+
+			result := newResult()
+			var buf bytes.Buffer
+			err := cfg.Encode(&buf, result)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				assert.Empty(t, buf.String())
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, buf.String())
+
+			// the result itself is never modified
+			assert.Equal(t, newResult(), result)
+		})
+	}
+}
+
+func Test_OutputConfig_Encode_NoSecrets(t *testing.T) {
+	cfg := NewOutputConfig("json").WithSecretPolicy(SecretsRequireFlag)
+
+	var buf bytes.Buffer
+	require.NoError(t, cfg.Encode(&buf, secretsTestCredential{Server: "example.com"}))
+	assert.JSONEq(t, `{"server":"example.com","token":""}`, buf.String())
+}
+
+func Test_OutputConfig_PostLoad_SecretPolicy(t *testing.T) {
+	cfg := NewOutputConfig("json").WithSecretPolicy("sometimes")
+	require.ErrorContains(t, cfg.PostLoad(), `invalid secret policy "sometimes"`)
+}
+
+type secretsTestNode struct {
+	Name  string           `json:"name"`
+	Token Secret           `json:"token"`
+	Next  *secretsTestNode `json:"-"`
+}
+
+func Test_maskSecrets_cycles(t *testing.T) {
+	a := &secretsTestNode{Name: "a", Token: "s3cret"}
+	b := &secretsTestNode{Name: "b", Next: a}
+	a.Next = b
+
+	assert.True(t, containsSecrets(reflect.ValueOf(a)))
+
+	masked := maskSecrets(reflect.ValueOf(a)).Interface().(*secretsTestNode)
+	assert.Equal(t, Secret(secretMask), masked.Token)
+	assert.Equal(t, "b", masked.Next.Name)
+	assert.Same(t, masked, masked.Next.Next, "the cycle is kept")
+	assert.Equal(t, Secret("s3cret"), a.Token, "the original is not modified")
+
+	self := map[string]any{"token": Secret("s3cret")}
+	self["self"] = self
+	maskedMap := maskSecrets(reflect.ValueOf(self)).Interface().(map[string]any)
+	assert.Equal(t, Secret(secretMask), maskedMap["token"])
+}
+
+func Test_maskSecrets_mapKeys(t *testing.T) {
+	result := map[Secret]string{"s3cret": "registry", "0ther": "mirror", "": "anonymous"}
+	assert.True(t, containsSecrets(reflect.ValueOf(map[Secret]int{"s3cret": 1})))
+
+	masked := maskSecrets(reflect.ValueOf(result)).Interface().(map[Secret]string)
+	assert.Len(t, masked, 3)
+	assert.Equal(t, "anonymous", masked[""])
+	assert.ElementsMatch(t, []string{"registry", "mirror"}, []string{masked[secretMask], masked[secretMask+" (2)"]})
+
+	buf := &bytes.Buffer{}
+	cfg := OutputConfig{Format: "json"}
+	require.NoError(t, cfg.WithSecretPolicy(SecretsMask).Encode(buf, map[Secret]int{"s3cret": 1}))
+	assert.NotContains(t, buf.String(), "s3cret")
+}
@@ -0,0 +1,460 @@
+package clio
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/boss-net/fangs"
+)
+
+const (
+	tableColumnSeparator = "   "
+	tableMinColumnWidth  = 5
+)
+
+// TableConfig contains the options for rendering list-style results as a table (with the "table" and "wide" formats).
+//
+// Columns are derived from the exported fields of the result elements, configured with a "table" struct tag:
+//
+//	type Image struct {
+//		Name   string `table:"NAME"`
+//		Digest string `table:"DIGEST,wide"` // only shown with "-o wide" (or when explicitly selected)
+//		Size   int64  `table:"SIZE,bytes"` // shown as a human-friendly size (e.g. "1.5 GiB")
+//		Pulls  int64  `table:"PULLS,number"` // shown with thousands separators (e.g. "12,345")
+//		Labels []string `table:"-"`         // never shown
+//	}
+//
+// When no fields have a "table" tag then all exported fields are shown, using the upper-cased field name as the header.
+// Durations are always shown in a human-friendly form (e.g. "3m 5s"). Values are shown as-is with "ui.raw-values", and
+// in the csv and tsv formats.
+type TableConfig struct {
+	Columns   []string `yaml:"columns" json:"columns" mapstructure:"columns"`          // --columns, which columns to show (and in which order)
+	SortBy    string   `yaml:"sort-by" json:"sort-by" mapstructure:"sort-by"`          // --sort-by, the column to sort rows by
+	NoHeaders bool     `yaml:"no-headers" json:"no-headers" mapstructure:"no-headers"` // --no-headers, do not show the header row
+}
+
+var _ interface {
+	fangs.PostLoader
+	fangs.FlagAdder
+	fangs.FieldDescriber
+	CompletionFieldsDescriber
+} = (*TableConfig)(nil)
+
+func (c *TableConfig) AddFlags(flags fangs.FlagSet) {
+	flags.StringArrayVarP(&c.Columns, "columns", "", "the columns to show in table output (comma separated)")
+	flags.StringVarP(&c.SortBy, "sort-by", "", "the column to sort table output by")
+	flags.BoolVarP(&c.NoHeaders, "no-headers", "", "do not show headers in table output")
+}
+
+func (c *TableConfig) DescribeFields(d fangs.FieldDescriptionSet) {
+	d.Add(&c.Columns, "the columns to show in table output")
+	d.Add(&c.SortBy, "the column to sort table output by")
+	d.Add(&c.NoHeaders, "do not show headers in table output")
+}
+
+func (c *TableConfig) DescribeCompletionFields(set CompletionFieldSet) {
+	set.None(&c.Columns)
+	set.None(&c.SortBy)
+}
+
+func (c *TableConfig) PostLoad() error {
+	c.Columns = splitCommaList(c.Columns)
+	return nil
+}
+
+// splitCommaList allows for both repeated flags and comma-separated values (e.g. "--columns name,size").
+func splitCommaList(values []string) []string {
+	var out []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+func newTableEncoder(cfg OutputConfig, _ string) (Encoder, error) {
+	return tableEncoder{cfg: cfg.Table}, nil
+}
+
+func newWideTableEncoder(cfg OutputConfig, _ string) (Encoder, error) {
+	return tableEncoder{cfg: cfg.Table, wide: true}, nil
+}
+
+type tableEncoder struct {
+	cfg  TableConfig
+	wide bool
+}
+
+func (e tableEncoder) Encode(w io.Writer, result any) error {
+	width := 0
+	if !e.wide {
+		width = terminalWidth(w)
+	}
+
+	table, err := e.cfg.table(result, e.wide, true)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, table.render(width))
+	return err
+}
+
+type tableColumn struct {
+	header string
+	name   string
+	index  int
+	wide   bool
+	format string // how integers are shown to the user: "bytes", "number", or as-is when empty
+}
+
+type table struct {
+	headers []string
+	rows    [][]string
+}
+
+// table returns the headers and rows for the result. When human is set, values are shown in human-friendly forms
+// (durations, and integer columns tagged with "bytes" or "number"), otherwise as raw values (e.g. for machine-readable
+// formats).
+func (c TableConfig) table(result any, wide, human bool) (*table, error) {
+	elements, elemType, err := tableElements(result)
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := c.selectColumns(tableColumns(elemType), wide)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &table{}
+	for _, col := range columns {
+		t.headers = append(t.headers, col.header)
+	}
+
+	if c.SortBy != "" {
+		idx := -1
+		for i, col := range columns {
+			if col.matches(c.SortBy) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, fmt.Errorf("unable to sort by %q: not a shown column (available: [%s])", c.SortBy, strings.Join(t.headers, ", "))
+		}
+		// sort by the raw values, since human-friendly forms (e.g. "1.5 KiB") do not sort numerically
+		field := columns[idx].index
+		sort.SliceStable(elements, func(i, j int) bool {
+			return tableLess(tableCell(elements[i].Field(field), "", false), tableCell(elements[j].Field(field), "", false))
+		})
+	}
+
+	for _, elem := range elements {
+		var row []string
+		for _, col := range columns {
+			row = append(row, tableCell(elem.Field(col.index), col.format, human))
+		}
+		t.rows = append(t.rows, row)
+	}
+
+	if c.SortBy == "" && DeterministicMode() {
+		// results are often collected from maps, so without a sort order the rows may be in any order
+		sort.SliceStable(t.rows, func(i, j int) bool {
+			return lessRow(t.rows[i], t.rows[j])
+		})
+	}
+
+	if c.NoHeaders {
+		t.headers = nil
+	}
+
+	return t, nil
+}
+
+func (c TableConfig) selectColumns(all []tableColumn, wide bool) ([]tableColumn, error) {
+	names := splitCommaList(c.Columns)
+	if len(names) == 0 {
+		var selected []tableColumn
+		for _, col := range all {
+			if col.wide && !wide {
+				continue
+			}
+			selected = append(selected, col)
+		}
+		return selected, nil
+	}
+
+	var selected []tableColumn
+	for _, name := range names {
+		found := false
+		for _, col := range all {
+			if col.matches(name) {
+				selected = append(selected, col)
+				found = true
+				break
+			}
+		}
+		if !found {
+			var available []string
+			for _, col := range all {
+				available = append(available, col.header)
+			}
+			return nil, fmt.Errorf("unknown column %q (available: [%s])", name, strings.Join(available, ", "))
+		}
+	}
+	return selected, nil
+}
+
+func (c tableColumn) matches(name string) bool {
+	return strings.EqualFold(c.header, name) || strings.EqualFold(c.name, name)
+}
+
+// tableElements returns the struct values to render as rows, accepting either a single struct or a slice of structs.
+func tableElements(result any) ([]reflect.Value, reflect.Type, error) {
+	v := reflect.ValueOf(result)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil, fmt.Errorf("no result to show")
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return []reflect.Value{v}, v.Type(), nil
+	case reflect.Slice, reflect.Array:
+		elemType := v.Type().Elem()
+		for elemType.Kind() == reflect.Ptr {
+			elemType = elemType.Elem()
+		}
+		if elemType.Kind() != reflect.Struct {
+			return nil, nil, fmt.Errorf("unable to show %s as a table: elements must be structs", v.Type())
+		}
+		var elements []reflect.Value
+		for i := 0; i < v.Len(); i++ {
+			elem := v.Index(i)
+			for elem.Kind() == reflect.Ptr {
+				if elem.IsNil() {
+					break
+				}
+				elem = elem.Elem()
+			}
+			if elem.Kind() != reflect.Struct {
+				continue
+			}
+			elements = append(elements, elem)
+		}
+		return elements, elemType, nil
+	}
+	return nil, nil, fmt.Errorf("unable to show %T as a table", result)
+}
+
+func tableColumns(t reflect.Type) []tableColumn {
+	var tagged, untagged []tableColumn
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		tag, ok := f.Tag.Lookup("table")
+		if !ok {
+			untagged = append(untagged, tableColumn{header: strings.ToUpper(f.Name), name: f.Name, index: i})
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		if parts[0] == "-" {
+			continue
+		}
+
+		col := tableColumn{header: parts[0], name: f.Name, index: i}
+		if col.header == "" {
+			col.header = strings.ToUpper(f.Name)
+		}
+		for _, opt := range parts[1:] {
+			switch opt = strings.TrimSpace(opt); opt {
+			case "wide":
+				col.wide = true
+			case "bytes", "number":
+				col.format = opt
+			}
+		}
+		tagged = append(tagged, col)
+	}
+
+	if len(tagged) > 0 {
+		return tagged
+	}
+	return untagged
+}
+
+// tableCell returns the value to show in a cell. When human is set, durations and integers (in the column format, see
+// humanInt) are shown in human-friendly forms.
+func tableCell(v reflect.Value, format string, human bool) string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	if human {
+		if d, ok := v.Interface().(time.Duration); ok {
+			return HumanDuration(d)
+		}
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return humanInt(v.Int(), format)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return humanInt(clampInt64(v.Uint()), format)
+		}
+	}
+
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		var parts []string
+		for i := 0; i < v.Len(); i++ {
+			parts = append(parts, tableCell(v.Index(i), format, human))
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// lessRow compares rows cell by cell (see tableLess).
+func lessRow(a, b []string) bool {
+	for i := range a {
+		if i >= len(b) {
+			return false
+		}
+		if a[i] != b[i] {
+			return tableLess(a[i], b[i])
+		}
+	}
+	return len(a) < len(b)
+}
+
+// tableLess compares cells numerically when both values are numbers, otherwise lexically.
+func tableLess(a, b string) bool {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return fa < fb
+	}
+	return a < b
+}
+
+// render writes all rows as aligned columns. When a maximum width is given, the widest columns are shrunk
+// (truncating their values) until the table fits.
+func (t *table) render(maxWidth int) string {
+	widths := t.columnWidths()
+	if maxWidth > 0 {
+		fitColumnWidths(widths, maxWidth)
+	}
+
+	var sb strings.Builder
+	write := func(row []string) {
+		var cells []string
+		for i, cell := range row {
+			cell = truncateCell(cell, widths[i])
+			if i < len(row)-1 {
+				cell += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+			}
+			cells = append(cells, cell)
+		}
+		sb.WriteString(strings.TrimRight(strings.Join(cells, tableColumnSeparator), " ") + "\n")
+	}
+
+	if len(t.headers) > 0 {
+		write(t.headers)
+	}
+	for _, row := range t.rows {
+		write(row)
+	}
+	return sb.String()
+}
+
+func (t *table) columnWidths() []int {
+	var widths []int
+	for _, row := range append([][]string{t.headers}, t.rows...) {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if l := utf8.RuneCountInString(cell); l > widths[i] {
+				widths[i] = l
+			}
+		}
+	}
+	return widths
+}
+
+func fitColumnWidths(widths []int, maxWidth int) {
+	total := func() int {
+		sum := len(tableColumnSeparator) * (len(widths) - 1)
+		for _, w := range widths {
+			sum += w
+		}
+		return sum
+	}
+
+	for total() > maxWidth {
+		widest := 0
+		for i, w := range widths {
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= tableMinColumnWidth {
+			// nothing left to shrink, allow the table to wrap
+			return
+		}
+		widths[widest]--
+	}
+}
+
+func truncateCell(cell string, width int) string {
+	if utf8.RuneCountInString(cell) <= width {
+		return cell
+	}
+	ellipsis := CurrentSymbols().Ellipsis
+	n := utf8.RuneCountInString(ellipsis)
+	if width <= n {
+		return string([]rune(ellipsis)[:width])
+	}
+	runes := []rune(cell)
+	return string(runes[:width-n]) + ellipsis
+}
+
+// terminalWidth returns the width of the terminal the writer is attached to (or 0 if it is not a terminal).
+func terminalWidth(w io.Writer) int {
+	f, ok := w.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return 0
+	}
+	width, _, err := term.GetSize(int(f.Fd()))
+	if err != nil {
+		return 0
+	}
+	return width
+}
@@ -0,0 +1,187 @@
+package clio
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tableImage struct {
+	Name   string   `table:"NAME"`
+	Digest string   `table:"DIGEST,wide"`
+	Size   int      `table:"SIZE"`
+	Tags   []string `table:"TAGS"`
+	Hidden string   `table:"-"`
+}
+
+func Test_tableEncoder(t *testing.T) {
+	images := []tableImage{
+		{Name: "ubuntu", Digest: "sha256:aaa", Size: 100, Tags: []string{"latest", "22.04"}},
+		{Name: "alpine", Digest: "sha256:bbb", Size: 5, Hidden: "secret"},
+		{Name: "debian", Digest: "sha256:ccc", Size: 50},
+	}
+
+	tests := []struct {
+		name    string
+		format  string
+		cfg     TableConfig
+		result  any
+		want    string
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:   "default columns",
+			format: "table",
+			result: images,
+			want: `NAME     SIZE   TAGS
+ubuntu   100    latest,22.04
+alpine   5
+debian   50
+`,
+		},
+		{
+			name:   "wide shows all columns",
+			format: "wide",
+			result: images,
+			want: `NAME     DIGEST       SIZE   TAGS
+ubuntu   sha256:aaa   100    latest,22.04
+alpine   sha256:bbb   5
+debian   sha256:ccc   50
+`,
+		},
+		{
+			name:   "select columns (including wide ones)",
+			format: "table",
+			cfg:    TableConfig{Columns: []string{"size,digest"}},
+			result: images,
+			want: `SIZE   DIGEST
+100    sha256:aaa
+5      sha256:bbb
+50     sha256:ccc
+`,
+		},
+		{
+			name:   "sort numerically without headers",
+			format: "table",
+			cfg:    TableConfig{Columns: []string{"name", "size"}, SortBy: "size", NoHeaders: true},
+			result: images,
+			want: `alpine   5
+debian   50
+ubuntu   100
+`,
+		},
+		{
+			name:   "single struct",
+			format: "table",
+			cfg:    TableConfig{Columns: []string{"name"}},
+			result: &images[0],
+			want: `NAME
+ubuntu
+`,
+		},
+		{
+			name:   "untagged fields",
+			format: "table",
+			result: []struct {
+				Name  string
+				Count int
+			}{{Name: "a", Count: 1}},
+			want: `NAME   COUNT
+a      1
+`,
+		},
+		{
+			name:    "unknown column",
+			format:  "table",
+			cfg:     TableConfig{Columns: []string{"bogus"}},
+			result:  images,
+			wantErr: require.Error,
+		},
+		{
+			name:    "unknown sort column",
+			format:  "table",
+			cfg:     TableConfig{SortBy: "digest"},
+			result:  images,
+			wantErr: require.Error,
+		},
+		{
+			name:    "not a list of structs",
+			format:  "table",
+			result:  []string{"a"},
+			wantErr: require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+			cfg := OutputConfig{Format: tt.format, Table: tt.cfg}
+			buf := &bytes.Buffer{}
+			err := cfg.Encode(buf, tt.result)
+			tt.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func Test_table_render_fitsWidth(t *testing.T) {
+	tbl := &table{
+		headers: []string{"NAME", "DESCRIPTION"},
+		rows: [][]string{
+			{"short", "a very long description that will not fit"},
+		},
+	}
+
+	setPresentation(true, false)
+	defer setPresentation(true, false)
+	assert.Equal(t, "NAME    DESCRIPTION\nshort   a very long description…\n", tbl.render(32))
+
+	setPresentation(false, false)
+	assert.Equal(t, "NAME    DESCRIPTION\nshort   a very long descripti...\n", tbl.render(32))
+}
+
+func Test_tableEncoder_HumanValues(t *testing.T) {
+	defer setFormatting(false, func(string) string { return "" })
+	setFormatting(false, func(string) string { return "" })
+
+	type layer struct {
+		Digest   string        `table:"DIGEST"`
+		Size     int64         `table:"SIZE,bytes"`
+		Pulls    uint32        `table:"PULLS,number"`
+		Year     int           `table:"YEAR"`
+		Duration time.Duration `table:"PULL TIME"`
+	}
+	layers := []layer{
+		{Digest: "a", Size: 1536, Pulls: 1234567, Year: 2024, Duration: 3*time.Minute + 5*time.Second},
+		{Digest: "b", Size: 2 << 30, Pulls: 12, Year: 2023, Duration: 250 * time.Millisecond},
+		{Digest: "c", Size: 10, Pulls: 1000, Year: 2022, Duration: 4200 * time.Millisecond},
+	}
+
+	cfg := NewOutputConfig("table")
+	cfg.Table.SortBy = "size"
+	var buf bytes.Buffer
+	require.NoError(t, cfg.Encode(&buf, layers))
+	// sorted by the raw size, not the human-friendly text
+	assert.Equal(t, `DIGEST   SIZE      PULLS       YEAR   PULL TIME
+c        10 B      1,000       2022   4.2s
+a        1.5 KiB   1,234,567   2024   3m 5s
+b        2.0 GiB   12          2023   250ms
+`, buf.String())
+
+	buf.Reset()
+	csvCfg := NewOutputConfig("csv")
+	require.NoError(t, csvCfg.Encode(&buf, layers[:1]))
+	assert.Equal(t, "DIGEST,SIZE,PULLS,YEAR,PULL TIME\na,1536,1234567,2024,3m5s\n", buf.String())
+
+	setFormatting(true, func(string) string { return "" })
+	buf.Reset()
+	require.NoError(t, cfg.Encode(&buf, layers[:1]))
+	assert.Equal(t, "DIGEST   SIZE   PULLS     YEAR   PULL TIME\na        1536   1234567   2024   3m5s\n", buf.String())
+}
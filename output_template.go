@@ -0,0 +1,165 @@
+package clio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// newTemplateEncoder renders the result with a go template, either given inline with the format
+// (e.g. "go-template={{.Name}}") or from the --template option. The result object is the template
+// root, so the fields of the command result are accessed directly (e.g. "{{.Name}}: {{.Version}}").
+func newTemplateEncoder(cfg OutputConfig, arg string) (Encoder, error) {
+	text := arg
+	if text == "" {
+		text = cfg.Template
+	}
+	if text == "" {
+		return nil, fmt.Errorf("no template provided (use --template)")
+	}
+	return newGoTemplateEncoder("output", text)
+}
+
+// newTemplateFileEncoder renders the result with a go template read from the file given with the format
+// (e.g. "go-template-file=./report.tmpl"), falling back to treating the --template option as a path.
+func newTemplateFileEncoder(cfg OutputConfig, arg string) (Encoder, error) {
+	path := arg
+	if path == "" {
+		path = cfg.Template
+	}
+	if path == "" {
+		return nil, fmt.Errorf("no template file provided")
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read template file: %w", err)
+	}
+	return newGoTemplateEncoder(path, string(contents))
+}
+
+func newGoTemplateEncoder(name, text string) (Encoder, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs()).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse template: %w", err)
+	}
+
+	return EncoderFunc(func(w io.Writer, result any) error {
+		return tmpl.Execute(w, result)
+	}), nil
+}
+
+// templateFuncs returns a set of sprig-style helper functions available to all output templates.
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		// strings
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"title":      titleCase,
+		"trim":       strings.TrimSpace,
+		"trimAll":    func(cutset, s string) string { return strings.Trim(s, cutset) },
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"replace":    func(old, replacement, s string) string { return strings.ReplaceAll(s, old, replacement) },
+		"repeat":     func(count int, s string) string { return strings.Repeat(s, count) },
+		"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":       join,
+		"quote":      func(v any) string { return fmt.Sprintf("%q", fmt.Sprint(v)) },
+		"squote":     func(v any) string { return "'" + fmt.Sprint(v) + "'" },
+		"indent":     func(spaces int, s string) string { return indentLines(spaces, s) },
+		"nindent":    func(spaces int, s string) string { return "\n" + indentLines(spaces, s) },
+
+		// defaults and collections
+		"default": defaultValue,
+		"empty":   isEmpty,
+		"list":    func(items ...any) []any { return items },
+		"dict":    dict,
+
+		// encoding
+		"toJson":       toJSON,
+		"toPrettyJson": toPrettyJSON,
+		"toYaml":       toYAML,
+	}
+}
+
+func titleCase(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+func join(sep string, v any) string {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return fmt.Sprint(v)
+	}
+	parts := make([]string, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		parts[i] = fmt.Sprint(rv.Index(i).Interface())
+	}
+	return strings.Join(parts, sep)
+}
+
+func indentLines(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+func defaultValue(def any, given ...any) any {
+	if len(given) == 0 || isEmpty(given[0]) {
+		return def
+	}
+	return given[0]
+}
+
+func isEmpty(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.String:
+		return rv.Len() == 0
+	default:
+		return rv.IsZero()
+	}
+}
+
+func dict(pairs ...any) (map[string]any, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict requires an even number of arguments")
+	}
+	d := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		d[fmt.Sprint(pairs[i])] = pairs[i+1]
+	}
+	return d, nil
+}
+
+func toJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func toPrettyJSON(v any) (string, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	return string(b), err
+}
+
+func toYAML(v any) (string, error) {
+	b, err := yaml.Marshal(v)
+	return strings.TrimSuffix(string(b), "\n"), err
+}
@@ -0,0 +1,148 @@
+package clio
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type outputResult struct {
+	Name    string   `json:"name" yaml:"name"`
+	Version string   `json:"version" yaml:"version"`
+	Tags    []string `json:"tags" yaml:"tags"`
+}
+
+func Test_OutputConfig_Encode(t *testing.T) {
+	result := outputResult{
+		Name:    "clio",
+		Version: "1.0.0",
+		Tags:    []string{"a", "b"},
+	}
+
+	tmplFile := filepath.Join(t.TempDir(), "report.tmpl")
+	require.NoError(t, os.WriteFile(tmplFile, []byte(`{{ .Name | upper }} ({{ join "," .Tags }})`), 0600))
+
+	tests := []struct {
+		name    string
+		cfg     OutputConfig
+		want    string
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name: "default to json",
+			cfg:  OutputConfig{},
+			want: "{\n \"name\": \"clio\",\n \"version\": \"1.0.0\",\n \"tags\": [\n  \"a\",\n  \"b\"\n ]\n}\n",
+		},
+		{
+			name: "yaml",
+			cfg:  OutputConfig{Format: "yaml"},
+			want: "name: clio\nversion: 1.0.0\ntags:\n  - a\n  - b\n",
+		},
+		{
+			name: "template from flag",
+			cfg:  OutputConfig{Format: "template", Template: "{{.Name}}: {{.Version}}"},
+			want: "clio: 1.0.0",
+		},
+		{
+			name: "template inline with format",
+			cfg:  OutputConfig{Format: "go-template={{.Name}}={{ default \"none\" .Version }}"},
+			want: "clio=1.0.0",
+		},
+		{
+			name: "template file",
+			cfg:  OutputConfig{Format: "go-template-file=" + tmplFile},
+			want: "CLIO (a,b)",
+		},
+		{
+			name:    "template missing",
+			cfg:     OutputConfig{Format: "template"},
+			wantErr: require.Error,
+		},
+		{
+			name:    "template invalid",
+			cfg:     OutputConfig{Format: "template", Template: "{{ .Name "},
+			wantErr: require.Error,
+		},
+		{
+			name:    "template file missing",
+			cfg:     OutputConfig{Format: "go-template-file=" + filepath.Join(t.TempDir(), "missing")},
+			wantErr: require.Error,
+		},
+		{
+			name:    "unknown format",
+			cfg:     OutputConfig{Format: "bogus"},
+			wantErr: require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+			buf := &bytes.Buffer{}
+			err := tt.cfg.Encode(buf, result)
+			tt.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func Test_OutputConfig_WithEncoder(t *testing.T) {
+	cfg := NewOutputConfig("text").WithEncoder("text", func(_ OutputConfig, _ string) (Encoder, error) {
+		return EncoderFunc(func(w io.Writer, result any) error {
+			return nil
+		}), nil
+	})
+
+	assert.Contains(t, cfg.Formats(), "text")
+	assert.Contains(t, cfg.Formats(), "json")
+	require.NoError(t, cfg.PostLoad())
+}
+
+func Test_templateFuncs(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     any
+		want     string
+	}{
+		{
+			name:     "string helpers",
+			template: `{{ "hello world" | title }} {{ trim "  x  " }} {{ replace "a" "b" "aaa" }}`,
+			want:     "Hello World x bbb",
+		},
+		{
+			name:     "default",
+			template: `{{ default "fallback" .Missing }}`,
+			data:     map[string]any{"Missing": ""},
+			want:     "fallback",
+		},
+		{
+			name:     "dict and json",
+			template: `{{ dict "a" 1 | toJson }}`,
+			want:     `{"a":1}`,
+		},
+		{
+			name:     "indent",
+			template: `{{ "a\nb" | indent 2 }}`,
+			want:     "  a\n  b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := newGoTemplateEncoder("test", tt.template)
+			require.NoError(t, err)
+			buf := &bytes.Buffer{}
+			require.NoError(t, enc.Encode(buf, tt.data))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
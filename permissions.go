@@ -0,0 +1,136 @@
+package clio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/boss-net/fangs"
+)
+
+const (
+	defaultFileMode os.FileMode = 0o600
+	defaultDirMode  os.FileMode = 0o700
+)
+
+// PermissionsConfig is the policy for the modes of all files and directories written by clio (e.g. temp directories
+// and log files) and by the application through State.CreateFile and State.MkdirAll. The modes are applied exactly,
+// regardless of the process umask.
+type PermissionsConfig struct {
+	FileMode string `yaml:"file-mode" json:"file-mode" mapstructure:"file-mode"` // octal mode for created files (default: 0600)
+	DirMode  string `yaml:"dir-mode" json:"dir-mode" mapstructure:"dir-mode"`    // octal mode for created directories (default: 0700)
+
+	fileMode os.FileMode
+	dirMode  os.FileMode
+}
+
+var _ interface {
+	fangs.FieldDescriber
+	fangs.PostLoader
+} = (*PermissionsConfig)(nil)
+
+func (c *PermissionsConfig) DescribeFields(set fangs.FieldDescriptionSet) {
+	set.Add(&c.FileMode, "octal mode for files written by the application (e.g. 0640)")
+	set.Add(&c.DirMode, "octal mode for directories created by the application (e.g. 0750)")
+}
+
+func (c *PermissionsConfig) PostLoad() error {
+	var err error
+	if c.fileMode, err = parseMode(c.FileMode, defaultFileMode, 0o600); err != nil {
+		return fmt.Errorf("invalid file-mode: %w", err)
+	}
+	if c.dirMode, err = parseMode(c.DirMode, defaultDirMode, 0o700); err != nil {
+		return fmt.Errorf("invalid dir-mode: %w", err)
+	}
+	return nil
+}
+
+// parseMode parses an octal mode, which must grant at least the given owner permissions (otherwise the application
+// would not be able to use what it creates) and must not be world-writable.
+func parseMode(value string, def, required os.FileMode) (os.FileMode, error) {
+	if value == "" {
+		return def, nil
+	}
+	m, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || m > 0o777 {
+		return 0, fmt.Errorf("%q is not an octal permission mode (e.g. %04o)", value, def)
+	}
+	mode := os.FileMode(m)
+	if mode&required != required {
+		return 0, fmt.Errorf("%04o must include the owner permissions %04o", mode, required)
+	}
+	if mode&0o002 != 0 {
+		return 0, fmt.Errorf("%04o must not be world-writable", mode)
+	}
+	return mode, nil
+}
+
+func (c *PermissionsConfig) modes() (file, dir os.FileMode) {
+	file, dir = defaultFileMode, defaultDirMode
+	if c == nil {
+		return file, dir
+	}
+	if c.fileMode != 0 {
+		file = c.fileMode
+	}
+	if c.dirMode != 0 {
+		dir = c.dirMode
+	}
+	return file, dir
+}
+
+// CreateFile creates (or truncates) the file at the given path (see ResolvePath), creating any parent directories,
+// following the configured permissions policy.
+func (s *State) CreateFile(path string) (*os.File, error) {
+	path = s.ResolvePath(path)
+	if err := s.MkdirAll(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	fileMode, _ := s.currentConfig().Permissions.modes()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileMode)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create file: %w", err)
+	}
+	if err := f.Chmod(fileMode); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("unable to set file permissions: %w", err)
+	}
+	return f, nil
+}
+
+// MkdirAll creates the directory at the given path (see ResolvePath) along with any parents, following the
+// configured permissions policy for all directories it creates.
+func (s *State) MkdirAll(path string) error {
+	_, dirMode := s.currentConfig().Permissions.modes()
+	return mkdirAll(s.ResolvePath(path), dirMode)
+}
+
+// mkdirAll is like os.MkdirAll, but sets the exact mode (ignoring umask) on the directories it creates.
+func mkdirAll(path string, mode os.FileMode) error {
+	if fi, err := os.Stat(path); err == nil {
+		if !fi.IsDir() {
+			return fmt.Errorf("unable to create directory %q: not a directory", path)
+		}
+		return nil
+	}
+
+	if parent := filepath.Dir(path); parent != path {
+		if err := mkdirAll(parent, mode); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Mkdir(path, mode); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("unable to create directory: %w", err)
+	}
+	return chmod(path, mode)
+}
+
+func chmod(path string, mode os.FileMode) error {
+	if err := os.Chmod(path, mode); err != nil {
+		return fmt.Errorf("unable to set permissions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package clio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PermissionsConfig_PostLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      PermissionsConfig
+		wantFile os.FileMode
+		wantDir  os.FileMode
+		wantErr  require.ErrorAssertionFunc
+	}{
+		{
+			name:     "secure defaults",
+			wantFile: 0o600,
+			wantDir:  0o700,
+		},
+		{
+			name:     "custom modes",
+			cfg:      PermissionsConfig{FileMode: "0640", DirMode: "750"},
+			wantFile: 0o640,
+			wantDir:  0o750,
+		},
+		{
+			name:    "not octal",
+			cfg:     PermissionsConfig{FileMode: "rw-r--r--"},
+			wantErr: require.Error,
+		},
+		{
+			name:    "out of range",
+			cfg:     PermissionsConfig{DirMode: "7777"},
+			wantErr: require.Error,
+		},
+		{
+			name:    "world writable",
+			cfg:     PermissionsConfig{FileMode: "0666"},
+			wantErr: require.Error,
+		},
+		{
+			name:    "owner cannot write",
+			cfg:     PermissionsConfig{FileMode: "0400"},
+			wantErr: require.Error,
+		},
+		{
+			name:    "owner cannot traverse directory",
+			cfg:     PermissionsConfig{DirMode: "0600"},
+			wantErr: require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+			err := tt.cfg.PostLoad()
+			tt.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			file, dir := tt.cfg.modes()
+			assert.Equal(t, tt.wantFile, file)
+			assert.Equal(t, tt.wantDir, dir)
+		})
+	}
+}
+
+func Test_State_CreateFile(t *testing.T) {
+	cfg := &PermissionsConfig{FileMode: "0640", DirMode: "0750"}
+	require.NoError(t, cfg.PostLoad())
+
+	s := &State{Config: Config{Permissions: cfg}}
+
+	path := filepath.Join(t.TempDir(), "reports", "nested", "report.json")
+	f, err := s.CreateFile(path)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assertMode(t, path, 0o640)
+	assertMode(t, filepath.Dir(path), 0o750)
+	assertMode(t, filepath.Dir(filepath.Dir(path)), 0o750)
+}
+
+func Test_State_TempDir_permissions(t *testing.T) {
+	cfg := &PermissionsConfig{DirMode: "0750"}
+	require.NoError(t, cfg.PostLoad())
+
+	s := &State{Config: Config{
+		Permissions: cfg,
+		Temp:        &TempConfig{Root: filepath.Join(t.TempDir(), "root")},
+	}}
+
+	dir, err := s.TempDir()
+	require.NoError(t, err)
+	defer s.removeTempDirs()
+
+	assertMode(t, dir, 0o750)
+	assertMode(t, filepath.Dir(dir), 0o750)
+	assertMode(t, s.Config.Temp.Root, 0o750)
+}
+
+func Test_createLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	require.NoError(t, createLogFile(path, nil))
+	assertMode(t, path, 0o600)
+	assertMode(t, filepath.Dir(path), 0o700)
+}
+
+func assertMode(t *testing.T, path string, want os.FileMode) {
+	t.Helper()
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, want, fi.Mode().Perm(), "unexpected mode for %q", path)
+}
@@ -0,0 +1,100 @@
+package clio
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Phase is a stage of the lifecycle of a command run, which libraries can check (see PhaseFromContext) to behave
+// differently within each (e.g. not prompting during teardown).
+type Phase string
+
+const (
+	// PhaseSetup is loading the configuration and setting up resources (including running initializers).
+	PhaseSetup Phase = "setup"
+	// PhaseRun is running the command itself.
+	PhaseRun Phase = "run"
+	// PhaseTeardown is everything after the command completes (e.g. running finalizers), even when it was cancelled.
+	PhaseTeardown Phase = "teardown"
+)
+
+type phaseKey struct{}
+
+// PhaseFromContext returns the lifecycle phase of the contexts derived from those given by the application (the command
+// context, and State.Context), which is empty for other contexts.
+func PhaseFromContext(ctx context.Context) Phase {
+	if ctx == nil {
+		return ""
+	}
+	phase, _ := ctx.Value(phaseKey{}).(Phase)
+	return phase
+}
+
+// withPhase returns a context within the given phase.
+func withPhase(ctx context.Context, phase Phase) context.Context {
+	return context.WithValue(ctx, phaseKey{}, phase)
+}
+
+// WithPhaseTimeout bounds how long operations using the context of the given phase may take. The setup and teardown
+// phases bound operations using State.Context (e.g. within initializers and finalizers), and the run phase bounds the
+// command itself (see WithRunTimeout).
+func (c *SetupConfig) WithPhaseTimeout(phase Phase, timeout time.Duration) *SetupConfig {
+	if phase == PhaseRun {
+		return c.WithRunTimeout(timeout)
+	}
+	if c.PhaseTimeouts == nil {
+		c.PhaseTimeouts = make(map[Phase]time.Duration)
+	}
+	c.PhaseTimeouts[phase] = timeout
+	return c
+}
+
+// phaseState holds the context of the current lifecycle phase (see State.Context).
+type phaseState struct {
+	lock   sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Context returns the context of the current lifecycle phase (see PhaseFromContext), for use where none is given
+// (e.g. within initializers and finalizers).
+func (s *State) Context() context.Context {
+	s.phase.lock.Lock()
+	defer s.phase.lock.Unlock()
+	if s.phase.ctx == nil {
+		return context.Background()
+	}
+	return s.phase.ctx
+}
+
+// enterPhase returns the context of the given phase (derived from the parent), which is done after the timeout when
+// positive (see SetupConfig.WithPhaseTimeout), and in that case also once the next phase is entered.
+func (s *State) enterPhase(parent context.Context, phase Phase, timeout time.Duration) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx := withPhase(parent, phase)
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	s.phase.lock.Lock()
+	defer s.phase.lock.Unlock()
+	if s.phase.cancel != nil {
+		s.phase.cancel()
+	}
+	s.phase.ctx, s.phase.cancel = ctx, cancel
+	return ctx
+}
+
+// exitPhase ends the current phase.
+func (s *State) exitPhase() {
+	s.phase.lock.Lock()
+	defer s.phase.lock.Unlock()
+	if s.phase.cancel != nil {
+		s.phase.cancel()
+	}
+	s.phase.ctx, s.phase.cancel = nil, nil
+}
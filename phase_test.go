@@ -0,0 +1,71 @@
+package clio
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_State_enterPhase(t *testing.T) {
+	s := &State{}
+	assert.Equal(t, Phase(""), PhaseFromContext(s.Context()))
+
+	setup := s.enterPhase(context.Background(), PhaseSetup, time.Minute)
+	assert.Equal(t, PhaseSetup, PhaseFromContext(setup))
+	assert.Equal(t, PhaseSetup, PhaseFromContext(s.Context()))
+	_, ok := setup.Deadline()
+	assert.True(t, ok)
+
+	run := s.enterPhase(context.Background(), PhaseRun, 0)
+	assert.Equal(t, PhaseRun, PhaseFromContext(run))
+	// the bounded setup phase is done once over
+	assert.Error(t, setup.Err())
+
+	teardown := s.enterPhase(context.Background(), PhaseTeardown, time.Millisecond)
+	<-teardown.Done()
+	assert.Equal(t, PhaseTeardown, PhaseFromContext(s.Context()))
+	assert.NoError(t, run.Err())
+
+	s.exitPhase()
+	assert.Equal(t, Phase(""), PhaseFromContext(s.Context()))
+	assert.Equal(t, Phase(""), PhaseFromContext(context.Background()))
+}
+
+func Test_SetupConfig_WithPhaseTimeout(t *testing.T) {
+	cfg := NewSetupConfig(Identification{Name: "app"}).
+		WithPhaseTimeout(PhaseTeardown, time.Second).
+		WithPhaseTimeout(PhaseRun, time.Minute)
+	assert.Equal(t, map[Phase]time.Duration{PhaseTeardown: time.Second}, cfg.PhaseTimeouts)
+	assert.Equal(t, time.Minute, cfg.RunTimeout)
+}
+
+func Test_application_phases(t *testing.T) {
+	var initPhase, runPhase, finalPhase Phase
+	cfg := NewSetupConfig(Identification{Name: "app"}).
+		WithNoBus().
+		WithInitializers(func(s *State) error {
+			initPhase = PhaseFromContext(s.Context())
+			return nil
+		}).
+		WithFinalizers(func(s *State, _ RunSummary) error {
+			finalPhase = PhaseFromContext(s.Context())
+			return nil
+		})
+	app := New(*cfg)
+	root := app.SetupRootCommand(&cobra.Command{
+		RunE: app.RunWithState(func(ctx context.Context, _ *State, _ []string) error {
+			runPhase = PhaseFromContext(ctx)
+			return nil
+		}),
+	})
+	root.SetArgs([]string{})
+	require.NoError(t, root.Execute())
+
+	assert.Equal(t, PhaseSetup, initPhase)
+	assert.Equal(t, PhaseRun, runPhase)
+	assert.Equal(t, PhaseTeardown, finalPhase)
+}
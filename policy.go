@@ -0,0 +1,288 @@
+package clio
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/boss-net/fangs"
+	"go.starlark.net/starlark"
+)
+
+// PolicyEvaluator decides whether an action is allowed at a decision point (see SetupConfig.WithPolicyPoints), e.g.
+// a policy engine. Applications may provide their own with SetupConfig.WithPolicyEvaluator.
+type PolicyEvaluator interface {
+	// Evaluate returns the verdict of the policy for the input, or nil when the policy has no verdict for the point.
+	Evaluate(ctx context.Context, point string, input any) (*PolicyVerdict, error)
+}
+
+// PolicyEvaluatorFunc is an adapter to allow the use of ordinary functions as a PolicyEvaluator.
+type PolicyEvaluatorFunc func(ctx context.Context, point string, input any) (*PolicyVerdict, error)
+
+func (f PolicyEvaluatorFunc) Evaluate(ctx context.Context, point string, input any) (*PolicyVerdict, error) {
+	return f(ctx, point, input)
+}
+
+// PolicyVerdict is the verdict of a single policy.
+type PolicyVerdict struct {
+	Policy  string // the policy that gave the verdict (e.g. the path of the policy file)
+	Allowed bool
+	Reasons []string // why the action is denied
+}
+
+// PolicyDecision is the decision made at a decision point from the verdicts of all policies (see State.Decide): the
+// action is allowed unless any policy denies it.
+type PolicyDecision struct {
+	Point    string   `json:"point" yaml:"point"`
+	Allowed  bool     `json:"allowed" yaml:"allowed"`
+	Reasons  []string `json:"reasons,omitempty" yaml:"reasons,omitempty"`   // why the action is denied
+	Policies []string `json:"policies,omitempty" yaml:"policies,omitempty"` // the policies that gave a verdict
+}
+
+// PolicyConfig is the user-facing configuration of the policies evaluated at decision points (see
+// SetupConfig.WithPolicyPoints).
+type PolicyConfig struct {
+	Files   []string `yaml:"files" json:"files" mapstructure:"files"`       // .rego or .star files
+	Bundle  string   `yaml:"bundle" json:"bundle" mapstructure:"bundle"`    // an OPA bundle (a directory or .tar.gz)
+	Server  string   `yaml:"server" json:"server" mapstructure:"server"`    // the URL of an OPA server to query
+	Package string   `yaml:"package" json:"package" mapstructure:"package"` // the rego package of the decisions (default: the application name)
+}
+
+var _ interface {
+	fangs.FieldDescriber
+	fangs.PostLoader
+} = (*PolicyConfig)(nil)
+
+func (c *PolicyConfig) DescribeFields(set fangs.FieldDescriptionSet) {
+	set.Add(&c.Files, "policy files: rego (.rego) or starlark (.star, with a function for each decision point)")
+	set.Add(&c.Bundle, "an OPA bundle of rego policies (a directory or .tar.gz)")
+	set.Add(&c.Server, "the URL of an OPA server to query for decisions")
+	set.Add(&c.Package, "the rego package with a rule for each decision point (default: the application name)")
+}
+
+func (c *PolicyConfig) PostLoad() error {
+	for _, file := range c.Files {
+		switch filepath.Ext(file) {
+		case ".rego", hookScriptExt:
+		default:
+			return fmt.Errorf("invalid policy file %q: expected a .rego or %s file", file, hookScriptExt)
+		}
+	}
+	if c.Server != "" {
+		u, err := url.Parse(c.Server)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid policy.server %q: expected an http(s) URL", c.Server)
+		}
+	}
+	return nil
+}
+
+// policyDecisions are the decisions made during the run.
+type policyDecisions struct {
+	lock    sync.Mutex
+	entries []PolicyDecision
+}
+
+// setupPolicies determines the policies to evaluate, when the application has registered decision points (see
+// SetupConfig.WithPolicyPoints): those of the application, then starlark policy files, then rego policy files (and
+// the bundle), then the OPA server.
+func (s *State) setupPolicies(points []string, evaluators []PolicyEvaluator) error {
+	s.policyPoints = points
+	s.policies = nil
+	if len(points) == 0 {
+		return nil
+	}
+	s.policies = append(s.policies, evaluators...)
+
+	cfg := s.Config.Policy
+	if cfg == nil {
+		return nil
+	}
+	pkg := cfg.Package
+	if pkg == "" {
+		pkg = strings.ReplaceAll(s.id.Name, "-", "_")
+	}
+
+	var rego []string
+	for _, file := range cfg.Files {
+		path := s.ResolvePath(file)
+		if filepath.Ext(path) == ".rego" {
+			rego = append(rego, path)
+			continue
+		}
+		policy, err := s.loadScriptPolicy(path)
+		if err != nil {
+			return err
+		}
+		s.policies = append(s.policies, policy)
+	}
+	if len(rego) > 0 || cfg.Bundle != "" {
+		bundle := cfg.Bundle
+		if bundle != "" {
+			bundle = s.ResolvePath(bundle)
+		}
+		policy, err := newRegoEval(rego, bundle, pkg, points)
+		if err != nil {
+			return err
+		}
+		s.policies = append(s.policies, policy)
+	}
+	if cfg.Server != "" {
+		s.policies = append(s.policies, &opaServer{state: s, server: cfg.Server, pkg: pkg})
+	}
+	return nil
+}
+
+// Decide evaluates all policies for the action at the decision point (see SetupConfig.WithPolicyPoints), e.g.
+// "should this finding fail the build?", with the input describing the action. The action is allowed unless any
+// policy denies it (so it is allowed when there are no policies). Decisions are logged, and surfaced with the result
+// (see State.Encode) and the run summary (see RunSummary).
+func (s *State) Decide(ctx context.Context, point string, input any) (PolicyDecision, error) {
+	decision := PolicyDecision{Point: point, Allowed: true}
+	if !contains(s.policyPoints, point) {
+		return decision, fmt.Errorf("unknown decision point %q (see SetupConfig.WithPolicyPoints)", point)
+	}
+	for _, policy := range s.policies {
+		verdict, err := policy.Evaluate(ctx, point, input)
+		if err != nil {
+			return decision, fmt.Errorf("unable to decide %s: %w", point, err)
+		}
+		if verdict == nil {
+			continue
+		}
+		decision.Policies = append(decision.Policies, verdict.Policy)
+		if !verdict.Allowed {
+			decision.Allowed = false
+			decision.Reasons = append(decision.Reasons, verdict.Reasons...)
+		}
+	}
+	if len(decision.Policies) == 0 {
+		return decision, nil
+	}
+
+	s.decisions.lock.Lock()
+	s.decisions.entries = append(s.decisions.entries, decision)
+	s.decisions.lock.Unlock()
+	if log := s.currentLogger(); log != nil {
+		fields := []any{"point", point, "policies", strings.Join(decision.Policies, ", ")}
+		if decision.Allowed {
+			log.WithFields(fields...).Debug("policy allowed")
+		} else {
+			log.WithFields(append(fields, "reasons", strings.Join(decision.Reasons, "; "))...).Info("policy denied")
+		}
+	}
+	return decision, nil
+}
+
+// PolicyDecisions returns the decisions made so far in the run (by policies, see State.Decide), in order.
+func (s *State) PolicyDecisions() []PolicyDecision {
+	s.decisions.lock.Lock()
+	defer s.decisions.lock.Unlock()
+	return append([]PolicyDecision(nil), s.decisions.entries...)
+}
+
+// policyVerdict interprets the value of a policy rule (or the result of a starlark policy function): a bool (whether
+// the action is allowed), a list of reasons the action is denied (allowed when empty), or an object with "allow"
+// and/or "deny" (the reasons). Undefined rules (nil) have no verdict.
+func policyVerdict(policy string, value any) (*PolicyVerdict, error) {
+	verdict := &PolicyVerdict{Policy: policy, Allowed: true}
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		verdict.Allowed = v
+	case []any:
+		verdict.Reasons = policyReasons(v)
+		verdict.Allowed = len(verdict.Reasons) == 0
+	case map[string]any:
+		if allow, ok := v["allow"]; ok {
+			b, ok := allow.(bool)
+			if !ok {
+				return nil, fmt.Errorf("invalid decision from %s: allow must be a bool", policy)
+			}
+			verdict.Allowed = b
+		}
+		if deny, ok := v["deny"]; ok {
+			reasons, ok := deny.([]any)
+			if !ok {
+				return nil, fmt.Errorf("invalid decision from %s: deny must be a list of reasons", policy)
+			}
+			verdict.Reasons = policyReasons(reasons)
+			verdict.Allowed = verdict.Allowed && len(verdict.Reasons) == 0
+		}
+	default:
+		return nil, fmt.Errorf("invalid decision from %s: expected a bool, a list of reasons, or an object with allow or deny", policy)
+	}
+	if !verdict.Allowed && len(verdict.Reasons) == 0 {
+		verdict.Reasons = []string{"denied by " + filepath.Base(policy)}
+	}
+	return verdict, nil
+}
+
+// policyReasons returns the reasons as strings (or the "msg" of objects, as is conventional for rego deny rules),
+// sorted since rego sets are unordered.
+func policyReasons(values []any) []string {
+	var reasons []string
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			reasons = append(reasons, v)
+		case map[string]any:
+			if msg, ok := v["msg"].(string); ok {
+				reasons = append(reasons, msg)
+				continue
+			}
+			reasons = append(reasons, fmt.Sprint(v))
+		default:
+			reasons = append(reasons, fmt.Sprint(v))
+		}
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// scriptPolicy is a starlark policy file, with a function for each decision point it decides.
+type scriptPolicy struct {
+	path   string
+	module *scriptModule
+}
+
+func (s *State) loadScriptPolicy(path string) (*scriptPolicy, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read policy: %w", err)
+	}
+	app := newScriptDict(map[string]string{"name": s.id.Name, "version": s.id.Version})
+
+	log := s.currentLogger()
+	module, err := loadScript(context.Background(), path, string(contents), starlark.StringDict{"app": app}, func(msg string) {
+		if log != nil {
+			log.WithFields("policy", path).Debug(msg)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to load policy: %w", err)
+	}
+	return &scriptPolicy{path: path, module: module}, nil
+}
+
+func (p *scriptPolicy) Evaluate(ctx context.Context, point string, input any) (*PolicyVerdict, error) {
+	fn, ok := p.module.function(point)
+	if !ok {
+		return nil, nil
+	}
+	doc, err := normalizeJSON(input)
+	if err != nil {
+		return nil, err
+	}
+	value, err := p.module.call(ctx, fn, toScriptValue(doc))
+	if err != nil {
+		return nil, err
+	}
+	return policyVerdict(p.path, fromScriptValue(value))
+}
@@ -0,0 +1,115 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// regoEval evaluates rego policy files (and bundles) within the application, querying the rule named after the
+// decision point within the package (e.g. data.app["fail_build"]). The policies are compiled once for each decision
+// point, when the policies are set up.
+type regoEval struct {
+	files   []string
+	bundle  string
+	queries map[string]rego.PreparedEvalQuery
+}
+
+// newRegoEval compiles the policies for each of the decision points.
+func newRegoEval(files []string, bundle, pkg string, points []string) (*regoEval, error) {
+	e := &regoEval{files: files, bundle: bundle, queries: map[string]rego.PreparedEvalQuery{}}
+	for _, point := range points {
+		opts := []func(*rego.Rego){rego.Query("data." + pkg + "[" + strconv.Quote(point) + "]")}
+		if len(files) > 0 {
+			opts = append(opts, rego.Load(files, nil))
+		}
+		if bundle != "" {
+			opts = append(opts, rego.LoadBundle(bundle))
+		}
+		query, err := rego.New(opts...).PrepareForEval(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("unable to load rego policies: %w", err)
+		}
+		e.queries[point] = query
+	}
+	return e, nil
+}
+
+func (e *regoEval) Evaluate(ctx context.Context, point string, input any) (*PolicyVerdict, error) {
+	query, ok := e.queries[point]
+	if !ok {
+		return nil, nil
+	}
+	results, err := query.Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 || len(results[0].Expressions) == 0 {
+		// the rule is undefined
+		return nil, nil
+	}
+	return policyVerdict(e.policyName(), results[0].Expressions[0].Value)
+}
+
+func (e *regoEval) policyName() string {
+	sources := append([]string(nil), e.files...)
+	if e.bundle != "" {
+		sources = append(sources, e.bundle)
+	}
+	return strings.Join(sources, ", ")
+}
+
+// opaServer queries the data API of an OPA server, for the rule named after the decision point within the package
+// (e.g. POST /v1/data/app/fail_build), sent with the client from State.HTTPClient so that the network policy is
+// enforced.
+type opaServer struct {
+	state  *State
+	server string
+	pkg    string
+}
+
+func (o *opaServer) Evaluate(ctx context.Context, point string, input any) (*PolicyVerdict, error) {
+	body, err := json.Marshal(map[string]any{"input": input})
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode policy input: %w", err)
+	}
+	target := strings.TrimSuffix(o.server, "/") + "/v1/data/" + strings.ReplaceAll(o.pkg, ".", "/") + "/" + point
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.state.HTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	contents, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	var out struct {
+		Result  any    `json:"result"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(contents, &out); err != nil && resp.StatusCode < 300 {
+		return nil, fmt.Errorf("unable to read the response of the OPA server: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		if out.Message != "" {
+			return nil, fmt.Errorf("OPA server: %s: %s", resp.Status, out.Message)
+		}
+		return nil, errors.New("OPA server: " + resp.Status)
+	}
+	return policyVerdict(o.server, out.Result)
+}
@@ -0,0 +1,168 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func policyState(t *testing.T, cfg PolicyConfig, evaluators ...PolicyEvaluator) *State {
+	t.Helper()
+	s := &State{
+		id:     Identification{Name: "app", Version: "1.0"},
+		Config: Config{Network: &NetworkConfig{}, Policy: &cfg},
+	}
+	require.NoError(t, s.setupPolicies([]string{"fail_build", "allow_upload"}, evaluators))
+	return s
+}
+
+func Test_State_Decide_starlark(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.star")
+	require.NoError(t, os.WriteFile(path, []byte(`
+def fail_build(finding):
+    if finding["severity"] == "critical":
+        return ["critical finding in " + finding["path"]]
+    return []
+`), 0o600))
+
+	app := PolicyEvaluatorFunc(func(_ context.Context, point string, input any) (*PolicyVerdict, error) {
+		if point != "fail_build" || input.(map[string]string)["path"] != "vendor/x.go" {
+			return nil, nil
+		}
+		return &PolicyVerdict{Policy: "app", Allowed: false, Reasons: []string{"vendored code is not allowed"}}, nil
+	})
+	s := policyState(t, PolicyConfig{Files: []string{path}}, app)
+
+	decision, err := s.Decide(context.Background(), "fail_build", map[string]string{"path": "main.go", "severity": "low"})
+	require.NoError(t, err)
+	assert.Equal(t, PolicyDecision{Point: "fail_build", Allowed: true, Policies: []string{path}}, decision)
+
+	decision, err = s.Decide(context.Background(), "fail_build", map[string]string{"path": "vendor/x.go", "severity": "critical"})
+	require.NoError(t, err)
+	assert.Equal(t, PolicyDecision{
+		Point:    "fail_build",
+		Allowed:  false,
+		Reasons:  []string{"vendored code is not allowed", "critical finding in vendor/x.go"},
+		Policies: []string{"app", path},
+	}, decision)
+
+	// policies without a verdict for the point are not recorded
+	decision, err = s.Decide(context.Background(), "allow_upload", nil)
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Empty(t, decision.Policies)
+	assert.Len(t, s.PolicyDecisions(), 2)
+
+	_, err = s.Decide(context.Background(), "deploy", nil)
+	require.EqualError(t, err, `unknown decision point "deploy" (see SetupConfig.WithPolicyPoints)`)
+
+	// decisions are included in json output
+	var buf bytes.Buffer
+	require.NoError(t, s.Encode(&buf, OutputConfig{Format: "json"}, "ok"))
+	var out ResultWithWarnings
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	assert.Equal(t, s.PolicyDecisions(), out.Decisions)
+}
+
+func Test_policyVerdict(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    *PolicyVerdict
+		wantErr string
+	}{
+		{name: "undefined", value: nil, want: nil},
+		{name: "allowed", value: true, want: &PolicyVerdict{Policy: "p.rego", Allowed: true}},
+		{name: "denied", value: false, want: &PolicyVerdict{Policy: "p.rego", Reasons: []string{"denied by p.rego"}}},
+		{name: "no reasons", value: []any{}, want: &PolicyVerdict{Policy: "p.rego", Allowed: true}},
+		{name: "reasons", value: []any{"b", map[string]any{"msg": "a"}}, want: &PolicyVerdict{Policy: "p.rego", Reasons: []string{"a", "b"}}},
+		{name: "allow and deny", value: map[string]any{"allow": true, "deny": []any{"x"}}, want: &PolicyVerdict{Policy: "p.rego", Reasons: []string{"x"}}},
+		{name: "allow only", value: map[string]any{"allow": true}, want: &PolicyVerdict{Policy: "p.rego", Allowed: true}},
+		{name: "invalid allow", value: map[string]any{"allow": "yes"}, wantErr: "invalid decision from p.rego: allow must be a bool"},
+		{name: "invalid", value: "yes", wantErr: "invalid decision from p.rego: expected a bool, a list of reasons, or an object with allow or deny"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := policyVerdict("p.rego", tt.value)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_State_Decide_opaServer(t *testing.T) {
+	var paths, inputs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		paths = append(paths, r.URL.Path)
+		inputs = append(inputs, string(body))
+		if strings.HasSuffix(r.URL.Path, "/allow_upload") {
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"result": {"deny": [{"msg": "too many findings"}]}}`))
+	}))
+	defer server.Close()
+
+	s := policyState(t, PolicyConfig{Server: server.URL, Package: "ci.scan"})
+	decision, err := s.Decide(context.Background(), "fail_build", map[string]int{"findings": 12})
+	require.NoError(t, err)
+	assert.Equal(t, PolicyDecision{Point: "fail_build", Reasons: []string{"too many findings"}, Policies: []string{server.URL}}, decision)
+
+	decision, err = s.Decide(context.Background(), "allow_upload", nil)
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed, "undefined rules have no verdict")
+
+	assert.Equal(t, []string{"/v1/data/ci/scan/fail_build", "/v1/data/ci/scan/allow_upload"}, paths)
+	assert.Equal(t, `{"input":{"findings":12}}`, inputs[0])
+}
+
+func Test_State_Decide_rego(t *testing.T) {
+	dir := t.TempDir()
+	policy := filepath.Join(dir, "policy.rego")
+	require.NoError(t, os.WriteFile(policy, []byte(`package app
+
+import future.keywords.contains
+import future.keywords.if
+
+fail_build contains "too many findings" if input.findings > 10
+`), 0o600))
+
+	s := policyState(t, PolicyConfig{Files: []string{policy}})
+	decision, err := s.Decide(context.Background(), "fail_build", map[string]int{"findings": 12})
+	require.NoError(t, err)
+	assert.Equal(t, PolicyDecision{Point: "fail_build", Reasons: []string{"too many findings"}, Policies: []string{policy}}, decision)
+
+	decision, err = s.Decide(context.Background(), "fail_build", map[string]int{"findings": 1})
+	require.NoError(t, err)
+	assert.Equal(t, PolicyDecision{Point: "fail_build", Allowed: true, Policies: []string{policy}}, decision)
+
+	decision, err = s.Decide(context.Background(), "allow_upload", nil)
+	require.NoError(t, err)
+	assert.Empty(t, decision.Policies, "undefined rules have no verdict")
+
+	invalid := filepath.Join(dir, "invalid.rego")
+	require.NoError(t, os.WriteFile(invalid, []byte("package app\n\nfail_build {\n"), 0o600))
+	err = (&State{Config: Config{Policy: &PolicyConfig{Files: []string{invalid}}}}).setupPolicies([]string{"fail_build"}, nil)
+	require.ErrorContains(t, err, "unable to load rego policies")
+}
+
+func Test_PolicyConfig_PostLoad(t *testing.T) {
+	require.NoError(t, (&PolicyConfig{Files: []string{"a.rego", "b.star"}, Server: "http://localhost:8181"}).PostLoad())
+	require.EqualError(t, (&PolicyConfig{Files: []string{"a.json"}}).PostLoad(), `invalid policy file "a.json": expected a .rego or .star file`)
+	require.EqualError(t, (&PolicyConfig{Server: "localhost:8181"}).PostLoad(), `invalid policy.server "localhost:8181": expected an http(s) URL`)
+}
@@ -0,0 +1,557 @@
+package clio
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wagoodman/go-partybus"
+)
+
+// PrefetchEvent is published on the bus as a resource is prefetched (see SetupConfig.WithPrefetch), with a
+// PrefetchProgress as the event value.
+const PrefetchEvent partybus.EventType = "clio-prefetch"
+
+// PrefetchProgress is a snapshot of a resource being prefetched, published as the value of each PrefetchEvent.
+type PrefetchProgress struct {
+	Resource string `json:"resource"`
+	Written  int64  `json:"written"`
+	Size     int64  `json:"size"` // -1 when not known
+	Done     bool   `json:"done"`
+	Error    string `json:"error,omitempty"` // set when done and failed (redacted)
+}
+
+// PrefetchResource is a resource the application can download into its cache ahead of time (e.g. a vulnerability
+// database or an index), so that commands run later do not wait for it (or can run without network access).
+type PrefetchResource struct {
+	// Name identifies the resource within the cache and bundles (e.g. "vulnerability-db").
+	Name string
+	// Description is shown when listing the resources.
+	Description string
+	// URL is where the resource is downloaded from (with the client from State.HTTPClient).
+	URL string
+	// SHA256 is the expected (hex) digest of the resource, which is verified when it is downloaded or imported. When
+	// empty, the digest of the download is recorded instead, and verified on import.
+	SHA256 string
+	// Verify optionally checks the downloaded file further (e.g. that a database opens) before it is cached.
+	Verify func(path string) error
+}
+
+const (
+	// prefetchDir is the directory within the cache dir holding prefetched resources.
+	prefetchDir = "prefetch"
+	// prefetchManifestFile describes the prefetched resources, within the prefetch dir and bundles.
+	prefetchManifestFile = "manifest.json"
+	// prefetchProgressInterval is how many bytes are downloaded between progress events.
+	prefetchProgressInterval = 256 << 10
+)
+
+// prefetchManifest describes prefetched resources.
+type prefetchManifest struct {
+	Resources map[string]prefetchedResource `json:"resources"`
+}
+
+// prefetchedResource describes a resource in the cache (or a bundle).
+type prefetchedResource struct {
+	SHA256  string    `json:"sha256"`
+	Size    int64     `json:"size"`
+	URL     string    `json:"url,omitempty"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// Prefetched returns the path of the prefetched resource in the cache (see SetupConfig.WithPrefetch), indicating
+// whether it has been prefetched.
+func (s *State) Prefetched(name string) (string, bool) {
+	dir, err := s.prefetchDir()
+	if err != nil {
+		return "", false
+	}
+	manifest, err := readPrefetchManifest(dir)
+	if err != nil {
+		return "", false
+	}
+	if _, ok := manifest.Resources[name]; !ok {
+		return "", false
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+func (s *State) prefetchDir() (string, error) {
+	dir, err := s.cacheDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine cache dir: %w", err)
+	}
+	return filepath.Join(dir, prefetchDir), nil
+}
+
+// writablePrefetchDir returns the prefetch dir, which must be writable.
+func (s *State) writablePrefetchDir() (string, error) {
+	dir, err := s.prefetchDir()
+	if err != nil {
+		return "", err
+	}
+	if !s.WritableDir(dir) {
+		return "", fmt.Errorf("unable to write to the cache dir %s", DisplayPath(dir))
+	}
+	return dir, nil
+}
+
+// Prefetch downloads the resource into the cache, verifying its integrity, and publishes its progress as
+// PrefetchEvents (within a stage, see State.StartStage).
+func (s *State) Prefetch(ctx context.Context, resource PrefetchResource) (err error) {
+	stage := s.StartStage("prefetch " + resource.Name)
+	defer func() { stage.Finish(err) }()
+
+	progress := &prefetchProgress{state: s, ctx: ctx, update: PrefetchProgress{Resource: resource.Name, Size: -1}}
+	defer func() { progress.finish(err) }()
+
+	dir, err := s.writablePrefetchDir()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, resource.URL, nil)
+	if err != nil {
+		return fmt.Errorf("unable to prefetch %s: %w", resource.Name, err)
+	}
+	resp, err := s.HTTPClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to prefetch %s: %w", resource.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to prefetch %s: %s", resource.Name, resp.Status)
+	}
+	progress.setSize(resp.ContentLength)
+
+	entry, err := s.installPrefetched(dir, resource, &prefetchProgressReader{r: resp.Body, progress: progress})
+	if err != nil {
+		return fmt.Errorf("unable to prefetch %s: %w", resource.Name, err)
+	}
+	entry.URL = resource.URL
+	return updatePrefetchManifest(s, dir, map[string]prefetchedResource{resource.Name: entry})
+}
+
+// installPrefetched writes the contents of the resource into the prefetch dir once it is verified (the previous
+// contents are kept when verification fails).
+func (s *State) installPrefetched(dir string, resource PrefetchResource, r io.Reader) (prefetchedResource, error) {
+	path := filepath.Join(dir, resource.Name)
+	tmp, err := s.CreateFile(filepath.Join(dir, "."+resource.Name+".download"))
+	if err != nil {
+		return prefetchedResource{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return prefetchedResource{}, err
+	}
+
+	digest := hex.EncodeToString(h.Sum(nil))
+	if resource.SHA256 != "" && !strings.EqualFold(resource.SHA256, digest) {
+		return prefetchedResource{}, fmt.Errorf("integrity check failed: expected sha256 %s, got %s", strings.ToLower(resource.SHA256), digest)
+	}
+	if resource.Verify != nil {
+		if err := resource.Verify(tmp.Name()); err != nil {
+			return prefetchedResource{}, fmt.Errorf("verification failed: %w", err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return prefetchedResource{}, err
+	}
+	return prefetchedResource{SHA256: digest, Size: n, Fetched: now().UTC()}, nil
+}
+
+func readPrefetchManifest(dir string) (*prefetchManifest, error) {
+	manifest := &prefetchManifest{}
+	contents, err := os.ReadFile(filepath.Join(dir, prefetchManifestFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(contents, manifest); err != nil {
+			return nil, fmt.Errorf("invalid prefetch manifest: %w", err)
+		}
+	}
+	if manifest.Resources == nil {
+		manifest.Resources = map[string]prefetchedResource{}
+	}
+	return manifest, nil
+}
+
+// prefetchManifestLock serializes updates of the prefetch manifest within the process.
+var prefetchManifestLock sync.Mutex
+
+func updatePrefetchManifest(s *State, dir string, entries map[string]prefetchedResource) error {
+	prefetchManifestLock.Lock()
+	defer prefetchManifestLock.Unlock()
+
+	manifest, err := readPrefetchManifest(dir)
+	if err != nil {
+		return err
+	}
+	for name, entry := range entries {
+		manifest.Resources[name] = entry
+	}
+	contents, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.writeFileAtomic(filepath.Join(dir, prefetchManifestFile), contents)
+}
+
+// ExportPrefetched writes a bundle (a zip archive) of the prefetched resources (all of them when no names are
+// given), which can be imported on another machine without network access (see ImportPrefetched).
+func (s *State) ExportPrefetched(path string, names ...string) error {
+	dir, err := s.prefetchDir()
+	if err != nil {
+		return err
+	}
+	manifest, err := readPrefetchManifest(dir)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		for name := range manifest.Resources {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	if len(names) == 0 {
+		return errors.New("there are no prefetched resources to export")
+	}
+
+	bundle := &prefetchManifest{Resources: map[string]prefetchedResource{}}
+	for _, name := range names {
+		entry, ok := manifest.Resources[name]
+		if !ok {
+			return fmt.Errorf("%s has not been prefetched", name)
+		}
+		bundle.Resources[name] = entry
+	}
+
+	f, err := s.CreateFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	archive := zip.NewWriter(f)
+	w, err := archive.Create(prefetchManifestFile)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(w).Encode(bundle); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := addZipFile(archive, name, filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("unable to export %s: %w", name, err)
+		}
+	}
+	return archive.Close()
+}
+
+func addZipFile(archive *zip.Writer, name, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
+
+// ImportPrefetched installs the resources of a bundle (see ExportPrefetched) into the cache, verifying the integrity
+// of each (against the registered resource and the bundle manifest), returning the names of the imported resources.
+func (s *State) ImportPrefetched(path string, resources []PrefetchResource) ([]string, error) {
+	dir, err := s.writablePrefetchDir()
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := zip.OpenReader(s.ResolvePath(path))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open bundle: %w", err)
+	}
+	defer archive.Close()
+
+	files := map[string]*zip.File{}
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+	manifestFile, ok := files[prefetchManifestFile]
+	if !ok {
+		return nil, errors.New("invalid bundle: no manifest")
+	}
+	bundle := &prefetchManifest{}
+	if err := readZipJSON(manifestFile, bundle); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+
+	known := map[string]PrefetchResource{}
+	for _, r := range resources {
+		known[r.Name] = r
+	}
+
+	var names []string
+	for name := range bundle.Resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	imported := map[string]prefetchedResource{}
+	for _, name := range names {
+		entry := bundle.Resources[name]
+		resource, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unable to import %s: not a resource of this application", name)
+		}
+		f, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid bundle: %s is missing", name)
+		}
+		if resource.SHA256 == "" {
+			resource.SHA256 = entry.SHA256
+		}
+		installed, err := s.importPrefetched(dir, resource, f)
+		if err != nil {
+			return nil, fmt.Errorf("unable to import %s: %w", name, err)
+		}
+		installed.URL = entry.URL
+		imported[name] = installed
+	}
+	if err := updatePrefetchManifest(s, dir, imported); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func (s *State) importPrefetched(dir string, resource PrefetchResource, f *zip.File) (prefetchedResource, error) {
+	r, err := f.Open()
+	if err != nil {
+		return prefetchedResource{}, err
+	}
+	defer r.Close()
+	return s.installPrefetched(dir, resource, r)
+}
+
+func readZipJSON(f *zip.File, v any) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return json.NewDecoder(r).Decode(v)
+}
+
+// setupPrefetchCommands adds the "prefetch" command (also "warmup"), which downloads the resources into the cache, with
+// "export" and "import" subcommands moving them between machines as a bundle.
+func (a *application) setupPrefetchCommands() {
+	resources := a.setupConfig.PrefetchResources
+
+	var list bool
+	prefetchCmd := &cobra.Command{
+		Use:     "prefetch [resource...]",
+		Aliases: []string{"warmup"},
+		Short:   "download resources into the cache ahead of time",
+		Long: "Download resources into the cache (all of them by default), verifying their integrity, so that later " +
+			"runs do not wait for them. Use \"export\" and \"import\" to move them to machines without network access.",
+		ValidArgsFunction: prefetchResourceCompletion(resources),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if list {
+				return a.writePrefetchList(cmd.OutOrStdout(), resources)
+			}
+			selected, err := selectPrefetchResources(resources, args)
+			if err != nil {
+				return err
+			}
+			for _, r := range selected {
+				if err := a.state.Prefetch(cmd.Context(), r); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	prefetchCmd.Flags().BoolVar(&list, "list", false, "list the resources and whether each has been prefetched")
+
+	exportCmd := &cobra.Command{
+		Use:               "export <bundle> [resource...]",
+		Short:             "write the prefetched resources to a bundle, to import on another machine",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: prefetchResourceCompletion(resources),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := a.state.ExportPrefetched(args[0], args[1:]...); err != nil {
+				return fmt.Errorf("unable to export: %w", err)
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "bundle written to %s\n", DisplayPath(args[0]))
+			return nil
+		},
+	}
+
+	importCmd := &cobra.Command{
+		Use:   "import <bundle>",
+		Short: "install the resources of a bundle into the cache",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names, err := a.state.ImportPrefetched(args[0], resources)
+			if err != nil {
+				return fmt.Errorf("unable to import: %w", err)
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "imported %s\n", strings.Join(names, ", "))
+			return nil
+		},
+	}
+
+	prefetchCmd.AddCommand(a.SetupCommand(exportCmd), a.SetupCommand(importCmd))
+	a.root.AddCommand(a.SetupCommand(prefetchCmd))
+}
+
+func selectPrefetchResources(resources []PrefetchResource, names []string) ([]PrefetchResource, error) {
+	if len(names) == 0 {
+		return resources, nil
+	}
+	var selected []PrefetchResource
+	for _, name := range names {
+		found := false
+		for _, r := range resources {
+			if r.Name == name {
+				selected = append(selected, r)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown resource %q (available: [%s])", name, strings.Join(prefetchResourceNames(resources), ", "))
+		}
+	}
+	return selected, nil
+}
+
+func prefetchResourceNames(resources []PrefetchResource) []string {
+	var names []string
+	for _, r := range resources {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func prefetchResourceCompletion(resources []PrefetchResource) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+		if cmd.Name() == "export" && len(args) == 0 {
+			// the bundle
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		var names []string
+		for _, r := range resources {
+			names = append(names, r.Name+"\t"+r.Description)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func (a *application) writePrefetchList(w io.Writer, resources []PrefetchResource) error {
+	for _, r := range resources {
+		status := "not prefetched"
+		if _, ok := a.state.Prefetched(r.Name); ok {
+			status = "prefetched"
+		}
+		line := fmt.Sprintf("%s (%s)", r.Name, status)
+		if r.Description != "" {
+			line += ": " + r.Description
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefetchProgress publishes the progress of a resource being prefetched.
+type prefetchProgress struct {
+	state *State
+	ctx   context.Context
+
+	lock      sync.Mutex
+	update    PrefetchProgress
+	published int64
+}
+
+func (p *prefetchProgress) setSize(size int64) {
+	p.lock.Lock()
+	p.update.Size = size
+	p.lock.Unlock()
+	p.publish()
+}
+
+func (p *prefetchProgress) add(n int) {
+	p.lock.Lock()
+	p.update.Written += int64(n)
+	due := p.update.Written-p.published >= prefetchProgressInterval
+	p.lock.Unlock()
+	if due {
+		p.publish()
+	}
+}
+
+func (p *prefetchProgress) finish(err error) {
+	p.lock.Lock()
+	p.update.Done = true
+	if err != nil {
+		p.update.Error = err.Error()
+		if p.state.RedactStore != nil {
+			p.update.Error = p.state.RedactStore.RedactString(p.update.Error)
+		}
+	}
+	p.lock.Unlock()
+	p.publish()
+}
+
+func (p *prefetchProgress) publish() {
+	p.lock.Lock()
+	update := p.update
+	p.published = update.Written
+	p.lock.Unlock()
+	p.state.Publish(p.ctx, partybus.Event{Type: PrefetchEvent, Value: update})
+}
+
+// prefetchProgressReader reports the bytes read from it as prefetch progress.
+type prefetchProgressReader struct {
+	r        io.Reader
+	progress *prefetchProgress
+}
+
+func (r *prefetchProgressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.progress.add(n)
+	}
+	return n, err
+}
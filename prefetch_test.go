@@ -0,0 +1,80 @@
+package clio
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_State_Prefetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("database"))
+	}))
+	defer server.Close()
+
+	sum := sha256.Sum256([]byte("database"))
+	db := PrefetchResource{Name: "db", URL: server.URL + "/db", SHA256: hex.EncodeToString(sum[:])}
+
+	s := &State{Config: Config{Network: &NetworkConfig{}}}
+	s.workspace.CacheDir = t.TempDir()
+
+	_, ok := s.Prefetched("db")
+	assert.False(t, ok)
+
+	require.NoError(t, s.Prefetch(context.Background(), db))
+	path, ok := s.Prefetched("db")
+	require.True(t, ok)
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "database", string(contents))
+
+	// a download that does not match the digest is not cached
+	corrupt := PrefetchResource{Name: "index", URL: server.URL + "/index", SHA256: "00"}
+	require.ErrorContains(t, s.Prefetch(context.Background(), corrupt), "integrity check failed")
+	_, ok = s.Prefetched("index")
+	assert.False(t, ok)
+
+	// moved to another machine as a bundle
+	bundle := filepath.Join(t.TempDir(), "bundle.zip")
+	require.NoError(t, s.ExportPrefetched(bundle))
+
+	other := &State{}
+	other.workspace.CacheDir = t.TempDir()
+	_, err = other.ImportPrefetched(bundle, nil)
+	require.ErrorContains(t, err, "not a resource of this application")
+
+	names, err := other.ImportPrefetched(bundle, []PrefetchResource{db})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db"}, names)
+	path, ok = other.Prefetched("db")
+	require.True(t, ok)
+	contents, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "database", string(contents))
+
+	_, err = other.ImportPrefetched(bundle, []PrefetchResource{{Name: "db", SHA256: "00"}})
+	require.ErrorContains(t, err, "integrity check failed")
+}
+
+func Test_selectPrefetchResources(t *testing.T) {
+	resources := []PrefetchResource{{Name: "db"}, {Name: "index"}}
+
+	selected, err := selectPrefetchResources(resources, nil)
+	require.NoError(t, err)
+	assert.Equal(t, resources, selected)
+
+	selected, err = selectPrefetchResources(resources, []string{"index"})
+	require.NoError(t, err)
+	assert.Equal(t, []PrefetchResource{{Name: "index"}}, selected)
+
+	_, err = selectPrefetchResources(resources, []string{"bogus"})
+	require.ErrorContains(t, err, `unknown resource "bogus" (available: [db, index])`)
+}
@@ -0,0 +1,132 @@
+package clio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// PrerequisitesError is returned when a command is run without meeting its prerequisites (see
+// Application.AddPrerequisites), listing all that are unmet.
+type PrerequisitesError struct {
+	Command string
+	Unmet   []RequirementResult
+}
+
+func (e *PrerequisitesError) Error() string {
+	if len(e.Unmet) == 1 {
+		return fmt.Sprintf("%s requires %s: %v", e.Command, e.Unmet[0].Name, e.Unmet[0].Err)
+	}
+	lines := make([]string, 0, len(e.Unmet)+1)
+	lines = append(lines, fmt.Sprintf("%s has %d unmet prerequisites:", e.Command, len(e.Unmet)))
+	for _, r := range e.Unmet {
+		lines = append(lines, fmt.Sprintf("  - %s: %v", r.Name, r.Err))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// AddPrerequisites declares conditions which must be met to run the command and all of its children (e.g.
+// RequireAuth, RequireWorkspace, or RequireNetwork), which are checked once the configuration is loaded and before the
+// command runs, rather than with guard clauses within each command.
+func (a *application) AddPrerequisites(cmd *cobra.Command, prerequisites ...Requirement) {
+	if a.prerequisites == nil {
+		a.prerequisites = make(map[*cobra.Command][]Requirement)
+	}
+	a.prerequisites[cmd] = append(a.prerequisites[cmd], prerequisites...)
+}
+
+// checkPrerequisites returns a PrerequisitesError when any prerequisites of the command (or its parents) are unmet.
+func (a *application) checkPrerequisites(cmd *cobra.Command) error {
+	var cmds []*cobra.Command
+	for c := cmd; c != nil; c = c.Parent() {
+		cmds = append(cmds, c)
+	}
+	if d := a.defaultCommandFor(cmd); d != nil {
+		cmds = append(cmds, d.cmd)
+	}
+
+	var unmet []RequirementResult
+	for i := len(cmds) - 1; i >= 0; i-- {
+		for _, p := range a.prerequisites[cmds[i]] {
+			if err := p.Check(&a.state); err != nil {
+				unmet = append(unmet, RequirementResult{Name: p.Name, Err: err})
+			}
+		}
+	}
+	if len(unmet) > 0 {
+		return &PrerequisitesError{Command: cmd.CommandPath(), Unmet: unmet}
+	}
+	return nil
+}
+
+// RequireAuth requires the user to be authenticated, as determined by the given function, with a hint for how to
+// authenticate (e.g. "run 'app login'").
+func RequireAuth(authenticated func(s *State) bool, hint string) Requirement {
+	return Requirement{
+		Name: "authentication",
+		Check: func(s *State) error {
+			if authenticated(s) {
+				return nil
+			}
+			return withHint("not authenticated", hint)
+		},
+	}
+}
+
+// RequireWorkspace requires running within an initialized workspace, which has a workspace config file (see
+// SetupConfig.WithWorkspace), with a hint for how to initialize one (e.g. "run 'app init'").
+func RequireWorkspace(hint string) Requirement {
+	return Requirement{
+		Name: "an initialized workspace",
+		Check: func(s *State) error {
+			ws := s.Workspace()
+			switch {
+			case ws.Root == "":
+				return withHint("not running within a workspace", hint)
+			case ws.ConfigFile == "":
+				return withHint(fmt.Sprintf("the workspace %s is not initialized", DisplayPath(ws.Root)), hint)
+			}
+			return nil
+		},
+	}
+}
+
+// RequireNetwork requires network access, to each of the given hosts when any are given, as allowed by the network
+// policy (see SetupConfig.WithNetworkPolicy).
+func RequireNetwork(hosts ...string) Requirement {
+	return Requirement{
+		Name: "network access",
+		Check: func(s *State) error {
+			policy := s.currentConfig().Network
+			if len(hosts) == 0 && policy != nil && policy.Offline {
+				return withHint("running offline", "run without --offline")
+			}
+			for _, host := range hosts {
+				err := policy.Check(host)
+				var policyErr *NetworkPolicyError
+				if !errors.As(err, &policyErr) {
+					continue
+				}
+				switch policyErr.Reason {
+				case "offline":
+					return withHint(err.Error(), "run without --offline, or allow the host with network.allow")
+				case "denied":
+					return withHint(err.Error(), "remove the host from network.deny")
+				default:
+					return withHint(err.Error(), "allow the host with network.allow")
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// withHint returns an error for the message, followed by the hint for how to resolve it (if any).
+func withHint(msg, hint string) error {
+	if hint != "" {
+		msg += fmt.Sprintf(" (%s)", hint)
+	}
+	return errors.New(msg)
+}
@@ -0,0 +1,101 @@
+package clio
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AddPrerequisites(t *testing.T) {
+	tests := []struct {
+		name          string
+		authenticated bool
+		args          []string
+		wantErr       string
+	}{
+		{
+			name:          "met",
+			authenticated: true,
+			args:          []string{"remote", "push"},
+		},
+		{
+			name:    "inherited from the parent command",
+			args:    []string{"remote", "push"},
+			wantErr: `app remote push requires authentication: not authenticated (run "app login")`,
+		},
+		{
+			name:          "all unmet prerequisites are listed",
+			authenticated: false,
+			args:          []string{"remote", "push", "--offline"},
+			wantErr: "app remote push has 2 unmet prerequisites:\n" +
+				`  - authentication: not authenticated (run "app login")` + "\n" +
+				"  - network access: connection to registry.example.com is not allowed by the network policy (offline) (run without --offline, or allow the host with network.allow)",
+		},
+		{
+			name: "other commands are not affected",
+			args: []string{"version", "--offline"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := New(*NewSetupConfig(Identification{Name: "app"}).
+				WithNoBus().
+				WithGlobalConfigFlag().
+				WithNetworkPolicy(NetworkConfig{}))
+			root := app.SetupRootCommand(&cobra.Command{})
+
+			ran := false
+			run := func(cmd *cobra.Command, args []string) error {
+				ran = true
+				return nil
+			}
+			remote := app.SetupCommand(&cobra.Command{Use: "remote"})
+			push := app.SetupCommand(&cobra.Command{Use: "push", RunE: run})
+			remote.AddCommand(push)
+			root.AddCommand(remote, app.SetupCommand(&cobra.Command{Use: "version", RunE: run}))
+
+			app.AddPrerequisites(remote, RequireAuth(func(*State) bool { return tt.authenticated }, `run "app login"`))
+			app.AddPrerequisites(push, RequireNetwork("registry.example.com"))
+
+			root.SetArgs(tt.args)
+			err := root.Execute()
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				var prereqErr *PrerequisitesError
+				require.ErrorAs(t, err, &prereqErr)
+				assert.False(t, ran, "the command does not run")
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, ran)
+		})
+	}
+}
+
+func Test_RequireWorkspace(t *testing.T) {
+	dir := t.TempDir()
+
+	s := &State{}
+	req := RequireWorkspace(`run "app init"`)
+	require.EqualError(t, req.Check(s), `not running within a workspace (run "app init")`)
+
+	s.workspace = Workspace{Root: dir}
+	require.EqualError(t, req.Check(s), "the workspace "+dir+` is not initialized (run "app init")`)
+
+	s.workspace.ConfigFile = filepath.Join(dir, ".app.yaml")
+	require.NoError(t, req.Check(s))
+}
+
+func Test_RequireNetwork(t *testing.T) {
+	s := &State{Config: Config{Network: &NetworkConfig{Offline: true}}}
+	require.EqualError(t, RequireNetwork().Check(s), "running offline (run without --offline)")
+
+	s.Config.Network = &NetworkConfig{Deny: []string{"*.example.com"}}
+	require.NoError(t, RequireNetwork().Check(s))
+	require.NoError(t, RequireNetwork("example.org").Check(s))
+	require.EqualError(t, RequireNetwork("registry.example.com").Check(s),
+		"connection to registry.example.com is not allowed by the network policy (denied) (remove the host from network.deny)")
+}
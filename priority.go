@@ -0,0 +1,85 @@
+package clio
+
+import (
+	"fmt"
+
+	"github.com/boss-net/fangs"
+)
+
+// IOClass is the IO scheduling class of the process (see PriorityConfig).
+type IOClass string
+
+const (
+	// IORealtime gets first access to the disk, regardless of other processes (usually requires privileges).
+	IORealtime IOClass = "realtime"
+	// IOBestEffort shares the disk with other processes by the IO level (the default for processes).
+	IOBestEffort IOClass = "best-effort"
+	// IOIdle only gets disk time when no other process needs it.
+	IOIdle IOClass = "idle"
+)
+
+var ioClasses = Enum(IORealtime, IOBestEffort, IOIdle)
+
+// PriorityConfig is the user-facing CPU and IO priority of the process (see SetupConfig.WithProcessPriority), which
+// is inherited by child processes started with State.Exec. Lowering the priority keeps heavy work (such as scans)
+// from slowing down the rest of a developer machine or shared CI agent.
+type PriorityConfig struct {
+	Nice    int     `yaml:"nice" json:"nice" mapstructure:"nice"`             // CPU niceness, from -20 (highest priority) to 19 (lowest)
+	IOClass IOClass `yaml:"io-class" json:"io-class" mapstructure:"io-class"` // IO scheduling class (realtime, best-effort, idle)
+	IOLevel int     `yaml:"io-level" json:"io-level" mapstructure:"io-level"` // IO priority within the class, from 0 (highest) to 7 (lowest)
+}
+
+var _ interface {
+	fangs.FieldDescriber
+	fangs.PostLoader
+	EnumFieldsDescriber
+} = (*PriorityConfig)(nil)
+
+func (c *PriorityConfig) DescribeFields(set fangs.FieldDescriptionSet) {
+	set.Add(&c.Nice, "CPU niceness of the process and the processes it starts, from -20 (highest priority) to 19 (lowest), where raising the priority usually requires privileges")
+	set.Add(&c.IOClass, "IO scheduling class of the process and the processes it starts (leave unset to keep the default)")
+	set.Add(&c.IOLevel, "IO priority within the IO class, from 0 (highest) to 7 (lowest)")
+}
+
+func (c *PriorityConfig) DescribeEnumFields(set EnumFieldSet) {
+	ioClasses.Describe(set, &c.IOClass)
+}
+
+func (c *PriorityConfig) PostLoad() error {
+	if c.Nice < -20 || c.Nice > 19 {
+		return fmt.Errorf("invalid priority.nice %d: must be between -20 and 19", c.Nice)
+	}
+	if c.IOLevel < 0 || c.IOLevel > 7 {
+		return fmt.Errorf("invalid priority.io-level %d: must be between 0 and 7", c.IOLevel)
+	}
+	return nil
+}
+
+// ioPriority returns the IO class and level as encoded for ioprio_set (0 when the IO class is not set).
+func (c *PriorityConfig) ioPriority() int {
+	var class int
+	switch c.IOClass {
+	case IORealtime:
+		class = 1
+	case IOBestEffort:
+		class = 2
+	case IOIdle:
+		// the level does not apply to the idle class
+		return 3 << 13
+	default:
+		return 0
+	}
+	return class<<13 | c.IOLevel
+}
+
+// setupPriority changes the priority of the process as configured. A priority that can't be set (e.g. raising the
+// priority without privileges) raises a warning rather than failing the command.
+func (s *State) setupPriority() {
+	cfg := s.Config.Priority
+	if cfg == nil || (cfg.Nice == 0 && cfg.IOClass == "") {
+		return
+	}
+	if err := setProcessPriority(cfg); err != nil {
+		s.Warn("priority", "unable to change the priority of the process", map[string]any{"error": err.Error()})
+	}
+}
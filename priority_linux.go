@@ -0,0 +1,52 @@
+//go:build linux
+
+package clio
+
+import (
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioprioWhoProcess selects a single thread (or process) for ioprio_set.
+const ioprioWhoProcess = 1
+
+// setProcessPriority changes the priority of each thread of the process, since linux applies both the niceness and
+// the IO priority per thread. Threads created later (and child processes) inherit the priority of the thread creating
+// them.
+func setProcessPriority(cfg *PriorityConfig) error {
+	tids, err := processThreads()
+	if err != nil {
+		return err
+	}
+	ioprio := cfg.ioPriority()
+	for _, tid := range tids {
+		if cfg.Nice != 0 {
+			if err := unix.Setpriority(unix.PRIO_PROCESS, tid, cfg.Nice); err != nil {
+				return err
+			}
+		}
+		if ioprio != 0 {
+			if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(ioprio)); errno != 0 {
+				return errno
+			}
+		}
+	}
+	return nil
+}
+
+// processThreads returns the IDs of all threads of the process.
+func processThreads() ([]int, error) {
+	entries, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return nil, err
+	}
+	var tids []int
+	for _, e := range entries {
+		if tid, err := strconv.Atoi(e.Name()); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids, nil
+}
@@ -0,0 +1,37 @@
+package clio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PriorityConfig_PostLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     PriorityConfig
+		wantErr string
+	}{
+		{name: "default"},
+		{name: "lowered", cfg: PriorityConfig{Nice: 19, IOClass: IOIdle}},
+		{name: "nice too low", cfg: PriorityConfig{Nice: -21}, wantErr: "must be between -20 and 19"},
+		{name: "io level too high", cfg: PriorityConfig{IOClass: IOBestEffort, IOLevel: 8}, wantErr: "must be between 0 and 7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.PostLoad()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_PriorityConfig_ioPriority(t *testing.T) {
+	assert.Equal(t, 0, (&PriorityConfig{IOLevel: 4}).ioPriority())
+	assert.Equal(t, 2<<13|4, (&PriorityConfig{IOClass: IOBestEffort, IOLevel: 4}).ioPriority())
+	assert.Equal(t, 1<<13, (&PriorityConfig{IOClass: IORealtime}).ioPriority())
+	assert.Equal(t, 3<<13, (&PriorityConfig{IOClass: IOIdle, IOLevel: 7}).ioPriority())
+}
@@ -0,0 +1,21 @@
+//go:build !windows && !linux
+
+package clio
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+func setProcessPriority(cfg *PriorityConfig) error {
+	if cfg.Nice != 0 {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, 0, cfg.Nice); err != nil {
+			return err
+		}
+	}
+	if cfg.IOClass != "" {
+		return errors.New("the IO priority can't be set on this platform")
+	}
+	return nil
+}
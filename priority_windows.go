@@ -0,0 +1,39 @@
+//go:build windows
+
+package clio
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// processModeBackgroundBegin lowers the CPU, IO, and memory priority of the process (for the idle IO class).
+const processModeBackgroundBegin = 0x00100000
+
+// setProcessPriority changes the priority class of the process, which windows uses in place of niceness. The idle
+// IO class puts the process in background mode.
+func setProcessPriority(cfg *PriorityConfig) error {
+	if cfg.Nice != 0 {
+		if err := windows.SetPriorityClass(windows.CurrentProcess(), priorityClass(cfg.Nice)); err != nil {
+			return err
+		}
+	}
+	if cfg.IOClass == IOIdle {
+		return windows.SetPriorityClass(windows.CurrentProcess(), processModeBackgroundBegin)
+	}
+	return nil
+}
+
+// priorityClass returns the windows priority class closest to the niceness.
+func priorityClass(nice int) uint32 {
+	switch {
+	case nice <= -15:
+		return windows.HIGH_PRIORITY_CLASS
+	case nice < 0:
+		return windows.ABOVE_NORMAL_PRIORITY_CLASS
+	case nice >= 15:
+		return windows.IDLE_PRIORITY_CLASS
+	case nice > 0:
+		return windows.BELOW_NORMAL_PRIORITY_CLASS
+	}
+	return windows.NORMAL_PRIORITY_CLASS
+}
@@ -0,0 +1,72 @@
+package clio
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RootPolicy determines how the application behaves when run with elevated privileges (as root or Administrator).
+type RootPolicy string
+
+const (
+	// RootAllowed runs the application regardless of privileges (the default).
+	RootAllowed RootPolicy = ""
+	// RootWarn logs a warning when the application is run with elevated privileges.
+	RootWarn RootPolicy = "warn"
+	// RootRefused fails setup when the application is run with elevated privileges.
+	RootRefused RootPolicy = "refuse"
+)
+
+// ErrPrivileged is returned when the application is run with elevated privileges and the RootPolicy is RootRefused.
+var ErrPrivileged = errors.New("refusing to run with elevated privileges (as root or Administrator)")
+
+// privileged is swappable for testing
+var privileged = isPrivileged
+
+// IsPrivileged indicates if the process is running with elevated privileges (as root on unix, or as an elevated
+// Administrator on windows).
+func IsPrivileged() bool {
+	return isPrivileged()
+}
+
+// DropPrivileges switches the process to the given user (and its groups). This is irreversible, so any resources
+// that require privileges (e.g. binding to a low port) must be acquired first. This is not supported on windows.
+func DropPrivileges(username string) error {
+	if err := dropPrivileges(username); err != nil {
+		return fmt.Errorf("unable to drop privileges to user %q: %w", username, err)
+	}
+	return nil
+}
+
+// checkPrivileges applies the configured RootPolicy. The policy does not apply when privileges are configured to be
+// dropped before the command runs, since running privileged is expected in that case.
+func (a *application) checkPrivileges() error {
+	if a.setupConfig.DropPrivilegesTo != "" || !privileged() {
+		return nil
+	}
+
+	switch a.setupConfig.RootPolicy {
+	case RootWarn:
+		if a.state.Logger != nil {
+			a.state.Logger.Warn("running with elevated privileges (as root or Administrator) is not recommended")
+		}
+	case RootRefused:
+		return ErrPrivileged
+	}
+	return nil
+}
+
+// dropConfiguredPrivileges drops privileges to the user given with SetupConfig.WithPrivilegeDrop (if any).
+func (a *application) dropConfiguredPrivileges() error {
+	username := a.setupConfig.DropPrivilegesTo
+	if username == "" || !privileged() {
+		return nil
+	}
+	if err := DropPrivileges(username); err != nil {
+		return err
+	}
+	if a.state.Logger != nil {
+		a.state.Logger.Debugf("dropped privileges to user %q", username)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package clio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/go-logger/adapter/discard"
+)
+
+func Test_Application_checkPrivileges(t *testing.T) {
+	tests := []struct {
+		name       string
+		privileged bool
+		cfg        *SetupConfig
+		wantWarn   bool
+		wantErr    require.ErrorAssertionFunc
+	}{
+		{
+			name:       "allowed by default",
+			privileged: true,
+			cfg:        NewSetupConfig(Identification{Name: "app"}),
+		},
+		{
+			name:       "warn",
+			privileged: true,
+			cfg:        NewSetupConfig(Identification{Name: "app"}).WithRootPolicy(RootWarn),
+			wantWarn:   true,
+		},
+		{
+			name:       "refuse",
+			privileged: true,
+			cfg:        NewSetupConfig(Identification{Name: "app"}).WithRootPolicy(RootRefused),
+			wantErr:    require.Error,
+		},
+		{
+			name: "refuse when not privileged",
+			cfg:  NewSetupConfig(Identification{Name: "app"}).WithRootPolicy(RootRefused),
+		},
+		{
+			name:       "privileges will be dropped",
+			privileged: true,
+			cfg:        NewSetupConfig(Identification{Name: "app"}).WithRootPolicy(RootRefused).WithPrivilegeDrop("nobody"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+
+			original := privileged
+			privileged = func() bool { return tt.privileged }
+			defer func() { privileged = original }()
+
+			rec := &warnRecorder{Logger: discard.New()}
+			a := &application{setupConfig: *tt.cfg, state: State{Logger: rec}}
+
+			err := a.checkPrivileges()
+			tt.wantErr(t, err)
+			if err != nil {
+				assert.ErrorIs(t, err, ErrPrivileged)
+			}
+			assert.Equal(t, tt.wantWarn, len(rec.warnings) > 0)
+		})
+	}
+}
+
+func Test_DropPrivileges_unknownUser(t *testing.T) {
+	err := DropPrivileges("clio-user-that-does-not-exist")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "clio-user-that-does-not-exist")
+}
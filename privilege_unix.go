@@ -0,0 +1,58 @@
+//go:build !windows
+
+package clio
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+func isPrivileged() bool {
+	return os.Geteuid() == 0
+}
+
+func dropPrivileges(username string) error {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return err
+	}
+
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("invalid uid %q: %w", u.Uid, err)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return fmt.Errorf("invalid gid %q: %w", u.Gid, err)
+	}
+
+	groupIDs, err := u.GroupIds()
+	if err != nil {
+		return fmt.Errorf("unable to list groups: %w", err)
+	}
+	gids := []int{gid}
+	for _, g := range groupIDs {
+		id, err := strconv.Atoi(g)
+		if err != nil {
+			return fmt.Errorf("invalid gid %q: %w", g, err)
+		}
+		if id != gid {
+			gids = append(gids, id)
+		}
+	}
+
+	// the order matters: the groups can only be changed while still privileged
+	if err := syscall.Setgroups(gids); err != nil {
+		return fmt.Errorf("unable to set groups: %w", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("unable to set gid: %w", err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("unable to set uid: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package clio
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+func isPrivileged() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
+
+func dropPrivileges(_ string) error {
+	return errors.New("not supported on windows")
+}
@@ -0,0 +1,176 @@
+package clio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+// promptCommandName is the hidden command shells call to render the prompt status. This is run for every prompt, so
+// it only reads the cached status, without loading the configuration or setting up any resources.
+const promptCommandName = "__prompt"
+
+// promptStatusFile returns the file caching the prompt status of the application.
+func promptStatusFile(appName string) (string, error) {
+	dir, err := stateDir(appName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "prompt.json"), nil
+}
+
+// SetPromptStatus caches a value to show in the user's shell prompt (see SetupConfig.WithPromptIntegration), such as
+// the current context or the number of pending updates. An empty value removes it. Values are shown in order of
+// their names, unless the user gives their own format.
+func (s *State) SetPromptStatus(name, value string) error {
+	file, err := promptStatusFile(s.id.Name)
+	if err != nil {
+		return fmt.Errorf("unable to set prompt status: %w", err)
+	}
+
+	status, err := readPromptStatus(file)
+	if err != nil {
+		return fmt.Errorf("unable to set prompt status: %w", err)
+	}
+	if value == "" {
+		delete(status, name)
+	} else {
+		status[name] = value
+	}
+
+	contents, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("unable to set prompt status: %w", err)
+	}
+
+	fileMode, dirMode := s.currentConfig().Permissions.modes()
+	if err := mkdirAll(filepath.Dir(file), dirMode); err != nil {
+		return fmt.Errorf("unable to set prompt status: %w", err)
+	}
+
+	// write to a temporary file first so that a prompt being rendered never reads a partial file
+	tmp := fmt.Sprintf("%s.%d.tmp", file, os.Getpid())
+	if err := os.WriteFile(tmp, contents, fileMode); err != nil {
+		return fmt.Errorf("unable to set prompt status: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("unable to set prompt status: %w", err)
+	}
+	return nil
+}
+
+func readPromptStatus(file string) (map[string]string, error) {
+	status := map[string]string{}
+	contents, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contents, &status); err != nil {
+		// a corrupt cache is replaced rather than failing every prompt
+		return map[string]string{}, nil
+	}
+	return status, nil
+}
+
+// writePromptStatus writes the status with the given go template (with the status values by name), or all values in
+// order of their names when there is no template.
+func writePromptStatus(w io.Writer, status map[string]string, format string) error {
+	if format != "" {
+		tmpl, err := template.New("prompt").Option("missingkey=zero").Parse(format)
+		if err != nil {
+			return fmt.Errorf("invalid prompt format: %w", err)
+		}
+		return tmpl.Execute(w, status)
+	}
+
+	var names []string
+	for name := range status {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var values []string
+	for _, name := range names {
+		values = append(values, status[name])
+	}
+	_, err := io.WriteString(w, strings.Join(values, " "))
+	return err
+}
+
+// setupPromptCommands adds the hidden command rendering the prompt status, and the "shell-integration" command
+// writing the snippet that adds it to the shell prompt.
+func (a *application) setupPromptCommands() {
+	var format string
+	promptCmd := &cobra.Command{
+		Use:    promptCommandName,
+		Short:  "write the cached status for the shell prompt",
+		Hidden: true,
+		Args:   cobra.NoArgs,
+		// note: never fail, since errors would be shown on every prompt
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, err := promptStatusFile(a.setupConfig.ID.Name)
+			if err != nil {
+				return nil
+			}
+			status, err := readPromptStatus(file)
+			if err != nil {
+				return nil
+			}
+			_ = writePromptStatus(cmd.OutOrStdout(), status, format)
+			return nil
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	promptCmd.Flags().StringVarP(&format, "format", "", "", "a go template to render the status with (e.g. '{{.context}}')")
+	a.root.AddCommand(promptCmd)
+
+	shellCmd := &cobra.Command{
+		Use:   "shell-integration [bash|zsh]",
+		Short: fmt.Sprintf("write the shell snippet showing the %s status in the prompt", a.setupConfig.ID.Name),
+		Long: fmt.Sprintf("Write the shell snippet showing the %[1]s status in the prompt. Add it to your shell "+
+			"startup file, e.g. for bash:\n\n  echo 'eval \"$(%[1]s shell-integration bash)\"' >> ~/.bashrc", a.setupConfig.ID.Name),
+		ValidArgs: []string{"bash", "zsh"},
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := io.WriteString(cmd.OutOrStdout(), promptSnippet(a.setupConfig.ID.Name, args[0]))
+			return err
+		},
+	}
+	a.root.AddCommand(shellCmd)
+}
+
+// promptSnippet returns the shell code prepending the prompt status (when there is any) to the prompt.
+func promptSnippet(appName, shell string) string {
+	fn := "__" + strings.NewReplacer("-", "_", ".", "_").Replace(appName) + "_prompt"
+	variable := "PS1"
+	var setup string
+	if shell == "zsh" {
+		variable = "PROMPT"
+		setup = "setopt prompt_subst\n"
+	}
+
+	// note: the variable is not named "status", which is read-only in zsh
+	return fmt.Sprintf(`%[4]s%[1]s() {
+  local clio_status
+  clio_status="$(command %[2]s %[5]s 2>/dev/null)"
+  [ -n "$clio_status" ] && printf '[%%s] ' "$clio_status"
+}
+case "$%[3]s" in
+  *%[1]s*) ;;
+  *) %[3]s='$(%[1]s)'"$%[3]s" ;;
+esac
+`, fn, appName, variable, setup, promptCommandName)
+}
@@ -0,0 +1,141 @@
+package clio
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_State_SetPromptStatus(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	s := &State{id: Identification{Name: "app"}}
+	require.NoError(t, s.SetPromptStatus("updates", "2 updates"))
+	require.NoError(t, s.SetPromptStatus("context", "prod"))
+	require.NoError(t, s.SetPromptStatus("removed", "soon"))
+	require.NoError(t, s.SetPromptStatus("removed", ""))
+
+	file, err := promptStatusFile("app")
+	require.NoError(t, err)
+	status, err := readPromptStatus(file)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"context": "prod", "updates": "2 updates"}, status)
+}
+
+func Test_Application_PromptCommand(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	tests := []struct {
+		name   string
+		status map[string]string
+		args   []string
+		want   string
+	}{
+		{
+			name:   "all values in order",
+			status: map[string]string{"updates": "2 updates", "context": "prod"},
+			want:   "prod 2 updates",
+		},
+		{
+			name:   "format",
+			status: map[string]string{"updates": "2 updates", "context": "prod"},
+			args:   []string{"--format", "ctx:{{.context}}{{.missing}}"},
+			want:   "ctx:prod",
+		},
+		{
+			name: "no status",
+			want: "",
+		},
+		{
+			name: "invalid format is not an error",
+			args: []string{"--format", "{{"},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := promptStatusFile("app")
+			require.NoError(t, err)
+			_ = os.Remove(file)
+
+			s := &State{id: Identification{Name: "app"}}
+			for name, value := range tt.status {
+				require.NoError(t, s.SetPromptStatus(name, value))
+			}
+
+			app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithPromptIntegration())
+			root := app.SetupRootCommand(&cobra.Command{})
+
+			var out bytes.Buffer
+			root.SetOut(&out)
+			root.SetArgs(append([]string{promptCommandName}, tt.args...))
+			require.NoError(t, root.Execute())
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func Test_Application_ShellIntegrationCommand(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh"} {
+		t.Run(shell, func(t *testing.T) {
+			app := New(*NewSetupConfig(Identification{Name: "my-app"}).WithNoBus().WithPromptIntegration())
+			root := app.SetupRootCommand(&cobra.Command{})
+
+			var out bytes.Buffer
+			root.SetOut(&out)
+			root.SetArgs([]string{"shell-integration", shell})
+			require.NoError(t, root.Execute())
+
+			assert.Contains(t, out.String(), "__my_app_prompt() {")
+			assert.Contains(t, out.String(), "command my-app __prompt 2>/dev/null")
+			if shell == "zsh" {
+				assert.Contains(t, out.String(), "setopt prompt_subst")
+				assert.Contains(t, out.String(), `PROMPT='$(__my_app_prompt)'"$PROMPT"`)
+			} else {
+				assert.Contains(t, out.String(), `PS1='$(__my_app_prompt)'"$PS1"`)
+			}
+		})
+	}
+
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithPromptIntegration())
+	root := app.SetupRootCommand(&cobra.Command{})
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	root.SetArgs([]string{"shell-integration", "fish"})
+	require.Error(t, root.Execute())
+}
+
+func Test_promptSnippet_shellSyntax(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh"} {
+		t.Run(shell, func(t *testing.T) {
+			path, err := exec.LookPath(shell)
+			if err != nil {
+				t.Skipf("%s is not available", shell)
+			}
+			snippet := promptSnippet("my-app", shell)
+
+			out, err := exec.Command(path, "-n", "-c", snippet).CombinedOutput()
+			require.NoError(t, err, string(out))
+
+			// the prompt function runs without errors (even when the application is not found)
+			out, err = exec.Command(path, "-c", snippet+"__my_app_prompt; echo ok\n").CombinedOutput()
+			require.NoError(t, err, string(out))
+			assert.Equal(t, "ok\n", string(out))
+		})
+	}
+}
+
+func Test_readPromptStatus_Corrupt(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "prompt.json")
+	require.NoError(t, os.WriteFile(file, []byte("{not json"), 0o600))
+
+	status, err := readPromptStatus(file)
+	require.NoError(t, err)
+	assert.Empty(t, status)
+}
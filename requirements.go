@@ -0,0 +1,162 @@
+package clio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Requirement is a condition the runtime environment must meet for the application to work (see
+// SetupConfig.WithRequirements). Check returns an actionable error (what is wrong and how to fix it) when the
+// requirement is not met.
+type Requirement struct {
+	Name  string
+	Check func(s *State) error
+}
+
+// RequirementResult is the outcome of checking a single requirement.
+type RequirementResult struct {
+	Name string
+	Err  error // nil when the requirement is met
+}
+
+// RequirementsError is returned when any requirements are not met, listing all of them.
+type RequirementsError struct {
+	Unmet []RequirementResult
+}
+
+func (e *RequirementsError) Error() string {
+	if len(e.Unmet) == 1 {
+		return fmt.Sprintf("requirement not met: %s: %v", e.Unmet[0].Name, e.Unmet[0].Err)
+	}
+	lines := make([]string, 0, len(e.Unmet)+1)
+	lines = append(lines, fmt.Sprintf("%d requirements not met:", len(e.Unmet)))
+	for _, r := range e.Unmet {
+		lines = append(lines, fmt.Sprintf("  - %s: %v", r.Name, r.Err))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CheckRequirements checks all requirements declared by the application (see SetupConfig.WithRequirements),
+// returning the outcome of each (e.g. for a diagnostic command to show).
+func (s *State) CheckRequirements() []RequirementResult {
+	results := make([]RequirementResult, 0, len(s.requirements))
+	for _, r := range s.requirements {
+		results = append(results, RequirementResult{Name: r.Name, Err: r.Check(s)})
+	}
+	return results
+}
+
+// checkRequirements returns a RequirementsError when any requirements are not met.
+func (s *State) checkRequirements() error {
+	var unmet []RequirementResult
+	for _, r := range s.CheckRequirements() {
+		if r.Err != nil {
+			unmet = append(unmet, r)
+		}
+	}
+	if len(unmet) > 0 {
+		return &RequirementsError{Unmet: unmet}
+	}
+	return nil
+}
+
+// RequireTerminalSize requires a terminal of at least the given size when the rich (interactive) UI is shown.
+func RequireTerminalSize(columns, rows int) Requirement {
+	return Requirement{
+		Name: "terminal size",
+		Check: func(s *State) error {
+			if !isTerminal(os.Stderr) || !s.Config.Log.AllowUI(os.Stdin) {
+				// there is no interactive UI to fit
+				return nil
+			}
+			width, height, err := term.GetSize(int(os.Stderr.Fd()))
+			if err != nil {
+				return nil
+			}
+			if width < columns || height < rows {
+				return fmt.Errorf("the terminal is %dx%d, but at least %dx%d is needed for the interactive UI (resize the terminal, or run with -v to show logs instead)", width, height, columns, rows)
+			}
+			return nil
+		},
+	}
+}
+
+// RequireExecutable requires the named program to be found in the PATH, with a hint for how to install it (e.g.
+// "see https://git-scm.com/downloads").
+func RequireExecutable(name, hint string) Requirement {
+	return Requirement{
+		Name: fmt.Sprintf("%s executable", name),
+		Check: func(*State) error {
+			if _, err := exec.LookPath(name); err != nil {
+				msg := fmt.Sprintf("%q was not found in the PATH", name)
+				if hint != "" {
+					msg += fmt.Sprintf(" (%s)", hint)
+				}
+				return errors.New(msg)
+			}
+			return nil
+		},
+	}
+}
+
+// RequireFreeDiskSpace requires at least the given number of bytes to be free where the application caches data
+// (the workspace cache dir when running within a workspace, see SetupConfig.WithWorkspace).
+func RequireFreeDiskSpace(bytes uint64) Requirement {
+	return Requirement{
+		Name: "free disk space",
+		Check: func(s *State) error {
+			dir, err := s.cacheDir()
+			if err != nil {
+				return nil
+			}
+			free, err := diskFree(existingParent(dir))
+			if err != nil {
+				return nil
+			}
+			if free < bytes {
+				return fmt.Errorf("%s free in %s, but at least %s is needed (free up disk space, or set XDG_CACHE_HOME to another location)", HumanBytes(clampInt64(free)), DisplayPath(dir), HumanBytes(clampInt64(bytes)))
+			}
+			return nil
+		},
+	}
+}
+
+// cacheDir returns the directory for data cached by the application.
+func (s *State) cacheDir() (string, error) {
+	if s.workspace.CacheDir != "" {
+		return s.workspace.CacheDir, nil
+	}
+	dir, err := userCacheDir(s.id.Name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, s.id.Name), nil
+}
+
+// existingParent returns the path, or its nearest parent that exists.
+func existingParent(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+func clampInt64(n uint64) int64 {
+	if n > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(n)
+}
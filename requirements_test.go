@@ -0,0 +1,85 @@
+package clio
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RequireExecutable(t *testing.T) {
+	require.NoError(t, RequireExecutable("go", "").Check(&State{}))
+
+	err := RequireExecutable("clio-does-not-exist", "see https://example.com/install").Check(&State{})
+	require.EqualError(t, err, `"clio-does-not-exist" was not found in the PATH (see https://example.com/install)`)
+}
+
+func Test_RequireFreeDiskSpace(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	s := &State{id: Identification{Name: "app"}}
+
+	require.NoError(t, RequireFreeDiskSpace(1).Check(s))
+	require.ErrorContains(t, RequireFreeDiskSpace(1<<62).Check(s), "but at least 4.0 EiB is needed")
+}
+
+func Test_RequirementsError(t *testing.T) {
+	one := &RequirementsError{Unmet: []RequirementResult{{Name: "git executable", Err: errors.New("not found")}}}
+	assert.Equal(t, "requirement not met: git executable: not found", one.Error())
+
+	many := &RequirementsError{Unmet: []RequirementResult{
+		{Name: "git executable", Err: errors.New("not found")},
+		{Name: "free disk space", Err: errors.New("full")},
+	}}
+	assert.Equal(t, "2 requirements not met:\n  - git executable: not found\n  - free disk space: full", many.Error())
+}
+
+func Test_Application_Requirements(t *testing.T) {
+	met := Requirement{Name: "met", Check: func(*State) error { return nil }}
+	unmet := Requirement{Name: "unmet", Check: func(*State) error { return errors.New("install it") }}
+
+	tests := []struct {
+		name    string
+		reqs    []Requirement
+		wantErr string
+	}{
+		{
+			name: "all met",
+			reqs: []Requirement{met},
+		},
+		{
+			name:    "unmet",
+			reqs:    []Requirement{met, unmet},
+			wantErr: "requirement not met: unmet: install it",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithRequirements(tt.reqs...))
+			root := app.SetupRootCommand(&cobra.Command{})
+
+			ran := false
+			root.AddCommand(app.SetupCommand(&cobra.Command{
+				Use: "sub",
+				RunE: func(cmd *cobra.Command, args []string) error {
+					ran = true
+					return nil
+				},
+			}))
+
+			root.SetArgs([]string{"sub"})
+			err := root.Execute()
+			if tt.wantErr != "" {
+				var reqErr *RequirementsError
+				require.ErrorAs(t, err, &reqErr)
+				assert.EqualError(t, err, tt.wantErr)
+				assert.False(t, ran)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, ran)
+			assert.Len(t, app.(*application).State().CheckRequirements(), len(tt.reqs))
+		})
+	}
+}
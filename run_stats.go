@@ -0,0 +1,109 @@
+package clio
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const runStatsFile = "last-run.json"
+
+// runStats describes the most recent command run, which is kept for the bug report (see WithBugReportCommand).
+type runStats struct {
+	Command    string    `json:"command"`
+	Invocation string    `json:"invocation"`
+	Started    time.Time `json:"started"`
+	Duration   string    `json:"duration"`
+	Events     int64     `json:"events"` // bus events handled by the UI
+	Panics     int64     `json:"panics"` // panics recovered from the UI
+	Error      string    `json:"error,omitempty"`
+}
+
+// eventHandled is called for each bus event given to the UI.
+func (r *runStats) eventHandled() {
+	if r == nil {
+		return
+	}
+	atomic.AddInt64(&r.Events, 1)
+}
+
+// panicked is called for each panic recovered from the UI.
+func (r *runStats) panicked() {
+	if r == nil {
+		return
+	}
+	atomic.AddInt64(&r.Panics, 1)
+}
+
+// startRunStats starts collecting stats for the command being run, which is only done when the bug report is
+// enabled (and never for the bug report command itself).
+func (a *application) startRunStats(cmd *cobra.Command) {
+	a.runStats = nil
+	if a.bugReportCmd == nil || cmd == a.bugReportCmd {
+		return
+	}
+	a.runStats = &runStats{Command: cmd.CommandPath(), Invocation: a.state.InvocationID(), Started: time.Now()}
+}
+
+// finishRunStats saves the stats of the completed run within the state dir.
+func (a *application) finishRunStats(err error) {
+	stats := a.runStats
+	if stats == nil {
+		return
+	}
+	stats.Duration = time.Since(stats.Started).Round(time.Millisecond).String()
+	if err != nil {
+		stats.Error = err.Error()
+	}
+
+	path, pathErr := runStatsPath(a.setupConfig.ID.Name)
+	if pathErr == nil {
+		if !a.state.WritableDir(filepath.Dir(path)) {
+			return
+		}
+		pathErr = a.writeRunStats(path, stats)
+	}
+	if pathErr != nil {
+		a.state.Logger.Debugf("unable to save run stats: %v", pathErr)
+	}
+}
+
+func (a *application) writeRunStats(path string, stats *runStats) error {
+	contents, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := a.state.CreateFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(contents)
+	return err
+}
+
+func runStatsPath(appName string) (string, error) {
+	dir, err := stateDir(appName)
+	if err != nil {
+		return "", fmt.Errorf("unable to determine state dir: %w", err)
+	}
+	return filepath.Join(dir, runStatsFile), nil
+}
+
+// readRunStats returns the saved stats of the most recent run (or nil if there are none).
+func readRunStats(appName string) ([]byte, error) {
+	path, err := runStatsPath(appName)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return contents, err
+}
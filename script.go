@@ -0,0 +1,232 @@
+package clio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"go.starlark.net/starlark"
+)
+
+// Hook scripts (see hooks.go) and starlark policies (see policy.go) are run with go.starlark.net. Scripts are
+// sandboxed: they have no access to files, the network, the environment, or the clock, and only reach the application
+// through the values given to them. Each load or call is limited in the number of steps it may take (and stops when
+// its context is canceled), and globals are frozen once a script is loaded, so that its functions can be called
+// concurrently.
+
+// scriptMaxSteps is the most steps of computation a script may take for each load or call.
+const scriptMaxSteps = 10_000_000
+
+// scriptError is an error running a script, at the position in the script where it happened.
+type scriptError struct {
+	pos string
+	msg string
+	err error // the cause, when from outside the script (e.g. the context, or a function given to the script)
+}
+
+func (e *scriptError) Error() string {
+	if e.pos == "" {
+		return e.msg
+	}
+	return fmt.Sprintf("%s: %s", e.pos, e.msg)
+}
+
+func (e *scriptError) Unwrap() error {
+	return e.err
+}
+
+// scriptModule is a loaded script, with its (frozen) global values.
+type scriptModule struct {
+	file    string
+	globals starlark.StringDict
+	print   func(msg string) // where print() writes (when set)
+}
+
+// loadScript runs the script, giving it the predeclared values (the API of the application).
+func loadScript(ctx context.Context, file, src string, predeclared starlark.StringDict, print func(msg string)) (*scriptModule, error) {
+	m := &scriptModule{file: file, print: print}
+	globals, err := runScript(ctx, m, func(thread *starlark.Thread) (starlark.StringDict, error) {
+		return starlark.ExecFile(thread, file, src, predeclared)
+	})
+	if err != nil {
+		return nil, err
+	}
+	globals.Freeze()
+	m.globals = globals
+	return m, nil
+}
+
+// function returns the global function with the given name, if the script defines one.
+func (m *scriptModule) function(name string) (*starlark.Function, bool) {
+	fn, ok := m.globals[name].(*starlark.Function)
+	return fn, ok
+}
+
+// call calls a function of the script.
+func (m *scriptModule) call(ctx context.Context, fn *starlark.Function, args ...starlark.Value) (starlark.Value, error) {
+	return runScript(ctx, m, func(thread *starlark.Thread) (starlark.Value, error) {
+		return starlark.Call(thread, fn, args, nil)
+	})
+}
+
+// runScript runs (part of) the script on a new thread, which is canceled when the context is.
+func runScript[T any](ctx context.Context, m *scriptModule, fn func(thread *starlark.Thread) (T, error)) (T, error) {
+	thread := &starlark.Thread{
+		Name: m.file,
+		Print: func(_ *starlark.Thread, msg string) {
+			if m.print != nil {
+				m.print(msg)
+			}
+		},
+	}
+	thread.SetMaxExecutionSteps(scriptMaxSteps)
+	if err := ctx.Err(); err != nil {
+		thread.Cancel(err.Error())
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			thread.Cancel(ctx.Err().Error())
+		case <-done:
+		}
+	}()
+
+	v, err := fn(thread)
+	if err != nil {
+		return v, newScriptError(ctx, err)
+	}
+	return v, nil
+}
+
+// newScriptError locates an error from evaluating a script at the innermost position within the script.
+func newScriptError(ctx context.Context, err error) error {
+	var evalErr *starlark.EvalError
+	if !errors.As(err, &evalErr) {
+		// syntax errors are located already
+		return err
+	}
+	e := &scriptError{msg: evalErr.Msg, err: evalErr.Unwrap()}
+	for i := range evalErr.CallStack {
+		if pos := evalErr.CallStack.At(i).Pos; pos.Filename() != "<builtin>" {
+			e.pos = fmt.Sprintf("%s:%d", pos.Filename(), pos.Line)
+			break
+		}
+	}
+	if ctx.Err() != nil {
+		e.err = ctx.Err()
+	}
+	return e
+}
+
+// scriptTakesOneArgument returns whether the function can be called with a single argument.
+func scriptTakesOneArgument(fn *starlark.Function) bool {
+	switch n := fn.NumParams() - fn.NumKwonlyParams(); {
+	case n == 0:
+		return fn.HasVarargs()
+	case n > 1:
+		return fn.ParamDefault(1) != nil
+	}
+	return true
+}
+
+// newScriptDict returns a frozen dict of the values.
+func newScriptDict(values map[string]string) *starlark.Dict {
+	d := starlark.NewDict(len(values))
+	for k, v := range values {
+		_ = d.SetKey(starlark.String(k), starlark.String(v))
+	}
+	d.Freeze()
+	return d
+}
+
+// scriptString returns the value as given to str().
+func scriptString(v starlark.Value) string {
+	if s, ok := starlark.AsString(v); ok {
+		return s
+	}
+	return v.String()
+}
+
+// toScriptValue converts a JSON value (as decoded into an any, see normalizeJSON) into a script value, with whole
+// numbers as ints.
+func toScriptValue(v any) starlark.Value {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		d := starlark.NewDict(len(v))
+		for _, k := range keys {
+			_ = d.SetKey(starlark.String(k), toScriptValue(v[k]))
+		}
+		return d
+	case []any:
+		elems := make([]starlark.Value, len(v))
+		for i, e := range v {
+			elems[i] = toScriptValue(e)
+		}
+		return starlark.NewList(elems)
+	case string:
+		return starlark.String(v)
+	case bool:
+		return starlark.Bool(v)
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return starlark.MakeInt64(int64(v))
+		}
+		return starlark.Float(v)
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return starlark.MakeInt64(n)
+		}
+		f, _ := v.Float64()
+		return starlark.Float(f)
+	}
+	return starlark.None
+}
+
+// fromScriptValue converts a script value into a JSON value (with dict keys as strings).
+func fromScriptValue(v starlark.Value) any {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil
+	case starlark.Bool:
+		return bool(v)
+	case starlark.String:
+		return string(v)
+	case starlark.Int:
+		if n, ok := v.Int64(); ok {
+			return n
+		}
+		return float64(v.Float())
+	case starlark.Float:
+		return float64(v)
+	case *starlark.Dict:
+		m := make(map[string]any, v.Len())
+		for _, item := range v.Items() {
+			m[scriptString(item[0])] = fromScriptValue(item[1])
+		}
+		return m
+	case starlark.Iterable:
+		var l []any
+		iter := v.Iterate()
+		defer iter.Done()
+		var e starlark.Value
+		for iter.Next(&e) {
+			l = append(l, fromScriptValue(e))
+		}
+		if l == nil {
+			l = []any{}
+		}
+		return l
+	}
+	return v.String()
+}
@@ -0,0 +1,109 @@
+package clio
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.starlark.net/starlark"
+)
+
+func Test_scriptModule_call(t *testing.T) {
+	var printed []string
+	m, err := loadScript(context.Background(), "test.star", `
+seen = []
+
+def remember(x):
+    seen.append(x)
+
+def label(result):
+    print("labeling", result["name"])
+    result["label"] = result["name"].upper()
+    result["sizes"] = [s * 2 for s in result["sizes"]]
+    return result
+`, nil, func(msg string) { printed = append(printed, msg) })
+	require.NoError(t, err)
+
+	label, ok := m.function("label")
+	require.True(t, ok)
+	got, err := m.call(context.Background(), label, toScriptValue(map[string]any{"name": "a", "sizes": []any{1.0, 1.5}}))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"label": "A", "name": "a", "sizes": []any{int64(2), 3.0}}, fromScriptValue(got))
+	assert.Equal(t, []string{"labeling a"}, printed)
+
+	remember, ok := m.function("remember")
+	require.True(t, ok)
+	_, err = m.call(context.Background(), remember, starlark.MakeInt(1))
+	require.EqualError(t, err, "test.star:5: append: cannot append to frozen list", "globals are frozen once loaded")
+
+	_, ok = m.function("seen")
+	assert.False(t, ok)
+}
+
+func Test_loadScript_errors(t *testing.T) {
+	cause := errors.New("denied")
+	predeclared := starlark.StringDict{
+		"deny": starlark.NewBuiltin("deny", func(*starlark.Thread, *starlark.Builtin, starlark.Tuple, []starlark.Tuple) (starlark.Value, error) {
+			return nil, cause
+		}),
+	}
+
+	_, err := loadScript(context.Background(), "test.star", "x = 1\ny = (1 +\n", nil, nil)
+	require.ErrorContains(t, err, "test.star:3:1:")
+
+	_, err = loadScript(context.Background(), "test.star", "x = 1\ny = {}['a']\n", nil, nil)
+	require.EqualError(t, err, `test.star:2: key "a" not in dict`)
+
+	_, err = loadScript(context.Background(), "test.star", "def f():\n    deny()\n\nf()\n", predeclared, nil)
+	require.ErrorIs(t, err, cause, "errors of the functions given to scripts are kept")
+	require.EqualError(t, err, "test.star:2: denied")
+}
+
+func Test_scriptModule_call_canceled(t *testing.T) {
+	m, err := loadScript(context.Background(), "test.star", `
+def spin(n):
+    for i in range(n):
+        for j in range(n):
+            pass
+`, nil, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	spin, _ := m.function("spin")
+	_, err = m.call(ctx, spin, starlark.MakeInt(900))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = m.call(context.Background(), spin, starlark.MakeInt(10_000))
+	require.ErrorContains(t, err, "too many steps")
+}
+
+func Test_scriptTakesOneArgument(t *testing.T) {
+	m, err := loadScript(context.Background(), "test.star", `
+def none():
+    pass
+
+def one(a):
+    pass
+
+def two(a, b):
+    pass
+
+def defaulted(a, b = 1):
+    pass
+
+def variadic(*args):
+    pass
+`, nil, nil)
+	require.NoError(t, err)
+
+	for name, want := range map[string]bool{"none": false, "one": true, "two": false, "defaulted": true, "variadic": true} {
+		fn, ok := m.function(name)
+		require.True(t, ok)
+		assert.Equal(t, want, scriptTakesOneArgument(fn), name)
+	}
+}
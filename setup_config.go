@@ -1,6 +1,10 @@
 package clio
 
 import (
+	"reflect"
+	"strings"
+	"time"
+
 	"github.com/wagoodman/go-partybus"
 
 	"github.com/boss-net/fangs"
@@ -14,8 +18,21 @@ type SetupConfig struct {
 	ID Identification
 
 	// Default configuration items that end up in the target application configuration
-	DefaultLoggingConfig     *LoggingConfig
-	DefaultDevelopmentConfig *DevelopmentConfig
+	DefaultLoggingConfig       *LoggingConfig
+	DefaultDevelopmentConfig   *DevelopmentConfig
+	DefaultTempConfig          *TempConfig
+	DefaultPermissions         *PermissionsConfig
+	DefaultTelemetryConfig     *TelemetryConfig
+	DefaultUIConfig            *UIConfig
+	DefaultNetworkConfig       *NetworkConfig
+	DefaultHTTPConfig          *HTTPConfig
+	DefaultNotificationsConfig *NotificationsConfig
+	DefaultHooksConfig         *HooksConfig
+	DefaultPolicyConfig        *PolicyConfig
+	DefaultStorageConfig       *StorageConfig
+	DefaultPriorityConfig      *PriorityConfig
+	DefaultLocksConfig         *LocksConfig
+	DefaultStrictModeConfig    *StrictModeConfig
 
 	// Items required for setting up the application (clio-only configuration)
 	FangsConfig       fangs.Config
@@ -23,7 +40,176 @@ type SetupConfig struct {
 	LoggerConstructor LoggerConstructor
 	UIConstructor     UIConstructor
 	Initializers      []Initializer
+	Finalizers        []Finalizer
 	postConstructs    []postConstruct
+
+	// UISections are the config sections of the UIs, nested under "ui.<name>" (see ConfigurableUI)
+	UISections map[string]any
+
+	// TelemetryExporters receive logs and metrics when telemetry is enabled in the application config
+	TelemetryExporters TelemetryExporters
+
+	// TracePropagator carries trace context through bus events published with State.Publish
+	TracePropagator TracePropagator
+
+	// BusBridgeAddress is where bus events are served to remote subscribers over a websocket or as a server-sent event
+	// (SSE) stream (disabled when empty)
+	BusBridgeAddress string
+	// BusBridgeControlTypes are the event types remote subscribers may publish on the bus
+	BusBridgeControlTypes []partybus.EventType
+
+	// DaemonSocket is where the daemon serves commands (default: within the user cache dir)
+	DaemonSocket string
+	// DaemonVersionSkew is how invocations handle a running daemon of an incompatible version, as determined by
+	// DaemonVersionCompatible (default: VersionSkewWarn when the versions differ, see WithDaemonVersionSkew)
+	DaemonVersionSkew       VersionSkewPolicy
+	DaemonVersionCompatible VersionCompatibility
+
+	// InstanceHandler handles invocations forwarded to the running instance (see WithSingleInstance)
+	InstanceHandler InstanceHandler
+	// InstanceSocket is where the running instance accepts invocations (default: within the user cache dir)
+	InstanceSocket string
+
+	// Checkpoints enables checkpointing command progress (see WithCheckpoints)
+	Checkpoints bool
+	// CheckpointDir is where checkpoints are saved (default: within the state dir)
+	CheckpointDir string
+	// CheckpointInterval is how often State.CheckpointPeriodically saves progress
+	CheckpointInterval time.Duration
+
+	// JobHandler processes jobs from the job queue within the daemon (see WithJobQueue)
+	JobHandler JobHandler
+	// JobWorkers is the number of jobs processed concurrently
+	JobWorkers int
+	// JobQueueDir is where jobs are stored (default: within the state dir)
+	JobQueueDir string
+	// JobRetention is how long finished jobs are kept (default: DefaultJobRetention, negative keeps them forever)
+	JobRetention time.Duration
+
+	// DotEnv loads environment variables from a .env file before loading configuration (see WithDotEnv)
+	DotEnv bool
+	// DotEnvFile is the .env file to load (default: .env in the current directory, which is optional)
+	DotEnvFile string
+
+	// ConfigVersion is the current version of the config format, and MinConfigVersion the oldest version still
+	// supported (see WithConfigVersion)
+	ConfigVersion    int
+	MinConfigVersion int
+	// ConfigMigrations update config files from the version (the key) to the next version
+	ConfigMigrations map[int]ConfigMigration
+
+	// StoreVersion is the current version of the state store (see WithStoreVersion), and StoreMigrations update the
+	// store from the version (the key) to the next version
+	StoreVersion    int
+	StoreMigrations map[int]StoreMigration
+
+	// History records each invocation in the state store, keeping the most recent HistoryLimit invocations (default:
+	// DefaultHistoryLimit, see WithHistory)
+	History      bool
+	HistoryLimit int
+
+	// StageTimings records the timing of the stages of each run in the state store, keeping the most recent
+	// StageTimingsLimit runs of each command (default: DefaultStageTimingsLimit, see WithStageTimings)
+	StageTimings      bool
+	StageTimingsLimit int
+
+	// Requirements are checked once the configuration is loaded, before running any command (see WithRequirements)
+	Requirements []Requirement
+
+	// StrictConfig rejects config files containing keys that are not used by any configuration
+	StrictConfig bool
+
+	// SystemConfig layers the user (or project) config file over a system-wide config file (see WithSystemConfig)
+	SystemConfig bool
+	// SystemConfigDir is where the system-wide config file is found (default: /etc/<app> or %ProgramData%\<app>)
+	SystemConfigDir string
+	// LockedConfigKeys are config keys that only the system-wide config file may set
+	LockedConfigKeys []string
+
+	// Workspace detects the workspace (project) root, with its own config file and cache and state dirs (see
+	// WithWorkspace)
+	Workspace bool
+	// WorkspaceMarkers are the files (or directories) marking a workspace root (default: .<app>, .git, .hg, .svn)
+	WorkspaceMarkers []string
+
+	// ConfigDocument selects a single document (1-based) of a multi-document yaml config file (see WithConfigDocument)
+	ConfigDocument int
+
+	// ExpandEnv expands environment variable references within config file values (see WithEnvExpansion)
+	ExpandEnv bool
+
+	// PrefetchResources are the resources the "prefetch" command downloads into the cache (see WithPrefetch)
+	PrefetchResources []PrefetchResource
+
+	// ConfigEncoders control how configs of each type appear in debug output (see WithConfigEncoder)
+	ConfigEncoders map[reflect.Type]ConfigEncoderFunc
+
+	// RunTimeout bounds how long commands may run before they are cancelled (see WithRunTimeout)
+	RunTimeout time.Duration
+	// PhaseTimeouts bound the contexts of the setup and teardown phases (see WithPhaseTimeout)
+	PhaseTimeouts map[Phase]time.Duration
+
+	// CompletionTimeout bounds how long completion functions may take (default: 2s, see WithCompletionTimeout)
+	CompletionTimeout time.Duration
+
+	// ControlServer serves the control API for running instances (see WithControlServer)
+	ControlServer bool
+	// ControlSocketDir is where running instances create their control sockets (default: within the user cache dir)
+	ControlSocketDir string
+
+	// ErrorPresenter shows the error a command failed with (default: DefaultErrorPresenter, see Application.Execute)
+	ErrorPresenter ErrorPresenter
+	errorExitCodes []errorExitCode
+
+	// HTTPMiddlewares wrap the transport of the client from State.HTTPClient (see WithHTTPMiddleware)
+	HTTPMiddlewares []HTTPMiddleware
+
+	// UploadDestinations upload reports to URLs with the scheme of the key (see WithUploadDestination)
+	UploadDestinations map[string]UploadDestination
+
+	// Authenticator logs in to the service of the application (see WithAuth), and CredentialStore keeps the credentials
+	// (see WithCredentialStore)
+	Authenticator   Authenticator
+	CredentialStore CredentialStore
+
+	// FaultPoints are the names of the points where faults may be injected (see WithFaultPoints)
+	FaultPoints []string
+
+	// HookPoints are the names of the extension points where hook scripts are run (see WithHookPoints)
+	HookPoints []string
+
+	// PolicyPoints are the names of the decision points where policies are evaluated (see WithPolicyPoints), by the
+	// PolicyEvaluators (see WithPolicyEvaluator) and those configured by the user
+	PolicyPoints     []string
+	PolicyEvaluators []PolicyEvaluator
+
+	// ReplaceableLogger allows the logger to be replaced (or the log level changed) while in use (see
+	// WithReplaceableLogger)
+	ReplaceableLogger bool
+
+	// DisableUIOnPanic tears down a UI that panics while handling an event instead of continuing to send it events
+	// (panics are always recovered and logged, see WithDisableUIOnPanic)
+	DisableUIOnPanic bool
+
+	// DefaultCommand is the name of the subcommand to run when the root command is invoked without a subcommand
+	DefaultCommand string
+
+	// CommandSuggestions replaces cobra's handling of unknown commands for the root command, suggesting commands by
+	// alias and plugins as well (see WithCommandSuggestions)
+	CommandSuggestions bool
+
+	// PluginCatalog provides plugins to consider when suggesting commands for an unknown command, and
+	// PluginInstallCommand is the subcommand used to install plugins (default: "plugin install")
+	PluginCatalog        PluginCatalog
+	PluginInstallCommand string
+
+	// RootPolicy determines how the application behaves when run as root or Administrator, and DropPrivilegesTo is
+	// the user to switch to (when privileged) before each command runs
+	RootPolicy       RootPolicy
+	DropPrivilegesTo string
+
+	// ShowEnvInFlagHelp appends the environment variable for each flag to the flag usage (e.g. "[APP_LOG_LEVEL]")
+	ShowEnvInFlagHelp bool
 }
 
 func NewSetupConfig(id Identification) *SetupConfig {
@@ -36,14 +222,38 @@ func NewSetupConfig(id Identification) *SetupConfig {
 		DefaultLoggingConfig: &LoggingConfig{
 			Level: logger.WarnLevel,
 		},
+		DefaultTempConfig:    &TempConfig{},
+		DefaultPermissions:   &PermissionsConfig{},
+		DefaultUIConfig:      &UIConfig{Unicode: UnicodeAuto},
+		DefaultStorageConfig: &StorageConfig{},
+		DefaultLocksConfig:   &LocksConfig{Wait: DefaultLockWait},
 		// note: no ui selector or dev options by default...
 	}
 }
 
+// WithUI sets the UIs of the application, registering the config section of each UI which implements
+// ConfigurableUI.
 func (c *SetupConfig) WithUI(uis ...UI) *SetupConfig {
 	c.UIConstructor = func(cfg Config) ([]UI, error) {
 		return uis, nil
 	}
+	for _, ui := range uis {
+		if configurable, ok := ui.(ConfigurableUI); ok {
+			c.WithUISection(configurable.ConfigSection())
+		}
+	}
+	return c
+}
+
+// WithUISection registers a UI config section, nested under "ui.<name>" in the application config, where cfg is a
+// pointer to the config struct (holding the defaults) which is updated in place as the configuration is loaded. This
+// is needed for UIs created with a UIConstructor, which are not created until the configuration is loaded (see
+// ConfigurableUI).
+func (c *SetupConfig) WithUISection(name string, cfg any) *SetupConfig {
+	if c.UISections == nil {
+		c.UISections = map[string]any{}
+	}
+	c.UISections[name] = cfg
 	return c
 }
 
@@ -84,6 +294,472 @@ func (c *SetupConfig) WithLoggingConfig(cfg LoggingConfig) *SetupConfig {
 	return c
 }
 
+func (c *SetupConfig) WithUIConfig(cfg UIConfig) *SetupConfig {
+	c.DefaultUIConfig = &cfg
+	return c
+}
+
+func (c *SetupConfig) WithTempConfig(cfg TempConfig) *SetupConfig {
+	c.DefaultTempConfig = &cfg
+	return c
+}
+
+// WithPermissions sets the default modes for files and directories written by the application (which can be
+// overridden by the user in the application config).
+func (c *SetupConfig) WithPermissions(cfg PermissionsConfig) *SetupConfig {
+	c.DefaultPermissions = &cfg
+	return c
+}
+
+// WithProcessPriority adds the "priority" section to the application config, setting the CPU niceness and IO
+// priority of the process (and the processes it starts with State.Exec), starting from the given priority.
+func (c *SetupConfig) WithProcessPriority(cfg PriorityConfig) *SetupConfig {
+	c.DefaultPriorityConfig = &cfg
+	return c
+}
+
+// WithTelemetry adds the "telemetry" section to the application config, exporting logs and metrics (including
+// application metrics, see State.Metrics) with the given exporters when enabled by the user. Without exporters, logs
+// and metrics are exported to the OTLP/HTTP endpoint configured by the user. Metrics are collected by clio rather than
+// registered with an OTel MeterProvider (see TelemetryExporters).
+func (c *SetupConfig) WithTelemetry(exporters TelemetryExporters) *SetupConfig {
+	c.TelemetryExporters = exporters
+	if c.DefaultTelemetryConfig == nil {
+		otlp := exporters.Logs == nil && exporters.Metrics == nil
+		c.DefaultTelemetryConfig = &TelemetryConfig{Logs: otlp || exporters.Logs != nil, Metrics: otlp || exporters.Metrics != nil}
+	}
+	return c
+}
+
+// WithNetworkPolicy adds the "network" section to the application config (and the --offline flag with
+// WithGlobalConfigFlag), restricting which hosts the application may connect to, starting from the given policy. The
+// policy is enforced for all requests made with State.HTTPClient and State.Download (other connections should be
+// checked with State.CheckNetwork).
+func (c *SetupConfig) WithNetworkPolicy(cfg NetworkConfig) *SetupConfig {
+	c.DefaultNetworkConfig = &cfg
+	return c
+}
+
+// WithHTTPConfig enables the user to configure retries and caching of requests made with the client from
+// State.HTTPClient (with the "http" section of the application config), starting from the given defaults.
+func (c *SetupConfig) WithHTTPConfig(cfg HTTPConfig) *SetupConfig {
+	c.DefaultHTTPConfig = &cfg
+	return c
+}
+
+// WithHTTPMiddleware wraps the transport of the client from State.HTTPClient with the given middlewares (e.g. to add
+// authentication or user agent headers), which see each request before it is cached, retried, and logged.
+func (c *SetupConfig) WithHTTPMiddleware(middlewares ...HTTPMiddleware) *SetupConfig {
+	c.HTTPMiddlewares = append(c.HTTPMiddlewares, middlewares...)
+	return c
+}
+
+// WithUploadDestination uploads reports written to URLs with the given scheme (e.g. with --output-file, or
+// State.Upload) with the given destination, which may replace a built-in destination (e.g. "s3").
+func (c *SetupConfig) WithUploadDestination(scheme string, destination UploadDestination) *SetupConfig {
+	if c.UploadDestinations == nil {
+		c.UploadDestinations = map[string]UploadDestination{}
+	}
+	c.UploadDestinations[strings.ToLower(scheme)] = destination
+	return c
+}
+
+// WithPromptIntegration adds a "shell-integration" command writing a bash or zsh snippet which shows the status of
+// the application (set with State.SetPromptStatus, e.g. the current context) in the shell prompt. The prompt is
+// rendered by a hidden command which only reads the cached status, so it stays fast enough to run for every prompt.
+func (c *SetupConfig) WithPromptIntegration() *SetupConfig {
+	return c.withPostConstructs(func(a *application) {
+		a.setupPromptCommands()
+	})
+}
+
+// WithTracePropagator carries the trace context of events published with State.Publish through to the UI
+// (see ContextHandler) and other subscribers (see State.EventContext).
+func (c *SetupConfig) WithTracePropagator(propagator TracePropagator) *SetupConfig {
+	c.TracePropagator = propagator
+	return c
+}
+
+// WithBusBridge serves all bus events to remote subscribers over a websocket (or as a server-sent event stream) on the
+// given address (e.g. "localhost:7070") while the application runs, accepting the given control event types back from
+// them over the websocket (or as http POST requests), see BusBridge. Control events must be sent with the token written
+// to "bus-bridge.token" within the state dir of the application (e.g. ~/.local/state/<app>), which is only readable by
+// the current user.
+func (c *SetupConfig) WithBusBridge(address string, controlTypes ...partybus.EventType) *SetupConfig {
+	c.BusBridgeAddress = address
+	c.BusBridgeControlTypes = controlTypes
+	return c
+}
+
+// WithDaemon adds a "daemon" command which keeps the application running in the background, serving commands on a
+// local socket (the default location is used when empty). While the daemon is running, all other invocations of the
+// application forward their command line, working directory, environment, and stdin to it and show the output.
+// Commands run within the daemon one at a time, sharing the State it has set up once (the bus, the store, the logger,
+// and the initializers), with the configuration, working directory, environment, and standard streams of their own
+// invocation. Note that the job queue workers of the daemon see those of the command being run meanwhile.
+func (c *SetupConfig) WithDaemon(socket string) *SetupConfig {
+	c.DaemonSocket = socket
+	return c.withPostConstructs(func(a *application) {
+		a.setupDaemon()
+	})
+}
+
+// WithSingleInstance allows only one instance of the application to run at a time. While a command is running, all
+// other invocations forward their command line, working directory, and environment to the running instance, where
+// they are given to the handler, and show the output from the handler instead of running (e.g. an editor opening
+// files in an existing window). The instance socket is created at the given path (the default location is used when
+// empty).
+func (c *SetupConfig) WithSingleInstance(socket string, handler InstanceHandler) *SetupConfig {
+	c.InstanceSocket = socket
+	c.InstanceHandler = handler
+	return c
+}
+
+// WithCheckpoints allows commands to save their progress (see State.Checkpoint and State.CheckpointPeriodically,
+// which saves at the given interval) so that an interrupted or failed command can be continued with --resume (see
+// State.Resume). Checkpoints are kept per invocation (command, arguments, and flags) until the command succeeds.
+func (c *SetupConfig) WithCheckpoints(interval time.Duration) *SetupConfig {
+	c.Checkpoints = true
+	c.CheckpointInterval = interval
+	return c.withPostConstructs(func(a *application) {
+		a.AddPersistentFlags(a.root, &a.state.checkpoints.cfg)
+	})
+}
+
+// WithJobQueue adds a persistent job queue (see State.JobQueue), where jobs are processed by the daemon (see
+// WithDaemon) with the given number of workers. Jobs can also be added to a running daemon through the control API
+// (see WithControlServer). Within the daemon, handlers run one at a time and never alongside the commands the daemon
+// runs, since those take over the working directory, environment, and standard streams of the process.
+func (c *SetupConfig) WithJobQueue(workers int, handler JobHandler) *SetupConfig {
+	c.JobWorkers = workers
+	c.JobHandler = handler
+	return c
+}
+
+// WithJobRetention sets how long finished (done or failed) jobs are kept in the job queue (see WithJobQueue) before
+// the daemon removes them, where a negative retention keeps them until removed with JobQueue.Remove.
+func (c *SetupConfig) WithJobRetention(retention time.Duration) *SetupConfig {
+	c.JobRetention = retention
+	return c
+}
+
+// WithDotEnv loads environment variables from the given .env file (or an optional .env file in the current directory
+// when empty) before configuration is loaded, so that the variables can be used for configuration (e.g. APP_LOG_LEVEL).
+// Variables already set in the environment take precedence. Values of variables that look sensitive (e.g. tokens and
+// passwords) are redacted from all output.
+func (c *SetupConfig) WithDotEnv(path string) *SetupConfig {
+	c.DotEnv = true
+	c.DotEnvFile = path
+	return c
+}
+
+// WithConfigVersion checks the "config-version" declared by config files against the versions of the config format
+// supported by the application: from the oldest (min) to the current version. Files for a newer version are
+// rejected, since their values may otherwise be silently misinterpreted, as are files for an older version unless
+// there are migrations (see WithConfigMigration) to bring them up to a supported version. Files that do not declare
+// a version are read as the current version.
+func (c *SetupConfig) WithConfigVersion(current, min int) *SetupConfig {
+	c.ConfigVersion = current
+	c.MinConfigVersion = min
+	return c
+}
+
+// WithConfigMigration registers a migration updating the values of config files from the given config version to
+// the next. Migrations are applied in order when loading config files for older versions (see WithConfigVersion),
+// and the user is warned to update the file.
+func (c *SetupConfig) WithConfigMigration(from int, migration ConfigMigration) *SetupConfig {
+	if c.ConfigMigrations == nil {
+		c.ConfigMigrations = map[int]ConfigMigration{}
+	}
+	c.ConfigMigrations[from] = migration
+	return c
+}
+
+// WithStoreVersion sets the current version of the values kept in the state store (see State.Store). Values written
+// by older versions of the application are migrated (see WithStoreMigration) when the store is read, while a store
+// written by a newer version is rejected.
+func (c *SetupConfig) WithStoreVersion(version int) *SetupConfig {
+	c.StoreVersion = version
+	return c
+}
+
+// WithStoreMigration registers a migration updating the values of the state store from the given store version to
+// the next. Migrations are applied in order when reading a store written for an older version (see WithStoreVersion).
+func (c *SetupConfig) WithStoreMigration(from int, migration StoreMigration) *SetupConfig {
+	if c.StoreMigrations == nil {
+		c.StoreMigrations = map[int]StoreMigration{}
+	}
+	c.StoreMigrations[from] = migration
+	return c
+}
+
+// WithRequirements declares conditions the runtime environment must meet for the application to work (e.g.
+// RequireExecutable("git", ...)), which are checked once the configuration is loaded, failing with all unmet
+// requirements and how to address them. The same checks are available to diagnostic commands with
+// State.CheckRequirements.
+func (c *SetupConfig) WithRequirements(requirements ...Requirement) *SetupConfig {
+	c.Requirements = append(c.Requirements, requirements...)
+	return c
+}
+
+// WithStrictConfig rejects config files containing keys that are not used by any configuration, listing them with
+// suggestions (typos in config files otherwise do nothing). Users can also opt in with "config.strict: true" in the
+// config file, or with --strict-config (see WithStrictConfigFlag).
+func (c *SetupConfig) WithStrictConfig() *SetupConfig {
+	c.StrictConfig = true
+	return c
+}
+
+// WithStrictConfigFlag adds a --strict-config flag, which rejects config files containing unknown keys.
+func (c *SetupConfig) WithStrictConfigFlag() *SetupConfig {
+	return c.withPostConstructs(func(a *application) {
+		a.root.PersistentFlags().BoolVar(&a.strictConfig, "strict-config", false, "fail when the config file contains unknown keys")
+	})
+}
+
+// WithSystemConfig layers the user (or project) config file over a system-wide config file (config.yaml within
+// /etc/<app>, or %ProgramData%\<app> on windows, unless a dir is given), which is useful for organization-wide
+// defaults. The given keys (or sections) are locked to the system-wide values: setting them in the user config file,
+// the environment, or with flags is rejected with a LockedConfigError. Only yaml, json, and toml config files are layered.
+func (c *SetupConfig) WithSystemConfig(dir string, lockedKeys ...string) *SetupConfig {
+	c.SystemConfig = true
+	c.SystemConfigDir = dir
+	c.LockedConfigKeys = append(c.LockedConfigKeys, lockedKeys...)
+	return c
+}
+
+// WithWorkspace detects the workspace (project) the application is running within: the nearest directory, from the
+// working directory up, containing any of the given marker files (.<app>, .git, .hg, or .svn by default), or the
+// directory given with the --workspace flag. The workspace config file (.<app>.yaml or .<app>/config.yaml within the
+// root) is layered over the user config file, and the workspace has its own cache and state dirs (see
+// State.Workspace), so that users working across many projects (e.g. within a monorepo) get isolated caches and
+// local configuration automatically.
+func (c *SetupConfig) WithWorkspace(markers ...string) *SetupConfig {
+	c.Workspace = true
+	c.WorkspaceMarkers = append(c.WorkspaceMarkers, markers...)
+	return c.withPostConstructs(func(a *application) {
+		a.AddPersistentFlags(a.root, &a.state.workspaceCfg)
+	})
+}
+
+// WithConfigDocument selects a single document (1-based) of a multi-document yaml config file. By default, all
+// documents are merged, with values in later documents taking precedence.
+func (c *SetupConfig) WithConfigDocument(document int) *SetupConfig {
+	c.ConfigDocument = document
+	return c
+}
+
+// WithEnvExpansion expands "${VAR}" and "${VAR:-default}" references within string values of config files when they
+// are loaded (the default is used when the variable is unset or empty). Use "$$" for a literal "$" (e.g. "$${VAR}").
+// Expansions are available from State.ConfigExpansion, and expanded values of variables that look sensitive (e.g.
+// tokens and passwords) are redacted from all output.
+func (c *SetupConfig) WithEnvExpansion() *SetupConfig {
+	c.ExpandEnv = true
+	return c
+}
+
+// WithRunTimeout cancels commands that run longer than the timeout, which then fail with a CancelledError (with
+// CancelTimeout as the reason) and exit with ExitCodeTimeout.
+func (c *SetupConfig) WithRunTimeout(timeout time.Duration) *SetupConfig {
+	c.RunTimeout = timeout
+	return c
+}
+
+// WithCompletionTimeout sets how long completion functions (see Application.RegisterFlagCompletion) may take before
+// the shell is given no suggestions, which keeps the shell responsive when live lookups are slow.
+func (c *SetupConfig) WithCompletionTimeout(timeout time.Duration) *SetupConfig {
+	c.CompletionTimeout = timeout
+	return c
+}
+
+// WithControlServer serves a control API (status, config, log level changes, cancel, and shutdown) on a unix socket
+// for each running instance, creating sockets within the given directory (the default location is used when empty).
+// A "ctl" command is added as the client (e.g. "app ctl status").
+func (c *SetupConfig) WithControlServer(socketDir string) *SetupConfig {
+	c.ControlServer = true
+	c.ControlSocketDir = socketDir
+	return c.withPostConstructs(func(a *application) {
+		a.setupControlCommand()
+	})
+}
+
+// WithFindCommand adds a "find" command, which fuzzy-searches the names, aliases, flags, and help text of all commands
+// (e.g. "app find pull image"), showing the best matches with their usage. This is useful for large command trees.
+func (c *SetupConfig) WithFindCommand() *SetupConfig {
+	return c.withPostConstructs(func(a *application) {
+		a.setupFindCommand()
+	})
+}
+
+// WithErrorPresenter sets how Application.Execute shows the error a command failed with.
+func (c *SetupConfig) WithErrorPresenter(presenter ErrorPresenter) *SetupConfig {
+	c.ErrorPresenter = presenter
+	return c
+}
+
+// WithErrorExitCode exits with the given code when a command fails with an error matching the target (see errors.Is),
+// when run with Application.Execute. The first matching target is used.
+func (c *SetupConfig) WithErrorExitCode(target error, code int) *SetupConfig {
+	c.errorExitCodes = append(c.errorExitCodes, errorExitCode{target: target, code: code})
+	return c
+}
+
+// WithBugReportCommand adds a "bug-report" command, which writes an archive the user can attach to an issue: the
+// version, the redacted configuration (with where each value came from), the detected environment, the end of the
+// log file, and stats from the most recent run.
+func (c *SetupConfig) WithBugReportCommand() *SetupConfig {
+	return c.withPostConstructs(func(a *application) {
+		a.setupBugReportCommand()
+	})
+}
+
+// WithHistory records each invocation (the command, arguments and flags, duration, and exit code) in the state store,
+// keeping the most recent invocations (DefaultHistoryLimit when not positive), and adds a "history" command listing
+// them and a "rerun" command running one of them again. Flags that look like they hold secrets are not recorded, and
+// values known to the redact store are redacted.
+func (c *SetupConfig) WithHistory(limit int) *SetupConfig {
+	c.History = true
+	c.HistoryLimit = limit
+	return c.withPostConstructs(func(a *application) {
+		a.setupHistoryCommands()
+	})
+}
+
+// WithStageTimings records how long each stage (see State.StartStage) of each run took in the state store, keeping the
+// most recent runs of each command (DefaultStageTimingsLimit when not positive), and adds a "stats timings" command
+// summarizing them, which also exports them as JSON (with --output json) for tracking performance over time.
+func (c *SetupConfig) WithStageTimings(limit int) *SetupConfig {
+	c.StageTimings = true
+	c.StageTimingsLimit = limit
+	return c.withPostConstructs(func(a *application) {
+		a.setupStageTimingsCommands()
+	})
+}
+
+// WithExamplesCommand adds a hidden "examples" command listing the examples declared on all commands (see
+// Application.AddExamples), and an "examples test" command running them as smoke tests (e.g. in CI), failing when any
+// exits with another exit code than expected.
+func (c *SetupConfig) WithExamplesCommand() *SetupConfig {
+	return c.withPostConstructs(func(a *application) {
+		a.setupExamplesCommand()
+	})
+}
+
+// WithPrefetch adds a "prefetch" command (also "warmup"), which downloads the resources into the cache ahead of time
+// with progress (see PrefetchEvent) and integrity verification, where commands find them with State.Prefetched. The
+// "prefetch export" and "prefetch import" subcommands move the resources to machines without network access as a
+// bundle.
+func (c *SetupConfig) WithPrefetch(resources ...PrefetchResource) *SetupConfig {
+	c.PrefetchResources = append(c.PrefetchResources, resources...)
+	return c.withPostConstructs(func(a *application) {
+		a.setupPrefetchCommands()
+	})
+}
+
+// WithStateBundleCommands adds "state export" and "state import" commands, which write the cache, state, and
+// configuration of the application (or the parts selected with --include) to a bundle without secrets, and install
+// such a bundle on another machine (see State.ExportBundle).
+func (c *SetupConfig) WithStateBundleCommands() *SetupConfig {
+	return c.withPostConstructs(func(a *application) {
+		a.setupStateBundleCommands()
+	})
+}
+
+// WithConfigWizardCommand adds a "config wizard" command, which prompts for each value of the configs of all commands
+// (showing the current value as the default, validating each answer, and masking secrets) and writes the result to
+// a config file.
+func (c *SetupConfig) WithConfigWizardCommand() *SetupConfig {
+	return c.withPostConstructs(func(a *application) {
+		a.setupConfigWizardCommand()
+	})
+}
+
+// WithConfigDiffCommand adds a "config diff" command, which shows only the config values that differ from the
+// defaults, along with where each value came from (a flag, an environment variable, or a config file).
+func (c *SetupConfig) WithConfigDiffCommand() *SetupConfig {
+	return c.withPostConstructs(func(a *application) {
+		a.setupConfigDiffCommand()
+	})
+}
+
+// WithAuth adds "login", "logout", and "whoami" commands, logging in to the service of the application with the given
+// authenticator (e.g. an APIKeyAuth, or the device code flow of the service). Credentials are kept in the system
+// keyring (see WithCredentialStore), and commands get them with State.Credentials, which renews them once they expire
+// when the authenticator is a CredentialsRefresher.
+func (c *SetupConfig) WithAuth(auth Authenticator) *SetupConfig {
+	c.Authenticator = auth
+	return c.withPostConstructs(func(a *application) {
+		a.setupAuthCommands()
+	})
+}
+
+// WithCredentialStore keeps secrets of the application (such as the credentials from logging in, see WithAuth) in the
+// given store, instead of the system keyring.
+func (c *SetupConfig) WithCredentialStore(store CredentialStore) *SetupConfig {
+	c.CredentialStore = store
+	return c
+}
+
+// WithFaultPoints registers named points where the application calls State.Fault, at which delays, errors, or panics
+// can be injected with the development config (e.g. "dev.faults: {download: error:timeout}") to test failure paths
+// without code changes. Faults may only be configured for registered points.
+func (c *SetupConfig) WithFaultPoints(names ...string) *SetupConfig {
+	c.FaultPoints = append(c.FaultPoints, names...)
+	if c.DefaultDevelopmentConfig == nil {
+		c.DefaultDevelopmentConfig = &DevelopmentConfig{}
+	}
+	return c
+}
+
+// WithHookPoints registers named extension points where the application calls State.RunHook (or FilterHook), at
+// which users may run hook scripts: files with a .star extension, within the hooks dir of the user config dir (e.g.
+// ~/.config/<app>/hooks) or of the workspace (.<app>/hooks), which define functions named after the extension points.
+// Scripts are written in Starlark (a dialect of Python, run with go.starlark.net) and are sandboxed: they can only
+// reach the application through "app" (its name and version), log(...), warn(code, message), and veto(reason).
+func (c *SetupConfig) WithHookPoints(names ...string) *SetupConfig {
+	c.HookPoints = append(c.HookPoints, names...)
+	if c.DefaultHooksConfig == nil {
+		c.DefaultHooksConfig = &HooksConfig{Enabled: true}
+	}
+	return c
+}
+
+// WithPolicyPoints registers named decision points where the application calls State.Decide (e.g. "should this
+// finding fail the build?"), which are decided by policies the user configures with the "policy" section of the
+// application config: rego policy files or bundles, an OPA server, or starlark (.star) policy files with a function
+// for each decision point. Policies decide with a bool (allowed or not), a list of reasons the action is denied, or an
+// object with "allow" and/or "deny" (the reasons). Rego and starlark policies are evaluated within the application.
+func (c *SetupConfig) WithPolicyPoints(names ...string) *SetupConfig {
+	c.PolicyPoints = append(c.PolicyPoints, names...)
+	if c.DefaultPolicyConfig == nil {
+		c.DefaultPolicyConfig = &PolicyConfig{}
+	}
+	return c
+}
+
+// WithPolicyEvaluator adds policies of the application, evaluated at the decision points (see WithPolicyPoints)
+// before those configured by the user.
+func (c *SetupConfig) WithPolicyEvaluator(evaluators ...PolicyEvaluator) *SetupConfig {
+	c.PolicyEvaluators = append(c.PolicyEvaluators, evaluators...)
+	return c
+}
+
+// WithReplaceableLogger allows the logger to be replaced with State.SetLogger, or its level changed with
+// State.SetLogLevel, while it is in use (e.g. when reloading the configuration on SIGHUP, or from a UI keybinding),
+// with all loggers nested from the logger of the application (such as component loggers) following the change. This
+// is enabled by the control server (see WithControlServer) for changing the log level.
+func (c *SetupConfig) WithReplaceableLogger() *SetupConfig {
+	c.ReplaceableLogger = true
+	return c
+}
+
+// WithDisableUIOnPanic stops sending events to a UI once it panics while handling an event (the panic is logged
+// and the worker continues either way).
+func (c *SetupConfig) WithDisableUIOnPanic() *SetupConfig {
+	c.DisableUIOnPanic = true
+	return c
+}
+
 func (c *SetupConfig) WithNoLogging() *SetupConfig {
 	c.DefaultLoggingConfig = nil
 	c.LoggerConstructor = func(_ Config, _ redact.Store) (logger.Logger, error) {
@@ -97,6 +773,23 @@ func (c *SetupConfig) WithInitializers(initializers ...Initializer) *SetupConfig
 	return c
 }
 
+// WithNotifications enables the user to configure notifications sent once each command completes (with the
+// "notifications" section of the application config): webhooks, slack messages, and emails, with bodies rendered from
+// the RunSummary (see State.SetResult to include the result of the command).
+func (c *SetupConfig) WithNotifications() *SetupConfig {
+	if c.DefaultNotificationsConfig == nil {
+		c.DefaultNotificationsConfig = &NotificationsConfig{Retries: defaultNotifyRetries, Timeout: defaultNotifyTimeout}
+	}
+	return c.WithInitializers(redactNotificationSecrets).WithFinalizers(notify)
+}
+
+// WithFinalizers runs the finalizers once each command has completed, whether it succeeded or not (e.g. to report the
+// outcome elsewhere). Finalizers that fail raise a warning, without changing the outcome of the command.
+func (c *SetupConfig) WithFinalizers(finalizers ...Finalizer) *SetupConfig {
+	c.Finalizers = append(c.Finalizers, finalizers...)
+	return c
+}
+
 func (c *SetupConfig) withPostConstructs(postConstructs ...postConstruct) *SetupConfig {
 	c.postConstructs = append(c.postConstructs, postConstructs...)
 	return c
@@ -114,6 +807,61 @@ func (c *SetupConfig) WithGlobalLoggingFlags() *SetupConfig {
 	})
 }
 
+// WithDefaultCommand runs the named subcommand when the application is invoked without a subcommand (instead of
+// showing help). Flags for the subcommand may be given without naming the subcommand. This has no effect if the root
+// command has its own Run or RunE function.
+func (c *SetupConfig) WithDefaultCommand(name string) *SetupConfig {
+	c.DefaultCommand = name
+	return c
+}
+
+// WithCommandSuggestions suggests commands for an unknown command given to the root command by their aliases as well
+// (and plugins, see WithPluginCatalog), instead of by name only as cobra does. To check for unknown commands, a root
+// command that is not runnable is made runnable, showing help when run without a command (exiting with 0, as before)
+// without setting up the application.
+func (c *SetupConfig) WithCommandSuggestions() *SetupConfig {
+	c.CommandSuggestions = true
+	return c
+}
+
+// WithPluginCatalog considers the given plugins when suggesting commands for an unknown command (which enables
+// WithCommandSuggestions). When a plugin that is not installed is given, a hint to install it with the given subcommand
+// (e.g. "plugin install") is shown.
+func (c *SetupConfig) WithPluginCatalog(catalog PluginCatalog, installCommand string) *SetupConfig {
+	c.CommandSuggestions = true
+	c.PluginCatalog = catalog
+	c.PluginInstallCommand = installCommand
+	return c
+}
+
+// WithRootPolicy warns or refuses to run when the application is run with elevated privileges.
+func (c *SetupConfig) WithRootPolicy(policy RootPolicy) *SetupConfig {
+	c.RootPolicy = policy
+	return c
+}
+
+// WithPrivilegeDrop switches to the given user (when running as root) after setup and before each command runs. Any
+// resources that require privileges should be acquired within initializers or config PostLoad functions.
+func (c *SetupConfig) WithPrivilegeDrop(username string) *SetupConfig {
+	c.DropPrivilegesTo = username
+	return c
+}
+
+// WithEnvInFlagHelp shows the environment variable that can be used in place of each flag in the help output.
+func (c *SetupConfig) WithEnvInFlagHelp() *SetupConfig {
+	c.ShowEnvInFlagHelp = true
+	return c
+}
+
+// WithWorkingDirFlag adds a global --cwd flag, changing to the given directory while the command runs. The .env file and
+// config files are also found as if started in the directory given with the flag (or its environment variable, but not
+// with a config file). Paths that are used before the command runs should be resolved with State.ResolvePath.
+func (c *SetupConfig) WithWorkingDirFlag() *SetupConfig {
+	return c.withPostConstructs(func(a *application) {
+		a.AddPersistentFlags(a.root, &a.state.cwd)
+	})
+}
+
 func (c *SetupConfig) WithConfigInRootHelp() *SetupConfig {
 	return c.withPostConstructs(updateHelpUsageTemplate, showConfigInRootHelp)
 }
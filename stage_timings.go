@@ -0,0 +1,296 @@
+package clio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// stageTimingsStoreKey is the state store key holding the stage timings of recent runs (see
+	// SetupConfig.WithStageTimings).
+	stageTimingsStoreKey = "stage-timings"
+	// DefaultStageTimingsLimit is the number of runs of each command kept in the stage timings.
+	DefaultStageTimingsLimit = 100
+)
+
+// stageTimingsRun is the timing of the stages of a run recorded in the stage timings, a time series (per command) for
+// tracking the performance of long-running commands.
+type stageTimingsRun struct {
+	Command    string        `json:"command"`
+	Started    time.Time     `json:"started"`
+	DurationMS int64         `json:"duration-ms"`
+	ExitCode   int           `json:"exit-code"`
+	Stages     []stageTiming `json:"stages"`
+}
+
+// stageTiming is the timing of a finished stage, named by its path in the stage tree (e.g. "build/compile").
+type stageTiming struct {
+	Stage      string      `json:"stage"`
+	Status     StageStatus `json:"status"`
+	StartedMS  int64       `json:"started-ms"` // since the start of the run
+	DurationMS int64       `json:"duration-ms"`
+}
+
+// stageTimings collects the timing of the stages finished while the command runs.
+type stageTimings struct {
+	lock      sync.Mutex
+	recording bool
+	started   time.Time
+	names     map[int64]string // the paths of the stages started, by ID
+	finished  []stageTiming
+}
+
+// start begins collecting stage timings for a run.
+func (t *stageTimings) start() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.recording = true
+	t.started = now()
+	t.names = make(map[int64]string)
+	t.finished = nil
+}
+
+// stop ends collecting stage timings, returning those collected.
+func (t *stageTimings) stop() []stageTiming {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	finished := t.finished
+	t.recording = false
+	t.names = nil
+	t.finished = nil
+	return finished
+}
+
+// stageStarted records the path of the started stage, to name its children.
+func (t *stageTimings) stageStarted(update StageUpdate) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.recording {
+		return
+	}
+	name := update.Name
+	if parent, ok := t.names[update.Parent]; ok {
+		name = parent + "/" + name
+	}
+	t.names[update.ID] = name
+}
+
+// stageFinished records the timing of the finished stage.
+func (t *stageTimings) stageFinished(update StageUpdate) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.recording {
+		return
+	}
+	name, ok := t.names[update.ID]
+	if !ok {
+		// started before the run
+		return
+	}
+	t.finished = append(t.finished, stageTiming{
+		Stage:      name,
+		Status:     update.Status,
+		StartedMS:  update.Started.Sub(t.started).Milliseconds(),
+		DurationMS: update.Duration.Milliseconds(),
+	})
+}
+
+// startStageTimings starts collecting the timing of the stages of the command being run, which is only done when the
+// stage timings are enabled.
+func (a *application) startStageTimings() {
+	if !a.setupConfig.StageTimings {
+		return
+	}
+	a.state.timings.start()
+}
+
+// finishStageTimings records the timing of the stages of the completed run (when there were any), keeping only the
+// most recent runs of each command.
+func (a *application) finishStageTimings(ctx context.Context, cmd *cobra.Command, started time.Time, err error) {
+	if !a.setupConfig.StageTimings {
+		return
+	}
+	stages := a.state.timings.stop()
+	if len(stages) == 0 {
+		return
+	}
+	run := stageTimingsRun{
+		Command:    cmd.CommandPath(),
+		Started:    started,
+		DurationMS: since(started).Milliseconds(),
+		Stages:     stages,
+	}
+	switch {
+	case ctx.Err() != nil:
+		run.ExitCode = (&CancelledError{Reason: a.state.cancelReason(ctx)}).ExitCode()
+	case err != nil:
+		run.ExitCode = a.exitCode(err)
+	}
+
+	store := a.state.Store()
+	var runs []stageTimingsRun
+	if _, err := store.Get(stageTimingsStoreKey, &runs); err != nil {
+		a.state.Logger.Debugf("unable to read stage timings: %v", err)
+		return
+	}
+	runs = append(runs, run)
+
+	limit := a.setupConfig.StageTimingsLimit
+	if limit <= 0 {
+		limit = DefaultStageTimingsLimit
+	}
+	if err := store.Set(stageTimingsStoreKey, limitStageTimings(runs, limit)); err != nil {
+		a.state.Logger.Debugf("unable to save stage timings: %v", err)
+	}
+}
+
+// limitStageTimings keeps the most recent runs of each command, so that frequent commands don't push out the runs of
+// others.
+func limitStageTimings(runs []stageTimingsRun, limit int) []stageTimingsRun {
+	counts := make(map[string]int)
+	var kept []stageTimingsRun
+	for i := len(runs) - 1; i >= 0; i-- {
+		counts[runs[i].Command]++
+		if counts[runs[i].Command] <= limit {
+			kept = append(kept, runs[i])
+		}
+	}
+	for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+		kept[i], kept[j] = kept[j], kept[i]
+	}
+	return kept
+}
+
+// setupStageTimingsCommands adds the "stats timings" command, which summarizes the recorded stage timings, or exports
+// them as JSON for dashboards.
+func (a *application) setupStageTimingsCommands() {
+	var command, format string
+	var last int
+	timingsCmd := &cobra.Command{
+		Use:   "timings",
+		Short: "show the time taken by the stages of recent runs",
+		Long: "Show how long each stage of recent runs took, to track the performance of long-running commands over " +
+			"time. Use --output json to export the timings of each run (a time series) for dashboards.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			runs, err := a.readStageTimings(command, last)
+			if err != nil {
+				return err
+			}
+			switch format {
+			case "text", "":
+				return writeStageTimings(cmd.OutOrStdout(), runs)
+			case "json":
+				if runs == nil {
+					runs = []stageTimingsRun{}
+				}
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetEscapeHTML(false)
+				enc.SetIndent("", " ")
+				return enc.Encode(runs)
+			default:
+				return fmt.Errorf("unsupported output format: %s", format)
+			}
+		},
+	}
+	flags := timingsCmd.Flags()
+	flags.StringVarP(&command, "command", "c", "", "only show the runs of the given command (e.g. \"scan\")")
+	flags.IntVarP(&last, "last", "n", 0, "only show the most recent runs (all runs when not positive)")
+	flags.StringVarP(&format, "output", "o", "text", "the format to show the results (allowable: [text json])")
+
+	statsCmd, _, err := a.root.Find([]string{"stats"})
+	if err != nil || statsCmd == a.root {
+		statsCmd = &cobra.Command{
+			Use:   "stats",
+			Short: "show statistics of recent runs",
+			Args:  cobra.NoArgs,
+		}
+		a.root.AddCommand(statsCmd)
+	}
+	statsCmd.AddCommand(a.SetupCommand(timingsCmd))
+}
+
+// readStageTimings returns the recorded runs of the given command (or all commands when empty), only the most recent
+// when last is positive.
+func (a *application) readStageTimings(command string, last int) ([]stageTimingsRun, error) {
+	var runs []stageTimingsRun
+	if _, err := a.state.Store().Get(stageTimingsStoreKey, &runs); err != nil {
+		return nil, fmt.Errorf("unable to read stage timings: %w", err)
+	}
+	if command != "" {
+		path := strings.TrimSpace(a.setupConfig.ID.Name + " " + command)
+		var matched []stageTimingsRun
+		for _, r := range runs {
+			if r.Command == path || r.Command == command {
+				matched = append(matched, r)
+			}
+		}
+		runs = matched
+	}
+	if last > 0 && len(runs) > last {
+		runs = runs[len(runs)-last:]
+	}
+	return runs, nil
+}
+
+// stageTimingsSummary summarizes the durations of a stage of a command across runs.
+type stageTimingsSummary struct {
+	command, stage string
+	durations      []time.Duration // in the order of the runs
+}
+
+func (s stageTimingsSummary) mean() time.Duration {
+	var total time.Duration
+	for _, d := range s.durations {
+		total += d
+	}
+	return total / time.Duration(len(s.durations))
+}
+
+func (s stageTimingsSummary) percentile(p int) time.Duration {
+	sorted := append([]time.Duration(nil), s.durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[(len(sorted)-1)*p/100]
+}
+
+// summarizeStageTimings summarizes the durations of each stage of each command (in the order first seen).
+func summarizeStageTimings(runs []stageTimingsRun) []*stageTimingsSummary {
+	var summaries []*stageTimingsSummary
+	index := make(map[[2]string]*stageTimingsSummary)
+	for _, r := range runs {
+		for _, st := range r.Stages {
+			key := [2]string{r.Command, st.Stage}
+			s, ok := index[key]
+			if !ok {
+				s = &stageTimingsSummary{command: r.Command, stage: st.Stage}
+				index[key] = s
+				summaries = append(summaries, s)
+			}
+			s.durations = append(s.durations, time.Duration(st.DurationMS)*time.Millisecond)
+		}
+	}
+	return summaries
+}
+
+func writeStageTimings(w io.Writer, runs []stageTimingsRun) error {
+	if len(runs) == 0 {
+		_, err := fmt.Fprintln(w, "no recorded stage timings")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "COMMAND\tSTAGE\tRUNS\tLAST\tMEAN\tP90\tMAX")
+	for _, s := range summarizeStageTimings(runs) {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", s.command, s.stage, len(s.durations),
+			s.durations[len(s.durations)-1], s.mean(), s.percentile(90), s.percentile(100))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,109 @@
+package clio
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runStageTimingsTestApp(t *testing.T, limit int, args ...string) (string, error) {
+	t.Helper()
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithStageTimings(limit))
+	root := app.SetupRootCommand(&cobra.Command{})
+
+	build := &cobra.Command{
+		Use: "build",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s := app.(*application).State()
+			stage := s.StartStage("build")
+			stage.StartStage("compile").Done()
+			stage.StartStage("link").Skip()
+			stage.Done()
+			if len(args) > 0 {
+				return fmt.Errorf("build failed")
+			}
+			return nil
+		},
+	}
+	lint := &cobra.Command{
+		Use: "lint",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+	}
+	root.AddCommand(app.SetupCommand(build), app.SetupCommand(lint))
+
+	out, _, err := runTestApp(root, "", args...)
+	return out, err
+}
+
+func Test_Application_stageTimings(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	out, err := runStageTimingsTestApp(t, 0, "stats", "timings")
+	require.NoError(t, err)
+	assert.Equal(t, "no recorded stage timings\n", out)
+
+	_, err = runStageTimingsTestApp(t, 0, "build")
+	require.NoError(t, err)
+	_, err = runStageTimingsTestApp(t, 0, "build", "fail")
+	require.Error(t, err)
+	// runs without stages are not recorded
+	_, err = runStageTimingsTestApp(t, 0, "lint")
+	require.NoError(t, err)
+
+	out, err = runStageTimingsTestApp(t, 0, "stats", "timings")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 4)
+	assert.Regexp(t, `^COMMAND\s+STAGE\s+RUNS\s+LAST\s+MEAN\s+P90\s+MAX$`, lines[0])
+	assert.Regexp(t, `^app build\s+build/compile\s+2\s`, lines[1])
+	assert.Regexp(t, `^app build\s+build/link\s+2\s`, lines[2])
+	assert.Regexp(t, `^app build\s+build\s+2\s`, lines[3])
+
+	out, err = runStageTimingsTestApp(t, 0, "stats", "timings", "--output", "json", "--last", "1")
+	require.NoError(t, err)
+	var runs []stageTimingsRun
+	require.NoError(t, json.Unmarshal([]byte(out), &runs))
+	require.Len(t, runs, 1)
+	assert.Equal(t, "app build", runs[0].Command)
+	assert.Equal(t, 1, runs[0].ExitCode)
+	require.Len(t, runs[0].Stages, 3)
+	assert.Equal(t, "build/link", runs[0].Stages[1].Stage)
+	assert.Equal(t, StageSkipped, runs[0].Stages[1].Status)
+
+	out, err = runStageTimingsTestApp(t, 0, "stats", "timings", "--command", "lint", "-o", "json")
+	require.NoError(t, err)
+	assert.Equal(t, "[]\n", out)
+
+	_, err = runStageTimingsTestApp(t, 0, "stats", "timings", "-o", "xml")
+	require.ErrorContains(t, err, "unsupported output format: xml")
+}
+
+func Test_limitStageTimings(t *testing.T) {
+	var runs []stageTimingsRun
+	for i := 0; i < 3; i++ {
+		runs = append(runs, stageTimingsRun{Command: "app build", DurationMS: int64(i)})
+	}
+	runs = append(runs, stageTimingsRun{Command: "app lint"})
+
+	kept := limitStageTimings(runs, 2)
+	require.Len(t, kept, 3)
+	assert.Equal(t, int64(1), kept[0].DurationMS)
+	assert.Equal(t, int64(2), kept[1].DurationMS)
+	assert.Equal(t, "app lint", kept[2].Command)
+}
+
+func Test_stageTimingsSummary(t *testing.T) {
+	s := stageTimingsSummary{durations: []time.Duration{4, 1, 3, 2, 10}}
+	assert.Equal(t, time.Duration(4), s.mean())
+	assert.Equal(t, time.Duration(4), s.percentile(90))
+	assert.Equal(t, time.Duration(10), s.percentile(100))
+	assert.Equal(t, time.Duration(1), s.percentile(0))
+}
@@ -0,0 +1,142 @@
+package clio
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wagoodman/go-partybus"
+)
+
+// StageEvent is published on the bus each time a stage (see State.StartStage) starts or finishes, with a StageUpdate
+// as the event value.
+const StageEvent partybus.EventType = "clio-stage"
+
+// StageStatus is the status of a stage of work.
+type StageStatus string
+
+const (
+	StageRunning   StageStatus = "running"
+	StageSucceeded StageStatus = "succeeded"
+	StageFailed    StageStatus = "failed"
+	StageSkipped   StageStatus = "skipped"
+)
+
+// StageUpdate is a snapshot of a stage, published as the value of each StageEvent.
+type StageUpdate struct {
+	ID       int64         `json:"id"`
+	Parent   int64         `json:"parent,omitempty"` // the ID of the parent stage, or 0 for a top-level stage
+	Name     string        `json:"name"`
+	Status   StageStatus   `json:"status"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration,omitempty"` // set once finished
+	Error    string        `json:"error,omitempty"`    // set when failed (redacted)
+}
+
+// Finished indicates the stage has completed (successfully or not).
+func (u StageUpdate) Finished() bool {
+	return u.Status != StageRunning
+}
+
+// stageIDs allocates the IDs of stages, which are unique for the life of the State.
+type stageIDs struct {
+	lock sync.Mutex
+	last int64
+}
+
+func (s *stageIDs) next() int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.last++
+	return s.last
+}
+
+// Stage is a unit of work within a multi-stage pipeline, which may be broken down into child stages. The progress of
+// all stages is published as StageEvents, for UIs to render as a tree (see NewTaskTreeUI). Stages are safe for
+// concurrent use, and finishing a stage more than once has no effect.
+type Stage struct {
+	state *State
+
+	lock   sync.Mutex
+	update StageUpdate
+}
+
+// StartStage starts a top-level stage of work.
+func (s *State) StartStage(name string) *Stage {
+	return s.startStage(0, name)
+}
+
+// StartStage starts a child stage of this stage.
+func (st *Stage) StartStage(name string) *Stage {
+	return st.state.startStage(st.update.ID, name)
+}
+
+func (s *State) startStage(parent int64, name string) *Stage {
+	st := &Stage{
+		state: s,
+		update: StageUpdate{
+			ID:      s.stages.next(),
+			Parent:  parent,
+			Name:    name,
+			Status:  StageRunning,
+			Started: now(),
+		},
+	}
+	s.timings.stageStarted(st.update)
+	s.publishStage(st.update)
+	return st
+}
+
+// Done finishes the stage successfully.
+func (st *Stage) Done() {
+	st.finish(StageSucceeded, nil)
+}
+
+// Fail finishes the stage unsuccessfully.
+func (st *Stage) Fail(err error) {
+	st.finish(StageFailed, err)
+}
+
+// Skip finishes the stage without doing the work.
+func (st *Stage) Skip() {
+	st.finish(StageSkipped, nil)
+}
+
+// Finish finishes the stage with the result of the work: successfully when the error is nil and unsuccessfully
+// otherwise. This is convenient to defer:
+//
+//	stage := state.StartStage("download")
+//	defer func() { stage.Finish(err) }()
+func (st *Stage) Finish(err error) {
+	if err != nil {
+		st.Fail(err)
+		return
+	}
+	st.Done()
+}
+
+func (st *Stage) finish(status StageStatus, err error) {
+	st.lock.Lock()
+	if st.update.Finished() {
+		st.lock.Unlock()
+		return
+	}
+	st.update.Status = status
+	st.update.Duration = since(st.update.Started)
+	if err != nil {
+		st.update.Error = err.Error()
+		if st.state.RedactStore != nil {
+			st.update.Error = st.state.RedactStore.RedactString(st.update.Error)
+		}
+	}
+	update := st.update
+	st.lock.Unlock()
+
+	st.state.timings.stageFinished(update)
+	st.state.publishStage(update)
+}
+
+func (s *State) publishStage(update StageUpdate) {
+	if s.Bus != nil {
+		s.Bus.Publish(partybus.Event{Type: StageEvent, Value: update})
+	}
+}
@@ -0,0 +1,55 @@
+package clio
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wagoodman/go-partybus"
+
+	"github.com/boss-net/go-logger/adapter/redact"
+)
+
+func TestState_StartStage(t *testing.T) {
+	bus := partybus.NewBus()
+	sub := bus.Subscribe()
+	s := &State{Bus: bus, RedactStore: redact.NewStore("s3cr3t")}
+
+	build := s.StartStage("build")
+	compile := build.StartStage("compile")
+	compile.Fail(errors.New("token s3cr3t rejected"))
+	compile.Done() // already finished
+	build.Finish(nil)
+
+	var got []StageUpdate
+	for len(got) < 4 {
+		select {
+		case e := <-sub.Events():
+			require.Equal(t, StageEvent, e.Type)
+			got = append(got, e.Value.(StageUpdate))
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for stage events")
+		}
+	}
+
+	assert.Equal(t, int64(1), got[0].ID)
+	assert.Equal(t, StageRunning, got[0].Status)
+
+	assert.Equal(t, int64(2), got[1].ID)
+	assert.Equal(t, int64(1), got[1].Parent)
+
+	assert.Equal(t, StageFailed, got[2].Status)
+	assert.Equal(t, "token ******* rejected", got[2].Error)
+	assert.True(t, got[2].Finished())
+
+	assert.Equal(t, int64(1), got[3].ID)
+	assert.Equal(t, StageSucceeded, got[3].Status)
+
+	select {
+	case e := <-sub.Events():
+		t.Fatalf("unexpected event: %+v", e)
+	default:
+	}
+}
@@ -2,6 +2,10 @@ package clio
 
 import (
 	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+	"sync"
 
 	"github.com/wagoodman/go-partybus"
 
@@ -9,6 +13,15 @@ import (
 	"github.com/boss-net/go-logger/adapter/redact"
 )
 
+// State holds the configuration and resources (bus, logger, UIs, etc.) of the application, which are set up before the
+// command runs.
+//
+// Concurrency: the exported fields may be read (and replaced) directly while the application is being set up (e.g. by
+// initializers and PostLoad functions) and from the goroutine running the command until it starts other goroutines.
+// From then on, goroutines (workers, UIs, the control server, etc.) must read the configuration and logger with
+// Snapshot, and replace them only with SetLogger and UpdateConfig, which may be called concurrently. The Bus,
+// RedactStore, and UIs are never replaced once the command runs. All other methods of State may be called
+// concurrently.
 type State struct {
 	Config       Config
 	Bus          *partybus.Bus
@@ -16,23 +29,251 @@ type State struct {
 	Logger       logger.Logger
 	RedactStore  redact.Store
 	UIs          []UI
+
+	temp         tempDirs
+	environment  Environment
+	cwd          WorkingDirConfig
+	workspace    Workspace
+	workspaceCfg WorkspaceConfig
+	propagator   TracePropagator
+	checkpoints  checkpoints
+	jobs         jobQueueState
+	health       healthChecks
+	warnings     warnings
+	id           Identification
+	invocation   invocation
+	stages       stageIDs
+	timings      stageTimings
+	requirements []Requirement
+	otlp         otlpExporterOnce
+	remoteLogs   remoteLogExporterOnce
+	metrics      *Metrics
+	store        *Store
+	faults       map[string][]faultAction
+	random       *rand.Rand
+	auth         Authenticator
+	credentials  CredentialStore
+	authLock     sync.Mutex
+
+	httpMiddlewares []HTTPMiddleware
+	result          runResult
+	uploads         map[string]UploadDestination
+	hooks           []*hookScript
+	hookPoints      []string
+	policyPoints    []string
+	policies        []PolicyEvaluator
+	decisions       policyDecisions
+	writable        writableDirs
+	cancellation    cancellation
+	phase           phaseState
+	confirm         confirmState
+	lockCommand     string // the path of the command holding locks (see State.Lock), guarded by lock
+
+	configSources    map[string]string
+	configExpansions map[string]ConfigExpansion
+	loadedConfigs    []any // copies of the configs loaded for the command (see setLoadedConfigs), guarded by lock
+
+	// guards replacing the Config and Logger while the command runs (see Snapshot)
+	lock sync.RWMutex
+	// constructs a logger for the given configuration, when the logger is replaceable (see SetLogLevel)
+	loggerFactory func(Config) (logger.Logger, error)
+}
+
+// StateSnapshot is a copy of the configuration and resources of the application at one point in time (see
+// State.Snapshot). Changes to the configuration within the snapshot do not affect the application.
+type StateSnapshot struct {
+	Config      Config
+	Bus         *partybus.Bus
+	Logger      logger.Logger
+	RedactStore redact.Store
+	UIs         []UI
+
+	// copies of the configs loaded for the command besides Config (shown by the control API)
+	loadedConfigs []any
+}
+
+// Snapshot returns a copy of the configuration and resources of the application, which is safe to use while other
+// goroutines replace the configuration or logger (see State).
+func (s *State) Snapshot() StateSnapshot {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return StateSnapshot{
+		Config:      s.Config.clone(),
+		Bus:         s.Bus,
+		Logger:      s.Logger,
+		RedactStore: s.RedactStore,
+		UIs:         append([]UI(nil), s.UIs...),
+
+		loadedConfigs: s.loadedConfigs,
+	}
+}
+
+// setLoadedConfigs keeps copies of the configs loaded for the command (besides Config, which snapshots have already)
+// for snapshots, since the configs themselves are loaded again for each command the daemon runs.
+func (s *State) setLoadedConfigs(cfgs []any) {
+	var copies []any
+	for _, cfg := range cfgs {
+		if cfg != &s.Config {
+			copies = append(copies, deepCopy(reflect.ValueOf(cfg)).Interface())
+		}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.loadedConfigs = copies
+}
+
+// SetLogger replaces the logger of the application, which is safe to do while other goroutines use the State. When
+// the logger is replaceable (see SetupConfig.WithReplaceableLogger), loggers nested from the logger of the
+// application follow the change.
+func (s *State) SetLogger(log logger.Logger) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if swappable, ok := s.Logger.(*swappableLogger); ok && swappable.derive == nil {
+		swappable.swap(log, swappable.currentLevel())
+		return
+	}
+	s.Logger = log
+}
+
+// UpdateConfig changes the configuration of the application, which is safe to do while other goroutines use the
+// State. The function is given a copy of the configuration, which replaces the configuration once it returns (so
+// readers never see a partial change).
+func (s *State) UpdateConfig(fn func(cfg *Config)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	cfg := s.Config.clone()
+	fn(&cfg)
+	s.Config = cfg
+}
+
+// currentLogger returns the logger of the application (see State for the concurrency contract).
+func (s *State) currentLogger() logger.Logger {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.Logger
+}
+
+// currentConfig returns the configuration of the application, which must not be modified (sections are only replaced
+// as a whole, see UpdateConfig).
+func (s *State) currentConfig() Config {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.Config
 }
 
 type Config struct {
 	// Items that end up in the target application configuration
-	Log *LoggingConfig     `yaml:"log" json:"log" mapstructure:"log"`
-	Dev *DevelopmentConfig `yaml:"dev" json:"dev" mapstructure:"dev"`
+	Log  *LoggingConfig     `yaml:"log" json:"log" mapstructure:"log"`
+	Dev  *DevelopmentConfig `yaml:"dev" json:"dev" mapstructure:"dev"`
+	Temp *TempConfig        `yaml:"temp" json:"temp" mapstructure:"temp"`
+
+	Permissions   *PermissionsConfig   `yaml:"permissions" json:"permissions" mapstructure:"permissions"`
+	Telemetry     *TelemetryConfig     `yaml:"telemetry" json:"telemetry" mapstructure:"telemetry"`
+	UI            *UIConfig            `yaml:"ui" json:"ui" mapstructure:"ui"`
+	Network       *NetworkConfig       `yaml:"network" json:"network" mapstructure:"network"`
+	HTTP          *HTTPConfig          `yaml:"http" json:"http" mapstructure:"http"`
+	Notifications *NotificationsConfig `yaml:"notifications" json:"notifications" mapstructure:"notifications"`
+	Hooks         *HooksConfig         `yaml:"hooks" json:"hooks" mapstructure:"hooks"`
+	Policy        *PolicyConfig        `yaml:"policy" json:"policy" mapstructure:"policy"`
+	Storage       *StorageConfig       `yaml:"storage" json:"storage" mapstructure:"storage"`
+	Priority      *PriorityConfig      `yaml:"priority" json:"priority" mapstructure:"priority"`
+	Locks         *LocksConfig         `yaml:"locks" json:"locks" mapstructure:"locks"`
+	Strict        *StrictModeConfig    `yaml:"strict" json:"strict" mapstructure:"strict"`
 
 	// this is a list of all "config" objects from SetupCommand calls
 	FromCommands []any `yaml:"-" json:"-" mapstructure:"-"`
+
+	// InvocationID and ParentInvocationID identify the run of the application (see State.InvocationID), which
+	// LoggerConstructors should attach to log records (e.g. with Nested("invocation", cfg.InvocationID))
+	InvocationID       string `yaml:"-" json:"-" mapstructure:"-"`
+	ParentInvocationID string `yaml:"-" json:"-" mapstructure:"-"`
+
+	// PlainUI indicates UIConstructors should select a plain UI (line-based output, without animations or redrawing)
+	// over a rich terminal UI, as detected for the environment (e.g. within a container, see State.Environment)
+	PlainUI bool `yaml:"-" json:"-" mapstructure:"-"`
+}
+
+// clone returns a copy of the configuration, with each core section copied.
+func (c Config) clone() Config {
+	c.Log = cp(c.Log)
+	c.Dev = cp(c.Dev)
+	c.Temp = cp(c.Temp)
+	c.Permissions = cp(c.Permissions)
+	c.Telemetry = cp(c.Telemetry)
+	c.UI = cp(c.UI)
+	c.Network = cp(c.Network)
+	c.HTTP = cp(c.HTTP)
+	c.Notifications = cp(c.Notifications)
+	c.Hooks = cp(c.Hooks)
+	if c.Hooks != nil {
+		c.Hooks.Dirs = append([]string(nil), c.Hooks.Dirs...)
+	}
+	c.Policy = cp(c.Policy)
+	if c.Policy != nil {
+		c.Policy.Files = append([]string(nil), c.Policy.Files...)
+	}
+	if c.Network != nil {
+		c.Network.Allow = append([]string(nil), c.Network.Allow...)
+		c.Network.Deny = append([]string(nil), c.Network.Deny...)
+	}
+	c.Storage = cp(c.Storage)
+	c.Priority = cp(c.Priority)
+	c.Locks = cp(c.Locks)
+	c.Strict = cp(c.Strict)
+	if c.Strict != nil {
+		c.Strict.Ignore = append([]string(nil), c.Strict.Ignore...)
+	}
+	c.FromCommands = append([]any(nil), c.FromCommands...)
+	return c
+}
+
+func (c Config) invocation() invocation {
+	return invocation{id: c.InvocationID, parent: c.ParentInvocationID}
 }
 
 func (s *State) setup(cfg SetupConfig) error {
+	s.id = cfg.ID
+	s.temp.prefix = cfg.ID.Name
+	s.propagator = cfg.TracePropagator
+	setDeterministic(s.Config.Dev.deterministic(), os.Getenv)
+	s.random = newRand()
+	s.setupInvocation()
+	s.store = newStore(cfg, s.Config.Permissions)
+	s.store.writable = s.WritableDir
+	s.auth = cfg.Authenticator
+	s.httpMiddlewares = cfg.HTTPMiddlewares
+	s.uploads = cfg.UploadDestinations
+	s.credentials = s.credentialStore(cfg)
+
+	setupConsole()
+	setPresentation(s.Config.UI.useUnicode(), s.Config.UI.accessible())
+	setFormatting(s.Config.UI.rawValues(), presentationEnv(os.Getenv))
 	s.setupBus(cfg.BusConstructor)
+	s.setupEnvironment()
 
-	if err := s.setupLogger(cfg.LoggerConstructor); err != nil {
+	s.Config.InvocationID = s.invocation.id
+	s.Config.ParentInvocationID = s.invocation.parent
+	if err := s.setupLogger(cfg); err != nil {
 		return fmt.Errorf("unable to setup logger: %w", err)
 	}
+	if err := s.setupMetrics(cfg); err != nil {
+		return fmt.Errorf("unable to setup metrics: %w", err)
+	}
+	s.setupPriority()
+	if err := s.setupFaults(cfg.FaultPoints); err != nil {
+		return err
+	}
+	if err := s.setupHooks(cfg.HookPoints); err != nil {
+		return err
+	}
+	if err := s.setupPolicies(cfg.PolicyPoints, cfg.PolicyEvaluators); err != nil {
+		return err
+	}
+
+	s.requirements = cfg.Requirements
+	if err := s.checkRequirements(); err != nil {
+		return err
+	}
 
 	if err := s.setupUI(cfg.UIConstructor); err != nil {
 		return fmt.Errorf("unable to setup UI: %w", err)
@@ -40,20 +281,55 @@ func (s *State) setup(cfg SetupConfig) error {
 	return nil
 }
 
-func (s *State) setupLogger(cx LoggerConstructor) error {
-	if cx == nil {
-		cx = DefaultLogger
+// setupForwarded sets up what depends on the invocation for a command run by the daemon on behalf of another
+// invocation (see runDaemonRequest), which shares everything else the daemon has set up.
+func (s *State) setupForwarded(cfg SetupConfig) error {
+	setupConsole()
+	setPresentation(s.Config.UI.useUnicode(), s.Config.UI.accessible())
+	setFormatting(s.Config.UI.rawValues(), presentationEnv(os.Getenv))
+	s.setupEnvironment()
+
+	s.Config.InvocationID = s.invocation.id
+	s.Config.ParentInvocationID = s.invocation.parent
+	if err := s.setupUI(cfg.UIConstructor); err != nil {
+		return fmt.Errorf("unable to setup UI: %w", err)
 	}
+	return nil
+}
 
-	lgr, err := cx(s.Config, s.RedactStore)
+func (s *State) setupLogger(cfg SetupConfig) error {
+	lgr, err := s.newLogger(cfg, s.Config)
 	if err != nil {
 		return err
 	}
 
+	if cfg.ControlServer || cfg.ReplaceableLogger {
+		// the logger can be replaced (e.g. the log level changed through the control API) while it is in use
+		lgr = newSwappableLogger(lgr, s.Config.Log)
+		s.loggerFactory = func(config Config) (logger.Logger, error) {
+			return s.newLogger(cfg, config)
+		}
+	}
 	s.Logger = lgr
 	return nil
 }
 
+// newLogger constructs the logger for the given configuration, with all entries teed to the log exporter and the
+// remote log endpoint (when enabled).
+func (s *State) newLogger(cfg SetupConfig, config Config) (logger.Logger, error) {
+	cx := cfg.LoggerConstructor
+	if cx == nil {
+		cx = DefaultLogger
+	}
+
+	config.Log = logConfigInAppHome(cfg.ID.Name, config.Log)
+	lgr, err := cx(config, s.RedactStore)
+	if err != nil {
+		return nil, err
+	}
+	return s.withRemoteLogs(cfg, config, s.withTelemetry(cfg, config, lgr)), nil
+}
+
 func (s *State) setupBus(cx BusConstructor) {
 	if cx == nil {
 		cx = newBus
@@ -0,0 +1,391 @@
+package clio
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// BundlePart is a part of the application state which can be exported to a bundle (see State.ExportBundle).
+type BundlePart string
+
+const (
+	// BundleCache is the cache dir (e.g. downloaded databases and the HTTP cache).
+	BundleCache BundlePart = "cache"
+	// BundleState is the state dir (e.g. the state store and the run history), without credentials.
+	BundleState BundlePart = "state"
+	// BundleConfig is the configuration, without secrets.
+	BundleConfig BundlePart = "config"
+)
+
+var bundleParts = Enum(BundleCache, BundleState, BundleConfig)
+
+const (
+	// bundleManifestFile describes the bundle, within the bundle.
+	bundleManifestFile = "bundle.json"
+	// bundleConfigFile is the configuration, within the bundle.
+	bundleConfigFile = "config/config.yaml"
+)
+
+// BundleOptions determine what is exported to (or imported from) a bundle.
+type BundleOptions struct {
+	// Parts to export or import (all parts when empty).
+	Parts []BundlePart
+	// Configs are the configs to export (the core configuration and the configs of all commands when empty).
+	Configs []any
+	// ConfigFile is where the configuration is imported to (default: config.yaml within the app dir of the user
+	// config dir).
+	ConfigFile string
+	// Force replaces an existing config file on import.
+	Force bool
+}
+
+func (o BundleOptions) includes(part BundlePart) bool {
+	if len(o.Parts) == 0 {
+		return true
+	}
+	for _, p := range o.Parts {
+		if p == part {
+			return true
+		}
+	}
+	return false
+}
+
+// bundleManifest describes a bundle.
+type bundleManifest struct {
+	App     string       `json:"app"`
+	Version string       `json:"version,omitempty"`
+	Created time.Time    `json:"created"`
+	Parts   []BundlePart `json:"parts"`
+}
+
+// ExportBundle writes the cache, state, and/or configuration of the application to a bundle (a zip archive), which
+// can be imported on another machine (see ImportBundle), e.g. for an air-gapped install or to reproduce a problem.
+// Secrets are left out: files with secret-looking names (such as the credentials file) and config values with
+// secret-looking keys (e.g. "registry.password").
+func (s *State) ExportBundle(dest string, opts BundleOptions) error {
+	f, err := s.CreateFile(dest)
+	if err != nil {
+		return fmt.Errorf("unable to export bundle: %w", err)
+	}
+	defer f.Close()
+
+	manifest := bundleManifest{App: s.id.Name, Version: s.id.Version, Created: now().UTC()}
+	archive := zip.NewWriter(f)
+	for _, part := range bundleParts.Values() {
+		if !opts.includes(part) {
+			continue
+		}
+		if err := s.exportBundlePart(archive, part, opts); err != nil {
+			return fmt.Errorf("unable to export %s to bundle: %w", part, err)
+		}
+		manifest.Parts = append(manifest.Parts, part)
+	}
+
+	w, err := archive.Create(bundleManifestFile)
+	if err != nil {
+		return fmt.Errorf("unable to export bundle: %w", err)
+	}
+	if err := json.NewEncoder(w).Encode(manifest); err != nil {
+		return fmt.Errorf("unable to export bundle: %w", err)
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("unable to export bundle: %w", err)
+	}
+	return nil
+}
+
+func (s *State) exportBundlePart(archive *zip.Writer, part BundlePart, opts BundleOptions) error {
+	if part == BundleConfig {
+		cfgs := opts.Configs
+		if len(cfgs) == 0 {
+			cfgs = append([]any{&s.Config}, s.Config.FromCommands...)
+		}
+		contents, err := bundleConfig(cfgs...)
+		if err != nil {
+			return err
+		}
+		w, err := archive.Create(bundleConfigFile)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(contents)
+		return err
+	}
+
+	dir, err := s.bundleDir(part)
+	if err != nil {
+		return err
+	}
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		switch {
+		case errors.Is(err, os.ErrNotExist) && p == dir:
+			return filepath.SkipDir
+		case err != nil:
+			return err
+		case d.IsDir() || !d.Type().IsRegular():
+			// e.g. control sockets
+			return nil
+		case dotEnvSecretPattern.MatchString(d.Name()):
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		return addZipFile(archive, path.Join(string(part), filepath.ToSlash(rel)), p)
+	})
+}
+
+// bundleDir returns the directory holding the part of the application state.
+func (s *State) bundleDir(part BundlePart) (string, error) {
+	if part == BundleCache {
+		return s.cacheDir()
+	}
+	return stateDir(s.id.Name)
+}
+
+// bundleConfig returns the configs as a single yaml document, without secret-looking values.
+func bundleConfig(cfgs ...any) ([]byte, error) {
+	sections := map[string]*yaml.Node{}
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, cfg := range nonNil(cfgs...) {
+		var doc yaml.Node
+		if err := doc.Encode(cfg); err != nil {
+			return nil, err
+		}
+		if mergeConfigSections(sections, &doc) || doc.Kind != yaml.MappingNode {
+			continue
+		}
+		root.Content = append(root.Content, doc.Content...)
+	}
+	withoutSecrets(root)
+	return yaml.Marshal(root)
+}
+
+// withoutSecrets removes the values with secret-looking keys (and null values) from the yaml mapping.
+func withoutSecrets(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	var kept []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if dotEnvSecretPattern.MatchString(key.Value) || value.Tag == "!!null" {
+			continue
+		}
+		withoutSecrets(value)
+		kept = append(kept, key, value)
+	}
+	node.Content = kept
+}
+
+// ImportBundle installs the contents of a bundle (see ExportBundle) into the cache, state, and/or configuration of the
+// application, returning the parts imported. Files in the cache and state dirs are replaced, however an existing
+// config file is only replaced with BundleOptions.Force.
+func (s *State) ImportBundle(src string, opts BundleOptions) ([]BundlePart, error) {
+	archive, err := zip.OpenReader(s.ResolvePath(src))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open bundle: %w", err)
+	}
+	defer archive.Close()
+
+	var manifest bundleManifest
+	var files []*zip.File
+	for _, f := range archive.File {
+		if f.Name == bundleManifestFile {
+			if err := readZipJSON(f, &manifest); err != nil {
+				return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+			}
+			continue
+		}
+		files = append(files, f)
+	}
+	if manifest.App == "" {
+		return nil, errors.New("invalid bundle: no manifest")
+	}
+	if manifest.App != s.id.Name {
+		return nil, fmt.Errorf("the bundle is for %s, not %s", manifest.App, s.id.Name)
+	}
+
+	var imported []BundlePart
+	for _, part := range manifest.Parts {
+		if !opts.includes(part) {
+			continue
+		}
+		if err := s.importBundlePart(files, part, opts); err != nil {
+			return imported, fmt.Errorf("unable to import %s from bundle: %w", part, err)
+		}
+		imported = append(imported, part)
+	}
+	return imported, nil
+}
+
+func (s *State) importBundlePart(files []*zip.File, part BundlePart, opts BundleOptions) error {
+	if part == BundleConfig {
+		return s.importBundleConfig(files, opts)
+	}
+
+	dir, err := s.bundleDir(part)
+	if err != nil {
+		return err
+	}
+	if !s.WritableDir(dir) {
+		return fmt.Errorf("unable to write to %s", DisplayPath(dir))
+	}
+	prefix := string(part) + "/"
+	for _, f := range files {
+		if !strings.HasPrefix(f.Name, prefix) {
+			continue
+		}
+		rel := strings.TrimPrefix(f.Name, prefix)
+		if !fs.ValidPath(rel) {
+			return fmt.Errorf("invalid path within bundle: %s", f.Name)
+		}
+		if err := s.extractZipFile(f, filepath.Join(dir, filepath.FromSlash(rel))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *State) importBundleConfig(files []*zip.File, opts BundleOptions) error {
+	dest := opts.ConfigFile
+	if dest == "" {
+		dir, err := userConfigDir(s.id.Name)
+		if err != nil {
+			return fmt.Errorf("unable to determine config dir: %w", err)
+		}
+		dest = filepath.Join(dir, s.id.Name, "config.yaml")
+	}
+	if _, err := os.Stat(dest); err == nil && !opts.Force {
+		return fmt.Errorf("%s already exists (import with --force to replace it)", DisplayPath(dest))
+	}
+	for _, f := range files {
+		if f.Name == bundleConfigFile {
+			return s.extractZipFile(f, dest)
+		}
+	}
+	return errors.New("invalid bundle: no config")
+}
+
+// extractZipFile writes the file from the archive to the path, replacing any existing file once written.
+func (s *State) extractZipFile(f *zip.File, dest string) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	tmp, err := s.CreateFile(filepath.Join(filepath.Dir(dest), "."+filepath.Base(dest)+".tmp"))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dest)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("unable to write %s: %w", dest, err)
+	}
+	return nil
+}
+
+// setupStateBundleCommands adds the "state export" and "state import" commands, which move the cache, state, and
+// configuration of the application to another machine as a bundle.
+func (a *application) setupStateBundleCommands() {
+	var parts []string
+	var opts BundleOptions
+
+	exportCmd := &cobra.Command{
+		Use:   "export <bundle>",
+		Short: "write the cache, state, and configuration (without secrets) to a bundle",
+		Long: "Write the cache, state, and configuration of the application to a bundle, to import on another machine " +
+			"(e.g. for an air-gapped install, or to reproduce a problem). Credentials and secret-looking config values " +
+			"are left out, however please review the contents before sharing.",
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			// the configuration of every command is exported (not only this one)
+			cfgs, err := a.loadConfigs(cmd, false, a.state.Config.FromCommands...)
+			if err != nil {
+				return fmt.Errorf("unable to export bundle: %w", err)
+			}
+			opts.Configs = cfgs
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := bundlePartsFrom(parts, &opts); err != nil {
+				return err
+			}
+			if err := a.state.ExportBundle(args[0], opts); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "bundle written to %s\n", DisplayPath(args[0]))
+			return nil
+		},
+	}
+
+	importCmd := &cobra.Command{
+		Use:   "import <bundle>",
+		Short: "install the cache, state, and configuration from a bundle",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := bundlePartsFrom(parts, &opts); err != nil {
+				return err
+			}
+			opts.ConfigFile = a.configFileUsed()
+			imported, err := a.state.ImportBundle(args[0], opts)
+			if err != nil {
+				return err
+			}
+			var names []string
+			for _, p := range imported {
+				names = append(names, string(p))
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "imported %s\n", strings.Join(names, ", "))
+			return nil
+		},
+	}
+	importCmd.Flags().BoolVar(&opts.Force, "force", false, "replace the existing config file")
+
+	usage := fmt.Sprintf("the parts of the application state (%s)", strings.Join(bundleParts.strings(), ", "))
+	for _, cmd := range []*cobra.Command{exportCmd, importCmd} {
+		cmd.Flags().StringSliceVar(&parts, "include", nil, usage+" (default: all)")
+	}
+
+	stateCmd := &cobra.Command{
+		Use:   "state",
+		Short: "move the application state between machines",
+		Args:  cobra.NoArgs,
+	}
+	stateCmd.AddCommand(a.SetupCommand(exportCmd), a.SetupCommand(importCmd))
+	a.root.AddCommand(stateCmd)
+}
+
+// bundlePartsFrom sets the parts of the options from the --include flag values.
+func bundlePartsFrom(values []string, opts *BundleOptions) error {
+	opts.Parts = nil
+	for _, v := range values {
+		part := BundlePart(strings.TrimSpace(v))
+		if err := bundleParts.Validate(part); err != nil {
+			return fmt.Errorf("invalid --include: %w", err)
+		}
+		opts.Parts = append(opts.Parts, part)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package clio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_State_ExportBundle(t *testing.T) {
+	stateHome := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", stateHome)
+	write := func(path, contents string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+	}
+	write(filepath.Join(stateHome, "app", "store.json"), "{}")
+	write(filepath.Join(stateHome, "app", "credentials.json"), "secret")
+
+	type registryConfig struct {
+		URL      string `yaml:"url"`
+		Password string `yaml:"password"`
+	}
+	cfg := &struct {
+		Registry registryConfig `yaml:"registry"`
+	}{Registry: registryConfig{URL: "registry.example.com", Password: "hunter2"}}
+
+	s := &State{id: Identification{Name: "app"}}
+	s.workspace.CacheDir = t.TempDir()
+	write(filepath.Join(s.workspace.CacheDir, "db", "index.json"), "[]")
+
+	bundle := filepath.Join(t.TempDir(), "bundle.zip")
+	require.NoError(t, s.ExportBundle(bundle, BundleOptions{Configs: []any{cfg}}))
+
+	// imported on another machine
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	other := &State{id: Identification{Name: "app"}}
+	other.workspace.CacheDir = t.TempDir()
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+
+	parts, err := other.ImportBundle(bundle, BundleOptions{ConfigFile: configFile})
+	require.NoError(t, err)
+	assert.Equal(t, []BundlePart{BundleCache, BundleState, BundleConfig}, parts)
+
+	dir, err := stateDir("app")
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "store.json"))
+	assert.NoFileExists(t, filepath.Join(dir, "credentials.json"))
+	assert.FileExists(t, filepath.Join(other.workspace.CacheDir, "db", "index.json"))
+
+	contents, err := os.ReadFile(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, "registry:\n    url: registry.example.com\n", string(contents))
+
+	// the config file is not replaced unless forced
+	_, err = other.ImportBundle(bundle, BundleOptions{ConfigFile: configFile, Parts: []BundlePart{BundleConfig}})
+	require.ErrorContains(t, err, "already exists")
+	_, err = other.ImportBundle(bundle, BundleOptions{ConfigFile: configFile, Parts: []BundlePart{BundleConfig}, Force: true})
+	require.NoError(t, err)
+
+	_, err = (&State{id: Identification{Name: "other"}}).ImportBundle(bundle, BundleOptions{})
+	require.ErrorContains(t, err, "the bundle is for app, not other")
+}
+
+func Test_bundlePartsFrom(t *testing.T) {
+	var opts BundleOptions
+	require.NoError(t, bundlePartsFrom([]string{"cache", " config"}, &opts))
+	assert.Equal(t, []BundlePart{BundleCache, BundleConfig}, opts.Parts)
+	require.ErrorContains(t, bundlePartsFrom([]string{"logs"}, &opts), `"logs" is not allowed`)
+}
@@ -0,0 +1,69 @@
+package clio
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/go-logger"
+	"github.com/boss-net/go-logger/adapter/discard"
+)
+
+func Test_State_Snapshot(t *testing.T) {
+	s := &State{Config: Config{
+		Log:     &LoggingConfig{Level: logger.InfoLevel},
+		Network: &NetworkConfig{Deny: []string{"example.com"}},
+	}}
+
+	snapshot := s.Snapshot()
+	snapshot.Config.Log.Level = logger.TraceLevel
+	snapshot.Config.Network.Deny[0] = "changed.com"
+	assert.Equal(t, logger.InfoLevel, s.Config.Log.Level, "the snapshot is a copy")
+	assert.Equal(t, []string{"example.com"}, s.Config.Network.Deny)
+
+	previous := s.Config.Log
+	s.UpdateConfig(func(cfg *Config) {
+		cfg.Log.Level = logger.DebugLevel
+	})
+	assert.Equal(t, logger.DebugLevel, s.Config.Log.Level)
+	assert.Equal(t, logger.InfoLevel, previous.Level, "sections are replaced rather than modified")
+
+	lgr := discard.New()
+	s.SetLogger(lgr)
+	assert.Equal(t, lgr, s.Snapshot().Logger)
+}
+
+func Test_State_concurrentAccess(t *testing.T) {
+	s := &State{Config: Config{
+		Log:         &LoggingConfig{Level: logger.InfoLevel},
+		Network:     &NetworkConfig{},
+		Permissions: &PermissionsConfig{},
+	}}
+	s.Logger = discard.New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = s.Snapshot().Config.Log.Level
+				require.NoError(t, s.CheckNetwork("example.com"))
+				_ = s.currentLogger()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s.SetLogger(discard.New())
+				s.UpdateConfig(func(cfg *Config) {
+					cfg.Log.Level = logger.DebugLevel
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, logger.DebugLevel, s.Snapshot().Config.Log.Level)
+}
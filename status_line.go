@@ -0,0 +1,107 @@
+package clio
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/gookit/color"
+	"golang.org/x/term"
+)
+
+// StatusLine shows the status of a long-running operation on a single terminal line, redrawn in place with a spinner.
+//
+// In accessible mode (see UIConfig.Accessible), or when the writer is not a terminal, there is no animation: each
+// status change is written as a discrete line, and completion is described in words rather than by a colored symbol.
+// There is no animation in deterministic mode either (see DeterministicMode).
+type StatusLine struct {
+	lock      sync.Mutex
+	w         io.Writer
+	symbols   Symbols
+	lineBased bool
+	frame     int
+	message   string
+}
+
+// NewStatusLine returns a status line writing to the given writer (typically ConsoleWriter(os.Stderr)).
+func NewStatusLine(w io.Writer) *StatusLine {
+	return &StatusLine{
+		w:         w,
+		symbols:   CurrentSymbols(),
+		lineBased: AccessibleMode() || DeterministicMode() || !isTerminal(w),
+	}
+}
+
+// Update changes the status message.
+func (s *StatusLine) Update(message string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if message == s.message {
+		return
+	}
+	s.message = message
+	if s.lineBased {
+		_, _ = fmt.Fprintln(s.w, message)
+		return
+	}
+	s.redraw()
+}
+
+// Tick advances the spinner animation (this does nothing when the status is line-based).
+func (s *StatusLine) Tick() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.lineBased || len(s.symbols.Spinner) == 0 {
+		return
+	}
+	s.frame = (s.frame + 1) % len(s.symbols.Spinner)
+	s.redraw()
+}
+
+// Done completes the status line successfully with the given message.
+func (s *StatusLine) Done(message string) {
+	s.finish(s.symbols.Success, color.Green, message)
+}
+
+// Fail completes the status line unsuccessfully with the given message.
+func (s *StatusLine) Fail(message string) {
+	s.finish(s.symbols.Failure, color.Red, message)
+}
+
+func (s *StatusLine) finish(marker string, c color.Color, message string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.lineBased {
+		_, _ = fmt.Fprintln(s.w, marker, message)
+		return
+	}
+	_, _ = fmt.Fprintf(s.w, "\r\x1b[K%s %s\n", c.Sprint(marker), message)
+}
+
+func (s *StatusLine) redraw() {
+	spinner := ""
+	if len(s.symbols.Spinner) > 0 {
+		spinner = s.symbols.Spinner[s.frame] + " "
+	}
+	_, _ = fmt.Fprintf(s.w, "\r\x1b[K%s%s", spinner, s.message)
+}
+
+// isTerminal indicates the stream is attached to a terminal (directly, or through a ConsoleWriter), which for commands
+// run by the daemon is whether the stream of the forwarding invocation is.
+func isTerminal(w io.Writer) bool {
+	if s, ok := w.(*ansiStripWriter); ok {
+		w = s.w
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	if terminal, forwarded := forwardedTerminal(f); forwarded {
+		return terminal
+	}
+	return term.IsTerminal(int(f.Fd()))
+}
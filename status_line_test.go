@@ -0,0 +1,37 @@
+package clio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gookit/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_StatusLine_lineBased(t *testing.T) {
+	defer setPresentation(true, false)
+	setPresentation(true, true)
+
+	buf := &bytes.Buffer{}
+	s := NewStatusLine(buf)
+	s.Update("downloading")
+	s.Tick()
+	s.Update("downloading") // unchanged status is not repeated
+	s.Update("extracting")
+	s.Done("installed")
+
+	assert.Equal(t, "downloading\nextracting\ndone: installed\n", buf.String())
+}
+
+func Test_StatusLine_animated(t *testing.T) {
+	defer func(enabled bool) { color.Enable = enabled }(color.Enable)
+	color.Enable = false
+
+	buf := &bytes.Buffer{}
+	s := &StatusLine{w: buf, symbols: ASCIISymbols}
+	s.Update("downloading")
+	s.Tick()
+	s.Fail("unable to download")
+
+	assert.Equal(t, "\r\x1b[K| downloading\r\x1b[K/ downloading\r\x1b[Kx unable to download\n", buf.String())
+}
@@ -0,0 +1,76 @@
+package clio
+
+import (
+	"os"
+	"sync"
+
+	"github.com/boss-net/fangs"
+)
+
+// StorageConfig is the user-facing configuration of what the application writes between runs (the cache and
+// persistent state).
+type StorageConfig struct {
+	NoCache bool `yaml:"no-cache" json:"no-cache" mapstructure:"no-cache"` // do not write the cache or persistent state
+}
+
+var _ interface {
+	fangs.FlagAdder
+	fangs.FieldDescriber
+} = (*StorageConfig)(nil)
+
+func (c *StorageConfig) AddFlags(flags fangs.FlagSet) {
+	flags.BoolVarP(&c.NoCache, "no-cache", "", "do not write the cache or persistent state (e.g. in read-only environments)")
+}
+
+func (c *StorageConfig) DescribeFields(set fangs.FieldDescriptionSet) {
+	set.Add(&c.NoCache, "do not write the cache or persistent state, such as the HTTP cache, the state store, and checkpoints")
+}
+
+// writableDirs remembers which directories could be written to during the run, so that each is probed (and warned
+// about) once.
+type writableDirs struct {
+	lock   sync.Mutex
+	probed map[string]bool
+}
+
+// WritableDir indicates whether the directory (which is created if needed) can be written to, so that subsystems
+// keeping a cache or state can degrade gracefully in locked-down environments (e.g. with a read-only home directory)
+// rather than failing the command. A warning is raised the first time each directory is found not to be writable.
+// Nothing should be written when the user asked not to (--no-cache). The HTTP cache, the state store (see
+// State.Store), and checkpoints are skipped this way.
+func (s *State) WritableDir(dir string) bool {
+	cfg := s.currentConfig()
+	if cfg.Storage != nil && cfg.Storage.NoCache {
+		return false
+	}
+
+	s.writable.lock.Lock()
+	defer s.writable.lock.Unlock()
+	if writable, ok := s.writable.probed[dir]; ok {
+		return writable
+	}
+	_, dirMode := cfg.Permissions.modes()
+	writable := probeWritable(dir, dirMode)
+	if s.writable.probed == nil {
+		s.writable.probed = map[string]bool{}
+	}
+	s.writable.probed[dir] = writable
+	if !writable {
+		s.Warn("read-only-dir", "continuing without writing to a directory which is not writable", map[string]any{"dir": DisplayPath(dir)})
+	}
+	return writable
+}
+
+// probeWritable creates the directory (when needed) and a file within it.
+func probeWritable(dir string, dirMode os.FileMode) bool {
+	if err := mkdirAll(dir, dirMode); err != nil {
+		return false
+	}
+	f, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		return false
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+	return true
+}
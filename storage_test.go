@@ -0,0 +1,49 @@
+package clio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WritableDir(t *testing.T) {
+	root := t.TempDir()
+	// a regular file in place of a parent directory can't be written to (not even by root)
+	blocked := filepath.Join(root, "file")
+	require.NoError(t, os.WriteFile(blocked, nil, 0o600))
+
+	s := &State{}
+	assert.True(t, s.WritableDir(filepath.Join(root, "cache")))
+	assert.DirExists(t, filepath.Join(root, "cache"))
+	assert.False(t, s.WritableDir(filepath.Join(blocked, "cache")))
+	assert.False(t, s.WritableDir(filepath.Join(blocked, "cache")))
+
+	// warned about once
+	require.Len(t, s.Warnings(), 1)
+	assert.Equal(t, "read-only-dir", s.Warnings()[0].Code)
+}
+
+func Test_WritableDir_NoCache(t *testing.T) {
+	s := &State{Config: Config{Storage: &StorageConfig{NoCache: true}}}
+	dir := filepath.Join(t.TempDir(), "cache")
+	assert.False(t, s.WritableDir(dir))
+	assert.NoDirExists(t, dir)
+	assert.Empty(t, s.Warnings())
+}
+
+func Test_Store_ReadOnly(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	cfg := NewSetupConfig(Identification{Name: "app"})
+	s := &State{Config: Config{Storage: &StorageConfig{NoCache: true}}}
+	store := newStore(*cfg, nil)
+	store.writable = s.WritableDir
+
+	// changes are not written (nor do they fail the command)
+	require.NoError(t, store.Set("key", "value"))
+	found, err := store.Get("key", new(string))
+	require.NoError(t, err)
+	assert.False(t, found)
+}
@@ -0,0 +1,199 @@
+package clio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// storeFile is the file within the state dir holding the values of the state store.
+const storeFile = "store.json"
+
+// StoreMigration updates the values of the state store from one store version to the next, in place (see
+// SetupConfig.WithStoreMigration).
+type StoreMigration func(values map[string]json.RawMessage) error
+
+// Store is a small key/value store within the state dir for remembering things between runs of the application (such
+// as when updates were last checked for, telemetry consent, or dismissed hints), see State.Store. Values are stored
+// as JSON, and each change is written to the file immediately (replacing the file, so that it is never read partially
+// written). The store is versioned, where values written by an older version of the application are migrated to the
+// current version (see SetupConfig.WithStoreVersion).
+type Store struct {
+	path       string
+	version    int
+	migrations map[int]StoreMigration
+	fileMode   os.FileMode
+	dirMode    os.FileMode
+	err        error                 // why the store can't be used (e.g. the state dir can't be determined)
+	writable   func(dir string) bool // whether the state dir may be written to (see State.WritableDir)
+
+	lock sync.Mutex
+}
+
+// storeContents is the contents of the store file.
+type storeContents struct {
+	Version int                        `json:"version"`
+	Values  map[string]json.RawMessage `json:"values"`
+}
+
+func newStore(cfg SetupConfig, permissions *PermissionsConfig) *Store {
+	fileMode, dirMode := permissions.modes()
+	s := &Store{
+		version:    cfg.StoreVersion,
+		migrations: cfg.StoreMigrations,
+		fileMode:   fileMode,
+		dirMode:    dirMode,
+	}
+	dir, err := stateDir(cfg.ID.Name)
+	if err != nil {
+		s.err = fmt.Errorf("unable to determine state dir: %w", err)
+		return s
+	}
+	s.path = filepath.Join(dir, storeFile)
+	return s
+}
+
+// Store returns the state store of the application.
+func (s *State) Store() *Store {
+	if s.store == nil {
+		s.store = &Store{err: errors.New("the application has not been set up")}
+	}
+	return s.store
+}
+
+// Get reads the value of the key into v (a pointer), indicating whether the key is set.
+func (s *Store) Get(key string, v any) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	contents, err := s.read()
+	if err != nil {
+		return false, fmt.Errorf("unable to read state store: %w", err)
+	}
+	raw, ok := contents.Values[key]
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return true, fmt.Errorf("unable to read %q from state store: %w", key, err)
+	}
+	return true, nil
+}
+
+// Set stores the value (which must be JSON serializable) under the key.
+func (s *Store) Set(key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("unable to store %q: %w", key, err)
+	}
+	return s.update(func(values map[string]json.RawMessage) {
+		values[key] = raw
+	})
+}
+
+// Delete removes the key (if it is set).
+func (s *Store) Delete(key string) error {
+	return s.update(func(values map[string]json.RawMessage) {
+		delete(values, key)
+	})
+}
+
+// Keys returns all keys which are set, sorted.
+func (s *Store) Keys() ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	contents, err := s.read()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read state store: %w", err)
+	}
+	var keys []string
+	for k := range contents.Values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// update changes the values of the store, writing them (migrated to the current version) to the file.
+func (s *Store) update(fn func(values map[string]json.RawMessage)) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	contents, err := s.read()
+	if err != nil {
+		return fmt.Errorf("unable to update state store: %w", err)
+	}
+	fn(contents.Values)
+	if err := s.write(contents); err != nil {
+		return fmt.Errorf("unable to update state store: %w", err)
+	}
+	return nil
+}
+
+// read returns the contents of the store file, migrated to the current version (the file is not changed until the
+// store is next updated).
+func (s *Store) read() (*storeContents, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	contents := &storeContents{Version: s.version}
+	raw, err := os.ReadFile(s.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(raw, contents); err != nil {
+			return nil, fmt.Errorf("invalid state store %s: %w", s.path, err)
+		}
+	}
+	if contents.Values == nil {
+		contents.Values = map[string]json.RawMessage{}
+	}
+
+	if contents.Version > s.version {
+		return nil, fmt.Errorf("state store %s has version %d, which is newer than this version of the application supports (%d)", s.path, contents.Version, s.version)
+	}
+	for v := contents.Version; v < s.version; v++ {
+		migrate := s.migrations[v]
+		if migrate == nil {
+			continue
+		}
+		if err := migrate(contents.Values); err != nil {
+			return nil, fmt.Errorf("unable to migrate state store from version %d to %d: %w", v, v+1, err)
+		}
+	}
+	contents.Version = s.version
+	return contents, nil
+}
+
+func (s *Store) write(contents *storeContents) error {
+	raw, err := json.MarshalIndent(contents, "", "  ")
+	if err != nil {
+		return err
+	}
+	if s.writable != nil && !s.writable(filepath.Dir(s.path)) {
+		// the change is kept for this run only
+		return nil
+	}
+	if err := mkdirAll(filepath.Dir(s.path), s.dirMode); err != nil {
+		return err
+	}
+
+	// write to a temporary file first so that the store is never read partially written
+	tmp := fmt.Sprintf("%s.%d.tmp", s.path, os.Getpid())
+	if err := os.WriteFile(tmp, raw, s.fileMode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package clio
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Store(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	cfg := NewSetupConfig(Identification{Name: "app"})
+	store := newStore(*cfg, nil)
+
+	var checked time.Time
+	found, err := store.Get("last-update-check", &checked)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, store.Set("last-update-check", now))
+	require.NoError(t, store.Set("dismissed-hints", []string{"completion"}))
+
+	// values are kept between runs
+	store = newStore(*cfg, nil)
+	found, err = store.Get("last-update-check", &checked)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.True(t, now.Equal(checked))
+
+	keys, err := store.Keys()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dismissed-hints", "last-update-check"}, keys)
+
+	require.NoError(t, store.Delete("dismissed-hints"))
+	require.NoError(t, store.Delete("missing"))
+	keys, err = store.Keys()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"last-update-check"}, keys)
+}
+
+func Test_Store_migration(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", dir)
+	file := filepath.Join(dir, "app", storeFile)
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o700))
+	require.NoError(t, os.WriteFile(file, []byte(`{"version":1,"values":{"consent":"yes"}}`), 0o600))
+
+	cfg := NewSetupConfig(Identification{Name: "app"}).
+		WithStoreVersion(3).
+		WithStoreMigration(1, func(values map[string]json.RawMessage) error {
+			values["telemetry-consent"] = json.RawMessage(`true`)
+			delete(values, "consent")
+			return nil
+		})
+	store := newStore(*cfg, nil)
+
+	var consent bool
+	found, err := store.Get("telemetry-consent", &consent)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.True(t, consent)
+
+	// the migrated values are written with the next update
+	require.NoError(t, store.Set("hint", "shown"))
+	contents, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version":3,"values":{"telemetry-consent":true,"hint":"shown"}}`, string(contents))
+}
+
+func Test_Store_newerVersion(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", dir)
+	file := filepath.Join(dir, "app", storeFile)
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o700))
+	require.NoError(t, os.WriteFile(file, []byte(`{"version":2,"values":{}}`), 0o600))
+
+	store := newStore(*NewSetupConfig(Identification{Name: "app"}).WithStoreVersion(1), nil)
+	_, err := store.Keys()
+	require.ErrorContains(t, err, "is newer than this version of the application supports")
+	require.Error(t, store.Set("key", "value"))
+
+	// the store is left as it was
+	contents, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version":2,"values":{}}`, string(contents))
+}
+
+func Test_State_Store_notSetup(t *testing.T) {
+	s := &State{}
+	_, err := s.Store().Get("key", new(string))
+	require.ErrorContains(t, err, "has not been set up")
+}
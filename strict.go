@@ -0,0 +1,111 @@
+package clio
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/boss-net/fangs"
+)
+
+// DeprecatedWarning is the code of the warnings raised for the use of deprecated features (see State.Deprecated).
+const DeprecatedWarning = "deprecated"
+
+// StrictModeConfig is the user-facing configuration of strict mode (see SetupConfig.WithStrictMode), where runs that
+// raise more warnings than allowed, use deprecated features (including deprecated commands and flags), or have unknown
+// keys in the config file fail, even when the command itself succeeds. This lets teams ratchet hygiene in CI while
+// staying permissive interactively.
+type StrictModeConfig struct {
+	Enabled     bool     `yaml:"enabled" json:"enabled" mapstructure:"enabled"`                // fail runs which are not clean
+	MaxWarnings int      `yaml:"max-warnings" json:"max-warnings" mapstructure:"max-warnings"` // the number of distinct warnings allowed
+	Ignore      []string `yaml:"ignore" json:"ignore" mapstructure:"ignore"`                   // the codes of warnings which are not counted
+}
+
+var _ interface {
+	fangs.FlagAdder
+	fangs.FieldDescriber
+	fangs.PostLoader
+} = (*StrictModeConfig)(nil)
+
+func (c *StrictModeConfig) AddFlags(flags fangs.FlagSet) {
+	flags.BoolVarP(&c.Enabled, "strict", "", "fail when warnings are raised, deprecated features are used, or the config file has unknown keys")
+}
+
+func (c *StrictModeConfig) DescribeFields(set fangs.FieldDescriptionSet) {
+	set.Add(&c.Enabled, "fail runs which raise warnings, use deprecated features, or have unknown keys in the config file (e.g. in CI)")
+	set.Add(&c.MaxWarnings, "the number of distinct warnings allowed in strict mode (the use of deprecated features is never allowed)")
+	set.Add(&c.Ignore, "the codes of warnings which are not counted in strict mode (e.g. read-only-dir)")
+}
+
+func (c *StrictModeConfig) PostLoad() error {
+	if c.MaxWarnings < 0 {
+		return fmt.Errorf("invalid strict.max-warnings %d: must not be negative", c.MaxWarnings)
+	}
+	return nil
+}
+
+func (c *StrictModeConfig) enabled() bool {
+	return c != nil && c.Enabled
+}
+
+// StrictModeError is returned when a run is not clean in strict mode (see StrictModeConfig).
+type StrictModeError struct {
+	Warnings    []Warning // the warnings counted against the allowed number
+	MaxWarnings int
+	Deprecated  []Warning // the uses of deprecated features
+}
+
+func (e *StrictModeError) Error() string {
+	var reasons []string
+	if len(e.Deprecated) > 0 {
+		var features []string
+		for _, w := range e.Deprecated {
+			features = append(features, w.Message)
+		}
+		reasons = append(reasons, "deprecated features were used: "+strings.Join(features, "; "))
+	}
+	if len(e.Warnings) > e.MaxWarnings {
+		noun := "warnings were"
+		if len(e.Warnings) == 1 {
+			noun = "warning was"
+		}
+		reasons = append(reasons, fmt.Sprintf("%d %s raised (at most %d allowed)", len(e.Warnings), noun, e.MaxWarnings))
+	}
+	return "strict mode: " + strings.Join(reasons, ", and ")
+}
+
+// WithStrictMode adds the strict mode config section (see StrictModeConfig), which is enabled with "strict.enabled"
+// in the config file, or with --strict (see WithGlobalConfigFlag).
+func (c *SetupConfig) WithStrictMode() *SetupConfig {
+	if c.DefaultStrictModeConfig == nil {
+		c.DefaultStrictModeConfig = &StrictModeConfig{}
+	}
+	return c
+}
+
+// Deprecated raises a warning for the use of a deprecated feature (e.g. a config key which has been replaced), which
+// fails the run in strict mode.
+func (s *State) Deprecated(feature, message string) {
+	s.Warn(DeprecatedWarning, message, map[string]any{"feature": feature})
+}
+
+// strictModeError returns a StrictModeError when strict mode is enabled and the run is not clean.
+func (a *application) strictModeError() error {
+	cfg := a.state.Config.Strict
+	if !cfg.enabled() {
+		return nil
+	}
+
+	e := &StrictModeError{MaxWarnings: cfg.MaxWarnings}
+	for _, w := range a.state.Warnings() {
+		switch {
+		case w.Code == DeprecatedWarning:
+			e.Deprecated = append(e.Deprecated, w)
+		case !contains(cfg.Ignore, w.Code):
+			e.Warnings = append(e.Warnings, w)
+		}
+	}
+	if len(e.Deprecated) == 0 && len(e.Warnings) <= e.MaxWarnings {
+		return nil
+	}
+	return e
+}
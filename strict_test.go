@@ -0,0 +1,56 @@
+package clio
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_application_strictModeError(t *testing.T) {
+	a := &application{}
+	a.state.Warn("unreadable-file", "skipped a file", map[string]any{"path": "a"})
+	assert.NoError(t, a.strictModeError(), "strict mode is not enabled")
+
+	a.state.Config.Strict = &StrictModeConfig{Enabled: true, MaxWarnings: 1}
+	assert.NoError(t, a.strictModeError())
+
+	a.state.Warn("read-only-dir", "continuing without writing", nil)
+	err := a.strictModeError()
+	var strictErr *StrictModeError
+	require.True(t, errors.As(err, &strictErr))
+	assert.Len(t, strictErr.Warnings, 2)
+	assert.Equal(t, "strict mode: 2 warnings were raised (at most 1 allowed)", err.Error())
+
+	a.state.Config.Strict.Ignore = []string{"read-only-dir"}
+	assert.NoError(t, a.strictModeError())
+
+	// deprecated features are never allowed
+	a.state.Deprecated("log.structured", "log.structured is deprecated, use log.format instead")
+	assert.EqualError(t, a.strictModeError(), "strict mode: deprecated features were used: log.structured is deprecated, use log.format instead")
+}
+
+func Test_StrictMode_flag(t *testing.T) {
+	run := func(args ...string) error {
+		app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithStrictMode().WithGlobalConfigFlag())
+		root := app.SetupRootCommand(&cobra.Command{
+			RunE: app.RunWithState(func(_ context.Context, s *State, _ []string) error {
+				s.Warn("unreadable-file", "skipped a file", nil)
+				return nil
+			}),
+		})
+		root.SetArgs(args)
+		return root.Execute()
+	}
+
+	require.NoError(t, run())
+	require.ErrorContains(t, run("--strict"), "strict mode: 1 warning was raised (at most 0 allowed)")
+}
+
+func Test_StrictModeConfig_PostLoad(t *testing.T) {
+	assert.NoError(t, (&StrictModeConfig{}).PostLoad())
+	assert.ErrorContains(t, (&StrictModeConfig{MaxWarnings: -1}).PostLoad(), "must not be negative")
+}
@@ -0,0 +1,460 @@
+package clio
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/wagoodman/go-partybus"
+
+	"github.com/boss-net/fangs"
+	"github.com/boss-net/go-logger"
+	"github.com/boss-net/go-logger/adapter/redact"
+)
+
+const defaultMetricsInterval = 10 * time.Second
+
+// TelemetryConfig is the user-facing configuration for exporting logs and metrics to a telemetry backend (see
+// SetupConfig.WithTelemetry for providing the exporters), and application metrics to statsd or a Prometheus
+// pushgateway (see State.Metrics).
+type TelemetryConfig struct {
+	Enabled         bool          `yaml:"enabled" json:"enabled" mapstructure:"enabled"`                            // export telemetry
+	ServiceName     string        `yaml:"service-name" json:"service-name" mapstructure:"service-name"`             // the service name attached to all telemetry (default: the application name)
+	Endpoint        string        `yaml:"endpoint" json:"endpoint" mapstructure:"endpoint"`                         // the OTLP/HTTP endpoint to export to, unless the application provides its own exporters
+	Logs            bool          `yaml:"logs" json:"logs" mapstructure:"logs"`                                     // export log records
+	Metrics         bool          `yaml:"metrics" json:"metrics" mapstructure:"metrics"`                            // export runtime and eventloop metrics
+	MetricsInterval time.Duration `yaml:"metrics-interval" json:"metrics-interval" mapstructure:"metrics-interval"` // how often metrics are collected
+	StatsD          string        `yaml:"statsd" json:"statsd" mapstructure:"statsd"`                               // the address (host:port) of a statsd server to send application metrics to
+	Pushgateway     string        `yaml:"pushgateway" json:"pushgateway" mapstructure:"pushgateway"`                // the URL of a Prometheus pushgateway to push application metrics to
+}
+
+var _ interface {
+	fangs.FieldDescriber
+	fangs.PostLoader
+} = (*TelemetryConfig)(nil)
+
+func (c *TelemetryConfig) DescribeFields(set fangs.FieldDescriptionSet) {
+	set.Add(&c.Enabled, "export telemetry (logs and metrics)")
+	set.Add(&c.ServiceName, "the service name attached to all telemetry (default: the application name)")
+	set.Add(&c.Endpoint, "the OTLP/HTTP endpoint to export to (e.g. http://localhost:4318), unless the application provides its own exporters")
+	set.Add(&c.Logs, "export log records")
+	set.Add(&c.Metrics, "export runtime, eventloop, and application metrics")
+	set.Add(&c.MetricsInterval, "how often metrics are collected (e.g. 10s)")
+	set.Add(&c.StatsD, "the address of a statsd server to send application metrics to (e.g. localhost:8125)")
+	set.Add(&c.Pushgateway, "the URL of a Prometheus pushgateway to push application metrics to once the run is complete (e.g. http://localhost:9091)")
+}
+
+func (c *TelemetryConfig) PostLoad() error {
+	if c.Endpoint != "" {
+		if u, err := url.Parse(c.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid telemetry endpoint %q", c.Endpoint)
+		}
+	}
+	if c.StatsD != "" {
+		if _, _, err := net.SplitHostPort(c.StatsD); err != nil {
+			return fmt.Errorf("invalid telemetry statsd address %q: expected host:port", c.StatsD)
+		}
+	}
+	if c.Pushgateway != "" {
+		if u, err := url.Parse(c.Pushgateway); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid telemetry pushgateway %q", c.Pushgateway)
+		}
+	}
+	if c.MetricsInterval < 0 {
+		return fmt.Errorf("invalid metrics-interval: %s", c.MetricsInterval)
+	}
+	if c.MetricsInterval == 0 {
+		c.MetricsInterval = defaultMetricsInterval
+	}
+	return nil
+}
+
+func (c *TelemetryConfig) exportsLogs() bool {
+	return c != nil && c.Enabled && c.Logs
+}
+
+func (c *TelemetryConfig) exportsMetrics() bool {
+	return c != nil && c.Enabled && c.Metrics
+}
+
+// LogRecord is a single log entry as exported to a telemetry backend.
+type LogRecord struct {
+	Time        time.Time
+	Level       logger.Level
+	Message     string
+	ServiceName string
+	Attributes  map[string]interface{}
+}
+
+// LogExporter receives all log records, typically adapting them to an OTLP logs exporter.
+type LogExporter interface {
+	ExportLog(record LogRecord)
+}
+
+// Metric is a single measurement as exported to a telemetry backend.
+type Metric struct {
+	Name        string
+	Description string
+	Unit        string
+	Value       float64
+	Time        time.Time
+	Attributes  map[string]string
+}
+
+// MetricsExporter receives batches of collected metrics, each the current value (or running total) of a series as of
+// the collection. Metrics are not recorded with instruments of an OTel MeterProvider (see TelemetryExporters), so to
+// export them through one, an adapter keeps the latest value of each metric and observes it from the callback of an
+// observable gauge (e.g. a Float64ObservableGauge per metric name).
+type MetricsExporter interface {
+	ExportMetrics(metrics []Metric)
+}
+
+// TelemetryExporters are the exporters used when telemetry is enabled in the application configuration. Clio does not
+// depend on any telemetry SDK or API (including the OTel metrics API, which requires a newer go than clio does), so
+// runtime and eventloop metrics are not registered with an OTel MeterProvider, and log records are not emitted to an
+// OTel LoggerProvider. Instead they are passed to these exporters, which are expected to be thin adapters (e.g. to
+// OpenTelemetry exporters, see MetricsExporter). Without exporters, telemetry is exported to the configured OTLP/HTTP
+// endpoint (see TelemetryConfig.Endpoint). Exporters which buffer telemetry may implement Flush() error, which is
+// called once the run is complete.
+type TelemetryExporters struct {
+	Logs    LogExporter
+	Metrics MetricsExporter
+}
+
+var _ logger.Logger = (*telemetryLogger)(nil)
+
+// telemetryLogger passes all log entries to the wrapped logger and tees them to the log exporter.
+type telemetryLogger struct {
+	log         logger.MessageLogger
+	exporter    LogExporter
+	level       logger.Level
+	serviceName string
+	attributes  map[string]interface{}
+}
+
+func newTelemetryLogger(log logger.Logger, exporter LogExporter, level logger.Level, serviceName string) *telemetryLogger {
+	return &telemetryLogger{
+		log:         log,
+		exporter:    exporter,
+		level:       level,
+		serviceName: serviceName,
+	}
+}
+
+func (t *telemetryLogger) Errorf(format string, args ...interface{}) {
+	t.log.Errorf(format, args...)
+	t.export(logger.ErrorLevel, fmt.Sprintf(format, args...))
+}
+
+func (t *telemetryLogger) Error(args ...interface{}) {
+	t.log.Error(args...)
+	t.export(logger.ErrorLevel, fmt.Sprint(args...))
+}
+
+func (t *telemetryLogger) Warnf(format string, args ...interface{}) {
+	t.log.Warnf(format, args...)
+	t.export(logger.WarnLevel, fmt.Sprintf(format, args...))
+}
+
+func (t *telemetryLogger) Warn(args ...interface{}) {
+	t.log.Warn(args...)
+	t.export(logger.WarnLevel, fmt.Sprint(args...))
+}
+
+func (t *telemetryLogger) Infof(format string, args ...interface{}) {
+	t.log.Infof(format, args...)
+	t.export(logger.InfoLevel, fmt.Sprintf(format, args...))
+}
+
+func (t *telemetryLogger) Info(args ...interface{}) {
+	t.log.Info(args...)
+	t.export(logger.InfoLevel, fmt.Sprint(args...))
+}
+
+func (t *telemetryLogger) Debugf(format string, args ...interface{}) {
+	t.log.Debugf(format, args...)
+	t.export(logger.DebugLevel, fmt.Sprintf(format, args...))
+}
+
+func (t *telemetryLogger) Debug(args ...interface{}) {
+	t.log.Debug(args...)
+	t.export(logger.DebugLevel, fmt.Sprint(args...))
+}
+
+func (t *telemetryLogger) Tracef(format string, args ...interface{}) {
+	t.log.Tracef(format, args...)
+	t.export(logger.TraceLevel, fmt.Sprintf(format, args...))
+}
+
+func (t *telemetryLogger) Trace(args ...interface{}) {
+	t.log.Trace(args...)
+	t.export(logger.TraceLevel, fmt.Sprint(args...))
+}
+
+func (t *telemetryLogger) WithFields(fields ...interface{}) logger.MessageLogger {
+	next := t.with(fields)
+	if l, ok := t.log.(logger.FieldLogger); ok {
+		next.log = l.WithFields(fields...)
+	}
+	return next
+}
+
+func (t *telemetryLogger) Nested(fields ...interface{}) logger.Logger {
+	next := t.with(fields)
+	if l, ok := t.log.(logger.NestedLogger); ok {
+		next.log = l.Nested(fields...)
+	}
+	return next
+}
+
+func (t *telemetryLogger) with(fields []interface{}) *telemetryLogger {
+	attributes := map[string]interface{}{}
+	for k, v := range t.attributes {
+		attributes[k] = v
+	}
+	for k, v := range logFields(fields) {
+		attributes[k] = v
+	}
+	next := *t
+	next.attributes = attributes
+	return &next
+}
+
+func (t *telemetryLogger) export(level logger.Level, msg string) {
+	if !levelEnabled(t.level, level) {
+		return
+	}
+	t.exporter.ExportLog(LogRecord{
+		Time:        time.Now(),
+		Level:       level,
+		Message:     msg,
+		ServiceName: t.serviceName,
+		Attributes:  t.attributes,
+	})
+}
+
+// levelEnabled indicates if entries at the given level are shown when configured with the given level.
+func levelEnabled(configured, level logger.Level) bool {
+	if configured == logger.DisabledLevel {
+		return false
+	}
+	rank := func(l logger.Level) int {
+		for i, v := range logger.Levels() {
+			if v == l {
+				return i
+			}
+		}
+		return -1
+	}
+	return rank(level) <= rank(configured)
+}
+
+// metricsCollector periodically collects runtime and eventloop metrics, exporting them in batches.
+type metricsCollector struct {
+	exporter    MetricsExporter
+	serviceName string
+	resource    map[string]string // attributes describing the environment (see Environment.resourceAttributes)
+	app         *Metrics          // application metrics (see State.Metrics)
+	started     time.Time
+
+	lock   sync.Mutex
+	events int64
+}
+
+func newMetricsCollector(exporter MetricsExporter, serviceName string) *metricsCollector {
+	return &metricsCollector{
+		exporter:    exporter,
+		serviceName: serviceName,
+		started:     time.Now(),
+	}
+}
+
+// eventHandled is called by the eventloop for each bus event given to the UI.
+func (m *metricsCollector) eventHandled() {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.events++
+}
+
+// start collects metrics at the given interval until the context is done, returning a function that waits for
+// collection to stop and exports a final batch.
+func (m *metricsCollector) start(ctx context.Context, interval time.Duration) func(err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.exporter.ExportMetrics(m.collect(nil))
+			}
+		}
+	}()
+
+	return func(err error) {
+		cancel()
+		<-done
+		m.exporter.ExportMetrics(m.collect(&err))
+	}
+}
+
+// collect returns a snapshot of all metrics. The final snapshot (once the run has completed) includes the run
+// duration and result.
+func (m *metricsCollector) collect(final *error) []Metric {
+	now := time.Now()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	m.lock.Lock()
+	events := m.events
+	m.lock.Unlock()
+
+	attrs := map[string]string{"service.name": m.serviceName}
+	for k, v := range m.resource {
+		attrs[k] = v
+	}
+	metrics := []Metric{
+		{Name: "process.runtime.go.goroutines", Description: "number of goroutines", Unit: "{goroutine}", Value: float64(runtime.NumGoroutine())},
+		{Name: "process.runtime.go.mem.heap_alloc", Description: "bytes of allocated heap objects", Unit: "By", Value: float64(mem.HeapAlloc)},
+		{Name: "process.runtime.go.gc.count", Description: "number of completed GC cycles", Unit: "{cycle}", Value: float64(mem.NumGC)},
+		{Name: "clio.eventloop.events", Description: "number of bus events handled by the UI", Unit: "{event}", Value: float64(events)},
+	}
+
+	if final != nil {
+		failed := 0.0
+		if *final != nil {
+			failed = 1
+		}
+		if reason, ok := CancelReasonOf(*final); ok {
+			attrs["clio.run.cancel_reason"] = string(reason)
+		}
+		metrics = append(metrics,
+			Metric{Name: "clio.run.duration", Description: "duration of the command run", Unit: "s", Value: now.Sub(m.started).Seconds()},
+			Metric{Name: "clio.run.failed", Description: "1 if the command run failed, otherwise 0", Unit: "1", Value: failed},
+		)
+	}
+
+	for i := range metrics {
+		metrics[i].Time = now
+		metrics[i].Attributes = attrs
+	}
+	return append(metrics, m.app.telemetryMetrics(now, attrs)...)
+}
+
+var _ UI = (*metricsUI)(nil)
+
+// metricsUI counts the events handled by the wrapped UI.
+type metricsUI struct {
+	UI
+	collector *metricsCollector
+}
+
+func (m metricsUI) Handle(e partybus.Event) error {
+	m.collector.eventHandled()
+	return m.UI.Handle(e)
+}
+
+// serviceName returns the configured service name, defaulting to the application name.
+func (c *TelemetryConfig) serviceName(id Identification) string {
+	if c != nil && c.ServiceName != "" {
+		return c.ServiceName
+	}
+	return id.Name
+}
+
+// withTelemetry tees all log entries to the log exporter (when enabled). Note: the logger is redacted again since
+// the exporter receives entries before the constructed logger redacts them.
+func (s *State) withTelemetry(cfg SetupConfig, config Config, lgr logger.Logger) logger.Logger {
+	exporter := cfg.TelemetryExporters.Logs
+	if otlp := s.otlpExporter(config.Telemetry); exporter == nil && otlp != nil {
+		exporter = otlp
+	}
+	if exporter == nil || !config.Telemetry.exportsLogs() || lgr == nil {
+		return lgr
+	}
+
+	level := logger.WarnLevel
+	if config.Log != nil {
+		level = config.Log.Level
+	}
+
+	// note: the invocation is only attached to the exported records (the wrapped logger attaches it as configured)
+	lgr = newTelemetryLogger(lgr, exporter, level, config.Telemetry.serviceName(cfg.ID)).with(s.invocation.fields())
+	if s.RedactStore != nil {
+		lgr = redact.New(lgr, s.RedactStore)
+	}
+	return lgr
+}
+
+// startMetrics starts collecting metrics for the run (when enabled), returning the UIs to use (instrumented to count
+// events) and a function to call with the run result once the run is complete, which also flushes the exporters.
+func (a *application) startMetrics(ctx context.Context) ([]UI, func(error)) {
+	exporter := a.setupConfig.TelemetryExporters.Metrics
+	cfg := a.state.Config.Telemetry
+	if otlp := a.state.otlpExporter(cfg); exporter == nil && otlp != nil {
+		exporter = otlp
+	}
+	if exporter == nil || !cfg.exportsMetrics() {
+		return a.state.UIs, func(error) { a.flushTelemetry() }
+	}
+
+	collector := newMetricsCollector(exporter, cfg.serviceName(a.setupConfig.ID))
+	collector.resource = a.state.environment.resourceAttributes()
+	collector.app = a.state.metrics
+
+	var uis []UI
+	for _, ui := range a.state.UIs {
+		uis = append(uis, metricsUI{UI: ui, collector: collector})
+	}
+
+	interval := cfg.MetricsInterval
+	if interval <= 0 {
+		interval = defaultMetricsInterval
+	}
+	stop := collector.start(ctx, interval)
+	return uis, func(err error) {
+		stop(err)
+		a.flushTelemetry()
+	}
+}
+
+// flushTelemetry sends all buffered telemetry (see TelemetryExporters) and log records (see RemoteLogConfig), and
+// pushes application metrics (see State.Metrics).
+func (a *application) flushTelemetry() {
+	if err := a.state.metrics.flush(context.Background()); err != nil {
+		a.state.Logger.Debugf("unable to flush metrics: %v", err)
+	}
+	if remote := a.state.remoteLogs.exporter; remote != nil {
+		// note: the error is not logged, since it would be shipped again
+		_ = remote.Flush()
+	}
+	if !a.state.Config.Telemetry.exportsLogs() && !a.state.Config.Telemetry.exportsMetrics() {
+		return
+	}
+	exporters := []any{a.setupConfig.TelemetryExporters.Logs, a.setupConfig.TelemetryExporters.Metrics}
+	if otlp := a.state.otlpExporter(a.state.Config.Telemetry); otlp != nil {
+		exporters = append(exporters, otlp)
+	}
+	for _, exporter := range exporters {
+		f, ok := exporter.(telemetryFlusher)
+		if !ok {
+			continue
+		}
+		if err := f.Flush(); err != nil {
+			a.state.Logger.Debugf("unable to flush telemetry: %v", err)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package clio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boss-net/go-logger"
+)
+
+const (
+	// otlpBatchSize is the number of log records buffered before they are sent (remaining records are sent once the
+	// run is complete)
+	otlpBatchSize = 512
+
+	otlpTimeout = 10 * time.Second
+)
+
+var _ interface {
+	LogExporter
+	MetricsExporter
+	telemetryFlusher
+} = (*otlpExporter)(nil)
+
+// telemetryFlusher is implemented by exporters that buffer telemetry, which is flushed once the run is complete.
+type telemetryFlusher interface {
+	Flush() error
+}
+
+// otlpExporter exports log records and metrics to an OTLP/HTTP endpoint (e.g. an OpenTelemetry collector) with the
+// JSON encoding, which is used when the telemetry endpoint is configured (see TelemetryConfig.Endpoint) and the
+// application does not provide its own exporters.
+type otlpExporter struct {
+	endpoint string
+	client   *http.Client
+	resource map[string]string
+
+	lock sync.Mutex
+	logs []LogRecord
+}
+
+func newOTLPExporter(endpoint string, client *http.Client, resource map[string]string) *otlpExporter {
+	return &otlpExporter{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   client,
+		resource: resource,
+	}
+}
+
+func (e *otlpExporter) ExportLog(record LogRecord) {
+	e.lock.Lock()
+	e.logs = append(e.logs, record)
+	full := len(e.logs) >= otlpBatchSize
+	e.lock.Unlock()
+
+	if full {
+		// note: there is nowhere to report the error to, since logging it would export it again
+		_ = e.Flush()
+	}
+}
+
+// Flush sends all buffered log records.
+func (e *otlpExporter) Flush() error {
+	e.lock.Lock()
+	records := e.logs
+	e.logs = nil
+	e.lock.Unlock()
+
+	if len(records) == 0 {
+		return nil
+	}
+	return e.send("/v1/logs", e.logsRequest(records))
+}
+
+func (e *otlpExporter) ExportMetrics(metrics []Metric) {
+	if len(metrics) == 0 {
+		return
+	}
+	_ = e.send("/v1/metrics", e.metricsRequest(metrics))
+}
+
+func (e *otlpExporter) send(path string, body any) error {
+	contents, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("unable to encode telemetry: %w", err)
+	}
+	resp, err := e.client.Post(e.endpoint+path, "application/json", bytes.NewReader(contents))
+	if err != nil {
+		return fmt.Errorf("unable to export telemetry: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unable to export telemetry: %s", resp.Status)
+	}
+	return nil
+}
+
+// the following types are the JSON encoding of the OTLP protobuf messages (see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding)
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	Body           otlpAnyValue   `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpMetric struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Unit        string    `json:"unit,omitempty"`
+	Gauge       otlpGauge `json:"gauge"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpDataPoint struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	AsDouble     float64        `json:"asDouble"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+// logsRequest groups the records by service name, with the resource attributes of the exporter.
+func (e *otlpExporter) logsRequest(records []LogRecord) otlpLogsRequest {
+	var req otlpLogsRequest
+	index := map[string]int{}
+	for _, r := range records {
+		i, ok := index[r.ServiceName]
+		if !ok {
+			i = len(req.ResourceLogs)
+			index[r.ServiceName] = i
+			req.ResourceLogs = append(req.ResourceLogs, otlpResourceLogs{
+				Resource:  e.otlpResource(r.ServiceName),
+				ScopeLogs: []otlpScopeLogs{{Scope: otlpScope{Name: "clio"}}},
+			})
+		}
+
+		scope := &req.ResourceLogs[i].ScopeLogs[0]
+		scope.LogRecords = append(scope.LogRecords, otlpLogRecord{
+			TimeUnixNano:   otlpTime(r.Time),
+			SeverityNumber: otlpSeverity(r.Level),
+			SeverityText:   strings.ToUpper(string(r.Level)),
+			Body:           otlpValue(r.Message),
+			Attributes:     otlpAttributes(r.Attributes),
+		})
+	}
+	return req
+}
+
+// metricsRequest exports each metric as a gauge, with the service name of the metrics (and the resource attributes
+// of the exporter) as the resource.
+func (e *otlpExporter) metricsRequest(metrics []Metric) otlpMetricsRequest {
+	var serviceName string
+	scope := otlpScopeMetrics{Scope: otlpScope{Name: "clio"}}
+	for _, m := range metrics {
+		attributes := map[string]any{}
+		for k, v := range m.Attributes {
+			if k == "service.name" {
+				serviceName = v
+				continue
+			}
+			if _, ok := e.resource[k]; ok {
+				continue
+			}
+			attributes[k] = v
+		}
+		scope.Metrics = append(scope.Metrics, otlpMetric{
+			Name:        m.Name,
+			Description: m.Description,
+			Unit:        m.Unit,
+			Gauge: otlpGauge{DataPoints: []otlpDataPoint{{
+				TimeUnixNano: otlpTime(m.Time),
+				AsDouble:     m.Value,
+				Attributes:   otlpAttributes(attributes),
+			}}},
+		})
+	}
+	return otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     e.otlpResource(serviceName),
+		ScopeMetrics: []otlpScopeMetrics{scope},
+	}}}
+}
+
+func (e *otlpExporter) otlpResource(serviceName string) otlpResource {
+	attributes := map[string]any{"service.name": serviceName}
+	for k, v := range e.resource {
+		attributes[k] = v
+	}
+	return otlpResource{Attributes: otlpAttributes(attributes)}
+}
+
+// otlpAttributes converts the attributes to key-values, sorted by key.
+func otlpAttributes(attributes map[string]any) []otlpKeyValue {
+	var keys []string
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var out []otlpKeyValue
+	for _, k := range keys {
+		out = append(out, otlpKeyValue{Key: k, Value: otlpValue(attributes[k])})
+	}
+	return out
+}
+
+func otlpValue(v any) otlpAnyValue {
+	switch v := v.(type) {
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		// note: 64-bit integers are encoded as strings in JSON
+		s := fmt.Sprint(v)
+		return otlpAnyValue{IntValue: &s}
+	case float32:
+		f := float64(v)
+		return otlpAnyValue{DoubleValue: &f}
+	case float64:
+		return otlpAnyValue{DoubleValue: &v}
+	case string:
+		return otlpAnyValue{StringValue: &v}
+	default:
+		s := fmt.Sprint(v)
+		return otlpAnyValue{StringValue: &s}
+	}
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// otlpSeverity returns the OTLP severity number for the log level (see
+// https://opentelemetry.io/docs/specs/otel/logs/data-model/#field-severitynumber).
+func otlpSeverity(level logger.Level) int {
+	switch level {
+	case logger.ErrorLevel:
+		return 17
+	case logger.WarnLevel:
+		return 13
+	case logger.InfoLevel:
+		return 9
+	case logger.DebugLevel:
+		return 5
+	case logger.TraceLevel:
+		return 1
+	}
+	return 0
+}
+
+// otlpExporter returns the exporter for the configured telemetry endpoint (shared by the logger and the metrics
+// collector), or nil when no endpoint is configured.
+func (s *State) otlpExporter(cfg *TelemetryConfig) *otlpExporter {
+	if cfg == nil || cfg.Endpoint == "" {
+		return nil
+	}
+	s.otlp.Do(func() {
+		client := s.HTTPClient()
+		client.Timeout = otlpTimeout
+		s.otlp.exporter = newOTLPExporter(cfg.Endpoint, client, s.environment.resourceAttributes())
+	})
+	return s.otlp.exporter
+}
+
+// otlpExporterOnce creates the exporter for the configured telemetry endpoint once.
+type otlpExporterOnce struct {
+	sync.Once
+	exporter *otlpExporter
+}
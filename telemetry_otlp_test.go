@@ -0,0 +1,152 @@
+package clio
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/go-logger"
+)
+
+type otlpCollector struct {
+	lock     sync.Mutex
+	requests map[string][]string
+}
+
+func newOTLPCollector(t *testing.T) (*otlpCollector, *httptest.Server) {
+	c := &otlpCollector{requests: map[string][]string{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		c.lock.Lock()
+		c.requests[r.URL.Path] = append(c.requests[r.URL.Path], string(body))
+		c.lock.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return c, server
+}
+
+func (c *otlpCollector) get(path string) []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.requests[path]
+}
+
+func Test_otlpExporter_logs(t *testing.T) {
+	collector, server := newOTLPCollector(t)
+	e := newOTLPExporter(server.URL+"/", server.Client(), map[string]string{"os.type": "linux"})
+
+	e.ExportLog(LogRecord{
+		Time:        time.Unix(1, 5),
+		Level:       logger.WarnLevel,
+		Message:     "careful",
+		ServiceName: "app",
+		Attributes:  map[string]interface{}{"count": 3, "path": "a.txt", "ok": true},
+	})
+	assert.Empty(t, collector.get("/v1/logs"), "records are buffered until flushed")
+
+	require.NoError(t, e.Flush())
+	require.NoError(t, e.Flush())
+	require.Len(t, collector.get("/v1/logs"), 1)
+	assert.JSONEq(t, `{"resourceLogs":[{
+		"resource":{"attributes":[
+			{"key":"os.type","value":{"stringValue":"linux"}},
+			{"key":"service.name","value":{"stringValue":"app"}}
+		]},
+		"scopeLogs":[{"scope":{"name":"clio"},"logRecords":[{
+			"timeUnixNano":"1000000005",
+			"severityNumber":13,
+			"severityText":"WARN",
+			"body":{"stringValue":"careful"},
+			"attributes":[
+				{"key":"count","value":{"intValue":"3"}},
+				{"key":"ok","value":{"boolValue":true}},
+				{"key":"path","value":{"stringValue":"a.txt"}}
+			]
+		}]}]
+	}]}`, collector.get("/v1/logs")[0])
+}
+
+func Test_otlpExporter_logsBatch(t *testing.T) {
+	collector, server := newOTLPCollector(t)
+	e := newOTLPExporter(server.URL, server.Client(), nil)
+
+	for i := 0; i < otlpBatchSize; i++ {
+		e.ExportLog(LogRecord{Level: logger.InfoLevel, Message: "entry"})
+	}
+	assert.Len(t, collector.get("/v1/logs"), 1)
+}
+
+func Test_otlpExporter_metrics(t *testing.T) {
+	collector, server := newOTLPCollector(t)
+	e := newOTLPExporter(server.URL, server.Client(), map[string]string{"os.type": "linux"})
+
+	e.ExportMetrics([]Metric{{
+		Name:        "clio.run.duration",
+		Description: "duration of the command run",
+		Unit:        "s",
+		Value:       1.5,
+		Time:        time.Unix(2, 0),
+		Attributes:  map[string]string{"service.name": "app", "os.type": "linux", "result": "ok"},
+	}})
+
+	require.Len(t, collector.get("/v1/metrics"), 1)
+	assert.JSONEq(t, `{"resourceMetrics":[{
+		"resource":{"attributes":[
+			{"key":"os.type","value":{"stringValue":"linux"}},
+			{"key":"service.name","value":{"stringValue":"app"}}
+		]},
+		"scopeMetrics":[{"scope":{"name":"clio"},"metrics":[{
+			"name":"clio.run.duration",
+			"description":"duration of the command run",
+			"unit":"s",
+			"gauge":{"dataPoints":[{
+				"timeUnixNano":"2000000000",
+				"asDouble":1.5,
+				"attributes":[{"key":"result","value":{"stringValue":"ok"}}]
+			}]}
+		}]}]
+	}]}`, collector.get("/v1/metrics")[0])
+}
+
+func Test_Application_telemetryEndpoint(t *testing.T) {
+	collector, server := newOTLPCollector(t)
+	t.Setenv("APP_TELEMETRY_ENABLED", "true")
+	t.Setenv("APP_TELEMETRY_ENDPOINT", server.URL)
+
+	cfg := NewSetupConfig(Identification{Name: "app", Version: "1.0"}).
+		WithNoBus().
+		WithLoggingConfig(LoggingConfig{Level: logger.InfoLevel}).
+		WithTelemetry(TelemetryExporters{})
+
+	app := New(*cfg)
+	root := app.SetupRootCommand(&cobra.Command{
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+	})
+	root.SetArgs([]string{})
+	require.NoError(t, root.Execute())
+
+	require.NotEmpty(t, collector.get("/v1/logs"))
+	require.NotEmpty(t, collector.get("/v1/metrics"))
+
+	var logs otlpLogsRequest
+	require.NoError(t, json.Unmarshal([]byte(collector.get("/v1/logs")[0]), &logs))
+	require.Len(t, logs.ResourceLogs, 1)
+	assert.Contains(t, logs.ResourceLogs[0].Resource.Attributes, otlpKeyValue{Key: "service.name", Value: otlpValue("app")})
+}
+
+func TestTelemetryConfig_PostLoad_endpoint(t *testing.T) {
+	require.NoError(t, (&TelemetryConfig{Endpoint: "http://localhost:4318"}).PostLoad())
+	require.ErrorContains(t, (&TelemetryConfig{Endpoint: "localhost:4318"}).PostLoad(), "invalid telemetry endpoint")
+}
@@ -0,0 +1,147 @@
+package clio
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/go-logger"
+	"github.com/boss-net/go-logger/adapter/discard"
+)
+
+type recordingExporter struct {
+	lock    sync.Mutex
+	logs    []LogRecord
+	metrics [][]Metric
+}
+
+func (r *recordingExporter) ExportLog(record LogRecord) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.logs = append(r.logs, record)
+}
+
+func (r *recordingExporter) ExportMetrics(metrics []Metric) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.metrics = append(r.metrics, metrics)
+}
+
+func (r *recordingExporter) metric(name string) *Metric {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i := len(r.metrics) - 1; i >= 0; i-- {
+		for _, m := range r.metrics[i] {
+			if m.Name == name {
+				return &m
+			}
+		}
+	}
+	return nil
+}
+
+func Test_telemetryLogger(t *testing.T) {
+	rec := &recordingExporter{}
+	l := newTelemetryLogger(discard.New(), rec, logger.InfoLevel, "app")
+
+	l.Debug("not exported")
+	l.Nested("component", "eventloop").WithFields("count", 3).Warnf("slow: %s", "yes")
+	l.Info("started")
+
+	require.Len(t, rec.logs, 2)
+
+	assert.Equal(t, logger.WarnLevel, rec.logs[0].Level)
+	assert.Equal(t, "slow: yes", rec.logs[0].Message)
+	assert.Equal(t, "app", rec.logs[0].ServiceName)
+	assert.Equal(t, map[string]interface{}{"component": "eventloop", "count": 3}, rec.logs[0].Attributes)
+
+	assert.Equal(t, logger.InfoLevel, rec.logs[1].Level)
+	assert.Equal(t, "started", rec.logs[1].Message)
+	assert.Empty(t, rec.logs[1].Attributes)
+}
+
+func Test_levelEnabled(t *testing.T) {
+	assert.True(t, levelEnabled(logger.InfoLevel, logger.ErrorLevel))
+	assert.True(t, levelEnabled(logger.InfoLevel, logger.InfoLevel))
+	assert.False(t, levelEnabled(logger.InfoLevel, logger.DebugLevel))
+	assert.False(t, levelEnabled(logger.DisabledLevel, logger.ErrorLevel))
+}
+
+func Test_metricsCollector(t *testing.T) {
+	rec := &recordingExporter{}
+	c := newMetricsCollector(rec, "app")
+
+	stop := c.start(context.Background(), time.Millisecond)
+	c.eventHandled()
+	c.eventHandled()
+	time.Sleep(10 * time.Millisecond)
+	stop(errors.New("failed"))
+
+	require.GreaterOrEqual(t, len(rec.metrics), 2, "expected periodic and final batches")
+
+	events := rec.metric("clio.eventloop.events")
+	require.NotNil(t, events)
+	assert.Equal(t, 2.0, events.Value)
+	assert.Equal(t, "app", events.Attributes["service.name"])
+
+	failed := rec.metric("clio.run.failed")
+	require.NotNil(t, failed)
+	assert.Equal(t, 1.0, failed.Value)
+	assert.NotNil(t, rec.metric("clio.run.duration"))
+	assert.NotNil(t, rec.metric("process.runtime.go.goroutines"))
+}
+
+func Test_Application_telemetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantLogs    bool
+		wantMetrics bool
+	}{
+		{
+			name: "disabled by default",
+		},
+		{
+			name:        "enabled",
+			env:         map[string]string{"APP_TELEMETRY_ENABLED": "true"},
+			wantLogs:    true,
+			wantMetrics: true,
+		},
+		{
+			name:        "metrics only",
+			env:         map[string]string{"APP_TELEMETRY_ENABLED": "true", "APP_TELEMETRY_LOGS": "false"},
+			wantMetrics: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			rec := &recordingExporter{}
+			cfg := NewSetupConfig(Identification{Name: "app", Version: "1.0"}).
+				WithNoBus().
+				WithLoggingConfig(LoggingConfig{Level: logger.InfoLevel}).
+				WithTelemetry(TelemetryExporters{Logs: rec, Metrics: rec})
+
+			app := New(*cfg)
+			root := app.SetupRootCommand(&cobra.Command{
+				RunE: func(cmd *cobra.Command, args []string) error {
+					return nil
+				},
+			})
+			root.SetArgs([]string{})
+			require.NoError(t, root.Execute())
+
+			assert.Equal(t, tt.wantLogs, len(rec.logs) > 0)
+			assert.Equal(t, tt.wantMetrics, rec.metric("clio.run.duration") != nil)
+		})
+	}
+}
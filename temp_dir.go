@@ -0,0 +1,143 @@
+package clio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/boss-net/fangs"
+	"github.com/boss-net/go-logger/adapter/discard"
+)
+
+type TempConfig struct {
+	Root string `yaml:"root" json:"root" mapstructure:"root"` // directory to create temporary directories within (default: the system temp directory)
+	Keep bool   `yaml:"keep" json:"keep" mapstructure:"keep"` // do not remove temporary directories on exit
+}
+
+var _ interface {
+	fangs.FlagAdder
+	fangs.FieldDescriber
+	fangs.PostLoader
+} = (*TempConfig)(nil)
+
+func (c *TempConfig) AddFlags(flags fangs.FlagSet) {
+	flags.BoolVarP(&c.Keep, "keep-temp", "", "do not remove temporary files on exit (useful for debugging)")
+}
+
+func (c *TempConfig) DescribeFields(set fangs.FieldDescriptionSet) {
+	set.Add(&c.Root, "directory to create temporary files within (default: the system temp directory)")
+	set.Add(&c.Keep, "do not remove temporary files on exit")
+}
+
+func (c *TempConfig) PostLoad() error {
+	if c.Root == "" {
+		return nil
+	}
+	root, err := filepath.Abs(c.Root)
+	if err != nil {
+		return fmt.Errorf("invalid temp root %q: %w", c.Root, err)
+	}
+	if fi, err := os.Stat(root); err == nil && !fi.IsDir() {
+		return fmt.Errorf("invalid temp root %q: not a directory", c.Root)
+	}
+	c.Root = root
+	return nil
+}
+
+// tempDirs tracks the temporary directory for a single run, within which all directories from State.TempDir()
+// are allocated, so that everything can be removed at once on exit.
+type tempDirs struct {
+	lock   sync.Mutex
+	prefix string
+	root   string // where the run directory is created, unless configured (default: the system temp directory)
+	runDir string
+}
+
+// TempDir allocates a new temporary directory, which is removed when the application exits (including on interrupt)
+// unless the user asked to keep temporary files (--keep-temp).
+func (s *State) TempDir() (string, error) {
+	s.temp.lock.Lock()
+	defer s.temp.lock.Unlock()
+
+	cfg := s.currentConfig()
+	_, dirMode := cfg.Permissions.modes()
+
+	if s.temp.runDir == "" {
+		root := s.temp.root
+		if root == "" {
+			root = os.TempDir()
+		}
+		if cfg.Temp != nil && cfg.Temp.Root != "" {
+			root = cfg.Temp.Root
+			if err := mkdirAll(root, dirMode); err != nil {
+				return "", fmt.Errorf("unable to create temp root: %w", err)
+			}
+		}
+
+		prefix := s.temp.prefix
+		if prefix == "" {
+			prefix = "clio"
+		}
+
+		dir, err := makeTempDir(root, prefix+"-", dirMode)
+		if err != nil {
+			return "", err
+		}
+		s.temp.runDir = dir
+	}
+
+	return makeTempDir(s.temp.runDir, "", dirMode)
+}
+
+func makeTempDir(root, pattern string, mode os.FileMode) (string, error) {
+	dir, err := os.MkdirTemp(root, pattern)
+	if err != nil {
+		return "", fmt.Errorf("unable to create temp dir: %w", err)
+	}
+	if err := chmod(dir, mode); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// removeTempDirs removes all directories allocated with TempDir (unless configured to keep them).
+func (s *State) removeTempDirs() {
+	s.temp.lock.Lock()
+	defer s.temp.lock.Unlock()
+
+	dir := s.temp.runDir
+	if dir == "" {
+		return
+	}
+
+	var log = s.currentLogger()
+	if log == nil {
+		log = discard.New()
+	}
+
+	if cfg := s.currentConfig(); cfg.Temp != nil && cfg.Temp.Keep {
+		log.Infof("keeping temp dir: %s", dir)
+		return
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		log.Warnf("unable to remove temp dir %q: %v", dir, err)
+		return
+	}
+	s.temp.runDir = ""
+}
+
+// writableTempRoot returns the first of $TMPDIR (when set) and /tmp which is an existing directory that can be written
+// to, or empty if neither can be.
+func writableTempRoot(getenv func(string) string, dirMode os.FileMode) string {
+	for _, dir := range []string{getenv("TMPDIR"), "/tmp"} {
+		if dir == "" {
+			continue
+		}
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() && probeWritable(dir, dirMode) {
+			return dir
+		}
+	}
+	return ""
+}
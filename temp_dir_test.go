@@ -0,0 +1,100 @@
+package clio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boss-net/go-logger/adapter/discard"
+)
+
+func Test_State_TempDir(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      *TempConfig
+		wantKept bool
+	}{
+		{
+			name: "no config",
+		},
+		{
+			name: "removed by default",
+			cfg:  &TempConfig{},
+		},
+		{
+			name:     "keep",
+			cfg:      &TempConfig{Keep: true},
+			wantKept: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			if tt.cfg != nil {
+				tt.cfg.Root = filepath.Join(root, "nested")
+			} else {
+				t.Setenv("TMPDIR", root)
+			}
+
+			s := &State{Config: Config{Temp: tt.cfg}, Logger: discard.New()}
+			s.temp.prefix = "app"
+
+			first, err := s.TempDir()
+			require.NoError(t, err)
+			second, err := s.TempDir()
+			require.NoError(t, err)
+
+			assert.NotEqual(t, first, second)
+			assert.Equal(t, filepath.Dir(first), filepath.Dir(second), "all temp dirs should be within the same run dir")
+			assert.DirExists(t, first)
+			assert.DirExists(t, second)
+
+			runDir := filepath.Dir(first)
+			assert.Contains(t, filepath.Base(runDir), "app-")
+			assert.True(t, strings.HasPrefix(runDir, root))
+
+			s.removeTempDirs()
+
+			if tt.wantKept {
+				assert.DirExists(t, runDir)
+			} else {
+				assert.NoDirExists(t, runDir)
+			}
+		})
+	}
+}
+
+func Test_Application_run_removesTempDirs(t *testing.T) {
+	a := &application{state: State{Logger: discard.New()}}
+
+	dir, err := a.state.TempDir()
+	require.NoError(t, err)
+
+	// interrupted before the worker exits
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	errs := make(chan error)
+	defer close(errs)
+
+	assert.ErrorIs(t, a.run(ctx, errs), context.Canceled)
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_TempConfig_PostLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o600))
+
+	cfg := &TempConfig{Root: file}
+	assert.Error(t, cfg.PostLoad())
+
+	cfg = &TempConfig{Root: "relative"}
+	require.NoError(t, cfg.PostLoad())
+	assert.True(t, filepath.IsAbs(cfg.Root))
+}
@@ -0,0 +1,96 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// testChildProcessEnv is set for the test binary when it is run in place of the application (see useTestChildProcess).
+const testChildProcessEnv = "CLIO_TEST_CHILD_PROCESS"
+
+// runTestApp executes the root command of a test application in-process with the given command line (after the
+// application name) and stdin, returning the output written to stdout and stderr.
+func runTestApp(root *cobra.Command, stdin string, args ...string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	root.SetIn(strings.NewReader(stdin))
+	root.SetOut(&stdout)
+	root.SetErr(&stderr)
+	root.SetArgs(args)
+	err := root.Execute()
+	return stdout.String(), stderr.String(), err
+}
+
+// testOutput is the combined output of a test application. Stdout and stderr may be written concurrently (e.g. when
+// copied from a child process), so writes are synchronized, and it does not implement io.ReaderFrom since
+// bytes.Buffer.ReadFrom discards whatever is written while it waits for input.
+type testOutput struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (o *testOutput) Write(p []byte) (int, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.buf.Write(p)
+}
+
+func (o *testOutput) String() string {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.buf.String()
+}
+
+// executeTestApp executes a test application in-process with the given command line (after the application name),
+// returning the output (stdout and stderr combined) and the exit code (see Application.Execute).
+func executeTestApp(app Application, root *cobra.Command, args ...string) (string, int) {
+	var out testOutput
+	root.SetOut(&out)
+	root.SetErr(&out)
+	root.SetArgs(args)
+	code := app.Execute(context.Background())
+	return out.String(), code
+}
+
+// useTestChildProcess runs the test binary (as Test_childProcess) in place of the application whenever it is run again
+// as a child process (e.g. by "rerun" or "examples test") for the duration of the test.
+func useTestChildProcess(t *testing.T) {
+	t.Helper()
+	original := applicationCommand
+	t.Cleanup(func() { applicationCommand = original })
+	applicationCommand = func(args []string) (*exec.Cmd, error) {
+		return exec.Command(os.Args[0], append([]string{"-test.run=^Test_childProcess$", "--"}, args...)...), nil
+	}
+	t.Setenv(testChildProcessEnv, "true")
+}
+
+// Test_childProcess stands in for the application run as a child process (see useTestChildProcess), printing the
+// arguments it was given as "ran: <args>" and exiting with the code given with --exit=<code> (or 0).
+func Test_childProcess(t *testing.T) {
+	if os.Getenv(testChildProcessEnv) == "" {
+		t.Skip("only run as a child process")
+	}
+	args := os.Args
+	for i, arg := range args {
+		if arg == "--" {
+			args = args[i+1:]
+			break
+		}
+	}
+	fmt.Println("ran:", strings.Join(args, " "))
+	code := 0
+	for _, arg := range args {
+		if value := strings.TrimPrefix(arg, "--exit="); value != arg {
+			code, _ = strconv.Atoi(value)
+		}
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,88 @@
+package clio
+
+import (
+	"context"
+
+	"github.com/wagoodman/go-partybus"
+)
+
+// TracePropagator injects trace context into (and extracts it from) a string map carrier, typically an adapter to an
+// OpenTelemetry TextMapPropagator (e.g. using propagation.MapCarrier).
+type TracePropagator interface {
+	Inject(ctx context.Context, carrier map[string]string)
+	Extract(ctx context.Context, carrier map[string]string) context.Context
+}
+
+// ContextHandler can be implemented by UIs to receive the trace context of each event published with State.Publish.
+// When implemented, HandleContext is called instead of Handle.
+type ContextHandler interface {
+	HandleContext(ctx context.Context, e partybus.Event) error
+}
+
+// tracedValue wraps the value of an event published with State.Publish, carrying the trace context of the publisher.
+// Events are always unwrapped before being given to UIs (see EventContext).
+type tracedValue struct {
+	value   interface{}
+	carrier map[string]string
+}
+
+// Publish publishes the event on the bus along with the trace context from the given context (when a TracePropagator
+// has been configured with SetupConfig.WithTracePropagator).
+func (s *State) Publish(ctx context.Context, e partybus.Event) {
+	if s.Bus == nil {
+		return
+	}
+	if s.propagator != nil && ctx != nil {
+		carrier := map[string]string{}
+		s.propagator.Inject(ctx, carrier)
+		if len(carrier) > 0 {
+			e.Value = tracedValue{value: e.Value, carrier: carrier}
+		}
+	}
+	s.Bus.Publish(e)
+}
+
+// EventContext restores the trace context of the publisher (see State.Publish) onto the given context, returning
+// the event with its original value. This is only needed for subscribers outside of the UI, since UIs are always
+// given unwrapped events.
+func (s *State) EventContext(ctx context.Context, e partybus.Event) (context.Context, partybus.Event) {
+	return eventContext(ctx, s.propagator, e)
+}
+
+func eventContext(ctx context.Context, propagator TracePropagator, e partybus.Event) (context.Context, partybus.Event) {
+	traced, ok := e.Value.(tracedValue)
+	if !ok {
+		return ctx, e
+	}
+	e.Value = traced.value
+	if propagator != nil {
+		ctx = propagator.Extract(ctx, traced.carrier)
+	}
+	return ctx, e
+}
+
+var _ UI = (*tracingUI)(nil)
+
+// tracingUI unwraps events published with State.Publish, restoring the trace context for UIs that implement
+// ContextHandler.
+type tracingUI struct {
+	UI
+	ctx        context.Context
+	propagator TracePropagator
+}
+
+func (t tracingUI) Handle(e partybus.Event) error {
+	ctx, e := eventContext(t.ctx, t.propagator, e)
+	if h, ok := t.UI.(ContextHandler); ok {
+		return h.HandleContext(ctx, e)
+	}
+	return t.UI.Handle(e)
+}
+
+func withTracing(ctx context.Context, propagator TracePropagator, uis []UI) []UI {
+	var out []UI
+	for _, ui := range uis {
+		out = append(out, tracingUI{UI: ui, ctx: ctx, propagator: propagator})
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package clio
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wagoodman/go-partybus"
+)
+
+type traceKey struct{}
+
+type testPropagator struct{}
+
+func (testPropagator) Inject(ctx context.Context, carrier map[string]string) {
+	if id, ok := ctx.Value(traceKey{}).(string); ok {
+		carrier["traceparent"] = id
+	}
+}
+
+func (testPropagator) Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if id, ok := carrier["traceparent"]; ok {
+		return context.WithValue(ctx, traceKey{}, id)
+	}
+	return ctx
+}
+
+type contextUI struct {
+	mockUI
+	traces []string
+	values []interface{}
+}
+
+func (c *contextUI) HandleContext(ctx context.Context, e partybus.Event) error {
+	id, _ := ctx.Value(traceKey{}).(string)
+	c.traces = append(c.traces, id)
+	c.values = append(c.values, e.Value)
+	return nil
+}
+
+type valueUI struct {
+	mockUI
+	values []interface{}
+}
+
+func (v *valueUI) Handle(e partybus.Event) error {
+	v.values = append(v.values, e.Value)
+	return nil
+}
+
+func Test_State_Publish(t *testing.T) {
+	bus := partybus.NewBus()
+	sub := bus.Subscribe()
+	s := &State{Bus: bus, propagator: testPropagator{}}
+
+	ctx := context.WithValue(context.Background(), traceKey{}, "00-trace-span-01")
+	s.Publish(ctx, partybus.Event{Type: "test", Value: "value"})
+	s.Publish(context.Background(), partybus.Event{Type: "test", Value: "untraced"})
+
+	e := <-sub.Events()
+	_, isTraced := e.Value.(tracedValue)
+	assert.True(t, isTraced)
+
+	restored, e := s.EventContext(context.Background(), e)
+	assert.Equal(t, "value", e.Value)
+	assert.Equal(t, "00-trace-span-01", restored.Value(traceKey{}))
+
+	e = <-sub.Events()
+	assert.Equal(t, "untraced", e.Value)
+}
+
+func Test_tracingUI(t *testing.T) {
+	ctxUI := &contextUI{}
+	plain := &valueUI{}
+	uis := withTracing(context.Background(), testPropagator{}, []UI{ctxUI, plain})
+	require.Len(t, uis, 2)
+
+	traced := partybus.Event{Type: "test", Value: tracedValue{value: "value", carrier: map[string]string{"traceparent": "00-trace-span-01"}}}
+	for _, ui := range uis {
+		require.NoError(t, ui.Handle(traced))
+	}
+	require.NoError(t, uis[0].Handle(partybus.Event{Type: "test", Value: "untraced"}))
+
+	assert.Equal(t, []string{"00-trace-span-01", ""}, ctxUI.traces)
+	assert.Equal(t, []interface{}{"value", "untraced"}, ctxUI.values)
+	assert.Equal(t, []interface{}{"value"}, plain.values)
+}
@@ -0,0 +1,205 @@
+package clio
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/boss-net/fangs"
+)
+
+// UnicodeMode determines whether the built-in UI components draw with unicode characters (spinners, box-drawing,
+// ellipsis) or fall back to ASCII equivalents.
+type UnicodeMode string
+
+const (
+	// UnicodeAuto uses unicode when the locale (or windows console code page) supports UTF-8 (the default).
+	UnicodeAuto UnicodeMode = "auto"
+	// UnicodeAlways always uses unicode.
+	UnicodeAlways UnicodeMode = "always"
+	// UnicodeNever always uses ASCII.
+	UnicodeNever UnicodeMode = "never"
+)
+
+var unicodeModes = Enum(UnicodeAuto, UnicodeAlways, UnicodeNever)
+
+type UIConfig struct {
+	Unicode    UnicodeMode `yaml:"unicode" json:"unicode" mapstructure:"unicode"`          // whether to draw with unicode characters (auto, always, never)
+	Accessible bool        `yaml:"accessible" json:"accessible" mapstructure:"accessible"` // screen-reader friendly output (also enabled when a screen reader is detected)
+	RawValues  bool        `yaml:"raw-values" json:"raw-values" mapstructure:"raw-values"` // show exact machine values instead of human-friendly sizes, durations, and numbers
+}
+
+var _ interface {
+	fangs.FieldDescriber
+	EnumFieldsDescriber
+} = (*UIConfig)(nil)
+
+func (c *UIConfig) DescribeFields(set fangs.FieldDescriptionSet) {
+	set.Add(&c.Unicode, "whether to draw with unicode characters, falling back to ASCII when the terminal does not support UTF-8")
+	set.Add(&c.Accessible, "screen-reader friendly output: no animations, line-based status updates, and no color-only signaling")
+	set.Add(&c.RawValues, "show exact machine values (bytes, durations, and plain numbers) instead of human-friendly forms for the locale")
+}
+
+func (c *UIConfig) DescribeEnumFields(set EnumFieldSet) {
+	unicodeModes.Describe(set, &c.Unicode)
+}
+
+// useUnicode indicates the built-in UI components should draw with unicode characters.
+func (c *UIConfig) useUnicode() bool {
+	if c != nil {
+		switch c.Unicode {
+		case UnicodeAlways:
+			return true
+		case UnicodeNever:
+			return false
+		}
+	}
+	if DeterministicMode() {
+		// the terminal encoding is not known in advance, so the output would depend on where it is produced
+		return false
+	}
+	return unicodeSupported(os.Getenv)
+}
+
+// rawValues indicates sizes, durations, and numbers should be shown as exact machine values.
+func (c *UIConfig) rawValues() bool {
+	return c != nil && c.RawValues
+}
+
+// accessible indicates the built-in UI components should produce screen-reader friendly output.
+func (c *UIConfig) accessible() bool {
+	return (c != nil && c.Accessible) || (!DeterministicMode() && screenReaderDetected(os.Getenv))
+}
+
+// screenReaderDetected indicates a screen reader is likely in use, based on common environment hints or, on windows,
+// the system screen reader setting.
+func screenReaderDetected(getenv func(string) string) bool {
+	for _, name := range []string{"ACCESSIBILITY_ENABLED", "SCREEN_READER"} {
+		switch strings.ToLower(getenv(name)) {
+		case "1", "true", "yes", "on":
+			return true
+		}
+	}
+	return systemScreenReader()
+}
+
+// unicodeSupported indicates the terminal encoding is UTF-8, based on the locale (the first of LC_ALL, LC_CTYPE, and
+// LANG which is set) or, on windows, the console output code page.
+func unicodeSupported(getenv func(string) string) bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := getenv(name); locale != "" {
+			locale = strings.ToLower(locale)
+			return strings.Contains(locale, "utf-8") || strings.Contains(locale, "utf8")
+		}
+	}
+	return consoleUnicode()
+}
+
+// Symbols are the characters the built-in UI components draw with.
+type Symbols struct {
+	Ellipsis string
+	Spinner  []string
+
+	// box-drawing
+	Horizontal  string
+	Vertical    string
+	TopLeft     string
+	TopRight    string
+	BottomLeft  string
+	BottomRight string
+	Tee         string // a branch of a tree (e.g. "├")
+	Corner      string // the last branch of a tree (e.g. "└")
+
+	// completion markers, which are words rather than symbols in accessible mode
+	Success string
+	Failure string
+}
+
+var (
+	// UnicodeSymbols are used for terminals which support UTF-8.
+	UnicodeSymbols = Symbols{
+		Ellipsis:    "…",
+		Spinner:     []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"},
+		Horizontal:  "─",
+		Vertical:    "│",
+		TopLeft:     "┌",
+		TopRight:    "┐",
+		BottomLeft:  "└",
+		BottomRight: "┘",
+		Tee:         "├",
+		Corner:      "└",
+		Success:     "✔",
+		Failure:     "✘",
+	}
+
+	// ASCIISymbols are the fallback for terminals which do not support UTF-8.
+	ASCIISymbols = Symbols{
+		Ellipsis:    "...",
+		Spinner:     []string{"|", "/", "-", "\\"},
+		Horizontal:  "-",
+		Vertical:    "|",
+		TopLeft:     "+",
+		TopRight:    "+",
+		BottomLeft:  "+",
+		BottomRight: "+",
+		Tee:         "|",
+		Corner:      "`",
+		Success:     "+",
+		Failure:     "x",
+	}
+
+	// AccessibleSymbols are used in accessible mode (see UIConfig.Accessible): there is no spinner animation and
+	// completion is described in words.
+	AccessibleSymbols = Symbols{
+		Ellipsis:    "...",
+		Horizontal:  "-",
+		Vertical:    "|",
+		TopLeft:     "+",
+		TopRight:    "+",
+		BottomLeft:  "+",
+		BottomRight: "+",
+		Tee:         "|",
+		Corner:      "`",
+		Success:     "done:",
+		Failure:     "failed:",
+	}
+)
+
+var (
+	presentationLock sync.RWMutex
+	activeSymbols    = UnicodeSymbols
+	accessibleMode   bool
+)
+
+// CurrentSymbols returns the characters the built-in UI components draw with, as configured with "ui.unicode" and
+// "ui.accessible".
+func CurrentSymbols() Symbols {
+	presentationLock.RLock()
+	defer presentationLock.RUnlock()
+	return activeSymbols
+}
+
+// AccessibleMode indicates output should be screen-reader friendly (see UIConfig.Accessible).
+func AccessibleMode() bool {
+	presentationLock.RLock()
+	defer presentationLock.RUnlock()
+	return accessibleMode
+}
+
+func setPresentation(unicode, accessible bool) {
+	presentationLock.Lock()
+	defer presentationLock.Unlock()
+	switch {
+	case accessible:
+		activeSymbols = AccessibleSymbols
+	case unicode:
+		activeSymbols = UnicodeSymbols
+	default:
+		activeSymbols = ASCIISymbols
+	}
+	if deterministicMode {
+		// there is no animation in deterministic mode
+		activeSymbols.Spinner = nil
+	}
+	accessibleMode = accessible
+}
@@ -0,0 +1,103 @@
+package clio
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_unicodeSupported(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want bool
+	}{
+		{
+			name: "utf-8 lang",
+			env:  map[string]string{"LANG": "en_US.UTF-8"},
+			want: true,
+		},
+		{
+			name: "utf8 lang",
+			env:  map[string]string{"LANG": "de_DE.utf8"},
+			want: true,
+		},
+		{
+			name: "LC_ALL takes precedence",
+			env:  map[string]string{"LC_ALL": "C", "LANG": "en_US.UTF-8"},
+			want: false,
+		},
+		{
+			name: "LC_CTYPE takes precedence over LANG",
+			env:  map[string]string{"LC_CTYPE": "en_US.UTF-8", "LANG": "POSIX"},
+			want: true,
+		},
+		{
+			name: "non-utf8 locale",
+			env:  map[string]string{"LANG": "en_US.ISO-8859-1"},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, unicodeSupported(func(name string) string {
+				return tt.env[name]
+			}))
+		})
+	}
+}
+
+func Test_UIConfig_useUnicode(t *testing.T) {
+	t.Setenv("LC_ALL", "C")
+	assert.False(t, (&UIConfig{Unicode: UnicodeAuto}).useUnicode())
+	assert.True(t, (&UIConfig{Unicode: UnicodeAlways}).useUnicode())
+
+	t.Setenv("LC_ALL", "en_US.UTF-8")
+	assert.True(t, (&UIConfig{Unicode: UnicodeAuto}).useUnicode())
+	assert.False(t, (&UIConfig{Unicode: UnicodeNever}).useUnicode())
+}
+
+func Test_screenReaderDetected(t *testing.T) {
+	assert.False(t, screenReaderDetected(func(string) string { return "" }))
+	assert.True(t, screenReaderDetected(func(name string) string {
+		if name == "ACCESSIBILITY_ENABLED" {
+			return "1"
+		}
+		return ""
+	}))
+	assert.False(t, screenReaderDetected(func(name string) string {
+		if name == "SCREEN_READER" {
+			return "0"
+		}
+		return ""
+	}))
+}
+
+func Test_Application_uiPresentation(t *testing.T) {
+	defer setPresentation(true, false)
+	t.Setenv("LC_ALL", "en_US.UTF-8")
+
+	run := func(args ...string) {
+		app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus())
+		cmd := app.SetupRootCommand(&cobra.Command{
+			RunE: func(cmd *cobra.Command, args []string) error { return nil },
+		})
+		cmd.SetArgs(args)
+		require.NoError(t, cmd.Execute())
+	}
+
+	run()
+	assert.Equal(t, UnicodeSymbols, CurrentSymbols())
+
+	t.Setenv("APP_UI_UNICODE", "never")
+	run()
+	assert.Equal(t, ASCIISymbols, CurrentSymbols())
+	assert.False(t, AccessibleMode())
+
+	t.Setenv("APP_UI_ACCESSIBLE", "true")
+	run()
+	assert.Equal(t, AccessibleSymbols, CurrentSymbols())
+	assert.True(t, AccessibleMode())
+}
@@ -0,0 +1,80 @@
+package clio
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/wagoodman/go-partybus"
+
+	"github.com/boss-net/go-logger"
+)
+
+var _ UI = (*isolatedUI)(nil)
+
+// isolatedUI recovers panics raised by a UI, logging them with a stack trace, so that a buggy renderer cannot take
+// down the eventloop (and with it the worker). When disableOnPanic is set the UI is torn down after the first panic
+// and receives no further events.
+type isolatedUI struct {
+	UI
+	log            logger.Logger
+	stats          *runStats
+	disableOnPanic bool
+	disabled       bool
+}
+
+func withPanicIsolation(log logger.Logger, disableOnPanic bool, stats *runStats, uis []UI) []UI {
+	var out []UI
+	for _, ui := range uis {
+		out = append(out, &isolatedUI{UI: ui, log: log, stats: stats, disableOnPanic: disableOnPanic})
+	}
+	return out
+}
+
+func (u *isolatedUI) Setup(subscription partybus.Unsubscribable) (err error) {
+	defer u.recover("setup", func(r any) {
+		// a UI that cannot be setup is skipped in favor of the next UI
+		err = fmt.Errorf("UI panicked during setup: %v", r)
+	})
+	return u.UI.Setup(subscription)
+}
+
+func (u *isolatedUI) Handle(e partybus.Event) (err error) {
+	if u.disabled {
+		return nil
+	}
+	u.stats.eventHandled()
+	defer u.recover(fmt.Sprintf("handling %q event", e.Type), func(any) {
+		err = nil
+		if u.disableOnPanic {
+			u.log.Warn("disabling the UI after panic")
+			u.disabled = true
+			u.teardown(true)
+		}
+	})
+	return u.UI.Handle(e)
+}
+
+func (u *isolatedUI) Teardown(force bool) error {
+	if u.disabled {
+		return nil
+	}
+	return u.teardown(force)
+}
+
+func (u *isolatedUI) teardown(force bool) (err error) {
+	defer u.recover("teardown", func(r any) {
+		err = fmt.Errorf("UI panicked during teardown: %v", r)
+	})
+	return u.UI.Teardown(force)
+}
+
+// recover must be deferred: it logs a panic raised while the UI was performing the given action, then calls onPanic.
+func (u *isolatedUI) recover(action string, onPanic func(r any)) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	u.log.Errorf("UI panicked while %s: %v\n%s", action, r, debug.Stack())
+	u.stats.panicked()
+	onPanic(r)
+}
@@ -0,0 +1,95 @@
+package clio
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wagoodman/go-partybus"
+
+	"github.com/boss-net/go-logger/adapter/discard"
+)
+
+type panickingUI struct {
+	handled   []partybus.EventType
+	tornDown  bool
+	panicOn   partybus.EventType
+	setupErr  error
+	panicTear bool
+}
+
+func (p *panickingUI) Setup(partybus.Unsubscribable) error {
+	return p.setupErr
+}
+
+func (p *panickingUI) Handle(e partybus.Event) error {
+	if e.Type == p.panicOn {
+		panic("boom")
+	}
+	p.handled = append(p.handled, e.Type)
+	return nil
+}
+
+func (p *panickingUI) Teardown(bool) error {
+	p.tornDown = true
+	if p.panicTear {
+		panic("teardown boom")
+	}
+	return nil
+}
+
+func Test_isolatedUI_recoversPanics(t *testing.T) {
+	ui := &panickingUI{panicOn: "bad"}
+	isolated := withPanicIsolation(discard.New(), false, nil, []UI{ui})[0]
+
+	require.NoError(t, isolated.Handle(partybus.Event{Type: "first"}))
+	require.NoError(t, isolated.Handle(partybus.Event{Type: "bad"}))
+	require.NoError(t, isolated.Handle(partybus.Event{Type: "second"}))
+
+	assert.Equal(t, []partybus.EventType{"first", "second"}, ui.handled)
+	assert.False(t, ui.tornDown)
+}
+
+func Test_isolatedUI_disableOnPanic(t *testing.T) {
+	ui := &panickingUI{panicOn: "bad"}
+	isolated := withPanicIsolation(discard.New(), true, nil, []UI{ui})[0]
+
+	require.NoError(t, isolated.Handle(partybus.Event{Type: "first"}))
+	require.NoError(t, isolated.Handle(partybus.Event{Type: "bad"}))
+	assert.True(t, ui.tornDown)
+
+	require.NoError(t, isolated.Handle(partybus.Event{Type: "second"}))
+	assert.Equal(t, []partybus.EventType{"first"}, ui.handled)
+
+	// already torn down
+	ui.tornDown = false
+	require.NoError(t, isolated.Teardown(false))
+	assert.False(t, ui.tornDown)
+}
+
+func Test_isolatedUI_teardownPanic(t *testing.T) {
+	isolated := withPanicIsolation(discard.New(), false, nil, []UI{&panickingUI{panicTear: true}})[0]
+	require.EqualError(t, isolated.Teardown(false), "UI panicked during teardown: teardown boom")
+}
+
+func Test_EventLoop_survivesUIPanic(t *testing.T) {
+	bus := partybus.NewBus()
+	t.Cleanup(bus.Close)
+	subscription := bus.Subscribe()
+
+	ui := &panickingUI{panicOn: "bad"}
+	workerErrs := make(chan error)
+	go func() {
+		bus.Publish(partybus.Event{Type: "bad"})
+		bus.Publish(partybus.Event{Type: "good"})
+		_ = subscription.Unsubscribe()
+		workerErrs <- errors.New("worker done")
+		close(workerErrs)
+	}()
+
+	err := eventloop(context.Background(), discard.New(), subscription, workerErrs, withPanicIsolation(discard.New(), false, nil, []UI{ui})...)
+	require.ErrorContains(t, err, "worker done")
+	assert.True(t, ui.tornDown)
+}
@@ -0,0 +1,97 @@
+package clio
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ConfigurableUI is a UI with its own configuration (e.g. refresh rate, verbosity, or theme), which is nested under
+// "ui.<name>" in the application config and loaded along with the rest of the configuration (from config files, env
+// vars such as APP_UI_<NAME>_<FIELD>, etc.) before the UI is set up. UIs given to SetupConfig.WithUI which implement
+// this are registered automatically (see SetupConfig.WithUISection otherwise).
+type ConfigurableUI interface {
+	UI
+	// ConfigSection returns the name of the config section and a pointer to the config struct (holding the defaults),
+	// which is updated in place as the configuration is loaded.
+	ConfigSection() (name string, cfg any)
+}
+
+// uiSectionsConfig returns the configuration holding the UI config sections (nil when there are none), which is
+// loaded with the core configuration so that it is ready before the UIs are set up.
+func (a *application) uiSectionsConfig() (any, error) {
+	if a.uiSections != nil {
+		return a.uiSections, nil
+	}
+	for name, cfg := range a.setupConfig.UISections {
+		if err := validUISection(name, cfg); err != nil {
+			return nil, err
+		}
+	}
+	a.uiSections = newUISectionsConfig(a.configTagName(), a.setupConfig.UISections)
+	return a.uiSections, nil
+}
+
+// newUISectionsConfig builds the configuration holding each UI config section (see ConfigurableUI), which is a struct
+// with a single "ui" field (alongside the core UIConfig) holding a field for each section, sorted by name. This is nil
+// when no sections are registered.
+func newUISectionsConfig(tagName string, sections map[string]any) any {
+	var names []string
+	for name := range sections {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+
+	fields := make([]reflect.StructField, len(names))
+	for i, name := range names {
+		fields[i] = reflect.StructField{
+			Name: fmt.Sprintf("Section%d", i),
+			Type: reflect.TypeOf(sections[name]),
+			Tag:  uiSectionTag(tagName, name),
+		}
+	}
+	inner := reflect.StructOf(fields)
+	outer := reflect.StructOf([]reflect.StructField{{
+		Name: "UI",
+		Type: reflect.PtrTo(inner),
+		Tag:  uiSectionTag(tagName, "ui"),
+	}})
+
+	value := reflect.New(inner)
+	for i, name := range names {
+		value.Elem().Field(i).Set(reflect.ValueOf(sections[name]))
+	}
+	cfg := reflect.New(outer)
+	cfg.Elem().Field(0).Set(value)
+	return cfg.Interface()
+}
+
+// uiSectionTag is the struct tag naming a field for yaml, json, and the tag used by fangs for loading configuration.
+func uiSectionTag(tagName, name string) reflect.StructTag {
+	tags := []string{"yaml", "json", "mapstructure"}
+	if tagName != "" && !contains(tags, tagName) {
+		tags = append(tags, tagName)
+	}
+	var parts []string
+	for _, tag := range tags {
+		parts = append(parts, fmt.Sprintf("%s:%q", tag, name))
+	}
+	return reflect.StructTag(strings.Join(parts, " "))
+}
+
+// validUISection checks that a UI config section can be loaded: the name must be a single config key and the config
+// a pointer to a struct.
+func validUISection(name string, cfg any) error {
+	if name == "" || strings.ContainsAny(name, ". ") {
+		return fmt.Errorf("invalid UI config section name %q", name)
+	}
+	t := reflect.TypeOf(cfg)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct || reflect.ValueOf(cfg).IsNil() {
+		return fmt.Errorf("UI config section %q must be a pointer to a struct, got %T", name, cfg)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package clio
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type progressUIConfig struct {
+	RefreshRate int    `yaml:"refresh-rate" json:"refresh-rate" mapstructure:"refresh-rate"`
+	Theme       string `yaml:"theme" json:"theme" mapstructure:"theme"`
+}
+
+var _ ConfigurableUI = (*progressUI)(nil)
+
+type progressUI struct {
+	mockUI
+	cfg progressUIConfig
+}
+
+func (u *progressUI) ConfigSection() (string, any) {
+	return "progress", &u.cfg
+}
+
+func Test_Application_uiSections(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("ui:\n  unicode: never\n  progress:\n    refresh-rate: 5\n"), 0o600))
+	t.Setenv("APP_UI_PROGRESS_THEME", "dark")
+
+	ui := &progressUI{cfg: progressUIConfig{RefreshRate: 10, Theme: "light"}}
+	setup := NewSetupConfig(Identification{Name: "app"}).
+		WithStrictConfig().
+		WithUI(ui)
+	setup.FangsConfig.File = file
+
+	app := New(*setup)
+	root := app.SetupRootCommand(&cobra.Command{
+		Run: func(cmd *cobra.Command, args []string) {},
+	})
+	root.SetArgs([]string{})
+	require.NoError(t, root.Execute())
+
+	assert.Equal(t, progressUIConfig{RefreshRate: 5, Theme: "dark"}, ui.cfg)
+	assert.Equal(t, UnicodeNever, app.(*application).State().Config.UI.Unicode)
+
+	// the sections are shown with the core UI config
+	formatted := formatConfiguration(app.(*application).loadedConfigs...)
+	assert.Equal(t, 1, strings.Count(formatted, "\nui:\n")+strings.Count(formatted[:3], "ui:"))
+	assert.Contains(t, formatted, "    progress:\n        refresh-rate: 5\n        theme: dark\n")
+
+	assert.Contains(t, app.(*application).summarizeConfig(root), "# (env: APP_UI_PROGRESS_REFRESH_RATE)")
+}
+
+func Test_Application_uiSections_constructor(t *testing.T) {
+	t.Setenv("APP_UI_PROGRESS_REFRESH_RATE", "2")
+
+	cfg := &progressUIConfig{RefreshRate: 10}
+	setup := NewSetupConfig(Identification{Name: "app"}).
+		WithNoBus().
+		WithUIConstructor(func(Config) ([]UI, error) {
+			return []UI{&mockUI{}}, nil
+		}).
+		WithUISection("progress", cfg)
+
+	app := New(*setup)
+	root := app.SetupRootCommand(&cobra.Command{
+		RunE: func(cmd *cobra.Command, args []string) error { return nil },
+	})
+	root.SetArgs([]string{})
+	require.NoError(t, root.Execute())
+
+	assert.Equal(t, 2, cfg.RefreshRate)
+}
+
+func Test_Application_uiSections_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		section string
+		cfg     any
+		wantErr string
+	}{
+		{name: "not a pointer", section: "progress", cfg: progressUIConfig{}, wantErr: "must be a pointer to a struct"},
+		{name: "nil", section: "progress", cfg: (*progressUIConfig)(nil), wantErr: "must be a pointer to a struct"},
+		{name: "nested name", section: "progress.bar", cfg: &progressUIConfig{}, wantErr: "invalid UI config section name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup := NewSetupConfig(Identification{Name: "app"}).
+				WithNoBus().
+				WithUISection(tt.section, tt.cfg)
+
+			app := New(*setup)
+			root := app.SetupRootCommand(&cobra.Command{
+				RunE: func(cmd *cobra.Command, args []string) error { return nil },
+			})
+			root.SetArgs([]string{})
+			require.ErrorContains(t, root.Execute(), tt.wantErr)
+		})
+	}
+}
+
+func Test_formatConfiguration_sharedSections(t *testing.T) {
+	type core struct {
+		UI  map[string]string `yaml:"ui"`
+		Log string            `yaml:"log"`
+	}
+	type extra struct {
+		UI map[string]string `yaml:"ui"`
+	}
+	type other struct {
+		Name string `yaml:"name"`
+	}
+
+	got := formatConfiguration(
+		&core{UI: map[string]string{"unicode": "auto"}, Log: "info"},
+		&extra{UI: map[string]string{"theme": "dark"}},
+		&other{Name: "app"},
+	)
+	assert.Equal(t, "ui:\n    unicode: auto\n    theme: dark\nlog: info\nname: app\n", got)
+}
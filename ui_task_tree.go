@@ -0,0 +1,189 @@
+package clio
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/wagoodman/go-partybus"
+)
+
+// TaskTree is the state of all stages (see State.StartStage), built from StageEvents, for rendering progress.
+type TaskTree struct {
+	stages   map[int64]*StageUpdate
+	children map[int64][]int64 // by parent ID (0 for top-level stages), in the order started
+}
+
+// NewTaskTree returns an empty task tree.
+func NewTaskTree() *TaskTree {
+	return &TaskTree{
+		stages:   map[int64]*StageUpdate{},
+		children: map[int64][]int64{},
+	}
+}
+
+// Apply updates the tree with the stage update (typically the value of a StageEvent).
+func (t *TaskTree) Apply(u StageUpdate) {
+	if existing, ok := t.stages[u.ID]; ok {
+		*existing = u
+		return
+	}
+	t.stages[u.ID] = &u
+	t.children[u.Parent] = append(t.children[u.Parent], u.ID)
+}
+
+// Render writes the tree with one stage per line, children nested beneath their parent.
+func (t *TaskTree) Render(w io.Writer, symbols Symbols) {
+	t.render(w, symbols, 0, "")
+}
+
+func (t *TaskTree) render(w io.Writer, symbols Symbols, parent int64, indent string) {
+	ids := t.children[parent]
+	for i, id := range ids {
+		branch, childIndent := "", ""
+		if parent != 0 {
+			last := i == len(ids)-1
+			branch, childIndent = symbols.Tee+symbols.Horizontal+" ", symbols.Vertical+"  "
+			if last {
+				branch, childIndent = symbols.Corner+symbols.Horizontal+" ", "   "
+			}
+		}
+		_, _ = fmt.Fprintf(w, "%s%s%s\n", indent, branch, describeStage(*t.stages[id], symbols))
+		t.render(w, symbols, id, indent+childIndent)
+	}
+}
+
+// Summary describes the outcome of all top-level stages (e.g. "3 stages: 2 succeeded, 1 failed (4.2s)").
+func (t *TaskTree) Summary() string {
+	counts := map[StageStatus]int{}
+	var total time.Duration
+	roots := t.children[0]
+	for _, id := range roots {
+		s := t.stages[id]
+		counts[s.Status]++
+		total += s.Duration
+	}
+
+	noun := "stages"
+	if len(roots) == 1 {
+		noun = "stage"
+	}
+	var parts []string
+	for _, status := range []StageStatus{StageSucceeded, StageFailed, StageSkipped, StageRunning} {
+		if counts[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+	return fmt.Sprintf("%d %s: %s (%s)", len(roots), noun, strings.Join(parts, ", "), HumanDuration(total))
+}
+
+// path returns the names of the stage and all its ancestors (e.g. "build > compile").
+func (t *TaskTree) path(u StageUpdate) string {
+	names := []string{u.Name}
+	for parent := t.stages[u.Parent]; parent != nil; parent = t.stages[parent.Parent] {
+		names = append([]string{parent.Name}, names...)
+	}
+	return strings.Join(names, " > ")
+}
+
+func describeStage(u StageUpdate, symbols Symbols) string {
+	switch u.Status {
+	case StageSucceeded:
+		return fmt.Sprintf("%s %s (%s)", color.Green.Sprint(symbols.Success), u.Name, HumanDuration(u.Duration))
+	case StageFailed:
+		return fmt.Sprintf("%s %s (%s): %s", color.Red.Sprint(symbols.Failure), u.Name, HumanDuration(u.Duration), u.Error)
+	case StageSkipped:
+		return fmt.Sprintf("- %s (skipped)", u.Name)
+	}
+	return fmt.Sprintf("%s %s", symbols.Ellipsis, u.Name)
+}
+
+var _ UI = (*taskTreeUI)(nil)
+
+// taskTreeUI renders stages as a nested tree, redrawn in place on a terminal, followed by a summary.
+type taskTreeUI struct {
+	lock      sync.Mutex
+	w         io.Writer
+	symbols   Symbols
+	lineBased bool
+	annotate  bool // write the summary as a GitHub Actions notice annotation
+	tree      *TaskTree
+	lines     int // the number of lines drawn by the last redraw
+}
+
+// NewTaskTreeUI returns a UI rendering the progress of all stages (see State.StartStage) as a live, nested tree with
+// a summary once the run is complete. In accessible mode (see UIConfig.Accessible), or when the writer is not a
+// terminal, each stage is written as a line when it starts and when it finishes instead. When running under GitHub
+// Actions, the summary is written as a notice annotation.
+func NewTaskTreeUI(w io.Writer) UI {
+	return &taskTreeUI{
+		w:         w,
+		symbols:   CurrentSymbols(),
+		lineBased: AccessibleMode() || !isTerminal(w),
+		annotate:  ciProvider() == LogFormatGitHubActions,
+		tree:      NewTaskTree(),
+	}
+}
+
+func (u *taskTreeUI) Setup(partybus.Unsubscribable) error {
+	return nil
+}
+
+func (u *taskTreeUI) Handle(e partybus.Event) error {
+	update, ok := e.Value.(StageUpdate)
+	if e.Type != StageEvent || !ok {
+		return nil
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	u.tree.Apply(update)
+	if u.lineBased {
+		path := u.tree.path(update)
+		if !update.Finished() {
+			_, _ = fmt.Fprintf(u.w, "started: %s\n", path)
+			return nil
+		}
+		update.Name = path
+		_, _ = fmt.Fprintln(u.w, describeStage(update, u.symbols))
+		return nil
+	}
+	u.redraw()
+	return nil
+}
+
+func (u *taskTreeUI) Teardown(bool) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if len(u.tree.children[0]) == 0 {
+		return nil
+	}
+	if !u.lineBased {
+		u.redraw()
+	}
+	if u.annotate {
+		_, _ = io.WriteString(u.w, githubCommand("notice", map[string]string{"title": "stages"}, u.tree.Summary()))
+		return nil
+	}
+	_, _ = fmt.Fprintln(u.w, u.tree.Summary())
+	return nil
+}
+
+// redraw replaces the previously drawn tree.
+func (u *taskTreeUI) redraw() {
+	var sb strings.Builder
+	u.tree.Render(&sb, u.symbols)
+	out := sb.String()
+
+	if u.lines > 0 {
+		// move to the start of the previous tree and clear everything below
+		_, _ = fmt.Fprintf(u.w, "\x1b[%dA\r\x1b[J", u.lines)
+	}
+	_, _ = io.WriteString(u.w, out)
+	u.lines = strings.Count(out, "\n")
+}
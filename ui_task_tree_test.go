@@ -0,0 +1,102 @@
+package clio
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wagoodman/go-partybus"
+)
+
+func testTaskTree() *TaskTree {
+	tree := NewTaskTree()
+	tree.Apply(StageUpdate{ID: 1, Name: "build", Status: StageRunning})
+	tree.Apply(StageUpdate{ID: 2, Parent: 1, Name: "compile", Status: StageSucceeded, Duration: 1200 * time.Millisecond})
+	tree.Apply(StageUpdate{ID: 3, Parent: 2, Name: "generate", Status: StageSkipped})
+	tree.Apply(StageUpdate{ID: 4, Parent: 1, Name: "link", Status: StageFailed, Duration: time.Second, Error: "missing symbol"})
+	tree.Apply(StageUpdate{ID: 5, Name: "publish", Status: StageRunning})
+	return tree
+}
+
+func TestTaskTree_Render(t *testing.T) {
+	defer func(enabled bool) { color.Enable = enabled }(color.Enable)
+	color.Enable = false
+
+	var buf bytes.Buffer
+	testTaskTree().Render(&buf, ASCIISymbols)
+	assert.Equal(t, `... build
+|- + compile (1.2s)
+|  `+"`"+`- - generate (skipped)
+`+"`"+`- x link (1s): missing symbol
+... publish
+`, buf.String())
+}
+
+func TestTaskTree_Summary(t *testing.T) {
+	tree := testTaskTree()
+	tree.Apply(StageUpdate{ID: 1, Name: "build", Status: StageFailed, Duration: 2 * time.Second})
+	assert.Equal(t, "2 stages: 1 failed, 1 running (2s)", tree.Summary())
+
+	tree.Apply(StageUpdate{ID: 5, Name: "publish", Status: StageSucceeded, Duration: 500 * time.Millisecond})
+	assert.Equal(t, "2 stages: 1 succeeded, 1 failed (2.5s)", tree.Summary())
+}
+
+func Test_taskTreeUI_lineBased(t *testing.T) {
+	defer func(enabled bool) { color.Enable = enabled }(color.Enable)
+	color.Enable = false
+	defer setPresentation(true, false)
+	setPresentation(true, true)
+
+	var buf bytes.Buffer
+	ui := NewTaskTreeUI(&buf)
+	require.NoError(t, ui.Setup(nil))
+
+	for _, u := range []StageUpdate{
+		{ID: 1, Name: "build", Status: StageRunning},
+		{ID: 2, Parent: 1, Name: "compile", Status: StageRunning},
+		{ID: 2, Parent: 1, Name: "compile", Status: StageSucceeded, Duration: time.Second},
+		{ID: 1, Name: "build", Status: StageSucceeded, Duration: time.Second},
+	} {
+		require.NoError(t, ui.Handle(partybus.Event{Type: StageEvent, Value: u}))
+	}
+	require.NoError(t, ui.Handle(partybus.Event{Type: "other"}))
+	require.NoError(t, ui.Teardown(false))
+
+	assert.Equal(t, `started: build
+started: build > compile
+done: build > compile (1s)
+done: build (1s)
+1 stage: 1 succeeded (1s)
+`, buf.String())
+}
+
+func Test_taskTreeUI_githubActions(t *testing.T) {
+	original := ciProvider
+	ciProvider = func() string { return LogFormatGitHubActions }
+	defer func() { ciProvider = original }()
+
+	var buf bytes.Buffer
+	ui := NewTaskTreeUI(&buf)
+	require.NoError(t, ui.Handle(partybus.Event{Type: StageEvent, Value: StageUpdate{ID: 1, Name: "build", Status: StageSucceeded, Duration: time.Second}}))
+	buf.Reset()
+	require.NoError(t, ui.Teardown(false))
+
+	assert.Equal(t, "::notice title=stages::1 stage: 1 succeeded (1s)\n", buf.String())
+}
+
+func Test_taskTreeUI_redraw(t *testing.T) {
+	defer func(enabled bool) { color.Enable = enabled }(color.Enable)
+	color.Enable = false
+
+	var buf bytes.Buffer
+	ui := &taskTreeUI{w: &buf, symbols: ASCIISymbols, tree: NewTaskTree()}
+
+	require.NoError(t, ui.Handle(partybus.Event{Type: StageEvent, Value: StageUpdate{ID: 1, Name: "build", Status: StageRunning}}))
+	require.NoError(t, ui.Handle(partybus.Event{Type: StageEvent, Value: StageUpdate{ID: 2, Parent: 1, Name: "compile", Status: StageRunning}}))
+
+	assert.Equal(t, "... build\n"+
+		"\x1b[1A\r\x1b[J... build\n`- ... compile\n", buf.String())
+}
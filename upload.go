@@ -0,0 +1,279 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wagoodman/go-partybus"
+)
+
+// UploadEvent is published on the bus as an upload (see State.Upload) progresses, with an UploadProgress as the event
+// value.
+const UploadEvent partybus.EventType = "clio-upload"
+
+// UploadProgress is a snapshot of an upload, published as the value of each UploadEvent.
+type UploadProgress struct {
+	Destination string `json:"destination"` // without credentials or query parameters
+	Written     int64  `json:"written"`
+	Size        int64  `json:"size"`
+	Done        bool   `json:"done"`
+	Error       string `json:"error,omitempty"` // set when done and failed (redacted)
+}
+
+// UploadDestination uploads contents to the destination URL, e.g. to a storage service. Destinations are registered
+// by URL scheme (see SetupConfig.WithUploadDestination).
+type UploadDestination interface {
+	Upload(ctx context.Context, s *State, dest *url.URL, body io.Reader, size int64) error
+}
+
+// UploadFunc is an adapter to allow the use of ordinary functions as an UploadDestination.
+type UploadFunc func(ctx context.Context, s *State, dest *url.URL, body io.Reader, size int64) error
+
+func (f UploadFunc) Upload(ctx context.Context, s *State, dest *url.URL, body io.Reader, size int64) error {
+	return f(ctx, s, dest, body, size)
+}
+
+// uploadProgressInterval is how many bytes are uploaded between progress events.
+const uploadProgressInterval = 256 << 10
+
+// Upload writes the contents to the destination, which is either a local path (see ResolvePath) or the URL of a
+// registered upload destination (see SetupConfig.WithUploadDestination). Built in are:
+//
+//   - s3://bucket/key, with credentials from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN, in the
+//     region from AWS_REGION (or AWS_DEFAULT_REGION), or at the endpoint from AWS_ENDPOINT_URL_S3 (or AWS_ENDPOINT_URL)
+//   - gs://bucket/object, with the access token from GOOGLE_OAUTH_ACCESS_TOKEN, or else one exchanged for the
+//     application default credentials (a service account key or user credentials) at GOOGLE_APPLICATION_CREDENTIALS
+//     or where "gcloud auth application-default login" writes them (credentials from the metadata server of GCP
+//     compute are not supported, set GOOGLE_OAUTH_ACCESS_TOKEN instead)
+//   - azblob://account/container/blob (or https://account.blob.core.windows.net/container/blob), with a SAS token
+//     within the URL or from AZURE_STORAGE_SAS_TOKEN
+//   - any other http(s) URL with a PUT request, with the credentials within the URL or the bearer token from
+//     <APP>_UPLOAD_TOKEN
+//
+// Credentials are read from the environment, or else from the credential store of the application (see
+// SetupConfig.WithCredentialStore) under the same name, and are redacted. Uploads publish UploadEvents as they progress.
+func (s *State) Upload(ctx context.Context, destination string, contents []byte) error {
+	dest, err := url.Parse(destination)
+	if err != nil || len(dest.Scheme) <= 1 {
+		// a path (a single letter scheme is a windows drive)
+		return s.writeFileAtomic(s.ResolvePath(destination), contents)
+	}
+	if dest.Scheme == "file" {
+		return s.writeFileAtomic(s.ResolvePath(filepath.FromSlash(dest.Path)), contents)
+	}
+
+	uploader, ok := s.uploadDestination(dest)
+	if !ok {
+		return fmt.Errorf("unable to upload to %s: unsupported destination (supported: [%s])", displayURL(dest), strings.Join(s.uploadSchemes(), ", "))
+	}
+
+	progress := &uploadProgress{state: s, ctx: ctx, update: UploadProgress{Destination: displayURL(dest), Size: int64(len(contents))}}
+	progress.publish()
+	err = uploader.Upload(ctx, s, dest, &progressReader{r: bytes.NewReader(contents), progress: progress}, int64(len(contents)))
+	if err != nil {
+		err = fmt.Errorf("unable to upload to %s: %w", displayURL(dest), err)
+	}
+	progress.finish(err)
+	return err
+}
+
+func (s *State) uploadDestination(dest *url.URL) (UploadDestination, bool) {
+	scheme := strings.ToLower(dest.Scheme)
+	if d, ok := s.uploads[scheme]; ok {
+		return d, true
+	}
+	if (scheme == "https" || scheme == "http") && strings.HasSuffix(strings.ToLower(dest.Hostname()), azureBlobHostSuffix) {
+		return azureBlobDestination, true
+	}
+	d, ok := defaultUploadDestinations[scheme]
+	return d, ok
+}
+
+func (s *State) uploadSchemes() []string {
+	var schemes []string
+	for scheme := range defaultUploadDestinations {
+		schemes = append(schemes, scheme)
+	}
+	for scheme := range s.uploads {
+		if _, ok := defaultUploadDestinations[scheme]; !ok {
+			schemes = append(schemes, scheme)
+		}
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// writeFileAtomic writes the contents to a temporary file beside the path first (following the configured permissions
+// policy) so that a failed write never leaves a partial file.
+func (s *State) writeFileAtomic(path string, contents []byte) error {
+	if dir := filepath.Dir(path); dir != "" {
+		_, dirMode := s.currentConfig().Permissions.modes()
+		if err := mkdirAll(dir, dirMode); err != nil {
+			return fmt.Errorf("unable to write %s: %w", path, err)
+		}
+	}
+	tmp, err := s.CreateFile(filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp"))
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	_, err = tmp.Write(contents)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// uploadSecret returns the named credential from the environment or the credential store (or "" when there is none),
+// adding it to the redact store.
+func (s *State) uploadSecret(name string) string {
+	value := os.Getenv(name)
+	if value == "" && s.credentials != nil {
+		value, _ = s.credentials.Get(name)
+	}
+	if value != "" && s.RedactStore != nil {
+		s.RedactStore.Add(value)
+	}
+	return value
+}
+
+// displayURL returns the URL without credentials or query parameters (e.g. SAS tokens), to show to the user.
+func displayURL(u *url.URL) string {
+	shown := *u
+	shown.User = nil
+	shown.RawQuery = ""
+	shown.Fragment = ""
+	return shown.String()
+}
+
+// uploadProgress publishes the progress of an upload.
+type uploadProgress struct {
+	state *State
+	ctx   context.Context
+
+	lock      sync.Mutex
+	update    UploadProgress
+	published int64
+}
+
+func (p *uploadProgress) add(n int) {
+	p.lock.Lock()
+	p.update.Written += int64(n)
+	due := p.update.Written-p.published >= uploadProgressInterval
+	p.lock.Unlock()
+	if due {
+		p.publish()
+	}
+}
+
+func (p *uploadProgress) finish(err error) {
+	p.lock.Lock()
+	p.update.Done = true
+	if err != nil {
+		p.update.Error = err.Error()
+		if p.state.RedactStore != nil {
+			p.update.Error = p.state.RedactStore.RedactString(p.update.Error)
+		}
+	}
+	p.lock.Unlock()
+	p.publish()
+}
+
+func (p *uploadProgress) publish() {
+	p.lock.Lock()
+	update := p.update
+	p.published = update.Written
+	p.lock.Unlock()
+	p.state.Publish(p.ctx, partybus.Event{Type: UploadEvent, Value: update})
+}
+
+// progressReader reports the bytes read from it as upload progress.
+type progressReader struct {
+	r        io.Reader
+	progress *uploadProgress
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.progress.add(n)
+	}
+	return n, err
+}
+
+// putDestination uploads with an http PUT request to the target of the destination (sent with the client from
+// State.HTTPClient, so that the network policy is enforced), after the request is prepared (e.g. with credentials).
+type putDestination struct {
+	target  func(s *State, dest *url.URL) (*url.URL, error)
+	prepare func(s *State, req *http.Request, dest *url.URL) error
+}
+
+func (d putDestination) Upload(ctx context.Context, s *State, dest *url.URL, body io.Reader, size int64) error {
+	target := dest
+	if d.target != nil {
+		var err error
+		if target, err = d.target(s, dest); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target.String(), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", uploadContentType(dest.Path))
+	if d.prepare != nil {
+		if err := d.prepare(s, req, dest); err != nil {
+			return err
+		}
+	}
+
+	resp, err := s.HTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if msg := strings.TrimSpace(string(detail)); msg != "" {
+			return fmt.Errorf("%s: %s", resp.Status, msg)
+		}
+		return errors.New(resp.Status)
+	}
+	return nil
+}
+
+// uploadContentType guesses the content type of a report from its name.
+func uploadContentType(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".sarif":
+		return "application/json"
+	case ".yaml", ".yml":
+		return "application/yaml"
+	case ".xml":
+		return "application/xml"
+	case ".html":
+		return "text/html; charset=utf-8"
+	case ".csv":
+		return "text/csv; charset=utf-8"
+	case ".md":
+		return "text/markdown; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
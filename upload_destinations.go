@@ -0,0 +1,450 @@
+package clio
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	azureBlobHostSuffix = ".blob.core.windows.net"
+	azureStorageVersion = "2021-08-06"
+	// s3UnsignedPayload is signed in place of the hash of the body, which S3 accepts over https.
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// gcsScope is the scope of the access tokens requested for uploads to GCS.
+	gcsScope       = "https://www.googleapis.com/auth/devstorage.read_write"
+	googleTokenURL = "https://oauth2.googleapis.com/token"
+)
+
+var defaultUploadDestinations = map[string]UploadDestination{
+	"http":   httpPutDestination,
+	"https":  httpPutDestination,
+	"s3":     s3Destination,
+	"gs":     gcsDestination,
+	"azblob": azureBlobDestination,
+}
+
+// httpPutDestination uploads to any http(s) URL, authenticating with the user within the URL (as basic auth) or the
+// bearer token from <APP>_UPLOAD_TOKEN.
+var httpPutDestination = putDestination{
+	target: func(_ *State, dest *url.URL) (*url.URL, error) {
+		target := *dest
+		target.User = nil
+		return &target, nil
+	},
+	prepare: func(s *State, req *http.Request, dest *url.URL) error {
+		if dest.User != nil {
+			password, _ := dest.User.Password()
+			if password != "" && s.RedactStore != nil {
+				s.RedactStore.Add(password)
+			}
+			req.SetBasicAuth(dest.User.Username(), password)
+			return nil
+		}
+		if token := s.uploadSecret(envVarName(s.id.Name, []string{"upload", "token"})); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return nil
+	},
+}
+
+// gcsDestination uploads gs://bucket/object URLs with the XML API of Google Cloud Storage (or the emulator at
+// STORAGE_EMULATOR_HOST), authenticating with an access token (see gcsAccessToken).
+var gcsDestination = putDestination{
+	target: func(_ *State, dest *url.URL) (*url.URL, error) {
+		if dest.Host == "" || strings.Trim(dest.Path, "/") == "" {
+			return nil, fmt.Errorf("expected gs://bucket/object")
+		}
+		target := &url.URL{Scheme: "https", Host: "storage.googleapis.com", Path: "/" + dest.Host + dest.Path}
+		if emulator := os.Getenv("STORAGE_EMULATOR_HOST"); emulator != "" {
+			if u, err := url.Parse(emulator); err == nil && u.Host != "" {
+				target.Scheme, target.Host = u.Scheme, u.Host
+			} else {
+				target.Scheme, target.Host = "http", emulator
+			}
+		}
+		return target, nil
+	},
+	prepare: func(s *State, req *http.Request, _ *url.URL) error {
+		token, err := gcsAccessToken(req.Context(), s)
+		if err != nil {
+			return err
+		}
+		if token == "" {
+			if os.Getenv("STORAGE_EMULATOR_HOST") != "" {
+				// the emulator does not authenticate requests
+				return nil
+			}
+			return fmt.Errorf("no GCS credentials (set GOOGLE_OAUTH_ACCESS_TOKEN or GOOGLE_APPLICATION_CREDENTIALS, or run \"gcloud auth application-default login\")")
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	},
+}
+
+// azureBlobDestination uploads block blobs to Azure storage, from azblob://account/container/blob URLs or the https
+// URL of the blob itself.
+var azureBlobDestination = putDestination{
+	target: func(s *State, dest *url.URL) (*url.URL, error) {
+		target := *dest
+		if strings.EqualFold(dest.Scheme, "azblob") {
+			if dest.Host == "" || strings.Count(strings.Trim(dest.Path, "/"), "/") < 1 {
+				return nil, fmt.Errorf("expected azblob://account/container/blob")
+			}
+			target.Scheme, target.Host = "https", dest.Host+azureBlobHostSuffix
+		}
+		if target.RawQuery == "" {
+			target.RawQuery = strings.TrimPrefix(s.uploadSecret("AZURE_STORAGE_SAS_TOKEN"), "?")
+		} else if s.RedactStore != nil {
+			s.RedactStore.Add(target.RawQuery)
+		}
+		return &target, nil
+	},
+	prepare: func(_ *State, req *http.Request, _ *url.URL) error {
+		req.Header.Set("x-ms-blob-type", "BlockBlob")
+		req.Header.Set("x-ms-version", azureStorageVersion)
+		return nil
+	},
+}
+
+// googleCredentials are application default credentials (see
+// https://cloud.google.com/docs/authentication/application-default-credentials), either the key of a service account or
+// the credentials of a user (as written by "gcloud auth application-default login").
+type googleCredentials struct {
+	Type string `json:"type"`
+
+	// service accounts
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+
+	// users
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// gcsAccessToken returns the access token from GOOGLE_OAUTH_ACCESS_TOKEN, or else exchanges the application default
+// credentials (see googleCredentialsPath) for one, or returns "" when there are no credentials.
+func gcsAccessToken(ctx context.Context, s *State) (string, error) {
+	if token := s.uploadSecret("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+	path := googleCredentialsPath()
+	if path == "" {
+		return "", nil
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read GCS credentials: %w", err)
+	}
+	var creds googleCredentials
+	if err := json.Unmarshal(contents, &creds); err != nil {
+		return "", fmt.Errorf("unable to read GCS credentials from %s: %w", path, err)
+	}
+	if s.RedactStore != nil {
+		for _, secret := range []string{creds.PrivateKey, creds.ClientSecret, creds.RefreshToken} {
+			if secret != "" {
+				s.RedactStore.Add(secret)
+			}
+		}
+	}
+
+	form := url.Values{}
+	switch creds.Type {
+	case "service_account":
+		assertion, err := googleJWT(creds, time.Now())
+		if err != nil {
+			return "", fmt.Errorf("unable to use GCS credentials from %s: %w", path, err)
+		}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+	case "authorized_user":
+		form.Set("grant_type", "refresh_token")
+		form.Set("client_id", creds.ClientID)
+		form.Set("client_secret", creds.ClientSecret)
+		form.Set("refresh_token", creds.RefreshToken)
+	default:
+		return "", fmt.Errorf("unsupported GCS credentials from %s: type %q (expected a service account key or user credentials)", path, creds.Type)
+	}
+	token, err := googleToken(ctx, s, creds.tokenURI(), form)
+	if err != nil {
+		return "", fmt.Errorf("unable to get a GCS access token: %w", err)
+	}
+	return token, nil
+}
+
+func (c googleCredentials) tokenURI() string {
+	if c.TokenURI != "" {
+		return c.TokenURI
+	}
+	return googleTokenURL
+}
+
+// googleCredentialsPath returns the path of the application default credentials, from GOOGLE_APPLICATION_CREDENTIALS or
+// else where gcloud writes them (when there are any), or "" when there are none.
+func googleCredentialsPath() string {
+	if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+		return path
+	}
+	dir := os.Getenv("CLOUDSDK_CONFIG")
+	if dir == "" {
+		if runtime.GOOS == "windows" {
+			dir = filepath.Join(os.Getenv("APPDATA"), "gcloud")
+		} else if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config", "gcloud")
+		} else {
+			return ""
+		}
+	}
+	path := filepath.Join(dir, "application_default_credentials.json")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// googleJWT returns the assertion of the service account, signed with its key, to exchange for an access token (see
+// https://developers.google.com/identity/protocols/oauth2/service-account#authorizingrequests).
+func googleJWT(creds googleCredentials, t time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return "", errors.New("no private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return "", fmt.Errorf("invalid private key: %w", err)
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("the private key is not an RSA key")
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": creds.PrivateKeyID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   creds.ClientEmail,
+		"scope": gcsScope,
+		"aud":   creds.tokenURI(),
+		"iat":   t.Unix(),
+		"exp":   t.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// googleToken requests an access token from the token endpoint (sent with the client from State.HTTPClient).
+func googleToken(ctx context.Context, s *State, tokenURI string, form url.Values) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.HTTPClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 300 {
+		if msg := strings.TrimSpace(string(body)); msg != "" {
+			return "", fmt.Errorf("%s: %s", resp.Status, msg)
+		}
+		return "", errors.New(resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("no access token in the response")
+	}
+	if s.RedactStore != nil {
+		s.RedactStore.Add(token.AccessToken)
+	}
+	return token.AccessToken, nil
+}
+
+// s3Destination uploads s3://bucket/key URLs, signing requests with AWS signature version 4.
+var s3Destination = putDestination{
+	target: func(_ *State, dest *url.URL) (*url.URL, error) {
+		bucket, key := dest.Host, strings.TrimPrefix(dest.Path, "/")
+		if bucket == "" || key == "" {
+			return nil, fmt.Errorf("expected s3://bucket/key")
+		}
+		endpoint := os.Getenv("AWS_ENDPOINT_URL_S3")
+		if endpoint == "" {
+			endpoint = os.Getenv("AWS_ENDPOINT_URL")
+		}
+		if endpoint != "" {
+			// custom endpoints (e.g. S3 compatible storage) use path-style URLs
+			u, err := url.Parse(endpoint)
+			if err != nil || u.Host == "" {
+				return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+			}
+			u.Path = strings.TrimSuffix(u.Path, "/") + "/" + bucket + "/" + key
+			u.RawPath = awsURIEscape(u.Path)
+			return u, nil
+		}
+		// the path is sent encoded as it is signed
+		path := "/" + key
+		return &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", bucket, s3Region()), Path: path, RawPath: awsURIEscape(path)}, nil
+	},
+	prepare: func(s *State, req *http.Request, _ *url.URL) error {
+		accessKey := s.uploadSecret("AWS_ACCESS_KEY_ID")
+		secretKey := s.uploadSecret("AWS_SECRET_ACCESS_KEY")
+		if accessKey == "" || secretKey == "" {
+			return fmt.Errorf("no AWS credentials (set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)")
+		}
+		if token := s.uploadSecret("AWS_SESSION_TOKEN"); token != "" {
+			req.Header.Set("x-amz-security-token", token)
+		}
+		req.Header.Set("x-amz-content-sha256", s3UnsignedPayload)
+		signAWSv4(req, accessKey, secretKey, s3Region(), "s3", s3UnsignedPayload, time.Now())
+		return nil
+	},
+}
+
+func s3Region() string {
+	for _, name := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(name); region != "" {
+			return region
+		}
+	}
+	return "us-east-1"
+}
+
+// signAWSv4 adds the AWS signature version 4 authorization to the request, signing the host and all headers of the
+// request (see https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html).
+func signAWSv4(req *http.Request, accessKey, secretKey, region, service, payloadHash string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		switch name {
+		case "authorization", "user-agent", "content-length", "accept-encoding":
+			continue
+		}
+		headers[name] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsURIEscape(req.URL.Path),
+		awsCanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hexSHA256(canonicalRequest)}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+// awsURIEscape encodes everything but unreserved characters (and path separators), as required for signing.
+func awsURIEscape(path string) string {
+	if path == "" {
+		return "/"
+	}
+	var sb strings.Builder
+	for _, b := range []byte(path) {
+		if ('A' <= b && b <= 'Z') || ('a' <= b && b <= 'z') || ('0' <= b && b <= '9') || strings.IndexByte("-_.~/", b) >= 0 {
+			sb.WriteByte(b)
+			continue
+		}
+		fmt.Fprintf(&sb, "%%%02X", b)
+	}
+	return sb.String()
+}
+
+func awsCanonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, awsQueryEscape(name)+"="+awsQueryEscape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func awsQueryEscape(s string) string {
+	if s == "" {
+		return ""
+	}
+	return strings.ReplaceAll(awsURIEscape(s), "/", "%2F")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
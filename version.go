@@ -23,17 +23,23 @@ type runtimeInfo struct {
 	GoVersion string `json:"goVersion,omitempty"` // go runtime version at build-time
 	Compiler  string `json:"compiler,omitempty"`  // compiler used at build-time
 	Platform  string `json:"platform,omitempty"`  // GOOS and GOARCH at build-time
-}
 
-func VersionCommand(id Identification) *cobra.Command {
-	var format string
+	Daemon *Identification `json:"daemon,omitempty"` // the running daemon, if any (see SetupConfig.WithDaemon)
+}
 
-	info := runtimeInfo{
+func newRuntimeInfo(id Identification) runtimeInfo {
+	return runtimeInfo{
 		Identification: id,
 		GoVersion:      runtime.Version(),
 		Compiler:       runtime.Compiler,
 		Platform:       fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
 	}
+}
+
+func VersionCommand(id Identification) *cobra.Command {
+	var format string
+
+	info := newRuntimeInfo(id)
 
 	cmd := &cobra.Command{
 		Use:   "version",
@@ -41,6 +47,12 @@ func VersionCommand(id Identification) *cobra.Command {
 		Args:  cobra.NoArgs,
 		// note: we intentionally do not execute through the application infrastructure (no app config is required for this command)
 		RunE: func(cmd *cobra.Command, args []string) error {
+			// the running daemon may be of another version than this invocation (see SetupConfig.WithDaemonVersionSkew)
+			info.Daemon = nil
+			if socket := cmd.Root().Annotations[daemonSocketAnnotation]; socket != "" {
+				info.Daemon = probeDaemonVersion(socket, id)
+			}
+
 			switch format {
 			case "text", "":
 				printIfNotEmpty("Application", info.Name)
@@ -51,6 +63,9 @@ func VersionCommand(id Identification) *cobra.Command {
 				printIfNotEmpty("Platform", info.Platform)
 				printIfNotEmpty("GoVersion", info.GoVersion)
 				printIfNotEmpty("Compiler", info.Compiler)
+				if info.Daemon != nil {
+					printIfNotEmpty("DaemonVersion", displayDaemonVersion(info.Daemon.Version))
+				}
 
 			case "json":
 				enc := json.NewEncoder(os.Stdout)
@@ -74,6 +89,13 @@ func VersionCommand(id Identification) *cobra.Command {
 	return cmd
 }
 
+func displayDaemonVersion(v string) string {
+	if v == "" {
+		return "(unknown)"
+	}
+	return v
+}
+
 func printIfNotEmpty(title, value string) {
 	if value == "" {
 		return
@@ -0,0 +1,203 @@
+package clio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gookit/color"
+	"github.com/wagoodman/go-partybus"
+
+	"github.com/boss-net/go-logger/adapter/redact"
+)
+
+// WarningEvent is published on the bus the first time each distinct warning is raised with State.Warn, with the
+// Warning as the event value.
+const WarningEvent partybus.EventType = "clio-warning"
+
+// Warning is a problem worth the user's attention that does not fail the command (e.g. a skipped file), as
+// opposed to transient log output.
+type Warning struct {
+	Code    string         `json:"code" yaml:"code"`                         // a stable identifier for the kind of warning (e.g. "unreadable-file")
+	Message string         `json:"message" yaml:"message"`                   // what happened, for the user
+	Fields  map[string]any `json:"fields,omitempty" yaml:"fields,omitempty"` // details (e.g. the path)
+	Count   int            `json:"count" yaml:"count"`                       // how many times the warning was raised
+}
+
+func (w Warning) key() string {
+	var keys []string
+	for k := range w.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(w.Code + "\x00" + w.Message)
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "\x00%s=%v", k, w.Fields[k])
+	}
+	return sb.String()
+}
+
+// warnings collects the warnings raised during the run, deduplicated and in the order first raised.
+type warnings struct {
+	lock    sync.Mutex
+	entries []*Warning
+	index   map[string]*Warning
+}
+
+// Warn raises a warning, which is shown in the summary at the end of the run and included in json output written
+// with State.Encode. Warnings with the same code, message, and fields are shown once (with a count). The message and
+// fields are redacted.
+func (s *State) Warn(code, message string, fields map[string]any) {
+	if s.RedactStore != nil {
+		message = s.RedactStore.RedactString(message)
+		fields = redactFields(s.RedactStore, fields)
+	}
+	w := Warning{Code: code, Message: message, Fields: fields}
+	key := w.key()
+
+	s.warnings.lock.Lock()
+	if existing, ok := s.warnings.index[key]; ok {
+		existing.Count++
+		s.warnings.lock.Unlock()
+		return
+	}
+	if s.warnings.index == nil {
+		s.warnings.index = map[string]*Warning{}
+	}
+	w.Count = 1
+	s.warnings.index[key] = &w
+	s.warnings.entries = append(s.warnings.entries, &w)
+	s.warnings.lock.Unlock()
+
+	if s.Bus != nil {
+		s.Bus.Publish(partybus.Event{Type: WarningEvent, Value: w})
+	}
+}
+
+// redactFields returns a copy of the fields where values containing redacted values are replaced with the redacted
+// string (other values are kept as-is).
+func redactFields(store redact.Store, fields map[string]any) map[string]any {
+	if fields == nil {
+		return nil
+	}
+	out := make(map[string]any, len(fields))
+	for k, v := range fields {
+		str := fmt.Sprint(v)
+		if redacted := store.RedactString(str); redacted != str {
+			out[k] = redacted
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// Warnings returns all warnings raised so far, in the order first raised.
+func (s *State) Warnings() []Warning {
+	s.warnings.lock.Lock()
+	defer s.warnings.lock.Unlock()
+
+	out := make([]Warning, 0, len(s.warnings.entries))
+	for _, w := range s.warnings.entries {
+		out = append(out, *w)
+	}
+	return out
+}
+
+// ResultWithWarnings is the json output written by State.Encode.
+type ResultWithWarnings struct {
+	Result    any              `json:"result"`
+	Warnings  []Warning        `json:"warnings"`
+	Decisions []PolicyDecision `json:"decisions,omitempty"` // see State.Decide
+}
+
+// Encode writes the command result like OutputConfig.Encode, however json output includes the warnings raised (and
+// the policy decisions made) during the run (as a ResultWithWarnings). Other formats are unchanged, since warnings
+// are shown at the end of the run. When an output file is configured (with --output-file), the result is written to
+// the file (or uploaded, see State.Upload) rather than the writer.
+func (s *State) Encode(w io.Writer, cfg OutputConfig, result any) error {
+	if name, _ := parseOutputFormat(cfg.Format); name == "json" && cfg.Diff == "" {
+		result = ResultWithWarnings{Result: result, Warnings: s.Warnings(), Decisions: s.PolicyDecisions()}
+	}
+	if cfg.File == "" {
+		return cfg.Encode(w, result)
+	}
+
+	destination := cfg.File
+	cfg.File = ""
+	var buf bytes.Buffer
+	err := cfg.Encode(&buf, result)
+	if err != nil && !errors.Is(err, ErrResultsDiffer) {
+		return err
+	}
+	if uploadErr := s.Upload(context.Background(), destination, buf.Bytes()); uploadErr != nil {
+		return uploadErr
+	}
+	return err
+}
+
+// showWarnings writes the summary of all warnings raised during the run (unless quiet). When running under GitHub
+// Actions, the summary is written as a collapsible group with each warning as a notice annotation.
+func (a *application) showWarnings(w io.Writer) {
+	all := a.state.Warnings()
+	if len(all) == 0 || (a.state.Config.Log != nil && a.state.Config.Log.Quiet) {
+		return
+	}
+
+	noun := "warnings"
+	if len(all) == 1 {
+		noun = "warning"
+	}
+	header := fmt.Sprintf("%d %s:", len(all), noun)
+	if a.state.Config.Log != nil && a.state.Config.Log.format() == LogFormatGitHubActions {
+		defer githubActionsNotices(w, all)
+		defer githubActionsGroup(w, strings.TrimSuffix(header, ":"))()
+	}
+
+	_, _ = fmt.Fprintf(w, "%s\n", color.Yellow.Sprint(header))
+	for _, warning := range all {
+		line := fmt.Sprintf("  [%s] %s", warning.Code, warning.Message)
+
+		var keys []string
+		for k := range warning.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var fields []string
+		for _, k := range keys {
+			fields = append(fields, fmt.Sprintf("%s=%v", k, warning.Fields[k]))
+		}
+		if len(fields) > 0 {
+			line += " (" + strings.Join(fields, " ") + ")"
+		}
+		if warning.Count > 1 {
+			line += fmt.Sprintf(" x%d", warning.Count)
+		}
+		_, _ = fmt.Fprintln(w, line)
+	}
+}
+
+// githubActionsNotices writes each warning as a GitHub Actions notice annotation, titled with the warning code (and
+// with the location of the warning from the "file" and "line" fields, and so on).
+func githubActionsNotices(w io.Writer, all []Warning) {
+	for _, warning := range all {
+		properties := map[string]string{"title": warning.Code}
+		for k, v := range warning.Fields {
+			if contains(annotationProperties, k) {
+				properties[k] = fmt.Sprint(v)
+			}
+		}
+		msg := warning.Message
+		if warning.Count > 1 {
+			msg += fmt.Sprintf(" (x%d)", warning.Count)
+		}
+		_, _ = io.WriteString(w, githubCommand("notice", properties, msg))
+	}
+}
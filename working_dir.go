@@ -0,0 +1,129 @@
+package clio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/spf13/cobra"
+
+	"github.com/boss-net/fangs"
+)
+
+// WorkingDirConfig is the directory the application should behave as if it was started within (see
+// SetupConfig.WithWorkingDirFlag).
+type WorkingDirConfig struct {
+	Dir string `yaml:"cwd" json:"cwd" mapstructure:"cwd"`
+
+	// the directory a relative Dir is given in, when the configuration is loaded elsewhere (see enterConfigDir)
+	base string
+}
+
+var _ interface {
+	fangs.FlagAdder
+	fangs.PostLoader
+	CompletionFieldsDescriber
+} = (*WorkingDirConfig)(nil)
+
+func (c *WorkingDirConfig) AddFlags(flags fangs.FlagSet) {
+	flags.StringVarP(&c.Dir, "cwd", "", "run as if started in the given directory")
+}
+
+func (c *WorkingDirConfig) DescribeCompletionFields(set CompletionFieldSet) {
+	set.Dirs(&c.Dir)
+}
+
+func (c *WorkingDirConfig) PostLoad() error {
+	if c.Dir == "" {
+		return nil
+	}
+	dir := c.Dir
+	if c.base != "" && !filepath.IsAbs(dir) {
+		dir = filepath.Join(c.base, dir)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("invalid working directory %q: %w", c.Dir, err)
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("invalid working directory %q: %w", c.Dir, err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("invalid working directory %q: not a directory", c.Dir)
+	}
+	c.Dir = dir
+	return nil
+}
+
+// ResolvePath returns the given path relative to the working directory given with --cwd (if any). Absolute paths
+// are returned as-is. This should be used for any paths that are resolved before the command runs (e.g. in PostLoad),
+// since the process only changes directories for the duration of the command.
+func (s *State) ResolvePath(path string) string {
+	if s.cwd.Dir == "" || path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(s.cwd.Dir, path)
+}
+
+// enterConfigDir changes to the working directory given with --cwd (or its environment variable) while the
+// configuration is loaded, so that the .env file and config files (e.g. of the project or workspace) are found as if
+// the application was started there. A working directory given within a config file only applies to the command. The
+// returned function restores the original working directory.
+func (a *application) enterConfigDir(cmd *cobra.Command) (func(), error) {
+	a.state.cwd.base = ""
+	flag := flagsByRef(cmd)[reflect.ValueOf(&a.state.cwd.Dir).Pointer()]
+	if flag == nil {
+		return func() {}, nil
+	}
+	dir := flag.Value.String()
+	if !flag.Changed {
+		dir = os.Getenv(envVarName(a.setupConfig.FangsConfig.AppName, []string{"cwd"}))
+	}
+	if dir == "" {
+		return func() {}, nil
+	}
+
+	original, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine working directory: %w", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		return nil, fmt.Errorf("invalid working directory %q: %w", dir, err)
+	}
+	a.state.cwd.base = original
+
+	return func() {
+		if err := os.Chdir(original); err != nil {
+			if log := a.state.currentLogger(); log != nil {
+				log.Warnf("unable to restore working directory %q: %v", original, err)
+			}
+		}
+	}, nil
+}
+
+// enterWorkingDir changes to the working directory given with --cwd (if any), returning a function that restores
+// the original working directory.
+func (s *State) enterWorkingDir() (func(), error) {
+	if s.cwd.Dir == "" {
+		return func() {}, nil
+	}
+
+	original, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine working directory: %w", err)
+	}
+
+	if err := os.Chdir(s.cwd.Dir); err != nil {
+		return nil, fmt.Errorf("unable to change to working directory: %w", err)
+	}
+
+	return func() {
+		if err := os.Chdir(original); err != nil {
+			if log := s.currentLogger(); log != nil {
+				log.Warnf("unable to restore working directory %q: %v", original, err)
+			}
+		}
+	}, nil
+}
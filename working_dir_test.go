@@ -0,0 +1,145 @@
+package clio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Application_WorkingDirFlag(t *testing.T) {
+	dir := t.TempDir()
+	// resolve any symlinks (e.g. /tmp on macOS) since the working directory is compared
+	dir, err := filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+
+	original, err := os.Getwd()
+	require.NoError(t, err)
+
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithWorkingDirFlag())
+	root := app.SetupRootCommand(&cobra.Command{})
+
+	var ranIn string
+	sub := app.SetupCommand(&cobra.Command{
+		Use: "sub",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			ranIn, err = os.Getwd()
+			return err
+		},
+	})
+	root.AddCommand(sub)
+
+	root.SetArgs([]string{"sub", "--cwd", dir})
+	require.NoError(t, root.Execute())
+
+	assert.Equal(t, dir, ranIn)
+
+	// the working directory is restored after the command runs
+	current, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, original, current)
+
+	state := app.(*application).State()
+	assert.Equal(t, filepath.Join(dir, "report.json"), state.ResolvePath("report.json"))
+	assert.Equal(t, "/abs/report.json", state.ResolvePath("/abs/report.json"))
+}
+
+func Test_Application_WorkingDirFlag_config(t *testing.T) {
+	// resolve any symlinks (e.g. /tmp on macOS) since the working directory is compared
+	parent, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	dir := filepath.Join(parent, "project")
+	require.NoError(t, os.Mkdir(dir, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("CLIO_TEST_CWD_DOTENV=found\n"), 0o600))
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(parent))
+	defer func() { require.NoError(t, os.Chdir(wd)) }()
+
+	for _, tt := range []struct {
+		name string
+		args []string
+		env  string
+	}{
+		{name: "flag", args: []string{"--cwd", "project"}},
+		{name: "env", env: "project"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { _ = os.Unsetenv("CLIO_TEST_CWD_DOTENV") })
+			if tt.env != "" {
+				t.Setenv("APP_CWD", tt.env)
+			}
+
+			app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithWorkingDirFlag().WithDotEnv(""))
+			root := app.SetupRootCommand(&cobra.Command{})
+			var ranIn, value string
+			root.AddCommand(app.SetupCommand(&cobra.Command{
+				Use: "sub",
+				RunE: func(cmd *cobra.Command, args []string) error {
+					value = os.Getenv("CLIO_TEST_CWD_DOTENV")
+					var err error
+					ranIn, err = os.Getwd()
+					return err
+				},
+			}))
+
+			root.SetArgs(append([]string{"sub"}, tt.args...))
+			require.NoError(t, root.Execute())
+
+			// the .env file is found in the working directory, which is relative to where the application started
+			assert.Equal(t, "found", value)
+			assert.Equal(t, dir, ranIn)
+			current, err := os.Getwd()
+			require.NoError(t, err)
+			assert.Equal(t, parent, current)
+		})
+	}
+}
+
+func Test_WorkingDirConfig_PostLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o600))
+
+	tests := []struct {
+		name    string
+		dir     string
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name: "directory",
+			dir:  t.TempDir(),
+		},
+		{
+			name:    "missing",
+			dir:     filepath.Join(t.TempDir(), "missing"),
+			wantErr: require.Error,
+		},
+		{
+			name:    "file",
+			dir:     file,
+			wantErr: require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+			cfg := &WorkingDirConfig{Dir: tt.dir}
+			tt.wantErr(t, cfg.PostLoad())
+		})
+	}
+}
+
+func Test_State_ResolvePath_noWorkingDir(t *testing.T) {
+	s := &State{}
+	assert.Equal(t, "report.json", s.ResolvePath("report.json"))
+}
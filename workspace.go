@@ -0,0 +1,165 @@
+package clio
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/boss-net/fangs"
+)
+
+// WorkspaceConfig selects the workspace (project) root, overriding detection (see SetupConfig.WithWorkspace).
+type WorkspaceConfig struct {
+	Root string `yaml:"-" json:"-" mapstructure:"workspace"`
+}
+
+var _ interface {
+	fangs.FlagAdder
+	CompletionFieldsDescriber
+} = (*WorkspaceConfig)(nil)
+
+func (c *WorkspaceConfig) AddFlags(flags fangs.FlagSet) {
+	flags.StringVarP(&c.Root, "workspace", "", "the workspace (project) root (default: detected from the working directory)")
+}
+
+func (c *WorkspaceConfig) DescribeCompletionFields(set CompletionFieldSet) {
+	set.Dirs(&c.Root)
+}
+
+// Workspace is the project the application is running within, such as a repository within a monorepo checkout.
+// Each workspace has its own config file, layered over the user config file, and its own cache and state dirs, so
+// that work in one workspace does not affect another.
+type Workspace struct {
+	// Root is the workspace root directory, or empty when not running within a workspace.
+	Root string
+
+	// ConfigFile is the workspace config file (.<app>.yaml or .<app>/config.yaml within the root), or empty if there
+	// is none.
+	ConfigFile string
+
+	// CacheDir and StateDir are the directories for the cache and persistent state of the workspace, which are kept
+	// outside of the workspace (within the user cache and state dirs). These are not created until used (see
+	// State.MkdirAll).
+	CacheDir string
+	StateDir string
+}
+
+// Workspace returns the workspace the application is running within (see SetupConfig.WithWorkspace). The root is
+// empty when not running within a workspace.
+func (s *State) Workspace() Workspace {
+	return s.workspace
+}
+
+// workspaceMarkers returns the files (or directories) that mark the root of a workspace, the application config
+// dir and version control roots by default.
+func (a *application) workspaceMarkers() []string {
+	if len(a.setupConfig.WorkspaceMarkers) > 0 {
+		return a.setupConfig.WorkspaceMarkers
+	}
+	return []string{"." + a.setupConfig.ID.Name, ".git", ".hg", ".svn"}
+}
+
+// detectWorkspace determines the workspace from the --workspace flag, or otherwise the nearest directory containing
+// a marker file, starting from the working directory (see SetupConfig.WithWorkingDirFlag).
+func (a *application) detectWorkspace() error {
+	a.state.workspace = Workspace{}
+	if !a.setupConfig.Workspace {
+		return nil
+	}
+
+	root := a.state.workspaceCfg.Root
+	if root != "" {
+		abs, err := filepath.Abs(a.state.ResolvePath(root))
+		if err != nil {
+			return fmt.Errorf("invalid workspace %q: %w", root, err)
+		}
+		if fi, err := os.Stat(abs); err != nil || !fi.IsDir() {
+			return fmt.Errorf("invalid workspace %q: not a directory", root)
+		}
+		root = abs
+	} else {
+		start, err := filepath.Abs(a.state.ResolvePath("."))
+		if err != nil {
+			return fmt.Errorf("unable to determine working directory: %w", err)
+		}
+		root = findWorkspaceRoot(start, a.workspaceMarkers())
+	}
+	if root == "" {
+		return nil
+	}
+
+	ws := Workspace{
+		Root:       root,
+		ConfigFile: workspaceConfigFile(root, a.setupConfig.ID.Name),
+	}
+
+	key := workspaceKey(root)
+	if dir, err := userCacheDir(a.setupConfig.ID.Name); err == nil {
+		ws.CacheDir = filepath.Join(dir, a.setupConfig.ID.Name, "workspaces", key)
+	}
+	if dir, err := stateDir(a.setupConfig.ID.Name); err == nil {
+		ws.StateDir = filepath.Join(dir, "workspaces", key)
+	}
+
+	a.state.workspace = ws
+	return nil
+}
+
+// findWorkspaceRoot returns the nearest directory (the given directory or a parent) containing any of the markers,
+// or empty if there is none.
+func findWorkspaceRoot(dir string, markers []string) string {
+	for {
+		for _, m := range markers {
+			if _, err := os.Stat(filepath.Join(dir, m)); err == nil {
+				return dir
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func workspaceConfigFile(root, appName string) string {
+	for _, name := range []string{
+		"." + appName + ".yaml",
+		"." + appName + ".yml",
+		"." + appName + ".json",
+		filepath.Join("."+appName, "config.yaml"),
+		filepath.Join("."+appName, "config.yml"),
+		filepath.Join("."+appName, "config.json"),
+	} {
+		file := filepath.Join(root, name)
+		if fi, err := os.Stat(file); err == nil && !fi.IsDir() {
+			return file
+		}
+	}
+	return ""
+}
+
+// workspaceKey identifies the workspace within the user cache and state dirs, readable (by the directory name) and
+// unique (by the hash of the full path).
+func workspaceKey(root string) string {
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Base(root) + "-" + hex.EncodeToString(sum[:6])
+}
+
+// sameFile indicates both paths refer to the same file.
+func sameFile(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fa, fb)
+}
@@ -0,0 +1,160 @@
+package clio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type workspaceTestConfig struct {
+	Name    string `yaml:"name" json:"name" mapstructure:"name"`
+	Workers int    `yaml:"workers" json:"workers" mapstructure:"workers"`
+}
+
+func Test_findWorkspaceRoot(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "services", "api")
+	require.NoError(t, os.MkdirAll(filepath.Join(nested, "src"), 0o755))
+	require.NoError(t, os.Mkdir(filepath.Join(root, ".git"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(nested, ".app"), nil, 0o600))
+
+	tests := []struct {
+		name    string
+		dir     string
+		markers []string
+		want    string
+	}{
+		{
+			name:    "nearest marker wins",
+			dir:     filepath.Join(nested, "src"),
+			markers: []string{".app", ".git"},
+			want:    nested,
+		},
+		{
+			name:    "marker in the directory itself",
+			dir:     root,
+			markers: []string{".app", ".git"},
+			want:    root,
+		},
+		{
+			name:    "only the given markers",
+			dir:     filepath.Join(nested, "src"),
+			markers: []string{".git"},
+			want:    root,
+		},
+		{
+			name:    "no marker",
+			dir:     filepath.Join(nested, "src"),
+			markers: []string{".does-not-exist"},
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, findWorkspaceRoot(tt.dir, tt.markers))
+		})
+	}
+}
+
+func Test_workspaceKey(t *testing.T) {
+	a := workspaceKey(filepath.Join("repos", "one", "api"))
+	b := workspaceKey(filepath.Join("repos", "two", "api"))
+
+	assert.Regexp(t, `^api-[0-9a-f]{12}$`, a)
+	assert.NotEqual(t, a, b)
+	assert.Equal(t, a, workspaceKey(filepath.Join("repos", "one", "api")))
+}
+
+func Test_Application_Workspace(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	home := t.TempDir()
+	userFile := filepath.Join(home, "config.yaml")
+	require.NoError(t, os.WriteFile(userFile, []byte("name: user\nworkers: 2\n"), 0o600))
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	sub := filepath.Join(root, "pkg")
+	require.NoError(t, os.Mkdir(sub, 0o755))
+	require.NoError(t, os.Mkdir(filepath.Join(root, ".app"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".app", "config.yaml"), []byte("workers: 8\n"), 0o600))
+
+	other, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantRoot    string
+		wantWorkers int
+	}{
+		{
+			name:        "detected from the working directory",
+			args:        []string{"sub", "--cwd", sub},
+			wantRoot:    root,
+			wantWorkers: 8,
+		},
+		{
+			name:        "given with the flag",
+			args:        []string{"sub", "--workspace", root},
+			wantRoot:    root,
+			wantWorkers: 8,
+		},
+		{
+			name:        "not within a workspace",
+			args:        []string{"sub", "--workspace", other},
+			wantRoot:    other,
+			wantWorkers: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewSetupConfig(Identification{Name: "app"}).
+				WithNoBus().
+				WithWorkingDirFlag().
+				WithWorkspace(".app")
+			cfg.FangsConfig.File = userFile
+
+			app := New(*cfg)
+			root := app.SetupRootCommand(&cobra.Command{})
+
+			c := &workspaceTestConfig{}
+			var ws Workspace
+			root.AddCommand(app.SetupCommand(&cobra.Command{
+				Use: "sub",
+				RunE: func(cmd *cobra.Command, args []string) error {
+					ws = app.(*application).State().Workspace()
+					return nil
+				},
+			}, c))
+
+			root.SetArgs(tt.args)
+			require.NoError(t, root.Execute())
+
+			assert.Equal(t, tt.wantRoot, ws.Root)
+			assert.Equal(t, "user", c.Name)
+			assert.Equal(t, tt.wantWorkers, c.Workers)
+
+			key := workspaceKey(tt.wantRoot)
+			assert.Equal(t, filepath.Join(os.Getenv("XDG_CACHE_HOME"), "app", "workspaces", key), ws.CacheDir)
+			assert.Equal(t, key, filepath.Base(ws.StateDir))
+		})
+	}
+}
+
+func Test_Application_Workspace_InvalidFlag(t *testing.T) {
+	app := New(*NewSetupConfig(Identification{Name: "app"}).WithNoBus().WithWorkspace())
+	root := app.SetupRootCommand(&cobra.Command{})
+	root.AddCommand(app.SetupCommand(&cobra.Command{
+		Use:  "sub",
+		RunE: func(cmd *cobra.Command, args []string) error { return nil },
+	}))
+
+	root.SetArgs([]string{"sub", "--workspace", filepath.Join(t.TempDir(), "missing")})
+	require.ErrorContains(t, root.Execute(), "invalid workspace")
+}